@@ -0,0 +1,69 @@
+// Command auditverify walks the hash chain of the audit log and reports the first broken link.
+// It exits with status 1 when the chain is broken, so it can run from cron or CI.
+//
+// Pass -head with a hash printed by an earlier run to also detect that the newest entries
+// were removed since then.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/config"
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+)
+
+func main() {
+	head := flag.String("head", "", "hash of the last entry reported by an earlier run, which must still be in the chain")
+	flag.Parse()
+
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found, using system environment variables")
+	}
+
+	cfg := config.Load()
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	result, err := audit.Verify(ctx, db)
+	if err != nil {
+		log.Fatalf("Failed to verify audit chain: %v", err)
+	}
+
+	if result.Unchained > 0 {
+		fmt.Printf("%d entries written before the chain started were not checked\n", result.Unchained)
+	}
+	fmt.Printf("%d chained entries checked\n", result.Checked)
+
+	if result.Broken != nil {
+		fmt.Printf("BROKEN at entry %d: %s\n", result.Broken.EntryID, result.Broken.Reason)
+		os.Exit(1)
+	}
+
+	if *head != "" {
+		found, err := audit.InChain(ctx, db, *head, result.HeadID)
+		if err != nil {
+			log.Fatalf("Failed to look up head hash: %v", err)
+		}
+		if !found {
+			fmt.Printf("BROKEN: the entry with hash %s is no longer in the chain; entries were removed\n", *head)
+			os.Exit(1)
+		}
+	}
+
+	if result.Checked > 0 {
+		fmt.Printf("Chain intact up to entry %d, head hash %s\n", result.HeadID, result.HeadHash)
+	} else {
+		fmt.Println("The chain has no entries yet")
+	}
+}
@@ -23,6 +23,7 @@ func main() {
 		Username: getEnv("SMTP_USERNAME", ""),
 		Password: getEnv("SMTP_PASSWORD", ""),
 		From:     getEnv("SMTP_FROM", "noreply@bairesdev.com"),
+		FromName: getEnv("SMTP_FROM_NAME", ""),
 	})
 	if err != nil {
 		log.Fatalf("❌ Failed to create email client: %v", err)
@@ -125,7 +126,6 @@ func sendMagicLinkTest(client *email.Client, templates *email.EmailTemplates, re
 	return client.Send(email.Message{
 		To:       []string{recipient},
 		Subject:  templates.GetSubject("magic_link", data),
-		Body:     "Please view this email in an HTML-capable email client.",
 		HTMLBody: html,
 	})
 }
@@ -147,7 +147,6 @@ func sendAddressConfirmationTest(client *email.Client, templates *email.EmailTem
 	return client.Send(email.Message{
 		To:       []string{recipient},
 		Subject:  templates.GetSubject("address_confirmation", data),
-		Body:     "Please view this email in an HTML-capable email client.",
 		HTMLBody: html,
 	})
 }
@@ -171,7 +170,6 @@ func sendPickupConfirmationTest(client *email.Client, templates *email.EmailTemp
 	return client.Send(email.Message{
 		To:       []string{recipient},
 		Subject:  templates.GetSubject("pickup_confirmation", data),
-		Body:     "Please view this email in an HTML-capable email client.",
 		HTMLBody: html,
 	})
 }
@@ -195,7 +193,6 @@ func sendWarehousePreAlertTest(client *email.Client, templates *email.EmailTempl
 	return client.Send(email.Message{
 		To:       []string{recipient},
 		Subject:  templates.GetSubject("warehouse_pre_alert", data),
-		Body:     "Please view this email in an HTML-capable email client.",
 		HTMLBody: html,
 	})
 }
@@ -222,7 +219,6 @@ func sendReleaseNotificationTest(client *email.Client, templates *email.EmailTem
 	return client.Send(email.Message{
 		To:       []string{recipient},
 		Subject:  templates.GetSubject("release_notification", data),
-		Body:     "Please view this email in an HTML-capable email client.",
 		HTMLBody: html,
 	})
 }
@@ -245,7 +241,6 @@ func sendDeliveryConfirmationTest(client *email.Client, templates *email.EmailTe
 	return client.Send(email.Message{
 		To:       []string{recipient},
 		Subject:  templates.GetSubject("delivery_confirmation", data),
-		Body:     "Please view this email in an HTML-capable email client.",
 		HTMLBody: html,
 	})
 }
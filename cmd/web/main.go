@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -15,14 +17,20 @@ import (
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 
+	"github.com/yourusername/laptop-tracking-system/internal/api"
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
 	"github.com/yourusername/laptop-tracking-system/internal/config"
 	"github.com/yourusername/laptop-tracking-system/internal/database"
+	"github.com/yourusername/laptop-tracking-system/internal/documents"
 	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/handlers"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/tracking"
 	"github.com/yourusername/laptop-tracking-system/internal/utils"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
+	"github.com/yourusername/laptop-tracking-system/internal/workflow"
 )
 
 func main() {
@@ -82,6 +90,8 @@ func main() {
 			switch val := v.(type) {
 			case []models.TimelineItem:
 				return len(val)
+			case []models.ShipmentPackage:
+				return len(val)
 			case []interface{}:
 				return len(val)
 			default:
@@ -92,6 +102,11 @@ func main() {
 		"getNav": func(role models.UserRole) views.NavigationLinks {
 			return views.GetNavigationLinks(role)
 		},
+		"can": func(user *models.User, perm string) bool {
+			return permissions.Can(user, permissions.Permission(perm))
+		},
+		// Hidden CSRF token field for POST forms
+		"csrfField": middleware.CSRFField,
 		// Calendar template functions
 		"formatDate": func(t time.Time) string {
 			return t.Format("Jan 2, 2006")
@@ -113,8 +128,10 @@ func main() {
 			switch status {
 			case models.ShipmentStatusPendingPickup:
 				return "bg-yellow-400"
-			case models.ShipmentStatusPickedUpFromClient:
+			case models.ShipmentStatusPickedUpFromClient, models.ShipmentStatusPickedUpFromEngineer:
 				return "bg-orange-400"
+			case models.ShipmentStatusReturnKitSent, models.ShipmentStatusReturnKitDelivered:
+				return "bg-teal-400"
 			case models.ShipmentStatusInTransitToWarehouse:
 				return "bg-purple-400"
 			case models.ShipmentStatusAtWarehouse:
@@ -125,6 +142,12 @@ func main() {
 				return "bg-cyan-400"
 			case models.ShipmentStatusDelivered:
 				return "bg-green-400"
+			case models.ShipmentStatusOnHold:
+				return "bg-amber-400"
+			case models.ShipmentStatusLost, models.ShipmentStatusDamaged:
+				return "bg-red-500"
+			case models.ShipmentStatusReturnedToSender, models.ShipmentStatusCancelled:
+				return "bg-gray-500"
 			default:
 				return "bg-gray-400"
 			}
@@ -143,6 +166,8 @@ func main() {
 				return "bg-blue-400"
 			case models.LaptopStatusRetired:
 				return "bg-gray-400"
+			case models.LaptopStatusLost, models.LaptopStatusDamaged:
+				return "bg-red-500"
 			default:
 				return "bg-gray-400"
 			}
@@ -162,6 +187,8 @@ func main() {
 				return "bg-blue-100 text-blue-800"
 			case models.LaptopStatusRetired:
 				return "bg-gray-100 text-gray-800"
+			case models.LaptopStatusLost, models.LaptopStatusDamaged:
+				return "bg-red-100 text-red-800"
 			default:
 				return "bg-gray-100 text-gray-800"
 			}
@@ -267,6 +294,7 @@ func main() {
 		Username: cfg.SMTP.User,
 		Password: cfg.SMTP.Password,
 		From:     cfg.SMTP.From,
+		FromName: cfg.SMTP.FromName,
 	})
 	if err != nil {
 		log.Printf("Warning: Failed to initialize email client: %v", err)
@@ -277,14 +305,61 @@ func main() {
 	var notifier *email.Notifier
 	if emailClient != nil {
 		// Use NewNotifierWithConfig to pass SMTP config for default emails
-		notifier = email.NewNotifierWithConfig(emailClient, db, &cfg.SMTP)
+		notifier = email.NewNotifierWithConfig(emailClient, db, &cfg.SMTP).WithBaseURL(cfg.App.BaseURL)
 		log.Println("Email notifications enabled")
+
+		// Deliver the notifications queued in the email outbox
+		go email.NewOutbox(db, notifier, cfg.SMTP.OutboxMaxAttempts).Run(context.Background(), time.Duration(cfg.SMTP.OutboxPollInterval)*time.Second)
+	}
+
+	// Initialize courier tracking
+	trackingRegistry, err := tracking.NewRegistryFromConfig(cfg.Tracking)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		log.Println("Courier tracking will be disabled")
+	}
+	trackingService := tracking.NewService(db, trackingRegistry, workflow.NewEngine(db, notifier), cfg.Tracking.AutoAdvance)
+	if !trackingRegistry.Empty() {
+		go trackingService.Run(context.Background(), time.Duration(cfg.Tracking.PollInterval)*time.Second)
+		log.Printf("Courier tracking enabled (provider: %s, auto-advance: %t)", cfg.Tracking.Provider, cfg.Tracking.AutoAdvance)
+	}
+
+	// Load the role permissions changed by admins; the defaults apply until then
+	if err := permissions.Load(context.Background(), db); err != nil {
+		log.Printf("Warning: Failed to load role permissions, using defaults: %v", err)
+	}
+	// Reload them regularly so changes saved on another instance, including revocations, apply here too
+	go permissions.Run(context.Background(), db, time.Duration(cfg.Security.PermissionsReloadInterval)*time.Second)
+
+	// Save the built-in email templates as the first version of templates that have none
+	if err := email.SeedTemplates(context.Background(), db); err != nil {
+		log.Printf("Warning: Failed to seed email templates, using built-in defaults: %v", err)
 	}
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(db, templates)
 	authHandler.OAuthConfig = oauthConfig
 	authHandler.OAuthDomain = cfg.Google.AllowedDomain
+	authHandler.OIDCProviders = newOIDCProviders(db, cfg)
+	authHandler.LoginLimits = auth.LoginLimits{
+		Window:                time.Duration(cfg.Security.LoginFailureWindow) * time.Minute,
+		MaxFailuresPerIP:      cfg.Security.LoginMaxFailuresPerIP,
+		MaxFailuresPerAccount: cfg.Security.LoginMaxFailuresPerAccount,
+		LockoutThreshold:      cfg.Security.LoginLockoutThreshold,
+		LockoutDuration:       time.Duration(cfg.Security.LoginLockoutDuration) * time.Minute,
+	}
+	authHandler.TrustProxyHeaders = cfg.Security.TrustProxyHeaders
+	authHandler.TwoFactorIssuer = cfg.Security.TwoFactorIssuer
+	authHandler.Notifier = notifier
+	authHandler.TwoFactorRequiredRoles = map[models.UserRole]bool{}
+	for _, role := range strings.Split(cfg.Security.TwoFactorRequiredRoles, ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			if !models.IsValidRole(models.UserRole(role)) {
+				log.Fatalf("Invalid role in TWO_FACTOR_REQUIRED_ROLES: %q", role)
+			}
+			authHandler.TwoFactorRequiredRoles[models.UserRole(role)] = true
+		}
+	}
 
 	dashboardHandler := handlers.NewDashboardHandler(db, templates)
 	chartsHandler := handlers.NewChartsHandler(db)
@@ -294,9 +369,15 @@ func main() {
 	receptionReportHandler := handlers.NewReceptionReportHandler(db, templates, notifier)
 	deliveryFormHandler := handlers.NewDeliveryFormHandler(db, templates, notifier)
 	shipmentsHandler := handlers.NewShipmentsHandler(db, templates, notifier)
+	shipmentsHandler.Tracking = trackingService
+	shipmentsHandler.Warehouse = documents.WarehouseAddress(cfg.Warehouse)
+	courierWebhookHandler := handlers.NewCourierWebhookHandler(trackingService)
 	formsHandler := handlers.NewFormsHandler(db, templates)
+	formsHandler.Notifier = notifier
 	reportsHandler := handlers.NewReportsHandler(db, templates)
 	aboutHandler := handlers.NewAboutHandler(db, templates)
+	apiTokensHandler := handlers.NewAPITokensHandler(db, templates)
+	apiHandler := api.NewHandler(db, notifier)
 
 	// Initialize router
 	router := mux.NewRouter()
@@ -304,6 +385,12 @@ func main() {
 	// Apply auth middleware globally
 	router.Use(middleware.AuthMiddleware(db))
 
+	// Check CSRF tokens on every form post (API token requests and courier webhooks are exempt)
+	if cfg.Security.CSRFSecret == "change-me-in-production" {
+		log.Println("Warning: CSRF_SECRET is not set, using the default secret")
+	}
+	router.Use(middleware.CSRF(cfg.Security.CSRFSecret))
+
 	// Public routes
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Check if user is authenticated
@@ -323,14 +410,29 @@ func main() {
 	// Authentication routes (public)
 	router.HandleFunc("/login", authHandler.LoginPage).Methods("GET")
 	router.HandleFunc("/login", authHandler.Login).Methods("POST")
+	router.HandleFunc("/login/2fa", authHandler.TwoFactorPage).Methods("GET")
+	router.HandleFunc("/login/2fa", authHandler.TwoFactorSubmit).Methods("POST")
+	router.HandleFunc("/forgot-password", authHandler.ForgotPasswordPage).Methods("GET")
+	router.HandleFunc("/forgot-password", authHandler.ForgotPassword).Methods("POST")
+	router.HandleFunc("/set-password", authHandler.SetPasswordPage).Methods("GET")
+	router.HandleFunc("/set-password", authHandler.SetPassword).Methods("POST")
 	router.HandleFunc("/logout", authHandler.Logout).Methods("POST", "GET")
 	router.HandleFunc("/auth/google", authHandler.GoogleLogin).Methods("GET")
 	router.HandleFunc("/auth/google/callback", authHandler.GoogleCallback).Methods("GET")
+	router.HandleFunc("/auth/oidc/{provider}", authHandler.OIDCLogin).Methods("GET")
+	router.HandleFunc("/auth/oidc/{provider}/callback", authHandler.OIDCCallback).Methods("GET")
 	router.HandleFunc("/auth/magic-link", authHandler.MagicLinkLogin).Methods("GET")
 
+	// Courier tracking webhooks (authenticated by the provider's signature)
+	router.HandleFunc("/webhooks/courier/{provider}", courierWebhookHandler.Receive).Methods("POST")
+
+	// JSON API (answers 401 itself instead of redirecting to the login page)
+	apiHandler.RegisterRoutes(router)
+
 	// Protected routes (require authentication)
 	protected := router.PathPrefix("/").Subrouter()
 	protected.Use(middleware.RequireAuth)
+	protected.Use(middleware.RejectAPITokens)
 
 	// Dashboard
 	protected.HandleFunc("/dashboard", dashboardHandler.Dashboard).Methods("GET")
@@ -351,6 +453,11 @@ func main() {
 	protected.HandleFunc("/forms/users/add", formsHandler.UserAddSubmit).Methods("POST")
 	protected.HandleFunc("/forms/users/{id:[0-9]+}/edit", formsHandler.UserEditPage).Methods("GET")
 	protected.HandleFunc("/forms/users/{id:[0-9]+}/edit", formsHandler.UserEditSubmit).Methods("POST")
+	protected.HandleFunc("/forms/users/{id:[0-9]+}/unlock", formsHandler.UserUnlock).Methods("POST")
+	protected.HandleFunc("/forms/users/{id:[0-9]+}/reset-2fa", formsHandler.UserResetTwoFactor).Methods("POST")
+	protected.HandleFunc("/forms/users/{id:[0-9]+}/resend-invitation", formsHandler.UserResendInvitation).Methods("POST")
+	protected.HandleFunc("/forms/users/{id:[0-9]+}/sessions", formsHandler.UserSessionsPage).Methods("GET")
+	protected.HandleFunc("/forms/users/{id:[0-9]+}/sessions/revoke", formsHandler.UserRevokeSessions).Methods("POST")
 	
 	// Client company management routes
 	protected.HandleFunc("/forms/client-companies", formsHandler.ClientCompaniesList).Methods("GET")
@@ -372,6 +479,24 @@ func main() {
 	protected.HandleFunc("/forms/couriers/add", formsHandler.CourierAddSubmit).Methods("POST")
 	protected.HandleFunc("/forms/couriers/{id:[0-9]+}/edit", formsHandler.CourierEditPage).Methods("GET")
 	protected.HandleFunc("/forms/couriers/{id:[0-9]+}/edit", formsHandler.CourierEditSubmit).Methods("POST")
+	protected.HandleFunc("/forms/workflows", formsHandler.WorkflowsList).Methods("GET")
+	protected.HandleFunc("/forms/workflows/{type}/edit", formsHandler.WorkflowEditPage).Methods("GET")
+	protected.HandleFunc("/forms/workflows/{type}/edit", formsHandler.WorkflowEditSubmit).Methods("POST")
+	protected.HandleFunc("/forms/workflows/{type}/reset", formsHandler.WorkflowResetSubmit).Methods("POST")
+	protected.HandleFunc("/forms/audit-logs", formsHandler.AuditLogsPage).Methods("GET")
+	protected.HandleFunc("/forms/email-outbox", formsHandler.EmailOutboxPage).Methods("GET")
+	protected.HandleFunc("/forms/email-outbox/{id:[0-9]+}/retry", formsHandler.EmailOutboxRetry).Methods("POST")
+	protected.HandleFunc("/forms/email-templates", formsHandler.EmailTemplatesList).Methods("GET")
+	protected.HandleFunc("/forms/email-templates/{name}/edit", formsHandler.EmailTemplateEditPage).Methods("GET")
+	protected.HandleFunc("/forms/email-templates/{name}/edit", formsHandler.EmailTemplateEditSubmit).Methods("POST")
+	protected.HandleFunc("/forms/email-templates/{name}/preview", formsHandler.EmailTemplatePreview).Methods("POST")
+	protected.HandleFunc("/forms/email-templates/{name}/versions/{version:[0-9]+}/restore", formsHandler.EmailTemplateRestoreVersion).Methods("POST")
+	protected.HandleFunc("/forms/email-templates/{name}/reset", formsHandler.EmailTemplateResetSubmit).Methods("POST")
+
+	// Role permissions (permission.manage only)
+	requirePermissionManage := middleware.RequirePermission(permissions.PermissionManage)
+	protected.Handle("/forms/permissions", requirePermissionManage(http.HandlerFunc(formsHandler.PermissionsPage))).Methods("GET")
+	protected.Handle("/forms/permissions", requirePermissionManage(http.HandlerFunc(formsHandler.PermissionsSubmit))).Methods("POST")
 
 	// Inventory routes
 	protected.HandleFunc("/inventory", inventoryHandler.InventoryList).Methods("GET")
@@ -415,6 +540,7 @@ func main() {
 	protected.HandleFunc("/shipments/create/single-minimal", pickupFormHandler.CreateMinimalSingleShipment).Methods("POST")
 	protected.HandleFunc("/shipments/create/bulk", pickupFormHandler.BulkShipmentFormPage).Methods("GET")
 	protected.HandleFunc("/shipments/create/warehouse-to-engineer", pickupFormHandler.WarehouseToEngineerFormPage).Methods("GET")
+	protected.HandleFunc("/shipments/create/engineer-to-warehouse", pickupFormHandler.EngineerToWarehouseFormPage).Methods("GET")
 
 	// Reception report routes (laptop-based)
 	protected.HandleFunc("/reception-reports", receptionReportHandler.LaptopBasedReceptionReportsList).Methods("GET")
@@ -460,6 +586,13 @@ func main() {
 	protected.HandleFunc("/shipments/{id:[0-9]+}/complete-details", pickupFormHandler.CompleteShipmentDetails).Methods("POST")
 	protected.HandleFunc("/shipments/{id:[0-9]+}/edit-details", pickupFormHandler.EditShipmentDetails).Methods("POST")
 	protected.HandleFunc("/shipments/{id:[0-9]+}/laptops/add", shipmentsHandler.AddLaptopToBulkShipment).Methods("POST")
+	protected.HandleFunc("/shipments/{id:[0-9]+}/packages", shipmentsHandler.CreateShipmentPackage).Methods("POST")
+	protected.HandleFunc("/shipments/{id:[0-9]+}/packages/{packageID:[0-9]+}", shipmentsHandler.UpdateShipmentPackage).Methods("POST")
+	protected.HandleFunc("/shipments/{id:[0-9]+}/packages/{packageID:[0-9]+}/delete", shipmentsHandler.DeleteShipmentPackage).Methods("POST")
+	protected.HandleFunc("/shipments/{id:[0-9]+}/tracking/refresh", shipmentsHandler.RefreshShipmentTracking).Methods("POST")
+	protected.HandleFunc("/shipments/{id:[0-9]+}/packing-slip", shipmentsHandler.PackingSlip).Methods("GET")
+	protected.HandleFunc("/shipments/{id:[0-9]+}/commercial-invoice", shipmentsHandler.CommercialInvoice).Methods("GET")
+	protected.HandleFunc("/shipments/packing-slips/released-today", shipmentsHandler.PackingSlipsReleasedToday).Methods("GET")
 
 	// Reports routes (Client and Project Manager users)
 	protected.HandleFunc("/reports", reportsHandler.ReportsIndex).Methods("GET")
@@ -470,6 +603,24 @@ func main() {
 	// About page (accessible to all authenticated users)
 	protected.HandleFunc("/about", aboutHandler.About).Methods("GET")
 
+	// Two-factor authentication of the logged-in user
+	protected.HandleFunc("/account/two-factor", authHandler.AccountTwoFactorPage).Methods("GET")
+	protected.HandleFunc("/account/two-factor/enable", authHandler.AccountTwoFactorEnable).Methods("POST")
+	protected.HandleFunc("/account/two-factor/recovery-codes", authHandler.AccountTwoFactorRecoveryCodes).Methods("POST")
+	protected.HandleFunc("/account/two-factor/disable", authHandler.AccountTwoFactorDisable).Methods("POST")
+	protected.HandleFunc("/account/sessions", authHandler.AccountSessionsPage).Methods("GET")
+	protected.HandleFunc("/account/sessions/revoke-others", authHandler.AccountSessionsRevokeOthers).Methods("POST")
+	protected.HandleFunc("/account/sessions/{id:[0-9]+}/revoke", authHandler.AccountSessionRevoke).Methods("POST")
+
+	// API tokens and service accounts
+	protected.HandleFunc("/api-tokens", apiTokensHandler.APITokensPage).Methods("GET")
+	protected.HandleFunc("/api-tokens", apiTokensHandler.CreatePersonalToken).Methods("POST")
+	protected.HandleFunc("/api-tokens/{id:[0-9]+}/revoke", apiTokensHandler.RevokeToken).Methods("POST")
+	protected.HandleFunc("/service-accounts", apiTokensHandler.CreateServiceAccount).Methods("POST")
+	protected.HandleFunc("/service-accounts/{id:[0-9]+}/tokens", apiTokensHandler.CreateServiceAccountToken).Methods("POST")
+	protected.HandleFunc("/service-accounts/{id:[0-9]+}/disable", apiTokensHandler.DisableServiceAccount).Methods("POST")
+	protected.HandleFunc("/service-accounts/{id:[0-9]+}/enable", apiTokensHandler.EnableServiceAccount).Methods("POST")
+
 	// Serve static files
 	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/",
 		http.FileServer(http.Dir("./static"))))
@@ -488,3 +639,44 @@ func main() {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
+
+// newOIDCProviders creates the OpenID Connect providers from the configuration.
+// A misconfigured provider stops the server, since users of its company could not sign in.
+func newOIDCProviders(db *sql.DB, cfg *config.Config) []*auth.OIDCProvider {
+	var providers []*auth.OIDCProvider
+	for _, p := range cfg.OIDC {
+		if p.IssuerURL == "" || p.ClientID == "" {
+			log.Fatalf("OIDC provider %s needs an issuer and a client ID", p.ID)
+		}
+		role := models.UserRole(p.DefaultRole)
+		if !models.IsValidRole(role) {
+			log.Fatalf("OIDC provider %s has invalid default role %q", p.ID, p.DefaultRole)
+		}
+
+		var companyID *int64
+		if p.ClientCompanyID != 0 {
+			if _, err := models.GetClientCompanyByID(db, p.ClientCompanyID); err != nil {
+				log.Fatalf("OIDC provider %s has invalid client company %d: %v", p.ID, p.ClientCompanyID, err)
+			}
+			id := p.ClientCompanyID
+			companyID = &id
+		} else if role == models.RoleClient {
+			log.Fatalf("OIDC provider %s provisions client users and needs a client company", p.ID)
+		}
+
+		providers = append(providers, auth.NewOIDCProvider(auth.OIDCProviderSettings{
+			ID:                p.ID,
+			Name:              p.Name,
+			IssuerURL:         p.IssuerURL,
+			ClientID:          p.ClientID,
+			ClientSecret:      p.ClientSecret,
+			RedirectURL:       strings.TrimSuffix(cfg.App.BaseURL, "/") + "/auth/oidc/" + p.ID + "/callback",
+			ClientCompanyID:   companyID,
+			DefaultRole:       role,
+			AllowedDomains:    p.AllowedDomains,
+			AllowEmailLinking: p.AllowEmailLinking,
+		}))
+		log.Printf("OIDC login enabled for %s (%s)", p.Name, p.IssuerURL)
+	}
+	return providers
+}
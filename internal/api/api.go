@@ -0,0 +1,293 @@
+// Package api implements the versioned JSON API served under /api/v1.
+//
+// The API exposes the same data as the HTML pages and enforces the same role rules.
+// Requests are authenticated with the session cookie or with an API token sent as
+// "Authorization: Bearer <token>". Tokens can always read; writes need the route's scope.
+// Every error is returned as {"error": {"code": "...", "message": "..."}} and every
+// list as {"data": [...], "pagination": {...}}. The OpenAPI document describing the
+// endpoints is served at /api/v1/openapi.json.
+package api
+
+import (
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// Prefix is the path all API routes are served under
+const Prefix = "/api/v1"
+
+// maxBodySize is the largest request body the API accepts (1MB)
+const maxBodySize = 1 << 20
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// Error codes returned in the "code" field of error bodies
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeInternalError    = "internal_error"
+)
+
+// Handler serves the JSON API
+type Handler struct {
+	DB            *sql.DB
+	EmailNotifier *email.Notifier
+	JiraValidator models.JiraTicketValidator // Optional, checks that JIRA tickets exist
+}
+
+// NewHandler creates a new API Handler
+func NewHandler(db *sql.DB, emailNotifier *email.Notifier) *Handler {
+	return &Handler{
+		DB:            db,
+		EmailNotifier: emailNotifier,
+	}
+}
+
+// ErrorBody is the JSON body of every error response
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes what went wrong
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// methodHandlers dispatches a request to the handler of its method, answering 405 for the others
+type methodHandlers map[string]http.HandlerFunc
+
+// ServeHTTP implements http.Handler
+func (m methodHandlers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if handler, ok := m[r.Method]; ok {
+		handler(w, r)
+		return
+	}
+	methods := make([]string, 0, len(m))
+	for method := range m {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+// route is an API path and the handlers of its methods
+type route struct {
+	path     string
+	handlers methodHandlers
+	public   bool                 // Served without a logged-in user
+	scope    models.APITokenScope // Scope an API token needs to write, tokens cannot write if empty
+}
+
+// routes returns every API route, relative to Prefix
+func (h *Handler) routes() []route {
+	return []route{
+		// The OpenAPI document is public so clients can be generated without an account
+		{"/openapi.json", methodHandlers{"GET": OpenAPI}, true, ""},
+
+		{"/shipments", methodHandlers{"GET": h.ListShipments, "POST": h.CreateShipment}, false, models.ScopeShipmentsWrite},
+		{"/shipments/{id:[0-9]+}", methodHandlers{"GET": h.GetShipment}, false, models.ScopeShipmentsWrite},
+		{"/shipments/{id:[0-9]+}/status", methodHandlers{"POST": h.UpdateShipmentStatus}, false, models.ScopeShipmentsWrite},
+
+		{"/laptops", methodHandlers{"GET": h.ListLaptops, "POST": h.CreateLaptop}, false, models.ScopeInventoryWrite},
+		{"/laptops/{id:[0-9]+}", methodHandlers{"GET": h.GetLaptop, "PATCH": h.UpdateLaptop, "DELETE": h.DeleteLaptop}, false, models.ScopeInventoryWrite},
+
+		// Engineers and companies are managed from the web pages, tokens can only read them
+		{"/software-engineers", methodHandlers{"GET": h.ListSoftwareEngineers, "POST": h.CreateSoftwareEngineer}, false, ""},
+		{"/software-engineers/{id:[0-9]+}", methodHandlers{"GET": h.GetSoftwareEngineer, "PATCH": h.UpdateSoftwareEngineer, "DELETE": h.DeleteSoftwareEngineer}, false, ""},
+
+		{"/client-companies", methodHandlers{"GET": h.ListClientCompanies, "POST": h.CreateClientCompany}, false, ""},
+		{"/client-companies/{id:[0-9]+}", methodHandlers{"GET": h.GetClientCompany, "PATCH": h.UpdateClientCompany, "DELETE": h.DeleteClientCompany}, false, ""},
+
+		{"/reception-reports", methodHandlers{"GET": h.ListReceptionReports}, false, models.ScopeInventoryWrite},
+		{"/reception-reports/{id:[0-9]+}", methodHandlers{"GET": h.GetReceptionReport}, false, models.ScopeInventoryWrite},
+		{"/reception-reports/{id:[0-9]+}/approve", methodHandlers{"POST": h.ApproveReceptionReport}, false, models.ScopeInventoryWrite},
+	}
+}
+
+// RegisterRoutes adds the API routes to the router.
+// The API must be registered before catch-all HTML routes so its 401 and 404 responses stay JSON.
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	api := router.PathPrefix(Prefix).Subrouter()
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, CodeNotFound, "Endpoint not found")
+	})
+
+	for _, rt := range h.routes() {
+		var handler http.Handler = rt.handlers
+		if !rt.public {
+			handler = RequireUser(RequireScope(rt.scope, handler))
+		}
+		api.Handle(rt.path, handler)
+	}
+}
+
+// OpenAPI serves the OpenAPI document of the API
+func OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
+
+// RequireUser rejects requests without a logged-in user with a JSON 401,
+// where the HTML pages would redirect to the login page
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if middleware.GetUserFromContext(r.Context()) == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope rejects writes made with an API token that was not granted scope.
+// Reads, and requests authenticated with a session, are not limited.
+func RequireScope(scope models.APITokenScope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := middleware.GetAPITokenFromContext(r.Context())
+		if token != nil && r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if scope == "" {
+				writeError(w, http.StatusForbidden, CodeForbidden, "API tokens cannot make changes to this resource")
+				return
+			}
+			if !token.HasScope(scope) {
+				writeError(w, http.StatusForbidden, CodeForbidden, "API token is missing the "+string(scope)+" scope")
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requirePermission returns the user if they are granted the permission, otherwise it writes a 403
+func requirePermission(w http.ResponseWriter, r *http.Request, perm permissions.Permission) (*models.User, bool) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+		return nil, false
+	}
+	if !permissions.Can(user, perm) {
+		permissions.LogDenied(r, user, perm)
+		writeError(w, http.StatusForbidden, CodeForbidden, "You do not have permission to perform this action")
+		return nil, false
+	}
+	return user, true
+}
+
+// actorUserID returns the user ID to record as the actor of a change, nil for service accounts
+func actorUserID(user *models.User) *int64 {
+	if user.IsServiceAccount() {
+		return nil
+	}
+	return &user.ID
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		fmt.Printf("Warning: failed to encode API response: %v\n", err)
+	}
+}
+
+// writeError writes a JSON error body
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorBody{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// writeInternalError logs err and writes a generic 500, so database details never reach clients
+func writeInternalError(w http.ResponseWriter, action string, err error) {
+	fmt.Printf("Error %s: %v\n", action, err)
+	writeError(w, http.StatusInternalServerError, CodeInternalError, "Failed "+action)
+}
+
+// writeNotFound writes a 404 for the named entity
+func writeNotFound(w http.ResponseWriter, entity string) {
+	writeError(w, http.StatusNotFound, CodeNotFound, entity+" not found")
+}
+
+// isNotFound reports whether err means the requested record does not exist
+func isNotFound(err error) bool {
+	return errors.Is(err, models.ErrNotFound) || errors.Is(err, sql.ErrNoRows)
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	// Some model functions wrap the driver error into a message
+	return strings.Contains(err.Error(), "already exists")
+}
+
+// isForeignKeyViolation reports whether err is a foreign key violation, e.g. deleting a record still in use
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// decodeJSON decodes the request body into v, rejecting unknown fields so typos are not silently ignored.
+// It writes a 400 and returns false if the body is not valid JSON.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		message := "Invalid JSON body"
+		if errors.Is(err, io.EOF) {
+			message = "Request body is required"
+		} else {
+			message += ": " + err.Error()
+		}
+		writeError(w, http.StatusBadRequest, CodeBadRequest, message)
+		return false
+	}
+	if decoder.More() {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "Request body must contain a single JSON object")
+		return false
+	}
+	return true
+}
+
+// pathID parses the {id} path variable, writing a 400 if it is not a number
+func pathID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid ID")
+		return 0, false
+	}
+	return id, true
+}
+
+// recordAudit writes an audit log entry for a change made through the API
+func (h *Handler) recordAudit(r *http.Request, user *models.User, entry audit.Entry) {
+	actor := audit.UserActor(user)
+	actor.Source = "api"
+	audit.Log(r.Context(), h.DB, actor, entry)
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// serve runs a request through the API router as the given user (nil for anonymous).
+// The handler has no database, so only requests rejected before any query can be tested here.
+func serve(t *testing.T, user *models.User, method, path, body string) (*httptest.ResponseRecorder, ErrorBody) {
+	t.Helper()
+	return serveWithToken(t, user, nil, method, path, body)
+}
+
+// serveWithToken runs a request as if the user had authenticated with the API token
+func serveWithToken(t *testing.T, user *models.User, token *models.APIToken, method, path, body string) (*httptest.ResponseRecorder, ErrorBody) {
+	t.Helper()
+	router := mux.NewRouter()
+	NewHandler(nil, nil).RegisterRoutes(router)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if user != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, user))
+	}
+	if token != nil {
+		req = req.WithContext(context.WithValue(req.Context(), middleware.APITokenContextKey, token))
+	}
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	var errBody ErrorBody
+	if rr.Code >= 400 {
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("Expected a JSON error, got Content-Type %q", ct)
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &errBody); err != nil {
+			t.Fatalf("Expected an error body, got %q: %v", rr.Body.String(), err)
+		}
+	}
+	return rr, errBody
+}
+
+func TestAPI_ErrorResponses(t *testing.T) {
+	companyID := int64(7)
+	logistics := &models.User{ID: 1, Role: models.RoleLogistics}
+	warehouse := &models.User{ID: 2, Role: models.RoleWarehouse}
+	client := &models.User{ID: 3, Role: models.RoleClient, ClientCompanyID: &companyID}
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"anonymous", nil, "GET", "/api/v1/shipments", "", http.StatusUnauthorized, CodeUnauthorized},
+		{"unknown endpoint", logistics, "GET", "/api/v1/unknown", "", http.StatusNotFound, CodeNotFound},
+		{"wrong method", logistics, "PUT", "/api/v1/shipments", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{"client creates shipment", client, "POST", "/api/v1/shipments", `{}`, http.StatusForbidden, CodeForbidden},
+		{"warehouse changes status", warehouse, "POST", "/api/v1/shipments/1/status", `{}`, http.StatusForbidden, CodeForbidden},
+		{"client creates laptop", client, "POST", "/api/v1/laptops", `{}`, http.StatusForbidden, CodeForbidden},
+		{"warehouse deletes laptop", warehouse, "DELETE", "/api/v1/laptops/1", "", http.StatusForbidden, CodeForbidden},
+		{"warehouse lists engineers", warehouse, "GET", "/api/v1/software-engineers", "", http.StatusForbidden, CodeForbidden},
+		{"client lists companies", client, "GET", "/api/v1/client-companies", "", http.StatusForbidden, CodeForbidden},
+		{"client lists reception reports", client, "GET", "/api/v1/reception-reports", "", http.StatusForbidden, CodeForbidden},
+		{"warehouse approves reception report", warehouse, "POST", "/api/v1/reception-reports/1/approve", "", http.StatusForbidden, CodeForbidden},
+		{"bad page", logistics, "GET", "/api/v1/client-companies?page=0", "", http.StatusBadRequest, CodeBadRequest},
+		{"bad per_page", logistics, "GET", "/api/v1/software-engineers?per_page=1000", "", http.StatusBadRequest, CodeBadRequest},
+		{"empty body", logistics, "POST", "/api/v1/client-companies", "", http.StatusBadRequest, CodeBadRequest},
+		{"malformed body", logistics, "POST", "/api/v1/client-companies", `{"name":`, http.StatusBadRequest, CodeBadRequest},
+		{"unknown field", logistics, "POST", "/api/v1/client-companies", `{"nmae":"Acme"}`, http.StatusBadRequest, CodeBadRequest},
+		{"two objects", logistics, "POST", "/api/v1/client-companies", `{"name":"Acme"} {}`, http.StatusBadRequest, CodeBadRequest},
+		{"invalid company", logistics, "POST", "/api/v1/client-companies", `{"name":"Ac"}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"invalid engineer", logistics, "POST", "/api/v1/software-engineers", `{"name":"Ada","email":"not-an-email"}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"invalid laptop", warehouse, "POST", "/api/v1/laptops", `{"serial_number":"SN1"}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"invalid shipment", logistics, "POST", "/api/v1/shipments", `{"client_company_id":1,"jira_ticket_number":"nope"}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+		{"invalid status", logistics, "POST", "/api/v1/shipments/1/status", `{"status":"teleported"}`, http.StatusUnprocessableEntity, CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, body := serve(t, tt.user, tt.method, tt.path, tt.body)
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("Expected code %q, got %q", tt.wantCode, body.Error.Code)
+			}
+			if body.Error.Message == "" {
+				t.Error("Expected an error message")
+			}
+		})
+	}
+}
+
+func TestAPI_TokenScopes(t *testing.T) {
+	logistics := &models.User{ID: 1, Role: models.RoleLogistics}
+	serviceAccount := (&models.ServiceAccount{ID: 5, Name: "erp-sync", Role: models.RoleLogistics}).AsUser()
+	readOnly := &models.APIToken{Scopes: []models.APITokenScope{models.ScopeRead}}
+	shipmentsWrite := &models.APIToken{Scopes: []models.APITokenScope{models.ScopeShipmentsWrite}}
+	allScopes := &models.APIToken{Scopes: models.AllAPITokenScopes}
+
+	invalidShipment := `{"client_company_id":1,"jira_ticket_number":"nope"}`
+
+	tests := []struct {
+		name       string
+		user       *models.User
+		token      *models.APIToken
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		// Requests that get past the scope check fail validation or paging instead, since there is no database
+		{"read-only token reads", logistics, readOnly, "GET", "/api/v1/client-companies?page=0", "", http.StatusBadRequest},
+		{"read-only token writes", logistics, readOnly, "POST", "/api/v1/shipments", invalidShipment, http.StatusForbidden},
+		{"token with the scope writes", logistics, shipmentsWrite, "POST", "/api/v1/shipments", invalidShipment, http.StatusUnprocessableEntity},
+		{"token with another scope writes", logistics, shipmentsWrite, "DELETE", "/api/v1/laptops/1", "", http.StatusForbidden},
+		{"token writes a read-only resource", logistics, allScopes, "POST", "/api/v1/client-companies", `{"name":"Ac"}`, http.StatusForbidden},
+		{"session writes a read-only resource", logistics, nil, "POST", "/api/v1/client-companies", `{"name":"Ac"}`, http.StatusUnprocessableEntity},
+		{"service account writes", serviceAccount, shipmentsWrite, "POST", "/api/v1/shipments", invalidShipment, http.StatusUnprocessableEntity},
+		{"service account approves reception report", serviceAccount, allScopes, "POST", "/api/v1/reception-reports/1/approve", "", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := serveWithToken(t, tt.user, tt.token, tt.method, tt.path, tt.body)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	rr, _ := serve(t, nil, "GET", "/api/v1/laptops", "")
+	if !strings.HasPrefix(rr.Header().Get("WWW-Authenticate"), "Bearer") {
+		t.Errorf("Expected a Bearer challenge on 401, got %q", rr.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestAPI_OpenAPIDocument(t *testing.T) {
+	rr, _ := serve(t, nil, "GET", "/api/v1/openapi.json", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected the OpenAPI document to be public, got %d", rr.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("OpenAPI document is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("Expected an OpenAPI 3 document, got %q", doc.OpenAPI)
+	}
+
+	// Every route must be documented, with the same methods
+	idPattern := regexp.MustCompile(`\{id:[^}]+\}`)
+	for _, rt := range NewHandler(nil, nil).routes() {
+		path := idPattern.ReplaceAllString(rt.path, "{id}")
+		for method := range rt.handlers {
+			if _, ok := doc.Paths[path][strings.ToLower(method)]; !ok {
+				t.Errorf("%s %s is not in the OpenAPI document", method, path)
+			}
+		}
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    Page
+		wantErr bool
+	}{
+		{"", Page{Number: 1, PerPage: DefaultPerPage}, false},
+		{"page=3&per_page=20", Page{Number: 3, PerPage: 20}, false},
+		{"per_page=200", Page{Number: 1, PerPage: 200}, false},
+		{"page=0", Page{}, true},
+		{"page=abc", Page{}, true},
+		{"per_page=0", Page{}, true},
+		{"per_page=201", Page{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, err := parsePage(httptest.NewRequest("GET", "/api/v1/laptops?"+tt.query, nil))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parsePage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	page := Page{Number: 3, PerPage: 20}
+	if page.Offset() != 40 || page.Limit() != 21 {
+		t.Errorf("Expected offset 40 and limit 21, got %d and %d", page.Offset(), page.Limit())
+	}
+}
+
+func TestSlicePage(t *testing.T) {
+	records := []int{1, 2, 3, 4, 5}
+
+	tests := []struct {
+		page        Page
+		wantData    []int
+		wantHasMore bool
+	}{
+		{Page{Number: 1, PerPage: 2}, []int{1, 2}, true},
+		{Page{Number: 3, PerPage: 2}, []int{5}, false},
+		{Page{Number: 1, PerPage: 5}, []int{1, 2, 3, 4, 5}, false},
+		{Page{Number: 4, PerPage: 2}, []int{}, false},
+	}
+	for _, tt := range tests {
+		got := slicePage(records, tt.page)
+		data := got.Data.([]int)
+		if len(data) != len(tt.wantData) || got.Pagination.HasMore != tt.wantHasMore {
+			t.Errorf("slicePage(%+v) = %v has_more=%t, want %v has_more=%t", tt.page, data, got.Pagination.HasMore, tt.wantData, tt.wantHasMore)
+		}
+	}
+
+	// Empty pages encode as [] rather than null
+	out, _ := json.Marshal(pageOf([]int(nil), Page{Number: 1, PerPage: 10}))
+	if !strings.Contains(string(out), `"data":[]`) {
+		t.Errorf("Expected an empty data array, got %s", out)
+	}
+}
+
+func TestCanViewLaptop(t *testing.T) {
+	companyA, companyB := int64(1), int64(2)
+	laptop := &models.Laptop{ClientCompanyID: &companyA, Status: models.LaptopStatusDelivered}
+
+	tests := []struct {
+		name string
+		user *models.User
+		want bool
+	}{
+		{"logistics", &models.User{Role: models.RoleLogistics}, true},
+		{"project manager of the company", &models.User{Role: models.RoleProjectManager, AssignedCompanyIDs: []int64{companyB, companyA}}, true},
+		{"project manager of other companies", &models.User{Role: models.RoleProjectManager, AssignedCompanyIDs: []int64{companyB}}, false},
+		{"project manager without companies", &models.User{Role: models.RoleProjectManager}, false},
+		{"client of the company", &models.User{Role: models.RoleClient, ClientCompanyID: &companyA}, true},
+		{"client of another company", &models.User{Role: models.RoleClient, ClientCompanyID: &companyB}, false},
+		{"client without company", &models.User{Role: models.RoleClient}, false},
+		{"warehouse, delivered laptop", &models.User{Role: models.RoleWarehouse}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canViewLaptop(tt.user, laptop); got != tt.want {
+				t.Errorf("canViewLaptop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	atWarehouse := &models.Laptop{Status: models.LaptopStatusAtWarehouse}
+	if !canViewLaptop(&models.User{Role: models.RoleWarehouse}, atWarehouse) {
+		t.Error("Expected warehouse users to see laptops at the warehouse")
+	}
+}
@@ -0,0 +1,395 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// softwareEngineerInput holds the fields of a software engineer that can be written through the API
+type softwareEngineerInput struct {
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	AddressStreet     string `json:"address_street"`
+	AddressCity       string `json:"address_city"`
+	AddressState      string `json:"address_state"`
+	AddressPostalCode string `json:"address_postal_code"`
+	AddressCountry    string `json:"address_country"`
+	Phone             string `json:"phone"`
+	EmployeeNumber    string `json:"employee_number"`
+	AddressConfirmed  bool   `json:"address_confirmed"`
+}
+
+// newSoftwareEngineerInput returns the writable fields of a stored engineer
+func newSoftwareEngineerInput(e *models.SoftwareEngineer) softwareEngineerInput {
+	return softwareEngineerInput{
+		Name:              e.Name,
+		Email:             e.Email,
+		AddressStreet:     e.AddressStreet,
+		AddressCity:       e.AddressCity,
+		AddressState:      e.AddressState,
+		AddressPostalCode: e.AddressPostalCode,
+		AddressCountry:    e.AddressCountry,
+		Phone:             e.Phone,
+		EmployeeNumber:    e.EmployeeNumber,
+		AddressConfirmed:  e.AddressConfirmed,
+	}
+}
+
+// apply copies the input onto the engineer, stamping the address confirmation when it is first confirmed
+func (in softwareEngineerInput) apply(e *models.SoftwareEngineer) {
+	e.Name = strings.TrimSpace(in.Name)
+	e.Email = strings.TrimSpace(in.Email)
+	e.AddressStreet = in.AddressStreet
+	e.AddressCity = in.AddressCity
+	e.AddressState = in.AddressState
+	e.AddressPostalCode = in.AddressPostalCode
+	e.AddressCountry = in.AddressCountry
+	e.Phone = in.Phone
+	e.EmployeeNumber = in.EmployeeNumber
+
+	switch {
+	case in.AddressConfirmed && !e.AddressConfirmed:
+		e.ConfirmAddress()
+	case !in.AddressConfirmed:
+		e.AddressConfirmed = false
+		e.AddressConfirmationAt = nil
+	}
+}
+
+// ListSoftwareEngineers returns software engineers (logistics only).
+// Filters: search (name, email or employee number).
+func (h *Handler) ListSoftwareEngineers(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, permissions.SoftwareEngineerManage); !ok {
+		return
+	}
+	page, ok := requirePage(w, r)
+	if !ok {
+		return
+	}
+
+	engineers, err := models.GetAllSoftwareEngineers(h.DB, &models.SoftwareEngineerFilter{
+		Search:    strings.TrimSpace(r.URL.Query().Get("search")),
+		SortBy:    r.URL.Query().Get("sort"),
+		SortOrder: r.URL.Query().Get("order"),
+	})
+	if err != nil {
+		writeInternalError(w, "loading software engineers", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, slicePage(engineers, page))
+}
+
+// GetSoftwareEngineer returns a software engineer (logistics only)
+func (h *Handler) GetSoftwareEngineer(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, permissions.SoftwareEngineerManage); !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	engineer, err := models.GetSoftwareEngineerByID(h.DB, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Software engineer")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading software engineer", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, engineer)
+}
+
+// CreateSoftwareEngineer adds a software engineer (logistics only)
+func (h *Handler) CreateSoftwareEngineer(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.SoftwareEngineerManage)
+	if !ok {
+		return
+	}
+
+	var input softwareEngineerInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	engineer := &models.SoftwareEngineer{}
+	input.apply(engineer)
+	if err := engineer.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	if err := models.CreateSoftwareEngineer(h.DB, engineer); err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, "a software engineer with this email already exists")
+			return
+		}
+		writeInternalError(w, "creating software engineer", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "software_engineer_created",
+		EntityType: audit.EntitySoftwareEngineer,
+		EntityID:   engineer.ID,
+		Changes:    audit.Diff(nil, engineer),
+	})
+	writeJSON(w, http.StatusCreated, engineer)
+}
+
+// UpdateSoftwareEngineer changes the fields given in the body (logistics only)
+func (h *Handler) UpdateSoftwareEngineer(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.SoftwareEngineerManage)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	engineer, err := models.GetSoftwareEngineerByID(h.DB, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Software engineer")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading software engineer", err)
+		return
+	}
+
+	before := audit.Snapshot(engineer)
+	input := newSoftwareEngineerInput(engineer)
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	input.apply(engineer)
+	if err := engineer.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	if err := models.UpdateSoftwareEngineer(h.DB, engineer); err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, "a software engineer with this email already exists")
+			return
+		}
+		writeInternalError(w, "updating software engineer", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "software_engineer_updated",
+		EntityType: audit.EntitySoftwareEngineer,
+		EntityID:   engineer.ID,
+		Changes:    audit.Diff(before, engineer),
+	})
+	writeJSON(w, http.StatusOK, engineer)
+}
+
+// DeleteSoftwareEngineer removes a software engineer (logistics only)
+func (h *Handler) DeleteSoftwareEngineer(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.SoftwareEngineerManage)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteSoftwareEngineer(h.DB, id); err != nil {
+		switch {
+		case isNotFound(err):
+			writeNotFound(w, "Software engineer")
+		case isForeignKeyViolation(err):
+			writeError(w, http.StatusConflict, CodeConflict, "Software engineer is still assigned to shipments or laptops")
+		default:
+			writeInternalError(w, "deleting software engineer", err)
+		}
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{Action: "software_engineer_deleted", EntityType: audit.EntitySoftwareEngineer, EntityID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clientCompanyInput holds the fields of a client company that can be written through the API
+type clientCompanyInput struct {
+	Name        string `json:"name"`
+	ContactInfo string `json:"contact_info"`
+}
+
+// ListClientCompanies returns client companies (logistics only)
+func (h *Handler) ListClientCompanies(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, permissions.ClientCompanyManage); !ok {
+		return
+	}
+	page, ok := requirePage(w, r)
+	if !ok {
+		return
+	}
+
+	companies, err := models.GetAllClientCompanies(h.DB)
+	if err != nil {
+		writeInternalError(w, "loading client companies", err)
+		return
+	}
+
+	// Filter by name, the list is small enough to filter in memory
+	if search := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("search"))); search != "" {
+		matching := []models.ClientCompany{}
+		for _, c := range companies {
+			if strings.Contains(strings.ToLower(c.Name), search) {
+				matching = append(matching, c)
+			}
+		}
+		companies = matching
+	}
+
+	writeJSON(w, http.StatusOK, slicePage(companies, page))
+}
+
+// GetClientCompany returns a client company (logistics only)
+func (h *Handler) GetClientCompany(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, permissions.ClientCompanyManage); !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	company, err := models.GetClientCompanyByID(h.DB, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Client company")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading client company", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, company)
+}
+
+// CreateClientCompany adds a client company (logistics only)
+func (h *Handler) CreateClientCompany(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ClientCompanyManage)
+	if !ok {
+		return
+	}
+
+	var input clientCompanyInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	company := &models.ClientCompany{Name: strings.TrimSpace(input.Name), ContactInfo: input.ContactInfo}
+	if err := company.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	if err := models.CreateClientCompany(h.DB, company); err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, "a client company with this name already exists")
+			return
+		}
+		writeInternalError(w, "creating client company", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "client_company_created",
+		EntityType: audit.EntityClientCompany,
+		EntityID:   company.ID,
+		Changes:    audit.Diff(nil, company),
+	})
+	writeJSON(w, http.StatusCreated, company)
+}
+
+// UpdateClientCompany changes the fields given in the body (logistics only)
+func (h *Handler) UpdateClientCompany(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ClientCompanyManage)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	company, err := models.GetClientCompanyByID(h.DB, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Client company")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading client company", err)
+		return
+	}
+
+	before := audit.Snapshot(company)
+	input := clientCompanyInput{Name: company.Name, ContactInfo: company.ContactInfo}
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	company.Name = strings.TrimSpace(input.Name)
+	company.ContactInfo = input.ContactInfo
+	if err := company.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	if err := models.UpdateClientCompany(h.DB, company); err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, "a client company with this name already exists")
+			return
+		}
+		writeInternalError(w, "updating client company", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "client_company_updated",
+		EntityType: audit.EntityClientCompany,
+		EntityID:   company.ID,
+		Changes:    audit.Diff(before, company),
+	})
+	writeJSON(w, http.StatusOK, company)
+}
+
+// DeleteClientCompany removes a client company (logistics only)
+func (h *Handler) DeleteClientCompany(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ClientCompanyManage)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteClientCompany(h.DB, id); err != nil {
+		switch {
+		case isNotFound(err):
+			writeNotFound(w, "Client company")
+		case isForeignKeyViolation(err):
+			writeError(w, http.StatusConflict, CodeConflict, "Client company still has shipments, laptops or users")
+		default:
+			writeInternalError(w, "deleting client company", err)
+		}
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{Action: "client_company_deleted", EntityType: audit.EntityClientCompany, EntityID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,293 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// laptopInput holds the fields of a laptop that can be written through the API.
+// Updates start from the stored laptop, so fields left out of a PATCH body keep their value.
+type laptopInput struct {
+	SerialNumber       string                `json:"serial_number"`
+	SKU                string                `json:"sku"`
+	Brand              string                `json:"brand"`
+	Model              string                `json:"model"`
+	CPU                string                `json:"cpu"`
+	RAMGB              string                `json:"ram_gb"`
+	SSDGB              string                `json:"ssd_gb"`
+	Status             models.LaptopStatus   `json:"status"`
+	ClientCompanyID    *int64                `json:"client_company_id"`
+	SoftwareEngineerID *int64                `json:"software_engineer_id"`
+	DeviceCategory     models.DeviceCategory `json:"device_category"`
+	DeclaredValue      *float64              `json:"declared_value"`
+	CountryOfOrigin    string                `json:"country_of_origin"`
+}
+
+// newLaptopInput returns the writable fields of a stored laptop
+func newLaptopInput(l *models.Laptop) laptopInput {
+	return laptopInput{
+		SerialNumber:       l.SerialNumber,
+		SKU:                l.SKU,
+		Brand:              l.Brand,
+		Model:              l.Model,
+		CPU:                l.CPU,
+		RAMGB:              l.RAMGB,
+		SSDGB:              l.SSDGB,
+		Status:             l.Status,
+		ClientCompanyID:    l.ClientCompanyID,
+		SoftwareEngineerID: l.SoftwareEngineerID,
+		DeviceCategory:     l.DeviceCategory,
+		DeclaredValue:      l.DeclaredValue,
+		CountryOfOrigin:    l.CountryOfOrigin,
+	}
+}
+
+// apply copies the input onto the laptop
+func (in laptopInput) apply(l *models.Laptop) {
+	l.SerialNumber = strings.TrimSpace(in.SerialNumber)
+	l.SKU = strings.TrimSpace(in.SKU)
+	l.Brand = in.Brand
+	l.Model = in.Model
+	l.CPU = in.CPU
+	l.RAMGB = in.RAMGB
+	l.SSDGB = in.SSDGB
+	l.Status = in.Status
+	l.ClientCompanyID = in.ClientCompanyID
+	l.SoftwareEngineerID = in.SoftwareEngineerID
+	l.DeviceCategory = in.DeviceCategory
+	l.DeclaredValue = in.DeclaredValue
+	l.CountryOfOrigin = strings.TrimSpace(in.CountryOfOrigin)
+}
+
+// canViewLaptop applies the inventory page's role rules to a single laptop
+func canViewLaptop(user *models.User, l *models.Laptop) bool {
+	switch user.Role {
+	case models.RoleClient, models.RoleProjectManager:
+		return user.CompanyScope().Allows(l.ClientCompanyID)
+	case models.RoleWarehouse:
+		for _, status := range models.GetAllowedStatusesForRole(user.Role) {
+			if l.Status == status {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ListLaptops returns the laptops visible to the user.
+// Filters: status, brand, search (serial number, brand, model or SKU).
+func (h *Handler) ListLaptops(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.InventoryView)
+	if !ok {
+		return
+	}
+	page, ok := requirePage(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	status := models.LaptopStatus(query.Get("status"))
+	if status != "" && !models.IsValidLaptopStatus(status) {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid status filter")
+		return
+	}
+
+	filter := &models.LaptopFilter{
+		Status:           status,
+		Brand:            query.Get("brand"),
+		Search:           strings.TrimSpace(query.Get("search")),
+		Limit:            page.Limit(),
+		Offset:           page.Offset(),
+		UserRole:         user.Role,
+		ClientCompanyID:  user.ClientCompanyID,
+		ClientCompanyIDs: user.AssignedCompanyIDs,
+		SortBy:           query.Get("sort"),
+		SortOrder:        query.Get("order"),
+	}
+	if user.Role == models.RoleClient && user.ClientCompanyID == nil {
+		// Client users without a company have no laptops
+		writeJSON(w, http.StatusOK, pageOf([]models.Laptop{}, page))
+		return
+	}
+
+	laptops, err := models.GetAllLaptops(h.DB, filter)
+	if err != nil {
+		writeInternalError(w, "loading laptops", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pageOf(laptops, page))
+}
+
+// GetLaptop returns a laptop
+func (h *Handler) GetLaptop(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.InventoryView)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	laptop, err := models.GetLaptopByID(h.DB, id)
+	if isNotFound(err) || (err == nil && !canViewLaptop(user, laptop)) {
+		writeNotFound(w, "Laptop")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading laptop", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, laptop)
+}
+
+// CreateLaptop adds a laptop to the inventory (logistics and warehouse only)
+func (h *Handler) CreateLaptop(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.InventoryEdit)
+	if !ok {
+		return
+	}
+
+	var input laptopInput
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+
+	laptop := &models.Laptop{}
+	input.apply(laptop)
+	laptop.GenerateAndSetSKU()
+	if err := laptop.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	if err := models.CreateLaptop(h.DB, laptop); err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, err.Error())
+			return
+		}
+		writeInternalError(w, "creating laptop", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "laptop_created",
+		EntityType: audit.EntityLaptop,
+		EntityID:   laptop.ID,
+		Changes:    audit.Diff(nil, laptop),
+	})
+	h.writeLaptop(w, r, http.StatusCreated, laptop.ID)
+}
+
+// UpdateLaptop changes the fields given in the body (logistics and warehouse only).
+// Setting the status to available requires an approved reception report, as in the edit page.
+func (h *Handler) UpdateLaptop(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.InventoryEdit)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	laptop, err := models.GetLaptopByID(h.DB, id)
+	if isNotFound(err) || (err == nil && !canViewLaptop(user, laptop)) {
+		writeNotFound(w, "Laptop")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading laptop", err)
+		return
+	}
+
+	before := audit.Snapshot(laptop)
+	input := newLaptopInput(laptop)
+	if !decodeJSON(w, r, &input) {
+		return
+	}
+	input.apply(laptop)
+	if laptop.SKU == "" {
+		laptop.GenerateAndSetSKU()
+	}
+	if err := laptop.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	var receptionReport *models.ReceptionReport
+	if laptop.Status == models.LaptopStatusAvailable {
+		receptionReport, err = models.GetLaptopReceptionReport(r.Context(), h.DB, laptop.ID)
+		if err != nil {
+			// Treat a report that cannot be loaded as missing
+			receptionReport = nil
+		}
+	}
+	if err := laptop.ValidateStatusChange(receptionReport); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+
+	if err := models.UpdateLaptop(h.DB, laptop); err != nil {
+		if isUniqueViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, "a laptop with this serial number already exists")
+			return
+		}
+		writeInternalError(w, "updating laptop", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "laptop_updated",
+		EntityType: audit.EntityLaptop,
+		EntityID:   laptop.ID,
+		Changes:    audit.Diff(before, laptop),
+	})
+	h.writeLaptop(w, r, http.StatusOK, laptop.ID)
+}
+
+// DeleteLaptop removes a laptop from the inventory (logistics only)
+func (h *Handler) DeleteLaptop(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.InventoryDelete)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteLaptop(h.DB, id); err != nil {
+		if isNotFound(err) {
+			writeNotFound(w, "Laptop")
+			return
+		}
+		if isForeignKeyViolation(err) {
+			writeError(w, http.StatusConflict, CodeConflict, "Laptop is still referenced by shipments or reception reports")
+			return
+		}
+		writeInternalError(w, "deleting laptop", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{Action: "laptop_deleted", EntityType: audit.EntityLaptop, EntityID: id})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeLaptop reloads a laptop so the response includes the joined names
+func (h *Handler) writeLaptop(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	laptop, err := models.GetLaptopByID(h.DB, id)
+	if err != nil {
+		writeInternalError(w, "loading laptop", err)
+		return
+	}
+	writeJSON(w, status, laptop)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Pagination defaults and limits
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// Page is the page of a list requested with the page and per_page query parameters
+type Page struct {
+	Number  int
+	PerPage int
+}
+
+// Offset returns the number of records before the page
+func (p Page) Offset() int {
+	return (p.Number - 1) * p.PerPage
+}
+
+// Limit returns the number of records to fetch: one more than the page size,
+// so whether another page follows is known without counting every record
+func (p Page) Limit() int {
+	return p.PerPage + 1
+}
+
+// Pagination is returned next to every list
+type Pagination struct {
+	Page    int  `json:"page"`
+	PerPage int  `json:"per_page"`
+	HasMore bool `json:"has_more"`
+}
+
+// ListResponse is the JSON body of every list endpoint
+type ListResponse struct {
+	Data       interface{} `json:"data"`
+	Pagination Pagination  `json:"pagination"`
+}
+
+// parsePage reads the page and per_page query parameters
+func parsePage(r *http.Request) (Page, error) {
+	page := Page{Number: 1, PerPage: DefaultPerPage}
+
+	if raw := r.URL.Query().Get("page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return page, fmt.Errorf("page must be a positive integer")
+		}
+		page.Number = n
+	}
+
+	if raw := r.URL.Query().Get("per_page"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > MaxPerPage {
+			return page, fmt.Errorf("per_page must be between 1 and %d", MaxPerPage)
+		}
+		page.PerPage = n
+	}
+
+	return page, nil
+}
+
+// requirePage parses the page parameters, writing a 400 if they are invalid
+func requirePage(w http.ResponseWriter, r *http.Request) (Page, bool) {
+	page, err := parsePage(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return page, false
+	}
+	return page, true
+}
+
+// pageOf cuts a page out of records fetched with page.Limit() and page.Offset()
+func pageOf[T any](records []T, page Page) ListResponse {
+	hasMore := len(records) > page.PerPage
+	if hasMore {
+		records = records[:page.PerPage]
+	}
+	if records == nil {
+		records = []T{}
+	}
+	return ListResponse{
+		Data:       records,
+		Pagination: Pagination{Page: page.Number, PerPage: page.PerPage, HasMore: hasMore},
+	}
+}
+
+// slicePage cuts a page out of all records, for lists that are loaded whole
+func slicePage[T any](records []T, page Page) ListResponse {
+	start := min(page.Offset(), len(records))
+	end := min(start+page.Limit(), len(records))
+	return pageOf(records[start:end], page)
+}
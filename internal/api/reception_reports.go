@@ -0,0 +1,175 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// receptionReportColumns selects a reception report; tracking_number and notes are nullable
+const receptionReportColumns = `
+	id, laptop_id, shipment_id, client_company_id, COALESCE(tracking_number, ''),
+	warehouse_user_id, received_at, COALESCE(notes, ''),
+	photo_serial_number, photo_external_condition, photo_working_condition,
+	status, approved_by, approved_at, created_at, updated_at
+	FROM reception_reports`
+
+// scanReceptionReport scans a row selected with receptionReportColumns
+func scanReceptionReport(row interface{ Scan(...interface{}) error }) (models.ReceptionReport, error) {
+	var rr models.ReceptionReport
+	err := row.Scan(
+		&rr.ID, &rr.LaptopID, &rr.ShipmentID, &rr.ClientCompanyID, &rr.TrackingNumber,
+		&rr.WarehouseUserID, &rr.ReceivedAt, &rr.Notes,
+		&rr.PhotoSerialNumber, &rr.PhotoExternalCondition, &rr.PhotoWorkingCondition,
+		&rr.Status, &rr.ApprovedBy, &rr.ApprovedAt, &rr.CreatedAt, &rr.UpdatedAt,
+	)
+	return rr, err
+}
+
+// ListReceptionReports returns reception reports, newest first (warehouse and logistics only).
+// Filters: status, laptop_id, shipment_id.
+// Reports are created from the web page because they require photo uploads.
+func (h *Handler) ListReceptionReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, permissions.ReceptionReportView); !ok {
+		return
+	}
+	page, ok := requirePage(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	conditions := []string{"TRUE"}
+	var args []interface{}
+
+	if status := models.ReceptionReportStatus(query.Get("status")); status != "" {
+		if status != models.ReceptionReportStatusPendingApproval && status != models.ReceptionReportStatusApproved {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid status filter")
+			return
+		}
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	for _, column := range []string{"laptop_id", "shipment_id"} {
+		raw := query.Get(column)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid "+column+" filter")
+			return
+		}
+		args = append(args, id)
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	args = append(args, page.Limit(), page.Offset())
+	rows, err := h.DB.QueryContext(r.Context(),
+		fmt.Sprintf("SELECT %s WHERE %s ORDER BY received_at DESC, id DESC LIMIT $%d OFFSET $%d",
+			receptionReportColumns, strings.Join(conditions, " AND "), len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		writeInternalError(w, "loading reception reports", err)
+		return
+	}
+	defer rows.Close()
+
+	reports := []models.ReceptionReport{}
+	for rows.Next() {
+		rr, err := scanReceptionReport(rows)
+		if err != nil {
+			writeInternalError(w, "loading reception reports", err)
+			return
+		}
+		reports = append(reports, rr)
+	}
+	if err := rows.Err(); err != nil {
+		writeInternalError(w, "loading reception reports", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pageOf(reports, page))
+}
+
+// GetReceptionReport returns a reception report (warehouse and logistics only)
+func (h *Handler) GetReceptionReport(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, permissions.ReceptionReportView); !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeReceptionReport(w, r, http.StatusOK, id)
+}
+
+// ApproveReceptionReport approves a reception report, making its laptop available (logistics only).
+// Approvals record who approved, so service accounts cannot approve.
+func (h *Handler) ApproveReceptionReport(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ReceptionReportApprove)
+	if !ok {
+		return
+	}
+	if user.IsServiceAccount() {
+		writeError(w, http.StatusForbidden, CodeForbidden, "Reception reports must be approved by a person")
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.loadReceptionReport(r, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Reception report")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading reception report", err)
+		return
+	}
+	if report.IsApproved() {
+		writeError(w, http.StatusConflict, CodeConflict, "Reception report is already approved")
+		return
+	}
+
+	if err := models.ApproveReceptionReport(r.Context(), h.DB, id, user.ID); err != nil {
+		writeInternalError(w, "approving reception report", err)
+		return
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "reception_report_approved",
+		EntityType: audit.EntityReceptionReport,
+		EntityID:   id,
+		Details:    map[string]interface{}{"laptop_id": report.LaptopID},
+	})
+	h.writeReceptionReport(w, r, http.StatusOK, id)
+}
+
+// loadReceptionReport loads a reception report by ID
+func (h *Handler) loadReceptionReport(r *http.Request, id int64) (models.ReceptionReport, error) {
+	return scanReceptionReport(h.DB.QueryRowContext(r.Context(), "SELECT "+receptionReportColumns+" WHERE id = $1", id))
+}
+
+// writeReceptionReport loads a reception report and writes it
+func (h *Handler) writeReceptionReport(w http.ResponseWriter, r *http.Request, status int, id int64) {
+	report, err := h.loadReceptionReport(r, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Reception report")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading reception report", err)
+		return
+	}
+	writeJSON(w, status, report)
+}
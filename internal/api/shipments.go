@@ -0,0 +1,403 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/workflow"
+)
+
+// shipmentResource is a shipment as returned by the API
+type shipmentResource struct {
+	models.Shipment
+	ClientCompanyName    string `json:"client_company_name"`
+	SoftwareEngineerName string `json:"software_engineer_name,omitempty"`
+}
+
+// shipmentSortColumns are the columns shipments can be sorted by
+var shipmentSortColumns = map[string]string{
+	"id":      "s.id",
+	"status":  "s.status::text",
+	"type":    "s.shipment_type::text",
+	"company": "c.name",
+	"created": "s.created_at",
+	"updated": "s.updated_at",
+}
+
+const shipmentColumns = `
+	s.id, s.shipment_type, s.laptop_count, s.client_company_id, s.software_engineer_id, s.status,
+	COALESCE(s.jira_ticket_number, ''), COALESCE(s.courier_name, ''), COALESCE(s.tracking_number, ''),
+	COALESCE(s.second_courier_name, ''), COALESCE(s.second_tracking_number, ''),
+	s.pickup_scheduled_date, s.picked_up_at, s.arrived_warehouse_at, s.released_warehouse_at,
+	s.eta_to_engineer, s.delivered_at,
+	COALESCE(s.exception_reason, ''), s.exception_at, s.status_before_exception,
+	COALESCE(s.export_reason, ''), COALESCE(s.recipient_tax_id, ''), s.customs_currency,
+	COALESCE(s.notes, ''), s.created_at, s.updated_at,
+	c.name, COALESCE(se.name, '')
+	FROM shipments s
+	JOIN client_companies c ON c.id = s.client_company_id
+	LEFT JOIN software_engineers se ON se.id = s.software_engineer_id`
+
+// scanShipment scans a row selected with shipmentColumns
+func scanShipment(row interface{ Scan(...interface{}) error }) (shipmentResource, error) {
+	var res shipmentResource
+	s := &res.Shipment
+	err := row.Scan(
+		&s.ID, &s.ShipmentType, &s.LaptopCount, &s.ClientCompanyID, &s.SoftwareEngineerID, &s.Status,
+		&s.JiraTicketNumber, &s.CourierName, &s.TrackingNumber,
+		&s.SecondCourierName, &s.SecondTrackingNumber,
+		&s.PickupScheduledDate, &s.PickedUpAt, &s.ArrivedWarehouseAt, &s.ReleasedWarehouseAt,
+		&s.ETAToEngineer, &s.DeliveredAt,
+		&s.ExceptionReason, &s.ExceptionAt, &s.StatusBeforeException,
+		&s.ExportReason, &s.RecipientTaxID, &s.CustomsCurrency,
+		&s.Notes, &s.CreatedAt, &s.UpdatedAt,
+		&res.ClientCompanyName, &res.SoftwareEngineerName,
+	)
+	return res, err
+}
+
+// shipmentVisibility returns the condition limiting shipments to those the user can see,
+// the same rules as the shipments page
+func shipmentVisibility(user *models.User, argIndex int) (string, []interface{}) {
+	switch user.Role {
+	case models.RoleClient:
+		// Clients can only see their own company's shipments
+		if user.ClientCompanyID == nil {
+			return "FALSE", nil
+		}
+		return fmt.Sprintf("s.client_company_id = $%d", argIndex), []interface{}{*user.ClientCompanyID}
+	case models.RoleWarehouse:
+		// Warehouse users see shipments in transit to, at or released from the warehouse
+		return "s.status IN ('in_transit_to_warehouse', 'at_warehouse', 'released_from_warehouse')", nil
+	case models.RoleProjectManager:
+		// Project managers see the shipments of the client companies assigned to them
+		return user.CompanyScope().Condition("s.client_company_id", argIndex)
+	case models.RoleLogistics:
+		return "TRUE", nil
+	}
+	return "FALSE", nil
+}
+
+// ListShipments returns the shipments visible to the user.
+// Filters: status, type, client_company_id, search (tracking number or company name).
+func (h *Handler) ListShipments(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ShipmentView)
+	if !ok {
+		return
+	}
+	page, ok := requirePage(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	visibility, args := shipmentVisibility(user, 1)
+	conditions := []string{visibility}
+
+	if status := query.Get("status"); status != "" {
+		if !models.IsValidShipmentStatus(models.ShipmentStatus(status)) {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid status filter")
+			return
+		}
+		args = append(args, status)
+		conditions = append(conditions, fmt.Sprintf("s.status = $%d", len(args)))
+	}
+	if shipmentType := query.Get("type"); shipmentType != "" {
+		if !models.IsValidShipmentType(models.ShipmentType(shipmentType)) {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid type filter")
+			return
+		}
+		args = append(args, shipmentType)
+		conditions = append(conditions, fmt.Sprintf("s.shipment_type = $%d", len(args)))
+	}
+	if raw := query.Get("client_company_id"); raw != "" {
+		companyID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, CodeBadRequest, "Invalid client_company_id filter")
+			return
+		}
+		args = append(args, companyID)
+		conditions = append(conditions, fmt.Sprintf("s.client_company_id = $%d", len(args)))
+	}
+	if search := strings.TrimSpace(query.Get("search")); search != "" {
+		args = append(args, "%"+search+"%")
+		conditions = append(conditions, fmt.Sprintf("(s.tracking_number ILIKE $%d OR c.name ILIKE $%d)", len(args), len(args)))
+	}
+
+	orderBy := "s.created_at DESC, s.id DESC"
+	if column, ok := shipmentSortColumns[query.Get("sort")]; ok {
+		direction := "ASC"
+		if query.Get("order") == "desc" {
+			direction = "DESC"
+		}
+		orderBy = fmt.Sprintf("%s %s, s.id %s", column, direction, direction)
+	}
+
+	args = append(args, page.Limit(), page.Offset())
+	rows, err := h.DB.QueryContext(r.Context(),
+		fmt.Sprintf("SELECT %s WHERE %s ORDER BY %s LIMIT $%d OFFSET $%d",
+			shipmentColumns, strings.Join(conditions, " AND "), orderBy, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		writeInternalError(w, "loading shipments", err)
+		return
+	}
+	defer rows.Close()
+
+	shipments := []shipmentResource{}
+	for rows.Next() {
+		s, err := scanShipment(rows)
+		if err != nil {
+			writeInternalError(w, "loading shipments", err)
+			return
+		}
+		shipments = append(shipments, s)
+	}
+	if err := rows.Err(); err != nil {
+		writeInternalError(w, "loading shipments", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pageOf(shipments, page))
+}
+
+// GetShipment returns a shipment with its laptops
+func (h *Handler) GetShipment(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ShipmentView)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	shipment, err := h.loadShipment(r, user, id)
+	if isNotFound(err) {
+		writeNotFound(w, "Shipment")
+		return
+	}
+	if err != nil {
+		writeInternalError(w, "loading shipment", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shipment)
+}
+
+// loadShipment loads a shipment visible to the user, with its laptops.
+// Shipments the user cannot see are reported as not found.
+func (h *Handler) loadShipment(r *http.Request, user *models.User, id int64) (*shipmentResource, error) {
+	visibility, args := shipmentVisibility(user, 2)
+	shipment, err := scanShipment(h.DB.QueryRowContext(r.Context(),
+		fmt.Sprintf("SELECT %s WHERE s.id = $1 AND %s", shipmentColumns, visibility),
+		append([]interface{}{id}, args...)...,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := h.DB.QueryContext(r.Context(),
+		`SELECT l.id, l.serial_number, COALESCE(l.sku, ''), COALESCE(l.brand, ''), l.model, l.cpu, l.ram_gb, l.ssd_gb,
+		        l.status, l.client_company_id, l.software_engineer_id, l.created_at, l.updated_at
+		FROM laptops l
+		JOIN shipment_laptops sl ON sl.laptop_id = l.id
+		WHERE sl.shipment_id = $1
+		ORDER BY l.serial_number`,
+		id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment laptops: %w", err)
+	}
+	defer rows.Close()
+
+	shipment.Laptops = []models.Laptop{}
+	for rows.Next() {
+		var l models.Laptop
+		if err := rows.Scan(&l.ID, &l.SerialNumber, &l.SKU, &l.Brand, &l.Model, &l.CPU, &l.RAMGB, &l.SSDGB,
+			&l.Status, &l.ClientCompanyID, &l.SoftwareEngineerID, &l.CreatedAt, &l.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment laptop: %w", err)
+		}
+		shipment.Laptops = append(shipment.Laptops, l)
+	}
+	return &shipment, rows.Err()
+}
+
+// createShipmentRequest is the body of POST /shipments
+type createShipmentRequest struct {
+	ClientCompanyID  int64  `json:"client_company_id"`
+	JiraTicketNumber string `json:"jira_ticket_number"`
+	Notes            string `json:"notes"`
+}
+
+// CreateShipment creates a single full journey shipment awaiting pickup (logistics only),
+// like the create shipment page
+func (h *Handler) CreateShipment(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ShipmentCreate)
+	if !ok {
+		return
+	}
+
+	var req createShipmentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	shipment := models.Shipment{
+		ShipmentType:     models.ShipmentTypeSingleFullJourney,
+		ClientCompanyID:  req.ClientCompanyID,
+		Status:           models.ShipmentStatusPendingPickup,
+		LaptopCount:      1,
+		JiraTicketNumber: strings.TrimSpace(req.JiraTicketNumber),
+		Notes:            req.Notes,
+	}
+	if err := shipment.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+	if ok, err := shipment.IsValidStatusForType(r.Context(), h.DB, shipment.Status); err != nil {
+		writeInternalError(w, "creating shipment", err)
+		return
+	} else if !ok {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed,
+			fmt.Sprintf("status %s is not valid for shipment type %s", shipment.Status, shipment.ShipmentType))
+		return
+	}
+	if err := models.ValidateJiraTicketExists(shipment.JiraTicketNumber, h.JiraValidator); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, err.Error())
+		return
+	}
+	if _, err := models.GetClientCompanyByID(h.DB, shipment.ClientCompanyID); err != nil {
+		if isNotFound(err) {
+			writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, "client company does not exist")
+			return
+		}
+		writeInternalError(w, "creating shipment", err)
+		return
+	}
+
+	shipment.BeforeCreate()
+	err := h.DB.QueryRowContext(r.Context(),
+		`INSERT INTO shipments (shipment_type, client_company_id, status, laptop_count, jira_ticket_number, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id`,
+		shipment.ShipmentType, shipment.ClientCompanyID, shipment.Status, shipment.LaptopCount,
+		shipment.JiraTicketNumber, shipment.Notes, shipment.CreatedAt, shipment.UpdatedAt,
+	).Scan(&shipment.ID)
+	if err != nil {
+		writeInternalError(w, "creating shipment", err)
+		return
+	}
+
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), h.DB, &models.ShipmentStatusEvent{
+		ShipmentID:  shipment.ID,
+		ToStatus:    shipment.Status,
+		ActorUserID: actorUserID(user),
+		Source:      models.StatusEventSourceAPI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		// Non-critical error, just log it
+		fmt.Printf("Warning: Failed to record shipment status event: %v\n", err)
+	}
+
+	h.recordAudit(r, user, audit.Entry{
+		Action:     "shipment_created",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipment.ID,
+		Details: map[string]interface{}{
+			"jira_ticket_number": shipment.JiraTicketNumber,
+			"client_company_id":  shipment.ClientCompanyID,
+		},
+	})
+
+	created, err := h.loadShipment(r, user, shipment.ID)
+	if err != nil {
+		writeInternalError(w, "loading shipment", err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/shipments/%d", Prefix, shipment.ID))
+	writeJSON(w, http.StatusCreated, created)
+}
+
+// updateShipmentStatusRequest is the body of POST /shipments/{id}/status
+type updateShipmentStatusRequest struct {
+	Status          models.ShipmentStatus `json:"status"`
+	TrackingNumber  string                `json:"tracking_number"`
+	CourierName     string                `json:"courier_name"`
+	ETAToEngineer   *time.Time            `json:"eta_to_engineer"`
+	ExceptionReason string                `json:"exception_reason"`
+	Comment         string                `json:"comment"`
+}
+
+// UpdateShipmentStatus moves a shipment to a new status through the workflow engine (logistics only).
+// Guard failures and transitions the workflow does not allow are returned as 422.
+func (h *Handler) UpdateShipmentStatus(w http.ResponseWriter, r *http.Request) {
+	user, ok := requirePermission(w, r, permissions.ShipmentUpdateStatus)
+	if !ok {
+		return
+	}
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateShipmentStatusRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !models.IsValidShipmentStatus(req.Status) {
+		writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, "invalid status")
+		return
+	}
+
+	result, err := workflow.NewEngine(h.DB, h.EmailNotifier).Transition(r.Context(), id, req.Status, workflow.TransitionInput{
+		TrackingNumber: strings.TrimSpace(req.TrackingNumber),
+		CourierName:    strings.TrimSpace(req.CourierName),
+		ETA:            req.ETAToEngineer,
+		Reason:         strings.TrimSpace(req.ExceptionReason),
+		ActorUserID:    actorUserID(user),
+		Source:         models.StatusEventSourceAPI,
+		Comment:        strings.TrimSpace(req.Comment),
+	})
+	if err != nil {
+		var guardErr *workflow.GuardError
+		switch {
+		case errors.As(err, &guardErr):
+			writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, guardErr.Message)
+		case errors.Is(err, workflow.ErrInvalidTransition):
+			writeError(w, http.StatusUnprocessableEntity, CodeValidationFailed, "Invalid status transition. Status updates must be sequential and cannot skip stages or go backwards.")
+		case errors.Is(err, workflow.ErrShipmentNotFound):
+			writeNotFound(w, "Shipment")
+		case errors.Is(err, workflow.ErrConcurrentUpdate):
+			writeError(w, http.StatusConflict, CodeConflict, "Shipment status was changed by someone else. Reload the shipment and try again.")
+		default:
+			writeInternalError(w, "updating shipment status", err)
+		}
+		return
+	}
+
+	details := map[string]interface{}{
+		"old_status": result.From,
+		"new_status": req.Status,
+	}
+	if models.IsExceptionStatus(req.Status) {
+		details["exception_reason"] = result.Shipment.ExceptionReason
+	}
+	h.recordAudit(r, user, audit.Entry{Action: "status_updated", EntityType: audit.EntityShipment, EntityID: id, Details: details})
+
+	shipment, err := h.loadShipment(r, user, id)
+	if err != nil {
+		writeInternalError(w, "loading shipment", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, shipment)
+}
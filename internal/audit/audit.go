@@ -0,0 +1,191 @@
+// Package audit records who changed what in the audit_logs table.
+// Every mutation goes through Log or Record with the actor, the entity, the action and, for
+// edits, the fields that changed, computed with Diff from snapshots taken before and after.
+// Entries are chained by hash and the table is append-only; Verify walks the chain.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// Entity types used in audit entries
+const (
+	EntityShipment         = "shipment"
+	EntityLaptop           = "laptop"
+	EntityUser             = "user"
+	EntityClientCompany    = "client_company"
+	EntitySoftwareEngineer = "software_engineer"
+	EntityCourier          = "courier"
+	EntityReceptionReport  = "reception_report"
+	EntityWorkflow         = "workflow"
+	EntityRolePermissions  = "role_permissions"
+	EntityAPIToken         = "api_token"
+	EntityServiceAccount   = "service_account"
+	EntityEmailOutbox      = "email_outbox"
+	EntityEmailTemplate    = "email_template"
+)
+
+// ignoredFields are left out of diffs because every save changes them
+var ignoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// Querier runs the queries that append an entry; *sql.DB and *sql.Tx both satisfy it, so an
+// entry can be written in the same transaction as the change it records
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Actor is who made a change: a user, or a service account acting through the API
+type Actor struct {
+	UserID           *int64
+	ServiceAccountID *int64
+	IPAddress        string // Recorded for account security events
+	Source           string // "api" for changes made through the JSON API
+}
+
+// UserActor returns the actor for a signed-in user or API client
+func UserActor(user *models.User) Actor {
+	if user == nil {
+		return Actor{}
+	}
+	if user.IsServiceAccount() {
+		return Actor{ServiceAccountID: user.ServiceAccountID}
+	}
+	id := user.ID
+	return Actor{UserID: &id}
+}
+
+// Entry is one audited action on an entity
+type Entry struct {
+	Action     string
+	EntityType string
+	EntityID   int64
+	Changes    []models.AuditChange   // Fields changed by an edit, from Diff
+	Details    map[string]interface{} // Other context, e.g. the reason for a status change
+}
+
+// Record appends an audit entry to the chain
+func Record(ctx context.Context, db Querier, actor Actor, entry Entry) error {
+	details := make(map[string]interface{}, len(entry.Details)+3)
+	for key, value := range entry.Details {
+		details[key] = value
+	}
+	details["action"] = entry.Action
+	if actor.IPAddress != "" {
+		details["ip_address"] = actor.IPAddress
+	}
+	if actor.Source != "" {
+		details["source"] = actor.Source
+	}
+
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+	changes := entry.Changes
+	if changes == nil {
+		changes = []models.AuditChange{}
+	}
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit changes: %w", err)
+	}
+
+	chained := chainedEntry{
+		UserID:           actor.UserID,
+		ServiceAccountID: actor.ServiceAccountID,
+		Action:           entry.Action,
+		EntityType:       entry.EntityType,
+		EntityID:         entry.EntityID,
+		Timestamp:        time.Now().Format(timestampLayout),
+	}
+
+	// Entries are chained, which takes a transaction; outside of one, start it here
+	if sqlDB, ok := db.(*sql.DB); ok {
+		tx, err := sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to start transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		if err := appendEntry(ctx, tx, chained, detailsJSON, changesJSON); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit audit log: %w", err)
+		}
+		return nil
+	}
+	return appendEntry(ctx, db, chained, detailsJSON, changesJSON)
+}
+
+// Log writes an audit entry. The change it records has already been made, so a failure
+// to write the entry is only reported and does not fail the request.
+func Log(ctx context.Context, db Querier, actor Actor, entry Entry) {
+	if err := Record(ctx, db, actor, entry); err != nil {
+		log.Printf("Warning: Failed to create audit log: %v", err)
+	}
+}
+
+// Diff compares two versions of an entity field by field, using their JSON names.
+// Each version is a struct, a pointer to one, or a Snapshot taken before the entity was changed.
+// Fields hidden from JSON, like password hashes, and the timestamps every save updates are left out.
+// Either version may be nil, for an entity that was created or deleted.
+func Diff(before, after interface{}) []models.AuditChange {
+	beforeFields := Snapshot(before)
+	afterFields := Snapshot(after)
+
+	names := make(map[string]bool, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names[name] = true
+	}
+	for name := range afterFields {
+		names[name] = true
+	}
+
+	var changes []models.AuditChange
+	for name := range names {
+		if ignoredFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			changes = append(changes, models.AuditChange{
+				Field:  name,
+				Before: beforeFields[name],
+				After:  afterFields[name],
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// Snapshot returns the JSON fields of an entity. Taken before an entity is changed in place,
+// it keeps the old values for Diff.
+func Snapshot(v interface{}) map[string]interface{} {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func TestDiff(t *testing.T) {
+	companyID := int64(3)
+	before := &models.Laptop{ID: 1, SerialNumber: "SN1", Brand: "Dell", Status: models.LaptopStatusAvailable, UpdatedAt: time.Now()}
+	snapshot := Snapshot(before)
+
+	// Edit the laptop in place, as handlers do
+	before.Status = models.LaptopStatusInTransitToEngineer
+	before.ClientCompanyID = &companyID
+	before.UpdatedAt = time.Now().Add(time.Minute)
+
+	changes := Diff(snapshot, before)
+	fields := make([]string, 0, len(changes))
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	if !reflect.DeepEqual(fields, []string{"client_company_id", "status"}) {
+		t.Fatalf("Expected client_company_id and status to change, got %v", fields)
+	}
+	if changes[1].Before != string(models.LaptopStatusAvailable) || changes[1].After != string(models.LaptopStatusInTransitToEngineer) {
+		t.Errorf("Unexpected status change: %+v", changes[1])
+	}
+}
+
+func TestDiff_CreatedAndDeleted(t *testing.T) {
+	user := &models.User{ID: 7, Email: "jane@example.com", PasswordHash: "secret", Role: models.RoleWarehouse}
+
+	created := Diff(nil, user)
+	if len(created) == 0 {
+		t.Fatal("Expected the fields of a created entity")
+	}
+	for _, change := range created {
+		if change.Before != nil {
+			t.Errorf("Expected no value before creation for %s, got %v", change.Field, change.Before)
+		}
+		if strings.Contains(change.Field, "password") {
+			t.Errorf("Expected the password hash to be left out, got field %s", change.Field)
+		}
+	}
+
+	var deletedLaptop *models.Laptop
+	if changes := Diff(deletedLaptop, nil); changes != nil {
+		t.Errorf("Expected no changes between two missing entities, got %v", changes)
+	}
+}
+
+func TestFilter_Where(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where, args := Filter{}.where()
+	if where != "" || args != nil {
+		t.Errorf("Expected an empty filter to match everything, got %q %v", where, args)
+	}
+
+	where, args = Filter{EntityType: EntityLaptop, UserID: 5, From: from}.where()
+	want := "WHERE a.entity_type = $1 AND a.user_id = $2 AND a.timestamp >= $3"
+	if where != want {
+		t.Errorf("where() = %q, want %q", where, want)
+	}
+	if !reflect.DeepEqual(args, []interface{}{EntityLaptop, int64(5), from}) {
+		t.Errorf("Unexpected arguments: %v", args)
+	}
+}
@@ -0,0 +1,226 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GenesisHash is the previous hash of the first entry of the chain
+var GenesisHash = strings.Repeat("0", 64)
+
+// timestampLayout is how the timestamp of an entry is written and hashed. The column has no
+// time zone and keeps microseconds, so the wall clock at that precision is what is stored.
+const timestampLayout = "2006-01-02T15:04:05.000000"
+
+// chainedEntry is the content of an entry covered by its hash
+type chainedEntry struct {
+	ID               int64       `json:"id"`
+	PrevHash         string      `json:"prev_hash"`
+	UserID           *int64      `json:"user_id"`
+	ServiceAccountID *int64      `json:"service_account_id"`
+	Action           string      `json:"action"`
+	EntityType       string      `json:"entity_type"`
+	EntityID         int64       `json:"entity_id"`
+	Timestamp        string      `json:"timestamp"`
+	Details          interface{} `json:"details"`
+	Changes          interface{} `json:"changes"`
+}
+
+// hash returns the hex SHA-256 of the entry. Details and changes are decoded and encoded again,
+// so the JSON as written and as PostgreSQL returns it from a JSONB column hash the same.
+func (e chainedEntry) hash(details, changes []byte) (string, error) {
+	if err := decodeJSON(details, &e.Details); err != nil {
+		return "", fmt.Errorf("failed to decode details: %w", err)
+	}
+	if err := decodeJSON(changes, &e.Changes); err != nil {
+		return "", fmt.Errorf("failed to decode changes: %w", err)
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// decodeJSON decodes a JSON value; NULL decodes to nil
+func decodeJSON(data []byte, v *interface{}) error {
+	if len(data) == 0 {
+		*v = nil
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// appendEntry writes an entry at the end of the chain. It must run in a transaction: the advisory
+// lock is held until commit, so entries are appended one at a time, in the order of their IDs.
+func appendEntry(ctx context.Context, tx Querier, entry chainedEntry, details, changes []byte) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('audit_logs'))`); err != nil {
+		return fmt.Errorf("failed to lock audit chain: %w", err)
+	}
+
+	err := tx.QueryRowContext(ctx,
+		`SELECT hash FROM audit_logs WHERE hash IS NOT NULL ORDER BY id DESC LIMIT 1`,
+	).Scan(&entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.PrevHash = GenesisHash
+	} else if err != nil {
+		return fmt.Errorf("failed to read end of audit chain: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx, `SELECT nextval(pg_get_serial_sequence('audit_logs', 'id'))`).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to allocate audit log ID: %w", err)
+	}
+
+	hash, err := entry.hash(details, changes)
+	if err != nil {
+		return fmt.Errorf("failed to hash audit log: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO audit_logs (id, user_id, service_account_id, action, entity_type, entity_id, timestamp, details, changes, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		entry.ID, entry.UserID, entry.ServiceAccountID, entry.Action, entry.EntityType, entry.EntityID,
+		entry.Timestamp, details, changes, entry.PrevHash, hash,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create audit log: %w", err)
+	}
+	return nil
+}
+
+// Verification is the result of walking the audit chain
+type Verification struct {
+	Unchained int    // Entries written before the chain started
+	Checked   int    // Chained entries whose links and hashes were checked
+	HeadID    int64  // ID of the last entry checked
+	HeadHash  string // Hash of the last entry checked; record it elsewhere to detect removal of the newest entries
+	Broken    *BrokenLink
+}
+
+// BrokenLink is the first entry where the chain does not hold
+type BrokenLink struct {
+	EntryID int64
+	Reason  string
+}
+
+// chainRow is an entry as read back for verification
+type chainRow struct {
+	entry    chainedEntry
+	hash     sql.NullString
+	prevHash sql.NullString
+	details  []byte
+	changes  []byte
+}
+
+// chainWalker checks entries in ID order against the entry before them
+type chainWalker struct {
+	result Verification
+}
+
+// check checks the next entry and returns false at the first broken link
+func (w *chainWalker) check(row chainRow) bool {
+	if !row.hash.Valid {
+		if w.result.Checked == 0 {
+			w.result.Unchained++
+			return true
+		}
+		w.result.Broken = &BrokenLink{EntryID: row.entry.ID, Reason: "entry has no hash after the chain started"}
+		return false
+	}
+
+	expectedPrev := GenesisHash
+	if w.result.Checked > 0 {
+		expectedPrev = w.result.HeadHash
+	}
+	if row.prevHash.String != expectedPrev {
+		reason := "previous hash does not match the first entry of the chain"
+		if w.result.Checked > 0 {
+			reason = fmt.Sprintf("previous hash does not match entry %d; an entry was removed, changed or inserted", w.result.HeadID)
+		}
+		w.result.Broken = &BrokenLink{EntryID: row.entry.ID, Reason: reason}
+		return false
+	}
+
+	row.entry.PrevHash = row.prevHash.String
+	hash, err := row.entry.hash(row.details, row.changes)
+	if err != nil {
+		w.result.Broken = &BrokenLink{EntryID: row.entry.ID, Reason: err.Error()}
+		return false
+	}
+	if hash != row.hash.String {
+		w.result.Broken = &BrokenLink{EntryID: row.entry.ID, Reason: "content does not match its hash; the entry was changed"}
+		return false
+	}
+
+	w.result.Checked++
+	w.result.HeadID = row.entry.ID
+	w.result.HeadHash = row.hash.String
+	return true
+}
+
+// Verify walks the audit chain from its first entry and stops at the first broken link
+func Verify(ctx context.Context, db *sql.DB) (*Verification, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, user_id, service_account_id, action, entity_type, entity_id, timestamp,
+			details, changes, prev_hash, hash
+		FROM audit_logs
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	walker := &chainWalker{}
+	for rows.Next() {
+		var row chainRow
+		var userID, serviceAccountID sql.NullInt64
+		var timestamp time.Time
+		err := rows.Scan(
+			&row.entry.ID, &userID, &serviceAccountID, &row.entry.Action, &row.entry.EntityType, &row.entry.EntityID,
+			&timestamp, &row.details, &row.changes, &row.prevHash, &row.hash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		if userID.Valid {
+			row.entry.UserID = &userID.Int64
+		}
+		if serviceAccountID.Valid {
+			row.entry.ServiceAccountID = &serviceAccountID.Int64
+		}
+		row.entry.Timestamp = timestamp.Format(timestampLayout)
+
+		if !walker.check(row) {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit logs: %w", err)
+	}
+
+	return &walker.result, nil
+}
+
+// InChain reports whether an entry with the hash is among the entries up to lastID, which
+// Verify checked. A head hash kept from an earlier run that is no longer found means the
+// newest entries were removed, which the chain alone cannot show.
+func InChain(ctx context.Context, db *sql.DB, hash string, lastID int64) (bool, error) {
+	var found bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM audit_logs WHERE hash = $1 AND id <= $2)`,
+		hash, lastID,
+	).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up audit hash: %w", err)
+	}
+	return found, nil
+}
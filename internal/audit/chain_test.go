@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestChainedEntryHash_CanonicalJSON(t *testing.T) {
+	userID := int64(5)
+	entry := chainedEntry{ID: 1, PrevHash: GenesisHash, UserID: &userID, Action: "laptop_updated", EntityType: EntityLaptop, EntityID: 9, Timestamp: "2026-01-02T03:04:05.000006"}
+
+	// As written by Record, and as PostgreSQL returns the same values from JSONB columns
+	written, err := entry.hash([]byte(`{"source":"api","action":"laptop_updated"}`), []byte(`[{"field":"status","before":"available","after":"in_transit_to_engineer"}]`))
+	if err != nil {
+		t.Fatalf("hash failed: %v", err)
+	}
+	stored, err := entry.hash([]byte(`{"action": "laptop_updated", "source": "api"}`), []byte(`[{"after": "in_transit_to_engineer", "field": "status", "before": "available"}]`))
+	if err != nil {
+		t.Fatalf("hash failed: %v", err)
+	}
+	if written != stored || len(written) != 64 {
+		t.Errorf("Expected the same hash for equivalent JSON, got %s and %s", written, stored)
+	}
+
+	entry.EntityID = 10
+	changed, _ := entry.hash([]byte(`{"action":"laptop_updated","source":"api"}`), []byte(`[]`))
+	if changed == written {
+		t.Error("Expected a different hash for different content")
+	}
+}
+
+// newChain returns rows forming a valid chain
+func newChain(t *testing.T, n int) []chainRow {
+	t.Helper()
+	rows := make([]chainRow, 0, n)
+	prev := GenesisHash
+	for i := 1; i <= n; i++ {
+		row := chainRow{
+			entry:   chainedEntry{ID: int64(i * 2), Action: "shipment_edited", EntityType: EntityShipment, EntityID: int64(i), Timestamp: "2026-01-02T03:04:05.000000"},
+			details: []byte(`{"action":"shipment_edited"}`),
+			changes: []byte(`[]`),
+		}
+		row.entry.PrevHash = prev
+		hash, err := row.entry.hash(row.details, row.changes)
+		if err != nil {
+			t.Fatalf("hash failed: %v", err)
+		}
+		row.prevHash = sql.NullString{String: prev, Valid: true}
+		row.hash = sql.NullString{String: hash, Valid: true}
+		rows = append(rows, row)
+		prev = hash
+	}
+	return rows
+}
+
+func walk(rows []chainRow) Verification {
+	walker := &chainWalker{}
+	for _, row := range rows {
+		if !walker.check(row) {
+			break
+		}
+	}
+	return walker.result
+}
+
+func TestChainWalker(t *testing.T) {
+	legacy := chainRow{entry: chainedEntry{ID: 1}}
+
+	t.Run("intact", func(t *testing.T) {
+		rows := append([]chainRow{legacy}, newChain(t, 3)...)
+		result := walk(rows)
+		if result.Broken != nil {
+			t.Fatalf("Expected an intact chain, got %+v", result.Broken)
+		}
+		if result.Unchained != 1 || result.Checked != 3 || result.HeadID != 6 || result.HeadHash != rows[3].hash.String {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	tests := []struct {
+		name      string
+		tamper    func(rows []chainRow) []chainRow
+		brokenID  int64
+		reasonHas string
+	}{
+		{"changed content", func(rows []chainRow) []chainRow {
+			rows[1].entry.EntityID = 99
+			return rows
+		}, 4, "content"},
+		{"changed details", func(rows []chainRow) []chainRow {
+			rows[2].details = []byte(`{"action":"shipment_deleted"}`)
+			return rows
+		}, 6, "content"},
+		{"removed entry", func(rows []chainRow) []chainRow {
+			return append(rows[:1], rows[2:]...)
+		}, 6, "entry 2"},
+		{"removed first entry", func(rows []chainRow) []chainRow {
+			return rows[1:]
+		}, 4, "first entry"},
+		{"unchained entry added", func(rows []chainRow) []chainRow {
+			return append(rows, chainRow{entry: chainedEntry{ID: 7}})
+		}, 7, "no hash"},
+		{"rehashed entry", func(rows []chainRow) []chainRow {
+			rows[0].entry.EntityID = 99
+			hash, _ := rows[0].entry.hash(rows[0].details, rows[0].changes)
+			rows[0].hash.String = hash
+			return rows
+		}, 4, "entry 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := walk(tt.tamper(newChain(t, 3)))
+			if result.Broken == nil {
+				t.Fatal("Expected a broken link")
+			}
+			if result.Broken.EntryID != tt.brokenID || !strings.Contains(result.Broken.Reason, tt.reasonHas) {
+				t.Errorf("Expected entry %d broken (%s), got %+v", tt.brokenID, tt.reasonHas, result.Broken)
+			}
+		})
+	}
+}
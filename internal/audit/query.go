@@ -0,0 +1,130 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// Filter selects audit entries; zero values match everything
+type Filter struct {
+	EntityType string
+	EntityID   int64
+	UserID     int64
+	From       time.Time // Entries at or after this time
+	To         time.Time // Entries before this time
+	Limit      int       // 0 for no limit
+	Offset     int
+}
+
+// where builds the WHERE clause of the filter
+func (f Filter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.EntityType != "" {
+		add("a.entity_type = $%d", f.EntityType)
+	}
+	if f.EntityID != 0 {
+		add("a.entity_id = $%d", f.EntityID)
+	}
+	if f.UserID != 0 {
+		add("a.user_id = $%d", f.UserID)
+	}
+	if !f.From.IsZero() {
+		add("a.timestamp >= $%d", f.From)
+	}
+	if !f.To.IsZero() {
+		add("a.timestamp < $%d", f.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// List returns the audit entries matching the filter, newest first, with the name of their actor
+func List(ctx context.Context, db *sql.DB, f Filter) ([]*models.AuditLog, error) {
+	where, args := f.where()
+	query := `SELECT a.id, COALESCE(a.user_id, 0), a.service_account_id, a.action, a.entity_type, a.entity_id,
+			a.timestamp, COALESCE(a.details, '{}'::jsonb), COALESCE(a.changes, '[]'::jsonb),
+			COALESCE(u.email, sa.name, '')
+		FROM audit_logs a
+		LEFT JOIN users u ON u.id = a.user_id
+		LEFT JOIN service_accounts sa ON sa.id = a.service_account_id
+		` + where + `
+		ORDER BY a.timestamp DESC, a.id DESC`
+	if f.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", f.Limit, f.Offset)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit logs: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []*models.AuditLog
+	for rows.Next() {
+		entry := &models.AuditLog{}
+		var details, changes []byte
+		err := rows.Scan(
+			&entry.ID, &entry.UserID, &entry.ServiceAccountID, &entry.Action, &entry.EntityType, &entry.EntityID,
+			&entry.Timestamp, &details, &changes, &entry.ActorName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit log: %w", err)
+		}
+		entry.Details = details
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, fmt.Errorf("failed to decode changes of audit log %d: %w", entry.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating audit logs: %w", err)
+	}
+
+	return entries, nil
+}
+
+// Count returns the number of audit entries matching the filter, ignoring its limit and offset
+func Count(ctx context.Context, db *sql.DB, f Filter) (int, error) {
+	where, args := f.where()
+	var count int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_logs a "+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count audit logs: %w", err)
+	}
+	return count, nil
+}
+
+// EntityTypes returns the entity types that have audit entries, for filtering
+func EntityTypes(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT entity_type FROM audit_logs ORDER BY entity_type")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit entity types: %w", err)
+	}
+	defer rows.Close()
+
+	var types []string
+	for rows.Next() {
+		var entityType string
+		if err := rows.Scan(&entityType); err != nil {
+			return nil, fmt.Errorf("failed to scan audit entity type: %w", err)
+		}
+		types = append(types, entityType)
+	}
+	return types, rows.Err()
+}
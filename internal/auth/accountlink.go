@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+const (
+	// DefaultInvitationDuration is how long an invitation link is valid, in hours
+	DefaultInvitationDuration = 72
+	// DefaultPasswordResetDuration is how long a password reset link is valid, in hours
+	DefaultPasswordResetDuration = 1
+	// PasswordResetCooldown is the minimum time between two password reset emails to the same user
+	PasswordResetCooldown = 5 * time.Minute
+)
+
+// CreateAccountLink creates an invitation or password reset link for the user.
+// Unused links of the same purpose stop working, so only the latest email is valid.
+// Creating an invitation also records it on the user for the invitation status.
+func CreateAccountLink(ctx context.Context, db *sql.DB, userID int64, purpose models.MagicLinkPurpose, durationHours int) (*models.MagicLink, error) {
+	if purpose != models.MagicLinkPurposeInvitation && purpose != models.MagicLinkPurposePasswordReset {
+		return nil, fmt.Errorf("invalid account link purpose %q", purpose)
+	}
+
+	token, err := GenerateMagicLinkToken()
+	if err != nil {
+		return nil, err
+	}
+
+	link := &models.MagicLink{
+		UserID:    userID,
+		Token:     token,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(time.Duration(durationHours) * time.Hour),
+	}
+	link.BeforeCreate()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"DELETE FROM magic_links WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL",
+		userID, purpose,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke previous links: %w", err)
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO magic_links (user_id, token_hash, purpose, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		link.UserID, hashToken(link.Token), link.Purpose, link.ExpiresAt, link.CreatedAt,
+	).Scan(&link.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s link: %w", purpose, err)
+	}
+
+	if purpose == models.MagicLinkPurposeInvitation {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE users SET invited_at = $2, invitation_expires_at = $3, invitation_accepted_at = NULL WHERE id = $1",
+			userID, link.CreatedAt, link.ExpiresAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record invitation: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit %s link: %w", purpose, err)
+	}
+	return link, nil
+}
+
+// ValidateAccountLink validates an invitation or password reset token and returns the link with user info
+// Returns nil if the link is invalid, expired, or already used
+func ValidateAccountLink(ctx context.Context, db *sql.DB, token string) (*models.MagicLink, error) {
+	return validateMagicLink(ctx, db, token, models.MagicLinkPurposeInvitation, models.MagicLinkPurposePasswordReset)
+}
+
+// PasswordResetRecentlySent reports whether a password reset link was created for the user
+// within PasswordResetCooldown, to stop the forgot password form from flooding a mailbox
+func PasswordResetRecentlySent(ctx context.Context, db *sql.DB, userID int64) (bool, error) {
+	var recent bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS(
+			SELECT 1 FROM magic_links
+			WHERE user_id = $1 AND purpose = $2 AND created_at > $3
+		)`,
+		userID, models.MagicLinkPurposePasswordReset, time.Now().Add(-PasswordResetCooldown),
+	).Scan(&recent)
+	if err != nil {
+		return false, fmt.Errorf("failed to check recent password resets: %w", err)
+	}
+	return recent, nil
+}
+
+// SetPasswordWithLink uses an invitation or password reset link to set the user's password.
+// The link can only be used once. Other unused account links of the user stop working, the failed
+// login count and lock are cleared, and all sessions of the user are ended.
+// Returns nil if the link is invalid, expired, or already used.
+func SetPasswordWithLink(ctx context.Context, db *sql.DB, token, passwordHash string) (*models.MagicLink, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	link := &models.MagicLink{Token: token, UsedAt: &now}
+	err = tx.QueryRowContext(ctx,
+		`UPDATE magic_links SET used_at = $2
+		WHERE token_hash = $1 AND purpose IN ('invitation', 'password_reset') AND used_at IS NULL AND expires_at > $2
+		RETURNING id, user_id, purpose, expires_at, created_at`,
+		hashToken(token), now,
+	).Scan(&link.ID, &link.UserID, &link.Purpose, &link.ExpiresAt, &link.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to use %s link: %w", link.Purpose, err)
+	}
+
+	queries := []struct {
+		query string
+		args  []interface{}
+	}{
+		{
+			`UPDATE users SET
+				password_hash = $2,
+				failed_login_count = 0,
+				locked_until = NULL,
+				invitation_accepted_at = CASE
+					WHEN invited_at IS NOT NULL AND invitation_accepted_at IS NULL THEN $3
+					ELSE invitation_accepted_at
+				END,
+				updated_at = $3
+			WHERE id = $1`,
+			[]interface{}{link.UserID, passwordHash, now},
+		},
+		{
+			"DELETE FROM magic_links WHERE user_id = $1 AND purpose IN ('invitation', 'password_reset') AND used_at IS NULL",
+			[]interface{}{link.UserID},
+		},
+		{
+			"DELETE FROM sessions WHERE user_id = $1",
+			[]interface{}{link.UserID},
+		},
+	}
+	for _, q := range queries {
+		if _, err := tx.ExecContext(ctx, q.query, q.args...); err != nil {
+			return nil, fmt.Errorf("failed to set password: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit password change: %w", err)
+	}
+	return link, nil
+}
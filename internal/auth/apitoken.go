@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+const (
+	// APITokenPrefix starts every API token, so leaked tokens are easy to recognise and search for
+	APITokenPrefix = "lts_"
+	// APITokenLength is the length of the random part of an API token in bytes
+	APITokenLength = 32
+	// apiTokenLastUsedInterval limits how often last-used timestamps are written for a busy token
+	apiTokenLastUsedInterval = time.Minute
+)
+
+// GenerateAPIToken generates a new API token
+func GenerateAPIToken() (string, error) {
+	bytes := make([]byte, APITokenLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	return APITokenPrefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// HashAPIToken returns the hex SHA-256 of a token, which is what the database stores.
+// Tokens are long random values, so a fast hash is enough to make a leaked table useless.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken generates a token, stores its hash on the given record and returns the token.
+// The token is not stored, so it must be shown to the user now.
+func CreateAPIToken(ctx context.Context, db *sql.DB, token *models.APIToken) (string, error) {
+	value, err := GenerateAPIToken()
+	if err != nil {
+		return "", err
+	}
+
+	token.TokenHash = HashAPIToken(value)
+	token.TokenPrefix = value[:models.APITokenPrefixLength]
+	if err := models.CreateAPIToken(db, token); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// ValidateAPIToken looks up a bearer token and returns it with the user it acts as.
+// For service account tokens the user is built from the service account (see ServiceAccount.AsUser).
+// Returns nil if the token is unknown, revoked, expired or its owner is disabled.
+func ValidateAPIToken(ctx context.Context, db *sql.DB, value string) (*models.APIToken, *models.User, error) {
+	if !strings.HasPrefix(value, APITokenPrefix) {
+		return nil, nil, nil
+	}
+
+	token, err := models.GetAPITokenByHash(db, HashAPIToken(value))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to validate API token: %w", err)
+	}
+	if !token.IsActive() {
+		return nil, nil, nil
+	}
+
+	var user *models.User
+	if token.ServiceAccountID != nil {
+		account, err := models.GetServiceAccountByID(db, *token.ServiceAccountID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load service account: %w", err)
+		}
+		if account.IsDisabled() {
+			return nil, nil, nil
+		}
+		user = account.AsUser()
+	} else {
+		user, err = models.GetUserByID(db, *token.UserID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load token owner: %w", err)
+		}
+		if err := models.LoadAssignedCompanies(db, user); err != nil {
+			return nil, nil, fmt.Errorf("failed to load assigned companies: %w", err)
+		}
+	}
+
+	touchAPIToken(ctx, db, token)
+	return token, user, nil
+}
+
+// touchAPIToken records that a token (and its service account) was used.
+// Failures are logged, they must not fail the request.
+func touchAPIToken(ctx context.Context, db *sql.DB, token *models.APIToken) {
+	now := time.Now()
+	if token.LastUsedAt != nil && now.Sub(*token.LastUsedAt) < apiTokenLastUsedInterval {
+		return
+	}
+	token.LastUsedAt = &now
+
+	if _, err := db.ExecContext(ctx, "UPDATE api_tokens SET last_used_at = $1 WHERE id = $2", now, token.ID); err != nil {
+		fmt.Printf("Warning: Failed to update API token last use: %v\n", err)
+	}
+	if token.ServiceAccountID != nil {
+		if _, err := db.ExecContext(ctx, "UPDATE service_accounts SET last_used_at = $1 WHERE id = $2", now, *token.ServiceAccountID); err != nil {
+			fmt.Printf("Warning: Failed to update service account last use: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func TestGenerateAPIToken(t *testing.T) {
+	token1, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken() failed: %v", err)
+	}
+	if !strings.HasPrefix(token1, APITokenPrefix) {
+		t.Errorf("GenerateAPIToken() = %q, expected prefix %q", token1, APITokenPrefix)
+	}
+	if len(token1) < len(APITokenPrefix)+40 {
+		t.Errorf("GenerateAPIToken() token length = %d, expected at least %d", len(token1), len(APITokenPrefix)+40)
+	}
+
+	token2, err := GenerateAPIToken()
+	if err != nil {
+		t.Fatalf("GenerateAPIToken() failed on second call: %v", err)
+	}
+	if token1 == token2 {
+		t.Error("GenerateAPIToken() generated duplicate tokens")
+	}
+}
+
+func TestHashAPIToken(t *testing.T) {
+	hash := HashAPIToken("lts_example")
+	if len(hash) != 64 {
+		t.Errorf("HashAPIToken() length = %d, expected 64", len(hash))
+	}
+	if hash != HashAPIToken("lts_example") {
+		t.Error("HashAPIToken() is not deterministic")
+	}
+	if hash == HashAPIToken("lts_example2") {
+		t.Error("HashAPIToken() returned the same hash for different tokens")
+	}
+}
+
+func TestValidateAPIToken_NotAnAPIToken(t *testing.T) {
+	// Values without the prefix are rejected before touching the database
+	token, user, err := ValidateAPIToken(context.Background(), nil, "some-session-token")
+	if err != nil || token != nil || user != nil {
+		t.Errorf("ValidateAPIToken() = %v, %v, %v, expected nil results", token, user, err)
+	}
+}
+
+func TestValidateAPIToken(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	user := &models.User{Email: "token@test.com", PasswordHash: "hashedpassword123", Role: models.RoleWarehouse}
+	if err := models.CreateUser(db, user); err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	account := &models.ServiceAccount{Name: "erp-sync", Role: models.RoleLogistics}
+	if err := models.CreateServiceAccount(db, account); err != nil {
+		t.Fatalf("Failed to create service account: %v", err)
+	}
+
+	personal := &models.APIToken{Name: "script", Scopes: []models.APITokenScope{models.ScopeRead}, UserID: &user.ID}
+	personalValue, err := CreateAPIToken(ctx, db, personal)
+	if err != nil {
+		t.Fatalf("Failed to create personal token: %v", err)
+	}
+
+	service := &models.APIToken{Name: "sync", Scopes: []models.APITokenScope{models.ScopeShipmentsWrite}, ServiceAccountID: &account.ID}
+	serviceValue, err := CreateAPIToken(ctx, db, service)
+	if err != nil {
+		t.Fatalf("Failed to create service account token: %v", err)
+	}
+
+	// Only the hash is stored
+	var stored string
+	if err := db.QueryRow("SELECT token_hash FROM api_tokens WHERE id = $1", personal.ID).Scan(&stored); err != nil {
+		t.Fatalf("Failed to read token hash: %v", err)
+	}
+	if stored == personalValue || stored != HashAPIToken(personalValue) {
+		t.Errorf("Expected the token hash to be stored, got %q", stored)
+	}
+
+	token, tokenUser, err := ValidateAPIToken(ctx, db, personalValue)
+	if err != nil || token == nil {
+		t.Fatalf("ValidateAPIToken() personal token = %v, %v", token, err)
+	}
+	if tokenUser.ID != user.ID || tokenUser.IsServiceAccount() {
+		t.Errorf("Expected the token to act as user %d, got %+v", user.ID, tokenUser)
+	}
+
+	token, tokenUser, err = ValidateAPIToken(ctx, db, serviceValue)
+	if err != nil || token == nil {
+		t.Fatalf("ValidateAPIToken() service account token = %v, %v", token, err)
+	}
+	if !tokenUser.IsServiceAccount() || tokenUser.Role != models.RoleLogistics {
+		t.Errorf("Expected the token to act as the service account, got %+v", tokenUser)
+	}
+
+	var lastUsed *string
+	db.QueryRow("SELECT last_used_at::text FROM service_accounts WHERE id = $1", account.ID).Scan(&lastUsed)
+	if lastUsed == nil {
+		t.Error("Expected the service account's last use to be recorded")
+	}
+
+	// Revoked tokens and disabled service accounts are rejected
+	if err := models.RevokeAPIToken(db, personal.ID); err != nil {
+		t.Fatalf("RevokeAPIToken() failed: %v", err)
+	}
+	if token, _, _ := ValidateAPIToken(ctx, db, personalValue); token != nil {
+		t.Error("Expected a revoked token to be rejected")
+	}
+
+	if err := models.SetServiceAccountDisabled(db, account.ID, true); err != nil {
+		t.Fatalf("SetServiceAccountDisabled() failed: %v", err)
+	}
+	if token, _, _ := ValidateAPIToken(ctx, db, serviceValue); token != nil {
+		t.Error("Expected a disabled service account's token to be rejected")
+	}
+
+	if token, _, _ := ValidateAPIToken(ctx, db, APITokenPrefix+"unknown"); token != nil {
+		t.Error("Expected an unknown token to be rejected")
+	}
+}
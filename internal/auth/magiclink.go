@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"github.com/yourusername/laptop-tracking-system/internal/models"
 )
 
@@ -60,6 +62,7 @@ func CreateMagicLink(ctx context.Context, db *sql.DB, userID int64, shipmentID *
 	magicLink := &models.MagicLink{
 		UserID:     userID,
 		Token:      token,
+		Purpose:    models.MagicLinkPurposeLogin,
 		ExpiresAt:  time.Now().Add(time.Duration(durationHours) * time.Hour),
 		ShipmentID: shipmentID,
 	}
@@ -68,10 +71,10 @@ func CreateMagicLink(ctx context.Context, db *sql.DB, userID int64, shipmentID *
 	// Insert magic link into database
 	err = db.QueryRowContext(
 		ctx,
-		`INSERT INTO magic_links (user_id, token, expires_at, shipment_id, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		`INSERT INTO magic_links (user_id, token_hash, purpose, expires_at, shipment_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
 		RETURNING id`,
-		magicLink.UserID, magicLink.Token, magicLink.ExpiresAt, magicLink.ShipmentID, magicLink.CreatedAt,
+		magicLink.UserID, hashToken(magicLink.Token), magicLink.Purpose, magicLink.ExpiresAt, magicLink.ShipmentID, magicLink.CreatedAt,
 	).Scan(&magicLink.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create magic link: %w", err)
@@ -83,29 +86,40 @@ func CreateMagicLink(ctx context.Context, db *sql.DB, userID int64, shipmentID *
 // ValidateMagicLink validates a magic link token and returns the magic link with user info
 // Returns nil if the magic link is invalid, expired, or already used
 func ValidateMagicLink(ctx context.Context, db *sql.DB, token string) (*models.MagicLink, error) {
+	return validateMagicLink(ctx, db, token, models.MagicLinkPurposeLogin)
+}
+
+// validateMagicLink validates a token of one of the given purposes, so an invitation or password
+// reset link cannot be used to sign in and a login link cannot be used to set a password
+func validateMagicLink(ctx context.Context, db *sql.DB, token string, purposes ...models.MagicLinkPurpose) (*models.MagicLink, error) {
 	if token == "" {
 		return nil, nil
 	}
 
-	magicLink := &models.MagicLink{}
+	magicLink := &models.MagicLink{Token: token}
 	user := &models.User{}
 
 	var usedAt sql.NullTime
 	var shipmentID sql.NullInt64
 	var googleID sql.NullString
 
+	purposeValues := make([]string, len(purposes))
+	for i, purpose := range purposes {
+		purposeValues[i] = string(purpose)
+	}
+
 	// Query magic link with user join
 	err := db.QueryRowContext(
 		ctx,
 		`SELECT 
-			ml.id, ml.user_id, ml.token, ml.expires_at, ml.used_at, ml.shipment_id, ml.created_at,
+			ml.id, ml.user_id, ml.purpose, ml.expires_at, ml.used_at, ml.shipment_id, ml.created_at,
 			u.id, u.email, u.password_hash, u.role, u.google_id, u.created_at, u.updated_at
 		FROM magic_links ml
 		INNER JOIN users u ON ml.user_id = u.id
-		WHERE ml.token = $1`,
-		token,
+		WHERE ml.token_hash = $1 AND ml.purpose = ANY($2)`,
+		hashToken(token), pq.Array(purposeValues),
 	).Scan(
-		&magicLink.ID, &magicLink.UserID, &magicLink.Token, &magicLink.ExpiresAt,
+		&magicLink.ID, &magicLink.UserID, &magicLink.Purpose, &magicLink.ExpiresAt,
 		&usedAt, &shipmentID, &magicLink.CreatedAt,
 		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &googleID,
 		&user.CreatedAt, &user.UpdatedAt,
@@ -148,8 +162,8 @@ func MarkMagicLinkAsUsed(ctx context.Context, db *sql.DB, token string) error {
 	now := time.Now()
 	result, err := db.ExecContext(
 		ctx,
-		"UPDATE magic_links SET used_at = $1 WHERE token = $2 AND used_at IS NULL",
-		now, token,
+		"UPDATE magic_links SET used_at = $1 WHERE token_hash = $2 AND used_at IS NULL",
+		now, hashToken(token),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to mark magic link as used: %w", err)
@@ -169,7 +183,7 @@ func MarkMagicLinkAsUsed(ctx context.Context, db *sql.DB, token string) error {
 
 // DeleteMagicLink deletes a magic link by token
 func DeleteMagicLink(ctx context.Context, db *sql.DB, token string) error {
-	_, err := db.ExecContext(ctx, "DELETE FROM magic_links WHERE token = $1", token)
+	_, err := db.ExecContext(ctx, "DELETE FROM magic_links WHERE token_hash = $1", hashToken(token))
 	if err != nil {
 		return fmt.Errorf("failed to delete magic link: %w", err)
 	}
@@ -196,44 +210,34 @@ func CleanupExpiredMagicLinks(ctx context.Context, db *sql.DB) (int, error) {
 	return int(rowsAffected), nil
 }
 
-// GetMagicLinkByShipmentAndUser retrieves a valid magic link for a specific shipment and user
-// Returns the token if found, empty string if not found
-func GetMagicLinkByShipmentAndUser(ctx context.Context, db *sql.DB, shipmentID int64, userID int64) (string, error) {
-	var token string
-	var usedAt sql.NullTime
-
-	err := db.QueryRowContext(
+// MarkShipmentMagicLinkAsUsed marks the latest valid login link of a user for a shipment as used,
+// once the form it was sent for has been submitted. It does nothing if there is no such link.
+func MarkShipmentMagicLinkAsUsed(ctx context.Context, db *sql.DB, shipmentID int64, userID int64) error {
+	now := time.Now()
+	_, err := db.ExecContext(
 		ctx,
-		`SELECT token, used_at
-		FROM magic_links
-		WHERE shipment_id = $1 AND user_id = $2 AND expires_at > $3
-		ORDER BY created_at DESC
-		LIMIT 1`,
-		shipmentID, userID, time.Now(),
-	).Scan(&token, &usedAt)
-
-	if err == sql.ErrNoRows {
-		return "", nil
-	}
+		`UPDATE magic_links SET used_at = $3
+		WHERE id = (
+			SELECT id FROM magic_links
+			WHERE shipment_id = $1 AND user_id = $2 AND expires_at > $3 AND used_at IS NULL AND purpose = 'login'
+			ORDER BY created_at DESC
+			LIMIT 1
+		)`,
+		shipmentID, userID, now,
+	)
 	if err != nil {
-		return "", fmt.Errorf("failed to get magic link: %w", err)
-	}
-
-	// Check if already used
-	if usedAt.Valid {
-		return "", nil
+		return fmt.Errorf("failed to mark magic link as used: %w", err)
 	}
-
-	return token, nil
+	return nil
 }
 
 // GetMagicLinksByUser retrieves all valid magic links for a user
 func GetMagicLinksByUser(ctx context.Context, db *sql.DB, userID int64) ([]*models.MagicLink, error) {
 	rows, err := db.QueryContext(
 		ctx,
-		`SELECT id, user_id, token, expires_at, used_at, shipment_id, created_at
+		`SELECT id, user_id, expires_at, used_at, shipment_id, created_at
 		FROM magic_links
-		WHERE user_id = $1 AND expires_at > $2
+		WHERE user_id = $1 AND expires_at > $2 AND purpose = 'login'
 		ORDER BY created_at DESC`,
 		userID, time.Now(),
 	)
@@ -249,7 +253,7 @@ func GetMagicLinksByUser(ctx context.Context, db *sql.DB, userID int64) ([]*mode
 		var shipmentID sql.NullInt64
 
 		err := rows.Scan(
-			&ml.ID, &ml.UserID, &ml.Token, &ml.ExpiresAt,
+			&ml.ID, &ml.UserID, &ml.ExpiresAt,
 			&usedAt, &shipmentID, &ml.CreatedAt,
 		)
 		if err != nil {
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -94,13 +95,14 @@ func FindOrCreateGoogleUser(ctx context.Context, db *sql.DB, userInfo *GoogleUse
 	var user models.User
 	err := db.QueryRowContext(
 		ctx,
-		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at
+		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at,
+			totp_enabled_at IS NOT NULL
 		FROM users
 		WHERE google_id = $1`,
 		userInfo.ID,
 	).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.ClientCompanyID,
-		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt,
+		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled,
 	)
 
 	if err == nil {
@@ -126,13 +128,14 @@ func FindOrCreateGoogleUser(ctx context.Context, db *sql.DB, userInfo *GoogleUse
 	// User not found, try to find by email
 	err = db.QueryRowContext(
 		ctx,
-		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at
+		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at,
+			totp_enabled_at IS NOT NULL
 		FROM users
 		WHERE email = $1`,
 		userInfo.Email,
 	).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.ClientCompanyID,
-		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt,
+		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled,
 	)
 
 	if err == nil {
@@ -177,26 +180,24 @@ func FindOrCreateGoogleUser(ctx context.Context, db *sql.DB, userInfo *GoogleUse
 	return &user, nil
 }
 
-// ValidateDomain checks if the user's email domain matches the allowed domain
-func ValidateDomain(email, allowedDomain string) bool {
-	if allowedDomain == "" {
+// ValidateDomain checks if the user's email domain is one of the allowed domains.
+// allowedDomains is a comma-separated list; an empty list allows any domain.
+func ValidateDomain(email, allowedDomains string) bool {
+	if strings.TrimSpace(allowedDomains) == "" {
 		return true // No domain restriction
 	}
 
 	// Extract domain from email
-	at := -1
-	for i := len(email) - 1; i >= 0; i-- {
-		if email[i] == '@' {
-			at = i
-			break
-		}
-	}
-
+	at := strings.LastIndex(email, "@")
 	if at == -1 {
 		return false
 	}
+	domain := strings.ToLower(email[at+1:])
 
-	domain := email[at+1:]
-	return domain == allowedDomain
+	for _, allowed := range strings.Split(allowedDomains, ",") {
+		if allowed = strings.ToLower(strings.TrimSpace(allowed)); allowed != "" && domain == allowed {
+			return true
+		}
+	}
+	return false
 }
-
@@ -0,0 +1,490 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// oidcClockSkew is how far the clocks of the app and a provider may differ when checking expiry
+const oidcClockSkew = time.Minute
+
+// ErrOIDCAccountConflict is returned when the email of an OIDC login belongs to an existing user
+// who is not a member of the provider's client company, so the identity cannot be linked to them
+var ErrOIDCAccountConflict = errors.New("an account with this email exists and cannot be linked to this provider")
+
+// OIDCProviderSettings configures an OpenID Connect identity provider
+type OIDCProviderSettings struct {
+	ID              string // Short name used in URLs and stored with linked identities
+	Name            string // Label of the sign-in button
+	IssuerURL       string
+	ClientID        string
+	ClientSecret    string
+	RedirectURL     string
+	ClientCompanyID *int64          // Client company new users are provisioned for
+	DefaultRole     models.UserRole // Role of new users
+	AllowedDomains  string          // Comma-separated email domains allowed to sign in, empty for any
+	// AllowEmailLinking lets the first login link an existing user with the same email, for
+	// providers trusted to verify the emails of their users
+	AllowEmailLinking bool
+}
+
+// OIDCProvider signs users in with an OpenID Connect provider such as Okta, Azure AD or Keycloak.
+// The endpoints are read from the provider's discovery document when it is first used.
+type OIDCProvider struct {
+	OIDCProviderSettings
+	HTTPClient *http.Client // Client for discovery, token and userinfo requests; nil for a default
+
+	mu       sync.Mutex
+	metadata *oidcMetadata
+}
+
+// oidcMetadata is the part of the discovery document the login needs
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// OIDCLoginRequest holds the random values that tie a callback to the login that started it
+type OIDCLoginRequest struct {
+	State    string
+	Nonce    string
+	Verifier string // PKCE code verifier
+}
+
+// OIDCClaims are the claims about the user from the ID token and the userinfo endpoint
+type OIDCClaims struct {
+	Issuer          string          `json:"iss"`
+	Subject         string          `json:"sub"`
+	Audience        oidcAudience    `json:"aud"`
+	AuthorizedParty string          `json:"azp"`
+	ExpiresAt       int64           `json:"exp"`
+	Nonce           string          `json:"nonce"`
+	Email           string          `json:"email"`
+	EmailVerified   json.RawMessage `json:"email_verified"`
+	Name            string          `json:"name"`
+}
+
+// oidcAudience is the aud claim, which is a string or an array of strings
+type oidcAudience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *oidcAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = oidcAudience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid aud claim: %w", err)
+	}
+	*a = list
+	return nil
+}
+
+// NewOIDCProvider creates a provider; nothing is requested from it until the first login
+func NewOIDCProvider(settings OIDCProviderSettings) *OIDCProvider {
+	return &OIDCProvider{OIDCProviderSettings: settings}
+}
+
+// NewOIDCLoginRequest generates the state, nonce and PKCE verifier of a new login
+func NewOIDCLoginRequest() (*OIDCLoginRequest, error) {
+	state, err := GenerateOAuthState()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := GenerateOAuthState()
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCLoginRequest{State: state, Nonce: nonce, Verifier: oauth2.GenerateVerifier()}, nil
+}
+
+// AuthCodeURL returns the URL of the provider's login page for the request
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, req *OIDCLoginRequest) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2Config(metadata).AuthCodeURL(req.State,
+		oauth2.S256ChallengeOption(req.Verifier),
+		oauth2.SetAuthURLParam("nonce", req.Nonce),
+	), nil
+}
+
+// Exchange redeems the authorization code of a callback and returns the verified claims about the user.
+// The ID token comes straight from the token endpoint over TLS, in exchange for the client secret and
+// PKCE verifier, so its claims are checked but not its signature (OpenID Connect Core 3.1.3.7).
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, req *OIDCLoginRequest) (*OIDCClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient())
+	token, err := p.oauth2Config(metadata).Exchange(ctx, code, oauth2.VerifierOption(req.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	claims, err := parseIDTokenClaims(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := claims.validate(metadata.Issuer, p.ClientID, req.Nonce, time.Now()); err != nil {
+		return nil, err
+	}
+
+	// Some providers only put the email in the userinfo response
+	if claims.Email == "" && metadata.UserinfoEndpoint != "" {
+		if err := p.addUserInfo(ctx, metadata.UserinfoEndpoint, token, claims); err != nil {
+			return nil, err
+		}
+	}
+	if claims.Email == "" {
+		return nil, errors.New("provider did not return an email address")
+	}
+	return claims, nil
+}
+
+// AllowsEmail reports whether the email's domain may sign in with the provider
+func (p *OIDCProvider) AllowsEmail(email string) bool {
+	return ValidateDomain(email, p.AllowedDomains)
+}
+
+// discover reads and caches the provider's discovery document
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	issuer := strings.TrimSuffix(p.IssuerURL, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery request: %w", err)
+	}
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get discovery document of %s: %w", p.ID, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get discovery document of %s: status %d", p.ID, resp.StatusCode)
+	}
+
+	var metadata oidcMetadata
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode discovery document of %s: %w", p.ID, err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %q", p.ID, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, fmt.Errorf("discovery document of %s has no authorization or token endpoint", p.ID)
+	}
+
+	p.metadata = &metadata
+	return p.metadata, nil
+}
+
+// oauth2Config returns the OAuth2 client of the provider
+func (p *OIDCProvider) oauth2Config(metadata *oidcMetadata) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		RedirectURL:  p.RedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  metadata.AuthorizationEndpoint,
+			TokenURL: metadata.TokenEndpoint,
+		},
+	}
+}
+
+func (p *OIDCProvider) httpClient() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// addUserInfo fills in the email of the claims from the userinfo endpoint
+func (p *OIDCProvider) addUserInfo(ctx context.Context, endpoint string, token *oauth2.Token, claims *OIDCClaims) error {
+	client := oauth2.NewClient(ctx, oauth2.StaticTokenSource(token))
+	resp, err := client.Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("failed to get user info: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get user info: status %d", resp.StatusCode)
+	}
+
+	var userInfo OIDCClaims
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&userInfo); err != nil {
+		return fmt.Errorf("failed to decode user info: %w", err)
+	}
+	// The userinfo response must be about the user of the ID token
+	if userInfo.Subject != claims.Subject {
+		return errors.New("user info is for a different subject")
+	}
+
+	claims.Email = userInfo.Email
+	claims.EmailVerified = userInfo.EmailVerified
+	if claims.Name == "" {
+		claims.Name = userInfo.Name
+	}
+	return nil
+}
+
+// parseIDTokenClaims decodes the payload of a JWT ID token
+func parseIDTokenClaims(rawIDToken string) (*OIDCClaims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("malformed id_token payload: %w", err)
+	}
+
+	var claims OIDCClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed id_token claims: %w", err)
+	}
+	return &claims, nil
+}
+
+// validate checks that the ID token was issued by the provider, for this client and this login
+func (c *OIDCClaims) validate(issuer, clientID, nonce string, now time.Time) error {
+	if c.Issuer != issuer {
+		return fmt.Errorf("id_token issuer %q does not match %q", c.Issuer, issuer)
+	}
+	if c.Subject == "" {
+		return errors.New("id_token has no subject")
+	}
+
+	audienceOK := false
+	for _, aud := range c.Audience {
+		if aud == clientID {
+			audienceOK = true
+		}
+	}
+	if !audienceOK {
+		return errors.New("id_token is not for this client")
+	}
+	if len(c.Audience) > 1 && c.AuthorizedParty != clientID {
+		return errors.New("id_token was issued to another party")
+	}
+
+	if now.After(time.Unix(c.ExpiresAt, 0).Add(oidcClockSkew)) {
+		return errors.New("id_token has expired")
+	}
+	if c.Nonce == "" || c.Nonce != nonce {
+		return errors.New("id_token nonce does not match the login")
+	}
+	return nil
+}
+
+// EmailUnverified reports whether the provider says the email is not verified.
+// Providers that leave out email_verified (like Azure AD) vouch for the email themselves.
+func (c *OIDCClaims) EmailUnverified() bool {
+	value := strings.Trim(strings.TrimSpace(string(c.EmailVerified)), `"`)
+	return value == "false"
+}
+
+// HasVerifiedEmail reports whether the provider explicitly says the email is verified
+func (c *OIDCClaims) HasVerifiedEmail() bool {
+	value := strings.Trim(strings.TrimSpace(string(c.EmailVerified)), `"`)
+	return value == "true"
+}
+
+// FindOrCreateOIDCUser finds the user linked to an OIDC identity, links an existing user of the
+// provider's client company with the same email (see isLinkableByEmail), or provisions a new user with the provider's
+// client company and default role. Returns whether the user was created.
+func FindOrCreateOIDCUser(ctx context.Context, db *sql.DB, provider *OIDCProvider, claims *OIDCClaims) (*models.User, bool, error) {
+	email := NormalizeEmail(claims.Email)
+	now := time.Now()
+
+	// Try to find the user linked to the identity
+	var user models.User
+	err := db.QueryRowContext(
+		ctx,
+		`SELECT u.id, u.email, u.password_hash, u.role, u.client_company_id, u.google_id, u.created_at, u.updated_at,
+			u.totp_enabled_at IS NOT NULL
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2`,
+		provider.ID, claims.Subject,
+	).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.ClientCompanyID,
+		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled,
+	)
+	if err == nil {
+		_, err := db.ExecContext(
+			ctx,
+			`UPDATE user_identities SET email = $3, last_login_at = $4 WHERE provider = $1 AND subject = $2`,
+			provider.ID, claims.Subject, email, now,
+		)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to update identity: %w", err)
+		}
+		return &user, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to query identity: %w", err)
+	}
+
+	// Not linked yet, try to find the user by email
+	err = db.QueryRowContext(
+		ctx,
+		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at,
+			totp_enabled_at IS NOT NULL
+		FROM users
+		WHERE LOWER(email) = $1`,
+		email,
+	).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.ClientCompanyID,
+		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt, &user.TwoFactorEnabled,
+	)
+	if err == nil {
+		linkable, err := isLinkableByEmail(db, provider, claims, &user)
+		if err != nil {
+			return nil, false, err
+		}
+		if !linkable {
+			return nil, false, ErrOIDCAccountConflict
+		}
+		if err := linkOIDCIdentity(ctx, db, user.ID, provider.ID, claims.Subject, email, now); err != nil {
+			return nil, false, err
+		}
+		return &user, false, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("failed to query user by email: %w", err)
+	}
+
+	// User doesn't exist, provision one
+	user = models.User{
+		Email:        email,
+		PasswordHash: models.ExternalLoginPasswordHash,
+		Role:         provider.DefaultRole,
+	}
+	if user.Role == models.RoleClient {
+		user.ClientCompanyID = provider.ClientCompanyID
+	}
+	user.BeforeCreate()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO users (email, password_hash, role, client_company_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`,
+		user.Email, user.PasswordHash, user.Role, user.ClientCompanyID, user.CreatedAt, user.UpdatedAt,
+	).Scan(&user.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`,
+		user.ID, provider.ID, claims.Subject, email, now,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	// Project managers see the provider's company through an assignment
+	if user.Role == models.RoleProjectManager && provider.ClientCompanyID != nil {
+		if err := models.ReplaceAssignedCompanies(tx, user.ID, []int64{*provider.ClientCompanyID}); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit user: %w", err)
+	}
+
+	return &user, true, nil
+}
+
+// isLinkableByEmail reports whether the first login with a provider may link an existing user with
+// the same email. The provider must opt in and vouch for the email, staff accounts are never linked,
+// and the user must belong to the provider's client company, so that a client's provider cannot
+// sign in as a user of another company.
+func isLinkableByEmail(db *sql.DB, provider *OIDCProvider, claims *OIDCClaims, user *models.User) (bool, error) {
+	if !provider.AllowEmailLinking || !claims.HasVerifiedEmail() {
+		return false, nil
+	}
+	if user.Role == models.RoleLogistics || user.Role == models.RoleWarehouse {
+		return false, nil
+	}
+	return isProviderCompanyMember(db, provider, user)
+}
+
+// isProviderCompanyMember reports whether an existing user belongs to the provider's client company
+func isProviderCompanyMember(db *sql.DB, provider *OIDCProvider, user *models.User) (bool, error) {
+	if provider.ClientCompanyID == nil {
+		return false, nil
+	}
+	if user.ClientCompanyID != nil && *user.ClientCompanyID == *provider.ClientCompanyID {
+		return true, nil
+	}
+	if user.Role != models.RoleProjectManager {
+		return false, nil
+	}
+
+	ids, err := models.GetAssignedCompanyIDs(db, user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == *provider.ClientCompanyID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// linkOIDCIdentity links an identity at a provider to an existing user
+func linkOIDCIdentity(ctx context.Context, db *sql.DB, userID int64, provider, subject, email string, now time.Time) error {
+	_, err := db.ExecContext(
+		ctx,
+		`INSERT INTO user_identities (user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`,
+		userID, provider, subject, email, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
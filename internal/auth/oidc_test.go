@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// fakeIDToken builds an unsigned JWT with the claims
+func fakeIDToken(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("Failed to encode claims: %v", err)
+	}
+	return "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+}
+
+// newFakeOIDCServer serves discovery, token and userinfo endpoints; the token endpoint
+// returns an ID token with the claims and checks the PKCE verifier is sent
+func newFakeOIDCServer(t *testing.T, claims func(issuer string) map[string]interface{}, userInfo map[string]interface{}) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"userinfo_endpoint":      server.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("code_verifier") == "" {
+			http.Error(w, "missing code_verifier", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     fakeIDToken(t, claims(server.URL)),
+		})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(userInfo)
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOIDCProvider_AuthCodeURL(t *testing.T) {
+	server := newFakeOIDCServer(t, nil, nil)
+	provider := NewOIDCProvider(OIDCProviderSettings{
+		ID:          "acme",
+		IssuerURL:   server.URL + "/",
+		ClientID:    "client-1",
+		RedirectURL: "https://app.example.com/auth/oidc/acme/callback",
+	})
+
+	req, err := NewOIDCLoginRequest()
+	if err != nil {
+		t.Fatalf("NewOIDCLoginRequest failed: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(context.Background(), req)
+	if err != nil {
+		t.Fatalf("AuthCodeURL failed: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("Invalid auth URL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, server.URL+"/authorize?") {
+		t.Errorf("Expected the discovered authorization endpoint, got %s", authURL)
+	}
+	query := parsed.Query()
+	if query.Get("state") != req.State || query.Get("nonce") != req.Nonce {
+		t.Errorf("Expected state and nonce of the request, got %s", authURL)
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Errorf("Expected a PKCE challenge, got %s", authURL)
+	}
+	if !strings.Contains(query.Get("scope"), "openid") {
+		t.Errorf("Expected the openid scope, got %q", query.Get("scope"))
+	}
+}
+
+func TestOIDCProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	server := newFakeOIDCServer(t, nil, nil)
+	provider := NewOIDCProvider(OIDCProviderSettings{
+		ID:        "acme",
+		IssuerURL: server.URL + "/tenant",
+		ClientID:  "client-1",
+	})
+
+	req := &OIDCLoginRequest{State: "s", Nonce: "n", Verifier: "v"}
+	if _, err := provider.AuthCodeURL(context.Background(), req); err == nil {
+		t.Error("Expected an error when the discovery document is missing or for another issuer")
+	}
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	req := &OIDCLoginRequest{State: "state", Nonce: "nonce-1", Verifier: "verifier-verifier-verifier-verifier-verifier"}
+
+	t.Run("ID token claims", func(t *testing.T) {
+		server := newFakeOIDCServer(t, func(issuer string) map[string]interface{} {
+			return map[string]interface{}{
+				"iss":            issuer,
+				"sub":            "user-123",
+				"aud":            "client-1",
+				"exp":            time.Now().Add(time.Hour).Unix(),
+				"nonce":          "nonce-1",
+				"email":          "Jane@Acme.com",
+				"email_verified": true,
+			}
+		}, nil)
+		provider := NewOIDCProvider(OIDCProviderSettings{ID: "acme", IssuerURL: server.URL, ClientID: "client-1"})
+
+		claims, err := provider.Exchange(context.Background(), "code", req)
+		if err != nil {
+			t.Fatalf("Exchange failed: %v", err)
+		}
+		if claims.Subject != "user-123" || claims.Email != "Jane@Acme.com" {
+			t.Errorf("Unexpected claims: %+v", claims)
+		}
+		if claims.EmailUnverified() {
+			t.Error("Expected the email to be verified")
+		}
+	})
+
+	t.Run("email from userinfo", func(t *testing.T) {
+		server := newFakeOIDCServer(t, func(issuer string) map[string]interface{} {
+			return map[string]interface{}{
+				"iss":   issuer,
+				"sub":   "user-123",
+				"aud":   []string{"client-1"},
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"nonce": "nonce-1",
+			}
+		}, map[string]interface{}{"sub": "user-123", "email": "jane@acme.com", "email_verified": "false"})
+		provider := NewOIDCProvider(OIDCProviderSettings{ID: "acme", IssuerURL: server.URL, ClientID: "client-1"})
+
+		claims, err := provider.Exchange(context.Background(), "code", req)
+		if err != nil {
+			t.Fatalf("Exchange failed: %v", err)
+		}
+		if claims.Email != "jane@acme.com" {
+			t.Errorf("Expected email from userinfo, got %q", claims.Email)
+		}
+		if !claims.EmailUnverified() {
+			t.Error("Expected the email to be unverified")
+		}
+	})
+
+	t.Run("wrong nonce", func(t *testing.T) {
+		server := newFakeOIDCServer(t, func(issuer string) map[string]interface{} {
+			return map[string]interface{}{
+				"iss":   issuer,
+				"sub":   "user-123",
+				"aud":   "client-1",
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"nonce": "another-login",
+				"email": "jane@acme.com",
+			}
+		}, nil)
+		provider := NewOIDCProvider(OIDCProviderSettings{ID: "acme", IssuerURL: server.URL, ClientID: "client-1"})
+
+		if _, err := provider.Exchange(context.Background(), "code", req); err == nil {
+			t.Error("Expected an error for an ID token of another login")
+		}
+	})
+}
+
+func TestOIDCClaims_Validate(t *testing.T) {
+	now := time.Now()
+	valid := func() *OIDCClaims {
+		return &OIDCClaims{
+			Issuer:    "https://idp.example.com",
+			Subject:   "user-123",
+			Audience:  oidcAudience{"client-1"},
+			ExpiresAt: now.Add(time.Hour).Unix(),
+			Nonce:     "nonce-1",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *OIDCClaims)
+		wantErr bool
+	}{
+		{"valid", func(c *OIDCClaims) {}, false},
+		{"other issuer", func(c *OIDCClaims) { c.Issuer = "https://evil.example.com" }, true},
+		{"no subject", func(c *OIDCClaims) { c.Subject = "" }, true},
+		{"other audience", func(c *OIDCClaims) { c.Audience = oidcAudience{"client-2"} }, true},
+		{"several audiences without azp", func(c *OIDCClaims) { c.Audience = oidcAudience{"client-1", "client-2"} }, true},
+		{"several audiences with azp", func(c *OIDCClaims) {
+			c.Audience = oidcAudience{"client-1", "client-2"}
+			c.AuthorizedParty = "client-1"
+		}, false},
+		{"expired", func(c *OIDCClaims) { c.ExpiresAt = now.Add(-2 * oidcClockSkew).Unix() }, true},
+		{"expired within clock skew", func(c *OIDCClaims) { c.ExpiresAt = now.Add(-oidcClockSkew / 2).Unix() }, false},
+		{"missing nonce", func(c *OIDCClaims) { c.Nonce = "" }, true},
+		{"other nonce", func(c *OIDCClaims) { c.Nonce = "nonce-2" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.modify(claims)
+			err := claims.validate("https://idp.example.com", "client-1", "nonce-1", now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	tests := []struct {
+		email   string
+		allowed string
+		want    bool
+	}{
+		{"jane@acme.com", "", true},
+		{"jane@acme.com", "acme.com", true},
+		{"jane@ACME.com", "acme.com", true},
+		{"jane@acme.io", "acme.com, acme.io", true},
+		{"jane@evil.com", "acme.com,acme.io", false},
+		{"jane@sub.acme.com", "acme.com", false},
+		{"jane@acme.com.evil.com", "acme.com", false},
+		{"not-an-email", "acme.com", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidateDomain(tt.email, tt.allowed); got != tt.want {
+			t.Errorf("ValidateDomain(%q, %q) = %v, want %v", tt.email, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestIsLinkableByEmail(t *testing.T) {
+	companyID := int64(7)
+	verified := &OIDCClaims{EmailVerified: json.RawMessage(`true`)}
+	omitted := &OIDCClaims{}
+	client := &models.User{ID: 1, Role: models.RoleClient, ClientCompanyID: &companyID}
+
+	newProvider := func(allowLinking bool, companyID *int64) *OIDCProvider {
+		return NewOIDCProvider(OIDCProviderSettings{ID: "acme", ClientCompanyID: companyID, AllowEmailLinking: allowLinking})
+	}
+
+	tests := []struct {
+		name     string
+		provider *OIDCProvider
+		claims   *OIDCClaims
+		user     *models.User
+		want     bool
+	}{
+		{"provider did not opt in", newProvider(false, &companyID), verified, client, false},
+		{"email_verified left out", newProvider(true, &companyID), omitted, client, false},
+		{"logistics account", newProvider(true, &companyID), verified, &models.User{ID: 2, Role: models.RoleLogistics}, false},
+		{"warehouse account", newProvider(true, &companyID), verified, &models.User{ID: 3, Role: models.RoleWarehouse}, false},
+		{"provider without a company", newProvider(true, nil), verified, &models.User{ID: 4, Role: models.RoleClient}, false},
+		{"client of the provider's company", newProvider(true, &companyID), verified, client, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// None of these cases needs to look up assigned companies
+			got, err := isLinkableByEmail(nil, tt.provider, tt.claims, tt.user)
+			if err != nil {
+				t.Fatalf("isLinkableByEmail() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isLinkableByEmail() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -16,6 +18,11 @@ const (
 	DefaultSessionDuration = 24
 	// SessionTokenLength is the length of the session token in bytes
 	SessionTokenLength = 32
+	// SessionLastSeenInterval is how often the last-seen time of a session is written,
+	// so that not every request updates the sessions table
+	SessionLastSeenInterval = time.Minute
+	// maxSessionUserAgentLength caps the stored user agent, which the client controls
+	maxSessionUserAgentLength = 512
 )
 
 // GenerateSessionToken generates a cryptographically secure random token
@@ -27,8 +34,21 @@ func GenerateSessionToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
+// hashToken returns the hex SHA-256 a session, magic link or two-factor challenge token is stored as.
+// The tokens are long random values, so the database never needs to hold the bearer token itself.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 // CreateSession creates a new session for the user
 func CreateSession(ctx context.Context, db *sql.DB, userID int64, durationHours int) (*models.Session, error) {
+	return CreateSessionForClient(ctx, db, userID, durationHours, "", "")
+}
+
+// CreateSessionForClient creates a new session for the user and records the IP address and
+// user agent it was signed in from, which the user sees in their list of sessions
+func CreateSessionForClient(ctx context.Context, db *sql.DB, userID int64, durationHours int, ipAddress, userAgent string) (*models.Session, error) {
 	// Verify user exists
 	var exists bool
 	err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&exists)
@@ -45,10 +65,16 @@ func CreateSession(ctx context.Context, db *sql.DB, userID int64, durationHours
 		return nil, err
 	}
 
+	if len(userAgent) > maxSessionUserAgentLength {
+		userAgent = userAgent[:maxSessionUserAgentLength]
+	}
+
 	// Create session
 	session := &models.Session{
 		UserID:    userID,
 		Token:     token,
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
 		ExpiresAt: time.Now().Add(time.Duration(durationHours) * time.Hour),
 	}
 	session.BeforeCreate()
@@ -56,10 +82,11 @@ func CreateSession(ctx context.Context, db *sql.DB, userID int64, durationHours
 	// Insert session into database
 	err = db.QueryRowContext(
 		ctx,
-		`INSERT INTO sessions (user_id, token, expires_at, created_at)
-		VALUES ($1, $2, $3, $4)
+		`INSERT INTO sessions (user_id, token_hash, ip_address, user_agent, last_seen_at, expires_at, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5, $6, $7)
 		RETURNING id`,
-		session.UserID, session.Token, session.ExpiresAt, session.CreatedAt,
+		session.UserID, hashToken(session.Token), session.IPAddress, session.UserAgent,
+		session.LastSeenAt, session.ExpiresAt, session.CreatedAt,
 	).Scan(&session.ID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
@@ -75,7 +102,7 @@ func ValidateSession(ctx context.Context, db *sql.DB, token string) (*models.Ses
 		return nil, nil
 	}
 
-	session := &models.Session{}
+	session := &models.Session{Token: token}
 	user := &models.User{}
 
 	// Query session with user join and company name (for client users)
@@ -83,16 +110,16 @@ func ValidateSession(ctx context.Context, db *sql.DB, token string) (*models.Ses
 	err := db.QueryRowContext(
 		ctx,
 		`SELECT 
-			s.id, s.user_id, s.token, s.expires_at, s.created_at,
+			s.id, s.user_id, COALESCE(s.ip_address, ''), COALESCE(s.user_agent, ''), s.last_seen_at, s.expires_at, s.created_at,
 			u.id, u.email, u.password_hash, u.role, u.client_company_id, u.google_id, u.created_at, u.updated_at,
 			cc.name as client_company_name
 		FROM sessions s
 		INNER JOIN users u ON s.user_id = u.id
 		LEFT JOIN client_companies cc ON cc.id = u.client_company_id
-		WHERE s.token = $1`,
-		token,
+		WHERE s.token_hash = $1`,
+		hashToken(token),
 	).Scan(
-		&session.ID, &session.UserID, &session.Token, &session.ExpiresAt, &session.CreatedAt,
+		&session.ID, &session.UserID, &session.IPAddress, &session.UserAgent, &session.LastSeenAt, &session.ExpiresAt, &session.CreatedAt,
 		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.ClientCompanyID, &user.GoogleID, &user.CreatedAt, &user.UpdatedAt,
 		&companyName,
 	)
@@ -111,18 +138,31 @@ func ValidateSession(ctx context.Context, db *sql.DB, token string) (*models.Ses
 		return nil, nil
 	}
 
+	// Record activity, at most once per SessionLastSeenInterval
+	if now := time.Now(); now.Sub(session.LastSeenAt) >= SessionLastSeenInterval {
+		if _, err := db.ExecContext(ctx, "UPDATE sessions SET last_seen_at = $1 WHERE id = $2", now, session.ID); err != nil {
+			return nil, fmt.Errorf("failed to update session activity: %w", err)
+		}
+		session.LastSeenAt = now
+	}
+
 	// Set company name if available
 	if companyName.Valid {
 		user.ClientCompanyName = companyName.String
 	}
 
+	// Project managers only see the client companies assigned to them
+	if err := models.LoadAssignedCompanies(db, user); err != nil {
+		return nil, fmt.Errorf("failed to load assigned companies: %w", err)
+	}
+
 	session.User = user
 	return session, nil
 }
 
 // DeleteSession deletes a session by token
 func DeleteSession(ctx context.Context, db *sql.DB, token string) error {
-	_, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE token = $1", token)
+	_, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = $1", hashToken(token))
 	if err != nil {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
@@ -138,6 +178,73 @@ func DeleteUserSessions(ctx context.Context, db *sql.DB, userID int64) error {
 	return nil
 }
 
+// DeleteOtherUserSessions deletes all sessions of a user except one, so a user who changes
+// their account can stay signed in on the device they used for it
+func DeleteOtherUserSessions(ctx context.Context, db *sql.DB, userID, keepSessionID int64) (int, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE user_id = $1 AND id <> $2", userID, keepSessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return int(rowsAffected), nil
+}
+
+// RevokeUserSession deletes one session of a user by ID.
+// Returns false if the session does not exist or belongs to another user.
+func RevokeUserSession(ctx context.Context, db *sql.DB, userID, sessionID int64) (bool, error) {
+	result, err := db.ExecContext(ctx, "DELETE FROM sessions WHERE id = $1 AND user_id = $2", sessionID, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+// GetUserSessions returns the unexpired sessions of a user, most recently used first
+func GetUserSessions(ctx context.Context, db *sql.DB, userID int64) ([]*models.Session, error) {
+	rows, err := db.QueryContext(
+		ctx,
+		`SELECT id, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''), last_seen_at, expires_at, created_at
+		FROM sessions
+		WHERE user_id = $1 AND expires_at > $2
+		ORDER BY last_seen_at DESC`,
+		userID, time.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session := &models.Session{}
+		err := rows.Scan(
+			&session.ID, &session.UserID, &session.IPAddress, &session.UserAgent,
+			&session.LastSeenAt, &session.ExpiresAt, &session.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
 // CleanupExpiredSessions removes all expired sessions from the database
 // Returns the number of sessions deleted
 func CleanupExpiredSessions(ctx context.Context, db *sql.DB) (int, error) {
@@ -164,8 +271,8 @@ func ExtendSession(ctx context.Context, db *sql.DB, token string, durationHours
 	
 	result, err := db.ExecContext(
 		ctx,
-		"UPDATE sessions SET expires_at = $1 WHERE token = $2",
-		newExpiresAt, token,
+		"UPDATE sessions SET expires_at = $1 WHERE token_hash = $2",
+		newExpiresAt, hashToken(token),
 	)
 	if err != nil {
 		return fmt.Errorf("failed to extend session: %w", err)
@@ -121,6 +121,18 @@ func TestCreateSession(t *testing.T) {
 				t.Error("CreateSession() returned session with empty token")
 			}
 
+			// Only the hash of the token is stored
+			var storedHash string
+			err = db.QueryRowContext(context.Background(),
+				`SELECT token_hash FROM sessions WHERE id = $1`, session.ID,
+			).Scan(&storedHash)
+			if err != nil {
+				t.Fatalf("Failed to read stored session: %v", err)
+			}
+			if storedHash != hashToken(session.Token) {
+				t.Errorf("stored token_hash = %q, want the SHA-256 of the token", storedHash)
+			}
+
 			if tt.checkExpiration {
 				expectedExpiry := time.Now().Add(time.Duration(tt.durationHours) * time.Hour)
 				timeDiff := session.ExpiresAt.Sub(expectedExpiry).Abs()
@@ -180,9 +192,9 @@ func TestValidateSession(t *testing.T) {
 
 	err = db.QueryRowContext(
 		context.Background(),
-		`INSERT INTO sessions (user_id, token, expires_at, created_at)
+		`INSERT INTO sessions (user_id, token_hash, expires_at, created_at)
 		VALUES ($1, $2, $3, $4) RETURNING id`,
-		expiredSession.UserID, expiredSession.Token, expiredSession.ExpiresAt, expiredSession.CreatedAt,
+		expiredSession.UserID, hashToken(expiredSession.Token), expiredSession.ExpiresAt, expiredSession.CreatedAt,
 	).Scan(&expiredSession.ID)
 	if err != nil {
 		t.Fatalf("Failed to create expired session: %v", err)
@@ -323,9 +335,9 @@ func TestCleanupExpiredSessions(t *testing.T) {
 
 		err = db.QueryRowContext(
 			context.Background(),
-			`INSERT INTO sessions (user_id, token, expires_at, created_at)
+			`INSERT INTO sessions (user_id, token_hash, expires_at, created_at)
 			VALUES ($1, $2, $3, $4) RETURNING id`,
-			session.UserID, session.Token, session.ExpiresAt, session.CreatedAt,
+			session.UserID, hashToken(session.Token), session.ExpiresAt, session.CreatedAt,
 		).Scan(&session.ID)
 		if err != nil {
 			t.Fatalf("Failed to create test session: %v", err)
@@ -355,8 +367,8 @@ func TestCleanupExpiredSessions(t *testing.T) {
 		var count int
 		err := db.QueryRowContext(
 			context.Background(),
-			`SELECT COUNT(*) FROM sessions WHERE token = $1`,
-			s.token,
+			`SELECT COUNT(*) FROM sessions WHERE token_hash = $1`,
+			hashToken(s.token),
 		).Scan(&count)
 		if err != nil {
 			t.Fatalf("Failed to check session existence: %v", err)
@@ -415,8 +427,8 @@ func TestDeleteSession(t *testing.T) {
 	var count int
 	err = db.QueryRowContext(
 		context.Background(),
-		`SELECT COUNT(*) FROM sessions WHERE token = $1`,
-		session.Token,
+		`SELECT COUNT(*) FROM sessions WHERE token_hash = $1`,
+		hashToken(session.Token),
 	).Scan(&count)
 	if err != nil {
 		t.Fatalf("Failed to check session deletion: %v", err)
@@ -433,3 +445,98 @@ func TestDeleteSession(t *testing.T) {
 	}
 }
 
+
+func TestUserSessionManagement(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	// Setup test database
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	// Create two test users
+	var userID, otherUserID int64
+	for _, u := range []struct {
+		email string
+		id    *int64
+	}{
+		{"sessions@test.com", &userID},
+		{"other-sessions@test.com", &otherUserID},
+	} {
+		err := db.QueryRowContext(ctx,
+			`INSERT INTO users (email, password_hash, role, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $4) RETURNING id`,
+			u.email, "hashedpassword123", models.RoleLogistics, time.Now(),
+		).Scan(u.id)
+		if err != nil {
+			t.Fatalf("Failed to create test user: %v", err)
+		}
+	}
+
+	laptop, err := CreateSessionForClient(ctx, db, userID, 24, "203.0.113.7", "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0")
+	if err != nil {
+		t.Fatalf("CreateSessionForClient() failed: %v", err)
+	}
+	phone, err := CreateSessionForClient(ctx, db, userID, 24, "198.51.100.4", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X)")
+	if err != nil {
+		t.Fatalf("CreateSessionForClient() failed: %v", err)
+	}
+	otherSession, err := CreateSession(ctx, db, otherUserID, 24)
+	if err != nil {
+		t.Fatalf("CreateSession() failed: %v", err)
+	}
+
+	sessions, err := GetUserSessions(ctx, db, userID)
+	if err != nil {
+		t.Fatalf("GetUserSessions() failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("GetUserSessions() returned %d sessions, want 2", len(sessions))
+	}
+	for _, s := range sessions {
+		if s.ID == laptop.ID && (s.IPAddress != "203.0.113.7" || s.Device() != "Firefox on Linux") {
+			t.Errorf("session client not recorded: ip %q, device %q", s.IPAddress, s.Device())
+		}
+	}
+
+	// A user cannot revoke another user's session
+	revoked, err := RevokeUserSession(ctx, db, userID, otherSession.ID)
+	if err != nil {
+		t.Fatalf("RevokeUserSession() failed: %v", err)
+	}
+	if revoked {
+		t.Error("RevokeUserSession() revoked a session of another user")
+	}
+
+	revoked, err = RevokeUserSession(ctx, db, userID, phone.ID)
+	if err != nil {
+		t.Fatalf("RevokeUserSession() failed: %v", err)
+	}
+	if !revoked {
+		t.Error("RevokeUserSession() did not revoke the session")
+	}
+	if s, _ := ValidateSession(ctx, db, phone.Token); s != nil {
+		t.Error("revoked session is still valid")
+	}
+
+	// Signing out other sessions keeps the current one
+	if _, err := CreateSession(ctx, db, userID, 24); err != nil {
+		t.Fatalf("CreateSession() failed: %v", err)
+	}
+	count, err := DeleteOtherUserSessions(ctx, db, userID, laptop.ID)
+	if err != nil {
+		t.Fatalf("DeleteOtherUserSessions() failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("DeleteOtherUserSessions() deleted %d sessions, want 1", count)
+	}
+	if s, _ := ValidateSession(ctx, db, laptop.Token); s == nil {
+		t.Error("DeleteOtherUserSessions() deleted the session to keep")
+	}
+	if s, _ := ValidateSession(ctx, db, otherSession.Token); s == nil {
+		t.Error("DeleteOtherUserSessions() deleted a session of another user")
+	}
+}
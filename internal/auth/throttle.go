@@ -0,0 +1,207 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Login methods recorded in login_attempts
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodMagicLink = "magic_link"
+	LoginMethodOIDC      = "oidc"
+)
+
+// Reasons a login attempt failed
+const (
+	LoginFailureUnknownEmail    = "unknown_email"
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureInvalidToken    = "invalid_token"
+	LoginFailureLocked          = "locked"
+	LoginFailureThrottled       = "throttled"
+	LoginFailureInvalidTOTP     = "invalid_two_factor_code"
+	LoginFailureProvider        = "provider_error"
+	LoginFailureDomain          = "domain_not_allowed"
+	LoginFailureAccountConflict = "account_conflict"
+)
+
+// LoginLimits configures brute-force protection of the login endpoints.
+// The state is kept in Postgres, so the limits apply across all app instances.
+type LoginLimits struct {
+	// Window is how far back failed attempts are counted
+	Window time.Duration
+	// MaxFailuresPerIP is the number of failures from an IP address within Window before it is throttled
+	MaxFailuresPerIP int
+	// MaxFailuresPerAccount is the number of failures for an email within Window before it is throttled
+	MaxFailuresPerAccount int
+	// LockoutThreshold is the number of consecutive wrong passwords that locks an account
+	LockoutThreshold int
+	// LockoutDuration is how long a locked account refuses password logins
+	LockoutDuration time.Duration
+}
+
+// DefaultLoginLimits are the limits used unless configured otherwise
+var DefaultLoginLimits = LoginLimits{
+	Window:                15 * time.Minute,
+	MaxFailuresPerIP:      20,
+	MaxFailuresPerAccount: 10,
+	LockoutThreshold:      5,
+	LockoutDuration:       30 * time.Minute,
+}
+
+// LoginAttempt is a password, magic link or OpenID Connect login to record
+type LoginAttempt struct {
+	Method    string
+	Email     string
+	UserID    *int64
+	IPAddress string
+	UserAgent string
+	Success   bool
+	Reason    string
+}
+
+// NormalizeEmail returns the email as failures are counted for it
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ClientIP returns the IP address of the client.
+// X-Forwarded-For is only used when the app runs behind a trusted reverse proxy.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			if ip := strings.TrimSpace(first); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RecordLoginAttempt stores a login attempt
+func RecordLoginAttempt(ctx context.Context, db *sql.DB, attempt LoginAttempt) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO login_attempts (method, email, user_id, ip_address, user_agent, success, reason, attempted_at)
+		VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''), $6, NULLIF($7, ''), $8)`,
+		attempt.Method, NormalizeEmail(attempt.Email), attempt.UserID, attempt.IPAddress,
+		attempt.UserAgent, attempt.Success, attempt.Reason, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
+
+// CheckLoginThrottle reports whether logins from the IP address or for the email are throttled.
+// It returns how long to wait before trying again, or zero if the attempt may go ahead.
+// An empty email only checks the IP address (magic links).
+func CheckLoginThrottle(ctx context.Context, db *sql.DB, ip, email string, limits LoginLimits) (time.Duration, error) {
+	since := time.Now().Add(-limits.Window)
+
+	retry, err := throttledFor(ctx, db, "ip_address", ip, since, limits)
+	if err != nil || retry > 0 {
+		return retry, err
+	}
+	if email = NormalizeEmail(email); email == "" {
+		return 0, nil
+	}
+	return throttledFor(ctx, db, "email", email, since, limits)
+}
+
+// throttledFor counts the failures of an IP address or email since the start of the window.
+// Once over the limit, the wait lasts until the oldest counted failure leaves the window.
+func throttledFor(ctx context.Context, db *sql.DB, column, value string, since time.Time, limits LoginLimits) (time.Duration, error) {
+	limit := limits.MaxFailuresPerIP
+	if column == "email" {
+		limit = limits.MaxFailuresPerAccount
+	}
+	if limit <= 0 {
+		return 0, nil
+	}
+
+	var failures int
+	var oldest sql.NullTime
+	err := db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT COUNT(*), MIN(attempted_at) FROM login_attempts
+		WHERE %s = $1 AND success = FALSE AND reason <> $2 AND attempted_at > $3`, column),
+		value, LoginFailureThrottled, since,
+	).Scan(&failures, &oldest)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count failed logins: %w", err)
+	}
+	if failures < limit || !oldest.Valid {
+		return 0, nil
+	}
+
+	retry := time.Until(oldest.Time.Add(limits.Window))
+	if retry < time.Second {
+		retry = time.Second
+	}
+	return retry, nil
+}
+
+// RegisterFailedLogin counts a wrong password for the user and locks the account once the
+// threshold is reached. It returns when the account is locked until, or nil if it is not locked.
+func RegisterFailedLogin(ctx context.Context, db *sql.DB, userID int64, limits LoginLimits) (*time.Time, error) {
+	var lockedUntil sql.NullTime
+	err := db.QueryRowContext(ctx,
+		`UPDATE users SET
+			failed_login_count = failed_login_count + 1,
+			locked_until = CASE
+				WHEN $2 > 0 AND failed_login_count + 1 >= $2 THEN $3
+				ELSE locked_until
+			END
+		WHERE id = $1
+		RETURNING locked_until`,
+		userID, limits.LockoutThreshold, time.Now().Add(limits.LockoutDuration),
+	).Scan(&lockedUntil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register failed login: %w", err)
+	}
+	if !lockedUntil.Valid || !lockedUntil.Time.After(time.Now()) {
+		return nil, nil
+	}
+	return &lockedUntil.Time, nil
+}
+
+// ResetFailedLogins clears the failed password count and the lock of a user,
+// after a successful login or when an admin unlocks the account
+func ResetFailedLogins(ctx context.Context, db *sql.DB, userID int64) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE users SET failed_login_count = 0, locked_until = NULL WHERE id = $1`,
+		userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+	return nil
+}
+
+// CleanupLoginAttempts removes login attempts older than the given age
+// Returns the number of attempts deleted
+func CleanupLoginAttempts(ctx context.Context, db *sql.DB, olderThan time.Duration) (int, error) {
+	result, err := db.ExecContext(ctx,
+		"DELETE FROM login_attempts WHERE attempted_at < $1",
+		time.Now().Add(-olderThan),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup login attempts: %w", err)
+	}
+
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+
+	return int(count), nil
+}
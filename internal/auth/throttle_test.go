@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		trustProxy bool
+		want       string
+	}{
+		{
+			name:       "remote address",
+			remoteAddr: "203.0.113.7:52000",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "IPv6 remote address",
+			remoteAddr: "[2001:db8::1]:52000",
+			want:       "2001:db8::1",
+		},
+		{
+			name:       "forwarded header ignored without a trusted proxy",
+			remoteAddr: "10.0.0.2:52000",
+			forwarded:  "198.51.100.4",
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "first forwarded address behind a trusted proxy",
+			remoteAddr: "10.0.0.2:52000",
+			forwarded:  "198.51.100.4, 10.0.0.1",
+			trustProxy: true,
+			want:       "198.51.100.4",
+		},
+		{
+			name:       "trusted proxy without forwarded header",
+			remoteAddr: "10.0.0.2:52000",
+			trustProxy: true,
+			want:       "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/login", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := ClientIP(req, tt.trustProxy); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	if got := NormalizeEmail("  Admin@Example.COM "); got != "admin@example.com" {
+		t.Errorf("NormalizeEmail() = %q, want %q", got, "admin@example.com")
+	}
+}
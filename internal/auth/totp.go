@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// TOTPSecretLength is the length of a TOTP secret in bytes (160 bits, as recommended by RFC 4226)
+	TOTPSecretLength = 20
+	// TOTPDigits is the number of digits of a TOTP code
+	TOTPDigits = 6
+	// TOTPPeriod is how long a TOTP code is valid
+	TOTPPeriod = 30 * time.Second
+	// TOTPSkew is the number of periods before and after the current one that are accepted,
+	// to allow for clock drift between the server and the authenticator app
+	TOTPSkew = 1
+
+	// RecoveryCodeCount is the number of recovery codes generated at once
+	RecoveryCodeCount = 10
+)
+
+// totpEncoding is the unpadded base32 alphabet authenticator apps expect
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates a random base32-encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	bytes := make([]byte, TOTPSecretLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(bytes), nil
+}
+
+// TOTPCode returns the code of a secret for the period containing t (RFC 6238, HMAC-SHA1)
+func TOTPCode(secret string, t time.Time) (string, error) {
+	return totpCodeForStep(secret, totpStep(t))
+}
+
+// totpStep returns the number of the period containing t
+func totpStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+func totpCodeForStep(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// ValidateTOTP checks a code against the secret at time t, allowing TOTPSkew periods of drift.
+// It returns the period the code belongs to, so callers can refuse a code that was already used.
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := totpStep(t)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		expected, err := totpCodeForStep(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPURI returns the otpauth:// URI authenticator apps read from the enrollment QR code
+func TOTPURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	params.Set("period", fmt.Sprintf("%d", int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GenerateRecoveryCodes generates single-use recovery codes of the form "xxxxx-xxxxx"
+func GenerateRecoveryCodes() ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789" // No 0/o, 1/l/i to avoid typos
+	// Bytes at or above the largest multiple of the alphabet size are skipped so every character is equally likely
+	limit := byte(256 - 256%len(alphabet))
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		chars := make([]byte, 0, 10)
+		buf := make([]byte, 16)
+		for len(chars) < cap(chars) {
+			if _, err := rand.Read(buf); err != nil {
+				return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+			}
+			for _, b := range buf {
+				if b < limit && len(chars) < cap(chars) {
+					chars = append(chars, alphabet[int(b)%len(alphabet)])
+				}
+			}
+		}
+		codes[i] = string(chars[:5]) + "-" + string(chars[5:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the SHA-256 hash a recovery code is stored as.
+// Case, spaces and dashes are ignored so users can type the code loosely.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(code)
+	normalized = strings.NewReplacer("-", "", " ", "").Replace(normalized)
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
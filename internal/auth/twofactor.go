@@ -0,0 +1,327 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// TwoFactorChallengeDuration is how long a user has to enter the second factor after the password
+	TwoFactorChallengeDuration = 10 * time.Minute
+	// MaxTwoFactorAttempts is the number of wrong codes after which the login starts over
+	MaxTwoFactorAttempts = 5
+)
+
+// ErrTwoFactorEnabled is returned when enrolling a user who already has two-factor authentication
+var ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+// TwoFactorChallenge is a password login waiting for a TOTP or recovery code
+type TwoFactorChallenge struct {
+	ID             int64
+	UserID         int64
+	RedirectURL    string
+	FailedAttempts int
+	ExpiresAt      time.Time
+}
+
+// TwoFactorStatus describes the two-factor authentication of a user
+type TwoFactorStatus struct {
+	Enabled           bool
+	EnabledAt         *time.Time
+	RecoveryCodesLeft int
+}
+
+// CreateTwoFactorChallenge starts the second step of a login and returns the token identifying it
+func CreateTwoFactorChallenge(ctx context.Context, db *sql.DB, userID int64, redirectURL string) (string, error) {
+	token, err := GenerateSessionToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO two_factor_challenges (token_hash, user_id, redirect_url, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		hashToken(token), userID, redirectURL, time.Now().Add(TwoFactorChallengeDuration), time.Now(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create two-factor challenge: %w", err)
+	}
+	return token, nil
+}
+
+// GetTwoFactorChallenge returns the challenge of a token, or nil if it is unknown or expired
+func GetTwoFactorChallenge(ctx context.Context, db *sql.DB, token string) (*TwoFactorChallenge, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	challenge := &TwoFactorChallenge{}
+	err := db.QueryRowContext(ctx,
+		`SELECT id, user_id, redirect_url, failed_attempts, expires_at
+		FROM two_factor_challenges WHERE token_hash = $1`,
+		hashToken(token),
+	).Scan(&challenge.ID, &challenge.UserID, &challenge.RedirectURL, &challenge.FailedAttempts, &challenge.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor challenge: %w", err)
+	}
+
+	if time.Now().After(challenge.ExpiresAt) {
+		_ = DeleteTwoFactorChallenge(ctx, db, challenge.ID)
+		return nil, nil
+	}
+	return challenge, nil
+}
+
+// FailTwoFactorChallenge counts a wrong code. After MaxTwoFactorAttempts the challenge is deleted.
+// Returns the number of attempts left.
+func FailTwoFactorChallenge(ctx context.Context, db *sql.DB, id int64) (int, error) {
+	var attempts int
+	err := db.QueryRowContext(ctx,
+		`UPDATE two_factor_challenges SET failed_attempts = failed_attempts + 1 WHERE id = $1 RETURNING failed_attempts`,
+		id,
+	).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to count two-factor attempt: %w", err)
+	}
+
+	if attempts >= MaxTwoFactorAttempts {
+		return 0, DeleteTwoFactorChallenge(ctx, db, id)
+	}
+	return MaxTwoFactorAttempts - attempts, nil
+}
+
+// DeleteTwoFactorChallenge removes a challenge once the login completed or failed
+func DeleteTwoFactorChallenge(ctx context.Context, db *sql.DB, id int64) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM two_factor_challenges WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete two-factor challenge: %w", err)
+	}
+	return nil
+}
+
+// GetTwoFactorStatus returns whether the user has two-factor authentication and how many recovery codes are left
+func GetTwoFactorStatus(ctx context.Context, db *sql.DB, userID int64) (*TwoFactorStatus, error) {
+	status := &TwoFactorStatus{}
+	err := db.QueryRowContext(ctx,
+		`SELECT u.totp_enabled_at,
+			(SELECT COUNT(*) FROM user_recovery_codes rc WHERE rc.user_id = u.id AND rc.used_at IS NULL)
+		FROM users u WHERE u.id = $1`,
+		userID,
+	).Scan(&status.EnabledAt, &status.RecoveryCodesLeft)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get two-factor status: %w", err)
+	}
+	status.Enabled = status.EnabledAt != nil
+	return status, nil
+}
+
+// StartTOTPEnrollment returns the secret the user should add to an authenticator app.
+// The secret is stored as pending until ConfirmTOTPEnrollment; calling this again returns the same secret.
+func StartTOTPEnrollment(ctx context.Context, db *sql.DB, userID int64) (string, error) {
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err := db.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1", userID,
+	).Scan(&secret, &enabledAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to get TOTP secret: %w", err)
+	}
+	if enabledAt.Valid {
+		return "", ErrTwoFactorEnabled
+	}
+	if secret.Valid && secret.String != "" {
+		return secret.String, nil
+	}
+
+	newSecret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+	_, err = db.ExecContext(ctx,
+		"UPDATE users SET totp_secret = $2, totp_last_step = NULL WHERE id = $1 AND totp_enabled_at IS NULL",
+		userID, newSecret,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to store TOTP secret: %w", err)
+	}
+	return newSecret, nil
+}
+
+// ConfirmTOTPEnrollment enables two-factor authentication if the code matches the pending secret.
+// It returns the new recovery codes, which are only shown this once.
+func ConfirmTOTPEnrollment(ctx context.Context, db *sql.DB, userID int64, code string) ([]string, bool, error) {
+	var secret sql.NullString
+	var enabledAt sql.NullTime
+	err := db.QueryRowContext(ctx,
+		"SELECT totp_secret, totp_enabled_at FROM users WHERE id = $1", userID,
+	).Scan(&secret, &enabledAt)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get TOTP secret: %w", err)
+	}
+	if enabledAt.Valid {
+		return nil, false, ErrTwoFactorEnabled
+	}
+	if !secret.Valid || secret.String == "" {
+		return nil, false, nil
+	}
+
+	step, ok := ValidateTOTP(secret.String, code, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE users SET totp_enabled_at = $2, totp_last_step = $3 WHERE id = $1",
+		userID, time.Now(), step,
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, fmt.Errorf("failed to commit two-factor enrollment: %w", err)
+	}
+	return codes, true, nil
+}
+
+// VerifyTOTP checks a code from the user's authenticator app.
+// A code is accepted only once, so an intercepted code cannot be replayed.
+func VerifyTOTP(ctx context.Context, db *sql.DB, userID int64, code string) (bool, error) {
+	var secret sql.NullString
+	err := db.QueryRowContext(ctx,
+		"SELECT totp_secret FROM users WHERE id = $1 AND totp_enabled_at IS NOT NULL", userID,
+	).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get TOTP secret: %w", err)
+	}
+	if !secret.Valid {
+		return false, nil
+	}
+
+	step, ok := ValidateTOTP(secret.String, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	result, err := db.ExecContext(ctx,
+		`UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`,
+		userID, step,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP use: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return updated == 1, nil
+}
+
+// UseRecoveryCode checks a recovery code and marks it as used
+func UseRecoveryCode(ctx context.Context, db *sql.DB, userID int64, code string) (bool, error) {
+	result, err := db.ExecContext(ctx,
+		`UPDATE user_recovery_codes SET used_at = $3
+		WHERE id = (
+			SELECT id FROM user_recovery_codes
+			WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+			LIMIT 1
+		)`,
+		userID, HashRecoveryCode(code), time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return updated == 1, nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes and returns the new ones
+func RegenerateRecoveryCodes(ctx context.Context, db *sql.DB, userID int64) ([]string, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	codes, err := replaceRecoveryCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit recovery codes: %w", err)
+	}
+	return codes, nil
+}
+
+// replaceRecoveryCodes deletes the recovery codes of a user and stores new ones
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int64) ([]string, error) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	for _, code := range codes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO user_recovery_codes (user_id, code_hash, created_at) VALUES ($1, $2, $3)",
+			userID, HashRecoveryCode(code), time.Now(),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to store recovery code: %w", err)
+		}
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor removes the TOTP secret, recovery codes and pending logins of a user.
+// Users of a role that requires two-factor authentication enroll again at their next login.
+func DisableTwoFactor(ctx context.Context, db *sql.DB, userID int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		"UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = NULL WHERE id = $1",
+		"DELETE FROM user_recovery_codes WHERE user_id = $1",
+		"DELETE FROM two_factor_challenges WHERE user_id = $1",
+	}
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit two-factor reset: %w", err)
+	}
+	return nil
+}
@@ -3,20 +3,24 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // Config holds all application configuration
 type Config struct {
-	App      AppConfig
-	Server   ServerConfig
-	Database DatabaseConfig
-	Session  SessionConfig
-	Google   GoogleOAuthConfig
-	SMTP     SMTPConfig
-	JIRA     JIRAConfig
-	Upload   UploadConfig
-	Security SecurityConfig
-	Logging  LoggingConfig
+	App       AppConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Session   SessionConfig
+	Google    GoogleOAuthConfig
+	OIDC      []OIDCProviderConfig
+	SMTP      SMTPConfig
+	JIRA      JIRAConfig
+	Upload    UploadConfig
+	Security  SecurityConfig
+	Logging   LoggingConfig
+	Tracking  CourierTrackingConfig
+	Warehouse WarehouseConfig
 }
 
 // AppConfig contains general application settings
@@ -52,7 +56,22 @@ type GoogleOAuthConfig struct {
 	ClientID      string
 	ClientSecret  string
 	RedirectURL   string
-	AllowedDomain string
+	AllowedDomain string // Comma-separated email domains allowed to sign in, empty for any
+}
+
+// OIDCProviderConfig contains the settings of one OpenID Connect identity provider.
+// Providers are listed in OIDC_PROVIDERS and each one is configured with OIDC_<ID>_* variables.
+type OIDCProviderConfig struct {
+	ID              string // Short name used in the login URL, e.g. "acme"
+	Name            string // Label of the sign-in button
+	IssuerURL       string // Issuer the discovery document is read from
+	ClientID        string
+	ClientSecret    string
+	ClientCompanyID int64  // Client company new users are provisioned for, 0 for none
+	DefaultRole     string // Role of new users
+	AllowedDomains  string // Comma-separated email domains allowed to sign in, empty for any
+	// AllowEmailLinking links existing users by email on their first login (never staff accounts)
+	AllowEmailLinking bool
 }
 
 // SMTPConfig contains email server settings
@@ -65,6 +84,10 @@ type SMTPConfig struct {
 	FromName       string
 	LogisticsEmail string // Default logistics team email
 	WarehouseEmail string // Default warehouse email (for fallback)
+
+	// Email outbox delivery
+	OutboxPollInterval int // Seconds between deliveries of queued notifications
+	OutboxMaxAttempts  int // Delivery attempts before a notification is dead-lettered
 }
 
 // JIRAConfig contains JIRA integration settings
@@ -87,7 +110,22 @@ type UploadConfig struct {
 
 // SecurityConfig contains security settings
 type SecurityConfig struct {
-	CSRFSecret string
+	CSRFSecret        string
+	TrustProxyHeaders bool // Take the client IP from X-Forwarded-For (only behind a reverse proxy)
+
+	// Login brute-force protection
+	LoginFailureWindow         int // Minutes failed logins are counted for
+	LoginMaxFailuresPerIP      int // Failures from an IP address within the window before it is throttled
+	LoginMaxFailuresPerAccount int // Failures for an email within the window before it is throttled
+	LoginLockoutThreshold      int // Consecutive wrong passwords that lock an account
+	LoginLockoutDuration       int // Minutes an account stays locked
+
+	// Two-factor authentication
+	TwoFactorRequiredRoles string // Comma-separated roles that must use TOTP, empty to keep it optional for everyone
+	TwoFactorIssuer        string // Name shown for the account in authenticator apps
+
+	// Role permissions
+	PermissionsReloadInterval int // Seconds between reloads of the role permissions saved by admins, 0 to disable
 }
 
 // LoggingConfig contains logging settings
@@ -96,6 +134,22 @@ type LoggingConfig struct {
 	Format string
 }
 
+// CourierTrackingConfig contains courier tracking integration settings
+type CourierTrackingConfig struct {
+	Provider      string // Tracking provider to enable ("fake"), empty to disable
+	Couriers      string // Comma-separated courier names the provider handles, empty for all
+	WebhookSecret string
+	PollInterval  int  // Seconds between polls of active shipments, 0 to disable polling
+	AutoAdvance   bool // Advance shipment status from courier checkpoints
+}
+
+// WarehouseConfig contains the warehouse details printed on shipping documents
+type WarehouseConfig struct {
+	Name    string
+	Address string // Address lines separated by "|", e.g. "100 Main St|Austin, TX 78701|USA"
+	Phone   string
+}
+
 // Load reads configuration from environment variables
 func Load() *Config {
 	return &Config{
@@ -125,6 +179,7 @@ func Load() *Config {
 			RedirectURL:   getEnv("GOOGLE_REDIRECT_URL", ""),
 			AllowedDomain: getEnv("GOOGLE_ALLOWED_DOMAIN", "bairesdev.com"),
 		},
+		OIDC: loadOIDCProviders(),
 		SMTP: SMTPConfig{
 			Host:           getEnv("SMTP_HOST", "localhost"),
 			Port:           getEnv("SMTP_PORT", "1025"),
@@ -134,6 +189,9 @@ func Load() *Config {
 			FromName:       getEnv("SMTP_FROM_NAME", "Align"),
 			LogisticsEmail: getEnv("LOGISTICS_EMAIL", "international@bairesdev.com"),
 			WarehouseEmail: getEnv("WAREHOUSE_EMAIL", "warehouse@bairesdev.com"),
+
+			OutboxPollInterval: getEnvAsInt("EMAIL_OUTBOX_POLL_INTERVAL", 30),
+			OutboxMaxAttempts:  getEnvAsInt("EMAIL_OUTBOX_MAX_ATTEMPTS", 8),
 		},
 		JIRA: JIRAConfig{
 			URL:            getEnv("JIRA_URL", ""),
@@ -149,13 +207,59 @@ func Load() *Config {
 			Path:    getEnv("UPLOAD_PATH", "./uploads"),
 		},
 		Security: SecurityConfig{
-			CSRFSecret: getEnv("CSRF_SECRET", "change-me-in-production"),
+			CSRFSecret:                 getEnv("CSRF_SECRET", "change-me-in-production"),
+			TrustProxyHeaders:          getEnvAsBool("TRUST_PROXY_HEADERS", false),
+			LoginFailureWindow:         getEnvAsInt("LOGIN_FAILURE_WINDOW", 15),
+			LoginMaxFailuresPerIP:      getEnvAsInt("LOGIN_MAX_FAILURES_PER_IP", 20),
+			LoginMaxFailuresPerAccount: getEnvAsInt("LOGIN_MAX_FAILURES_PER_ACCOUNT", 10),
+			LoginLockoutThreshold:      getEnvAsInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+			LoginLockoutDuration:       getEnvAsInt("LOGIN_LOCKOUT_DURATION", 30),
+			TwoFactorRequiredRoles:     getEnv("TWO_FACTOR_REQUIRED_ROLES", ""),
+			TwoFactorIssuer:            getEnv("TWO_FACTOR_ISSUER", "Align"),
+			PermissionsReloadInterval:  getEnvAsInt("PERMISSIONS_RELOAD_INTERVAL", 30),
 		},
 		Logging: LoggingConfig{
 			Level:  getEnv("LOG_LEVEL", "info"),
 			Format: getEnv("LOG_FORMAT", "json"),
 		},
+		Tracking: CourierTrackingConfig{
+			Provider:      getEnv("COURIER_TRACKING_PROVIDER", ""),
+			Couriers:      getEnv("COURIER_TRACKING_COURIERS", ""),
+			WebhookSecret: getEnv("COURIER_WEBHOOK_SECRET", ""),
+			PollInterval:  getEnvAsInt("COURIER_POLL_INTERVAL", 900),
+			AutoAdvance:   getEnvAsBool("COURIER_AUTO_ADVANCE", false),
+		},
+		Warehouse: WarehouseConfig{
+			Name:    getEnv("WAREHOUSE_NAME", "Warehouse"),
+			Address: getEnv("WAREHOUSE_ADDRESS", ""),
+			Phone:   getEnv("WAREHOUSE_PHONE", ""),
+		},
+	}
+}
+
+// loadOIDCProviders reads the providers listed in OIDC_PROVIDERS, e.g. "acme,globex".
+// The settings of provider "acme" are read from OIDC_ACME_ISSUER, OIDC_ACME_CLIENT_ID and so on.
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, id := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			ID:                id,
+			Name:              getEnv(prefix+"NAME", id),
+			IssuerURL:         getEnv(prefix+"ISSUER", ""),
+			ClientID:          getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret:      getEnv(prefix+"CLIENT_SECRET", ""),
+			ClientCompanyID:   getEnvAsInt64(prefix+"CLIENT_COMPANY_ID", 0),
+			DefaultRole:       getEnv(prefix+"DEFAULT_ROLE", "client"),
+			AllowedDomains:    getEnv(prefix+"ALLOWED_DOMAINS", ""),
+			AllowEmailLinking: getEnvAsBool(prefix+"ALLOW_EMAIL_LINKING", false),
+		})
 	}
+	return providers
 }
 
 // getEnv retrieves an environment variable or returns a default value
@@ -183,3 +287,12 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	}
 	return defaultValue
 }
+
+// getEnvAsBool retrieves an environment variable as bool or returns default
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
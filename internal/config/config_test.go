@@ -132,3 +132,81 @@ func TestGetEnvAsInt64(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEnvAsBool(t *testing.T) {
+	tests := []struct {
+		name         string
+		key          string
+		value        string
+		defaultValue bool
+		expected     bool
+	}{
+		{"True", "TEST_BOOL", "true", false, true},
+		{"Numeric", "TEST_BOOL", "0", true, false},
+		{"InvalidBool", "TEST_BOOL", "maybe", true, true},
+		{"EmptyString", "TEST_BOOL", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.value != "" {
+				os.Setenv(tt.key, tt.value)
+			} else {
+				os.Unsetenv(tt.key)
+			}
+			defer os.Unsetenv(tt.key)
+
+			result := getEnvAsBool(tt.key, tt.defaultValue)
+			if result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestLoadOIDCProviders(t *testing.T) {
+	t.Setenv("OIDC_PROVIDERS", " Acme, globex-corp ,")
+	t.Setenv("OIDC_ACME_NAME", "Acme Okta")
+	t.Setenv("OIDC_ACME_ISSUER", "https://acme.okta.com")
+	t.Setenv("OIDC_ACME_CLIENT_ID", "acme-client")
+	t.Setenv("OIDC_ACME_CLIENT_COMPANY_ID", "7")
+	t.Setenv("OIDC_ACME_ALLOWED_DOMAINS", "acme.com,acme.io")
+	t.Setenv("OIDC_ACME_ALLOW_EMAIL_LINKING", "true")
+	t.Setenv("OIDC_GLOBEX_CORP_ISSUER", "https://login.globex.com")
+	t.Setenv("OIDC_GLOBEX_CORP_DEFAULT_ROLE", "project_manager")
+
+	providers := loadOIDCProviders()
+	if len(providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(providers))
+	}
+
+	acme := providers[0]
+	if acme.ID != "acme" || acme.Name != "Acme Okta" || acme.IssuerURL != "https://acme.okta.com" {
+		t.Errorf("Unexpected acme provider: %+v", acme)
+	}
+	if acme.ClientID != "acme-client" || acme.ClientCompanyID != 7 || acme.AllowedDomains != "acme.com,acme.io" {
+		t.Errorf("Unexpected acme provider: %+v", acme)
+	}
+	if acme.DefaultRole != "client" {
+		t.Errorf("Expected default role 'client', got '%s'", acme.DefaultRole)
+	}
+	if !acme.AllowEmailLinking {
+		t.Error("Expected email linking to be allowed for acme")
+	}
+
+	globex := providers[1]
+	if globex.ID != "globex-corp" || globex.Name != "globex-corp" || globex.IssuerURL != "https://login.globex.com" {
+		t.Errorf("Unexpected globex provider: %+v", globex)
+	}
+	if globex.DefaultRole != "project_manager" {
+		t.Errorf("Expected role 'project_manager', got '%s'", globex.DefaultRole)
+	}
+	if globex.AllowEmailLinking {
+		t.Error("Expected email linking to be off by default")
+	}
+
+	t.Setenv("OIDC_PROVIDERS", "")
+	if providers := loadOIDCProviders(); len(providers) != 0 {
+		t.Errorf("Expected no providers, got %d", len(providers))
+	}
+}
@@ -32,11 +32,14 @@ func SetupTestDB(t *testing.T) (*sql.DB, func()) {
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
+		testDBMutex.Unlock()
 		t.Fatalf("Failed to connect to test database: %v", err)
 	}
 
-	// Test the connection
+	// Test the connection. Release the lock before failing, or every later test would wait for it.
 	if err := db.Ping(); err != nil {
+		db.Close()
+		testDBMutex.Unlock()
 		t.Fatalf("Failed to ping test database: %v", err)
 	}
 
@@ -44,9 +47,20 @@ func SetupTestDB(t *testing.T) (*sql.DB, func()) {
 	// This ensures each test starts with a clean slate, preventing race conditions
 	cleanupQueries := []string{
 		"DELETE FROM sessions",
+		"DELETE FROM login_attempts",
+		"DELETE FROM two_factor_challenges",
+		"DELETE FROM user_recovery_codes",
 		"DELETE FROM magic_links",
 		"DELETE FROM notification_logs",
-		"DELETE FROM audit_logs",
+		"DELETE FROM email_outbox",
+		"DELETE FROM email_template_versions",
+		// audit_logs rejects DELETE; its owner, which runs the tests, may still TRUNCATE it
+		"TRUNCATE audit_logs",
+		"DELETE FROM api_tokens",
+		"DELETE FROM service_accounts",
+		"DELETE FROM role_permissions",
+		"DELETE FROM user_client_companies",
+		"DELETE FROM shipment_workflows",
 		"DELETE FROM delivery_forms",
 		"DELETE FROM reception_reports",
 		"DELETE FROM pickup_forms",
@@ -0,0 +1,215 @@
+package documents
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+const invoiceRowHeight = 7.0
+
+// invoiceColumns are the goods table columns: header, width in mm and alignment
+var invoiceColumns = []struct {
+	header string
+	width  float64
+	align  string
+}{
+	{"#", 8, "L"},
+	{"Description of Goods", 76, "L"},
+	{"HS Code", 20, "L"},
+	{"Origin", 28, "L"},
+	{"Qty", 12, "R"},
+	{"Unit Value", 21, "R"},
+	{"Total", 21, "R"},
+}
+
+// InvoiceTitle returns the document title: goods that are sold travel on a commercial invoice,
+// everything else (company equipment, repairs, returns) on a pro forma invoice
+func InvoiceTitle(invoice *models.CommercialInvoice) string {
+	if invoice.Shipment.ExportReason == models.ExportReasonSale {
+		return "COMMERCIAL INVOICE"
+	}
+	return "PRO FORMA INVOICE"
+}
+
+// WriteCommercialInvoice renders the commercial invoice of an international shipment as PDF.
+// Missing customs details are listed on the invoice so a draft can still be reviewed.
+func WriteCommercialInvoice(w io.Writer, invoice *models.CommercialInvoice) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(slipMargin, slipMargin, slipMargin)
+	pdf.SetAutoPageBreak(false, slipMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	writeCommercialInvoice(pdf, tr, invoice)
+	if pdf.Err() {
+		return pdf.Error()
+	}
+	return pdf.Output(w)
+}
+
+// writeCommercialInvoice adds the pages of a commercial invoice
+func writeCommercialInvoice(pdf *gofpdf.Fpdf, tr func(string) string, invoice *models.CommercialInvoice) {
+	s := invoice.Shipment
+	contentWidth := slipPageWidth - 2*slipMargin
+	currency := invoice.Currency()
+
+	pdf.AddPage()
+
+	// Header with the shipment barcode on the right
+	pdf.SetFont("Arial", "B", 20)
+	pdf.CellFormat(120, 10, InvoiceTitle(invoice), "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(120, 6, "Invoice No.: "+invoice.InvoiceNumber(), "", 1, "L", false, 0, "")
+	pdf.CellFormat(120, 6, "Date: "+invoice.GeneratedAt.Format("Jan 2, 2006"), "", 1, "L", false, 0, "")
+	jira := s.JiraTicketNumber
+	if jira == "" {
+		jira = "-"
+	}
+	pdf.CellFormat(120, 6, tr(fmt.Sprintf("Shipment #%d    JIRA Ticket: %s", s.ID, jira)), "", 1, "L", false, 0, "")
+	if s.TrackingNumber != "" {
+		pdf.CellFormat(120, 6, tr(fmt.Sprintf("Air Waybill: %s %s", s.CourierName, s.TrackingNumber)), "", 1, "L", false, 0, "")
+	}
+
+	code := ShipmentCode(s.ID)
+	drawCode128(pdf, code, slipPageWidth-slipMargin-56, slipMargin+2, 56, 14)
+	pdf.SetFont("Arial", "", 8)
+	pdf.SetXY(slipPageWidth-slipMargin-56, slipMargin+17)
+	pdf.CellFormat(56, 4, code, "", 0, "C", false, 0, "")
+
+	// Exporter and consignee side by side
+	y := slipMargin + 42
+	boxWidth := (contentWidth - 6) / 2
+	consignee := invoice.Consignee
+	taxID := s.RecipientTaxID
+	if taxID == "" {
+		taxID = "____________________"
+	}
+	consignee.Lines = append(append([]string{}, consignee.Lines...), "Tax ID: "+taxID)
+	exporterHeight := writeAddressBox(pdf, tr, "EXPORTER / SHIPPER", invoice.Exporter, slipMargin, y, boxWidth)
+	consigneeHeight := writeAddressBox(pdf, tr, "CONSIGNEE / IMPORTER", consignee, slipMargin+boxWidth+6, y, boxWidth)
+	y += max(exporterHeight, consigneeHeight) + 6
+
+	// Shipment terms
+	reason := s.ExportReason.Label()
+	if reason == "" {
+		reason = "Not specified"
+	}
+	pdf.SetXY(slipMargin, y)
+	writeInvoiceTerm(pdf, tr, "Reason for Export:", reason, contentWidth)
+	writeInvoiceTerm(pdf, tr, "Currency:", currency, contentWidth)
+	packages := fmt.Sprintf("%d", max(len(invoice.Packages), 1))
+	if weight := invoice.TotalWeightLb(); weight > 0 {
+		packages += fmt.Sprintf("    Total Gross Weight: %.2f lb (%.2f kg)", weight, weight*0.45359237)
+	}
+	writeInvoiceTerm(pdf, tr, "Packages:", packages, contentWidth)
+	pdf.Ln(3)
+
+	// Goods
+	writeInvoiceHeader(pdf)
+	pdf.SetFont("Arial", "", 9)
+	for i, item := range invoice.Items {
+		if pdf.GetY()+invoiceRowHeight > slipPageHeight-slipMargin-50 {
+			pdf.AddPage()
+			pdf.SetFont("Arial", "", 8)
+			pdf.CellFormat(contentWidth, 5, tr(fmt.Sprintf("%s %s (continued)", InvoiceTitle(invoice), invoice.InvoiceNumber())), "", 1, "L", false, 0, "")
+			writeInvoiceHeader(pdf)
+			pdf.SetFont("Arial", "", 9)
+		}
+		writeInvoiceRow(pdf, tr, i+1, item)
+	}
+	if len(invoice.Items) == 0 {
+		pdf.CellFormat(contentWidth, invoiceRowHeight, "No devices assigned to this shipment", "1", 1, "C", false, 0, "")
+	}
+
+	// Totals
+	labelWidth := contentWidth - invoiceColumns[len(invoiceColumns)-1].width
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(labelWidth, invoiceRowHeight, fmt.Sprintf("Total Declared Value (%s)", currency), "1", 0, "R", false, 0, "")
+	pdf.CellFormat(invoiceColumns[len(invoiceColumns)-1].width, invoiceRowHeight, formatMoney(invoice.TotalValue()), "1", 1, "R", false, 0, "")
+
+	// Missing details, so a draft is never mistaken for a finished invoice
+	if missing := invoice.MissingFields(); len(missing) > 0 {
+		pdf.Ln(3)
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetTextColor(200, 0, 0)
+		pdf.MultiCell(contentWidth, 5, tr("DRAFT - missing customs details: "+strings.Join(missing, ", ")), "", "L", false)
+		pdf.SetTextColor(0, 0, 0)
+	}
+
+	// Declaration and signature at the bottom of the last page
+	if pdf.GetY() > slipPageHeight-slipMargin-40 {
+		pdf.AddPage()
+	}
+	pdf.SetXY(slipMargin, slipPageHeight-slipMargin-32)
+	pdf.SetFont("Arial", "", 9)
+	pdf.MultiCell(contentWidth, 5, "I declare that the information on this invoice is true and correct and that the contents of this shipment are as stated above.", "", "L", false)
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(contentWidth/3, 8, "Name: ________________", "", 0, "L", false, 0, "")
+	pdf.CellFormat(contentWidth/3, 8, "Signature: ________________", "", 0, "C", false, 0, "")
+	pdf.CellFormat(contentWidth/3, 8, "Date: ____________", "", 1, "R", false, 0, "")
+}
+
+// writeInvoiceTerm prints one "label: value" line of the shipment terms
+func writeInvoiceTerm(pdf *gofpdf.Fpdf, tr func(string) string, label, value string, width float64) {
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(38, 6, label, "", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(width-38, 6, tr(value), "", 1, "L", false, 0, "")
+}
+
+// writeInvoiceHeader prints the goods table header row
+func writeInvoiceHeader(pdf *gofpdf.Fpdf) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range invoiceColumns {
+		pdf.CellFormat(col.width, invoiceRowHeight, col.header, "1", 0, col.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// writeInvoiceRow prints one declared device
+func writeInvoiceRow(pdf *gofpdf.Fpdf, tr func(string) string, number int, item models.CommercialInvoiceItem) {
+	hsCode := item.HSCode()
+	if hsCode == "" {
+		hsCode = "-"
+	}
+	origin := item.CountryOfOrigin
+	if origin == "" {
+		origin = "-"
+	}
+	value := "-"
+	if item.DeclaredValue != nil {
+		value = formatMoney(*item.DeclaredValue)
+	}
+
+	// Long model names are cut to keep one row per device
+	description := []rune(item.Description())
+	for len(description) > 0 && pdf.GetStringWidth(tr(string(description))) > invoiceColumns[1].width-2 {
+		description = description[:len(description)-1]
+	}
+
+	values := []string{strconv.Itoa(number), string(description), hsCode, origin, "1", value, value}
+	for i, col := range invoiceColumns {
+		pdf.CellFormat(col.width, invoiceRowHeight, tr(values[i]), "1", 0, col.align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// formatMoney formats an amount with two decimals and thousands separators, e.g. "1,299.00"
+func formatMoney(amount float64) string {
+	text := strconv.FormatFloat(amount, 'f', 2, 64)
+	whole, cents := text[:len(text)-3], text[len(text)-3:]
+	sign := ""
+	if strings.HasPrefix(whole, "-") {
+		sign, whole = "-", whole[1:]
+	}
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + "," + whole[i:]
+	}
+	return sign + whole + cents
+}
@@ -0,0 +1,104 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func testCommercialInvoice(laptops int) *models.CommercialInvoice {
+	value := 1299.5
+	invoice := &models.CommercialInvoice{
+		Shipment: models.Shipment{
+			ID:               7,
+			ShipmentType:     models.ShipmentTypeWarehouseToEngineer,
+			JiraTicketNumber: "SCOP-123",
+			CourierName:      "DHL",
+			TrackingNumber:   "1234567890",
+			ExportReason:     models.ExportReasonCompanyEquipment,
+			RecipientTaxID:   "20-12345678-9",
+			CustomsCurrency:  "USD",
+		},
+		CompanyName: "Acme Corp",
+		Exporter:    models.ShippingAddress{Name: "Warehouse", Lines: []string{"100 Main St", "Austin, TX 78701", "USA"}},
+		Consignee: models.ShippingAddress{
+			Name:  "José Pérez",
+			Lines: models.NewShippingAddressLines("Av. Siempre Viva 742", "Córdoba", "", "5000", "Argentina"),
+		},
+		Packages:    []models.ShipmentPackage{{PackageNumber: 1, WeightLb: 6.5}},
+		GeneratedAt: time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < laptops; i++ {
+		invoice.Items = append(invoice.Items, models.CommercialInvoiceItem{
+			SerialNumber:    fmt.Sprintf("SN-%04d", i),
+			Brand:           "Dell",
+			Model:           "Latitude 5420",
+			DeviceCategory:  models.DeviceCategoryLaptop,
+			CountryOfOrigin: "China",
+			DeclaredValue:   &value,
+		})
+	}
+	return invoice
+}
+
+func writeTestInvoice(t *testing.T, invoice *models.CommercialInvoice) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteCommercialInvoice(&buf, invoice); err != nil {
+		t.Fatalf("WriteCommercialInvoice() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "%PDF-") {
+		t.Fatal("Expected PDF output")
+	}
+	return buf.String()
+}
+
+func TestWriteCommercialInvoice(t *testing.T) {
+	if pages := strings.Count(writeTestInvoice(t, testCommercialInvoice(3)), "/Type /Page\n"); pages != 1 {
+		t.Errorf("Expected a small shipment to fit on 1 page, got %d", pages)
+	}
+	if pages := strings.Count(writeTestInvoice(t, testCommercialInvoice(40)), "/Type /Page\n"); pages < 2 {
+		t.Errorf("Expected 40 devices to need more than 1 page, got %d", pages)
+	}
+}
+
+func TestWriteCommercialInvoice_Draft(t *testing.T) {
+	invoice := testCommercialInvoice(1)
+	invoice.Shipment.ExportReason = ""
+	invoice.Shipment.RecipientTaxID = ""
+	invoice.Items[0].DeclaredValue = nil
+	invoice.Items[0].Model = strings.Repeat("Very Long Model Name ", 10)
+
+	writeTestInvoice(t, invoice)
+}
+
+func TestInvoiceTitle(t *testing.T) {
+	invoice := testCommercialInvoice(1)
+	if got := InvoiceTitle(invoice); got != "PRO FORMA INVOICE" {
+		t.Errorf("InvoiceTitle() = %q for company equipment", got)
+	}
+	invoice.Shipment.ExportReason = models.ExportReasonSale
+	if got := InvoiceTitle(invoice); got != "COMMERCIAL INVOICE" {
+		t.Errorf("InvoiceTitle() = %q for a sale", got)
+	}
+}
+
+func TestFormatMoney(t *testing.T) {
+	tests := map[float64]string{
+		0:          "0.00",
+		12.5:       "12.50",
+		999.999:    "1,000.00",
+		1299.5:     "1,299.50",
+		1234567.89: "1,234,567.89",
+		-1500:      "-1,500.00",
+	}
+	for amount, want := range tests {
+		if got := formatMoney(amount); got != want {
+			t.Errorf("formatMoney(%v) = %q, want %q", amount, got, want)
+		}
+	}
+}
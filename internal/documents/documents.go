@@ -0,0 +1,109 @@
+// Package documents renders printable shipping documents, such as packing slips and
+// commercial invoices, as PDF.
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yourusername/laptop-tracking-system/internal/config"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// WarehouseAddress returns the configured warehouse as a shipping address
+func WarehouseAddress(cfg config.WarehouseConfig) models.ShippingAddress {
+	lines := []string{}
+	for _, line := range strings.Split(cfg.Address, "|") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return models.ShippingAddress{
+		Name:  cfg.Name,
+		Lines: lines,
+		Phone: cfg.Phone,
+	}
+}
+
+// ShipmentCode is the value encoded in a shipment's barcodes
+func ShipmentCode(shipmentID int64) string {
+	return fmt.Sprintf("SHIP-%d", shipmentID)
+}
+
+// humanize turns an identifier such as "single_full_journey" into "Single Full Journey"
+func humanize(value string) string {
+	words := strings.Fields(strings.ReplaceAll(value, "_", " "))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// isBarcodeText returns true if the text can be encoded as Code128 (printable ASCII)
+func isBarcodeText(text string) bool {
+	if text == "" {
+		return false
+	}
+	for _, r := range text {
+		if r < 32 || r > 126 {
+			return false
+		}
+	}
+	return true
+}
+
+// drawCode128 draws a Code128 barcode of text in the given box.
+// Text that cannot be encoded is skipped, the caller prints it in plain text anyway.
+func drawCode128(pdf *gofpdf.Fpdf, text string, x, y, w, h float64) {
+	if !isBarcodeText(text) {
+		return
+	}
+	bc, err := code128.Encode(text)
+	if err != nil {
+		return
+	}
+	// Three pixels per module keeps bars crisp when the image is scaled to the box
+	scaled, err := barcode.Scale(bc, bc.Bounds().Dx()*3, 60)
+	if err != nil {
+		return
+	}
+	drawBarcodeImage(pdf, "code128:"+text, scaled, x, y, w, h)
+}
+
+// drawQR draws a QR code of text in the given square
+func drawQR(pdf *gofpdf.Fpdf, text string, x, y, size float64) {
+	bc, err := qr.Encode(text, qr.M, qr.Auto)
+	if err != nil {
+		return
+	}
+	scaled, err := barcode.Scale(bc, bc.Bounds().Dx()*8, bc.Bounds().Dy()*8)
+	if err != nil {
+		return
+	}
+	drawBarcodeImage(pdf, "qr:"+text, scaled, x, y, size, size)
+}
+
+// drawBarcodeImage embeds a barcode as an 8-bit grayscale PNG, the format gofpdf supports
+func drawBarcodeImage(pdf *gofpdf.Fpdf, name string, bc barcode.Barcode, x, y, w, h float64) {
+	gray := image.NewGray(bc.Bounds())
+	draw.Draw(gray, gray.Bounds(), bc, bc.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, gray); err != nil {
+		pdf.SetError(fmt.Errorf("failed to encode barcode: %w", err))
+		return
+	}
+	options := gofpdf.ImageOptions{ImageType: "PNG"}
+	if pdf.GetImageInfo(name) == nil {
+		pdf.RegisterImageOptionsReader(name, options, &buf)
+	}
+	pdf.ImageOptions(name, x, y, w, h, false, options, 0, "")
+}
@@ -0,0 +1,222 @@
+package documents
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/jung-kurt/gofpdf"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+const (
+	slipMargin     = 12.0
+	slipPageWidth  = 210.0
+	slipPageHeight = 297.0
+	slipRowHeight  = 16.0 // Tall enough for a serial number barcode
+)
+
+// slipColumns are the contents table columns: header and width in mm
+var slipColumns = []struct {
+	header string
+	width  float64
+}{
+	{"#", 8},
+	{"Serial Number", 70},
+	{"SKU", 42},
+	{"Description", 48},
+	{"Package", 18},
+}
+
+// WritePackingSlips renders packing slips into one PDF, each slip starting on a new page
+func WritePackingSlips(w io.Writer, slips []*models.PackingSlip) error {
+	if len(slips) == 0 {
+		return errors.New("no packing slips to print")
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(slipMargin, slipMargin, slipMargin)
+	pdf.SetAutoPageBreak(false, slipMargin)
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	for _, slip := range slips {
+		writePackingSlip(pdf, tr, slip)
+		if pdf.Err() {
+			return pdf.Error()
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+// writePackingSlip adds the pages of one packing slip
+func writePackingSlip(pdf *gofpdf.Fpdf, tr func(string) string, slip *models.PackingSlip) {
+	s := slip.Shipment
+	code := ShipmentCode(s.ID)
+	contentWidth := slipPageWidth - 2*slipMargin
+
+	pdf.AddPage()
+
+	// Header with the shipment barcodes on the right
+	pdf.SetFont("Arial", "B", 20)
+	pdf.CellFormat(100, 10, "PACKING SLIP", "", 1, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(100, 6, tr(fmt.Sprintf("Shipment #%d - %s", s.ID, humanize(string(s.ShipmentType)))), "", 1, "L", false, 0, "")
+	jira := s.JiraTicketNumber
+	if jira == "" {
+		jira = "-"
+	}
+	pdf.CellFormat(100, 6, tr("JIRA Ticket: "+jira), "", 1, "L", false, 0, "")
+	pdf.CellFormat(100, 6, tr("Client: "+slip.CompanyName), "", 1, "L", false, 0, "")
+	pdf.CellFormat(100, 6, "Printed: "+slip.GeneratedAt.Format("Jan 2, 2006 15:04"), "", 1, "L", false, 0, "")
+
+	drawQR(pdf, code, slipPageWidth-slipMargin-28, slipMargin, 28)
+	drawCode128(pdf, code, slipPageWidth-slipMargin-80, slipMargin+2, 48, 14)
+	pdf.SetFont("Arial", "", 8)
+	pdf.SetXY(slipPageWidth-slipMargin-80, slipMargin+17)
+	pdf.CellFormat(48, 4, code, "", 0, "C", false, 0, "")
+
+	// Sender and recipient side by side
+	y := slipMargin + 42
+	boxWidth := (contentWidth - 6) / 2
+	fromHeight := writeAddressBox(pdf, tr, "FROM", slip.From, slipMargin, y, boxWidth)
+	toHeight := writeAddressBox(pdf, tr, "SHIP TO", slip.To, slipMargin+boxWidth+6, y, boxWidth)
+	y += max(fromHeight, toHeight) + 6
+
+	// Courier and package summary
+	pdf.SetXY(slipMargin, y)
+	pdf.SetFont("Arial", "", 10)
+	courier := s.CourierName
+	if courier == "" {
+		courier = "-"
+	}
+	tracking := s.TrackingNumber
+	if tracking == "" {
+		tracking = "-"
+	}
+	pdf.CellFormat(contentWidth, 6, tr(fmt.Sprintf("Courier: %s    Tracking: %s    Packages: %d    Laptops: %d",
+		courier, tracking, max(len(slip.Packages), 1), len(slip.Items))), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	// Contents
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(contentWidth, 8, "Contents", "", 1, "L", false, 0, "")
+	writeContentsHeader(pdf)
+
+	pdf.SetFont("Arial", "", 9)
+	for i, item := range slip.Items {
+		if pdf.GetY()+slipRowHeight > slipPageHeight-slipMargin-30 {
+			pdf.AddPage()
+			pdf.SetFont("Arial", "", 8)
+			pdf.CellFormat(contentWidth, 5, tr(fmt.Sprintf("Packing slip for shipment #%d (continued)", s.ID)), "", 1, "L", false, 0, "")
+			writeContentsHeader(pdf)
+			pdf.SetFont("Arial", "", 9)
+		}
+		writeContentsRow(pdf, tr, i+1, item)
+	}
+	if len(slip.Items) == 0 {
+		pdf.CellFormat(contentWidth, 8, "No laptops assigned to this shipment", "1", 1, "C", false, 0, "")
+	}
+
+	// Accessories and instructions
+	if pdf.GetY() > slipPageHeight-slipMargin-45 {
+		pdf.AddPage()
+	}
+	pdf.Ln(4)
+	pdf.SetFont("Arial", "B", 10)
+	pdf.CellFormat(30, 6, "Accessories:", "", 0, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	accessories := "Not included"
+	if slip.IncludeAccessories {
+		accessories = "Included"
+		if slip.AccessoriesDescription != "" {
+			accessories += " - " + slip.AccessoriesDescription
+		}
+	}
+	pdf.MultiCell(contentWidth-30, 6, tr(accessories), "", "L", false)
+	if slip.SpecialInstructions != "" {
+		pdf.SetFont("Arial", "B", 10)
+		pdf.CellFormat(30, 6, "Instructions:", "", 0, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 10)
+		pdf.MultiCell(contentWidth-30, 6, tr(slip.SpecialInstructions), "", "L", false)
+	}
+
+	// Sign-off at the bottom of the last page
+	pdf.SetXY(slipMargin, slipPageHeight-slipMargin-12)
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(contentWidth/2, 8, "Packed by: ______________________", "", 0, "L", false, 0, "")
+	pdf.CellFormat(contentWidth/2, 8, "Date: ______________", "", 1, "R", false, 0, "")
+}
+
+// writeAddressBox prints a framed address and returns its height
+func writeAddressBox(pdf *gofpdf.Fpdf, tr func(string) string, title string, a models.ShippingAddress, x, y, width float64) float64 {
+	lines := []string{}
+	if a.Name != "" {
+		lines = append(lines, a.Name)
+	}
+	if a.Company != "" && a.Company != a.Name {
+		lines = append(lines, a.Company)
+	}
+	lines = append(lines, a.Lines...)
+	if a.Phone != "" {
+		lines = append(lines, "Phone: "+a.Phone)
+	}
+	if a.Email != "" {
+		lines = append(lines, a.Email)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Address not available")
+	}
+
+	pdf.SetXY(x+2, y+2)
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(width-4, 5, title, "", 2, "L", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	for _, line := range lines {
+		pdf.SetX(x + 2)
+		pdf.MultiCell(width-4, 5, tr(line), "", "L", false)
+	}
+
+	height := pdf.GetY() - y + 2
+	pdf.Rect(x, y, width, height, "D")
+	return height
+}
+
+// writeContentsHeader prints the contents table header row
+func writeContentsHeader(pdf *gofpdf.Fpdf) {
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, col := range slipColumns {
+		pdf.CellFormat(col.width, 7, col.header, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+// writeContentsRow prints one laptop with a barcode of its serial number
+func writeContentsRow(pdf *gofpdf.Fpdf, tr func(string) string, number int, item models.PackingSlipItem) {
+	x, y := pdf.GetXY()
+
+	packageLabel := "-"
+	if item.PackageNumber > 0 {
+		packageLabel = strconv.Itoa(item.PackageNumber)
+	}
+	sku := item.SKU
+	if sku == "" {
+		sku = "-"
+	}
+
+	values := []string{strconv.Itoa(number), "", sku, item.Description(), packageLabel}
+	for i, col := range slipColumns {
+		pdf.CellFormat(col.width, slipRowHeight, tr(values[i]), "1", 0, "L", false, 0, "")
+	}
+
+	// Serial number barcode above its text
+	serialX := x + slipColumns[0].width
+	serialWidth := slipColumns[1].width
+	drawCode128(pdf, item.SerialNumber, serialX+2, y+1.5, serialWidth-4, 9)
+	pdf.SetXY(serialX, y+slipRowHeight-5)
+	pdf.CellFormat(serialWidth, 4, tr(item.SerialNumber), "", 0, "C", false, 0, "")
+
+	pdf.SetXY(x, y+slipRowHeight)
+}
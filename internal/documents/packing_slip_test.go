@@ -0,0 +1,108 @@
+package documents
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/config"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func testPackingSlip(id int64, laptops int) *models.PackingSlip {
+	slip := &models.PackingSlip{
+		Shipment: models.Shipment{
+			ID:               id,
+			ShipmentType:     models.ShipmentTypeWarehouseToEngineer,
+			JiraTicketNumber: "SCOP-123",
+			CourierName:      "UPS",
+			TrackingNumber:   "1Z999AA10123456784",
+		},
+		CompanyName: "Acme Corp",
+		From:        models.ShippingAddress{Name: "Warehouse", Lines: []string{"100 Main St", "Austin, TX 78701"}},
+		To: models.ShippingAddress{
+			Name:  "José Pérez",
+			Lines: models.NewShippingAddressLines("Av. Siempre Viva 742", "Córdoba", "CBA", "5000", "Argentina"),
+			Phone: "+54 351 000 0000",
+		},
+		IncludeAccessories:     true,
+		AccessoriesDescription: "Charger, sleeve",
+		GeneratedAt:            time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC),
+	}
+	for i := 0; i < laptops; i++ {
+		slip.Items = append(slip.Items, models.PackingSlipItem{
+			SerialNumber:  fmt.Sprintf("SN-%04d", i),
+			SKU:           "DELL-LAT-5420",
+			Brand:         "Dell",
+			Model:         "Latitude 5420",
+			PackageNumber: 1,
+		})
+	}
+	return slip
+}
+
+func countPages(t *testing.T, slips ...*models.PackingSlip) int {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WritePackingSlips(&buf, slips); err != nil {
+		t.Fatalf("WritePackingSlips() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "%PDF-") {
+		t.Fatal("Expected PDF output")
+	}
+	return strings.Count(buf.String(), "/Type /Page\n")
+}
+
+func TestWritePackingSlips(t *testing.T) {
+	if pages := countPages(t, testPackingSlip(1, 2)); pages != 1 {
+		t.Errorf("Expected a small shipment to fit on 1 page, got %d", pages)
+	}
+
+	// Long contents continue on more pages
+	long := countPages(t, testPackingSlip(2, 25))
+	if long < 2 {
+		t.Errorf("Expected 25 laptops to need more than 1 page, got %d", long)
+	}
+
+	// Each slip of a bulk download starts on its own page
+	if pages := countPages(t, testPackingSlip(1, 2), testPackingSlip(2, 25)); pages != long+1 {
+		t.Errorf("Expected %d pages, got %d", long+1, pages)
+	}
+}
+
+func TestWritePackingSlips_NonASCIISerial(t *testing.T) {
+	slip := testPackingSlip(3, 1)
+	slip.Items[0].SerialNumber = "SÉRIE-1"
+
+	var buf bytes.Buffer
+	if err := WritePackingSlips(&buf, []*models.PackingSlip{slip}); err != nil {
+		t.Fatalf("Expected serial numbers that cannot be barcoded to print as text, got %v", err)
+	}
+}
+
+func TestWritePackingSlips_Empty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePackingSlips(&buf, nil); err == nil {
+		t.Error("Expected an error without packing slips")
+	}
+}
+
+func TestWarehouseAddress(t *testing.T) {
+	a := WarehouseAddress(config.WarehouseConfig{Name: "Main Warehouse", Address: "100 Main St| Austin, TX 78701 ||USA", Phone: "555-0100"})
+
+	if a.Name != "Main Warehouse" || a.Phone != "555-0100" {
+		t.Errorf("Unexpected warehouse address %+v", a)
+	}
+	if !reflect.DeepEqual(a.Lines, []string{"100 Main St", "Austin, TX 78701", "USA"}) {
+		t.Errorf("Unexpected address lines %v", a.Lines)
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	if got := humanize("single_full_journey"); got != "Single Full Journey" {
+		t.Errorf("humanize() = %q", got)
+	}
+}
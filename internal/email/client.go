@@ -1,10 +1,22 @@
 package email
 
 import (
+	"bytes"
+	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 )
 
 // Config holds the SMTP configuration for the email client
@@ -14,14 +26,28 @@ type Config struct {
 	Username string // SMTP authentication username (optional for some servers)
 	Password string // SMTP authentication password (optional for some servers)
 	From     string // Default sender email address
+	FromName string // Sender display name (optional)
 }
 
 // Message represents an email message to be sent
 type Message struct {
-	To       []string // List of recipient email addresses
-	Subject  string   // Email subject line
-	Body     string   // Plain text body
-	HTMLBody string   // HTML body (optional)
+	To          []string     // List of recipient email addresses
+	Cc          []string     // Carbon copy recipients (optional)
+	Bcc         []string     // Blind carbon copy recipients, not shown in the headers (optional)
+	ReplyTo     string       // Address replies go to instead of the sender (optional)
+	Subject     string       // Email subject line
+	Body        string       // Plain text body; generated from the HTML body when empty
+	HTMLBody    string       // HTML body (optional)
+	Attachments []Attachment // Attached files and inline images (optional)
+}
+
+// Attachment is a file attached to a message. An attachment with a ContentID is an inline
+// image shown in the HTML body, which refers to it as "cid:<ContentID>".
+type Attachment struct {
+	Filename    string
+	ContentType string // Detected from the file extension when empty
+	Data        []byte
+	ContentID   string // Set for inline images
 }
 
 // Client represents an email client for sending messages via SMTP
@@ -45,6 +71,10 @@ func NewClient(config Config) (*Client, error) {
 		return nil, fmt.Errorf("from address is required")
 	}
 
+	if _, err := mail.ParseAddress(config.From); err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+
 	return &Client{
 		config: config,
 	}, nil
@@ -64,10 +94,32 @@ func (c *Client) BuildMessage(msg Message) error {
 	}
 
 	// Validate body
-	if msg.Body == "" {
+	if msg.Body == "" && msg.HTMLBody == "" {
 		return fmt.Errorf("body is required")
 	}
 
+	// Validate addresses
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		if _, err := parseAddresses(list); err != nil {
+			return err
+		}
+	}
+	if msg.ReplyTo != "" {
+		if _, err := mail.ParseAddress(msg.ReplyTo); err != nil {
+			return fmt.Errorf("invalid reply-to address %q: %w", msg.ReplyTo, err)
+		}
+	}
+
+	// Validate attachments
+	for _, a := range msg.Attachments {
+		if a.Filename == "" {
+			return fmt.Errorf("attachment filename is required")
+		}
+		if a.ContentID != "" && msg.HTMLBody == "" {
+			return fmt.Errorf("inline image %s requires an HTML body", a.Filename)
+		}
+	}
+
 	return nil
 }
 
@@ -91,13 +143,22 @@ func (c *Client) Send(msg Message) error {
 	// Build server address
 	addr := fmt.Sprintf("%s:%d", c.config.Host, c.config.Port)
 
+	// Every recipient gets the message; Bcc recipients only appear in the envelope
+	var recipients []string
+	for _, list := range [][]string{msg.To, msg.Cc, msg.Bcc} {
+		addresses, _ := parseAddresses(list)
+		for _, a := range addresses {
+			recipients = append(recipients, a.Address)
+		}
+	}
+
 	// For port 587 (TLS), use STARTTLS
 	if c.config.Port == 587 {
-		return c.sendWithTLS(addr, auth, msg.To, body)
+		return c.sendWithTLS(addr, auth, recipients, body)
 	}
 
 	// For other ports (25, 465, etc.), use standard SMTP
-	return smtp.SendMail(addr, auth, c.config.From, msg.To, body)
+	return smtp.SendMail(addr, auth, c.sender().Address, recipients, body)
 }
 
 // sendWithTLS sends email using STARTTLS (for port 587)
@@ -125,7 +186,7 @@ func (c *Client) sendWithTLS(addr string, auth smtp.Auth, to []string, body []by
 	}
 
 	// Set sender
-	if err = client.Mail(c.config.From); err != nil {
+	if err = client.Mail(c.sender().Address); err != nil {
 		return fmt.Errorf("failed to set sender: %w", err)
 	}
 
@@ -155,45 +216,218 @@ func (c *Client) sendWithTLS(addr string, auth smtp.Auth, to []string, body []by
 	return client.Quit()
 }
 
-// buildEmailBody constructs the email body with headers
+// sender returns the From address with the configured display name
+func (c *Client) sender() *mail.Address {
+	from, err := mail.ParseAddress(c.config.From)
+	if err != nil {
+		// NewClient rejects invalid addresses; keep the configured value as it is
+		from = &mail.Address{Address: c.config.From}
+	}
+	if c.config.FromName != "" {
+		from.Name = c.config.FromName
+	}
+	return from
+}
+
+// messageID returns a new unique Message-ID in the domain of the sender
+func (c *Client) messageID() string {
+	host := "localhost"
+	from := c.sender().Address
+	if at := strings.LastIndex(from, "@"); at >= 0 && at < len(from)-1 {
+		host = from[at+1:]
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// Unique enough for a header that only helps clients thread and deduplicate messages
+		return fmt.Sprintf("<%d@%s>", time.Now().UnixNano(), host)
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), host)
+}
+
+// buildEmailBody constructs the email with its headers. A message with an HTML body is
+// multipart/alternative with a plain text and an HTML part; inline images make the HTML part
+// multipart/related, and attachments wrap everything in multipart/mixed.
 func (c *Client) buildEmailBody(msg Message) []byte {
-	var body strings.Builder
+	var body bytes.Buffer
 
 	// Add headers
-	body.WriteString(fmt.Sprintf("From: %s\r\n", c.config.From))
-	body.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(msg.To, ", ")))
-	body.WriteString(fmt.Sprintf("Subject: %s\r\n", msg.Subject))
+	body.WriteString(fmt.Sprintf("From: %s\r\n", formatAddress(c.sender())))
+	body.WriteString(fmt.Sprintf("To: %s\r\n", formatAddressList(msg.To)))
+	if len(msg.Cc) > 0 {
+		body.WriteString(fmt.Sprintf("Cc: %s\r\n", formatAddressList(msg.Cc)))
+	}
+	if msg.ReplyTo != "" {
+		body.WriteString(fmt.Sprintf("Reply-To: %s\r\n", formatAddressList([]string{msg.ReplyTo})))
+	}
+	body.WriteString(fmt.Sprintf("Subject: %s\r\n", encodeHeader(msg.Subject)))
+	body.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	body.WriteString(fmt.Sprintf("Message-ID: %s\r\n", c.messageID()))
 	body.WriteString("MIME-Version: 1.0\r\n")
 
-	// If HTML body is provided, create multipart message
+	content := buildContent(msg)
+	writeHeader(&body, content.header)
+	body.WriteString("\r\n")
+	body.Write(content.body)
+
+	return body.Bytes()
+}
+
+// mimePart is a MIME entity: its headers and encoded body
+type mimePart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// buildContent builds the MIME entity of a message body
+func buildContent(msg Message) mimePart {
+	var attachments, inline []mimePart
+	for _, a := range msg.Attachments {
+		if a.ContentID != "" && msg.HTMLBody != "" {
+			inline = append(inline, attachmentPart(a, true))
+		} else {
+			attachments = append(attachments, attachmentPart(a, false))
+		}
+	}
+
+	text := msg.Body
+	if text == "" {
+		text = htmlToText(msg.HTMLBody)
+	}
+	content := textPart("text/plain", text)
+
 	if msg.HTMLBody != "" {
-		boundary := "boundary-string-12345"
-		body.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n", boundary))
-		body.WriteString("\r\n")
+		htmlContent := textPart("text/html", msg.HTMLBody)
+		if len(inline) > 0 {
+			htmlContent = multipartPart("related", append([]mimePart{htmlContent}, inline...))
+		}
+		content = multipartPart("alternative", []mimePart{content, htmlContent})
+	}
+
+	if len(attachments) > 0 {
+		content = multipartPart("mixed", append([]mimePart{content}, attachments...))
+	}
+
+	return content
+}
+
+// textPart is a UTF-8 text entity, quoted-printable encoded so long lines and non-ASCII text survive
+func textPart(contentType, text string) mimePart {
+	var body bytes.Buffer
+	w := quotedprintable.NewWriter(&body)
+	w.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\n", "\r\n")))
+	w.Close()
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType(contentType, map[string]string{"charset": "UTF-8"}))
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	return mimePart{header: header, body: body.Bytes()}
+}
+
+// attachmentPart is a base64 encoded attachment, shown inline when it is an image of the HTML body
+func attachmentPart(a Attachment, inline bool) mimePart {
+	contentType := a.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(a.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "base64")
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+	if inline {
+		header.Set("Content-ID", "<"+strings.Trim(a.ContentID, "<>")+">")
+	}
+
+	// Base64 lines are at most 76 characters
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	var body bytes.Buffer
+	for len(encoded) > 76 {
+		body.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	body.WriteString(encoded + "\r\n")
+
+	return mimePart{header: header, body: body.Bytes()}
+}
+
+// multipartPart combines parts into a multipart entity of the given subtype
+func multipartPart(subtype string, parts []mimePart) mimePart {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, part := range parts {
+		pw, _ := w.CreatePart(part.header)
+		pw.Write(part.body)
+	}
+	w.Close()
 
-		// Plain text part
-		body.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		body.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
-		body.WriteString("\r\n")
-		body.WriteString(msg.Body)
-		body.WriteString("\r\n")
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": w.Boundary()}))
+	return mimePart{header: header, body: body.Bytes()}
+}
+
+// writeHeader writes MIME headers in a stable order
+func writeHeader(w *bytes.Buffer, header textproto.MIMEHeader) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range header[key] {
+			w.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+}
 
-		// HTML part
-		body.WriteString(fmt.Sprintf("--%s\r\n", boundary))
-		body.WriteString("Content-Type: text/html; charset=\"UTF-8\"\r\n")
-		body.WriteString("\r\n")
-		body.WriteString(msg.HTMLBody)
-		body.WriteString("\r\n")
+// parseAddresses parses a list of addresses, with or without display names
+func parseAddresses(list []string) ([]*mail.Address, error) {
+	addresses := make([]*mail.Address, 0, len(list))
+	for _, s := range list {
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", s, err)
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses, nil
+}
 
-		// End boundary
-		body.WriteString(fmt.Sprintf("--%s--\r\n", boundary))
-	} else {
-		// Plain text only
-		body.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
-		body.WriteString("\r\n")
-		body.WriteString(msg.Body)
+// formatAddressList formats addresses for a header, encoding non-ASCII display names
+func formatAddressList(list []string) string {
+	formatted := make([]string, 0, len(list))
+	for _, s := range list {
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			// Send validates addresses first; keep an unparsable one as it is, on one line
+			formatted = append(formatted, encodeHeader(s))
+			continue
+		}
+		formatted = append(formatted, formatAddress(a))
 	}
+	return strings.Join(formatted, ", ")
+}
 
-	return []byte(body.String())
+// formatAddress formats an address for a header. A display name is quoted or, when it is not
+// ASCII, encoded as an RFC 2047 encoded-word.
+func formatAddress(a *mail.Address) string {
+	if a.Name == "" {
+		return a.Address
+	}
+	return a.String()
 }
 
+// encodeHeader puts a header value on one line and encodes it as RFC 2047 encoded-words when
+// it is not ASCII
+func encodeHeader(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	return mime.QEncoding.Encode("UTF-8", value)
+}
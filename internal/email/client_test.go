@@ -1,6 +1,16 @@
 package email
 
 import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -181,6 +191,37 @@ func TestClient_BuildMessage(t *testing.T) {
 			wantErr: true,
 			errMsg:  "body is required",
 		},
+		{
+			name: "HTML body only",
+			message: Message{
+				To:       []string{"recipient@example.com"},
+				Subject:  "Test Subject",
+				HTMLBody: "<p>HTML body</p>",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid cc address",
+			message: Message{
+				To:      []string{"recipient@example.com"},
+				Cc:      []string{"not an address"},
+				Subject: "Test Subject",
+				Body:    "Test Body",
+			},
+			wantErr: true,
+			errMsg:  `invalid address "not an address": mail: no angle-addr`,
+		},
+		{
+			name: "inline image without HTML body",
+			message: Message{
+				To:          []string{"recipient@example.com"},
+				Subject:     "Test Subject",
+				Body:        "Test Body",
+				Attachments: []Attachment{{Filename: "logo.png", Data: []byte("png"), ContentID: "logo"}},
+			},
+			wantErr: true,
+			errMsg:  "inline image logo.png requires an HTML body",
+		},
 		{
 			name: "with HTML body",
 			message: Message{
@@ -207,3 +248,212 @@ func TestClient_BuildMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestNewClient_FromName(t *testing.T) {
+	client, err := NewClient(Config{
+		Host:     "smtp.example.com",
+		Port:     587,
+		From:     "noreply@example.com",
+		FromName: "Align Logística",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	msg := parseEmail(t, client.buildEmailBody(Message{
+		To:      []string{"recipient@example.com"},
+		Subject: "Test",
+		Body:    "Body",
+	}))
+
+	from, err := msg.Header.AddressList("From")
+	if err != nil {
+		t.Fatalf("Failed to parse From: %v", err)
+	}
+	if from[0].Name != "Align Logística" || from[0].Address != "noreply@example.com" {
+		t.Errorf("From = %v", from[0])
+	}
+	if raw := msg.Header.Get("From"); !strings.HasPrefix(strings.ToUpper(raw), "=?UTF-8?") {
+		t.Errorf("From display name is not encoded: %s", raw)
+	}
+}
+
+func TestClient_buildEmailBody_Headers(t *testing.T) {
+	client, err := NewClient(Config{Host: "smtp.example.com", Port: 587, From: "sender@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	raw := client.buildEmailBody(Message{
+		To:       []string{"José García <jose@example.com>", "plain@example.com"},
+		Cc:       []string{"Team Lead <lead@example.com>"},
+		Bcc:      []string{"audit@example.com"},
+		ReplyTo:  "Logistics <logistics@example.com>",
+		Subject:  "Entrega confirmada – Ñandú",
+		Body:     "Body",
+		HTMLBody: "<p>Body</p>",
+	})
+	msg := parseEmail(t, raw)
+
+	for _, line := range strings.Split(string(raw[:bytes.Index(raw, []byte("\r\n\r\n"))]), "\r\n") {
+		for _, r := range line {
+			if r > 127 {
+				t.Fatalf("header line is not ASCII: %s", line)
+			}
+		}
+	}
+
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("Failed to decode subject: %v", err)
+	}
+	if subject != "Entrega confirmada – Ñandú" {
+		t.Errorf("Subject = %q", subject)
+	}
+
+	to, err := msg.Header.AddressList("To")
+	if err != nil {
+		t.Fatalf("Failed to parse To: %v", err)
+	}
+	if len(to) != 2 || to[0].Name != "José García" || to[1].Address != "plain@example.com" {
+		t.Errorf("To = %v", to)
+	}
+	if cc, _ := msg.Header.AddressList("Cc"); len(cc) != 1 || cc[0].Address != "lead@example.com" {
+		t.Errorf("Cc = %v", cc)
+	}
+	if replyTo, _ := msg.Header.AddressList("Reply-To"); len(replyTo) != 1 || replyTo[0].Address != "logistics@example.com" {
+		t.Errorf("Reply-To = %v", replyTo)
+	}
+	if strings.Contains(string(raw), "audit@example.com") {
+		t.Error("Bcc recipient appears in the message")
+	}
+}
+
+func TestClient_buildEmailBody_MessageID(t *testing.T) {
+	client, err := NewClient(Config{Host: "smtp.example.com", Port: 587, From: "Laptop Tracking <sender@example.com>"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	message := Message{To: []string{"to@example.com"}, Subject: "Subject", Body: "Body"}
+	first := parseEmail(t, client.buildEmailBody(message)).Header.Get("Message-ID")
+	second := parseEmail(t, client.buildEmailBody(message)).Header.Get("Message-ID")
+
+	if !regexp.MustCompile(`^<[0-9a-f]{32}@example\.com>$`).MatchString(first) {
+		t.Errorf("Message-ID = %q, want <random@example.com>", first)
+	}
+	if first == second {
+		t.Errorf("Message-ID %q is not unique", first)
+	}
+}
+
+func TestClient_buildEmailBody_Parts(t *testing.T) {
+	client, err := NewClient(Config{Host: "smtp.example.com", Port: 587, From: "sender@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	msg := parseEmail(t, client.buildEmailBody(Message{
+		To:       []string{"recipient@example.com"},
+		Subject:  "Reception report",
+		HTMLBody: `<p>See the <b>photo</b>:</p><img src="cid:photo1" alt="Photo">`,
+		Attachments: []Attachment{
+			{Filename: "photo.jpg", Data: []byte("jpeg data"), ContentID: "photo1"},
+			{Filename: "report.pdf", Data: bytes.Repeat([]byte("pdf"), 100)},
+		},
+	}))
+
+	// multipart/mixed: the message and the PDF
+	mixed := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(mixed) != 2 {
+		t.Fatalf("got %d parts in multipart/mixed, want 2", len(mixed))
+	}
+
+	pdf := mixed[1]
+	if pdf.header.Get("Content-Type") != "application/pdf" {
+		t.Errorf("attachment Content-Type = %q", pdf.header.Get("Content-Type"))
+	}
+	if pdf.header.Get("Content-Disposition") != "attachment; filename=report.pdf" {
+		t.Errorf("attachment Content-Disposition = %q", pdf.header.Get("Content-Disposition"))
+	}
+	if string(pdf.body) != strings.Repeat("pdf", 100) {
+		t.Errorf("attachment body = %q", pdf.body)
+	}
+
+	// multipart/alternative: plain text generated from the HTML, and the HTML with its image
+	alternative := readParts(t, mixed[0].header.Get("Content-Type"), bytes.NewReader(mixed[0].body))
+	if len(alternative) != 2 {
+		t.Fatalf("got %d parts in multipart/alternative, want 2", len(alternative))
+	}
+	if got := string(alternative[0].body); got != "See the photo:\r\n\r\nPhoto" {
+		t.Errorf("plain text = %q", got)
+	}
+
+	related := readParts(t, alternative[1].header.Get("Content-Type"), bytes.NewReader(alternative[1].body))
+	if len(related) != 2 {
+		t.Fatalf("got %d parts in multipart/related, want 2", len(related))
+	}
+	if !strings.HasPrefix(related[0].header.Get("Content-Type"), "text/html") {
+		t.Errorf("first related part is %q", related[0].header.Get("Content-Type"))
+	}
+	image := related[1]
+	if image.header.Get("Content-Id") != "<photo1>" {
+		t.Errorf("Content-ID = %q", image.header.Get("Content-Id"))
+	}
+	if image.header.Get("Content-Disposition") != "inline; filename=photo.jpg" {
+		t.Errorf("inline Content-Disposition = %q", image.header.Get("Content-Disposition"))
+	}
+	if string(image.body) != "jpeg data" {
+		t.Errorf("inline body = %q", image.body)
+	}
+}
+
+// parseEmail parses a built email
+func parseEmail(t *testing.T, raw []byte) *mail.Message {
+	t.Helper()
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Failed to parse email: %v", err)
+	}
+	return msg
+}
+
+// decodedPart is a part of a multipart entity with its transfer encoding decoded
+type decodedPart struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// readParts reads the parts of a multipart entity
+func readParts(t *testing.T, contentType string, body io.Reader) []decodedPart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("Content-Type %q is not multipart", contentType)
+	}
+
+	var parts []decodedPart
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("Failed to read part: %v", err)
+		}
+		var content io.Reader = p
+		switch p.Header.Get("Content-Transfer-Encoding") {
+		case "base64":
+			content = base64.NewDecoder(base64.StdEncoding, p)
+		case "quoted-printable":
+			content = quotedprintable.NewReader(p)
+		}
+		data, err := io.ReadAll(content)
+		if err != nil {
+			t.Fatalf("Failed to decode part: %v", err)
+		}
+		parts = append(parts, decodedPart{header: p.Header, body: data})
+	}
+}
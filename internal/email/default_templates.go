@@ -0,0 +1,802 @@
+package email
+
+// baseTemplate is the layout every HTML body is rendered into as its "content" template.
+// It holds the styling, so a template edited in the UI only contains the message itself.
+const baseTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Subject}}</title>
+    <style>
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, 'Helvetica Neue', Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+            background-color: #f5f5f5;
+        }
+        .email-container {
+            background-color: #ffffff;
+            padding: 40px;
+            border-radius: 8px;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        .header {
+            border-bottom: 3px solid #0052CC;
+            padding-bottom: 20px;
+            margin-bottom: 30px;
+        }
+        .header h1 {
+            color: #0052CC;
+            margin: 0;
+            font-size: 24px;
+        }
+        .content {
+            margin-bottom: 30px;
+        }
+        .button {
+            display: inline-block;
+            padding: 12px 24px;
+            background-color: #0052CC;
+            color: #ffffff !important;
+            text-decoration: none;
+            border-radius: 4px;
+            margin: 20px 0;
+            font-weight: 600;
+        }
+        .button:hover {
+            background-color: #0747A6;
+        }
+        .info-box {
+            background-color: #F4F5F7;
+            padding: 20px;
+            border-radius: 4px;
+            margin: 20px 0;
+        }
+        .info-box h3 {
+            margin-top: 0;
+            color: #0052CC;
+        }
+        .info-row {
+            margin: 10px 0;
+        }
+        .info-label {
+            font-weight: 600;
+            color: #172B4D;
+        }
+        .footer {
+            margin-top: 40px;
+            padding-top: 20px;
+            border-top: 1px solid #DFE1E6;
+            font-size: 12px;
+            color: #6B778C;
+            text-align: center;
+        }
+        .warning {
+            background-color: #FFF3CD;
+            border-left: 4px solid #FFC107;
+            padding: 15px;
+            margin: 20px 0;
+        }
+        .success {
+            background-color: #D4EDDA;
+            border-left: 4px solid #28A745;
+            padding: 15px;
+            margin: 20px 0;
+        }
+    </style>
+</head>
+<body>
+    <div class="email-container">
+        {{template "content" .}}
+        <div class="footer">
+            <p>This is an automated message from Align.</p>
+            <p>© {{.Year}} BairesDev. All rights reserved.</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+// defaultTemplates are the built-in templates. Each one is seeded as the first version of its
+// template in the database, and restoring the default saves it as a new version.
+var defaultTemplates = map[string]TemplateContent{
+	// Magic Link Template
+	"magic_link": {
+		Subject: "Access Your Form - {{.FormType}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>🔗 Access Your Form</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.RecipientName}},</p>
+            <p>You've been granted access to complete the {{.FormType}} form. Click the button below to get started:</p>
+            <p style="text-align: center;">
+                <a href="{{.MagicLink}}" class="button">Access Form</a>
+            </p>
+            <div class="warning">
+                <strong>⚠️ Security Notice:</strong> This link is valid for one use only and expires on <strong>{{.ExpiresAtFormatted}}</strong>. Do not share this link with others.
+            </div>
+            <p>If you didn't request this form, please ignore this email.</p>
+        </div>
+    `,
+	},
+	// Address Confirmation Template
+	"address_confirmation": {
+		Subject: "Confirm Your Delivery Address",
+		HTMLBody: `
+        <div class="header">
+            <h1>📍 Confirm Your Delivery Address</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.EngineerName}},</p>
+            <p>We're preparing to ship configured hardware for the <strong>{{.ProjectName}}</strong> project. The expected delivery date is <strong>{{.ExpectedDate}}</strong>.</p>
+            <p>Please confirm or update your delivery address to ensure successful delivery:</p>
+            <p style="text-align: center;">
+                <a href="{{.ConfirmationURL}}" class="button">Confirm Address</a>
+            </p>
+            <div class="info-box">
+                <h3>What You Need to Do:</h3>
+                <ol>
+                    <li>Click the button above</li>
+                    <li>Verify your current address is correct</li>
+                    <li>Update if necessary</li>
+                    <li>Submit the confirmation</li>
+                </ol>
+            </div>
+            <p>If you have any questions, please contact your project manager.</p>
+        </div>
+    `,
+	},
+	// Pickup Confirmation Template
+	"pickup_confirmation": {
+		Subject: "Pickup Confirmation - {{.ConfirmationCode}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>✅ Pickup Request Confirmed</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.ClientName}},</p>
+            <div class="success">
+                Thank you for completing the hardware shipping form. Your pickup has been scheduled!
+            </div>
+            <div class="info-box">
+                <h3>📦 Pickup Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Confirmation Code:</span> {{.ConfirmationCode}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Pickup Date:</span> {{.PickupDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Time Slot:</span> {{.PickupTimeSlot}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Number of Devices:</span> {{.NumberOfDevices}}
+                </div>
+                {{if .TrackingNumber}}
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>📋 What Happens Next:</h3>
+                <ol>
+                    <li>You'll receive UPS shipping labels via email</li>
+                    <li>Print and attach the labels to your package</li>
+                    <li>Have the device(s) ready for pickup</li>
+                    <li>The courier will collect the package during the scheduled time slot</li>
+                </ol>
+            </div>
+            <p>If you need to make any changes, please contact us immediately.</p>
+        </div>
+    `,
+	},
+	// Pickup Scheduled Notification Template
+	"pickup_scheduled": {
+		Subject: "Pickup Scheduled - Hardware Shipment",
+		HTMLBody: `
+        <div class="header">
+            <h1>📅 Pickup Has Been Scheduled</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.ContactName}},</p>
+            <div class="success">
+                Great news! Your hardware pickup has been officially scheduled.
+            </div>
+            <div class="info-box">
+                <h3>📦 Pickup Details</h3>
+                {{if .TrackingNumber}}
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                {{end}}
+                <div class="info-row">
+                    <span class="info-label">Scheduled Pickup Date:</span> {{.PickupDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Time Slot:</span> {{.PickupTimeSlot}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Pickup Address:</span> {{.PickupAddress}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Company:</span> {{.ClientCompany}}
+                </div>
+            </div>
+            <div class="info-box">
+                <h3>📋 Important Reminders:</h3>
+                <ol>
+                    <li>Please have the device(s) packaged and ready for pickup</li>
+                    <li>UPS shipping labels will be sent to you separately</li>
+                    <li>Ensure all labels are securely attached to the package</li>
+                    <li>Our courier will arrive during the specified time slot</li>
+                    <li>You'll receive tracking updates once the package is picked up</li>
+                </ol>
+            </div>
+            <div class="warning">
+                <strong>⚠️ Need to Make Changes?</strong> If you need to reschedule or modify the pickup, please contact our logistics team immediately at <a href="mailto:logistics@bairesdev.com">logistics@bairesdev.com</a>
+            </div>
+            <p>Thank you for your cooperation!</p>
+        </div>
+    `,
+	},
+	// Warehouse Pre-Alert Template
+	"warehouse_pre_alert": {
+		Subject: "Incoming Shipment Alert - {{.TrackingNumber}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>📬 Incoming Shipment Alert</h1>
+        </div>
+        <div class="content">
+            <p>Hello Warehouse Team,</p>
+            <p>Please be advised that a hardware shipment is scheduled for delivery to our facility.</p>
+            <div class="info-box">
+                <h3>📦 Shipment Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Expected Delivery:</span> {{.ExpectedDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Shipper:</span> {{.ShipperName}}
+                </div>
+                {{if .IsSingleShipment}}
+                <div class="info-box" style="border: 2px solid #2196F3; background-color: #f0f7ff; margin-top: 15px;">
+                    <h3>💻 Laptop Details</h3>
+                    {{if .SerialNumber}}
+                    <div class="info-row" style="font-size: 1.1em; font-weight: bold; color: #1976D2; margin-bottom: 10px;">
+                        <span class="info-label">Serial Number:</span> <span style="font-family: monospace; background-color: #fff; padding: 4px 8px; border-radius: 4px;">{{.SerialNumber}}</span>
+                    </div>
+                    {{end}}
+                    {{if .Brand}}
+                    <div class="info-row">
+                        <span class="info-label">Brand:</span> {{.Brand}}
+                    </div>
+                    {{end}}
+                    {{if .Model}}
+                    <div class="info-row">
+                        <span class="info-label">Model:</span> {{.Model}}
+                    </div>
+                    {{end}}
+                    {{if .CPU}}
+                    <div class="info-row">
+                        <span class="info-label">CPU:</span> {{.CPU}}
+                    </div>
+                    {{end}}
+                    {{if .RAMGB}}
+                    <div class="info-row">
+                        <span class="info-label">RAM:</span> {{.RAMGB}}
+                    </div>
+                    {{end}}
+                    {{if .SSDGB}}
+                    <div class="info-row">
+                        <span class="info-label">Storage:</span> {{.SSDGB}}
+                    </div>
+                    {{end}}
+                    {{if .SKU}}
+                    <div class="info-row">
+                        <span class="info-label">SKU:</span> {{.SKU}}
+                    </div>
+                    {{end}}
+                </div>
+                {{else if .IsBulkShipment}}
+                <div class="info-box" style="border: 2px solid #FF9800; background-color: #fff8f0; margin-top: 15px;">
+                    <h3>📦 Bulk Shipment Information</h3>
+                    {{if .LaptopCount}}
+                    <div class="info-row">
+                        <span class="info-label">Number of Laptops:</span> <strong>{{.LaptopCount}}</strong>
+                    </div>
+                    {{end}}
+                    {{if .NumberOfBoxes}}
+                    <div class="info-row">
+                        <span class="info-label">Number of Boxes:</span> <strong>{{.NumberOfBoxes}}</strong>
+                    </div>
+                    {{end}}
+                    {{if .BulkDescription}}
+                    <div class="info-row">
+                        <span class="info-label">Description:</span> {{.BulkDescription}}
+                    </div>
+                    {{end}}
+                </div>
+                {{else}}
+                <div class="info-row">
+                    <span class="info-label">Contents:</span> {{.DeviceDescription}}
+                </div>
+                {{end}}
+                {{if .ProjectName}}
+                <div class="info-row">
+                    <span class="info-label">Project:</span> {{.ProjectName}}
+                </div>
+                {{end}}
+                {{if .Packages}}
+                <div class="info-box" style="margin-top: 15px;">
+                    <h3>Packages</h3>
+                    {{range .Packages}}
+                    <div class="info-row">
+                        <span class="info-label">Package {{.PackageNumber}}:</span>
+                        {{if .TrackingNumber}}{{.CourierName}} {{if .TrackingURL}}<a href="{{.TrackingURL}}">{{.TrackingNumber}}</a>{{else}}{{.TrackingNumber}}{{end}}{{else}}No tracking number yet{{end}}
+                        {{if .HasDimensions}} | {{.DimensionsLabel}}{{end}}{{if .WeightLb}} | {{.WeightLb}} lb{{end}}
+                        {{if .Laptops}} | {{len .Laptops}} laptop(s){{end}}
+                    </div>
+                    {{end}}
+                </div>
+                {{end}}
+            </div>
+            {{if .TrackingURL}}
+            <p style="text-align: center;">
+                <a href="{{.TrackingURL}}" class="button">Track Shipment</a>
+            </p>
+            {{end}}
+            <div class="info-box">
+                <h3>✅ Action Required</h3>
+                <p>Upon receipt of this package, please:</p>
+                <ol>
+                    <li>Verify the package condition and contents</li>
+                    <li>Complete the Hardware Reception Report</li>
+                    <li>Upload photos of the device</li>
+                    <li>Submit the report immediately</li>
+                </ol>
+            </div>
+            <p>Please confirm receipt of this notification and contact logistics immediately if there are any issues.</p>
+        </div>
+    `,
+	},
+	// Release Notification Template
+	"release_notification": {
+		Subject: "Hardware Release for Pickup - {{.TrackingNumber}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>🚚 Hardware Release for Pickup</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.CourierName}},</p>
+            <p>Hardware has been released from our warehouse and is ready for pickup and delivery to the engineer.</p>
+            <div class="info-box">
+                <h3>📦 Pickup Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Pickup Date:</span> {{.PickupDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Time Slot:</span> {{.PickupTimeSlot}}
+                </div>
+            </div>
+            <div class="info-box">
+                <h3>📍 Pickup Location</h3>
+                <div class="info-row">
+                    <span class="info-label">Address:</span> {{.WarehouseAddress}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Contact Person:</span> {{.ContactPerson}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Contact Phone:</span> {{.ContactPhone}}
+                </div>
+            </div>
+            <div class="info-box">
+                <h3>📋 Device Information</h3>
+                <div class="info-row">
+                    <span class="info-label">Serial Number:</span> {{.DeviceSerialNumber}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Deliver To:</span> {{.EngineerName}}
+                </div>
+            </div>
+            <p>Please confirm pickup and update the tracking status once the device is collected.</p>
+        </div>
+    `,
+	},
+	// Delivery Confirmation Template
+	"delivery_confirmation": {
+		Subject: "Device Delivered Successfully",
+		HTMLBody: `
+        <div class="header">
+            <h1>✅ Device Delivered Successfully</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.EngineerName}},</p>
+            <div class="success">
+                Your device has been successfully delivered! Welcome to the team!
+            </div>
+            <div class="info-box">
+                <h3>📦 Delivery Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Delivery Date:</span> {{.DeliveryDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Device Model:</span> {{.DeviceModel}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Serial Number:</span> {{.DeviceSerialNumber}}
+                </div>
+                {{if .TrackingNumber}}
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                {{end}}
+                {{if .ProjectName}}
+                <div class="info-row">
+                    <span class="info-label">Project:</span> {{.ProjectName}}
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>📋 Next Steps</h3>
+                <ol>
+                    <li>Inspect the device for any shipping damage</li>
+                    <li>Set up your device following the included instructions</li>
+                    <li>Install required software</li>
+                    <li>Contact IT support if you encounter any issues</li>
+                </ol>
+            </div>
+            <p>If you have any questions or concerns about your device, please contact your project manager.</p>
+        </div>
+    `,
+	},
+	// Shipment Picked Up Template
+	"shipment_picked_up": {
+		Subject: "Shipment Picked Up - {{.TrackingNumber}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>📦 Shipment Picked Up</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.ContactName}},</p>
+            <div class="success">
+                Great news! Your shipment has been picked up and is now on its way.
+            </div>
+            <div class="info-box">
+                <h3>📋 Shipment Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Courier:</span> {{.CourierName}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Picked Up Date:</span> {{.PickedUpDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Expected Arrival:</span> {{.ExpectedArrival}}
+                </div>
+                {{if .TrackingURL}}
+                <div class="info-row">
+                    <span class="info-label">Track Your Shipment:</span> <a href="{{.TrackingURL}}" class="button">Track Now</a>
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>📬 What's Next?</h3>
+                <p>Your shipment is now in transit. You can track its progress using the tracking number above. We'll notify you once it arrives at the warehouse.</p>
+            </div>
+            <p>If you have any questions about your shipment, please don't hesitate to contact us.</p>
+        </div>
+    `,
+	},
+	// Pickup Form Submitted to Logistics Template
+	"pickup_form_submitted_logistics": {
+		Subject: "New Pickup Form Submitted - {{.ClientCompany}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>📋 New Pickup Form Submitted</h1>
+        </div>
+        <div class="content">
+            <p>Hello Logistics Team,</p>
+            <div class="info-box">
+                <p>A new pickup form has been submitted and requires your attention.</p>
+            </div>
+            <div class="info-box">
+                <h3>📦 Shipment Information</h3>
+                <div class="info-row">
+                    <span class="info-label">Shipment ID:</span> #{{.ShipmentID}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Shipment Type:</span> {{.ShipmentType}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Client Company:</span> {{.ClientCompany}}
+                </div>
+                {{if .JiraTicket}}
+                <div class="info-row">
+                    <span class="info-label">JIRA Ticket:</span> {{.JiraTicket}}
+                </div>
+                {{end}}
+                <div class="info-row">
+                    <span class="info-label">Number of Devices:</span> {{.NumberOfDevices}}
+                </div>
+            </div>
+            <div class="info-box">
+                <h3>👤 Contact Information</h3>
+                <div class="info-row">
+                    <span class="info-label">Contact Name:</span> {{.ContactName}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Email:</span> {{.ContactEmail}}
+                </div>
+                {{if .ContactPhone}}
+                <div class="info-row">
+                    <span class="info-label">Phone:</span> {{.ContactPhone}}
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>📍 Pickup Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Pickup Address:</span> {{.PickupAddress}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Pickup Date:</span> {{.PickupDate}}
+                </div>
+            </div>
+            {{if .ShipmentURL}}
+            <div style="text-align: center; margin: 30px 0;">
+                <a href="{{.ShipmentURL}}" class="button">View Shipment Details</a>
+            </div>
+            {{end}}
+            <p>Please review the pickup form and schedule the pickup accordingly.</p>
+        </div>
+    `,
+	},
+	// Engineer Delivery Notification to Client Template
+	"engineer_delivery_notification_to_client": {
+		Subject: "Device Delivered to Engineer - {{.TrackingNumber}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>✅ Device Delivered to Engineer</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.ContactName}},</p>
+            <div class="success">
+                Great news! Your shipment has been successfully delivered to the engineer.
+            </div>
+            <div class="info-box">
+                <h3>📦 Delivery Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Engineer Name:</span> {{.EngineerName}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Delivery Date:</span> {{.DeliveryDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                {{if .JiraTicket}}
+                <div class="info-row">
+                    <span class="info-label">JIRA Ticket:</span> {{.JiraTicket}}
+                </div>
+                {{end}}
+                {{if .ProjectName}}
+                <div class="info-row">
+                    <span class="info-label">Project:</span> {{.ProjectName}}
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>🎉 What's Next?</h3>
+                <p>The engineer will now set up the device and begin work on the project. You'll be notified of any updates or issues.</p>
+            </div>
+            <p>If you have any questions, please don't hesitate to contact us.</p>
+        </div>
+    `,
+	},
+	// In Transit to Engineer Template
+	"in_transit_to_engineer": {
+		Subject: "Device In Transit - Expected Arrival {{.ETA}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>🚚 Device In Transit to You</h1>
+        </div>
+        <div class="content">
+            <p>Hello {{.EngineerName}},</p>
+            <div class="info-box">
+                <p>Your device is on its way! We wanted to let you know so you can prepare for its arrival.</p>
+            </div>
+            <div class="info-box">
+                <h3>📦 Shipment Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Courier:</span> {{.CourierName}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Expected Arrival (ETA):</span> {{.ETA}}
+                </div>
+            </div>
+            <div class="info-box" style="border: 2px solid #4CAF50; background-color: #f0f9f0;">
+                <h3>💻 Laptop Details</h3>
+                {{if .SerialNumber}}
+                <div class="info-row" style="font-size: 1.1em; font-weight: bold; color: #2E7D32; margin-bottom: 10px;">
+                    <span class="info-label">Serial Number:</span> <span style="font-family: monospace; background-color: #fff; padding: 4px 8px; border-radius: 4px;">{{.SerialNumber}}</span>
+                </div>
+                {{end}}
+                {{if .DeviceModel}}
+                <div class="info-row">
+                    <span class="info-label">Model:</span> {{.DeviceModel}}
+                </div>
+                {{end}}
+                {{if .Brand}}
+                <div class="info-row">
+                    <span class="info-label">Brand:</span> {{.Brand}}
+                </div>
+                {{end}}
+                {{if .CPU}}
+                <div class="info-row">
+                    <span class="info-label">CPU:</span> {{.CPU}}
+                </div>
+                {{end}}
+                {{if .RAMGB}}
+                <div class="info-row">
+                    <span class="info-label">RAM:</span> {{.RAMGB}}
+                </div>
+                {{end}}
+                {{if .SSDGB}}
+                <div class="info-row">
+                    <span class="info-label">Storage:</span> {{.SSDGB}}
+                </div>
+                {{end}}
+                {{if .SKU}}
+                <div class="info-row">
+                    <span class="info-label">SKU:</span> {{.SKU}}
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>📋 What to Expect</h3>
+                <ul>
+                    <li>The device will arrive at your specified delivery address</li>
+                    <li>Please be available to receive the package</li>
+                    <li>Inspect the device for any shipping damage upon arrival</li>
+                    <li>Contact us immediately if there are any issues</li>
+                </ul>
+            </div>
+            {{if .ContactInfo}}
+            <div class="info-box">
+                <h3>📞 Contact Information</h3>
+                <p>{{.ContactInfo}}</p>
+            </div>
+            {{end}}
+            {{if .ShipmentURL}}
+            <div style="text-align: center; margin: 30px 0;">
+                <a href="{{.ShipmentURL}}" class="button">Track Shipment</a>
+            </div>
+            {{end}}
+            <p>We'll notify you once the device has been delivered. Thank you for your patience!</p>
+        </div>
+    `,
+	},
+	// Reception Report Approval Request Template
+	"reception_report_approval_request": {
+		Subject: "Reception Report Requires Approval - {{.SerialNumber}}",
+		HTMLBody: `
+        <div class="header">
+            <h1>📋 Reception Report Requires Approval</h1>
+        </div>
+        <div class="content">
+            <p>Hello Logistics Team,</p>
+            <div class="info-box">
+                <p>A new reception report has been submitted by the warehouse and requires your review and approval.</p>
+            </div>
+            <div class="info-box">
+                <h3>📦 Shipment Information</h3>
+                {{if .ShipmentID}}
+                <div class="info-row">
+                    <span class="info-label">Shipment ID:</span> #{{.ShipmentID}}
+                </div>
+                {{end}}
+                {{if .TrackingNumber}}
+                <div class="info-row">
+                    <span class="info-label">Tracking Number:</span> {{.TrackingNumber}}
+                </div>
+                {{end}}
+                {{if .ClientCompany}}
+                <div class="info-row">
+                    <span class="info-label">Client Company:</span> {{.ClientCompany}}
+                </div>
+                {{end}}
+                {{if .SerialNumber}}
+                <div class="info-row" style="font-weight: bold;">
+                    <span class="info-label">Serial Number:</span> {{.SerialNumber}}
+                </div>
+                {{end}}
+            </div>
+            <div class="info-box">
+                <h3>📅 Reception Details</h3>
+                <div class="info-row">
+                    <span class="info-label">Received Date:</span> {{.ReceivedDate}}
+                </div>
+                <div class="info-row">
+                    <span class="info-label">Submitted By:</span> {{.WarehouseUser}}
+                </div>
+                {{if .Notes}}
+                <div class="info-row">
+                    <span class="info-label">Notes:</span> {{.Notes}}
+                </div>
+                {{end}}
+            </div>
+            {{if .PhotoURLs}}
+            <div class="info-box">
+                <h3>📸 Photos</h3>
+                <p>The following photos have been uploaded:</p>
+                <ul>
+                    {{range .PhotoURLs}}
+                    <li><a href="{{.}}" target="_blank">{{.}}</a></li>
+                    {{end}}
+                </ul>
+            </div>
+            {{end}}
+            {{if .ReportURL}}
+            <div style="text-align: center; margin: 30px 0;">
+                <a href="{{.ReportURL}}" class="button">View Reception Report</a>
+            </div>
+            {{end}}
+            <p>Please review the reception report and approve it if everything is in order.</p>
+        </div>
+    `,
+	},
+	// User Invitation Template
+	"user_invitation": {
+		Subject: "You're invited to Align",
+		HTMLBody: `
+        <div class="header">
+            <h1>👋 You're Invited to Align</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>{{if .InvitedBy}}{{.InvitedBy}} invited you{{else}}You have been invited{{end}} to Align as a <strong>{{.Role}}</strong> user with the email <strong>{{.RecipientEmail}}</strong>.</p>
+            <p>Choose a password to activate your account:</p>
+            <p style="text-align: center;">
+                <a href="{{.SetPasswordURL}}" class="button">Set Your Password</a>
+            </p>
+            <div class="warning">
+                <strong>⚠️ Security Notice:</strong> This link is valid for one use only and expires on <strong>{{.ExpiresAtFormatted}}</strong>. Do not share this link with others.
+            </div>
+            <p>If you weren't expecting this invitation, please ignore this email.</p>
+        </div>
+    `,
+	},
+	// Password Reset Template
+	"password_reset": {
+		Subject: "Reset Your Align Password",
+		HTMLBody: `
+        <div class="header">
+            <h1>🔑 Reset Your Password</h1>
+        </div>
+        <div class="content">
+            <p>Hello,</p>
+            <p>We received a request to reset the password of the Align account <strong>{{.RecipientEmail}}</strong>. Click the button below to choose a new one:</p>
+            <p style="text-align: center;">
+                <a href="{{.ResetURL}}" class="button">Reset Password</a>
+            </p>
+            <div class="warning">
+                <strong>⚠️ Security Notice:</strong> This link is valid for one use only and expires on <strong>{{.ExpiresAtFormatted}}</strong>. Setting a new password signs you out of all devices.
+            </div>
+            <p>If you didn't request a password reset, you can ignore this email. Your password will not change.</p>
+        </div>
+    `,
+	},
+}
@@ -5,26 +5,41 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/lib/pq"
+
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
 	"github.com/yourusername/laptop-tracking-system/internal/config"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
 )
 
+// DeliveryPhotoDir is where the delivery form stores its photos (see handlers.DeliveryUploadDir)
+var DeliveryPhotoDir = "./uploads/delivery"
+
+// maxDeliveryPhotoBytes caps the photos attached to a delivery confirmation, to stay under the
+// message size limits of mail servers
+const maxDeliveryPhotoBytes = 10 * 1024 * 1024
+
 // Notifier handles sending email notifications for various events
 type Notifier struct {
 	client    *Client
 	templates *EmailTemplates
 	db        *sql.DB
 	config    *config.SMTPConfig // Optional config for default emails
+	preview   *templatePreview   // Set on copies that render a template preview instead of sending
+	baseURL   string             // Address of the application, used in account links
 }
 
 // NewNotifier creates a new email notifier instance
 func NewNotifier(client *Client, db *sql.DB) *Notifier {
 	return &Notifier{
 		client:    client,
-		templates: NewEmailTemplates(),
+		templates: NewEmailTemplatesWithDB(db),
 		db:        db,
 		config:    nil, // Config is optional for backward compatibility
 	}
@@ -34,12 +49,18 @@ func NewNotifier(client *Client, db *sql.DB) *Notifier {
 func NewNotifierWithConfig(client *Client, db *sql.DB, cfg *config.SMTPConfig) *Notifier {
 	return &Notifier{
 		client:    client,
-		templates: NewEmailTemplates(),
+		templates: NewEmailTemplatesWithDB(db),
 		db:        db,
 		config:    cfg,
 	}
 }
 
+// WithBaseURL sets the address of the application used in the links of account emails
+func (n *Notifier) WithBaseURL(baseURL string) *Notifier {
+	n.baseURL = strings.TrimSuffix(baseURL, "/")
+	return n
+}
+
 // SendPickupConfirmation sends a pickup confirmation email to the client
 func (n *Notifier) SendPickupConfirmation(ctx context.Context, shipmentID int64) error {
 	// Fetch shipment details
@@ -116,7 +137,7 @@ func (n *Notifier) SendPickupConfirmation(ctx context.Context, shipmentID int64)
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("pickup_confirmation", data)
+	rendered, err := n.render(ctx, "pickup_confirmation", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -124,12 +145,12 @@ func (n *Notifier) SendPickupConfirmation(ctx context.Context, shipmentID int64)
 	// Send email
 	message := Message{
 		To:       []string{clientEmail},
-		Subject:  n.templates.GetSubject("pickup_confirmation", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -244,7 +265,7 @@ func (n *Notifier) SendPickupScheduledNotification(ctx context.Context, shipment
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("pickup_scheduled", data)
+	rendered, err := n.render(ctx, "pickup_scheduled", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -252,12 +273,12 @@ func (n *Notifier) SendPickupScheduledNotification(ctx context.Context, shipment
 	// Send email
 	message := Message{
 		To:       []string{contactEmail},
-		Subject:  n.templates.GetSubject("pickup_scheduled", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -269,6 +290,21 @@ func (n *Notifier) SendPickupScheduledNotification(ctx context.Context, shipment
 	return nil
 }
 
+// courierTrackingURL returns the tracking link from the courier's record.
+// Returns an empty string if there is no tracking number or the courier has no tracking link.
+func (n *Notifier) courierTrackingURL(courierName, trackingNumber string) string {
+	if trackingNumber == "" {
+		return ""
+	}
+	couriers, err := models.LoadCourierDirectory(n.db)
+	if err != nil {
+		// Non-critical, fall back to the built-in couriers
+		fmt.Printf("Warning: failed to load couriers: %v\n", err)
+		couriers = models.DefaultCourierDirectory()
+	}
+	return couriers.TrackingURL(courierName, trackingNumber)
+}
+
 // SendWarehousePreAlert sends a pre-alert email to warehouse about incoming shipment
 func (n *Notifier) SendWarehousePreAlert(ctx context.Context, shipmentID int64) error {
 	// Fetch shipment details including shipment type
@@ -375,13 +411,22 @@ func (n *Notifier) SendWarehousePreAlert(ctx context.Context, shipmentID int64)
 		ShipperCompany:    shipperCompany,
 		DeviceDescription: fmt.Sprintf("%d device(s)", shipment.LaptopCount),
 		ProjectName:       "", // Can be added if needed
-		TrackingURL:       fmt.Sprintf("https://www.ups.com/track?tracknum=%s", shipment.TrackingNumber.String),
+		TrackingURL:       n.courierTrackingURL(shipment.CourierName.String, shipment.TrackingNumber.String),
 		IsSingleShipment:  isSingleShipment,
 		IsBulkShipment:    isBulkShipment,
 		LaptopCount:       shipment.LaptopCount,
 		NumberOfBoxes:     0, // Will be set for bulk shipments below
 	}
 
+	// Each package is listed with its own tracking link
+	packages, err := models.GetShipmentPackages(ctx, n.db, shipmentID)
+	if err != nil {
+		// Non-critical, the alert falls back to the shipment's tracking number
+		fmt.Printf("Warning: failed to load shipment packages: %v\n", err)
+	} else if len(packages) > 1 || (len(packages) == 1 && packages[0].TrackingNumber != shipment.TrackingNumber.String) {
+		data.Packages = packages
+	}
+
 	// Fetch laptop details for single shipments
 	if isSingleShipment {
 		var serialNumber, brand, model, cpu, ramGB, ssdGB, sku sql.NullString
@@ -449,6 +494,11 @@ func (n *Notifier) SendWarehousePreAlert(ctx context.Context, shipmentID int64)
 			data.LaptopCount = shipment.LaptopCount
 		}
 		
+		// Recorded packages take precedence over the box count declared on the form
+		if len(packages) > 0 {
+			numberOfBoxes = len(packages)
+		}
+
 		// Set number of boxes
 		if numberOfBoxes > 0 {
 			data.NumberOfBoxes = numberOfBoxes
@@ -465,7 +515,7 @@ func (n *Notifier) SendWarehousePreAlert(ctx context.Context, shipmentID int64)
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("warehouse_pre_alert", data)
+	rendered, err := n.render(ctx, "warehouse_pre_alert", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -473,12 +523,12 @@ func (n *Notifier) SendWarehousePreAlert(ctx context.Context, shipmentID int64)
 	// Send email
 	message := Message{
 		To:       []string{warehouseEmail},
-		Subject:  n.templates.GetSubject("warehouse_pre_alert", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -560,21 +610,15 @@ func (n *Notifier) SendShipmentPickedUpNotification(ctx context.Context, shipmen
 	}
 	expectedArrival := expectedArrivalDate.Format("Monday, January 2, 2006")
 
-	// Build tracking URL based on courier
+	// Build tracking URL from the courier's record
 	trackingURL := ""
 	courierNameStr := "Courier"
 	if courierName.Valid && courierName.String != "" {
 		courierNameStr = courierName.String
 	}
 	if shipment.TrackingNumber.String != "" {
-		switch strings.ToUpper(courierNameStr) {
-		case "UPS":
-			trackingURL = fmt.Sprintf("https://www.ups.com/track?tracknum=%s", shipment.TrackingNumber.String)
-		case "FEDEX", "FEDEX EXPRESS":
-			trackingURL = fmt.Sprintf("https://www.fedex.com/fedextrack/?trknbr=%s", shipment.TrackingNumber.String)
-		case "DHL":
-			trackingURL = fmt.Sprintf("https://www.dhl.com/en/express/tracking.html?AWB=%s", shipment.TrackingNumber.String)
-		default:
+		trackingURL = n.courierTrackingURL(courierNameStr, shipment.TrackingNumber.String)
+		if trackingURL == "" {
 			trackingURL = fmt.Sprintf("https://www.google.com/search?q=track+%s", shipment.TrackingNumber.String)
 		}
 	}
@@ -592,7 +636,7 @@ func (n *Notifier) SendShipmentPickedUpNotification(ctx context.Context, shipmen
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("shipment_picked_up", data)
+	rendered, err := n.render(ctx, "shipment_picked_up", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -600,12 +644,12 @@ func (n *Notifier) SendShipmentPickedUpNotification(ctx context.Context, shipmen
 	// Send email
 	message := Message{
 		To:       []string{contactEmail},
-		Subject:  n.templates.GetSubject("shipment_picked_up", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -720,7 +764,7 @@ func (n *Notifier) SendPickupFormSubmittedNotification(ctx context.Context, ship
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("pickup_form_submitted_logistics", data)
+	rendered, err := n.render(ctx, "pickup_form_submitted_logistics", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -728,12 +772,12 @@ func (n *Notifier) SendPickupFormSubmittedNotification(ctx context.Context, ship
 	// Send email
 	message := Message{
 		To:       []string{logisticsEmail},
-		Subject:  n.templates.GetSubject("pickup_form_submitted_logistics", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -809,7 +853,7 @@ func (n *Notifier) SendReleaseNotification(ctx context.Context, shipmentID int64
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("release_notification", data)
+	rendered, err := n.render(ctx, "release_notification", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -817,12 +861,12 @@ func (n *Notifier) SendReleaseNotification(ctx context.Context, shipmentID int64
 	// Send email
 	message := Message{
 		To:       []string{courierEmail},
-		Subject:  n.templates.GetSubject("release_notification", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -900,20 +944,31 @@ func (n *Notifier) SendDeliveryConfirmation(ctx context.Context, shipmentID int6
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("delivery_confirmation", data)
+	rendered, err := n.render(ctx, "delivery_confirmation", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
 
+	// Attach the photos taken when the device was handed over
+	var photoURLs pq.StringArray
+	err = n.db.QueryRowContext(ctx,
+		`SELECT photo_urls FROM delivery_forms WHERE shipment_id = $1 ORDER BY id DESC LIMIT 1`,
+		shipmentID,
+	).Scan(&photoURLs)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to fetch delivery photos: %w", err)
+	}
+
 	// Send email
 	message := Message{
-		To:       []string{engineerEmail},
-		Subject:  n.templates.GetSubject("delivery_confirmation", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		To:          []string{engineerEmail},
+		Subject:     rendered.Subject,
+		Body:        rendered.Text,
+		HTMLBody:    rendered.HTML,
+		Attachments: deliveryPhotoAttachments(photoURLs),
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -925,6 +980,28 @@ func (n *Notifier) SendDeliveryConfirmation(ctx context.Context, shipmentID int6
 	return nil
 }
 
+// deliveryPhotoAttachments reads the delivery photos stored in DeliveryPhotoDir. Photos that cannot
+// be read, or would make the email larger than maxDeliveryPhotoBytes, are left out.
+func deliveryPhotoAttachments(photoURLs []string) []Attachment {
+	var attachments []Attachment
+	total := 0
+	for _, photoURL := range photoURLs {
+		filename := filepath.Base(photoURL)
+		data, err := os.ReadFile(filepath.Join(DeliveryPhotoDir, filename))
+		if err != nil {
+			fmt.Printf("Warning: failed to read delivery photo %s: %v\n", photoURL, err)
+			continue
+		}
+		if total+len(data) > maxDeliveryPhotoBytes {
+			fmt.Printf("Warning: delivery photo %s not attached, the email would be too large\n", photoURL)
+			continue
+		}
+		total += len(data)
+		attachments = append(attachments, Attachment{Filename: filename, Data: data})
+	}
+	return attachments
+}
+
 // SendEngineerDeliveryNotificationToClient sends notification to client when device is delivered to engineer
 func (n *Notifier) SendEngineerDeliveryNotificationToClient(ctx context.Context, shipmentID int64) error {
 	// Fetch shipment details
@@ -1078,7 +1155,7 @@ func (n *Notifier) SendEngineerDeliveryNotificationToClient(ctx context.Context,
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("engineer_delivery_notification_to_client", data)
+	rendered, err := n.render(ctx, "engineer_delivery_notification_to_client", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -1086,12 +1163,12 @@ func (n *Notifier) SendEngineerDeliveryNotificationToClient(ctx context.Context,
 	// Send email
 	message := Message{
 		To:       []string{contactEmail},
-		Subject:  n.templates.GetSubject("engineer_delivery_notification_to_client", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -1203,17 +1280,11 @@ func (n *Notifier) SendInTransitToEngineerNotification(ctx context.Context, ship
 		}
 	}
 
-	// Build tracking URL based on courier
+	// Build tracking URL from the courier's record
 	trackingURL := ""
 	if shipment.TrackingNumber.String != "" {
-		switch strings.ToUpper(courierNameStr) {
-		case "UPS":
-			trackingURL = fmt.Sprintf("https://www.ups.com/track?tracknum=%s", shipment.TrackingNumber.String)
-		case "FEDEX", "FEDEX EXPRESS":
-			trackingURL = fmt.Sprintf("https://www.fedex.com/fedextrack/?trknbr=%s", shipment.TrackingNumber.String)
-		case "DHL":
-			trackingURL = fmt.Sprintf("https://www.dhl.com/en/express/tracking.html?AWB=%s", shipment.TrackingNumber.String)
-		default:
+		trackingURL = n.courierTrackingURL(courierNameStr, shipment.TrackingNumber.String)
+		if trackingURL == "" {
 			trackingURL = fmt.Sprintf("https://www.google.com/search?q=track+%s", shipment.TrackingNumber.String)
 		}
 	}
@@ -1244,7 +1315,7 @@ func (n *Notifier) SendInTransitToEngineerNotification(ctx context.Context, ship
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("in_transit_to_engineer", data)
+	rendered, err := n.render(ctx, "in_transit_to_engineer", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -1252,12 +1323,12 @@ func (n *Notifier) SendInTransitToEngineerNotification(ctx context.Context, ship
 	// Send email
 	message := Message{
 		To:       []string{engineerEmail},
-		Subject:  n.templates.GetSubject("in_transit_to_engineer", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -1384,7 +1455,7 @@ func (n *Notifier) SendReceptionReportApprovalRequest(ctx context.Context, repor
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("reception_report_approval_request", data)
+	rendered, err := n.render(ctx, "reception_report_approval_request", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -1392,12 +1463,12 @@ func (n *Notifier) SendReceptionReportApprovalRequest(ctx context.Context, repor
 	// Send email
 	message := Message{
 		To:       []string{logisticsEmail},
-		Subject:  n.templates.GetSubject("reception_report_approval_request", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -1420,7 +1491,7 @@ func (n *Notifier) SendMagicLink(ctx context.Context, recipientEmail, recipientN
 	}
 
 	// Render template
-	htmlBody, err := n.templates.RenderTemplate("magic_link", data)
+	rendered, err := n.render(ctx, "magic_link", data)
 	if err != nil {
 		return fmt.Errorf("failed to render template: %w", err)
 	}
@@ -1428,12 +1499,12 @@ func (n *Notifier) SendMagicLink(ctx context.Context, recipientEmail, recipientN
 	// Send email
 	message := Message{
 		To:       []string{recipientEmail},
-		Subject:  n.templates.GetSubject("magic_link", data),
-		Body:     n.generatePlainTextFromHTML(htmlBody),
-		HTMLBody: htmlBody,
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
 	}
 
-	if err := n.client.Send(message); err != nil {
+	if err := n.send(message); err != nil {
 		return fmt.Errorf("failed to send email: %w", err)
 	}
 
@@ -1445,12 +1516,104 @@ func (n *Notifier) SendMagicLink(ctx context.Context, recipientEmail, recipientN
 	return nil
 }
 
+// SendUserInvitation sends an invitation with a link to set the first password
+func (n *Notifier) SendUserInvitation(ctx context.Context, data UserInvitationData) error {
+	return n.sendAccountEmail(ctx, "user_invitation", data.RecipientEmail, data)
+}
+
+// SendPasswordReset sends a link to choose a new password
+func (n *Notifier) SendPasswordReset(ctx context.Context, data PasswordResetData) error {
+	return n.sendAccountEmail(ctx, "password_reset", data.RecipientEmail, data)
+}
+
+// sendQueuedUserInvitation sends a queued invitation with a new link to set the first password.
+// Users who set a password since the invitation was queued are skipped.
+func (n *Notifier) sendQueuedUserInvitation(ctx context.Context, msg *OutboxMessage) error {
+	user, err := models.GetUserByID(n.db, msg.SubjectID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if user.PasswordHash != models.PendingPasswordHash {
+		return nil
+	}
+
+	invitedBy := ""
+	if msg.RequestedByUserID != nil {
+		if inviter, err := models.GetUserByID(n.db, *msg.RequestedByUserID); err == nil {
+			invitedBy = inviter.Email
+		}
+	}
+
+	link, err := auth.CreateAccountLink(ctx, n.db, user.ID, models.MagicLinkPurposeInvitation, auth.DefaultInvitationDuration)
+	if err != nil {
+		return err
+	}
+
+	return n.SendUserInvitation(ctx, UserInvitationData{
+		RecipientEmail: user.Email,
+		InvitedBy:      invitedBy,
+		Role:           strings.ReplaceAll(string(user.Role), "_", " "),
+		SetPasswordURL: n.setPasswordURL(link.Token),
+		ExpiresAt:      link.ExpiresAt,
+	})
+}
+
+// sendQueuedPasswordReset sends a queued password reset with a new link to choose a password
+func (n *Notifier) sendQueuedPasswordReset(ctx context.Context, msg *OutboxMessage) error {
+	user, err := models.GetUserByID(n.db, msg.SubjectID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+
+	link, err := auth.CreateAccountLink(ctx, n.db, user.ID, models.MagicLinkPurposePasswordReset, auth.DefaultPasswordResetDuration)
+	if err != nil {
+		return err
+	}
+
+	return n.SendPasswordReset(ctx, PasswordResetData{
+		RecipientEmail: user.Email,
+		ResetURL:       n.setPasswordURL(link.Token),
+		ExpiresAt:      link.ExpiresAt,
+	})
+}
+
+// setPasswordURL returns the link to the page where an account link token is used
+func (n *Notifier) setPasswordURL(token string) string {
+	return fmt.Sprintf("%s/set-password?token=%s", n.baseURL, url.QueryEscape(token))
+}
+
+// sendAccountEmail renders and sends an email about the recipient's account (no shipment)
+func (n *Notifier) sendAccountEmail(ctx context.Context, templateName, recipientEmail string, data interface{}) error {
+	rendered, err := n.render(ctx, templateName, data)
+	if err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+
+	message := Message{
+		To:       []string{recipientEmail},
+		Subject:  rendered.Subject,
+		Body:     rendered.Text,
+		HTMLBody: rendered.HTML,
+	}
+
+	if err := n.send(message); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	if err := n.logNotification(ctx, 0, templateName, recipientEmail, "sent"); err != nil {
+		fmt.Printf("Warning: failed to log notification: %v\n", err)
+	}
+
+	return nil
+}
+
 // Helper methods
 
 type shipmentDetails struct {
 	ClientCompanyID      int64
 	SoftwareEngineerID   sql.NullInt64
 	TrackingNumber       sql.NullString
+	CourierName          sql.NullString
 	PickupScheduledDate  sql.NullTime
 	ArrivedWarehouseAt   sql.NullTime
 	LaptopCount          int
@@ -1461,7 +1624,7 @@ func (n *Notifier) getShipmentDetails(ctx context.Context, shipmentID int64) (*s
 
 	// Get basic shipment info
 	err := n.db.QueryRowContext(ctx,
-		`SELECT client_company_id, software_engineer_id, tracking_number, 
+		`SELECT client_company_id, software_engineer_id, tracking_number, courier_name,
 		pickup_scheduled_date, arrived_warehouse_at
 		FROM shipments WHERE id = $1`,
 		shipmentID,
@@ -1469,6 +1632,7 @@ func (n *Notifier) getShipmentDetails(ctx context.Context, shipmentID int64) (*s
 		&details.ClientCompanyID,
 		&details.SoftwareEngineerID,
 		&details.TrackingNumber,
+		&details.CourierName,
 		&details.PickupScheduledDate,
 		&details.ArrivedWarehouseAt,
 	)
@@ -1563,7 +1727,90 @@ func (n *Notifier) getContactEmailFromForm(ctx context.Context, shipmentID int64
 	return contactEmail, nil
 }
 
+// templatePreview holds the draft template a previewing notifier renders and the message it captures
+type templatePreview struct {
+	name     string
+	template *compiledTemplate
+	message  *Message
+}
+
+// render renders the current version of a template, or the draft when previewing it.
+// A template without a plain text body gets one generated from the HTML.
+func (n *Notifier) render(ctx context.Context, name string, data interface{}) (*RenderedEmail, error) {
+	var rendered *RenderedEmail
+	if n.preview != nil && n.preview.name == name {
+		dataMap, err := templateData(data)
+		if err != nil {
+			return nil, err
+		}
+		rendered, err = n.preview.template.render(dataMap)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		rendered, err = n.templates.Render(ctx, name, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if strings.TrimSpace(rendered.Text) == "" {
+		rendered.Text = n.generatePlainTextFromHTML(rendered.HTML)
+	}
+	return rendered, nil
+}
+
+// send sends a message, or captures the first one when previewing
+func (n *Notifier) send(message Message) error {
+	if n.preview != nil {
+		if n.preview.message == nil {
+			n.preview.message = &message
+		}
+		return nil
+	}
+	return n.client.Send(message)
+}
+
+// PreviewTemplate renders draft template content into the message it would produce, without sending
+// or logging anything. A notification about a shipment or reception report is rendered for subjectID
+// with its real data; other templates, or a subjectID of 0, are rendered with sample data.
+func (n *Notifier) PreviewTemplate(ctx context.Context, name string, content TemplateContent, subjectID int64) (*Message, error) {
+	compiled, err := compileTemplate(content, false)
+	if err != nil {
+		return nil, err
+	}
+
+	previewer := *n
+	previewer.preview = &templatePreview{name: name, template: compiled}
+
+	// Account emails are always previewed with sample data: sending one creates a real account link
+	if sender, ok := outboxSenders[name]; ok && subjectID > 0 && !isAccountEmail(name) {
+		if err := sender(&previewer, ctx, &OutboxMessage{Kind: name, SubjectID: subjectID}); err != nil {
+			return nil, err
+		}
+		if previewer.preview.message == nil {
+			return nil, fmt.Errorf("this notification would not be sent for %s %d", PreviewSubject(name), subjectID)
+		}
+		return previewer.preview.message, nil
+	}
+
+	sample, ok := templateSamples[name]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+	rendered, err := previewer.render(ctx, name, sample)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Subject: rendered.Subject, Body: rendered.Text, HTMLBody: rendered.HTML}, nil
+}
+
 func (n *Notifier) logNotification(ctx context.Context, shipmentID int64, notificationType, recipient, status string) error {
+	if n.preview != nil {
+		return nil
+	}
+
 	var shipmentIDPtr *int64
 	if shipmentID > 0 {
 		shipmentIDPtr = &shipmentID
@@ -1581,11 +1828,8 @@ func (n *Notifier) logNotification(ctx context.Context, shipmentID int64, notifi
 	return err
 }
 
-// generatePlainTextFromHTML creates a simple plain text version from HTML
-// This is a basic implementation - could be improved with proper HTML parsing
+// generatePlainTextFromHTML creates the plain text version of an HTML email
 func (n *Notifier) generatePlainTextFromHTML(html string) string {
-	// For now, just strip HTML tags - could be improved
-	// In production, consider using a library like bluemonday or goquery
-	return "Please view this email in an HTML-capable email client."
+	return htmlToText(html)
 }
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -335,12 +337,9 @@ func TestNotifier_generatePlainTextFromHTML(t *testing.T) {
 	html := "<html><body><h1>Test</h1><p>This is a test</p></body></html>"
 	plainText := notifier.generatePlainTextFromHTML(html)
 
-	if plainText == "" {
-		t.Error("generatePlainTextFromHTML() returned empty string")
+	if plainText != "Test\n\nThis is a test" {
+		t.Errorf("generatePlainTextFromHTML() = %q", plainText)
 	}
-
-	// For now, we just check it returns something
-	// In future, could implement proper HTML to text conversion
 }
 
 func TestNotifier_SendPickupScheduledNotification(t *testing.T) {
@@ -1335,3 +1334,27 @@ func TestNotifier_SendReceptionReportApprovalRequest(t *testing.T) {
 		t.Logf("Note: Notification was not logged due to mock SMTP server quirk (returns 250 OK as error)")
 	}
 }
+
+func TestDeliveryPhotoAttachments(t *testing.T) {
+	dir := t.TempDir()
+	original := DeliveryPhotoDir
+	DeliveryPhotoDir = dir
+	defer func() { DeliveryPhotoDir = original }()
+
+	if err := os.WriteFile(filepath.Join(dir, "12_1.jpg"), []byte("photo"), 0644); err != nil {
+		t.Fatalf("Failed to write photo: %v", err)
+	}
+
+	attachments := deliveryPhotoAttachments([]string{
+		"/uploads/delivery/12_1.jpg",
+		"/uploads/delivery/missing.jpg",
+		"/uploads/delivery/../../config.yaml",
+	})
+
+	if len(attachments) != 1 {
+		t.Fatalf("Expected 1 attachment, got %d", len(attachments))
+	}
+	if attachments[0].Filename != "12_1.jpg" || string(attachments[0].Data) != "photo" {
+		t.Errorf("attachment = %s %q", attachments[0].Filename, attachments[0].Data)
+	}
+}
@@ -0,0 +1,407 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Kinds of notification that can be queued in the outbox.
+// Each one is sent by the Notifier method of the same name, for a shipment, a reception report or a user.
+const (
+	KindPickupConfirmation                   = "pickup_confirmation"
+	KindPickupFormSubmitted                  = "pickup_form_submitted_logistics"
+	KindPickupScheduled                      = "pickup_scheduled"
+	KindWarehousePreAlert                    = "warehouse_pre_alert"
+	KindShipmentPickedUp                     = "shipment_picked_up"
+	KindReleaseNotification                  = "release_notification"
+	KindDeliveryConfirmation                 = "delivery_confirmation"
+	KindEngineerDeliveryNotificationToClient = "engineer_delivery_notification_to_client"
+	KindInTransitToEngineer                  = "in_transit_to_engineer"
+	KindReceptionReportApprovalRequest       = "reception_report_approval_request"
+	KindUserInvitation                       = "user_invitation"
+	KindPasswordReset                        = "password_reset"
+)
+
+// Outbox message statuses
+const (
+	OutboxStatusPending = "pending" // Waiting for its first or next attempt
+	OutboxStatusSending = "sending" // Claimed by a worker until locked_until
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead" // Every attempt failed; waits for a retry from the UI
+)
+
+// ErrOutboxMessageNotFound is returned when retrying a message that does not exist or has not failed
+var ErrOutboxMessageNotFound = errors.New("outbox message not found or not failed")
+
+// outboxSender sends a queued notification
+type outboxSender func(n *Notifier, ctx context.Context, msg *OutboxMessage) error
+
+// bySubject adapts a Notifier method that only needs the subject of the message
+func bySubject(send func(n *Notifier, ctx context.Context, subjectID int64) error) outboxSender {
+	return func(n *Notifier, ctx context.Context, msg *OutboxMessage) error {
+		return send(n, ctx, msg.SubjectID)
+	}
+}
+
+// outboxSenders sends a queued notification for its subject
+var outboxSenders = map[string]outboxSender{
+	KindPickupConfirmation:                   bySubject((*Notifier).SendPickupConfirmation),
+	KindPickupFormSubmitted:                  bySubject((*Notifier).SendPickupFormSubmittedNotification),
+	KindPickupScheduled:                      bySubject((*Notifier).SendPickupScheduledNotification),
+	KindWarehousePreAlert:                    bySubject((*Notifier).SendWarehousePreAlert),
+	KindShipmentPickedUp:                     bySubject((*Notifier).SendShipmentPickedUpNotification),
+	KindReleaseNotification:                  bySubject((*Notifier).SendReleaseNotification),
+	KindDeliveryConfirmation:                 bySubject((*Notifier).SendDeliveryConfirmation),
+	KindEngineerDeliveryNotificationToClient: bySubject((*Notifier).SendEngineerDeliveryNotificationToClient),
+	KindInTransitToEngineer:                  bySubject((*Notifier).SendInTransitToEngineerNotification),
+	KindReceptionReportApprovalRequest:       bySubject((*Notifier).SendReceptionReportApprovalRequest),
+	KindUserInvitation:                       (*Notifier).sendQueuedUserInvitation,
+	KindPasswordReset:                        (*Notifier).sendQueuedPasswordReset,
+}
+
+// isAccountEmail returns true for the kinds whose subject is a user
+func isAccountEmail(kind string) bool {
+	return kind == KindUserInvitation || kind == KindPasswordReset
+}
+
+// OutboxMessage is a queued notification and the state of its delivery
+type OutboxMessage struct {
+	ID            int64
+	Kind          string
+	SubjectID     int64 // Shipment ID, reception report ID for approval requests, or user ID for account emails
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        *time.Time
+
+	RequestedByUserID *int64 // User whose action queued the message, if any
+}
+
+// SubjectURL returns the page of the shipment, reception report or user the message is about
+func (m *OutboxMessage) SubjectURL() string {
+	if m.Kind == KindReceptionReportApprovalRequest {
+		return fmt.Sprintf("/reception-reports/%d", m.SubjectID)
+	}
+	if isAccountEmail(m.Kind) {
+		return fmt.Sprintf("/forms/users/%d/edit", m.SubjectID)
+	}
+	return fmt.Sprintf("/shipments/%d", m.SubjectID)
+}
+
+// Enqueue queues a notification. Pass the transaction that makes the change the notification
+// announces, so the message is only queued if the change commits.
+func Enqueue(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, kind string, subjectID int64) error {
+	return enqueue(ctx, db, kind, subjectID, nil)
+}
+
+// EnqueueAccountEmail queues an invitation or password reset email for a user.
+// The account link in it is created when the email is sent, so no usable token is stored in the outbox.
+// requestedByUserID is the user asking for the email, if it is not the recipient.
+func EnqueueAccountEmail(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, kind string, userID int64, requestedByUserID *int64) error {
+	if !isAccountEmail(kind) {
+		return fmt.Errorf("%q is not an account email", kind)
+	}
+	return enqueue(ctx, db, kind, userID, requestedByUserID)
+}
+
+// enqueue inserts a pending message
+func enqueue(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, kind string, subjectID int64, requestedByUserID *int64) error {
+	if _, ok := outboxSenders[kind]; !ok {
+		return fmt.Errorf("unknown notification kind %q", kind)
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO email_outbox (kind, subject_id, requested_by_user_id, status, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $5)`,
+		kind, subjectID, requestedByUserID, OutboxStatusPending, time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to queue %s notification: %w", kind, err)
+	}
+	return nil
+}
+
+// IsQueued returns true while a notification of the given kind for the subject waits to be sent
+func IsQueued(ctx context.Context, db *sql.DB, kind string, subjectID int64) (bool, error) {
+	var queued bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS(
+			SELECT 1 FROM email_outbox
+			WHERE kind = $1 AND subject_id = $2 AND status IN ($3, $4)
+		)`,
+		kind, subjectID, OutboxStatusPending, OutboxStatusSending,
+	).Scan(&queued)
+	if err != nil {
+		return false, fmt.Errorf("failed to check email outbox: %w", err)
+	}
+	return queued, nil
+}
+
+// Outbox delivers queued notifications in the background.
+// Failed deliveries are retried with exponential backoff; after MaxAttempts the message is dead.
+// A message is delivered at least once: if the process stops between sending it and recording
+// the delivery, its claim expires after ClaimTimeout and it is sent again.
+type Outbox struct {
+	DB           *sql.DB
+	Notifier     *Notifier
+	MaxAttempts  int           // Attempts before a message is dead
+	BaseDelay    time.Duration // Wait after the first failure; doubled after each further one
+	MaxDelay     time.Duration // Longest wait between attempts
+	ClaimTimeout time.Duration // How long a worker may take to send a message before another one takes it over
+}
+
+// NewOutbox creates an Outbox worker
+func NewOutbox(db *sql.DB, notifier *Notifier, maxAttempts int) *Outbox {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &Outbox{
+		DB:           db,
+		Notifier:     notifier,
+		MaxAttempts:  maxAttempts,
+		BaseDelay:    time.Minute,
+		MaxDelay:     6 * time.Hour,
+		ClaimTimeout: 10 * time.Minute,
+	}
+}
+
+// outboxBatchSize is the most messages delivered per poll
+const outboxBatchSize = 50
+
+// Run delivers due messages every interval and returns when ctx is canceled
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	if o.Notifier == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			sent, failed, err := o.DeliverDue(ctx)
+			if err != nil {
+				log.Printf("Warning: email outbox delivery failed: %v", err)
+				continue
+			}
+			if sent > 0 || failed > 0 {
+				log.Printf("Email outbox delivered %d message(s), %d failed", sent, failed)
+			}
+		}
+	}
+}
+
+// DeliverDue delivers the messages whose next attempt is due and returns how many were sent and how many failed
+func (o *Outbox) DeliverDue(ctx context.Context) (sent, failed int, err error) {
+	for i := 0; i < outboxBatchSize; i++ {
+		delivered, ok, err := o.deliverNext(ctx)
+		if err != nil {
+			return sent, failed, err
+		}
+		if !ok {
+			break
+		}
+		if delivered {
+			sent++
+		} else {
+			failed++
+		}
+	}
+	return sent, failed, nil
+}
+
+// deliverNext attempts the next due message. ok is false when no message is due.
+// The message is claimed in a short transaction of its own, sent without holding any lock,
+// and the result is recorded in a second transaction.
+func (o *Outbox) deliverNext(ctx context.Context) (delivered, ok bool, err error) {
+	msg, err := o.claimNext(ctx)
+	if err != nil || msg == nil {
+		return false, false, err
+	}
+
+	sendErr := o.send(ctx, msg)
+	if err := o.record(ctx, msg, sendErr); err != nil {
+		return false, false, err
+	}
+	return sendErr == nil, true, nil
+}
+
+// claimNext marks the next due message as sending until the claim timeout and returns it,
+// or nil when no message is due. A message whose claim expired is due again.
+// Workers in other processes skip the row while it is being claimed.
+func (o *Outbox) claimNext(ctx context.Context) (*OutboxMessage, error) {
+	now := time.Now()
+	var msg OutboxMessage
+	err := o.DB.QueryRowContext(ctx,
+		`UPDATE email_outbox
+		SET status = $1, locked_until = $2
+		WHERE id = (
+			SELECT id FROM email_outbox
+			WHERE (status = $3 AND next_attempt_at <= $4)
+			   OR (status = $1 AND locked_until <= $4)
+			ORDER BY next_attempt_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, kind, subject_id, requested_by_user_id, attempts`,
+		OutboxStatusSending, now.Add(o.ClaimTimeout), OutboxStatusPending, now,
+	).Scan(&msg.ID, &msg.Kind, &msg.SubjectID, &msg.RequestedByUserID, &msg.Attempts)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim email outbox message: %w", err)
+	}
+	return &msg, nil
+}
+
+// record stores the result of sending a claimed message and releases the claim.
+// A failed message is scheduled for another attempt, or dead after MaxAttempts.
+func (o *Outbox) record(ctx context.Context, msg *OutboxMessage, sendErr error) error {
+	msg.Attempts++
+	now := time.Now()
+
+	var err error
+	if sendErr == nil {
+		_, err = o.DB.ExecContext(ctx,
+			`UPDATE email_outbox
+			SET status = $1, attempts = $2, last_error = '', sent_at = $3, locked_until = NULL
+			WHERE id = $4 AND status = $5`,
+			OutboxStatusSent, msg.Attempts, now, msg.ID, OutboxStatusSending,
+		)
+	} else {
+		status := OutboxStatusPending
+		if msg.Attempts >= o.MaxAttempts {
+			status = OutboxStatusDead
+			log.Printf("Warning: giving up on %s notification for %d after %d attempts: %v", msg.Kind, msg.SubjectID, msg.Attempts, sendErr)
+		}
+		_, err = o.DB.ExecContext(ctx,
+			`UPDATE email_outbox
+			SET status = $1, attempts = $2, last_error = $3, next_attempt_at = $4, locked_until = NULL
+			WHERE id = $5 AND status = $6`,
+			status, msg.Attempts, sendErr.Error(), now.Add(o.backoff(msg.Attempts)), msg.ID, OutboxStatusSending,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update email outbox message %d: %w", msg.ID, err)
+	}
+	return nil
+}
+
+// send sends a message with the Notifier method for its kind
+func (o *Outbox) send(ctx context.Context, msg *OutboxMessage) error {
+	sender, ok := outboxSenders[msg.Kind]
+	if !ok {
+		return fmt.Errorf("unknown notification kind %q", msg.Kind)
+	}
+	return sender(o.Notifier, ctx, msg)
+}
+
+// backoff returns the wait after the given number of failed attempts:
+// BaseDelay after the first, doubling after each further one, up to MaxDelay
+func (o *Outbox) backoff(attempts int) time.Duration {
+	delay := o.BaseDelay
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= o.MaxDelay {
+			return o.MaxDelay
+		}
+	}
+	if delay > o.MaxDelay {
+		return o.MaxDelay
+	}
+	return delay
+}
+
+// FailedOutboxMessages returns the messages that failed at least once and were not delivered
+// since, dead ones first, newest first
+func FailedOutboxMessages(ctx context.Context, db *sql.DB, limit int) ([]*OutboxMessage, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, kind, subject_id, status, attempts, next_attempt_at, last_error, created_at, sent_at
+		FROM email_outbox
+		WHERE status = $1 OR (status = $2 AND attempts > 0)
+		ORDER BY status = $1 DESC, created_at DESC, id DESC
+		LIMIT $3`,
+		OutboxStatusDead, OutboxStatusPending, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email outbox: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*OutboxMessage
+	for rows.Next() {
+		var msg OutboxMessage
+		if err := rows.Scan(&msg.ID, &msg.Kind, &msg.SubjectID, &msg.Status, &msg.Attempts,
+			&msg.NextAttemptAt, &msg.LastError, &msg.CreatedAt, &msg.SentAt); err != nil {
+			return nil, fmt.Errorf("failed to scan email outbox message: %w", err)
+		}
+		messages = append(messages, &msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating email outbox: %w", err)
+	}
+	return messages, nil
+}
+
+// OutboxCounts returns the number of messages in each status
+func OutboxCounts(ctx context.Context, db *sql.DB) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, `SELECT status, COUNT(*) FROM email_outbox GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count email outbox: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]int{
+		OutboxStatusPending: 0,
+		OutboxStatusSent:    0,
+		OutboxStatusDead:    0,
+	}
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan email outbox count: %w", err)
+		}
+		// A message being sent stays pending until its delivery is recorded
+		if status == OutboxStatusSending {
+			status = OutboxStatusPending
+		}
+		counts[status] += count
+	}
+	return counts, rows.Err()
+}
+
+// RetryOutboxMessage schedules a failed message for delivery right away.
+// A dead message gets a fresh set of attempts.
+func RetryOutboxMessage(ctx context.Context, db *sql.DB, id int64) (*OutboxMessage, error) {
+	var msg OutboxMessage
+	err := db.QueryRowContext(ctx,
+		`UPDATE email_outbox
+		SET status = $1, next_attempt_at = $2,
+		    attempts = CASE WHEN status = $3 THEN 0 ELSE attempts END
+		WHERE id = $4 AND (status = $3 OR (status = $1 AND attempts > 0))
+		RETURNING id, kind, subject_id, status, attempts, next_attempt_at, last_error, created_at, sent_at`,
+		OutboxStatusPending, time.Now(), OutboxStatusDead, id,
+	).Scan(&msg.ID, &msg.Kind, &msg.SubjectID, &msg.Status, &msg.Attempts,
+		&msg.NextAttemptAt, &msg.LastError, &msg.CreatedAt, &msg.SentAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrOutboxMessageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry email outbox message: %w", err)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,261 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+)
+
+func TestOutbox_backoff(t *testing.T) {
+	o := NewOutbox(nil, nil, 8)
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{6, 32 * time.Minute},
+		{9, 4*time.Hour + 16*time.Minute},
+		{10, 6 * time.Hour},
+		{50, 6 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := o.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestNewOutbox_AtLeastOneAttempt(t *testing.T) {
+	if o := NewOutbox(nil, nil, 0); o.MaxAttempts != 1 {
+		t.Errorf("MaxAttempts = %d, want 1", o.MaxAttempts)
+	}
+}
+
+func TestOutboxSenders_CoverEveryKind(t *testing.T) {
+	kinds := []string{
+		KindPickupConfirmation,
+		KindPickupFormSubmitted,
+		KindPickupScheduled,
+		KindWarehousePreAlert,
+		KindShipmentPickedUp,
+		KindReleaseNotification,
+		KindDeliveryConfirmation,
+		KindEngineerDeliveryNotificationToClient,
+		KindInTransitToEngineer,
+		KindReceptionReportApprovalRequest,
+		KindUserInvitation,
+		KindPasswordReset,
+	}
+	for _, kind := range kinds {
+		if _, ok := outboxSenders[kind]; !ok {
+			t.Errorf("no sender for notification kind %q", kind)
+		}
+	}
+}
+
+func TestEnqueue_UnknownKind(t *testing.T) {
+	if err := Enqueue(context.Background(), nil, "carrier_pigeon", 1); err == nil {
+		t.Error("Enqueue() with an unknown kind should fail")
+	}
+}
+
+func TestOutboxMessage_SubjectURL(t *testing.T) {
+	shipment := &OutboxMessage{Kind: KindWarehousePreAlert, SubjectID: 12}
+	if got := shipment.SubjectURL(); got != "/shipments/12" {
+		t.Errorf("SubjectURL() = %q, want /shipments/12", got)
+	}
+	report := &OutboxMessage{Kind: KindReceptionReportApprovalRequest, SubjectID: 7}
+	if got := report.SubjectURL(); got != "/reception-reports/7" {
+		t.Errorf("SubjectURL() = %q, want /reception-reports/7", got)
+	}
+	invitation := &OutboxMessage{Kind: KindUserInvitation, SubjectID: 3}
+	if got := invitation.SubjectURL(); got != "/forms/users/3/edit" {
+		t.Errorf("SubjectURL() = %q, want /forms/users/3/edit", got)
+	}
+}
+
+func TestEnqueueAccountEmail_OtherKind(t *testing.T) {
+	if err := EnqueueAccountEmail(context.Background(), nil, KindDeliveryConfirmation, 1, nil); err == nil {
+		t.Error("EnqueueAccountEmail() with a shipment notification should fail")
+	}
+}
+
+func TestOutbox_Run_StopsWithContext(t *testing.T) {
+	o := NewOutbox(nil, &Notifier{}, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		o.Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after the context was canceled")
+	}
+}
+
+// withTestSender registers a sender for a notification kind used only by the test
+func withTestSender(t *testing.T, kind string, send outboxSender) {
+	t.Helper()
+	outboxSenders[kind] = send
+	t.Cleanup(func() { delete(outboxSenders, kind) })
+}
+
+// outboxRow reads the delivery state of a message
+func outboxRow(t *testing.T, db *sql.DB, id int64) (status string, attempts int, lastError string, locked bool) {
+	t.Helper()
+	err := db.QueryRow(
+		`SELECT status, attempts, last_error, locked_until IS NOT NULL FROM email_outbox WHERE id = $1`, id,
+	).Scan(&status, &attempts, &lastError, &locked)
+	if err != nil {
+		t.Fatalf("Failed to read outbox message %d: %v", id, err)
+	}
+	return status, attempts, lastError, locked
+}
+
+// insertOutboxMessage queues a message of the given kind and returns its ID
+func insertOutboxMessage(t *testing.T, db *sql.DB, kind string) int64 {
+	t.Helper()
+	var id int64
+	err := db.QueryRow(
+		`INSERT INTO email_outbox (kind, subject_id, status, next_attempt_at, created_at)
+		VALUES ($1, 1, $2, NOW() - INTERVAL '1 second', NOW()) RETURNING id`,
+		kind, OutboxStatusPending,
+	).Scan(&id)
+	if err != nil {
+		t.Fatalf("Failed to queue outbox message: %v", err)
+	}
+	return id
+}
+
+func TestOutbox_DeliverDue_RecordsResults(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	withTestSender(t, "test_ok", func(n *Notifier, ctx context.Context, msg *OutboxMessage) error {
+		return nil
+	})
+	withTestSender(t, "test_fail", func(n *Notifier, ctx context.Context, msg *OutboxMessage) error {
+		return errors.New("smtp unavailable")
+	})
+	okID := insertOutboxMessage(t, db, "test_ok")
+	failID := insertOutboxMessage(t, db, "test_fail")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sent, failed, err := NewOutbox(db, nil, 3).DeliverDue(ctx)
+	if err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if sent != 1 || failed != 1 {
+		t.Errorf("DeliverDue() = %d sent, %d failed, want 1 and 1", sent, failed)
+	}
+
+	status, attempts, _, locked := outboxRow(t, db, okID)
+	if status != OutboxStatusSent || attempts != 1 || locked {
+		t.Errorf("sent message: status %s, attempts %d, locked %t", status, attempts, locked)
+	}
+
+	status, attempts, lastError, locked := outboxRow(t, db, failID)
+	if status != OutboxStatusPending || attempts != 1 || lastError != "smtp unavailable" || locked {
+		t.Errorf("failed message: status %s, attempts %d, last error %q, locked %t", status, attempts, lastError, locked)
+	}
+}
+
+func TestOutbox_DeliverDue_SendsWithoutRowLock(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	// While the message is being sent, another connection can lock its row right away
+	withTestSender(t, "test_ok", func(n *Notifier, ctx context.Context, msg *OutboxMessage) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var status string
+		if err := tx.QueryRowContext(ctx,
+			`SELECT status FROM email_outbox WHERE id = $1 FOR UPDATE NOWAIT`, msg.ID,
+		).Scan(&status); err != nil {
+			return err
+		}
+		if status != OutboxStatusSending {
+			return fmt.Errorf("status while sending = %s, want %s", status, OutboxStatusSending)
+		}
+		return nil
+	})
+	id := insertOutboxMessage(t, db, "test_ok")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, _, err := NewOutbox(db, nil, 1).DeliverDue(ctx); err != nil {
+		t.Fatalf("DeliverDue() error = %v", err)
+	}
+	if status, _, lastError, _ := outboxRow(t, db, id); status != OutboxStatusSent {
+		t.Errorf("status = %s (%s), want %s", status, lastError, OutboxStatusSent)
+	}
+}
+
+func TestOutbox_DeliverDue_Claims(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	withTestSender(t, "test_ok", func(n *Notifier, ctx context.Context, msg *OutboxMessage) error {
+		return nil
+	})
+	id := insertOutboxMessage(t, db, "test_ok")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	o := NewOutbox(db, nil, 1)
+
+	// Another worker is sending the message
+	if _, err := db.Exec(`UPDATE email_outbox SET status = $1, locked_until = $2 WHERE id = $3`,
+		OutboxStatusSending, time.Now().Add(time.Minute), id); err != nil {
+		t.Fatalf("Failed to claim message: %v", err)
+	}
+	if sent, _, err := o.DeliverDue(ctx); err != nil || sent != 0 {
+		t.Fatalf("DeliverDue() = %d sent, error %v; a claimed message must be skipped", sent, err)
+	}
+
+	// That worker stopped and its claim expired
+	if _, err := db.Exec(`UPDATE email_outbox SET locked_until = $1 WHERE id = $2`,
+		time.Now().Add(-time.Second), id); err != nil {
+		t.Fatalf("Failed to expire claim: %v", err)
+	}
+	if sent, _, err := o.DeliverDue(ctx); err != nil || sent != 1 {
+		t.Fatalf("DeliverDue() = %d sent, error %v; an expired claim must be taken over", sent, err)
+	}
+	if status, _, _, locked := outboxRow(t, db, id); status != OutboxStatusSent || locked {
+		t.Errorf("status = %s, locked %t, want sent and unlocked", status, locked)
+	}
+}
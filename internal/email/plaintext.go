@@ -0,0 +1,197 @@
+package email
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToText renders an HTML email as plain text. Paragraphs, headings and blocks become lines,
+// list items get a dash, links keep their URL after the link text and images their alt text.
+// The head, styles and scripts are left out.
+func htmlToText(s string) string {
+	w := &textWriter{}
+	z := html.NewTokenizer(strings.NewReader(s))
+
+	hidden := 0 // Depth inside elements whose text is not shown
+	pre := 0    // Depth inside elements whose whitespace is kept
+	var links []openLink
+
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			return w.String()
+
+		case html.TextToken:
+			if hidden == 0 {
+				w.text(string(z.Text()), pre > 0)
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			tag := z.Token()
+			if hidden > 0 {
+				if tt == html.StartTagToken && hiddenElements[tag.DataAtom] {
+					hidden++
+				}
+				continue
+			}
+
+			switch tag.DataAtom {
+			case atom.Head, atom.Style, atom.Script, atom.Title, atom.Template:
+				if tt == html.StartTagToken {
+					hidden++
+				}
+			case atom.Br:
+				w.newline(1)
+			case atom.Hr:
+				w.newline(2)
+				w.text("--------", false)
+				w.newline(2)
+			case atom.Li:
+				w.newline(1)
+				w.text("- ", false)
+			case atom.Td, atom.Th:
+				w.space = true
+			case atom.Img:
+				if alt := attr(tag, "alt"); alt != "" {
+					w.text(alt, false)
+				}
+			case atom.A:
+				if tt == html.StartTagToken {
+					links = append(links, openLink{href: attr(tag, "href"), start: w.b.Len()})
+				}
+			case atom.Pre:
+				w.newline(2)
+				pre++
+			default:
+				w.newline(blockSpacing[tag.DataAtom])
+			}
+
+		case html.EndTagToken:
+			tag := z.Token()
+			if hidden > 0 {
+				if hiddenElements[tag.DataAtom] {
+					hidden--
+				}
+				continue
+			}
+
+			switch tag.DataAtom {
+			case atom.A:
+				if len(links) == 0 {
+					continue
+				}
+				link := links[len(links)-1]
+				links = links[:len(links)-1]
+				w.link(link)
+			case atom.Pre:
+				if pre > 0 {
+					pre--
+				}
+				w.newline(2)
+			default:
+				w.newline(blockSpacing[tag.DataAtom])
+			}
+		}
+	}
+}
+
+// hiddenElements are the elements whose content htmlToText leaves out
+var hiddenElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Style: true, atom.Script: true, atom.Title: true, atom.Template: true,
+}
+
+// blockSpacing is the number of line breaks around a block element: 1 starts a new line, 2 leaves a blank line
+var blockSpacing = map[atom.Atom]int{
+	atom.P: 2, atom.H1: 2, atom.H2: 2, atom.H3: 2, atom.H4: 2, atom.H5: 2, atom.H6: 2,
+	atom.Table: 2, atom.Ul: 2, atom.Ol: 2, atom.Blockquote: 2,
+	atom.Div: 1, atom.Section: 1, atom.Header: 1, atom.Footer: 1, atom.Article: 1,
+	atom.Tr: 1, atom.Li: 1, atom.Dt: 1, atom.Dd: 1, atom.Dl: 2,
+}
+
+// openLink is an <a> element whose text is being written
+type openLink struct {
+	href  string
+	start int // Length of the text when the link started
+}
+
+// attr returns the value of an attribute of a tag
+func attr(tag html.Token, name string) string {
+	for _, a := range tag.Attr {
+		if a.Key == name {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// textWriter collapses whitespace like a browser and keeps track of line breaks
+type textWriter struct {
+	b        strings.Builder
+	newlines int  // Line breaks at the end of the text
+	space    bool // A space is due before the next word
+}
+
+// text writes text, collapsing whitespace unless pre is set
+func (w *textWriter) text(s string, pre bool) {
+	if pre {
+		w.b.WriteString(s)
+		w.newlines = len(s) - len(strings.TrimRight(s, "\n"))
+		w.space = false
+		return
+	}
+
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			w.space = true
+		}
+		return
+	}
+
+	if w.b.Len() > 0 && w.newlines == 0 && (w.space || startsWithSpace(s)) {
+		w.b.WriteByte(' ')
+	}
+	w.b.WriteString(strings.Join(words, " "))
+	w.newlines = 0
+	w.space = endsWithSpace(s)
+}
+
+// newline ends the current line with at least n line breaks
+func (w *textWriter) newline(n int) {
+	if n == 0 || w.b.Len() == 0 {
+		return
+	}
+	for w.newlines < n {
+		w.b.WriteByte('\n')
+		w.newlines++
+	}
+	w.space = false
+}
+
+// link writes the URL of a link after its text, unless the text already is the URL
+func (w *textWriter) link(link openLink) {
+	target := strings.TrimPrefix(link.href, "mailto:")
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "javascript:") {
+		return
+	}
+	if label := strings.TrimSpace(w.b.String()[link.start:]); label == target {
+		return
+	}
+	w.text(" ("+target+")", false)
+}
+
+// String returns the text without leading and trailing whitespace
+func (w *textWriter) String() string {
+	return strings.TrimSpace(w.b.String())
+}
+
+func startsWithSpace(s string) bool {
+	return len(s) > 0 && strings.TrimLeft(s, " \t\r\n\f") != s
+}
+
+func endsWithSpace(s string) bool {
+	return len(s) > 0 && strings.TrimRight(s, " \t\r\n\f") != s
+}
@@ -0,0 +1,89 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHTMLToText(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{
+			name: "paragraphs and headings",
+			html: "<html><head><title>Hi</title><style>p { color: red; }</style></head>" +
+				"<body><h1>Welcome</h1><p>First   paragraph\n spans lines.</p><p>Second</p></body></html>",
+			want: "Welcome\n\nFirst paragraph spans lines.\n\nSecond",
+		},
+		{
+			name: "inline elements keep their spaces",
+			html: "<p>Expires on <strong>Monday</strong> at <em>5 PM</em>.</p>",
+			want: "Expires on Monday at 5 PM.",
+		},
+		{
+			name: "links keep their URL",
+			html: `<p><a href="https://example.com/form">Open form</a> or visit <a href="https://example.com">https://example.com</a></p>`,
+			want: "Open form (https://example.com/form) or visit https://example.com",
+		},
+		{
+			name: "mailto links",
+			html: `<p>Write to <a href="mailto:help@example.com">help@example.com</a> or <a href="mailto:ops@example.com">operations</a></p>`,
+			want: "Write to help@example.com or operations (ops@example.com)",
+		},
+		{
+			name: "lists and line breaks",
+			html: "<p>Please:</p><ul><li>Check the box</li><li>Upload photos</li></ul><p>Thanks<br>Logistics</p>",
+			want: "Please:\n\n- Check the box\n- Upload photos\n\nThanks\nLogistics",
+		},
+		{
+			name: "table rows",
+			html: "<table><tr><td>Tracking:</td><td>1Z999</td></tr><tr><td>Courier:</td><td>UPS</td></tr></table>",
+			want: "Tracking: 1Z999\nCourier: UPS",
+		},
+		{
+			name: "entities and images",
+			html: `<p>Tom &amp; Jerry&nbsp;Inc <img src="cid:logo" alt="Logo"></p><script>alert(1)</script>`,
+			want: "Tom & Jerry Inc Logo",
+		},
+		{
+			name: "empty",
+			html: "",
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := htmlToText(tt.html); got != tt.want {
+				t.Errorf("htmlToText() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTMLToText_DefaultTemplate(t *testing.T) {
+	rendered, err := NewEmailTemplates().Render(context.Background(), "magic_link", templateSamples["magic_link"])
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	text := htmlToText(rendered.HTML)
+
+	for _, want := range []string{
+		"Hello Jane Doe,",
+		"(https://align.example.com/magic-link?token=sample)",
+		"expires on Wednesday, January 15, 2025",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text does not contain %q:\n%s", want, text)
+		}
+	}
+	for _, notWant := range []string{"<", "font-family", rendered.Subject + "\n\n" + rendered.Subject} {
+		if strings.Contains(text, notWant) {
+			t.Errorf("text contains %q:\n%s", notWant, text)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// ErrTemplateNotFound is returned for a template or version that does not exist
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// TemplateVersion is a saved version of an email template
+type TemplateVersion struct {
+	ID              int64
+	TemplateName    string
+	Version         int
+	Subject         string
+	HTMLBody        string
+	TextBody        string
+	Comment         string
+	CreatedByUserID *int64
+	CreatedByEmail  string // Empty for the seeded defaults
+	CreatedAt       time.Time
+}
+
+// Content returns the editable content of the version
+func (v *TemplateVersion) Content() TemplateContent {
+	return TemplateContent{Subject: v.Subject, HTMLBody: v.HTMLBody, TextBody: v.TextBody}
+}
+
+// TemplateSummary is a template with its current version, as listed for editing
+type TemplateSummary struct {
+	Name       string
+	Current    *TemplateVersion
+	Customized bool // The current version differs from the built-in default
+}
+
+// TemplateNames returns the names of the built-in templates, sorted
+func TemplateNames() []string {
+	names := make([]string, 0, len(defaultTemplates))
+	for name := range defaultTemplates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultTemplate returns the built-in content of a template
+func DefaultTemplate(name string) (TemplateContent, bool) {
+	content, ok := defaultTemplates[name]
+	return content, ok
+}
+
+// PreviewSubject returns what a template is previewed against: "shipment" or "reception report"
+// for notifications about one, empty for templates that are only previewed with sample data
+func PreviewSubject(name string) string {
+	if _, ok := outboxSenders[name]; !ok || isAccountEmail(name) {
+		return ""
+	}
+	if name == KindReceptionReportApprovalRequest {
+		return "reception report"
+	}
+	return "shipment"
+}
+
+// SeedTemplates saves the built-in templates as the first version of every template that has none yet
+func SeedTemplates(ctx context.Context, db *sql.DB) error {
+	for _, name := range TemplateNames() {
+		content := defaultTemplates[name]
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO email_template_versions (template_name, version, subject, html_body, text_body, comment, created_at)
+			VALUES ($1, 1, $2, $3, $4, $5, $6)
+			ON CONFLICT (template_name, version) DO NOTHING`,
+			name, content.Subject, content.HTMLBody, content.TextBody, "Built-in default", time.Now(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to seed email template %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// templateVersionColumns are the columns scanned by scanTemplateVersion
+const templateVersionColumns = `v.id, v.template_name, v.version, v.subject, v.html_body, v.text_body, v.comment,
+	v.created_by_user_id, COALESCE(u.email, ''), v.created_at`
+
+// scanTemplateVersion scans the templateVersionColumns of a row
+func scanTemplateVersion(row interface {
+	Scan(dest ...interface{}) error
+}) (*TemplateVersion, error) {
+	var v TemplateVersion
+	var createdBy sql.NullInt64
+	err := row.Scan(&v.ID, &v.TemplateName, &v.Version, &v.Subject, &v.HTMLBody, &v.TextBody, &v.Comment,
+		&createdBy, &v.CreatedByEmail, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if createdBy.Valid {
+		v.CreatedByUserID = &createdBy.Int64
+	}
+	return &v, nil
+}
+
+// GetCurrentTemplate returns the highest version of a template
+func GetCurrentTemplate(ctx context.Context, db *sql.DB, name string) (*TemplateVersion, error) {
+	v, err := scanTemplateVersion(db.QueryRowContext(ctx,
+		`SELECT `+templateVersionColumns+`
+		FROM email_template_versions v
+		LEFT JOIN users u ON u.id = v.created_by_user_id
+		WHERE v.template_name = $1
+		ORDER BY v.version DESC
+		LIMIT 1`,
+		name,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email template %s: %w", name, err)
+	}
+	return v, nil
+}
+
+// GetTemplateVersion returns one version of a template
+func GetTemplateVersion(ctx context.Context, db *sql.DB, name string, version int) (*TemplateVersion, error) {
+	v, err := scanTemplateVersion(db.QueryRowContext(ctx,
+		`SELECT `+templateVersionColumns+`
+		FROM email_template_versions v
+		LEFT JOIN users u ON u.id = v.created_by_user_id
+		WHERE v.template_name = $1 AND v.version = $2`,
+		name, version,
+	))
+	if err == sql.ErrNoRows {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get email template %s version %d: %w", name, version, err)
+	}
+	return v, nil
+}
+
+// GetTemplateVersions returns every version of a template, newest first
+func GetTemplateVersions(ctx context.Context, db *sql.DB, name string) ([]*TemplateVersion, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+templateVersionColumns+`
+		FROM email_template_versions v
+		LEFT JOIN users u ON u.id = v.created_by_user_id
+		WHERE v.template_name = $1
+		ORDER BY v.version DESC`,
+		name,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email template versions: %w", err)
+	}
+	defer rows.Close()
+
+	var versions []*TemplateVersion
+	for rows.Next() {
+		v, err := scanTemplateVersion(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan email template version: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating email template versions: %w", err)
+	}
+	return versions, nil
+}
+
+// ListTemplates returns every built-in template with its current version.
+// Current is nil for a template that has no saved version yet.
+func ListTemplates(ctx context.Context, db *sql.DB) ([]TemplateSummary, error) {
+	var summaries []TemplateSummary
+	for _, name := range TemplateNames() {
+		summary := TemplateSummary{Name: name}
+		current, err := GetCurrentTemplate(ctx, db, name)
+		if err != nil && !errors.Is(err, ErrTemplateNotFound) {
+			return nil, err
+		}
+		if current != nil {
+			summary.Current = current
+			summary.Customized = current.Content() != defaultTemplates[name]
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// SaveTemplate checks that the content renders and saves it as the next version of the template
+func SaveTemplate(ctx context.Context, db *sql.DB, name string, content TemplateContent, userID *int64, comment string) (*TemplateVersion, error) {
+	if _, ok := defaultTemplates[name]; !ok {
+		return nil, ErrTemplateNotFound
+	}
+	if err := ValidateTemplate(name, content); err != nil {
+		return nil, err
+	}
+
+	var id int64
+	err := db.QueryRowContext(ctx,
+		`INSERT INTO email_template_versions (template_name, version, subject, html_body, text_body, comment, created_by_user_id, created_at)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, $5, $6, $7
+		FROM email_template_versions
+		WHERE template_name = $1
+		RETURNING id`,
+		name, content.Subject, content.HTMLBody, content.TextBody, strings.TrimSpace(comment), userID, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save email template %s: %w", name, err)
+	}
+
+	return GetCurrentTemplate(ctx, db, name)
+}
+
+// RestoreTemplateVersion rolls a template back by saving an earlier version as the next one
+func RestoreTemplateVersion(ctx context.Context, db *sql.DB, name string, version int, userID *int64) (*TemplateVersion, error) {
+	old, err := GetTemplateVersion(ctx, db, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return SaveTemplate(ctx, db, name, old.Content(), userID, fmt.Sprintf("Restored version %d", version))
+}
+
+// ValidateTemplate checks that a template parses and renders with sample data of its notification.
+// Referring to a value the notification does not have is an error.
+func ValidateTemplate(name string, content TemplateContent) error {
+	if strings.TrimSpace(content.Subject) == "" {
+		return errors.New("subject is required")
+	}
+	if strings.TrimSpace(content.HTMLBody) == "" {
+		return errors.New("HTML body is required")
+	}
+
+	sample, ok := templateSamples[name]
+	if !ok {
+		return ErrTemplateNotFound
+	}
+	compiled, err := compileTemplate(content, true)
+	if err != nil {
+		return err
+	}
+	data, err := templateData(sample)
+	if err != nil {
+		return err
+	}
+	if _, err := compiled.render(data); err != nil {
+		return err
+	}
+	return nil
+}
+
+// templateSamples is example data for every template, used to check templates before they are
+// saved and to preview templates that are not about a shipment. Lists have an entry and flags are
+// set so conditional sections are rendered too.
+var templateSamples = map[string]interface{}{
+	"magic_link": MagicLinkData{
+		RecipientName: "Jane Doe",
+		MagicLink:     "https://align.example.com/magic-link?token=sample",
+		ExpiresAt:     time.Date(2025, 1, 15, 17, 0, 0, 0, time.UTC),
+		FormType:      "pickup",
+	},
+	"address_confirmation": AddressConfirmationData{
+		EngineerName:    "John Smith",
+		CompanyName:     "Acme Corp",
+		ProjectName:     "Project Phoenix",
+		ExpectedDate:    "January 20, 2025",
+		ConfirmationURL: "https://align.example.com/confirm-address?token=sample",
+	},
+	KindPickupConfirmation: PickupConfirmationData{
+		ClientName:       "Jane Doe",
+		ClientCompany:    "Acme Corp",
+		TrackingNumber:   "1Z999AA10123456784",
+		PickupDate:       "Monday, January 13, 2025",
+		PickupTimeSlot:   "Morning (8AM - 12PM)",
+		NumberOfDevices:  2,
+		ConfirmationCode: "CONF-1001",
+	},
+	KindPickupScheduled: PickupScheduledData{
+		ContactName:    "Jane Doe",
+		ClientCompany:  "Acme Corp",
+		TrackingNumber: "1Z999AA10123456784",
+		PickupDate:     "Monday, January 13, 2025",
+		PickupTimeSlot: "Morning (8AM - 12PM)",
+		PickupAddress:  "123 Main St, Austin, TX 78701",
+		ShipmentID:     1001,
+	},
+	KindWarehousePreAlert: WarehousePreAlertData{
+		TrackingNumber:    "1Z999AA10123456784",
+		ExpectedDate:      "Wednesday, January 15, 2025",
+		ShipperName:       "Jane Doe",
+		ShipperCompany:    "Acme Corp",
+		DeviceDescription: "Dell Latitude 7420",
+		ProjectName:       "Project Phoenix",
+		TrackingURL:       "https://www.ups.com/track?tracknum=1Z999AA10123456784",
+		IsSingleShipment:  true,
+		SerialNumber:      "SN-123456",
+		Brand:             "Dell",
+		Model:             "Latitude 7420",
+		CPU:               "Intel Core i7",
+		RAMGB:             "16",
+		SSDGB:             "512",
+		SKU:               "DL-7420-16-512",
+		IsBulkShipment:    true,
+		LaptopCount:       5,
+		NumberOfBoxes:     2,
+		BulkDescription:   "5 laptops in 2 boxes",
+		Packages: []models.ShipmentPackage{
+			{PackageNumber: 1, TrackingNumber: "1Z999AA10123456784", CourierName: "UPS", WeightLb: 12.5},
+		},
+	},
+	KindReleaseNotification: ReleaseNotificationData{
+		CourierName:        "UPS",
+		CourierCompany:     "UPS",
+		PickupDate:         "Thursday, January 16, 2025",
+		PickupTimeSlot:     "Afternoon (12PM - 5PM)",
+		WarehouseAddress:   "100 Warehouse Way, Austin, TX 78744",
+		ContactPerson:      "Warehouse Team",
+		ContactPhone:       "+1 512 555 0100",
+		DeviceSerialNumber: "SN-123456",
+		EngineerName:       "John Smith",
+		TrackingNumber:     "1Z999AA10123456785",
+	},
+	KindDeliveryConfirmation: DeliveryConfirmationData{
+		EngineerName:       "John Smith",
+		DeviceSerialNumber: "SN-123456",
+		DeviceModel:        "Dell Latitude 7420",
+		DeliveryDate:       "Friday, January 17, 2025",
+		TrackingNumber:     "1Z999AA10123456785",
+		ProjectName:        "Project Phoenix",
+	},
+	KindShipmentPickedUp: ShipmentPickedUpData{
+		ContactName:     "Jane Doe",
+		ClientCompany:   "Acme Corp",
+		TrackingNumber:  "1Z999AA10123456784",
+		CourierName:     "UPS",
+		PickedUpDate:    "Monday, January 13, 2025",
+		ExpectedArrival: "Wednesday, January 15, 2025",
+		TrackingURL:     "https://www.ups.com/track?tracknum=1Z999AA10123456784",
+		ShipmentType:    "single_full_journey",
+	},
+	KindPickupFormSubmitted: PickupFormSubmittedData{
+		ShipmentID:      1001,
+		ShipmentType:    "single_full_journey",
+		ClientCompany:   "Acme Corp",
+		ContactName:     "Jane Doe",
+		ContactEmail:    "jane.doe@acme.example.com",
+		ContactPhone:    "+1 512 555 0101",
+		PickupAddress:   "123 Main St, Austin, TX 78701",
+		PickupDate:      "Monday, January 13, 2025",
+		NumberOfDevices: 2,
+		JiraTicket:      "SCOP-1001",
+		ShipmentURL:     "https://align.example.com/shipments/1001",
+	},
+	KindEngineerDeliveryNotificationToClient: EngineerDeliveryClientData{
+		ContactName:    "Jane Doe",
+		ClientCompany:  "Acme Corp",
+		EngineerName:   "John Smith",
+		DeliveryDate:   "Friday, January 17, 2025",
+		TrackingNumber: "1Z999AA10123456785",
+		JiraTicket:     "SCOP-1001",
+		ProjectName:    "Project Phoenix",
+	},
+	KindInTransitToEngineer: InTransitToEngineerData{
+		EngineerName:   "John Smith",
+		SerialNumber:   "SN-123456",
+		Brand:          "Dell",
+		DeviceModel:    "Latitude 7420",
+		CPU:            "Intel Core i7",
+		RAMGB:          "16",
+		SSDGB:          "512",
+		SKU:            "DL-7420-16-512",
+		TrackingNumber: "1Z999AA10123456785",
+		CourierName:    "UPS",
+		ETA:            "Friday, January 17, 2025",
+		ShipmentURL:    "https://align.example.com/shipments/1001",
+		ContactInfo:    "international@example.com",
+	},
+	KindReceptionReportApprovalRequest: ReceptionReportApprovalData{
+		ShipmentID:     1001,
+		TrackingNumber: "1Z999AA10123456784",
+		ClientCompany:  "Acme Corp",
+		ReceivedDate:   "Wednesday, January 15, 2025",
+		WarehouseUser:  "warehouse@example.com",
+		Notes:          "Box slightly dented, device undamaged",
+		PhotoURLs:      []string{"https://align.example.com/uploads/reception/sample.jpg"},
+		SerialNumber:   "SN-123456",
+		ReportURL:      "https://align.example.com/reception-reports/1",
+		ApprovalURL:    "https://align.example.com/reception-reports/1",
+	},
+	"user_invitation": UserInvitationData{
+		RecipientEmail: "new.user@example.com",
+		InvitedBy:      "admin@example.com",
+		Role:           "logistics",
+		SetPasswordURL: "https://align.example.com/set-password?token=sample",
+		ExpiresAt:      time.Date(2025, 1, 20, 17, 0, 0, 0, time.UTC),
+	},
+	"password_reset": PasswordResetData{
+		RecipientEmail: "user@example.com",
+		ResetURL:       "https://align.example.com/reset-password?token=sample",
+		ExpiresAt:      time.Date(2025, 1, 15, 18, 0, 0, 0, time.UTC),
+	},
+}
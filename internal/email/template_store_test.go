@@ -0,0 +1,147 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTemplateSamples_CoverEveryTemplate(t *testing.T) {
+	for _, name := range TemplateNames() {
+		if _, ok := templateSamples[name]; !ok {
+			t.Errorf("template %s has no sample data", name)
+		}
+	}
+}
+
+func TestValidateTemplate_Defaults(t *testing.T) {
+	for _, name := range TemplateNames() {
+		content, _ := DefaultTemplate(name)
+		if err := ValidateTemplate(name, content); err != nil {
+			t.Errorf("built-in template %s does not validate: %v", name, err)
+		}
+	}
+}
+
+func TestValidateTemplate_Errors(t *testing.T) {
+	valid, _ := DefaultTemplate("magic_link")
+
+	tests := []struct {
+		name    string
+		content TemplateContent
+		wantErr string
+	}{
+		{
+			name:    "missing subject",
+			content: TemplateContent{Subject: " ", HTMLBody: valid.HTMLBody},
+			wantErr: "subject is required",
+		},
+		{
+			name:    "missing HTML body",
+			content: TemplateContent{Subject: valid.Subject},
+			wantErr: "HTML body is required",
+		},
+		{
+			name:    "subject does not parse",
+			content: TemplateContent{Subject: "Hello {{.RecipientName", HTMLBody: valid.HTMLBody},
+			wantErr: "subject:",
+		},
+		{
+			name:    "HTML body does not parse",
+			content: TemplateContent{Subject: valid.Subject, HTMLBody: "<p>{{if .MagicLink}}</p>"},
+			wantErr: "HTML body:",
+		},
+		{
+			name:    "unknown field",
+			content: TemplateContent{Subject: valid.Subject, HTMLBody: "<p>{{.TrackingNumber}}</p>"},
+			wantErr: "TrackingNumber",
+		},
+		{
+			name:    "unknown field in plain text body",
+			content: TemplateContent{Subject: valid.Subject, HTMLBody: valid.HTMLBody, TextBody: "{{.ShipmentURL}}"},
+			wantErr: "ShipmentURL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTemplate("magic_link", tt.content)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error %q does not contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTemplate_UnknownTemplate(t *testing.T) {
+	err := ValidateTemplate("no_such_template", TemplateContent{Subject: "Hi", HTMLBody: "<p>Hi</p>"})
+	if err != ErrTemplateNotFound {
+		t.Errorf("error = %v, want ErrTemplateNotFound", err)
+	}
+}
+
+func TestPreviewSubject(t *testing.T) {
+	tests := map[string]string{
+		KindPickupConfirmation:             "shipment",
+		KindInTransitToEngineer:            "shipment",
+		KindReceptionReportApprovalRequest: "reception report",
+		"magic_link":                       "",
+		"password_reset":                   "",
+	}
+	for name, want := range tests {
+		if got := PreviewSubject(name); got != want {
+			t.Errorf("PreviewSubject(%s) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestNotifier_PreviewTemplate_SampleData(t *testing.T) {
+	n := NewNotifier(nil, nil)
+
+	content := TemplateContent{
+		Subject:  "Sign in, {{.RecipientName}}",
+		HTMLBody: `<p>Open <a href="{{.MagicLink}}">your form</a></p>`,
+		TextBody: "Open {{.MagicLink}}",
+	}
+	msg, err := n.PreviewTemplate(context.Background(), "magic_link", content, 0)
+	if err != nil {
+		t.Fatalf("PreviewTemplate failed: %v", err)
+	}
+
+	if msg.Subject != "Sign in, Jane Doe" {
+		t.Errorf("Subject = %q", msg.Subject)
+	}
+	if !strings.Contains(msg.HTMLBody, "magic-link?token=sample") {
+		t.Errorf("HTML body does not contain the sample link: %s", msg.HTMLBody)
+	}
+	if msg.Body != "Open https://align.example.com/magic-link?token=sample" {
+		t.Errorf("Body = %q", msg.Body)
+	}
+}
+
+func TestNotifier_PreviewTemplate_ParseError(t *testing.T) {
+	n := NewNotifier(nil, nil)
+
+	_, err := n.PreviewTemplate(context.Background(), "magic_link", TemplateContent{Subject: "{{", HTMLBody: "<p></p>"}, 0)
+	if err == nil {
+		t.Error("expected an error for a subject that does not parse")
+	}
+}
+
+func TestEmailTemplates_Render_WithoutDB(t *testing.T) {
+	templates := NewEmailTemplates()
+
+	rendered, err := templates.Render(context.Background(), KindInTransitToEngineer, templateSamples[KindInTransitToEngineer])
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.Subject != "Device In Transit - Expected Arrival Friday, January 17, 2025" {
+		t.Errorf("Subject = %q", rendered.Subject)
+	}
+	if !strings.Contains(rendered.HTML, "<title>"+rendered.Subject+"</title>") {
+		t.Error("layout title does not contain the rendered subject")
+	}
+}
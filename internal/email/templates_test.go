@@ -4,6 +4,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
 )
 
 func TestNewEmailTemplates(t *testing.T) {
@@ -183,6 +185,45 @@ func TestEmailTemplates_RenderTemplate_WarehousePreAlert(t *testing.T) {
 	}
 }
 
+func TestEmailTemplates_RenderTemplate_WarehousePreAlertPackages(t *testing.T) {
+	templates := NewEmailTemplates()
+
+	data := WarehousePreAlertData{
+		TrackingNumber: "1Z0001",
+		ExpectedDate:   "December 22, 2024",
+		ShipperName:    "ClientCorp",
+		IsBulkShipment: true,
+		LaptopCount:    4,
+		NumberOfBoxes:  2,
+		Packages: []models.ShipmentPackage{
+			{PackageNumber: 1, CourierName: "UPS", TrackingNumber: "1Z0001", LengthIn: 20, WidthIn: 16, HeightIn: 8, WeightLb: 25},
+			{PackageNumber: 2, CourierName: "FedEx", TrackingNumber: "7770002"},
+		},
+	}
+	for i := range data.Packages {
+		data.Packages[i].SetTrackingURL(models.DefaultCourierDirectory())
+	}
+
+	html, err := templates.RenderTemplate("warehouse_pre_alert", data)
+	if err != nil {
+		t.Fatalf("RenderTemplate() error = %v", err)
+	}
+
+	expectedContent := []string{
+		"Package 1:",
+		"Package 2:",
+		"https://www.ups.com/track?tracknum=1Z0001",
+		"https://www.fedex.com/fedextrack/?tracknumbers=7770002",
+		"20 x 16 x 8 in",
+	}
+
+	for _, expected := range expectedContent {
+		if !strings.Contains(html, expected) {
+			t.Errorf("Rendered HTML missing expected content: %s", expected)
+		}
+	}
+}
+
 func TestEmailTemplates_RenderTemplate_ReleaseNotification(t *testing.T) {
 	templates := NewEmailTemplates()
 
@@ -256,6 +297,55 @@ func TestEmailTemplates_RenderTemplate_DeliveryConfirmation(t *testing.T) {
 	}
 }
 
+func TestEmailTemplates_RenderTemplate_AccountLinks(t *testing.T) {
+	templates := NewEmailTemplates()
+	expiresAt := time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		templateName string
+		data         interface{}
+		expected     []string
+	}{
+		{
+			name:         "invitation",
+			templateName: "user_invitation",
+			data: UserInvitationData{
+				RecipientEmail: "new.user@example.com",
+				InvitedBy:      "logistics@bairesdev.com",
+				Role:           "warehouse",
+				SetPasswordURL: "https://example.com/set-password?token=abc123",
+				ExpiresAt:      expiresAt,
+			},
+			expected: []string{"new.user@example.com", "logistics@bairesdev.com invited you", "warehouse", "https://example.com/set-password?token=abc123", "Set Your Password", "December 31, 2024"},
+		},
+		{
+			name:         "password reset",
+			templateName: "password_reset",
+			data: PasswordResetData{
+				RecipientEmail: "user@example.com",
+				ResetURL:       "https://example.com/set-password?token=def456",
+				ExpiresAt:      expiresAt,
+			},
+			expected: []string{"user@example.com", "https://example.com/set-password?token=def456", "Reset Password", "December 31, 2024"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			html, err := templates.RenderTemplate(tt.templateName, tt.data)
+			if err != nil {
+				t.Fatalf("RenderTemplate() error = %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(html, expected) {
+					t.Errorf("Rendered HTML missing expected content: %s", expected)
+				}
+			}
+		})
+	}
+}
+
 func TestEmailTemplates_RenderTemplate_InvalidTemplate(t *testing.T) {
 	templates := NewEmailTemplates()
 
@@ -330,6 +420,18 @@ func TestEmailTemplates_GetSubject(t *testing.T) {
 			data:         DeliveryConfirmationData{},
 			want:         "Device Delivered Successfully",
 		},
+		{
+			name:         "user invitation subject",
+			templateName: "user_invitation",
+			data:         UserInvitationData{},
+			want:         "You're invited to Align",
+		},
+		{
+			name:         "password reset subject",
+			templateName: "password_reset",
+			data:         PasswordResetData{},
+			want:         "Reset Your Align Password",
+		},
 	}
 
 	for _, tt := range tests {
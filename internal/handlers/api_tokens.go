@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// APITokenExpiryOptions are the expiries offered when creating a token, in days (0 never expires)
+var APITokenExpiryOptions = []int{30, 90, 365, 0}
+
+// APITokensHandler handles personal access tokens and service accounts
+type APITokensHandler struct {
+	DB        *sql.DB
+	Templates *template.Template
+}
+
+// NewAPITokensHandler creates a new APITokensHandler
+func NewAPITokensHandler(db *sql.DB, templates *template.Template) *APITokensHandler {
+	return &APITokensHandler{
+		DB:        db,
+		Templates: templates,
+	}
+}
+
+// apiTokenScopeOption is a scope checkbox on the token forms
+type apiTokenScopeOption struct {
+	Value       models.APITokenScope
+	Description string
+}
+
+// apiTokenTable is the data of the api-token-table template
+type apiTokenTable struct {
+	Tokens    []models.APIToken
+	CSRFToken string
+}
+
+// APITokensPage lists the user's personal access tokens, and for logistics users the service accounts
+func (h *APITokensHandler) APITokensPage(w http.ResponseWriter, r *http.Request) {
+	h.renderPage(w, r, "", nil)
+}
+
+// renderPage renders the API tokens page. newToken is shown once after a token is created.
+func (h *APITokensHandler) renderPage(w http.ResponseWriter, r *http.Request, newToken string, newTokenRecord *models.APIToken) {
+	user := middleware.GetUserFromContext(r.Context())
+	csrfToken := middleware.GetCSRFToken(r.Context())
+
+	tokens, err := models.GetAPITokensByUser(h.DB, user.ID)
+	if err != nil {
+		log.Printf("Error getting API tokens: %v", err)
+		http.Error(w, "Failed to load API tokens", http.StatusInternalServerError)
+		return
+	}
+
+	scopeOptions := make([]apiTokenScopeOption, len(models.AllAPITokenScopes))
+	for i, scope := range models.AllAPITokenScopes {
+		scopeOptions[i] = apiTokenScopeOption{Value: scope, Description: models.GetAPITokenScopeDescription(scope)}
+	}
+
+	data := map[string]interface{}{
+		"User":           user,
+		"Nav":            views.GetNavigationLinks(user.Role),
+		"CurrentPage":    "api-tokens",
+		"TokenTable":     apiTokenTable{Tokens: tokens, CSRFToken: csrfToken},
+		"ScopeOptions":   scopeOptions,
+		"ExpiryOptions":  APITokenExpiryOptions,
+		"NewToken":       newToken,
+		"NewTokenRecord": newTokenRecord,
+		"Success":        r.URL.Query().Get("success"),
+		"Error":          r.URL.Query().Get("error"),
+		"CSRFToken":      csrfToken,
+	}
+
+	canManage := permissions.Can(user, permissions.ServiceAccountManage)
+	data["CanManageServiceAccounts"] = canManage
+	if canManage {
+		accounts, err := models.GetAllServiceAccounts(h.DB)
+		if err != nil {
+			log.Printf("Error getting service accounts: %v", err)
+			http.Error(w, "Failed to load service accounts", http.StatusInternalServerError)
+			return
+		}
+		tokenTables := make(map[int64]apiTokenTable, len(accounts))
+		for i := range accounts {
+			accounts[i].Tokens, err = models.GetAPITokensByServiceAccount(h.DB, accounts[i].ID)
+			if err != nil {
+				log.Printf("Error getting service account tokens: %v", err)
+				http.Error(w, "Failed to load service accounts", http.StatusInternalServerError)
+				return
+			}
+			tokenTables[accounts[i].ID] = apiTokenTable{Tokens: accounts[i].Tokens, CSRFToken: csrfToken}
+		}
+
+		companies, err := models.GetAllClientCompanies(h.DB)
+		if err != nil {
+			log.Printf("Error getting client companies: %v", err)
+			http.Error(w, "Failed to load client companies", http.StatusInternalServerError)
+			return
+		}
+
+		data["ServiceAccounts"] = accounts
+		data["ServiceAccountTokenTables"] = tokenTables
+		data["ClientCompanies"] = companies
+		data["Roles"] = []models.UserRole{models.RoleLogistics, models.RoleWarehouse, models.RoleProjectManager, models.RoleClient}
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "api-tokens.html", data); err != nil {
+		log.Printf("Error executing API tokens template: %v", err)
+		http.Error(w, "Failed to render API tokens page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// CreatePersonalToken creates a personal access token for the logged-in user
+func (h *APITokensHandler) CreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	token, ok := h.parseTokenForm(w, r)
+	if !ok {
+		return
+	}
+	token.UserID = &user.ID
+	token.CreatedByUserID = &user.ID
+
+	h.createToken(w, r, user, token)
+}
+
+// CreateServiceAccountToken creates a token for a service account (logistics only)
+func (h *APITokensHandler) CreateServiceAccountToken(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.requireServiceAccountManage(w, r)
+	if !ok {
+		return
+	}
+
+	account, ok := h.loadServiceAccount(w, r)
+	if !ok {
+		return
+	}
+	if account.IsDisabled() {
+		redirectAPITokens(w, r, "error", "Enable the service account before creating tokens")
+		return
+	}
+
+	token, ok := h.parseTokenForm(w, r)
+	if !ok {
+		return
+	}
+	token.ServiceAccountID = &account.ID
+	token.CreatedByUserID = &user.ID
+
+	h.createToken(w, r, user, token)
+}
+
+// createToken stores the token and shows it once
+func (h *APITokensHandler) createToken(w http.ResponseWriter, r *http.Request, user *models.User, token *models.APIToken) {
+	value, err := auth.CreateAPIToken(r.Context(), h.DB, token)
+	if err != nil {
+		log.Printf("Error creating API token: %v", err)
+		redirectAPITokens(w, r, "error", "Failed to create API token: "+err.Error())
+		return
+	}
+
+	h.logAudit(r, user, "api_token_created", audit.EntityAPIToken, token.ID, map[string]interface{}{
+		"name":               token.Name,
+		"scopes":             token.Scopes,
+		"service_account_id": token.ServiceAccountID,
+		"expires_at":         token.ExpiresAt,
+	})
+
+	// Render instead of redirecting so the token never appears in a URL
+	r.URL.RawQuery = ""
+	h.renderPage(w, r, value, token)
+}
+
+// RevokeToken revokes a token. Users can revoke their own tokens, logistics users any token.
+func (h *APITokensHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid token ID", http.StatusBadRequest)
+		return
+	}
+
+	token, err := models.GetAPITokenByID(h.DB, id)
+	if errors.Is(err, models.ErrNotFound) {
+		http.Error(w, "API token not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting API token: %v", err)
+		http.Error(w, "Failed to load API token", http.StatusInternalServerError)
+		return
+	}
+
+	ownToken := token.UserID != nil && *token.UserID == user.ID
+	if !ownToken && !middleware.Authorize(w, r, permissions.ServiceAccountManage) {
+		return
+	}
+
+	if err := models.RevokeAPIToken(h.DB, id); err != nil {
+		log.Printf("Error revoking API token: %v", err)
+		http.Error(w, "Failed to revoke API token", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, user, "api_token_revoked", audit.EntityAPIToken, id, map[string]interface{}{
+		"name": token.Name,
+	})
+	redirectAPITokens(w, r, "success", fmt.Sprintf("Token %q revoked", token.Name))
+}
+
+// CreateServiceAccount creates a service account (logistics only)
+func (h *APITokensHandler) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.requireServiceAccountManage(w, r)
+	if !ok {
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	account := &models.ServiceAccount{
+		Name:            strings.TrimSpace(r.FormValue("name")),
+		Description:     strings.TrimSpace(r.FormValue("description")),
+		Role:            models.UserRole(r.FormValue("role")),
+		CreatedByUserID: &user.ID,
+	}
+	if account.Role == models.RoleClient {
+		if companyID, err := strconv.ParseInt(r.FormValue("client_company_id"), 10, 64); err == nil {
+			account.ClientCompanyID = &companyID
+		}
+	}
+
+	if err := models.CreateServiceAccount(h.DB, account); err != nil {
+		log.Printf("Error creating service account: %v", err)
+		redirectAPITokens(w, r, "error", "Failed to create service account: "+err.Error())
+		return
+	}
+
+	h.logAudit(r, user, "service_account_created", audit.EntityServiceAccount, account.ID, map[string]interface{}{
+		"name": account.Name,
+		"role": account.Role,
+	})
+	redirectAPITokens(w, r, "success", fmt.Sprintf("Service account %q created", account.Name))
+}
+
+// DisableServiceAccount disables a service account, rejecting all of its tokens (logistics only)
+func (h *APITokensHandler) DisableServiceAccount(w http.ResponseWriter, r *http.Request) {
+	h.setServiceAccountDisabled(w, r, true)
+}
+
+// EnableServiceAccount enables a disabled service account again (logistics only)
+func (h *APITokensHandler) EnableServiceAccount(w http.ResponseWriter, r *http.Request) {
+	h.setServiceAccountDisabled(w, r, false)
+}
+
+// setServiceAccountDisabled disables or enables the service account in the path
+func (h *APITokensHandler) setServiceAccountDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	user, ok := h.requireServiceAccountManage(w, r)
+	if !ok {
+		return
+	}
+	account, ok := h.loadServiceAccount(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.SetServiceAccountDisabled(h.DB, account.ID, disabled); err != nil {
+		log.Printf("Error updating service account: %v", err)
+		http.Error(w, "Failed to update service account", http.StatusInternalServerError)
+		return
+	}
+
+	action, message := "service_account_enabled", "enabled"
+	if disabled {
+		action, message = "service_account_disabled", "disabled"
+	}
+	h.logAudit(r, user, action, audit.EntityServiceAccount, account.ID, map[string]interface{}{
+		"name": account.Name,
+	})
+	redirectAPITokens(w, r, "success", fmt.Sprintf("Service account %q %s", account.Name, message))
+}
+
+// parseTokenForm reads the name, scopes and expiry of a new token
+func (h *APITokensHandler) parseTokenForm(w http.ResponseWriter, r *http.Request) (*models.APIToken, bool) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return nil, false
+	}
+
+	token := &models.APIToken{
+		Name:   strings.TrimSpace(r.FormValue("name")),
+		Scopes: models.ParseAPITokenScopes(r.Form["scopes"]),
+	}
+	if len(token.Scopes) == 0 {
+		token.Scopes = []models.APITokenScope{models.ScopeRead}
+	}
+
+	days, err := strconv.Atoi(r.FormValue("expires_in_days"))
+	if err != nil || days < 0 {
+		redirectAPITokens(w, r, "error", "Invalid expiry")
+		return nil, false
+	}
+	if days > 0 {
+		expiresAt := time.Now().AddDate(0, 0, days)
+		token.ExpiresAt = &expiresAt
+	}
+
+	return token, true
+}
+
+// requireServiceAccountManage returns the user if they may manage service accounts, otherwise it writes a 403
+func (h *APITokensHandler) requireServiceAccountManage(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	if !middleware.Authorize(w, r, permissions.ServiceAccountManage) {
+		return nil, false
+	}
+	return middleware.GetUserFromContext(r.Context()), true
+}
+
+// loadServiceAccount loads the service account in the path
+func (h *APITokensHandler) loadServiceAccount(w http.ResponseWriter, r *http.Request) (*models.ServiceAccount, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid service account ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	account, err := models.GetServiceAccountByID(h.DB, id)
+	if errors.Is(err, models.ErrNotFound) {
+		http.Error(w, "Service account not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Error getting service account: %v", err)
+		http.Error(w, "Failed to load service account", http.StatusInternalServerError)
+		return nil, false
+	}
+	return account, true
+}
+
+// logAudit writes an audit log entry for a token or service account change
+func (h *APITokensHandler) logAudit(r *http.Request, user *models.User, action, entityType string, entityID int64, details map[string]interface{}) {
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Details:    details,
+	})
+}
+
+// redirectAPITokens redirects back to the API tokens page with a message
+func redirectAPITokens(w http.ResponseWriter, r *http.Request, kind, message string) {
+	http.Redirect(w, r, "/api-tokens?"+kind+"="+url.QueryEscape(message), http.StatusSeeOther)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// auditLogPageSize is the number of audit entries shown per page
+const auditLogPageSize = 100
+
+// AuditLogsPage lists the audit log with filters by entity, user and date.
+// With ?format=csv it exports every matching entry instead.
+func (h *FormsHandler) AuditLogsPage(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.AuditLogView) {
+		return
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		http.Redirect(w, r, "/forms/audit-logs?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		entries, err := audit.List(r.Context(), h.DB, filter)
+		if err != nil {
+			log.Printf("Error exporting audit logs: %v", err)
+			http.Error(w, "Failed to export audit log", http.StatusInternalServerError)
+			return
+		}
+		exportAuditLogsCSV(w, entries)
+		return
+	}
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	filter.Limit = auditLogPageSize
+	filter.Offset = (page - 1) * auditLogPageSize
+
+	entries, err := audit.List(r.Context(), h.DB, filter)
+	if err != nil {
+		log.Printf("Error getting audit logs: %v", err)
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+	total, err := audit.Count(r.Context(), h.DB, filter)
+	if err != nil {
+		log.Printf("Error counting audit logs: %v", err)
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+	entityTypes, err := audit.EntityTypes(r.Context(), h.DB)
+	if err != nil {
+		log.Printf("Error getting audit entity types: %v", err)
+		http.Error(w, "Failed to load audit log", http.StatusInternalServerError)
+		return
+	}
+	users, err := models.GetAllUsers(h.DB)
+	if err != nil {
+		log.Printf("Error getting users: %v", err)
+		http.Error(w, "Failed to load users", http.StatusInternalServerError)
+		return
+	}
+
+	// Links to other pages and to the export keep the filters
+	query := r.URL.Query()
+	query.Del("error")
+	query.Del("page")
+	pageURL := func(key, value string) template.URL {
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set(key, value)
+		return template.URL("/forms/audit-logs?" + q.Encode())
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":        user,
+		"Nav":         views.GetNavigationLinks(user.Role),
+		"CurrentPage": "forms",
+		"Entries":     entries,
+		"Total":       total,
+		"Page":        page,
+		"HasPrev":     page > 1,
+		"HasNext":     filter.Offset+len(entries) < total,
+		"PrevURL":     pageURL("page", strconv.Itoa(page-1)),
+		"NextURL":     pageURL("page", strconv.Itoa(page+1)),
+		"ExportURL":   pageURL("format", "csv"),
+		"EntityTypes": entityTypes,
+		"Users":       users,
+		"Filter": map[string]string{
+			"EntityType": query.Get("entity_type"),
+			"EntityID":   query.Get("entity_id"),
+			"UserID":     query.Get("user_id"),
+			"From":       query.Get("from"),
+			"To":         query.Get("to"),
+		},
+		"Success": r.URL.Query().Get("success"),
+		"Error":   r.URL.Query().Get("error"),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "audit-logs.html", data); err != nil {
+		log.Printf("Error executing audit logs template: %v", err)
+		http.Error(w, "Failed to render audit log", http.StatusInternalServerError)
+		return
+	}
+}
+
+// parseAuditFilter reads the filters of the audit log page. Dates are whole days,
+// and the "to" date is included.
+func parseAuditFilter(query url.Values) (audit.Filter, error) {
+	filter := audit.Filter{EntityType: query.Get("entity_type")}
+
+	if value := query.Get("entity_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid entity ID")
+		}
+		filter.EntityID = id
+	}
+	if value := query.Get("user_id"); value != "" {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user")
+		}
+		filter.UserID = id
+	}
+	if value := query.Get("from"); value != "" {
+		from, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date")
+		}
+		filter.From = from
+	}
+	if value := query.Get("to"); value != "" {
+		to, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date")
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+// exportAuditLogsCSV writes the audit entries as CSV, one row per entry
+func exportAuditLogsCSV(w http.ResponseWriter, entries []*models.AuditLog) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=audit-log-%s.csv", time.Now().Format("2006-01-02")))
+
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	writer.Write([]string{"ID", "Timestamp", "Actor", "Action", "Entity Type", "Entity ID", "Changes", "Details"})
+
+	for _, entry := range entries {
+		changes := make([]string, 0, len(entry.Changes))
+		for _, change := range entry.Changes {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", change.Field, change.BeforeText(), change.AfterText()))
+		}
+
+		detailFields := entry.DetailFields()
+		keys := make([]string, 0, len(detailFields))
+		for key := range detailFields {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		details := make([]string, 0, len(keys))
+		for _, key := range keys {
+			details = append(details, key+"="+detailFields[key])
+		}
+
+		writer.Write([]string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.Timestamp.Format("2006-01-02 15:04:05"),
+			entry.ActorName,
+			entry.Action,
+			entry.EntityType,
+			strconv.FormatInt(entry.EntityID, 10),
+			strings.Join(changes, "; "),
+			strings.Join(details, "; "),
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"html/template"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -13,8 +14,10 @@ import (
 	"golang.org/x/oauth2"
 
 	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
 
@@ -23,7 +26,16 @@ type AuthHandler struct {
 	DB          *sql.DB
 	Templates   *template.Template
 	OAuthConfig *oauth2.Config
-	OAuthDomain string // Allowed domain for Google OAuth
+	OAuthDomain string          // Allowed domains for Google OAuth, comma-separated
+	Notifier    *email.Notifier // Sends password reset emails
+
+	OIDCProviders []*auth.OIDCProvider // OpenID Connect providers offered on the login page
+
+	LoginLimits       auth.LoginLimits // Brute-force protection of password and magic link logins
+	TrustProxyHeaders bool             // Use X-Forwarded-For as the client IP (behind a reverse proxy)
+
+	TwoFactorRequiredRoles map[models.UserRole]bool // Roles that must sign in with a TOTP code
+	TwoFactorIssuer        string                   // Name of the account in authenticator apps
 }
 
 // isProduction checks if the application is running in production
@@ -35,8 +47,10 @@ func isProduction() bool {
 // NewAuthHandler creates a new AuthHandler
 func NewAuthHandler(db *sql.DB, templates *template.Template) *AuthHandler {
 	return &AuthHandler{
-		DB:        db,
-		Templates: templates,
+		DB:              db,
+		Templates:       templates,
+		LoginLimits:     auth.DefaultLoginLimits,
+		TwoFactorIssuer: "Align",
 	}
 }
 
@@ -75,7 +89,10 @@ func (h *AuthHandler) LoginPage(w http.ResponseWriter, r *http.Request) {
 	errorMsg := r.URL.Query().Get("error")
 
 	data := map[string]interface{}{
-		"Error": errorMsg,
+		"Error":         errorMsg,
+		"Message":       r.URL.Query().Get("message"),
+		"OIDCProviders": h.OIDCProviders,
+		"CSRFToken":     middleware.GetCSRFToken(r.Context()),
 	}
 
 	err := h.Templates.ExecuteTemplate(w, "login.html", data)
@@ -108,20 +125,35 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attempt := auth.LoginAttempt{
+		Method:    auth.LoginMethodPassword,
+		Email:     email,
+		IPAddress: auth.ClientIP(r, h.TrustProxyHeaders),
+		UserAgent: r.UserAgent(),
+	}
+
+	// Throttle repeated failures from the same IP address or for the same account
+	if !h.checkLoginThrottle(w, r, attempt) {
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	err = h.DB.QueryRowContext(
 		r.Context(),
-		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at
+		`SELECT id, email, password_hash, role, client_company_id, google_id, created_at, updated_at, locked_until,
+			totp_enabled_at IS NOT NULL
 		FROM users
 		WHERE email = $1`,
 		email,
 	).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Role, &user.ClientCompanyID,
-		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt,
+		&user.GoogleID, &user.CreatedAt, &user.UpdatedAt, &user.LockedUntil,
+		&user.TwoFactorEnabled,
 	)
 
 	if err == sql.ErrNoRows {
+		h.recordLoginFailure(r, attempt, auth.LoginFailureUnknownEmail)
 		http.Redirect(w, r, "/login?error=Invalid+email+or+password", http.StatusSeeOther)
 		return
 	}
@@ -129,6 +161,7 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	attempt.UserID = &user.ID
 
 	// Check if user authenticated via Google OAuth (no password)
 	if user.IsGoogleUser() {
@@ -136,33 +169,93 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Users provisioned by an OpenID Connect provider sign in there
+	if user.IsExternalLoginUser() {
+		http.Redirect(w, r, "/login?error="+url.QueryEscape("Please sign in with your organization's single sign-on"), http.StatusSeeOther)
+		return
+	}
+
+	// Locked accounts refuse passwords until the lock expires or an admin unlocks them
+	if user.IsLocked() {
+		h.recordLoginFailure(r, attempt, auth.LoginFailureLocked)
+		http.Redirect(w, r, "/login?error="+url.QueryEscape(lockedMessage(*user.LockedUntil)), http.StatusSeeOther)
+		return
+	}
+
 	// Verify password
 	if !auth.CheckPasswordHash(password, user.PasswordHash) {
+		h.recordLoginFailure(r, attempt, auth.LoginFailureInvalidPassword)
+
+		lockedUntil, err := auth.RegisterFailedLogin(r.Context(), h.DB, user.ID, h.LoginLimits)
+		if err != nil {
+			log.Printf("Error registering failed login: %v", err)
+		}
+		if lockedUntil != nil {
+			log.Printf("Account locked: user %d after %d failed logins", user.ID, h.LoginLimits.LockoutThreshold)
+			h.auditAccountLocked(r, user.ID, *lockedUntil)
+			http.Redirect(w, r, "/login?error="+url.QueryEscape(lockedMessage(*lockedUntil)), http.StatusSeeOther)
+			return
+		}
+
 		http.Redirect(w, r, "/login?error=Invalid+email+or+password", http.StatusSeeOther)
 		return
 	}
 
-	// Create session
-	session, err := auth.CreateSession(r.Context(), h.DB, user.ID, auth.DefaultSessionDuration)
+	attempt.Success = true
+	if err := auth.RecordLoginAttempt(r.Context(), h.DB, attempt); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
+	if err := auth.ResetFailedLogins(r.Context(), h.DB, user.ID); err != nil {
+		log.Printf("Error resetting failed logins: %v", err)
+	}
+
+	// Ask for the second factor before the session is created, then redirect based on user role
+	h.completeLogin(w, r, &user, getRedirectURLForRole(user.Role))
+}
+
+// checkLoginThrottle refuses the attempt if its IP address or account had too many recent failures.
+// Returns false if a response was written.
+func (h *AuthHandler) checkLoginThrottle(w http.ResponseWriter, r *http.Request, attempt auth.LoginAttempt) bool {
+	retry, err := auth.CheckLoginThrottle(r.Context(), h.DB, attempt.IPAddress, attempt.Email, h.LoginLimits)
 	if err != nil {
-		http.Error(w, "Failed to create session", http.StatusInternalServerError)
-		return
+		log.Printf("Error checking login throttle: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if retry == 0 {
+		return true
 	}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.SessionCookieName,
-		Value:    session.Token,
-		Path:     "/",
-		Expires:  session.ExpiresAt,
-		HttpOnly: true,
-		Secure:   isProduction(), // Only require HTTPS in production
-		SameSite: http.SameSiteStrictMode,
+	h.recordLoginFailure(r, attempt, auth.LoginFailureThrottled)
+	log.Printf("Login throttled: %s attempt from %s for %q", attempt.Method, attempt.IPAddress, attempt.Email)
+
+	minutes := int(retry.Minutes()) + 1
+	message := fmt.Sprintf("Too many failed login attempts. Please try again in %d minute(s).", minutes)
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(retry.Seconds())+1))
+	http.Redirect(w, r, "/login?error="+url.QueryEscape(message), http.StatusSeeOther)
+	return false
+}
+
+// recordLoginFailure stores a failed login attempt; a failure to record it is only logged
+func (h *AuthHandler) recordLoginFailure(r *http.Request, attempt auth.LoginAttempt, reason string) {
+	attempt.Success = false
+	attempt.Reason = reason
+	if err := auth.RecordLoginAttempt(r.Context(), h.DB, attempt); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
+}
+
+// auditAccountLocked records that an account was locked after too many failed logins
+func (h *AuthHandler) auditAccountLocked(r *http.Request, userID int64, lockedUntil time.Time) {
+	h.logAudit(r, userID, "account_locked", map[string]interface{}{
+		"locked_until": lockedUntil,
 	})
+}
 
-	// Redirect based on user role
-	redirectURL := getRedirectURLForRole(user.Role)
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+// lockedMessage tells a user until when their account is locked
+func lockedMessage(lockedUntil time.Time) string {
+	minutes := int(time.Until(lockedUntil).Minutes()) + 1
+	return fmt.Sprintf("This account is locked after too many failed login attempts. Try again in %d minute(s) or ask an administrator to unlock it.", minutes)
 }
 
 // Logout handles user logout
@@ -281,6 +374,17 @@ func (h *AuthHandler) MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	attempt := auth.LoginAttempt{
+		Method:    auth.LoginMethodMagicLink,
+		IPAddress: auth.ClientIP(r, h.TrustProxyHeaders),
+		UserAgent: r.UserAgent(),
+	}
+
+	// Throttle IP addresses probing for tokens
+	if !h.checkLoginThrottle(w, r, attempt) {
+		return
+	}
+
 	// Validate magic link
 	magicLink, err := auth.ValidateMagicLink(r.Context(), h.DB, token)
 	if err != nil {
@@ -289,27 +393,23 @@ func (h *AuthHandler) MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if magicLink == nil {
+		h.recordLoginFailure(r, attempt, auth.LoginFailureInvalidToken)
 		http.Redirect(w, r, "/login?error=Magic+link+is+invalid+or+has+expired", http.StatusSeeOther)
 		return
 	}
 
-	// Create session (magic link will be marked as used when form is submitted)
-	session, err := auth.CreateSession(r.Context(), h.DB, magicLink.UserID, auth.DefaultSessionDuration)
+	user, err := models.GetUserByID(h.DB, magicLink.UserID)
 	if err != nil {
-		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		log.Printf("Error getting magic link user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.SessionCookieName,
-		Value:    session.Token,
-		Path:     "/",
-		Expires:  session.ExpiresAt,
-		HttpOnly: true,
-		Secure:   isProduction(),
-		SameSite: http.SameSiteStrictMode,
-	})
+	attempt.UserID = &magicLink.UserID
+	attempt.Success = true
+	if err := auth.RecordLoginAttempt(r.Context(), h.DB, attempt); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
 
 	// Redirect based on context
 	redirectURL := "/dashboard"
@@ -318,7 +418,9 @@ func (h *AuthHandler) MagicLinkLogin(w http.ResponseWriter, r *http.Request) {
 		redirectURL = fmt.Sprintf("/shipments/%d/form", *magicLink.ShipmentID)
 	}
 
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	// Create session, or ask for the second factor first (magic link will be marked as used when form is submitted).
+	// Magic links are opened from emails, so the redirect must not continue that cross-site navigation.
+	h.completeLogin(&sameSiteRedirectWriter{ResponseWriter: w}, r, user, redirectURL)
 }
 
 // SendMagicLink generates and sends a magic link to the user
@@ -329,10 +431,7 @@ func (h *AuthHandler) SendMagicLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only logistics users can send magic links
-	user := middleware.GetUserFromContext(r.Context())
-	if user == nil || user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.MagicLinkManage) {
 		return
 	}
 
@@ -466,16 +565,14 @@ func (h *AuthHandler) MagicLinksList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only logistics users can view magic links
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.MagicLinkManage) {
 		return
 	}
 
 	// Query magic links with associated user and shipment info
 	query := `
 		SELECT 
-			ml.id, ml.token, ml.user_id, ml.shipment_id, ml.expires_at, 
+			ml.id, ml.user_id, ml.shipment_id, ml.expires_at, 
 			ml.used_at, ml.created_at,
 			u.email as user_email,
 			COALESCE(s.id, 0) as shipment_id_coalesce,
@@ -484,6 +581,7 @@ func (h *AuthHandler) MagicLinksList(w http.ResponseWriter, r *http.Request) {
 		JOIN users u ON u.id = ml.user_id
 		LEFT JOIN shipments s ON s.id = ml.shipment_id
 		LEFT JOIN client_companies cc ON cc.id = s.client_company_id
+		WHERE ml.purpose = 'login'
 		ORDER BY ml.created_at DESC
 		LIMIT 100
 	`
@@ -497,7 +595,6 @@ func (h *AuthHandler) MagicLinksList(w http.ResponseWriter, r *http.Request) {
 
 	type MagicLinkDisplay struct {
 		ID          int64
-		Token       string
 		UserEmail   string
 		ShipmentID  *int64
 		CompanyName string
@@ -515,7 +612,7 @@ func (h *AuthHandler) MagicLinksList(w http.ResponseWriter, r *http.Request) {
 		var companyName string
 		
 		err := rows.Scan(
-			&link.ID, &link.Token, new(int64), &link.ShipmentID, &link.ExpiresAt,
+			&link.ID, new(int64), &link.ShipmentID, &link.ExpiresAt,
 			&link.UsedAt, &link.CreatedAt, &link.UserEmail,
 			&shipmentIDCoalesce, &companyName,
 		)
@@ -539,6 +636,7 @@ func (h *AuthHandler) MagicLinksList(w http.ResponseWriter, r *http.Request) {
 		"Nav":         views.GetNavigationLinks(user.Role),
 		"CurrentPage": "magic-links",
 		"MagicLinks":  links,
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	err = h.Templates.ExecuteTemplate(w, "magic-links-list.html", data)
@@ -648,25 +746,6 @@ func (h *AuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create session
-	session, err := auth.CreateSession(r.Context(), h.DB, user.ID, auth.DefaultSessionDuration)
-	if err != nil {
-		http.Error(w, "Failed to create session", http.StatusInternalServerError)
-		return
-	}
-
-	// Set session cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     middleware.SessionCookieName,
-		Value:    session.Token,
-		Path:     "/",
-		Expires:  session.ExpiresAt,
-		HttpOnly: true,
-		Secure:   isProduction(),
-		SameSite: http.SameSiteStrictMode,
-	})
-
-	// Redirect based on user role
-	redirectURL := getRedirectURLForRole(user.Role)
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	// Create session, or ask for the second factor first, then redirect based on user role
+	h.completeLogin(&sameSiteRedirectWriter{ResponseWriter: w}, r, user, getRedirectURLForRole(user.Role))
 }
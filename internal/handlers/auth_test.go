@@ -18,6 +18,7 @@ import (
 	"github.com/yourusername/laptop-tracking-system/internal/database"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/utils"
 )
 
@@ -67,6 +68,11 @@ func loadTestTemplates(t *testing.T) *template.Template {
 				return 0
 			}
 		},
+		"can": func(user *models.User, perm string) bool {
+			return permissions.Can(user, permissions.Permission(perm))
+		},
+		// Hidden CSRF token field for POST forms
+		"csrfField": middleware.CSRFField,
 		// Calendar template functions
 		"formatDate": func(t time.Time) string {
 			return t.Format("Jan 2, 2006")
@@ -327,6 +333,18 @@ func TestLoginPage(t *testing.T) {
 		}
 	})
 
+	t.Run("login form carries the CSRF token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/login", nil)
+		req = req.WithContext(context.WithValue(req.Context(), middleware.CSRFContextKey, "test-csrf-token"))
+		w := httptest.NewRecorder()
+
+		handler.LoginPage(w, req)
+
+		if !strings.Contains(w.Body.String(), `<input type="hidden" name="csrf_token" value="test-csrf-token">`) {
+			t.Error("Expected the login form to contain the CSRF token field")
+		}
+	})
+
 	t.Run("authenticated user redirects to dashboard", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/login", nil)
 
@@ -734,8 +752,12 @@ func TestMagicLinkLogin(t *testing.T) {
 
 		handler.MagicLinkLogin(w, req)
 
-		if w.Code != http.StatusSeeOther {
-			t.Errorf("Expected status 303, got %d", w.Code)
+		// The redirect is a page that navigates to the dashboard, so the SameSite=Strict session cookie is sent
+		if w.Code != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `url=/dashboard`) {
+			t.Errorf("Expected a redirect to /dashboard, got %s", w.Body.String())
 		}
 
 		// Check session cookie was set
@@ -58,16 +58,13 @@ func (h *CalendarHandler) Calendar(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get calendar events
-	// For client users, filter by their company ID; for warehouse users, filter by warehouse statuses
-	var clientCompanyID *int64
+	// For client users and project managers, filter by their companies; for warehouse users, filter by warehouse statuses
 	var userRole *models.UserRole
-	if user.Role == models.RoleClient && user.ClientCompanyID != nil {
-		clientCompanyID = user.ClientCompanyID
-	} else if user.Role == models.RoleWarehouse {
+	if user.Role == models.RoleWarehouse {
 		userRole = &user.Role
 	}
 
-	events, err := models.GetCalendarEvents(h.DB, startDate, endDate, clientCompanyID, userRole)
+	events, err := models.GetCalendarEventsForCompanies(h.DB, startDate, endDate, user.CompanyScope().IDs(), userRole)
 	if err != nil {
 		log.Printf("Error getting calendar events: %v", err)
 		http.Error(w, "Failed to load calendar events", http.StatusInternalServerError)
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/documents"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// CommercialInvoice downloads the commercial invoice PDF of a delivery to an engineer,
+// for customs on international shipments
+func (h *ShipmentsHandler) CommercialInvoice(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if !middleware.Authorize(w, r, permissions.ShipmentPrintDocuments) {
+		return
+	}
+
+	shipmentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	invoice, err := models.GetCommercialInvoice(r.Context(), h.DB, shipmentID, h.Warehouse)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error loading commercial invoice: %v\n", err)
+		http.Error(w, "Failed to load commercial invoice", http.StatusInternalServerError)
+		return
+	}
+
+	if !models.CanHaveCommercialInvoice(invoice.Shipment.ShipmentType) {
+		http.Error(w, "Commercial invoices are only available for deliveries to engineers", http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := documents.WriteCommercialInvoice(&buf, invoice); err != nil {
+		fmt.Printf("Error generating commercial invoice PDF: %v\n", err)
+		http.Error(w, "Failed to generate commercial invoice", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=commercial-invoice-%d.pdf", shipmentID))
+	w.Write(buf.Bytes())
+}
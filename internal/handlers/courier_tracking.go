@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/tracking"
+)
+
+// CourierWebhookHandler receives tracking pushes from courier providers
+type CourierWebhookHandler struct {
+	Tracking *tracking.Service
+}
+
+// NewCourierWebhookHandler creates a new CourierWebhookHandler
+func NewCourierWebhookHandler(trackingService *tracking.Service) *CourierWebhookHandler {
+	return &CourierWebhookHandler{
+		Tracking: trackingService,
+	}
+}
+
+// Receive handles a webhook push from the provider named in the URL.
+// Providers authenticate pushes with their own signature scheme, so this route is public.
+func (h *CourierWebhookHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	if h.Tracking == nil || h.Tracking.Registry.Empty() {
+		http.Error(w, "Courier tracking is not enabled", http.StatusNotFound)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	result, err := h.Tracking.HandleWebhook(r.Context(), provider, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, tracking.ErrProviderNotFound):
+			http.Error(w, "Unknown tracking provider", http.StatusNotFound)
+		case errors.Is(err, tracking.ErrInvalidSignature):
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		case errors.Is(err, tracking.ErrWebhooksNotSupported):
+			http.Error(w, "Webhooks not supported for this provider", http.StatusNotFound)
+		default:
+			fmt.Printf("Error ingesting courier webhook from %s: %v\n", provider, err)
+			http.Error(w, "Failed to process webhook", http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"recorded":   result.Recorded,
+		"duplicates": result.Duplicates,
+		"unmatched":  result.Unmatched,
+		"advanced":   result.Advanced,
+	})
+}
+
+// RefreshShipmentTracking polls the couriers of a shipment right away (logistics only)
+func (h *ShipmentsHandler) RefreshShipmentTracking(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if !middleware.Authorize(w, r, permissions.ShipmentRefreshTracking) {
+		return
+	}
+
+	shipmentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	if h.Tracking == nil || h.Tracking.Registry.Empty() {
+		redirectURL := fmt.Sprintf("/shipments/%d?error=Courier+tracking+is+not+enabled", shipmentID)
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	result, err := h.Tracking.PollShipment(r.Context(), shipmentID)
+	if err != nil {
+		fmt.Printf("Error refreshing courier tracking: %v\n", err)
+		redirectURL := fmt.Sprintf("/shipments/%d?error=Failed+to+refresh+courier+tracking", shipmentID)
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	message := fmt.Sprintf("Tracking refreshed: %d new checkpoint(s)", result.Recorded)
+	if len(result.Advanced) > 0 {
+		message += fmt.Sprintf(", %d status change(s)", len(result.Advanced))
+	}
+	redirectURL := fmt.Sprintf("/shipments/%d?success=%s", shipmentID, url.QueryEscape(message))
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
 
@@ -34,9 +35,7 @@ func (h *DashboardHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only logistics and project manager users can access the dashboard
-	if user.Role != models.RoleLogistics && user.Role != models.RoleProjectManager {
-		http.Error(w, "Forbidden: Only logistics and project manager users can access this page", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.DashboardView) {
 		return
 	}
 
@@ -51,9 +50,10 @@ func (h *DashboardHandler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	// Prepare template data
 	data := map[string]interface{}{
 		"User":        user,
-		"Stats":       stats,
-		"Nav":         views.GetNavigationLinks(user.Role),
-		"CurrentPage": "dashboard",
+		"Stats":             stats,
+		"ExceptionStatuses": models.GetExceptionStatuses(),
+		"Nav":               views.GetNavigationLinks(user.Role),
+		"CurrentPage":       "dashboard",
 	}
 
 	// Execute template using pre-parsed global templates
@@ -1,18 +1,23 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"log"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
 
 	"github.com/yourusername/laptop-tracking-system/internal/auth"
 	"github.com/yourusername/laptop-tracking-system/internal/email"
@@ -20,6 +25,7 @@ import (
 	"github.com/yourusername/laptop-tracking-system/internal/models"
 	"github.com/yourusername/laptop-tracking-system/internal/validator"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
+	"github.com/yourusername/laptop-tracking-system/internal/workflow"
 )
 
 const (
@@ -139,6 +145,7 @@ func (h *DeliveryFormHandler) DeliveryFormPage(w http.ResponseWriter, r *http.Re
 		"EngineerName": engineerName.String,
 		"EngineerID":   engineerID.Int64,
 		"Engineers":    engineers,
+		"CSRFToken":    middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -263,74 +270,94 @@ func (h *DeliveryFormHandler) DeliveryFormSubmit(w http.ResponseWriter, r *http.
 		return
 	}
 
-	// Start transaction
-	tx, err := h.DB.BeginTx(r.Context(), nil)
-	if err != nil {
-		http.Error(w, "Failed to start transaction", http.StatusInternalServerError)
-		return
-	}
-	defer tx.Rollback()
+	// Deliver the shipment through the workflow engine. The delivery form and the engineer
+	// are saved in the same transaction as the status change, and the delivery notifications
+	// (Step 11-12 in process flow) are queued by the workflow's effects.
+	user := middleware.GetUserFromContext(r.Context())
+	input := workflow.TransitionInput{
+		Source:  models.StatusEventSourceUI,
+		Comment: "Delivery form submitted",
+		Persist: func(ctx context.Context, tx *sql.Tx) error {
+			deliveryForm := models.DeliveryForm{
+				ShipmentID: shipmentID,
+				EngineerID: engineerID,
+				Notes:      notes,
+				PhotoURLs:  photoURLs,
+			}
+			deliveryForm.BeforeCreate()
+
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO delivery_forms (shipment_id, engineer_id, delivered_at, notes, photo_urls)
+				VALUES ($1, $2, $3, $4, $5)`,
+				deliveryForm.ShipmentID, deliveryForm.EngineerID, deliveryForm.DeliveredAt,
+				deliveryForm.Notes, pq.Array(deliveryForm.PhotoURLs),
+			)
+			if err != nil {
+				return fmt.Errorf("failed to save delivery form: %w", err)
+			}
 
-	// Create delivery form
-	deliveryForm := models.DeliveryForm{
-		ShipmentID: shipmentID,
-		EngineerID: engineerID,
-		Notes:      notes,
-		PhotoURLs:  photoURLs,
+			// Set the engineer if not already set
+			_, err = tx.ExecContext(ctx,
+				`UPDATE shipments SET software_engineer_id = $1 WHERE id = $2`,
+				engineerID, shipmentID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to set shipment engineer: %w", err)
+			}
+			return nil
+		},
 	}
-	deliveryForm.BeforeCreate()
-
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO delivery_forms (shipment_id, engineer_id, delivered_at, notes, photo_urls)
-		VALUES ($1, $2, $3, $4, $5)`,
-		deliveryForm.ShipmentID, deliveryForm.EngineerID, deliveryForm.DeliveredAt,
-		deliveryForm.Notes, pq.Array(deliveryForm.PhotoURLs),
-	)
-	if err != nil {
-		http.Error(w, "Failed to save delivery form", http.StatusInternalServerError)
-		return
+	if user != nil {
+		input.ActorUserID = &user.ID
 	}
 
-	// Update shipment status to "delivered" and set engineer if not already set
-	now := time.Now()
-	_, err = tx.ExecContext(r.Context(),
-		`UPDATE shipments 
-		SET status = $1, software_engineer_id = $2, delivered_at = $3, updated_at = $4
-		WHERE id = $5`,
-		models.ShipmentStatusDelivered, engineerID, now, now, shipmentID,
-	)
+	result, err := workflow.NewEngine(h.DB, h.Notifier).Transition(r.Context(), shipmentID, models.ShipmentStatusDelivered, input)
 	if err != nil {
-		http.Error(w, "Failed to update shipment status", http.StatusInternalServerError)
-		return
-	}
+		// Nothing was saved, so the uploaded photos are not referenced anywhere
+		for _, photoURL := range photoURLs {
+			os.Remove("." + photoURL)
+		}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
+		var guardErr *workflow.GuardError
+		var message string
+		switch {
+		case errors.As(err, &guardErr):
+			message = guardErr.Message
+		case errors.Is(err, workflow.ErrInvalidTransition):
+			message = "This shipment cannot be marked as delivered from its current status"
+		case errors.Is(err, workflow.ErrConcurrentUpdate):
+			message = "Shipment status was changed by someone else. Please reload the page and try again."
+		case errors.Is(err, workflow.ErrShipmentNotFound):
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+			return
+		default:
+			log.Printf("Error submitting delivery form for shipment %d: %v", shipmentID, err)
+			http.Error(w, "Failed to update shipment status", http.StatusInternalServerError)
+			return
+		}
+		redirectURL := fmt.Sprintf("/delivery-form?shipment_id=%d&error=%s", shipmentID, url.QueryEscape(message))
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 		return
 	}
 
 	// Mark magic link as used (if accessed via magic link)
-	user := middleware.GetUserFromContext(r.Context())
 	if user != nil {
-		magicLinkToken, err := auth.GetMagicLinkByShipmentAndUser(r.Context(), h.DB, shipmentID, user.ID)
-		if err == nil && magicLinkToken != "" {
-			_ = auth.MarkMagicLinkAsUsed(r.Context(), h.DB, magicLinkToken)
+		if err := auth.MarkShipmentMagicLinkAsUsed(r.Context(), h.DB, shipmentID, user.ID); err != nil {
 			// Log error but don't fail the request if marking as used fails
+			fmt.Printf("Warning: Failed to mark magic link as used: %v\n", err)
 		}
 	}
 
-	// Send delivery confirmation email (Step 11-12 in process flow)
-	if h.Notifier != nil {
-		if err := h.Notifier.SendDeliveryConfirmation(r.Context(), shipmentID); err != nil {
-			// Log error but don't fail the request
-			fmt.Printf("Warning: Failed to send delivery confirmation email: %v\n", err)
-		}
-	}
-
-	// Create audit log entry outside transaction (non-critical, user_id would need to be set)
-	// Skipping for now as we don't have a valid user_id for delivery forms
-	// In production, you might want to add a system user or make user_id nullable
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "delivery_confirmed",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Changes:    []models.AuditChange{{Field: "status", Before: result.From, After: result.To}},
+		Details: map[string]interface{}{
+			"engineer_id": engineerID,
+			"photo_count": len(photoURLs),
+		},
+	})
 
 	// Redirect to success page or shipment detail
 	redirectURL := fmt.Sprintf("/shipments/%d?success=Delivery+confirmed+successfully", shipmentID)
@@ -217,6 +217,60 @@ func TestDeliveryFormSubmit(t *testing.T) {
 		}
 	})
 
+	t.Run("shipment not in transit to engineer is rejected", func(t *testing.T) {
+		var pendingShipmentID int64
+		err := db.QueryRowContext(ctx,
+			`INSERT INTO shipments (client_company_id, status, jira_ticket_number, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+			companyID, models.ShipmentStatusAtWarehouse, "TEST-103", time.Now(), time.Now(),
+		).Scan(&pendingShipmentID)
+		if err != nil {
+			t.Fatalf("Failed to create test shipment: %v", err)
+		}
+
+		formData := url.Values{}
+		formData.Set("shipment_id", strconv.FormatInt(pendingShipmentID, 10))
+		formData.Set("engineer_id", strconv.FormatInt(engineerID, 10))
+
+		req := httptest.NewRequest(http.MethodPost, "/delivery-form", strings.NewReader(formData.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		w := httptest.NewRecorder()
+		handler.DeliveryFormSubmit(w, req)
+
+		if w.Code != http.StatusSeeOther {
+			t.Errorf("Expected status 303, got %d", w.Code)
+		}
+		if location := w.Header().Get("Location"); !strings.Contains(location, "error=") {
+			t.Errorf("Expected redirect with an error, got %s", location)
+		}
+
+		// Neither the form nor the status change were saved
+		var count int
+		err = db.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM delivery_forms WHERE shipment_id = $1`,
+			pendingShipmentID,
+		).Scan(&count)
+		if err != nil {
+			t.Fatalf("Failed to query delivery forms: %v", err)
+		}
+		if count != 0 {
+			t.Errorf("Expected no delivery form, got %d", count)
+		}
+
+		var status models.ShipmentStatus
+		err = db.QueryRowContext(ctx,
+			`SELECT status FROM shipments WHERE id = $1`,
+			pendingShipmentID,
+		).Scan(&status)
+		if err != nil {
+			t.Fatalf("Failed to query shipment status: %v", err)
+		}
+		if status != models.ShipmentStatusAtWarehouse {
+			t.Errorf("Expected shipment status 'at_warehouse', got '%s'", status)
+		}
+	})
+
 	t.Run("non-POST method returns error", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/delivery-form", nil)
 		w := httptest.NewRecorder()
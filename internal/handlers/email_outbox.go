@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// emailOutboxPageSize is the number of failed messages shown
+const emailOutboxPageSize = 200
+
+// EmailOutboxPage lists the notification emails that failed to send: those waiting for
+// another attempt and the dead-lettered ones that wait for a retry
+func (h *FormsHandler) EmailOutboxPage(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailOutboxManage) {
+		return
+	}
+
+	messages, err := email.FailedOutboxMessages(r.Context(), h.DB, emailOutboxPageSize)
+	if err != nil {
+		log.Printf("Error getting email outbox: %v", err)
+		http.Error(w, "Failed to load email outbox", http.StatusInternalServerError)
+		return
+	}
+	counts, err := email.OutboxCounts(r.Context(), h.DB)
+	if err != nil {
+		log.Printf("Error counting email outbox: %v", err)
+		http.Error(w, "Failed to load email outbox", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":         user,
+		"Nav":          views.GetNavigationLinks(user.Role),
+		"CurrentPage":  "forms",
+		"Messages":     messages,
+		"Counts":       counts,
+		"EmailEnabled": h.Notifier != nil,
+		"Success":      r.URL.Query().Get("success"),
+		"Error":        r.URL.Query().Get("error"),
+		"CSRFToken":    middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "email-outbox.html", data); err != nil {
+		log.Printf("Error executing email outbox template: %v", err)
+		http.Error(w, "Failed to render email outbox", http.StatusInternalServerError)
+		return
+	}
+}
+
+// EmailOutboxRetry schedules a failed notification email for delivery at the next poll
+func (h *FormsHandler) EmailOutboxRetry(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailOutboxManage) {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid message ID", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := email.RetryOutboxMessage(r.Context(), h.DB, id)
+	if errors.Is(err, email.ErrOutboxMessageNotFound) {
+		http.Redirect(w, r, "/forms/email-outbox?error="+url.QueryEscape("Message not found or already sent"), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("Error retrying email outbox message: %v", err)
+		http.Redirect(w, r, "/forms/email-outbox?error="+url.QueryEscape("Failed to retry message"), http.StatusSeeOther)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "email_retried",
+		EntityType: audit.EntityEmailOutbox,
+		EntityID:   msg.ID,
+		Details: map[string]interface{}{
+			"kind":       msg.Kind,
+			"subject_id": msg.SubjectID,
+		},
+	})
+
+	http.Redirect(w, r, "/forms/email-outbox?success="+url.QueryEscape("Message queued for delivery"), http.StatusSeeOther)
+}
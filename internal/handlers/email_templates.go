@@ -0,0 +1,343 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// ========== EMAIL TEMPLATE HANDLERS ==========
+
+// EmailTemplatesList displays every notification email template with its current version
+func (h *FormsHandler) EmailTemplatesList(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailTemplateManage) {
+		return
+	}
+
+	summaries, err := email.ListTemplates(r.Context(), h.DB)
+	if err != nil {
+		log.Printf("Error getting email templates: %v", err)
+		http.Error(w, "Failed to load email templates", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":        user,
+		"Nav":         views.GetNavigationLinks(user.Role),
+		"CurrentPage": "forms",
+		"Templates":   summaries,
+		"Success":     r.URL.Query().Get("success"),
+		"Error":       r.URL.Query().Get("error"),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "email-templates-list.html", data); err != nil {
+		log.Printf("Error executing email templates list template: %v", err)
+		http.Error(w, "Failed to render email templates list", http.StatusInternalServerError)
+		return
+	}
+}
+
+// EmailTemplateEditPage displays the editor for a template with its version history.
+// The version query parameter loads an earlier version into the editor.
+func (h *FormsHandler) EmailTemplateEditPage(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailTemplateManage) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if _, ok := email.DefaultTemplate(name); !ok {
+		http.Error(w, "Email template not found", http.StatusNotFound)
+		return
+	}
+
+	content, err := h.currentEmailTemplate(r.Context(), name)
+	if err != nil {
+		log.Printf("Error getting email template: %v", err)
+		http.Error(w, "Failed to load email template", http.StatusInternalServerError)
+		return
+	}
+
+	loadedVersion := 0
+	if v := r.URL.Query().Get("version"); v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		old, err := email.GetTemplateVersion(r.Context(), h.DB, name, version)
+		if errors.Is(err, email.ErrTemplateNotFound) {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error getting email template version: %v", err)
+			http.Error(w, "Failed to load email template", http.StatusInternalServerError)
+			return
+		}
+		content = old.Content()
+		loadedVersion = old.Version
+	}
+
+	h.renderEmailTemplateForm(w, r, name, content, map[string]interface{}{
+		"LoadedVersion": loadedVersion,
+		"Success":       r.URL.Query().Get("success"),
+		"Error":         r.URL.Query().Get("error"),
+	})
+}
+
+// EmailTemplateEditSubmit saves the edited template as a new version.
+// A template that does not render is shown again with the error instead of being saved.
+func (h *FormsHandler) EmailTemplateEditSubmit(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailTemplateManage) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if _, ok := email.DefaultTemplate(name); !ok {
+		http.Error(w, "Email template not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	content := emailTemplateContentFromForm(r)
+
+	previous, err := h.currentEmailTemplate(r.Context(), name)
+	if err != nil {
+		log.Printf("Error loading email template: %v", err)
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	version, err := email.SaveTemplate(r.Context(), h.DB, name, content, &user.ID, r.FormValue("comment"))
+	if err != nil {
+		log.Printf("Error saving email template %s: %v", name, err)
+		h.renderEmailTemplateForm(w, r, name, content, map[string]interface{}{
+			"Comment": r.FormValue("comment"),
+			"Error":   "The template was not saved: " + err.Error(),
+		})
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "email_template_updated",
+		EntityType: audit.EntityEmailTemplate,
+		EntityID:   version.ID,
+		Changes:    audit.Diff(previous, content),
+		Details: map[string]interface{}{
+			"template": name,
+			"version":  version.Version,
+			"comment":  version.Comment,
+		},
+	})
+
+	http.Redirect(w, r, emailTemplateEditURL(name)+"?success="+url.QueryEscape(fmt.Sprintf("Saved as version %d", version.Version)), http.StatusSeeOther)
+}
+
+// EmailTemplatePreview renders the template in the editor without saving it, for a real shipment
+// (or reception report) when one is given and with sample data otherwise
+func (h *FormsHandler) EmailTemplatePreview(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailTemplateManage) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if _, ok := email.DefaultTemplate(name); !ok {
+		http.Error(w, "Email template not found", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	content := emailTemplateContentFromForm(r)
+
+	data := map[string]interface{}{
+		"Comment": r.FormValue("comment"),
+	}
+
+	var subjectID int64
+	if v := strings.TrimSpace(r.FormValue("preview_subject_id")); v != "" && email.PreviewSubject(name) != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			data["PreviewError"] = "Enter a valid " + email.PreviewSubject(name) + " ID"
+			h.renderEmailTemplateForm(w, r, name, content, data)
+			return
+		}
+		subjectID = id
+	}
+	data["PreviewSubjectID"] = subjectID
+
+	notifier := h.Notifier
+	if notifier == nil {
+		// Previews are never sent, so they work without email configured
+		notifier = email.NewNotifier(nil, h.DB)
+	}
+	message, err := notifier.PreviewTemplate(r.Context(), name, content, subjectID)
+	if err != nil {
+		data["PreviewError"] = err.Error()
+	} else {
+		data["Preview"] = message
+	}
+
+	h.renderEmailTemplateForm(w, r, name, content, data)
+}
+
+// EmailTemplateRestoreVersion rolls a template back by saving an earlier version as a new one
+func (h *FormsHandler) EmailTemplateRestoreVersion(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailTemplateManage) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	restored, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := h.currentEmailTemplate(r.Context(), name)
+	if err != nil {
+		log.Printf("Error loading email template: %v", err)
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	version, err := email.RestoreTemplateVersion(r.Context(), h.DB, name, restored, &user.ID)
+	if errors.Is(err, email.ErrTemplateNotFound) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error restoring email template %s version %d: %v", name, restored, err)
+		http.Redirect(w, r, emailTemplateEditURL(name)+"?error="+url.QueryEscape("Failed to restore version: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "email_template_restored",
+		EntityType: audit.EntityEmailTemplate,
+		EntityID:   version.ID,
+		Changes:    audit.Diff(previous, version.Content()),
+		Details: map[string]interface{}{
+			"template":         name,
+			"version":          version.Version,
+			"restored_version": restored,
+		},
+	})
+
+	http.Redirect(w, r, emailTemplateEditURL(name)+"?success="+url.QueryEscape(fmt.Sprintf("Version %d restored as version %d", restored, version.Version)), http.StatusSeeOther)
+}
+
+// EmailTemplateResetSubmit saves the built-in default of a template as a new version
+func (h *FormsHandler) EmailTemplateResetSubmit(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.EmailTemplateManage) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	content, ok := email.DefaultTemplate(name)
+	if !ok {
+		http.Error(w, "Email template not found", http.StatusNotFound)
+		return
+	}
+
+	previous, err := h.currentEmailTemplate(r.Context(), name)
+	if err != nil {
+		log.Printf("Error loading email template: %v", err)
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	version, err := email.SaveTemplate(r.Context(), h.DB, name, content, &user.ID, "Restored built-in default")
+	if err != nil {
+		log.Printf("Error resetting email template %s: %v", name, err)
+		http.Error(w, "Failed to reset email template", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "email_template_reset",
+		EntityType: audit.EntityEmailTemplate,
+		EntityID:   version.ID,
+		Changes:    audit.Diff(previous, content),
+		Details: map[string]interface{}{
+			"template": name,
+			"version":  version.Version,
+		},
+	})
+
+	http.Redirect(w, r, emailTemplateEditURL(name)+"?success="+url.QueryEscape("Built-in default restored"), http.StatusSeeOther)
+}
+
+// renderEmailTemplateForm renders the template editor with the given content and version history
+func (h *FormsHandler) renderEmailTemplateForm(w http.ResponseWriter, r *http.Request, name string, content email.TemplateContent, extra map[string]interface{}) {
+	versions, err := email.GetTemplateVersions(r.Context(), h.DB, name)
+	if err != nil {
+		log.Printf("Error getting email template versions: %v", err)
+		http.Error(w, "Failed to load email template", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":           user,
+		"Nav":            views.GetNavigationLinks(user.Role),
+		"CurrentPage":    "forms",
+		"Name":           name,
+		"Content":        content,
+		"Versions":       versions,
+		"PreviewSubject": email.PreviewSubject(name),
+		"CSRFToken":      middleware.GetCSRFToken(r.Context()),
+	}
+	for key, value := range extra {
+		data[key] = value
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "email-template-form.html", data); err != nil {
+		log.Printf("Error executing email template form template: %v", err)
+		http.Error(w, "Failed to render form", http.StatusInternalServerError)
+		return
+	}
+}
+
+// currentEmailTemplate returns the content of the current version of a template,
+// or the built-in default when none is saved
+func (h *FormsHandler) currentEmailTemplate(ctx context.Context, name string) (email.TemplateContent, error) {
+	current, err := email.GetCurrentTemplate(ctx, h.DB, name)
+	if errors.Is(err, email.ErrTemplateNotFound) {
+		content, _ := email.DefaultTemplate(name)
+		return content, nil
+	}
+	if err != nil {
+		return email.TemplateContent{}, err
+	}
+	return current.Content(), nil
+}
+
+// emailTemplateContentFromForm reads the template content submitted by the editor
+func emailTemplateContentFromForm(r *http.Request) email.TemplateContent {
+	return email.TemplateContent{
+		Subject:  r.FormValue("subject"),
+		HTMLBody: strings.ReplaceAll(r.FormValue("html_body"), "\r\n", "\n"),
+		TextBody: strings.ReplaceAll(r.FormValue("text_body"), "\r\n", "\n"),
+	}
+}
+
+// emailTemplateEditURL is the editor page of a template
+func emailTemplateEditURL(name string) string {
+	return "/forms/email-templates/" + url.PathEscape(name) + "/edit"
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
@@ -9,8 +11,12 @@ import (
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -19,6 +25,7 @@ import (
 type FormsHandler struct {
 	DB        *sql.DB
 	Templates *template.Template
+	Notifier  *email.Notifier // Sends user invitations; without it the invitation link is shown to the admin
 }
 
 // NewFormsHandler creates a new FormsHandler
@@ -29,6 +36,11 @@ func NewFormsHandler(db *sql.DB, templates *template.Template) *FormsHandler {
 	}
 }
 
+// logAudit writes an audit log entry for a change made by the signed-in user
+func (h *FormsHandler) logAudit(r *http.Request, entry audit.Entry) {
+	audit.Log(r.Context(), h.DB, audit.UserActor(middleware.GetUserFromContext(r.Context())), entry)
+}
+
 // FormsPage displays the forms management page
 func (h *FormsHandler) FormsPage(w http.ResponseWriter, r *http.Request) {
 	// Get user from context
@@ -38,9 +50,7 @@ func (h *FormsHandler) FormsPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Only logistics users can access forms page
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only logistics users can access this page", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.FormsPermissions...) {
 		return
 	}
 
@@ -59,25 +69,11 @@ func (h *FormsHandler) FormsPage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// requireLogisticsRole checks if the user is a logistics user
-func (h *FormsHandler) requireLogisticsRole(w http.ResponseWriter, r *http.Request) bool {
-	user := middleware.GetUserFromContext(r.Context())
-	if user == nil {
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
-		return false
-	}
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only logistics users can access this page", http.StatusForbidden)
-		return false
-	}
-	return true
-}
-
 // ========== USER HANDLERS ==========
 
 // UsersList displays a list of all users
 func (h *FormsHandler) UsersList(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
 		return
 	}
 
@@ -95,6 +91,9 @@ func (h *FormsHandler) UsersList(w http.ResponseWriter, r *http.Request) {
 		"CurrentPage": "forms",
 		"Users":       users,
 		"Roles":       []models.UserRole{models.RoleLogistics, models.RoleClient, models.RoleWarehouse, models.RoleProjectManager},
+		"Success":     r.URL.Query().Get("success"),
+		"Error":       r.URL.Query().Get("error"),
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "users-list.html", data); err != nil {
@@ -106,7 +105,7 @@ func (h *FormsHandler) UsersList(w http.ResponseWriter, r *http.Request) {
 
 // UserAddPage displays the form to add a new user
 func (h *FormsHandler) UserAddPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
 		return
 	}
 
@@ -124,6 +123,7 @@ func (h *FormsHandler) UserAddPage(w http.ResponseWriter, r *http.Request) {
 		"CurrentPage": "forms",
 		"Companies":   companies,
 		"Roles":       []models.UserRole{models.RoleLogistics, models.RoleClient, models.RoleWarehouse, models.RoleProjectManager},
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "user-form.html", data); err != nil {
@@ -135,7 +135,7 @@ func (h *FormsHandler) UserAddPage(w http.ResponseWriter, r *http.Request) {
 
 // UserAddSubmit handles the submission of a new user
 func (h *FormsHandler) UserAddSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
 		return
 	}
 
@@ -144,23 +144,23 @@ func (h *FormsHandler) UserAddSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Hash password - required for new users
+	// Without a password the user is invited to choose one by email
 	password := r.FormValue("password")
-	if password == "" {
-		http.Error(w, "Password is required for new users", http.StatusBadRequest)
-		return
-	}
-
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Printf("Error hashing password: %v", err)
-		http.Error(w, "Failed to process password", http.StatusInternalServerError)
-		return
+	invite := password == ""
+	passwordHash := models.PendingPasswordHash
+	if !invite {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("Error hashing password: %v", err)
+			http.Error(w, "Failed to process password", http.StatusInternalServerError)
+			return
+		}
+		passwordHash = string(hashedPassword)
 	}
 
 	user := &models.User{
 		Email:        r.FormValue("email"),
-		PasswordHash: string(hashedPassword),
+		PasswordHash: passwordHash,
 		Role:         models.UserRole(r.FormValue("role")),
 	}
 
@@ -174,18 +174,78 @@ func (h *FormsHandler) UserAddSubmit(w http.ResponseWriter, r *http.Request) {
 		user.ClientCompanyID = &clientCompanyID
 	}
 
-	if err := models.CreateUser(h.DB, user); err != nil {
+	assignedCompanyIDs, ok := parseAssignedCompanyIDs(r, user.Role)
+	if !ok {
+		http.Error(w, "Invalid assigned company ID", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %v", err)
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if err := models.CreateUser(tx, user); err != nil {
 		log.Printf("Error creating user: %v", err)
 		http.Error(w, "Failed to create user: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("User created successfully"), http.StatusSeeOther)
+	// The invitation email is queued with the user, so it is only sent if the user is created
+	if invite && h.Notifier != nil {
+		if err := h.queueInvitation(r, tx, user); err != nil {
+			log.Printf("Error queueing invitation: %v", err)
+			http.Error(w, "Failed to queue the invitation", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing user: %v", err)
+		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		return
+	}
+
+	if err := models.SetAssignedCompanies(h.DB, user.ID, assignedCompanyIDs); err != nil {
+		log.Printf("Error assigning companies to user: %v", err)
+		http.Error(w, "Failed to assign client companies", http.StatusInternalServerError)
+		return
+	}
+	user.AssignedCompanyIDs = assignedCompanyIDs
+
+	h.logAudit(r, audit.Entry{
+		Action:     "user_created",
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+		Changes:    audit.Diff(nil, user),
+	})
+
+	if !invite {
+		http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("User created successfully"), http.StatusSeeOther)
+		return
+	}
+
+	if h.Notifier != nil {
+		h.logInvitation(r, user)
+		http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("User created. Invitation sent to "+user.Email), http.StatusSeeOther)
+		return
+	}
+
+	message, err := h.invitationLinkMessage(r, user)
+	if err != nil {
+		log.Printf("Error creating invitation link: %v", err)
+		http.Redirect(w, r, "/forms/users?error="+url.QueryEscape("User created, but the invitation could not be sent. Use Resend invitation to try again."), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("User created. "+message), http.StatusSeeOther)
 }
 
 // UserEditPage displays the form to edit an existing user
 func (h *FormsHandler) UserEditPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
 		return
 	}
 
@@ -211,6 +271,13 @@ func (h *FormsHandler) UserEditPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	assignedCompanyIDs, err := models.GetAssignedCompanyIDs(h.DB, user.ID)
+	if err != nil {
+		log.Printf("Error getting assigned companies: %v", err)
+		http.Error(w, "Failed to load assigned companies", http.StatusInternalServerError)
+		return
+	}
+
 	currentUser := middleware.GetUserFromContext(r.Context())
 	
 	// Get current company ID for pre-selection (0 if nil)
@@ -218,16 +285,24 @@ func (h *FormsHandler) UserEditPage(w http.ResponseWriter, r *http.Request) {
 	if user.ClientCompanyID != nil {
 		currentCompanyID = *user.ClientCompanyID
 	}
+
+	// Companies assigned to a project manager, for pre-selection
+	assignedCompanies := make(map[int64]bool, len(assignedCompanyIDs))
+	for _, companyID := range assignedCompanyIDs {
+		assignedCompanies[companyID] = true
+	}
 	
 	data := map[string]interface{}{
-		"User":             currentUser,
-		"Nav":              views.GetNavigationLinks(currentUser.Role),
-		"CurrentPage":      "forms",
-		"EditUser":         user,
-		"Companies":        companies,
-		"Roles":            []models.UserRole{models.RoleLogistics, models.RoleClient, models.RoleWarehouse, models.RoleProjectManager},
-		"IsEdit":           true,
-		"CurrentCompanyID": currentCompanyID,
+		"User":              currentUser,
+		"Nav":               views.GetNavigationLinks(currentUser.Role),
+		"CurrentPage":       "forms",
+		"EditUser":          user,
+		"Companies":         companies,
+		"Roles":             []models.UserRole{models.RoleLogistics, models.RoleClient, models.RoleWarehouse, models.RoleProjectManager},
+		"IsEdit":            true,
+		"CurrentCompanyID":  currentCompanyID,
+		"AssignedCompanies": assignedCompanies,
+		"CSRFToken":         middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "user-form.html", data); err != nil {
@@ -239,7 +314,7 @@ func (h *FormsHandler) UserEditPage(w http.ResponseWriter, r *http.Request) {
 
 // UserEditSubmit handles the submission of user updates
 func (h *FormsHandler) UserEditSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
 		return
 	}
 
@@ -263,12 +338,19 @@ func (h *FormsHandler) UserEditSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := models.LoadAssignedCompanies(h.DB, user); err != nil {
+		log.Printf("Error loading assigned companies: %v", err)
+	}
+	before := audit.Snapshot(user)
+
 	// Update fields
+	previousRole := user.Role
 	user.Email = r.FormValue("email")
 	user.Role = models.UserRole(r.FormValue("role"))
 
 	// Update password if provided
-	if password := r.FormValue("password"); password != "" {
+	password := r.FormValue("password")
+	if password != "" {
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 		if err != nil {
 			log.Printf("Error hashing password: %v", err)
@@ -291,20 +373,348 @@ func (h *FormsHandler) UserEditSubmit(w http.ResponseWriter, r *http.Request) {
 		user.ClientCompanyID = nil
 	}
 
+	assignedCompanyIDs, ok := parseAssignedCompanyIDs(r, user.Role)
+	if !ok {
+		http.Error(w, "Invalid assigned company ID", http.StatusBadRequest)
+		return
+	}
+
 	if err := models.UpdateUser(h.DB, user); err != nil {
 		log.Printf("Error updating user: %v", err)
 		http.Redirect(w, r, "/forms/users/"+idStr+"/edit?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
 		return
 	}
 
-	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("User updated successfully"), http.StatusSeeOther)
+	// Only project managers keep company assignments; changing the role clears them
+	if err := models.SetAssignedCompanies(h.DB, user.ID, assignedCompanyIDs); err != nil {
+		log.Printf("Error assigning companies to user: %v", err)
+		http.Redirect(w, r, "/forms/users/"+idStr+"/edit?error="+url.QueryEscape("Failed to assign client companies"), http.StatusSeeOther)
+		return
+	}
+	user.AssignedCompanyIDs = assignedCompanyIDs
+
+	// The password hash is not part of the diff, only the fact that it changed
+	entry := audit.Entry{
+		Action:     "user_updated",
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+		Changes:    audit.Diff(before, user),
+	}
+	if password != "" {
+		entry.Details = map[string]interface{}{"password_changed": true}
+	}
+	h.logAudit(r, entry)
+
+	// A new role or password takes effect everywhere: existing sessions must sign in again.
+	// An administrator editing their own account stays signed in on this device.
+	message := "User updated successfully"
+	if user.Role != previousRole || password != "" {
+		var err error
+		if current := middleware.GetSessionFromContext(r.Context()); current != nil && current.UserID == user.ID {
+			_, err = auth.DeleteOtherUserSessions(r.Context(), h.DB, user.ID, current.ID)
+		} else {
+			err = auth.DeleteUserSessions(r.Context(), h.DB, user.ID)
+		}
+		if err != nil {
+			log.Printf("Error deleting sessions: %v", err)
+		} else {
+			message += ". Existing sessions were signed out"
+		}
+	}
+
+	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// UserResendInvitation sends a new invitation link to a user who has not set a password yet.
+// The previous link stops working.
+func (h *FormsHandler) UserResendInvitation(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, id)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if status := user.InvitationStatus(); status != models.InvitationPending && status != models.InvitationExpired {
+		http.Redirect(w, r, "/forms/users?error="+url.QueryEscape(user.Email+" has no open invitation"), http.StatusSeeOther)
+		return
+	}
+
+	if h.Notifier == nil {
+		message, err := h.invitationLinkMessage(r, user)
+		if err != nil {
+			log.Printf("Error creating invitation link: %v", err)
+			http.Redirect(w, r, "/forms/users?error="+url.QueryEscape("Failed to send invitation"), http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, "/forms/users?success="+url.QueryEscape(message), http.StatusSeeOther)
+		return
+	}
+
+	if err := h.queueInvitation(r, h.DB, user); err != nil {
+		log.Printf("Error queueing invitation: %v", err)
+		http.Redirect(w, r, "/forms/users?error="+url.QueryEscape("Failed to send invitation"), http.StatusSeeOther)
+		return
+	}
+	h.logInvitation(r, user)
+	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("Invitation sent to "+user.Email), http.StatusSeeOther)
+}
+
+// queueInvitation queues an invitation email for the user in the email outbox. The outbox creates
+// the invitation link when it sends the email; the user's previous link stops working then.
+func (h *FormsHandler) queueInvitation(r *http.Request, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, user *models.User) error {
+	var invitedBy *int64
+	if currentUser := middleware.GetUserFromContext(r.Context()); currentUser != nil {
+		invitedBy = &currentUser.ID
+	}
+	return email.EnqueueAccountEmail(r.Context(), db, email.KindUserInvitation, user.ID, invitedBy)
+}
+
+// invitationLinkMessage creates an invitation link for the user when email is not configured.
+// Returns the message to show to the admin, with the link to be passed on by hand.
+func (h *FormsHandler) invitationLinkMessage(r *http.Request, user *models.User) (string, error) {
+	link, err := auth.CreateAccountLink(r.Context(), h.DB, user.ID, models.MagicLinkPurposeInvitation, auth.DefaultInvitationDuration)
+	if err != nil {
+		return "", err
+	}
+	setPasswordURL := fmt.Sprintf("%s/set-password?token=%s", getBaseURL(r), url.QueryEscape(link.Token))
+
+	h.logInvitation(r, user)
+	return "Email is not configured, send this link to " + user.Email + ": " + setPasswordURL, nil
+}
+
+// logInvitation records an invitation in the audit log
+func (h *FormsHandler) logInvitation(r *http.Request, user *models.User) {
+	h.logAudit(r, audit.Entry{
+		Action:     "user_invited",
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+		Details: map[string]interface{}{
+			"email": user.Email,
+			"role":  user.Role,
+		},
+	})
+}
+
+// UserUnlock clears the failed logins of a user and lifts the lockout
+func (h *FormsHandler) UserUnlock(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, id)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := auth.ResetFailedLogins(r.Context(), h.DB, user.ID); err != nil {
+		log.Printf("Error unlocking user: %v", err)
+		http.Redirect(w, r, "/forms/users?error="+url.QueryEscape("Failed to unlock user"), http.StatusSeeOther)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "user_unlocked",
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+		Details: map[string]interface{}{
+			"email":              user.Email,
+			"failed_login_count": user.FailedLoginCount,
+			"locked_until":       user.LockedUntil,
+		},
+	})
+
+	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("User "+user.Email+" unlocked"), http.StatusSeeOther)
+}
+
+// UserResetTwoFactor removes a user's TOTP secret and recovery codes, e.g. after a lost phone.
+// The user is signed out; if their role requires two-factor authentication they enroll again at the next login.
+func (h *FormsHandler) UserResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, id)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	if err := auth.DisableTwoFactor(r.Context(), h.DB, user.ID); err != nil {
+		log.Printf("Error resetting two-factor authentication: %v", err)
+		http.Redirect(w, r, "/forms/users?error="+url.QueryEscape("Failed to reset two-factor authentication"), http.StatusSeeOther)
+		return
+	}
+	if err := auth.DeleteUserSessions(r.Context(), h.DB, user.ID); err != nil {
+		log.Printf("Error deleting sessions: %v", err)
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "two_factor_reset",
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+		Details: map[string]interface{}{
+			"email":              user.Email,
+			"two_factor_enabled": user.TwoFactorEnabled,
+		},
+	})
+
+	http.Redirect(w, r, "/forms/users?success="+url.QueryEscape("Two-factor authentication reset for "+user.Email), http.StatusSeeOther)
+}
+
+// UserSessionsPage lists the devices a user is signed in on
+func (h *FormsHandler) UserSessionsPage(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	editUser, err := models.GetUserByID(h.DB, id)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	sessions, err := auth.GetUserSessions(r.Context(), h.DB, editUser.ID)
+	if err != nil {
+		log.Printf("Error getting sessions: %v", err)
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":        user,
+		"Nav":         views.GetNavigationLinks(user.Role),
+		"CurrentPage": "forms",
+		"EditUser":    editUser,
+		"Sessions":    sessions,
+		"Success":     r.URL.Query().Get("success"),
+		"Error":       r.URL.Query().Get("error"),
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "user-sessions.html", data); err != nil {
+		log.Printf("Error executing user sessions template: %v", err)
+		http.Error(w, "Failed to render user sessions", http.StatusInternalServerError)
+	}
+}
+
+// UserRevokeSessions signs a user out of one session, given as session_id, or of all their sessions
+func (h *FormsHandler) UserRevokeSessions(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.UserManage) {
+		return
+	}
+
+	idStr := mux.Vars(r)["id"]
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, id)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	redirectURL := "/forms/users/" + idStr + "/sessions"
+	details := map[string]interface{}{
+		"email": user.Email,
+	}
+	message := "All sessions of " + user.Email + " signed out"
+
+	if sessionIDStr := r.FormValue("session_id"); sessionIDStr != "" {
+		sessionID, err := strconv.ParseInt(sessionIDStr, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid session ID", http.StatusBadRequest)
+			return
+		}
+		revoked, err := auth.RevokeUserSession(r.Context(), h.DB, user.ID, sessionID)
+		if err != nil {
+			log.Printf("Error revoking session: %v", err)
+			http.Redirect(w, r, redirectURL+"?error="+url.QueryEscape("Failed to sign out the session"), http.StatusSeeOther)
+			return
+		}
+		if !revoked {
+			http.Redirect(w, r, redirectURL+"?error="+url.QueryEscape("Session not found"), http.StatusSeeOther)
+			return
+		}
+		details["session_id"] = sessionID
+		message = "Session signed out"
+	} else if err := auth.DeleteUserSessions(r.Context(), h.DB, user.ID); err != nil {
+		log.Printf("Error deleting sessions: %v", err)
+		http.Redirect(w, r, redirectURL+"?error="+url.QueryEscape("Failed to sign out sessions"), http.StatusSeeOther)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "sessions_revoked",
+		EntityType: audit.EntityUser,
+		EntityID:   user.ID,
+		Details:    details,
+	})
+
+	http.Redirect(w, r, redirectURL+"?success="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// parseAssignedCompanyIDs reads the client companies checked for a project manager.
+// Users with other roles get no assignments. Returns false if an ID is invalid.
+func parseAssignedCompanyIDs(r *http.Request, role models.UserRole) ([]int64, bool) {
+	if role != models.RoleProjectManager {
+		return nil, true
+	}
+	var ids []int64
+	for _, value := range r.Form["assigned_company_ids"] {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
 }
 
 // ========== CLIENT COMPANY HANDLERS ==========
 
 // ClientCompaniesList displays a list of all client companies
 func (h *FormsHandler) ClientCompaniesList(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.ClientCompanyManage) {
 		return
 	}
 
@@ -332,7 +742,7 @@ func (h *FormsHandler) ClientCompaniesList(w http.ResponseWriter, r *http.Reques
 
 // ClientCompanyAddPage displays the form to add a new client company
 func (h *FormsHandler) ClientCompanyAddPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.ClientCompanyManage) {
 		return
 	}
 
@@ -341,6 +751,7 @@ func (h *FormsHandler) ClientCompanyAddPage(w http.ResponseWriter, r *http.Reque
 		"User":        user,
 		"Nav":         views.GetNavigationLinks(user.Role),
 		"CurrentPage": "forms",
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "client-company-form.html", data); err != nil {
@@ -352,7 +763,7 @@ func (h *FormsHandler) ClientCompanyAddPage(w http.ResponseWriter, r *http.Reque
 
 // ClientCompanyAddSubmit handles the submission of a new client company
 func (h *FormsHandler) ClientCompanyAddSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.ClientCompanyManage) {
 		return
 	}
 
@@ -372,12 +783,19 @@ func (h *FormsHandler) ClientCompanyAddSubmit(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	h.logAudit(r, audit.Entry{
+		Action:     "client_company_created",
+		EntityType: audit.EntityClientCompany,
+		EntityID:   company.ID,
+		Changes:    audit.Diff(nil, company),
+	})
+
 	http.Redirect(w, r, "/forms/client-companies?success="+url.QueryEscape("Client company created successfully"), http.StatusSeeOther)
 }
 
 // ClientCompanyEditPage displays the form to edit an existing client company
 func (h *FormsHandler) ClientCompanyEditPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.ClientCompanyManage) {
 		return
 	}
 
@@ -403,6 +821,7 @@ func (h *FormsHandler) ClientCompanyEditPage(w http.ResponseWriter, r *http.Requ
 		"CurrentPage": "forms",
 		"Company":     company,
 		"IsEdit":      true,
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "client-company-form.html", data); err != nil {
@@ -414,7 +833,7 @@ func (h *FormsHandler) ClientCompanyEditPage(w http.ResponseWriter, r *http.Requ
 
 // ClientCompanyEditSubmit handles the submission of client company updates
 func (h *FormsHandler) ClientCompanyEditSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.ClientCompanyManage) {
 		return
 	}
 
@@ -438,6 +857,7 @@ func (h *FormsHandler) ClientCompanyEditSubmit(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	before := audit.Snapshot(company)
 	company.Name = r.FormValue("name")
 	company.ContactInfo = r.FormValue("contact_info")
 
@@ -447,6 +867,13 @@ func (h *FormsHandler) ClientCompanyEditSubmit(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	h.logAudit(r, audit.Entry{
+		Action:     "client_company_updated",
+		EntityType: audit.EntityClientCompany,
+		EntityID:   company.ID,
+		Changes:    audit.Diff(before, company),
+	})
+
 	http.Redirect(w, r, "/forms/client-companies?success="+url.QueryEscape("Client company updated successfully"), http.StatusSeeOther)
 }
 
@@ -454,7 +881,7 @@ func (h *FormsHandler) ClientCompanyEditSubmit(w http.ResponseWriter, r *http.Re
 
 // SoftwareEngineersList displays a list of all software engineers
 func (h *FormsHandler) SoftwareEngineersList(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.SoftwareEngineerManage) {
 		return
 	}
 
@@ -497,7 +924,7 @@ func (h *FormsHandler) SoftwareEngineersList(w http.ResponseWriter, r *http.Requ
 
 // SoftwareEngineerAddPage displays the form to add a new software engineer
 func (h *FormsHandler) SoftwareEngineerAddPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.SoftwareEngineerManage) {
 		return
 	}
 
@@ -506,6 +933,7 @@ func (h *FormsHandler) SoftwareEngineerAddPage(w http.ResponseWriter, r *http.Re
 		"User":        user,
 		"Nav":         views.GetNavigationLinks(user.Role),
 		"CurrentPage": "forms",
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "software-engineer-form.html", data); err != nil {
@@ -517,7 +945,7 @@ func (h *FormsHandler) SoftwareEngineerAddPage(w http.ResponseWriter, r *http.Re
 
 // SoftwareEngineerAddSubmit handles the submission of a new software engineer
 func (h *FormsHandler) SoftwareEngineerAddSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.SoftwareEngineerManage) {
 		return
 	}
 
@@ -552,12 +980,19 @@ func (h *FormsHandler) SoftwareEngineerAddSubmit(w http.ResponseWriter, r *http.
 		return
 	}
 
+	h.logAudit(r, audit.Entry{
+		Action:     "software_engineer_created",
+		EntityType: audit.EntitySoftwareEngineer,
+		EntityID:   engineer.ID,
+		Changes:    audit.Diff(nil, engineer),
+	})
+
 	http.Redirect(w, r, "/forms/software-engineers?success="+url.QueryEscape("Software engineer created successfully"), http.StatusSeeOther)
 }
 
 // SoftwareEngineerEditPage displays the form to edit an existing software engineer
 func (h *FormsHandler) SoftwareEngineerEditPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.SoftwareEngineerManage) {
 		return
 	}
 
@@ -583,6 +1018,7 @@ func (h *FormsHandler) SoftwareEngineerEditPage(w http.ResponseWriter, r *http.R
 		"CurrentPage": "forms",
 		"Engineer":    engineer,
 		"IsEdit":      true,
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "software-engineer-form.html", data); err != nil {
@@ -594,7 +1030,7 @@ func (h *FormsHandler) SoftwareEngineerEditPage(w http.ResponseWriter, r *http.R
 
 // SoftwareEngineerEditSubmit handles the submission of software engineer updates
 func (h *FormsHandler) SoftwareEngineerEditSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.SoftwareEngineerManage) {
 		return
 	}
 
@@ -618,6 +1054,7 @@ func (h *FormsHandler) SoftwareEngineerEditSubmit(w http.ResponseWriter, r *http
 		return
 	}
 
+	before := audit.Snapshot(engineer)
 	engineer.Name = r.FormValue("name")
 	engineer.Email = r.FormValue("email")
 	engineer.Address = r.FormValue("address") // Legacy field
@@ -651,6 +1088,13 @@ func (h *FormsHandler) SoftwareEngineerEditSubmit(w http.ResponseWriter, r *http
 		return
 	}
 
+	h.logAudit(r, audit.Entry{
+		Action:     "software_engineer_updated",
+		EntityType: audit.EntitySoftwareEngineer,
+		EntityID:   engineer.ID,
+		Changes:    audit.Diff(before, engineer),
+	})
+
 	http.Redirect(w, r, "/forms/software-engineers?success="+url.QueryEscape("Software engineer updated successfully"), http.StatusSeeOther)
 }
 
@@ -658,7 +1102,7 @@ func (h *FormsHandler) SoftwareEngineerEditSubmit(w http.ResponseWriter, r *http
 
 // CouriersList displays a list of all couriers
 func (h *FormsHandler) CouriersList(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.CourierManage) {
 		return
 	}
 
@@ -686,7 +1130,7 @@ func (h *FormsHandler) CouriersList(w http.ResponseWriter, r *http.Request) {
 
 // CourierAddPage displays the form to add a new courier
 func (h *FormsHandler) CourierAddPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.CourierManage) {
 		return
 	}
 
@@ -695,6 +1139,7 @@ func (h *FormsHandler) CourierAddPage(w http.ResponseWriter, r *http.Request) {
 		"User":        user,
 		"Nav":         views.GetNavigationLinks(user.Role),
 		"CurrentPage": "forms",
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "courier-form.html", data); err != nil {
@@ -706,7 +1151,7 @@ func (h *FormsHandler) CourierAddPage(w http.ResponseWriter, r *http.Request) {
 
 // CourierAddSubmit handles the submission of a new courier
 func (h *FormsHandler) CourierAddSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.CourierManage) {
 		return
 	}
 
@@ -716,8 +1161,11 @@ func (h *FormsHandler) CourierAddSubmit(w http.ResponseWriter, r *http.Request)
 	}
 
 	courier := &models.Courier{
-		Name:        r.FormValue("name"),
-		ContactInfo: r.FormValue("contact_info"),
+		Name:                  r.FormValue("name"),
+		ContactInfo:           r.FormValue("contact_info"),
+		TrackingURLTemplate:   r.FormValue("tracking_url_template"),
+		TrackingNumberPattern: r.FormValue("tracking_number_pattern"),
+		ServiceLevels:         models.ParseServiceLevels(r.FormValue("service_levels")),
 	}
 
 	if err := models.CreateCourier(h.DB, courier); err != nil {
@@ -726,12 +1174,19 @@ func (h *FormsHandler) CourierAddSubmit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.logAudit(r, audit.Entry{
+		Action:     "courier_created",
+		EntityType: audit.EntityCourier,
+		EntityID:   courier.ID,
+		Changes:    audit.Diff(nil, courier),
+	})
+
 	http.Redirect(w, r, "/forms/couriers?success="+url.QueryEscape("Courier created successfully"), http.StatusSeeOther)
 }
 
 // CourierEditPage displays the form to edit an existing courier
 func (h *FormsHandler) CourierEditPage(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.CourierManage) {
 		return
 	}
 
@@ -757,6 +1212,8 @@ func (h *FormsHandler) CourierEditPage(w http.ResponseWriter, r *http.Request) {
 		"CurrentPage": "forms",
 		"Courier":     courier,
 		"IsEdit":      true,
+		"Error":       r.URL.Query().Get("error"),
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if err := h.Templates.ExecuteTemplate(w, "courier-form.html", data); err != nil {
@@ -768,7 +1225,7 @@ func (h *FormsHandler) CourierEditPage(w http.ResponseWriter, r *http.Request) {
 
 // CourierEditSubmit handles the submission of courier updates
 func (h *FormsHandler) CourierEditSubmit(w http.ResponseWriter, r *http.Request) {
-	if !h.requireLogisticsRole(w, r) {
+	if !middleware.Authorize(w, r, permissions.CourierManage) {
 		return
 	}
 
@@ -792,8 +1249,12 @@ func (h *FormsHandler) CourierEditSubmit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	before := audit.Snapshot(courier)
 	courier.Name = r.FormValue("name")
 	courier.ContactInfo = r.FormValue("contact_info")
+	courier.TrackingURLTemplate = r.FormValue("tracking_url_template")
+	courier.TrackingNumberPattern = r.FormValue("tracking_number_pattern")
+	courier.ServiceLevels = models.ParseServiceLevels(r.FormValue("service_levels"))
 
 	if err := models.UpdateCourier(h.DB, courier); err != nil {
 		log.Printf("Error updating courier: %v", err)
@@ -801,6 +1262,13 @@ func (h *FormsHandler) CourierEditSubmit(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	h.logAudit(r, audit.Entry{
+		Action:     "courier_updated",
+		EntityType: audit.EntityCourier,
+		EntityID:   courier.ID,
+		Changes:    audit.Diff(before, courier),
+	})
+
 	http.Redirect(w, r, "/forms/couriers?success="+url.QueryEscape("Courier updated successfully"), http.StatusSeeOther)
 }
 
@@ -7,10 +7,13 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
 
@@ -37,6 +40,10 @@ func (h *InventoryHandler) InventoryList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !middleware.Authorize(w, r, permissions.InventoryView) {
+		return
+	}
+
 	// Parse query parameters
 	searchQuery := r.URL.Query().Get("search")
 	statusFilter := r.URL.Query().Get("status")
@@ -45,11 +52,12 @@ func (h *InventoryHandler) InventoryList(w http.ResponseWriter, r *http.Request)
 
 	// Build filter
 	filter := &models.LaptopFilter{
-		Search:          searchQuery,
-		UserRole:        user.Role, // Apply role-based filtering
-		ClientCompanyID: user.ClientCompanyID, // Apply client company filtering for client users
-		SortBy:          sortBy,
-		SortOrder:       sortOrder,
+		Search:           searchQuery,
+		UserRole:         user.Role,               // Apply role-based filtering
+		ClientCompanyID:  user.ClientCompanyID,    // Apply client company filtering for client users
+		ClientCompanyIDs: user.AssignedCompanyIDs, // Apply assigned company filtering for project managers
+		SortBy:           sortBy,
+		SortOrder:        sortOrder,
 	}
 
 	if statusFilter != "" {
@@ -94,6 +102,10 @@ func (h *InventoryHandler) LaptopDetail(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !middleware.Authorize(w, r, permissions.InventoryView) {
+		return
+	}
+
 	// Get laptop ID from URL
 	vars := mux.Vars(r)
 	idStr := vars["id"]
@@ -151,9 +163,7 @@ func (h *InventoryHandler) AddLaptopPage(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Only logistics role can add laptops
-	if user.Role != models.RoleLogistics && user.Role != models.RoleWarehouse {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.InventoryEdit) {
 		return
 	}
 
@@ -178,9 +188,11 @@ func (h *InventoryHandler) AddLaptopPage(w http.ResponseWriter, r *http.Request)
 		"User":        user,
 		"Nav":         views.GetNavigationLinks(user.Role),
 		"CurrentPage": "inventory",
-		"Statuses":    models.GetLaptopStatusesForNewLaptop(),
-		"Companies":   companies,
-		"Engineers":   engineers,
+		"Statuses":         models.GetLaptopStatusesForNewLaptop(),
+		"Companies":        companies,
+		"Engineers":        engineers,
+		"DeviceCategories": models.GetDeviceCategories(),
+		"CSRFToken":        middleware.GetCSRFToken(r.Context()),
 	}
 
 	// Execute template using pre-parsed global templates
@@ -200,9 +212,7 @@ func (h *InventoryHandler) AddLaptopSubmit(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Only logistics and warehouse roles can add laptops
-	if user.Role != models.RoleLogistics && user.Role != models.RoleWarehouse {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.InventoryEdit) {
 		return
 	}
 
@@ -224,6 +234,12 @@ func (h *InventoryHandler) AddLaptopSubmit(w http.ResponseWriter, r *http.Reques
 		Status:       models.LaptopStatus(r.FormValue("status")),
 	}
 
+	// Parse customs details
+	if err := parseLaptopCustoms(r, laptop); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Parse client company ID (required field)
 	clientCompanyIDStr := r.FormValue("client_company_id")
 	if clientCompanyIDStr != "" {
@@ -253,6 +269,13 @@ func (h *InventoryHandler) AddLaptopSubmit(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "laptop_created",
+		EntityType: audit.EntityLaptop,
+		EntityID:   laptop.ID,
+		Changes:    audit.Diff(nil, laptop),
+	})
+
 	// Redirect to inventory list
 	http.Redirect(w, r, "/inventory", http.StatusSeeOther)
 }
@@ -266,9 +289,7 @@ func (h *InventoryHandler) EditLaptopPage(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Only logistics and warehouse roles can edit laptops
-	if user.Role != models.RoleLogistics && user.Role != models.RoleWarehouse {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.InventoryEdit) {
 		return
 	}
 
@@ -332,6 +353,8 @@ func (h *InventoryHandler) EditLaptopPage(w http.ResponseWriter, r *http.Request
 		"CurrentEngineerID": currentEngineerID,
 		"Error":             errorMsg,
 		"Success":           successMsg,
+		"DeviceCategories":  models.GetDeviceCategories(),
+		"CSRFToken":         middleware.GetCSRFToken(r.Context()),
 	}
 
 	// Execute template using pre-parsed global templates
@@ -351,9 +374,7 @@ func (h *InventoryHandler) UpdateLaptopSubmit(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// Only logistics and warehouse roles can update laptops
-	if user.Role != models.RoleLogistics && user.Role != models.RoleWarehouse {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.InventoryEdit) {
 		return
 	}
 
@@ -379,6 +400,7 @@ func (h *InventoryHandler) UpdateLaptopSubmit(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Laptop not found", http.StatusNotFound)
 		return
 	}
+	before := audit.Snapshot(laptop)
 
 	// Update laptop fields
 	laptop.SerialNumber = r.FormValue("serial_number")
@@ -400,6 +422,12 @@ func (h *InventoryHandler) UpdateLaptopSubmit(w http.ResponseWriter, r *http.Req
 	laptop.SSDGB = r.FormValue("ssd_gb")
 	laptop.Status = models.LaptopStatus(r.FormValue("status"))
 
+	// Parse customs details
+	if err := parseLaptopCustoms(r, laptop); err != nil {
+		http.Redirect(w, r, "/inventory/"+idStr+"/edit?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
 	// Parse client company ID
 	clientCompanyIDStr := r.FormValue("client_company_id")
 	if clientCompanyIDStr != "" {
@@ -454,6 +482,13 @@ func (h *InventoryHandler) UpdateLaptopSubmit(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "laptop_updated",
+		EntityType: audit.EntityLaptop,
+		EntityID:   laptop.ID,
+		Changes:    audit.Diff(before, laptop),
+	})
+
 	// Redirect to laptop detail with success message
 	http.Redirect(w, r, "/inventory/"+idStr+"?success="+url.QueryEscape("Laptop updated successfully"), http.StatusSeeOther)
 }
@@ -467,9 +502,7 @@ func (h *InventoryHandler) DeleteLaptop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Only logistics role can delete laptops
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Unauthorized", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.InventoryDelete) {
 		return
 	}
 
@@ -482,6 +515,12 @@ func (h *InventoryHandler) DeleteLaptop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Keep the deleted laptop's fields for the audit log
+	deleted, err := models.GetLaptopByID(h.DB, id)
+	if err != nil {
+		log.Printf("Error getting laptop: %v", err)
+	}
+
 	// Delete laptop
 	if err := models.DeleteLaptop(h.DB, id); err != nil {
 		log.Printf("Error deleting laptop: %v", err)
@@ -489,7 +528,25 @@ func (h *InventoryHandler) DeleteLaptop(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "laptop_deleted",
+		EntityType: audit.EntityLaptop,
+		EntityID:   id,
+		Changes:    audit.Diff(deleted, nil),
+	})
+
 	// Redirect to inventory list
 	http.Redirect(w, r, "/inventory", http.StatusSeeOther)
 }
 
+// parseLaptopCustoms reads the customs details of a laptop from the submitted form
+func parseLaptopCustoms(r *http.Request, laptop *models.Laptop) error {
+	declaredValue, err := models.ParseDeclaredValue(r.FormValue("declared_value"))
+	if err != nil {
+		return err
+	}
+	laptop.DeclaredValue = declaredValue
+	laptop.DeviceCategory = models.DeviceCategory(r.FormValue("device_category"))
+	laptop.CountryOfOrigin = strings.TrimSpace(r.FormValue("country_of_origin"))
+	return nil
+}
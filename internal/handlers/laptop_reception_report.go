@@ -8,11 +8,13 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
 
@@ -25,9 +27,7 @@ func (h *ReceptionReportHandler) LaptopReceptionReportPage(w http.ResponseWriter
 		return
 	}
 
-	// Only warehouse users can submit reception reports
-	if user.Role != models.RoleWarehouse {
-		http.Error(w, "Forbidden: Only warehouse users can create reception reports", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportCreate) {
 		return
 	}
 
@@ -49,6 +49,7 @@ func (h *ReceptionReportHandler) LaptopReceptionReportPage(w http.ResponseWriter
 		LEFT JOIN shipment_laptops sl ON sl.laptop_id = l.id
 		LEFT JOIN shipments s ON s.id = sl.shipment_id AND s.status != 'delivered'
 		WHERE l.id = $1
+		ORDER BY s.created_at DESC NULLS LAST
 		LIMIT 1`,
 		laptopID,
 	).Scan(
@@ -80,7 +81,10 @@ func (h *ReceptionReportHandler) LaptopReceptionReportPage(w http.ResponseWriter
 		http.Error(w, "Failed to check existing reception report", http.StatusInternalServerError)
 		return
 	}
-	if existingReport != nil {
+	// An approved report from an earlier arrival doesn't cover a laptop that came back
+	// (e.g. returned by an engineer), so only reuse a pending report or one for this shipment
+	if existingReport != nil && (!existingReport.IsApproved() ||
+		(existingReport.ShipmentID != nil && shipmentID.Valid && *existingReport.ShipmentID == shipmentID.Int64)) {
 		// Redirect to view the existing report
 		http.Redirect(w, r, fmt.Sprintf("/reception-reports/%d", existingReport.ID), http.StatusSeeOther)
 		return
@@ -100,6 +104,7 @@ func (h *ReceptionReportHandler) LaptopReceptionReportPage(w http.ResponseWriter
 		"ClientCompanyName": clientCompanyName.String,
 		"ShipmentID":        shipmentID,
 		"TrackingNumber":    trackingNumber.String,
+		"CSRFToken":         middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -129,9 +134,7 @@ func (h *ReceptionReportHandler) LaptopReceptionReportSubmit(w http.ResponseWrit
 		return
 	}
 
-	// Only warehouse users can submit reception reports
-	if user.Role != models.RoleWarehouse {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportCreate) {
 		return
 	}
 
@@ -189,6 +192,7 @@ func (h *ReceptionReportHandler) LaptopReceptionReportSubmit(w http.ResponseWrit
 		LEFT JOIN shipment_laptops sl ON sl.laptop_id = l.id
 		LEFT JOIN shipments s ON s.id = sl.shipment_id
 		WHERE l.id = $1 AND (s.status IS NULL OR s.status != 'delivered')
+		ORDER BY s.created_at DESC NULLS LAST
 		LIMIT 1`,
 		laptopID,
 	).Scan(&shipmentID, &shipmentClientCompanyID, &laptopClientCompanyID, &trackingNumber)
@@ -240,8 +244,8 @@ func (h *ReceptionReportHandler) LaptopReceptionReportSubmit(w http.ResponseWrit
 		return
 	}
 
-	// Save to database
-	err = models.CreateReceptionReport(r.Context(), h.DB, report)
+	// Save to database together with the approval request email for logistics
+	err = h.saveReceptionReport(r.Context(), report)
 	if err != nil {
 		// Clean up uploaded photos on database error
 		os.Remove("." + photoSerialNumber)
@@ -251,8 +255,12 @@ func (h *ReceptionReportHandler) LaptopReceptionReportSubmit(w http.ResponseWrit
 		return
 	}
 
-	// Send email notification to international.logistics@bairesdev.com
-	go h.sendReceptionReportNotification(report, user)
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "reception_report_submitted",
+		EntityType: audit.EntityReceptionReport,
+		EntityID:   report.ID,
+		Details:    map[string]interface{}{"laptop_id": laptopID},
+	})
 
 	// Redirect to reception report detail page
 	redirectURL := fmt.Sprintf("/reception-reports/%d?success=Reception+report+created+successfully", report.ID)
@@ -294,23 +302,26 @@ func (h *ReceptionReportHandler) handleSinglePhotoUpload(r *http.Request, fieldN
 	return fmt.Sprintf("/uploads/reception/%s", filename), nil
 }
 
-// sendReceptionReportNotification sends email notification when a reception report is created
-func (h *ReceptionReportHandler) sendReceptionReportNotification(report *models.ReceptionReport, submitter *models.User) {
-	// Email sending is handled asynchronously, errors are logged but don't fail the request
+// saveReceptionReport creates the reception report and queues the approval request email
+// to logistics in the same transaction
+func (h *ReceptionReportHandler) saveReceptionReport(ctx context.Context, report *models.ReceptionReport) error {
+	tx, err := h.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := models.CreateReceptionReport(ctx, tx, report); err != nil {
+		return err
+	}
+
 	if h.Notifier == nil {
 		fmt.Printf("Warning: Email notifier not available, skipping reception report notification\n")
-		return
+	} else if err := email.Enqueue(ctx, tx, email.KindReceptionReportApprovalRequest, report.ID); err != nil {
+		return err
 	}
 
-	// Send notification asynchronously
-	go func() {
-		ctx := context.Background()
-		if err := h.Notifier.SendReceptionReportApprovalRequest(ctx, report.ID); err != nil {
-			fmt.Printf("Warning: failed to send reception report approval request: %v\n", err)
-		} else {
-			fmt.Printf("Reception report approval request sent successfully for report %d\n", report.ID)
-		}
-	}()
+	return tx.Commit()
 }
 
 // ApproveReceptionReport approves a reception report (logistics only)
@@ -327,20 +338,32 @@ func (h *ReceptionReportHandler) ApproveReceptionReport(w http.ResponseWriter, r
 		return
 	}
 
-	// Only logistics users can approve reception reports
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only logistics users can approve reception reports", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportApprove) {
+		return
+	}
+
+	report, err := models.GetReceptionReportByID(r.Context(), h.DB, reportID)
+	if err != nil {
+		redirectURL := fmt.Sprintf("/reception-reports/%d?error=%s", reportID, err.Error())
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 		return
 	}
 
 	// Approve the report (this also updates laptop status)
-	err := models.ApproveReceptionReport(r.Context(), h.DB, reportID, user.ID)
+	err = models.ApproveReceptionReport(r.Context(), h.DB, reportID, user.ID)
 	if err != nil {
 		redirectURL := fmt.Sprintf("/reception-reports/%d?error=%s", reportID, err.Error())
 		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 		return
 	}
 
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "reception_report_approved",
+		EntityType: audit.EntityReceptionReport,
+		EntityID:   reportID,
+		Details:    map[string]interface{}{"laptop_id": report.LaptopID},
+	})
+
 	// Redirect back to report detail with success message
 	redirectURL := fmt.Sprintf("/reception-reports/%d?success=Reception+report+approved+successfully", reportID)
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
@@ -355,9 +378,7 @@ func (h *ReceptionReportHandler) LaptopBasedReceptionReportsList(w http.Response
 		return
 	}
 
-	// Only warehouse and logistics users can view reception reports list
-	if user.Role != models.RoleWarehouse && user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only warehouse and logistics users can access this page", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportView) {
 		return
 	}
 
@@ -464,7 +485,14 @@ func (h *ReceptionReportHandler) LaptopBasedReceptionReportsList(w http.Response
 		return
 	}
 
-	// Generate tracking URLs for reports
+	// Generate tracking URLs for reports from the courier records
+	couriers, err := models.LoadCourierDirectory(h.DB)
+	if err != nil {
+		// Non-critical error, fall back to the built-in couriers
+		fmt.Printf("Warning: Failed to load couriers: %v\n", err)
+		couriers = models.DefaultCourierDirectory()
+	}
+
 	type ReportWithURL struct {
 		ReceptionReportRow
 		TrackingURL string
@@ -474,7 +502,7 @@ func (h *ReceptionReportHandler) LaptopBasedReceptionReportsList(w http.Response
 	for _, report := range receptionReports {
 		trackingURL := ""
 		if report.TrackingNumber.Valid && report.CourierName.Valid {
-			trackingURL = generateTrackingURL(report.CourierName.String, report.TrackingNumber.String)
+			trackingURL = couriers.TrackingURL(report.CourierName.String, report.TrackingNumber.String)
 		}
 		reportsWithURLs = append(reportsWithURLs, ReportWithURL{
 			ReceptionReportRow: report,
@@ -554,27 +582,3 @@ func buildLaptopReceptionReportsOrderByClause(sortBy, sortOrder string) string {
 	return fmt.Sprintf("ORDER BY %s %s", sqlColumn, order)
 }
 
-// generateTrackingURL generates a tracking URL based on the courier and tracking number
-func generateTrackingURL(courierName, trackingNumber string) string {
-	if courierName == "" || trackingNumber == "" {
-		return ""
-	}
-
-	// Normalize courier name to lowercase for comparison
-	courierLower := strings.ToLower(strings.TrimSpace(courierName))
-
-	// Check for courier name using substring matching to support service types
-	var baseURL string
-	if strings.Contains(courierLower, "ups") {
-		baseURL = "https://www.ups.com/track?tracknum="
-	} else if strings.Contains(courierLower, "dhl") {
-		baseURL = "http://www.dhl.com/en/express/tracking.html?AWB="
-	} else if strings.Contains(courierLower, "fedex") {
-		baseURL = "https://www.fedex.com/fedextrack/?tracknumbers="
-	} else {
-		return ""
-	}
-
-	return baseURL + trackingNumber
-}
-
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+)
+
+// oidcCookieName is the cookie that carries the state, nonce and PKCE verifier of an OIDC login
+const oidcCookieName = "oidc_login"
+
+// oidcProvider returns the configured OIDC provider with the ID, or nil
+func (h *AuthHandler) oidcProvider(id string) *auth.OIDCProvider {
+	for _, provider := range h.OIDCProviders {
+		if provider.ID == id {
+			return provider
+		}
+	}
+	return nil
+}
+
+// OIDCLogin initiates the login with an OpenID Connect provider
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider := h.oidcProvider(mux.Vars(r)["provider"])
+	if provider == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	loginRequest, err := auth.NewOIDCLoginRequest()
+	if err != nil {
+		http.Error(w, "Failed to generate state token", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), loginRequest)
+	if err != nil {
+		log.Printf("Error starting %s login: %v", provider.ID, err)
+		http.Redirect(w, r, "/login?error="+url.QueryEscape(provider.Name+" sign-in is unavailable"), http.StatusSeeOther)
+		return
+	}
+
+	// The callback is a cross-site navigation from the provider, so the cookie must be SameSite=Lax
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    strings.Join([]string{provider.ID, loginRequest.State, loginRequest.Nonce, loginRequest.Verifier}, "|"),
+		Path:     "/auth/oidc/",
+		MaxAge:   600, // 10 minutes
+		HttpOnly: true,
+		Secure:   isProduction(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+}
+
+// OIDCCallback handles the redirect back from an OpenID Connect provider.
+// Users are linked by provider and subject; on their first login they are matched by email
+// within the provider's client company, or provisioned with the provider's company and role.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider := h.oidcProvider(mux.Vars(r)["provider"])
+	if provider == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Verify state token to prevent CSRF
+	loginRequest := readOIDCCookie(r, provider.ID)
+	clearOIDCCookie(w)
+	if loginRequest == nil || r.URL.Query().Get("state") != loginRequest.State {
+		http.Redirect(w, r, "/login?error=Invalid+OAuth+state", http.StatusSeeOther)
+		return
+	}
+
+	if errorCode := r.URL.Query().Get("error"); errorCode != "" {
+		log.Printf("%s login failed: %s %s", provider.ID, errorCode, r.URL.Query().Get("error_description"))
+		http.Redirect(w, r, "/login?error="+url.QueryEscape(provider.Name+" sign-in was cancelled or failed"), http.StatusSeeOther)
+		return
+	}
+
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Redirect(w, r, "/login?error=No+authorization+code", http.StatusSeeOther)
+		return
+	}
+
+	attempt := auth.LoginAttempt{
+		Method:    auth.LoginMethodOIDC,
+		IPAddress: auth.ClientIP(r, h.TrustProxyHeaders),
+		UserAgent: r.UserAgent(),
+	}
+	if !h.checkLoginThrottle(w, r, attempt) {
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), code, loginRequest)
+	if err != nil {
+		log.Printf("Error completing %s login: %v", provider.ID, err)
+		h.recordLoginFailure(r, attempt, auth.LoginFailureProvider)
+		http.Redirect(w, r, "/login?error="+url.QueryEscape("Failed to sign in with "+provider.Name), http.StatusSeeOther)
+		return
+	}
+	attempt.Email = claims.Email
+
+	if claims.EmailUnverified() {
+		h.recordLoginFailure(r, attempt, auth.LoginFailureProvider)
+		http.Redirect(w, r, "/login?error=Email+not+verified", http.StatusSeeOther)
+		return
+	}
+	if !provider.AllowsEmail(claims.Email) {
+		h.recordLoginFailure(r, attempt, auth.LoginFailureDomain)
+		http.Redirect(w, r, "/login?error=Email+domain+not+allowed", http.StatusSeeOther)
+		return
+	}
+
+	user, created, err := auth.FindOrCreateOIDCUser(r.Context(), h.DB, provider, claims)
+	if errors.Is(err, auth.ErrOIDCAccountConflict) {
+		log.Printf("%s login for %q refused: the existing account cannot be linked to the provider", provider.ID, claims.Email)
+		h.recordLoginFailure(r, attempt, auth.LoginFailureAccountConflict)
+		message := fmt.Sprintf("An account with this email already exists and cannot sign in with %s. Please contact an administrator.", provider.Name)
+		http.Redirect(w, r, "/login?error="+url.QueryEscape(message), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("Error finding or creating %s user: %v", provider.ID, err)
+		http.Error(w, "Failed to create/find user", http.StatusInternalServerError)
+		return
+	}
+	attempt.UserID = &user.ID
+
+	if created {
+		h.logAudit(r, user.ID, "user_provisioned", map[string]interface{}{
+			"provider":          provider.ID,
+			"email":             user.Email,
+			"role":              user.Role,
+			"client_company_id": user.ClientCompanyID,
+		})
+	}
+
+	attempt.Success = true
+	if err := auth.RecordLoginAttempt(r.Context(), h.DB, attempt); err != nil {
+		log.Printf("Error recording login attempt: %v", err)
+	}
+
+	h.completeLogin(&sameSiteRedirectWriter{ResponseWriter: w}, r, user, getRedirectURLForRole(user.Role))
+}
+
+// readOIDCCookie returns the login request stored when the login with the provider started, or nil
+func readOIDCCookie(r *http.Request, providerID string) *auth.OIDCLoginRequest {
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		return nil
+	}
+	parts := strings.Split(cookie.Value, "|")
+	if len(parts) != 4 || parts[0] != providerID || parts[1] == "" {
+		return nil
+	}
+	return &auth.OIDCLoginRequest{State: parts[1], Nonce: parts[2], Verifier: parts[3]}
+}
+
+// clearOIDCCookie removes the login cookie so a callback cannot be replayed
+func clearOIDCCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    "",
+		Path:     "/auth/oidc/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isProduction(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sameSiteRedirectWriter turns a 303 redirect into a page that navigates to the same URL.
+// The session and two-factor cookies are SameSite=Strict, and browsers do not send them on a
+// redirect that is still part of a navigation that started on another site, like an identity
+// provider or the email a magic link was opened from.
+type sameSiteRedirectWriter struct {
+	http.ResponseWriter
+	redirected bool
+}
+
+func (w *sameSiteRedirectWriter) WriteHeader(status int) {
+	location := w.Header().Get("Location")
+	if status != http.StatusSeeOther || location == "" {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.redirected = true
+	w.Header().Del("Location")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	location = html.EscapeString(location)
+	fmt.Fprintf(w.ResponseWriter,
+		`<!DOCTYPE html><html><head><meta http-equiv="refresh" content="0;url=%s"></head><body><a href="%s">Continue</a></body></html>`,
+		location, location)
+}
+
+// Write drops the body http.Redirect writes after the header
+func (w *sameSiteRedirectWriter) Write(b []byte) (int, error) {
+	if w.redirected {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/documents"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// PackingSlip downloads the packing slip PDF of a shipment
+func (h *ShipmentsHandler) PackingSlip(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if !middleware.Authorize(w, r, permissions.ShipmentPrintDocuments) {
+		return
+	}
+
+	shipmentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return
+	}
+
+	slip, err := models.GetPackingSlip(r.Context(), h.DB, shipmentID, h.Warehouse)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Error loading packing slip: %v\n", err)
+		http.Error(w, "Failed to load packing slip", http.StatusInternalServerError)
+		return
+	}
+
+	h.writePackingSlips(w, fmt.Sprintf("packing-slip-%d.pdf", shipmentID), []*models.PackingSlip{slip})
+}
+
+// PackingSlipsReleasedToday downloads one PDF with the packing slips of every shipment
+// released from the warehouse today (logistics and warehouse only)
+func (h *ShipmentsHandler) PackingSlipsReleasedToday(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if !middleware.Authorize(w, r, permissions.ShipmentPrintDocuments) {
+		return
+	}
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	shipmentIDs, err := models.GetShipmentIDsReleasedBetween(r.Context(), h.DB, today, today.AddDate(0, 0, 1))
+	if err != nil {
+		fmt.Printf("Error loading released shipments: %v\n", err)
+		http.Error(w, "Failed to load released shipments", http.StatusInternalServerError)
+		return
+	}
+	if len(shipmentIDs) == 0 {
+		http.Redirect(w, r, "/shipments?error=No+shipments+were+released+from+the+warehouse+today", http.StatusSeeOther)
+		return
+	}
+
+	slips := make([]*models.PackingSlip, 0, len(shipmentIDs))
+	for _, shipmentID := range shipmentIDs {
+		slip, err := models.GetPackingSlip(r.Context(), h.DB, shipmentID, h.Warehouse)
+		if err != nil {
+			fmt.Printf("Error loading packing slip for shipment %d: %v\n", shipmentID, err)
+			http.Error(w, "Failed to load packing slips", http.StatusInternalServerError)
+			return
+		}
+		slips = append(slips, slip)
+	}
+
+	h.writePackingSlips(w, fmt.Sprintf("packing-slips-%s.pdf", today.Format("2006-01-02")), slips)
+}
+
+// writePackingSlips renders the slips and sends them as a PDF download.
+// The PDF is rendered to memory first so a failure can still be reported as an error page.
+func (h *ShipmentsHandler) writePackingSlips(w http.ResponseWriter, filename string, slips []*models.PackingSlip) {
+	var buf bytes.Buffer
+	if err := documents.WritePackingSlips(&buf, slips); err != nil {
+		fmt.Printf("Error generating packing slip PDF: %v\n", err)
+		http.Error(w, "Failed to generate packing slip", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// forgotPasswordMessage is shown whether or not the email belongs to an account,
+// so the form cannot be used to find out who has one
+const forgotPasswordMessage = "If an account with a password exists for that email, we sent a link to reset it. The link expires in 1 hour."
+
+// ForgotPasswordPage displays the form to request a password reset link
+func (h *AuthHandler) ForgotPasswordPage(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Error":     r.URL.Query().Get("error"),
+		"CSRFToken": middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "forgot-password.html", data); err != nil {
+		log.Printf("Error executing forgot password template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// ForgotPassword emails a password reset link to the account of the submitted email.
+// Google and OpenID Connect accounts have no password to reset and are skipped, as are
+// accounts that were sent a link within the last few minutes.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	emailAddress := auth.NormalizeEmail(r.FormValue("email"))
+	if emailAddress == "" {
+		http.Redirect(w, r, "/forgot-password?error="+url.QueryEscape("Email is required"), http.StatusSeeOther)
+		return
+	}
+
+	var userID int64
+	var googleID sql.NullString
+	var passwordHash string
+	err := h.DB.QueryRowContext(r.Context(),
+		"SELECT id, google_id, password_hash FROM users WHERE LOWER(email) = $1",
+		emailAddress,
+	).Scan(&userID, &googleID, &passwordHash)
+	externalLogin := (googleID.Valid && googleID.String != "") || passwordHash == models.ExternalLoginPasswordHash
+	if err == sql.ErrNoRows || (err == nil && externalLogin) {
+		http.Redirect(w, r, "/login?message="+url.QueryEscape(forgotPasswordMessage), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up user for password reset: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	recent, err := auth.PasswordResetRecentlySent(r.Context(), h.DB, userID)
+	if err == nil && !recent {
+		recent, err = email.IsQueued(r.Context(), h.DB, email.KindPasswordReset, userID)
+	}
+	if err != nil {
+		log.Printf("Error checking recent password resets: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !recent {
+		if err := h.sendPasswordReset(r, userID, emailAddress); err != nil {
+			log.Printf("Error sending password reset to user %d: %v", userID, err)
+		}
+	}
+
+	http.Redirect(w, r, "/login?message="+url.QueryEscape(forgotPasswordMessage), http.StatusSeeOther)
+}
+
+// sendPasswordReset queues the password reset email in the email outbox, which creates the link when it sends it
+func (h *AuthHandler) sendPasswordReset(r *http.Request, userID int64, emailAddress string) error {
+	if h.Notifier == nil {
+		// Development without SMTP: the link is only written to the server log
+		link, err := auth.CreateAccountLink(r.Context(), h.DB, userID, models.MagicLinkPurposePasswordReset, auth.DefaultPasswordResetDuration)
+		if err != nil {
+			return err
+		}
+		resetURL := fmt.Sprintf("%s/set-password?token=%s", getBaseURL(r), url.QueryEscape(link.Token))
+		h.logAudit(r, userID, "password_reset_requested", nil)
+		log.Printf("Email not configured; password reset link for %s: %s", emailAddress, resetURL)
+		return nil
+	}
+
+	if err := email.EnqueueAccountEmail(r.Context(), h.DB, email.KindPasswordReset, userID, nil); err != nil {
+		return err
+	}
+	h.logAudit(r, userID, "password_reset_requested", nil)
+	return nil
+}
+
+// SetPasswordPage displays the form to choose a password from an invitation or password reset link
+func (h *AuthHandler) SetPasswordPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	link, err := auth.ValidateAccountLink(r.Context(), h.DB, token)
+	if err != nil {
+		log.Printf("Error validating account link: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		redirectInvalidAccountLink(w, r)
+		return
+	}
+
+	data := map[string]interface{}{
+		"Token":        token,
+		"Email":        link.User.Email,
+		"IsInvitation": link.Purpose == models.MagicLinkPurposeInvitation,
+		"Error":        r.URL.Query().Get("error"),
+		"CSRFToken":    middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "set-password.html", data); err != nil {
+		log.Printf("Error executing set password template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// SetPassword sets the password chosen with an invitation or password reset link.
+// The link is used up and the user is signed out everywhere, then signs in with the new password.
+func (h *AuthHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	password := r.FormValue("password")
+	retry := func(message string) {
+		http.Redirect(w, r, "/set-password?token="+url.QueryEscape(token)+"&error="+url.QueryEscape(message), http.StatusSeeOther)
+	}
+
+	if password != r.FormValue("confirm_password") {
+		retry("Passwords do not match")
+		return
+	}
+	if err := auth.ValidatePassword(password); err != nil {
+		retry(err.Error())
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("Error hashing password: %v", err)
+		http.Error(w, "Failed to process password", http.StatusInternalServerError)
+		return
+	}
+
+	link, err := auth.SetPasswordWithLink(r.Context(), h.DB, token, passwordHash)
+	if err != nil {
+		log.Printf("Error setting password: %v", err)
+		http.Error(w, "Failed to set password", http.StatusInternalServerError)
+		return
+	}
+	if link == nil {
+		redirectInvalidAccountLink(w, r)
+		return
+	}
+
+	action, message := "password_reset", "Your password was changed. Please sign in."
+	if link.Purpose == models.MagicLinkPurposeInvitation {
+		action, message = "invitation_accepted", "Your account is ready. Please sign in."
+	}
+	h.logAudit(r, link.UserID, action, nil)
+
+	http.Redirect(w, r, "/login?message="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// redirectInvalidAccountLink sends the user to request a new link
+func redirectInvalidAccountLink(w http.ResponseWriter, r *http.Request) {
+	message := "This link is invalid, has expired or was already used. Request a new one below, or ask an administrator to resend your invitation."
+	http.Redirect(w, r, "/forgot-password?error="+url.QueryEscape(message), http.StatusSeeOther)
+}
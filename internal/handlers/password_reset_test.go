@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func TestForgotPassword_QueuesResetEmail(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	var userID int64
+	err := db.QueryRow(
+		`INSERT INTO users (email, password_hash, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4) RETURNING id`,
+		"reset@example.com", "hash", models.RoleLogistics, time.Now(),
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+
+	handler := NewAuthHandler(db, nil)
+	handler.Notifier = email.NewNotifier(nil, db)
+
+	// The second request finds the first one still queued and does not queue another email
+	for i := 0; i < 2; i++ {
+		form := url.Values{"email": {"Reset@Example.com"}}
+		req := httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+
+		handler.ForgotPassword(w, req)
+
+		if w.Code != http.StatusSeeOther {
+			t.Fatalf("Expected status 303, got %d", w.Code)
+		}
+	}
+
+	var queued int
+	err = db.QueryRow(
+		`SELECT COUNT(*) FROM email_outbox WHERE kind = $1 AND subject_id = $2 AND status = $3`,
+		email.KindPasswordReset, userID, email.OutboxStatusPending,
+	).Scan(&queued)
+	if err != nil {
+		t.Fatalf("Failed to count queued emails: %v", err)
+	}
+	if queued != 1 {
+		t.Errorf("Expected 1 queued password reset, got %d", queued)
+	}
+
+	// The link is only created when the outbox sends the email
+	var links int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM magic_links WHERE user_id = $1`, userID).Scan(&links); err != nil {
+		t.Fatalf("Failed to count links: %v", err)
+	}
+	if links != 0 {
+		t.Errorf("Expected no password reset link before sending, got %d", links)
+	}
+}
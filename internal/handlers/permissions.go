@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// ========== PERMISSION HANDLERS ==========
+// The routes are registered with middleware.RequirePermission(permissions.PermissionManage).
+
+// PermissionGroup is a group of rows of the permissions matrix
+type PermissionGroup struct {
+	Name string
+	Rows []PermissionRow
+}
+
+// PermissionRow is a permission with whether each role is granted it
+type PermissionRow struct {
+	Definition permissions.Definition
+	Cells      []PermissionCell
+}
+
+// PermissionCell is a checkbox of the permissions matrix
+type PermissionCell struct {
+	Role      models.UserRole
+	Granted   bool
+	IsDefault bool
+	Locked    bool
+}
+
+// PermissionsPage displays the role × permission matrix
+func (h *FormsHandler) PermissionsPage(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	roles := permissions.Roles()
+	data := map[string]interface{}{
+		"User":        user,
+		"Nav":         views.GetNavigationLinks(user.Role),
+		"CurrentPage": "forms",
+		"Roles":       roles,
+		"ColumnCount": len(roles) + 1,
+		"Groups":      buildPermissionGroups(permissions.Default().Grants()),
+		"Success":     r.URL.Query().Get("success"),
+		"Error":       r.URL.Query().Get("error"),
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "permissions-form.html", data); err != nil {
+		log.Printf("Error executing permissions template: %v", err)
+		http.Error(w, "Failed to render permissions page", http.StatusInternalServerError)
+		return
+	}
+}
+
+// PermissionsSubmit stores the grants of the permissions matrix.
+// Every checked box is sent as a "grants" value of the form "role|permission".
+func (h *FormsHandler) PermissionsSubmit(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	grants := map[models.UserRole]map[permissions.Permission]bool{}
+	for _, role := range permissions.Roles() {
+		grants[role] = map[permissions.Permission]bool{}
+	}
+	for _, value := range r.Form["grants"] {
+		role, perm, found := strings.Cut(value, "|")
+		if !found || !models.IsValidRole(models.UserRole(role)) || !permissions.IsValid(permissions.Permission(perm)) {
+			http.Redirect(w, r, "/forms/permissions?error="+url.QueryEscape("Invalid permission: "+value), http.StatusSeeOther)
+			return
+		}
+		grants[models.UserRole(role)][permissions.Permission(perm)] = true
+	}
+
+	previous := permissions.Default().Grants()
+	if err := permissions.Default().Save(r.Context(), h.DB, grants, user.ID); err != nil {
+		log.Printf("Error saving role permissions: %v", err)
+		http.Redirect(w, r, "/forms/permissions?error="+url.QueryEscape("Failed to save permissions"), http.StatusSeeOther)
+		return
+	}
+
+	granted, revoked := permissionChanges(previous, permissions.Default().Grants())
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "permissions_updated",
+		EntityType: audit.EntityRolePermissions,
+		Details: map[string]interface{}{
+			"granted": granted,
+			"revoked": revoked,
+		},
+	})
+
+	http.Redirect(w, r, "/forms/permissions?success="+url.QueryEscape("Permissions updated successfully"), http.StatusSeeOther)
+}
+
+// buildPermissionGroups arranges the grants as the rows of the permissions matrix
+func buildPermissionGroups(grants map[models.UserRole]map[permissions.Permission]bool) []PermissionGroup {
+	defaults := permissions.DefaultGrants()
+	var groups []PermissionGroup
+	for _, d := range permissions.Definitions {
+		if len(groups) == 0 || groups[len(groups)-1].Name != d.Group {
+			groups = append(groups, PermissionGroup{Name: d.Group})
+		}
+		row := PermissionRow{Definition: d}
+		for _, role := range permissions.Roles() {
+			granted := grants[role][d.Permission]
+			row.Cells = append(row.Cells, PermissionCell{
+				Role:      role,
+				Granted:   granted,
+				IsDefault: granted == defaults[role][d.Permission],
+				Locked:    permissions.IsLocked(role, d.Permission),
+			})
+		}
+		group := &groups[len(groups)-1]
+		group.Rows = append(group.Rows, row)
+	}
+	return groups
+}
+
+// permissionChanges lists the "role|permission" pairs granted and revoked between two sets of grants
+func permissionChanges(before, after map[models.UserRole]map[permissions.Permission]bool) (granted, revoked []string) {
+	granted, revoked = []string{}, []string{}
+	for _, role := range permissions.Roles() {
+		for _, d := range permissions.Definitions {
+			was, is := before[role][d.Permission], after[role][d.Permission]
+			key := string(role) + "|" + string(d.Permission)
+			if is && !was {
+				granted = append(granted, key)
+			} else if was && !is {
+				revoked = append(revoked, key)
+			}
+		}
+	}
+	return granted, revoked
+}
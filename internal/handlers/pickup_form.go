@@ -11,10 +11,12 @@ import (
 	"strings"
 	"time"
 
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
 	"github.com/yourusername/laptop-tracking-system/internal/auth"
 	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/validator"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
@@ -44,8 +46,8 @@ func (h *PickupFormHandler) PickupFormsLandingPage(w http.ResponseWriter, r *htt
 		return
 	}
 
-	// Determine which form options to show based on user role
-	showWarehouseToEngineer := user.Role == models.RoleLogistics
+	// Determine which form options to show based on user permissions
+	showWarehouseToEngineer := permissions.Can(user, permissions.ShipmentCreate)
 
 	data := map[string]interface{}{
 		"User":                    user,
@@ -97,9 +99,9 @@ func (h *PickupFormHandler) PickupFormPage(w http.ResponseWriter, r *http.Reques
 	errorMsg := r.URL.Query().Get("error")
 	successMsg := r.URL.Query().Get("success")
 
-	// Get list of client companies (for logistics users)
+	// Get list of client companies (for users who create shipments for any company)
 	companies := []models.ClientCompany{}
-	if user.Role == models.RoleLogistics {
+	if permissions.Can(user, permissions.ShipmentCreate) {
 		rows, err := h.DB.QueryContext(r.Context(),
 			`SELECT id, name, contact_info, created_at FROM client_companies ORDER BY name`,
 		)
@@ -128,6 +130,7 @@ func (h *PickupFormHandler) PickupFormPage(w http.ResponseWriter, r *http.Reques
 		"CompanyID":   companyID,
 		"Companies":   companies,
 		"TimeSlots":   []string{"morning", "afternoon", "evening"},
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -156,9 +159,9 @@ func (h *PickupFormHandler) SingleShipmentFormPage(w http.ResponseWriter, r *htt
 	errorMsg := r.URL.Query().Get("error")
 	successMsg := r.URL.Query().Get("success")
 
-	// Get list of client companies (for logistics users)
+	// Get list of client companies (for users who create shipments for any company)
 	companies := []models.ClientCompany{}
-	if user.Role == models.RoleLogistics {
+	if permissions.Can(user, permissions.ShipmentCreate) {
 		rows, err := h.DB.QueryContext(r.Context(),
 			`SELECT id, name, contact_info, created_at FROM client_companies ORDER BY name`,
 		)
@@ -187,6 +190,7 @@ func (h *PickupFormHandler) SingleShipmentFormPage(w http.ResponseWriter, r *htt
 		"Companies":    companies,
 		"TimeSlots":    []string{"morning", "afternoon", "evening"},
 		"ShipmentType": models.ShipmentTypeSingleFullJourney,
+		"CSRFToken":    middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -215,9 +219,9 @@ func (h *PickupFormHandler) BulkShipmentFormPage(w http.ResponseWriter, r *http.
 	errorMsg := r.URL.Query().Get("error")
 	successMsg := r.URL.Query().Get("success")
 
-	// Get list of client companies (for logistics users)
+	// Get list of client companies (for users who create shipments for any company)
 	companies := []models.ClientCompany{}
-	if user.Role == models.RoleLogistics {
+	if permissions.Can(user, permissions.ShipmentCreate) {
 		rows, err := h.DB.QueryContext(r.Context(),
 			`SELECT id, name, contact_info, created_at FROM client_companies ORDER BY name`,
 		)
@@ -246,6 +250,7 @@ func (h *PickupFormHandler) BulkShipmentFormPage(w http.ResponseWriter, r *http.
 		"Companies":    companies,
 		"TimeSlots":    []string{"morning", "afternoon", "evening"},
 		"ShipmentType": models.ShipmentTypeBulkToWarehouse,
+		"CSRFToken":    middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -297,6 +302,18 @@ func (h *PickupFormHandler) WarehouseToEngineerFormPage(w http.ResponseWriter, r
 			  WHERE sl.laptop_id = l.id
 				AND s.status NOT IN ('delivered', 'at_warehouse')
 		  )
+		  -- Returned laptops need a reception report for the return shipment
+		  AND NOT EXISTS (
+			  SELECT 1 FROM shipment_laptops sl
+			  JOIN shipments s ON s.id = sl.shipment_id
+			  WHERE sl.laptop_id = l.id
+				AND s.shipment_type = 'engineer_to_warehouse'
+				AND s.status = 'at_warehouse'
+				AND NOT EXISTS (
+					SELECT 1 FROM reception_reports rr
+					WHERE rr.laptop_id = l.id AND rr.shipment_id = s.id
+				)
+		  )
 		ORDER BY l.created_at DESC
 	`)
 	if err != nil {
@@ -341,6 +358,119 @@ func (h *PickupFormHandler) WarehouseToEngineerFormPage(w http.ResponseWriter, r
 		engineers = []models.SoftwareEngineer{} // Use empty slice on error
 	}
 
+	data := map[string]interface{}{
+		"Error":         errorMsg,
+		"Success":       successMsg,
+		"User":          user,
+		"Nav":           views.GetNavigationLinks(user.Role),
+		"CurrentPage":   "pickup-forms",
+		"Laptops":       laptops,
+		"LaptopCount":   len(laptops),
+		"Engineers":     engineers,
+		"ShipmentType":  models.ShipmentTypeWarehouseToEngineer,
+		"ExportReasons": models.GetExportReasons(),
+		"CSRFToken":     middleware.GetCSRFToken(r.Context()),
+	}
+
+	if h.Templates != nil {
+		err := h.Templates.ExecuteTemplate(w, "warehouse-to-engineer-form.html", data)
+		if err != nil {
+			http.Error(w, "Failed to render template", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// For testing without templates
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "Warehouse to Engineer Form Page")
+	}
+}
+
+// EngineerToWarehouseFormPage displays the engineer to warehouse (return) shipment form
+func (h *PickupFormHandler) EngineerToWarehouseFormPage(w http.ResponseWriter, r *http.Request) {
+	// Get user from context
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if !middleware.Authorize(w, r, permissions.ShipmentCreate) {
+		return
+	}
+
+	// Get error and success messages from query parameters
+	errorMsg := r.URL.Query().Get("error")
+	successMsg := r.URL.Query().Get("success")
+
+	// Get laptops currently delivered to an engineer
+	laptops := []models.Laptop{}
+	rows, err := h.DB.QueryContext(r.Context(), `
+		SELECT l.id, l.serial_number, l.sku, l.brand, l.model, l.cpu, l.ram_gb, l.ssd_gb,
+			   l.status, l.client_company_id, l.software_engineer_id,
+			   l.created_at, l.updated_at,
+			   cc.name as client_company_name
+		FROM laptops l
+		LEFT JOIN client_companies cc ON cc.id = l.client_company_id
+		WHERE l.status = 'delivered'
+		  AND l.software_engineer_id IS NOT NULL
+		  -- Must not already be in an active shipment (e.g. a return that is already underway)
+		  AND NOT EXISTS (
+			  SELECT 1 FROM shipment_laptops sl
+			  JOIN shipments s ON s.id = sl.shipment_id
+			  WHERE sl.laptop_id = l.id
+				AND s.status NOT IN ('delivered', 'at_warehouse', 'returned_to_sender', 'cancelled')
+		  )
+		ORDER BY l.serial_number
+	`)
+	if err != nil {
+		http.Error(w, "Failed to load laptops", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	assignedEngineers := make(map[int64]bool)
+	for rows.Next() {
+		var laptop models.Laptop
+		var clientCompanyName sql.NullString
+		var sku sql.NullString
+		err := rows.Scan(
+			&laptop.ID, &laptop.SerialNumber, &sku, &laptop.Brand,
+			&laptop.Model, &laptop.CPU, &laptop.RAMGB, &laptop.SSDGB, &laptop.Status, &laptop.ClientCompanyID,
+			&laptop.SoftwareEngineerID, &laptop.CreatedAt, &laptop.UpdatedAt,
+			&clientCompanyName,
+		)
+		if err != nil {
+			fmt.Printf("Warning: Failed to scan laptop row: %v\n", err)
+			continue
+		}
+		if sku.Valid {
+			laptop.SKU = sku.String
+		}
+		if clientCompanyName.Valid {
+			laptop.ClientCompanyName = clientCompanyName.String
+		}
+		assignedEngineers[*laptop.SoftwareEngineerID] = true
+		laptops = append(laptops, laptop)
+	}
+
+	// Check for iteration errors
+	if err := rows.Err(); err != nil {
+		fmt.Printf("Warning: Error iterating laptop rows: %v\n", err)
+	}
+
+	// Only list engineers who have a laptop to return
+	allEngineers, err := models.GetAllSoftwareEngineers(h.DB, nil)
+	if err != nil {
+		fmt.Printf("Warning: Failed to load software engineers: %v\n", err)
+		allEngineers = []models.SoftwareEngineer{} // Use empty slice on error
+	}
+	engineers := []models.SoftwareEngineer{}
+	for _, engineer := range allEngineers {
+		if assignedEngineers[engineer.ID] {
+			engineers = append(engineers, engineer)
+		}
+	}
+
 	data := map[string]interface{}{
 		"Error":        errorMsg,
 		"Success":      successMsg,
@@ -350,11 +480,12 @@ func (h *PickupFormHandler) WarehouseToEngineerFormPage(w http.ResponseWriter, r
 		"Laptops":      laptops,
 		"LaptopCount":  len(laptops),
 		"Engineers":    engineers,
-		"ShipmentType": models.ShipmentTypeWarehouseToEngineer,
+		"ShipmentType": models.ShipmentTypeEngineerToWarehouse,
+		"CSRFToken":    middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
-		err := h.Templates.ExecuteTemplate(w, "warehouse-to-engineer-form.html", data)
+		err := h.Templates.ExecuteTemplate(w, "engineer-to-warehouse-form.html", data)
 		if err != nil {
 			http.Error(w, "Failed to render template", http.StatusInternalServerError)
 			return
@@ -362,7 +493,7 @@ func (h *PickupFormHandler) WarehouseToEngineerFormPage(w http.ResponseWriter, r
 	} else {
 		// For testing without templates
 		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "Warehouse to Engineer Form Page")
+		fmt.Fprintf(w, "Engineer to Warehouse Form Page")
 	}
 }
 
@@ -414,8 +545,12 @@ func (h *PickupFormHandler) PickupFormSubmit(w http.ResponseWriter, r *http.Requ
 	var companyID int64
 	companyIDStr := r.FormValue("client_company_id")
 
-	// For warehouse-to-engineer shipments, extract company ID from laptop if not provided
-	if shipmentType == models.ShipmentTypeWarehouseToEngineer && companyIDStr == "" {
+	// For warehouse-to-engineer and engineer-to-warehouse shipments, extract company ID from laptop if not provided
+	if (shipmentType == models.ShipmentTypeWarehouseToEngineer || shipmentType == models.ShipmentTypeEngineerToWarehouse) && companyIDStr == "" {
+		formURL := "/shipments/create/warehouse-to-engineer"
+		if shipmentType == models.ShipmentTypeEngineerToWarehouse {
+			formURL = "/shipments/create/engineer-to-warehouse"
+		}
 		laptopIDStr := r.FormValue("laptop_id")
 		if laptopIDStr != "" {
 			laptopID, err := strconv.ParseInt(laptopIDStr, 10, 64)
@@ -428,7 +563,7 @@ func (h *PickupFormHandler) PickupFormSubmit(w http.ResponseWriter, r *http.Requ
 				).Scan(&nullableCompanyID)
 
 				if err != nil {
-					http.Redirect(w, r, "/shipments/create/warehouse-to-engineer?error=Laptop+not+found", http.StatusSeeOther)
+					http.Redirect(w, r, formURL+"?error=Laptop+not+found", http.StatusSeeOther)
 					return
 				}
 
@@ -448,7 +583,7 @@ func (h *PickupFormHandler) PickupFormSubmit(w http.ResponseWriter, r *http.Requ
 					).Scan(&companyID)
 
 					if err != nil {
-						http.Redirect(w, r, "/shipments/create/warehouse-to-engineer?error=Unable+to+find+laptop+company", http.StatusSeeOther)
+						http.Redirect(w, r, formURL+"?error=Unable+to+find+laptop+company", http.StatusSeeOther)
 						return
 					}
 				}
@@ -528,6 +663,16 @@ func (h *PickupFormHandler) PickupFormSubmit(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
+	case models.ShipmentTypeEngineerToWarehouse:
+		// Return shipments are scheduled through the return kit, no pickup date needed
+		shipmentID, err = h.handleEngineerToWarehouseForm(r, user, companyID, includeAccessories)
+		if err != nil {
+			redirectURL := fmt.Sprintf("/shipments/create/engineer-to-warehouse?error=%s",
+				err.Error())
+			http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+			return
+		}
+
 	case "legacy":
 		if !hasPickupDate {
 			http.Redirect(w, r, "/pickup-form?error=Pickup+date+is+required", http.StatusSeeOther)
@@ -547,30 +692,26 @@ func (h *PickupFormHandler) PickupFormSubmit(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Send pickup confirmation email (Step 4 in process flow)
-	// Skip notifications for warehouse-to-engineer shipments (they don't have pickup from client)
-	if h.Notifier != nil && shipmentType != models.ShipmentTypeWarehouseToEngineer {
-		if err := h.Notifier.SendPickupConfirmation(r.Context(), shipmentID); err != nil {
-			// Log error but don't fail the request
-			fmt.Printf("Warning: Failed to send pickup confirmation email: %v\n", err)
-		}
-		
-		// Also send notification to logistics team
-		go func() {
-			ctx := context.Background()
-			if err := h.Notifier.SendPickupFormSubmittedNotification(ctx, shipmentID); err != nil {
-				fmt.Printf("Warning: Failed to send pickup form submitted notification to logistics: %v\n", err)
-			} else {
-				fmt.Printf("Pickup form submitted notification sent successfully to logistics for shipment %d\n", shipmentID)
-			}
-		}()
-	}
-
 	// Redirect to success page or shipment detail
 	redirectURL := fmt.Sprintf("/shipments/%d?success=Pickup+form+submitted+successfully", shipmentID)
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
 }
 
+// enqueuePickupNotifications queues the pickup confirmation for the client (Step 4 in process flow)
+// and the form submitted notice for logistics in the transaction that saves the pickup form.
+// Warehouse-to-engineer and engineer-to-warehouse shipments have no pickup from a client and don't call it.
+func (h *PickupFormHandler) enqueuePickupNotifications(ctx context.Context, tx *sql.Tx, shipmentID int64) error {
+	if h.Notifier == nil {
+		return nil
+	}
+	for _, kind := range []string{email.KindPickupConfirmation, email.KindPickupFormSubmitted} {
+		if err := email.Enqueue(ctx, tx, kind, shipmentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // handleSingleFullJourneyForm handles single full journey shipment form submission
 func (h *PickupFormHandler) handleSingleFullJourneyForm(r *http.Request, user *models.User, companyID int64, pickupDate time.Time, includeAccessories bool) (int64, error) {
 	// Build validation input
@@ -635,6 +776,17 @@ func (h *PickupFormHandler) handleSingleFullJourneyForm(r *http.Request, user *m
 		return 0, fmt.Errorf("failed to create shipment: %w", err)
 	}
 
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		return 0, err
+	}
+
 	// Auto-create laptop record
 	laptop := models.Laptop{
 		SerialNumber:    formInput.LaptopSerialNumber,
@@ -715,22 +867,20 @@ func (h *PickupFormHandler) handleSingleFullJourneyForm(r *http.Request, user *m
 	}
 
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":        "pickup_form_submitted",
-		"shipment_id":   shipmentID,
-		"shipment_type": models.ShipmentTypeSingleFullJourney,
-		"company_id":    companyID,
-		"laptop_id":     laptopID,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "pickup_form_submitted",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id":   shipmentID,
+			"shipment_type": models.ShipmentTypeSingleFullJourney,
+			"company_id":    companyID,
+			"laptop_id":     laptopID,
+		},
 	})
 
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "pickup_form_submitted", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
+	if err := h.enqueuePickupNotifications(r.Context(), tx, shipmentID); err != nil {
+		return 0, err
 	}
 
 	// Commit transaction
@@ -824,6 +974,17 @@ func (h *PickupFormHandler) handleLegacyPickupForm(r *http.Request, user *models
 		return 0, fmt.Errorf("failed to create shipment: %w", err)
 	}
 
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		return 0, err
+	}
+
 	// Create pickup form with form data as JSONB
 	formDataJSON, err := json.Marshal(map[string]interface{}{
 		"contact_name":            formInput.ContactName,
@@ -868,21 +1029,29 @@ func (h *PickupFormHandler) handleLegacyPickupForm(r *http.Request, user *models
 		return 0, fmt.Errorf("failed to save pickup form: %w", err)
 	}
 
+	// Create one package per declared box
+	if err := models.EnsureShipmentPackages(r.Context(), tx, shipmentID, formInput.NumberOfBoxes, models.ShipmentPackage{
+		LengthIn: formInput.BulkLength,
+		WidthIn:  formInput.BulkWidth,
+		HeightIn: formInput.BulkHeight,
+		WeightLb: formInput.BulkWeight,
+	}); err != nil {
+		return 0, err
+	}
+
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":      "pickup_form_submitted",
-		"shipment_id": shipmentID,
-		"company_id":  companyID,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "pickup_form_submitted",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id": shipmentID,
+			"company_id":  companyID,
+		},
 	})
 
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "pickup_form_submitted", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
+	if err := h.enqueuePickupNotifications(r.Context(), tx, shipmentID); err != nil {
+		return 0, err
 	}
 
 	// Commit transaction
@@ -904,9 +1073,9 @@ func (h *PickupFormHandler) handleBulkToWarehouseForm(r *http.Request, user *mod
 
 	// For minimal creation, validate only JIRA ticket and company
 	if isMinimalCreation {
-		// Only logistics users can create minimal shipments
-		if user.Role != models.RoleLogistics {
-			return 0, fmt.Errorf("only logistics users can create minimal bulk shipments")
+		if !permissions.Can(user, permissions.ShipmentCreate) {
+			permissions.LogDenied(r, user, permissions.ShipmentCreate)
+			return 0, fmt.Errorf("you are not allowed to create minimal bulk shipments")
 		}
 
 		// Validate JIRA ticket format
@@ -943,23 +1112,28 @@ func (h *PickupFormHandler) handleBulkToWarehouseForm(r *http.Request, user *mod
 			return 0, fmt.Errorf("failed to create shipment: %w", err)
 		}
 
+		// Start the shipment's status history
+		if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+			ShipmentID:  shipmentID,
+			ToStatus:    shipment.Status,
+			ActorUserID: &user.ID,
+			Source:      models.StatusEventSourceUI,
+			OccurredAt:  shipment.CreatedAt,
+		}); err != nil {
+			return 0, err
+		}
+
 		// Create audit log entry
-		auditDetails, _ := json.Marshal(map[string]interface{}{
-			"action":             "minimal_bulk_shipment_created",
-			"shipment_id":        shipmentID,
-			"jira_ticket_number": jiraTicketNumber,
+		audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+			Action:     "minimal_bulk_shipment_created",
+			EntityType: audit.EntityShipment,
+			EntityID:   shipmentID,
+			Details: map[string]interface{}{
+				"shipment_id":        shipmentID,
+				"jira_ticket_number": jiraTicketNumber,
+			},
 		})
 
-		_, err = tx.ExecContext(r.Context(),
-			`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-			VALUES ($1, $2, $3, $4, $5, $6)`,
-			user.ID, "minimal_bulk_shipment_created", "shipment", shipmentID, time.Now(), auditDetails,
-		)
-		if err != nil {
-			// Non-critical, log and continue
-			fmt.Printf("Failed to create audit log: %v\n", err)
-		}
-
 		// Commit transaction
 		if err = tx.Commit(); err != nil {
 			return 0, fmt.Errorf("failed to commit transaction: %w", err)
@@ -1041,6 +1215,17 @@ func (h *PickupFormHandler) handleBulkToWarehouseForm(r *http.Request, user *mod
 		return 0, fmt.Errorf("failed to create shipment: %w", err)
 	}
 
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		return 0, err
+	}
+
 	// Create pickup form with form data as JSONB (including bulk dimensions)
 	formDataJSON, err := json.Marshal(map[string]interface{}{
 		"contact_name":            formInput.ContactName,
@@ -1083,27 +1268,35 @@ func (h *PickupFormHandler) handleBulkToWarehouseForm(r *http.Request, user *mod
 		return 0, fmt.Errorf("failed to save pickup form: %w", err)
 	}
 
+	// The bulk form describes a single package
+	if err := models.EnsureShipmentPackages(r.Context(), tx, shipmentID, 1, models.ShipmentPackage{
+		LengthIn: bulkLength,
+		WidthIn:  bulkWidth,
+		HeightIn: bulkHeight,
+		WeightLb: bulkWeight,
+	}); err != nil {
+		return 0, err
+	}
+
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":        "pickup_form_submitted",
-		"shipment_id":   shipmentID,
-		"shipment_type": models.ShipmentTypeBulkToWarehouse,
-		"company_id":    companyID,
-		"laptop_count":  numberOfLaptops,
-		"bulk_length":   bulkLength,
-		"bulk_width":    bulkWidth,
-		"bulk_height":   bulkHeight,
-		"bulk_weight":   bulkWeight,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "pickup_form_submitted",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id":   shipmentID,
+			"shipment_type": models.ShipmentTypeBulkToWarehouse,
+			"company_id":    companyID,
+			"laptop_count":  numberOfLaptops,
+			"bulk_length":   bulkLength,
+			"bulk_width":    bulkWidth,
+			"bulk_height":   bulkHeight,
+			"bulk_weight":   bulkWeight,
+		},
 	})
 
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "pickup_form_submitted", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
+	if err := h.enqueuePickupNotifications(r.Context(), tx, shipmentID); err != nil {
+		return 0, err
 	}
 
 	// Commit transaction
@@ -1191,6 +1384,17 @@ func (h *PickupFormHandler) handleWarehouseToEngineerForm(r *http.Request, user
 		return 0, err
 	}
 
+	// Customs details for the commercial invoice of international deliveries
+	customs := models.Shipment{
+		ExportReason:    models.ExportReason(r.FormValue("export_reason")),
+		RecipientTaxID:  r.FormValue("recipient_tax_id"),
+		CustomsCurrency: r.FormValue("customs_currency"),
+	}
+	customs.NormalizeCustoms()
+	if err := customs.ValidateCustoms(); err != nil {
+		return 0, err
+	}
+
 	// Start transaction
 	tx, err := h.DB.BeginTx(r.Context(), nil)
 	if err != nil {
@@ -1231,6 +1435,29 @@ func (h *PickupFormHandler) handleWarehouseToEngineerForm(r *http.Request, user
 		return 0, fmt.Errorf("laptop must have a completed reception report before shipping to engineer")
 	}
 
+	// Verify a returned laptop has been received again
+	var hasPendingReturn bool
+	err = tx.QueryRowContext(r.Context(),
+		`SELECT EXISTS(
+			SELECT 1 FROM shipment_laptops sl
+			JOIN shipments s ON s.id = sl.shipment_id
+			WHERE sl.laptop_id = $1
+			AND s.shipment_type = $2
+			AND s.status = $3
+			AND NOT EXISTS (
+				SELECT 1 FROM reception_reports rr
+				WHERE rr.laptop_id = sl.laptop_id AND rr.shipment_id = s.id
+			)
+		)`,
+		laptopID, models.ShipmentTypeEngineerToWarehouse, models.ShipmentStatusAtWarehouse,
+	).Scan(&hasPendingReturn)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check return shipments: %w", err)
+	}
+	if hasPendingReturn {
+		return 0, fmt.Errorf("returned laptop must have a reception report for its return shipment before shipping to engineer")
+	}
+
 	// Create shipment with warehouse_to_engineer type
 	shipment := models.Shipment{
 		ShipmentType:       models.ShipmentTypeWarehouseToEngineer,
@@ -1240,22 +1467,38 @@ func (h *PickupFormHandler) handleWarehouseToEngineerForm(r *http.Request, user
 		SoftwareEngineerID: softwareEngineerID,
 		JiraTicketNumber:   formInput.JiraTicketNumber,
 		Notes:              formInput.SpecialInstructions,
+		ExportReason:       customs.ExportReason,
+		RecipientTaxID:     customs.RecipientTaxID,
+		CustomsCurrency:    customs.CustomsCurrency,
 	}
 	shipment.BeforeCreate()
 
 	var shipmentID int64
 	err = tx.QueryRowContext(r.Context(),
-		`INSERT INTO shipments (shipment_type, client_company_id, status, laptop_count, software_engineer_id, jira_ticket_number, notes, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		`INSERT INTO shipments (shipment_type, client_company_id, status, laptop_count, software_engineer_id, jira_ticket_number, notes, created_at, updated_at,
+		                        export_reason, recipient_tax_id, customs_currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), $12)
 		RETURNING id`,
 		shipment.ShipmentType, shipment.ClientCompanyID, shipment.Status, shipment.LaptopCount,
 		shipment.SoftwareEngineerID, shipment.JiraTicketNumber, shipment.Notes,
 		shipment.CreatedAt, shipment.UpdatedAt,
+		shipment.ExportReason, shipment.RecipientTaxID, shipment.CustomsCurrency,
 	).Scan(&shipmentID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to create shipment: %w", err)
 	}
 
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		return 0, err
+	}
+
 	// Link laptop to shipment
 	_, err = tx.ExecContext(r.Context(),
 		`INSERT INTO shipment_laptops (shipment_id, laptop_id, created_at)
@@ -1332,25 +1575,213 @@ func (h *PickupFormHandler) handleWarehouseToEngineerForm(r *http.Request, user
 	}
 
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":               "warehouse_to_engineer_form_submitted",
-		"shipment_id":          shipmentID,
-		"shipment_type":        models.ShipmentTypeWarehouseToEngineer,
-		"company_id":           companyID,
-		"laptop_id":            laptopID,
-		"software_engineer_id": softwareEngineerID,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "warehouse_to_engineer_form_submitted",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id":          shipmentID,
+			"shipment_type":        models.ShipmentTypeWarehouseToEngineer,
+			"company_id":           companyID,
+			"laptop_id":            laptopID,
+			"software_engineer_id": softwareEngineerID,
+		},
 	})
 
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return shipmentID, nil
+}
+
+// handleEngineerToWarehouseForm handles engineer-to-warehouse (return) shipment form submission
+func (h *PickupFormHandler) handleEngineerToWarehouseForm(r *http.Request, user *models.User, companyID int64, includeAccessories bool) (int64, error) {
+	if !permissions.Can(user, permissions.ShipmentCreate) {
+		permissions.LogDenied(r, user, permissions.ShipmentCreate)
+		return 0, fmt.Errorf("you are not allowed to create return shipments")
+	}
+
+	// Parse laptop and engineer selection
+	var laptopID, softwareEngineerID int64
+	if laptopIDStr := r.FormValue("laptop_id"); laptopIDStr != "" {
+		id, err := strconv.ParseInt(laptopIDStr, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid laptop ID")
+		}
+		laptopID = id
+	}
+	if engineerIDStr := r.FormValue("software_engineer_id"); engineerIDStr != "" {
+		id, err := strconv.ParseInt(engineerIDStr, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid software engineer ID")
+		}
+		softwareEngineerID = id
+	}
+
+	// Build validation input
+	formInput := validator.EngineerToWarehouseFormInput{
+		LaptopID:            laptopID,
+		SoftwareEngineerID:  softwareEngineerID,
+		EngineerEmail:       r.FormValue("engineer_email"),
+		PickupAddress:       r.FormValue("engineer_address"),
+		PickupCity:          r.FormValue("engineer_city"),
+		PickupCountry:       r.FormValue("engineer_country"),
+		PickupState:         r.FormValue("engineer_state"),
+		PickupZip:           r.FormValue("engineer_zip"),
+		CourierName:         strings.TrimSpace(r.FormValue("courier_name")),
+		TrackingNumber:      strings.TrimSpace(r.FormValue("tracking_number")),
+		JiraTicketNumber:    r.FormValue("jira_ticket_number"),
+		SpecialInstructions: r.FormValue("special_instructions"),
+	}
+
+	// Validate form
+	if err := validator.ValidateEngineerToWarehouseForm(formInput); err != nil {
+		return 0, err
+	}
+
+	engineer, err := models.GetSoftwareEngineerByID(h.DB, softwareEngineerID)
+	if err != nil || engineer == nil {
+		return 0, fmt.Errorf("engineer with ID %d does not exist", softwareEngineerID)
+	}
+
+	// Start transaction
+	tx, err := h.DB.BeginTx(r.Context(), nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Verify the laptop is currently delivered to this engineer
+	var currentLaptopStatus models.LaptopStatus
+	var assignedEngineerID sql.NullInt64
+	err = tx.QueryRowContext(r.Context(),
+		`SELECT status, software_engineer_id FROM laptops WHERE id = $1`,
+		laptopID,
+	).Scan(&currentLaptopStatus, &assignedEngineerID)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("laptop not found")
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to query laptop: %w", err)
+	}
+	if !assignedEngineerID.Valid || assignedEngineerID.Int64 != softwareEngineerID {
+		return 0, fmt.Errorf("laptop is not assigned to the selected engineer")
+	}
+	if currentLaptopStatus != models.LaptopStatusDelivered {
+		return 0, fmt.Errorf("laptop is not with the engineer (current status: %s)", currentLaptopStatus)
+	}
+
+	// Verify the laptop is not already in an active shipment
+	var inActiveShipment bool
+	err = tx.QueryRowContext(r.Context(),
+		`SELECT EXISTS(
+			SELECT 1 FROM shipment_laptops sl
+			JOIN shipments s ON s.id = sl.shipment_id
+			WHERE sl.laptop_id = $1
+			AND s.status NOT IN ('delivered', 'at_warehouse', 'returned_to_sender', 'cancelled')
+		)`,
+		laptopID,
+	).Scan(&inActiveShipment)
+	if err != nil {
+		return 0, fmt.Errorf("failed to check active shipments: %w", err)
+	}
+	if inActiveShipment {
+		return 0, fmt.Errorf("laptop is already in an active shipment")
+	}
+
+	// Create shipment with engineer_to_warehouse type
+	shipment := models.Shipment{
+		ShipmentType:       models.ShipmentTypeEngineerToWarehouse,
+		ClientCompanyID:    companyID,
+		Status:             models.ShipmentStatusReturnKitSent, // Start once the return kit is on its way
+		LaptopCount:        1,
+		SoftwareEngineerID: &softwareEngineerID,
+		CourierName:        formInput.CourierName,
+		TrackingNumber:     formInput.TrackingNumber,
+		JiraTicketNumber:   formInput.JiraTicketNumber,
+		Notes:              formInput.SpecialInstructions,
+	}
+	shipment.BeforeCreate()
+
+	var shipmentID int64
+	err = tx.QueryRowContext(r.Context(),
+		`INSERT INTO shipments (shipment_type, client_company_id, status, laptop_count, software_engineer_id,
+		                        courier_name, tracking_number, jira_ticket_number, notes, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11)
+		RETURNING id`,
+		shipment.ShipmentType, shipment.ClientCompanyID, shipment.Status, shipment.LaptopCount,
+		shipment.SoftwareEngineerID, shipment.CourierName, shipment.TrackingNumber,
+		shipment.JiraTicketNumber, shipment.Notes,
+		shipment.CreatedAt, shipment.UpdatedAt,
+	).Scan(&shipmentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create shipment: %w", err)
+	}
+
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		return 0, err
+	}
+
+	// Link laptop to shipment
+	// The laptop keeps its status until it is picked up from the engineer
 	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "warehouse_to_engineer_form_submitted", "shipment", shipmentID, time.Now(), auditDetails,
+		`INSERT INTO shipment_laptops (shipment_id, laptop_id, created_at)
+		VALUES ($1, $2, $3)`,
+		shipmentID, laptopID, time.Now(),
 	)
 	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
+		return 0, fmt.Errorf("failed to link laptop to shipment: %w", err)
 	}
 
+	// Create pickup form record with all data as JSON
+	formData := map[string]interface{}{
+		"contact_name":         engineer.Name,
+		"contact_email":        formInput.EngineerEmail,
+		"contact_phone":        r.FormValue("engineer_phone"),
+		"pickup_address":       formInput.PickupAddress,
+		"pickup_city":          formInput.PickupCity,
+		"pickup_country":       formInput.PickupCountry,
+		"pickup_state":         formInput.PickupState,
+		"pickup_zip":           formInput.PickupZip,
+		"courier_name":         formInput.CourierName,
+		"tracking_number":      formInput.TrackingNumber,
+		"include_accessories":  includeAccessories,
+		"special_instructions": formInput.SpecialInstructions,
+		"laptop_id":            laptopID,
+	}
+	formDataJSON, _ := json.Marshal(formData)
+
+	_, err = tx.ExecContext(r.Context(),
+		`INSERT INTO pickup_forms (shipment_id, submitted_by_user_id, submitted_at, form_data)
+		VALUES ($1, $2, $3, $4)`,
+		shipmentID, user.ID, time.Now(), formDataJSON,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create pickup form: %w", err)
+	}
+
+	// Create audit log entry
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "engineer_to_warehouse_form_submitted",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id":          shipmentID,
+			"shipment_type":        models.ShipmentTypeEngineerToWarehouse,
+			"company_id":           companyID,
+			"laptop_id":            laptopID,
+			"software_engineer_id": softwareEngineerID,
+		},
+	})
+
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return 0, fmt.Errorf("failed to commit transaction: %w", err)
@@ -1374,9 +1805,7 @@ func (h *PickupFormHandler) CreateMinimalSingleShipment(w http.ResponseWriter, r
 		return
 	}
 
-	// Only logistics users can create minimal shipments
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only logistics users can create minimal shipments", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentCreate) {
 		return
 	}
 
@@ -1437,25 +1866,31 @@ func (h *PickupFormHandler) CreateMinimalSingleShipment(w http.ResponseWriter, r
 		return
 	}
 
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		http.Error(w, "Failed to record shipment status", http.StatusInternalServerError)
+		return
+	}
+
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":             "minimal_shipment_created",
-		"shipment_id":        shipmentID,
-		"shipment_type":      models.ShipmentTypeSingleFullJourney,
-		"company_id":         companyID,
-		"jira_ticket_number": jiraTicketNumber,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "minimal_shipment_created",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id":        shipmentID,
+			"shipment_type":      models.ShipmentTypeSingleFullJourney,
+			"company_id":         companyID,
+			"jira_ticket_number": jiraTicketNumber,
+		},
 	})
 
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "minimal_shipment_created", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
-	}
-
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
@@ -1691,22 +2126,25 @@ func (h *PickupFormHandler) CompleteShipmentDetails(w http.ResponseWriter, r *ht
 	}
 
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":       "shipment_details_completed",
-		"shipment_id":  shipmentID,
-		"company_id":   companyID,
-		"laptop_id":    laptopID,
-		"completed_by": user.Email,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "shipment_details_completed",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id":  shipmentID,
+			"company_id":   companyID,
+			"laptop_id":    laptopID,
+			"completed_by": user.Email,
+		},
 	})
 
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "shipment_details_completed", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
+	// Queue the pickup confirmation email (Step 4 in process flow)
+	// Skip it for warehouse-to-engineer and engineer-to-warehouse shipments (they don't have pickup from client)
+	if h.Notifier != nil && shipmentType != models.ShipmentTypeWarehouseToEngineer && shipmentType != models.ShipmentTypeEngineerToWarehouse {
+		if err := email.Enqueue(r.Context(), tx, email.KindPickupConfirmation, shipmentID); err != nil {
+			http.Error(w, "Failed to queue pickup confirmation", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Commit transaction
@@ -1716,19 +2154,9 @@ func (h *PickupFormHandler) CompleteShipmentDetails(w http.ResponseWriter, r *ht
 	}
 
 	// Mark magic link as used (if accessed via magic link)
-	magicLinkToken, err := auth.GetMagicLinkByShipmentAndUser(r.Context(), h.DB, shipmentID, user.ID)
-	if err == nil && magicLinkToken != "" {
-		_ = auth.MarkMagicLinkAsUsed(r.Context(), h.DB, magicLinkToken)
+	if err := auth.MarkShipmentMagicLinkAsUsed(r.Context(), h.DB, shipmentID, user.ID); err != nil {
 		// Log error but don't fail the request if marking as used fails
-	}
-
-	// Send pickup confirmation email (Step 4 in process flow)
-	// Skip notifications for warehouse-to-engineer shipments (they don't have pickup from client)
-	if h.Notifier != nil && shipmentType != models.ShipmentTypeWarehouseToEngineer {
-		if err := h.Notifier.SendPickupConfirmation(r.Context(), shipmentID); err != nil {
-			// Log error but don't fail the request
-			fmt.Printf("Warning: Failed to send pickup confirmation email: %v\n", err)
-		}
+		fmt.Printf("Warning: Failed to mark magic link as used: %v\n", err)
 	}
 
 	// Redirect to shipment detail page with success message
@@ -1750,9 +2178,7 @@ func (h *PickupFormHandler) EditShipmentDetails(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Only logistics users can edit shipment details
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only logistics users can edit shipment details", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentEdit) {
 		return
 	}
 
@@ -1956,22 +2382,16 @@ func (h *PickupFormHandler) EditShipmentDetails(w http.ResponseWriter, r *http.R
 	}
 
 	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":      "shipment_details_edited",
-		"shipment_id": shipmentID,
-		"edited_by":   user.Email,
+	audit.Log(r.Context(), tx, audit.UserActor(user), audit.Entry{
+		Action:     "shipment_details_edited",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"shipment_id": shipmentID,
+			"edited_by":   user.Email,
+		},
 	})
 
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "shipment_details_edited", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
-	}
-
 	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
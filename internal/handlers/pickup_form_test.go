@@ -993,21 +993,18 @@ func TestPickupFormHandler_SubmitWarehouseToEngineer(t *testing.T) {
 			t.Errorf("Expected status 303, got %d", w.Code)
 		}
 
-		// Wait a bit for async email goroutine to complete
-		time.Sleep(300 * time.Millisecond)
-
-		// Verify pickup confirmation notification was NOT logged
+		// Verify pickup confirmation notification was NOT queued
 		var count int
 		err = db.QueryRowContext(ctx,
-			`SELECT COUNT(*) FROM notification_logs 
-			WHERE type = 'pickup_confirmation' AND shipment_id IN (
+			`SELECT COUNT(*) FROM email_outbox
+			WHERE kind = 'pickup_confirmation' AND subject_id IN (
 				SELECT id FROM shipments WHERE jira_ticket_number = $1
 			)`,
 			"SCOP-NO-PICKUP-CONF",
 		).Scan(&count)
 
 		if err != nil {
-			t.Fatalf("Failed to query notification log: %v", err)
+			t.Fatalf("Failed to query email outbox: %v", err)
 		}
 
 		if count > 0 {
@@ -1058,21 +1055,18 @@ func TestPickupFormHandler_SubmitWarehouseToEngineer(t *testing.T) {
 			t.Errorf("Expected status 303, got %d", w.Code)
 		}
 
-		// Wait a bit for async email goroutine to complete
-		time.Sleep(300 * time.Millisecond)
-
-		// Verify pickup form submitted notification was NOT logged
+		// Verify pickup form submitted notification was NOT queued
 		var count int
 		err = db.QueryRowContext(ctx,
-			`SELECT COUNT(*) FROM notification_logs 
-			WHERE type = 'pickup_form_submitted' AND shipment_id IN (
+			`SELECT COUNT(*) FROM email_outbox
+			WHERE kind = 'pickup_form_submitted_logistics' AND subject_id IN (
 				SELECT id FROM shipments WHERE jira_ticket_number = $1
 			)`,
 			"SCOP-NO-FORM-SUBMITTED",
 		).Scan(&count)
 
 		if err != nil {
-			t.Fatalf("Failed to query notification log: %v", err)
+			t.Fatalf("Failed to query email outbox: %v", err)
 		}
 
 		if count > 0 {
@@ -18,6 +18,7 @@ import (
 	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/validator"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
@@ -57,9 +58,7 @@ func (h *ReceptionReportHandler) ReceptionReportPage(w http.ResponseWriter, r *h
 		return
 	}
 
-	// Only warehouse users can submit reception reports
-	if user.Role != models.RoleWarehouse {
-		http.Error(w, "Forbidden: Only warehouse users can access this page", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportCreate) {
 		return
 	}
 
@@ -109,6 +108,7 @@ func (h *ReceptionReportHandler) ReceptionReportPage(w http.ResponseWriter, r *h
 		"CurrentPage": "reception-reports",
 		"Shipment":    shipment,
 		"CompanyName": companyName,
+		"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -138,9 +138,7 @@ func (h *ReceptionReportHandler) ReceptionReportSubmit(w http.ResponseWriter, r
 		return
 	}
 
-	// Only warehouse users can submit reception reports
-	if user.Role != models.RoleWarehouse {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportCreate) {
 		return
 	}
 
@@ -253,70 +251,6 @@ func (h *ReceptionReportHandler) ReceptionReportSubmit(w http.ResponseWriter, r
 	// For now, return an error directing to new system
 	http.Error(w, "This endpoint is deprecated. Please use the laptop-based reception report system at /laptops/{id}/reception-report", http.StatusGone)
 	return
-	
-	/*
-	// Create reception report
-	report := models.ReceptionReport{
-		ShipmentID:      &shipmentID,
-		WarehouseUserID: user.ID,
-		Notes:           notes,
-	}
-	report.BeforeCreate()
-
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO reception_reports (shipment_id, warehouse_user_id, received_at, notes)
-		VALUES ($1, $2, $3, $4)`,
-		report.ShipmentID, report.WarehouseUserID, report.ReceivedAt,
-		report.Notes,
-	)
-	if err != nil {
-		http.Error(w, "Failed to save reception report", http.StatusInternalServerError)
-		return
-	}
-
-	// Update shipment status to "at_warehouse"
-	now := time.Now()
-	_, err = tx.ExecContext(r.Context(),
-		`UPDATE shipments 
-		SET status = $1, arrived_warehouse_at = $2, updated_at = $3
-		WHERE id = $4`,
-		models.ShipmentStatusAtWarehouse, now, now, shipmentID,
-	)
-	if err != nil {
-		http.Error(w, "Failed to update shipment status", http.StatusInternalServerError)
-		return
-	}
-
-	// Create audit log entry
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":      "reception_report_submitted",
-		"shipment_id": shipmentID,
-	})
-
-	_, err = tx.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "reception_report_submitted", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error, just log it
-		fmt.Printf("Failed to create audit log: %v\n", err)
-	}
-
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		http.Error(w, "Failed to commit transaction", http.StatusInternalServerError)
-		return
-	}
-
-	// TODO: Send notification to logistics about warehouse reception (Step 8 in process flow)
-	// This would require adding a new method to the Notifier like SendWarehouseReceptionNotification
-	// For now, logging the event via audit_logs is sufficient
-
-	// Redirect to success page or shipment detail
-	redirectURL := fmt.Sprintf("/shipments/%d?success=Reception+report+submitted+successfully", shipmentID)
-	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
-	*/
 }
 
 // ReceptionReportsList displays a list of all reception reports
@@ -328,9 +262,7 @@ func (h *ReceptionReportHandler) ReceptionReportsList(w http.ResponseWriter, r *
 		return
 	}
 
-	// Only warehouse and logistics users can view reception reports list
-	if user.Role != models.RoleWarehouse && user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only warehouse and logistics users can access this page", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportView) {
 		return
 	}
 
@@ -462,9 +394,7 @@ func (h *ReceptionReportHandler) ReceptionReportDetail(w http.ResponseWriter, r
 		return
 	}
 
-	// Only warehouse and logistics users can view reception reports
-	if user.Role != models.RoleWarehouse && user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only warehouse and logistics users can access this page", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReceptionReportView) {
 		return
 	}
 
@@ -571,6 +501,7 @@ func (h *ReceptionReportHandler) ReceptionReportDetail(w http.ResponseWriter, r
 			"Nav":         views.GetNavigationLinks(user.Role),
 			"CurrentPage": "reception-reports",
 			"Report":      report,
+			"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 		}
 
 		err := h.Templates.ExecuteTemplate(w, "laptop-reception-report-detail.html", data)
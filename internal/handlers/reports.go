@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/csv"
 	"fmt"
@@ -11,10 +12,12 @@ import (
 	"time"
 
 	"github.com/jung-kurt/gofpdf"
+	"github.com/lib/pq"
 	"github.com/xuri/excelize/v2"
 
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
 
@@ -32,7 +35,7 @@ func NewReportsHandler(db *sql.DB, templates *template.Template) *ReportsHandler
 	}
 }
 
-// requireReportsAccess checks if the user has access to reports (Client or Project Manager)
+// requireReportsAccess checks if the user has access to reports
 func (h *ReportsHandler) requireReportsAccess(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
 	user := middleware.GetUserFromContext(r.Context())
 	if user == nil {
@@ -40,9 +43,7 @@ func (h *ReportsHandler) requireReportsAccess(w http.ResponseWriter, r *http.Req
 		return nil, false
 	}
 	
-	// Allow Client and Project Manager roles
-	if user.Role != models.RoleClient && user.Role != models.RoleProjectManager {
-		http.Error(w, "Forbidden: Only client and project manager users can access reports", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ReportsView) {
 		return nil, false
 	}
 	
@@ -84,12 +85,8 @@ func (h *ReportsHandler) ShipmentStatusDashboard(w http.ResponseWriter, r *http.
 
 	format := r.URL.Query().Get("format")
 
-	// Get report data - PM users see all data, Client users see only their company's data
-	var companyID *int64
-	if user.Role == models.RoleClient {
-		companyID = user.ClientCompanyID
-	}
-	reportData, err := h.getShipmentStatusData(companyID)
+	// Get report data - PM users see their assigned companies, Client users see only their company's data
+	reportData, err := h.getShipmentStatusData(user.CompanyScope().IDs())
 	if err != nil {
 		log.Printf("Error getting shipment status data: %v", err)
 		http.Error(w, "Failed to load report data", http.StatusInternalServerError)
@@ -134,12 +131,8 @@ func (h *ReportsHandler) InventorySummaryReport(w http.ResponseWriter, r *http.R
 
 	format := r.URL.Query().Get("format")
 
-	// Get report data - PM users see all data, Client users see only their company's data
-	var companyID *int64
-	if user.Role == models.RoleClient {
-		companyID = user.ClientCompanyID
-	}
-	reportData, err := h.getInventorySummaryData(companyID)
+	// Get report data - PM users see their assigned companies, Client users see only their company's data
+	reportData, err := h.getInventorySummaryData(user.CompanyScope().IDs())
 	if err != nil {
 		log.Printf("Error getting inventory summary data: %v", err)
 		http.Error(w, "Failed to load report data", http.StatusInternalServerError)
@@ -184,12 +177,8 @@ func (h *ReportsHandler) ShipmentTimelineReport(w http.ResponseWriter, r *http.R
 
 	format := r.URL.Query().Get("format")
 
-	// Get report data - PM users see all data, Client users see only their company's data
-	var companyID *int64
-	if user.Role == models.RoleClient {
-		companyID = user.ClientCompanyID
-	}
-	reportData, err := h.getShipmentTimelineData(companyID)
+	// Get report data - PM users see their assigned companies, Client users see only their company's data
+	reportData, err := h.getShipmentTimelineData(user.CompanyScope().IDs())
 	if err != nil {
 		log.Printf("Error getting shipment timeline data: %v", err)
 		http.Error(w, "Failed to load report data", http.StatusInternalServerError)
@@ -235,6 +224,7 @@ type ShipmentStatusData struct {
 	PendingPickups      int
 	InTransitCount      int
 	DeliveredCount      int
+	ExceptionCount      int
 	AverageDeliveryTime float64
 	Shipments           []ShipmentStatusRow
 }
@@ -248,15 +238,16 @@ type ShipmentStatusRow struct {
 	LaptopCount       int
 	CourierName       string
 	TrackingNumber    string
+	ExceptionReason   string
 	CreatedAt         time.Time
 	DeliveredAt       *time.Time
 	DaysSinceCreated  int
 	DaysSinceDelivery *int
 }
 
-// getShipmentStatusData retrieves shipment status data for a client company
-// If companyID is nil, returns data for all companies (for PM users)
-func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatusData, error) {
+// getShipmentStatusData retrieves shipment status data for a set of client companies
+// If companyIDs is nil, returns data for all companies
+func (h *ReportsHandler) getShipmentStatusData(companyIDs []int64) (*ShipmentStatusData, error) {
 	data := &ShipmentStatusData{
 		ByStatus: make(map[string]int),
 		ByType:   make(map[string]int),
@@ -266,12 +257,12 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 	startOfYear := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
 
-	// Build query based on whether companyID is provided
+	// Build query based on whether companyIDs is provided
 	var query string
 	var args []interface{}
-	if companyID != nil {
-		query = `SELECT COUNT(*) FROM shipments WHERE client_company_id = $1`
-		args = []interface{}{*companyID}
+	if companyIDs != nil {
+		query = `SELECT COUNT(*) FROM shipments WHERE client_company_id = ANY($1)`
+		args = []interface{}{pq.Array(companyIDs)}
 	} else {
 		query = `SELECT COUNT(*) FROM shipments`
 		args = []interface{}{}
@@ -284,10 +275,10 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 	}
 
 	// Get this month's shipments
-	if companyID != nil {
+	if companyIDs != nil {
 		err = h.DB.QueryRow(
-			`SELECT COUNT(*) FROM shipments WHERE client_company_id = $1 AND created_at >= $2`,
-			*companyID, startOfMonth,
+			`SELECT COUNT(*) FROM shipments WHERE client_company_id = ANY($1) AND created_at >= $2`,
+			pq.Array(companyIDs), startOfMonth,
 		).Scan(&data.TotalThisMonth)
 	} else {
 		err = h.DB.QueryRow(
@@ -300,10 +291,10 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 	}
 
 	// Get this year's shipments
-	if companyID != nil {
+	if companyIDs != nil {
 		err = h.DB.QueryRow(
-			`SELECT COUNT(*) FROM shipments WHERE client_company_id = $1 AND created_at >= $2`,
-			*companyID, startOfYear,
+			`SELECT COUNT(*) FROM shipments WHERE client_company_id = ANY($1) AND created_at >= $2`,
+			pq.Array(companyIDs), startOfYear,
 		).Scan(&data.TotalThisYear)
 	} else {
 		err = h.DB.QueryRow(
@@ -317,10 +308,10 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 
 	// Get shipments by status
 	var rows *sql.Rows
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
-			`SELECT status, COUNT(*) FROM shipments WHERE client_company_id = $1 GROUP BY status`,
-			*companyID,
+			`SELECT status, COUNT(*) FROM shipments WHERE client_company_id = ANY($1) GROUP BY status`,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
@@ -342,10 +333,10 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 	}
 
 	// Get shipments by type
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
-			`SELECT shipment_type, COUNT(*) FROM shipments WHERE client_company_id = $1 GROUP BY shipment_type`,
-			*companyID,
+			`SELECT shipment_type, COUNT(*) FROM shipments WHERE client_company_id = ANY($1) GROUP BY shipment_type`,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
@@ -376,14 +367,19 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 
 	data.DeliveredCount = data.ByStatus[string(models.ShipmentStatusDelivered)]
 
+	// Calculate shipments in an exception status (on hold, lost, damaged, returned, cancelled)
+	for _, status := range models.GetExceptionStatuses() {
+		data.ExceptionCount += data.ByStatus[string(status)]
+	}
+
 	// Get average delivery time for delivered shipments
 	var avgDays sql.NullFloat64
-	if companyID != nil {
+	if companyIDs != nil {
 		err = h.DB.QueryRow(
 			`SELECT AVG(EXTRACT(EPOCH FROM (delivered_at - created_at)) / 86400)
 			 FROM shipments 
-			 WHERE client_company_id = $1 AND delivered_at IS NOT NULL`,
-			*companyID,
+			 WHERE client_company_id = ANY($1) AND delivered_at IS NOT NULL`,
+			pq.Array(companyIDs),
 		).Scan(&avgDays)
 	} else {
 		err = h.DB.QueryRow(
@@ -397,19 +393,19 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 	}
 
 	// Get detailed shipment list
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
 			`SELECT id, jira_ticket_number, shipment_type, status, laptop_count, 
-			 courier_name, tracking_number, created_at, delivered_at
+			 courier_name, tracking_number, COALESCE(exception_reason, ''), created_at, delivered_at
 			 FROM shipments 
-			 WHERE client_company_id = $1 
+			 WHERE client_company_id = ANY($1) 
 			 ORDER BY created_at DESC`,
-			*companyID,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
 			`SELECT id, jira_ticket_number, shipment_type, status, laptop_count, 
-			 courier_name, tracking_number, created_at, delivered_at
+			 courier_name, tracking_number, COALESCE(exception_reason, ''), created_at, delivered_at
 			 FROM shipments 
 			 ORDER BY created_at DESC`,
 		)
@@ -424,7 +420,7 @@ func (h *ReportsHandler) getShipmentStatusData(companyID *int64) (*ShipmentStatu
 		var deliveredAt sql.NullTime
 		err := rows.Scan(
 			&row.ID, &row.JiraTicket, &row.Type, &row.Status, &row.LaptopCount,
-			&row.CourierName, &row.TrackingNumber, &row.CreatedAt, &deliveredAt,
+			&row.CourierName, &row.TrackingNumber, &row.ExceptionReason, &row.CreatedAt, &deliveredAt,
 		)
 		if err != nil {
 			continue
@@ -469,9 +465,9 @@ type InventorySummaryRow struct {
 	UpdatedAt          time.Time
 }
 
-// getInventorySummaryData retrieves inventory summary data for a client company
-// If companyID is nil, returns data for all companies (for PM users)
-func (h *ReportsHandler) getInventorySummaryData(companyID *int64) (*InventorySummaryData, error) {
+// getInventorySummaryData retrieves inventory summary data for a set of client companies
+// If companyIDs is nil, returns data for all companies
+func (h *ReportsHandler) getInventorySummaryData(companyIDs []int64) (*InventorySummaryData, error) {
 	data := &InventorySummaryData{
 		ByStatus: make(map[string]int),
 		ByBrand:  make(map[string]int),
@@ -479,10 +475,10 @@ func (h *ReportsHandler) getInventorySummaryData(companyID *int64) (*InventorySu
 
 	// Get total laptops
 	var err error
-	if companyID != nil {
+	if companyIDs != nil {
 		err = h.DB.QueryRow(
-			`SELECT COUNT(*) FROM laptops WHERE client_company_id = $1`,
-			*companyID,
+			`SELECT COUNT(*) FROM laptops WHERE client_company_id = ANY($1)`,
+			pq.Array(companyIDs),
 		).Scan(&data.TotalLaptops)
 	} else {
 		err = h.DB.QueryRow(
@@ -495,10 +491,10 @@ func (h *ReportsHandler) getInventorySummaryData(companyID *int64) (*InventorySu
 
 	// Get laptops by status
 	var rows *sql.Rows
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
-			`SELECT status, COUNT(*) FROM laptops WHERE client_company_id = $1 GROUP BY status`,
-			*companyID,
+			`SELECT status, COUNT(*) FROM laptops WHERE client_company_id = ANY($1) GROUP BY status`,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
@@ -520,10 +516,10 @@ func (h *ReportsHandler) getInventorySummaryData(companyID *int64) (*InventorySu
 	}
 
 	// Get laptops by brand
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
-			`SELECT brand, COUNT(*) FROM laptops WHERE client_company_id = $1 AND brand IS NOT NULL GROUP BY brand`,
-			*companyID,
+			`SELECT brand, COUNT(*) FROM laptops WHERE client_company_id = ANY($1) AND brand IS NOT NULL GROUP BY brand`,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
@@ -545,10 +541,10 @@ func (h *ReportsHandler) getInventorySummaryData(companyID *int64) (*InventorySu
 	}
 
 	// Get assigned vs unassigned count
-	if companyID != nil {
+	if companyIDs != nil {
 		err = h.DB.QueryRow(
-			`SELECT COUNT(*) FROM laptops WHERE client_company_id = $1 AND software_engineer_id IS NOT NULL`,
-			*companyID,
+			`SELECT COUNT(*) FROM laptops WHERE client_company_id = ANY($1) AND software_engineer_id IS NOT NULL`,
+			pq.Array(companyIDs),
 		).Scan(&data.AssignedCount)
 	} else {
 		err = h.DB.QueryRow(
@@ -562,16 +558,16 @@ func (h *ReportsHandler) getInventorySummaryData(companyID *int64) (*InventorySu
 	data.UnassignedCount = data.TotalLaptops - data.AssignedCount
 
 	// Get detailed laptop list
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
 			`SELECT l.id, l.serial_number, l.brand, l.model, l.cpu, l.ram_gb, l.ssd_gb, 
 			 l.status, l.software_engineer_id, se.name as engineer_name,
 			 l.created_at, l.updated_at
 			 FROM laptops l
 			 LEFT JOIN software_engineers se ON se.id = l.software_engineer_id
-			 WHERE l.client_company_id = $1 
+			 WHERE l.client_company_id = ANY($1) 
 			 ORDER BY l.created_at DESC`,
-			*companyID,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
@@ -637,25 +633,27 @@ type ShipmentTimelineRow struct {
 	CreatedAt           time.Time
 	TotalDurationDays   *int
 	TimeBetweenStages   map[string]int // Days between stages
+	StatusChanges       int            // Number of recorded status changes
+	LastChangedBy       string         // Actor (or source) of the latest status change
 }
 
-// getShipmentTimelineData retrieves shipment timeline data for a client company
-// If companyID is nil, returns data for all companies (for PM users)
-func (h *ReportsHandler) getShipmentTimelineData(companyID *int64) (*ShipmentTimelineData, error) {
+// getShipmentTimelineData retrieves shipment timeline data for a set of client companies
+// If companyIDs is nil, returns data for all companies
+func (h *ReportsHandler) getShipmentTimelineData(companyIDs []int64) (*ShipmentTimelineData, error) {
 	data := &ShipmentTimelineData{}
 
 	var rows *sql.Rows
 	var err error
-	if companyID != nil {
+	if companyIDs != nil {
 		rows, err = h.DB.Query(
 			`SELECT id, jira_ticket_number, shipment_type, status, laptop_count,
 			 courier_name, tracking_number, pickup_scheduled_date, picked_up_at,
 			 arrived_warehouse_at, released_warehouse_at, eta_to_engineer, delivered_at,
 			 created_at
 			 FROM shipments 
-			 WHERE client_company_id = $1 
+			 WHERE client_company_id = ANY($1) 
 			 ORDER BY created_at DESC`,
-			*companyID,
+			pq.Array(companyIDs),
 		)
 	} else {
 		rows, err = h.DB.Query(
@@ -708,7 +706,31 @@ func (h *ReportsHandler) getShipmentTimelineData(companyID *int64) (*ShipmentTim
 		}
 		if deliveredAt.Valid {
 			row.DeliveredAt = &deliveredAt.Time
-			duration := int(deliveredAt.Time.Sub(row.CreatedAt).Hours() / 24)
+		}
+
+		data.Shipments = append(data.Shipments, row)
+	}
+
+	// Rebuild the milestones from the status history where it exists
+	shipmentIDs := make([]int64, 0, len(data.Shipments))
+	for _, row := range data.Shipments {
+		shipmentIDs = append(shipmentIDs, row.ID)
+	}
+	eventsByShipment, err := models.GetShipmentStatusEventsForShipments(context.Background(), h.DB, shipmentIDs)
+	if err != nil {
+		// Non-critical error, fall back to the milestone columns
+		log.Printf("Warning: failed to load shipment status history: %v", err)
+		eventsByShipment = map[int64][]models.ShipmentStatusEvent{}
+	}
+
+	for i := range data.Shipments {
+		row := &data.Shipments[i]
+		if events := eventsByShipment[row.ID]; len(events) > 0 {
+			applyStatusEventsToTimelineRow(row, events)
+		}
+
+		if row.DeliveredAt != nil {
+			duration := int(row.DeliveredAt.Sub(row.CreatedAt).Hours() / 24)
 			row.TotalDurationDays = &duration
 		}
 
@@ -730,13 +752,40 @@ func (h *ReportsHandler) getShipmentTimelineData(companyID *int64) (*ShipmentTim
 			days := int(row.DeliveredAt.Sub(*row.ReleasedWarehouseAt).Hours() / 24)
 			row.TimeBetweenStages["warehouse_to_delivered"] = days
 		}
-
-		data.Shipments = append(data.Shipments, row)
 	}
 
 	return data, nil
 }
 
+// applyStatusEventsToTimelineRow sets the row's milestones from the latest event reaching each of them
+// and records how often the status changed and who changed it last
+func applyStatusEventsToTimelineRow(row *ShipmentTimelineRow, events []models.ShipmentStatusEvent) {
+	eventTime := func(statuses ...models.ShipmentStatus) *time.Time {
+		var latest *time.Time
+		for _, status := range statuses {
+			if event := models.LatestStatusEvent(events, status); event != nil {
+				if latest == nil || event.OccurredAt.After(*latest) {
+					t := event.OccurredAt
+					latest = &t
+				}
+			}
+		}
+		return latest
+	}
+
+	row.PickedUpAt = eventTime(models.ShipmentStatusPickedUpFromClient, models.ShipmentStatusPickedUpFromEngineer)
+	row.ArrivedWarehouseAt = eventTime(models.ShipmentStatusAtWarehouse)
+	row.ReleasedWarehouseAt = eventTime(models.ShipmentStatusReleasedFromWarehouse)
+	row.DeliveredAt = eventTime(models.ShipmentStatusDelivered)
+
+	row.StatusChanges = len(events)
+	last := events[len(events)-1]
+	row.LastChangedBy = last.ActorEmail
+	if row.LastChangedBy == "" {
+		row.LastChangedBy = string(last.Source)
+	}
+}
+
 // Export functions will be implemented next...
 // Placeholder functions to satisfy compilation
 func (h *ReportsHandler) exportShipmentStatusCSV(w http.ResponseWriter, data *ShipmentStatusData) {
@@ -747,7 +796,7 @@ func (h *ReportsHandler) exportShipmentStatusCSV(w http.ResponseWriter, data *Sh
 	defer writer.Flush()
 
 	// Write header
-	writer.Write([]string{"ID", "JIRA Ticket", "Type", "Status", "Laptop Count", "Courier", "Tracking Number", "Created At", "Delivered At", "Days Since Created"})
+	writer.Write([]string{"ID", "JIRA Ticket", "Type", "Status", "Laptop Count", "Courier", "Tracking Number", "Created At", "Delivered At", "Days Since Created", "Exception Reason"})
 
 	// Write data
 	for _, shipment := range data.Shipments {
@@ -766,6 +815,7 @@ func (h *ReportsHandler) exportShipmentStatusCSV(w http.ResponseWriter, data *Sh
 			shipment.CreatedAt.Format("2006-01-02 15:04:05"),
 			deliveredAt,
 			strconv.Itoa(shipment.DaysSinceCreated),
+			shipment.ExceptionReason,
 		})
 	}
 }
@@ -779,7 +829,7 @@ func (h *ReportsHandler) exportShipmentStatusExcel(w http.ResponseWriter, data *
 	f.DeleteSheet("Sheet1")
 
 	// Set headers
-	headers := []string{"ID", "JIRA Ticket", "Type", "Status", "Laptop Count", "Courier", "Tracking Number", "Created At", "Delivered At", "Days Since Created"}
+	headers := []string{"ID", "JIRA Ticket", "Type", "Status", "Laptop Count", "Courier", "Tracking Number", "Created At", "Delivered At", "Days Since Created", "Exception Reason"}
 	for i, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, header)
@@ -802,6 +852,7 @@ func (h *ReportsHandler) exportShipmentStatusExcel(w http.ResponseWriter, data *
 		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), shipment.CreatedAt.Format("2006-01-02 15:04:05"))
 		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), deliveredAt)
 		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), shipment.DaysSinceCreated)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), shipment.ExceptionReason)
 	}
 
 	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
@@ -818,6 +869,8 @@ func (h *ReportsHandler) exportShipmentStatusPDF(w http.ResponseWriter, data *Sh
 
 	pdf.SetFont("Arial", "", 10)
 	pdf.Cell(40, 10, fmt.Sprintf("Total Shipments: %d", data.TotalShipments))
+	pdf.Ln(6)
+	pdf.Cell(40, 10, fmt.Sprintf("Exceptions: %d", data.ExceptionCount))
 	pdf.Ln(10)
 
 	// Table headers
@@ -954,7 +1007,7 @@ func (h *ReportsHandler) exportShipmentTimelineCSV(w http.ResponseWriter, data *
 	writer := csv.NewWriter(w)
 	defer writer.Flush()
 
-	writer.Write([]string{"ID", "JIRA Ticket", "Type", "Status", "Pickup Scheduled", "Picked Up", "Arrived Warehouse", "Released", "Delivered", "Total Days"})
+	writer.Write([]string{"ID", "JIRA Ticket", "Type", "Status", "Pickup Scheduled", "Picked Up", "Arrived Warehouse", "Released", "Delivered", "Total Days", "Status Changes", "Last Changed By"})
 
 	for _, shipment := range data.Shipments {
 		formatTime := func(t *time.Time) string {
@@ -978,6 +1031,8 @@ func (h *ReportsHandler) exportShipmentTimelineCSV(w http.ResponseWriter, data *
 			formatTime(shipment.ReleasedWarehouseAt),
 			formatTime(shipment.DeliveredAt),
 			totalDays,
+			strconv.Itoa(shipment.StatusChanges),
+			shipment.LastChangedBy,
 		})
 	}
 }
@@ -990,7 +1045,7 @@ func (h *ReportsHandler) exportShipmentTimelineExcel(w http.ResponseWriter, data
 	f.NewSheet(sheetName)
 	f.DeleteSheet("Sheet1")
 
-	headers := []string{"ID", "JIRA Ticket", "Type", "Status", "Pickup Scheduled", "Picked Up", "Arrived Warehouse", "Released", "Delivered", "Total Days"}
+	headers := []string{"ID", "JIRA Ticket", "Type", "Status", "Pickup Scheduled", "Picked Up", "Arrived Warehouse", "Released", "Delivered", "Total Days", "Status Changes", "Last Changed By"}
 	for i, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, header)
@@ -1019,6 +1074,8 @@ func (h *ReportsHandler) exportShipmentTimelineExcel(w http.ResponseWriter, data
 		f.SetCellValue(sheetName, fmt.Sprintf("H%d", row), formatTime(shipment.ReleasedWarehouseAt))
 		f.SetCellValue(sheetName, fmt.Sprintf("I%d", row), formatTime(shipment.DeliveredAt))
 		f.SetCellValue(sheetName, fmt.Sprintf("J%d", row), totalDays)
+		f.SetCellValue(sheetName, fmt.Sprintf("K%d", row), shipment.StatusChanges)
+		f.SetCellValue(sheetName, fmt.Sprintf("L%d", row), shipment.LastChangedBy)
 	}
 
 	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// AccountSessionsPage lists the devices the user is signed in on
+func (h *AuthHandler) AccountSessionsPage(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	sessions, err := auth.GetUserSessions(r.Context(), h.DB, user.ID)
+	if err != nil {
+		log.Printf("Error getting sessions: %v", err)
+		http.Error(w, "Failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	var currentSessionID int64
+	if current := middleware.GetSessionFromContext(r.Context()); current != nil {
+		currentSessionID = current.ID
+	}
+
+	data := map[string]interface{}{
+		"User":             user,
+		"Nav":              views.GetNavigationLinks(user.Role),
+		"CurrentPage":      "sessions",
+		"Sessions":         sessions,
+		"CurrentSessionID": currentSessionID,
+		"Success":          r.URL.Query().Get("success"),
+		"Error":            r.URL.Query().Get("error"),
+		"CSRFToken":        middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "account-sessions.html", data); err != nil {
+		log.Printf("Error executing sessions template: %v", err)
+		http.Error(w, "Failed to render sessions page", http.StatusInternalServerError)
+	}
+}
+
+// AccountSessionRevoke signs the user out of one of their sessions.
+// Revoking the current session is the same as logging out.
+func (h *AuthHandler) AccountSessionRevoke(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	sessionID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := auth.RevokeUserSession(r.Context(), h.DB, user.ID, sessionID)
+	if err != nil {
+		log.Printf("Error revoking session: %v", err)
+		redirectAccountSessions(w, r, "error", "Failed to sign out the session")
+		return
+	}
+	if !revoked {
+		redirectAccountSessions(w, r, "error", "Session not found")
+		return
+	}
+
+	h.logAudit(r, user.ID, "session_revoked", map[string]interface{}{
+		"session_id": sessionID,
+	})
+
+	if current := middleware.GetSessionFromContext(r.Context()); current != nil && current.ID == sessionID {
+		h.Logout(w, r)
+		return
+	}
+	redirectAccountSessions(w, r, "success", "Session signed out")
+}
+
+// AccountSessionsRevokeOthers signs the user out everywhere except the current session
+func (h *AuthHandler) AccountSessionsRevokeOthers(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	current := middleware.GetSessionFromContext(r.Context())
+	if current == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	count, err := auth.DeleteOtherUserSessions(r.Context(), h.DB, user.ID, current.ID)
+	if err != nil {
+		log.Printf("Error revoking sessions: %v", err)
+		redirectAccountSessions(w, r, "error", "Failed to sign out other sessions")
+		return
+	}
+
+	h.logAudit(r, user.ID, "sessions_revoked", map[string]interface{}{
+		"count": count,
+	})
+	redirectAccountSessions(w, r, "success", strconv.Itoa(count)+" other session(s) signed out")
+}
+
+func redirectAccountSessions(w http.ResponseWriter, r *http.Request, kind, message string) {
+	http.Redirect(w, r, "/account/sessions?"+kind+"="+url.QueryEscape(message), http.StatusSeeOther)
+}
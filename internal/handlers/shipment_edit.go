@@ -10,8 +10,10 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
 
@@ -24,9 +26,7 @@ func (h *ShipmentsHandler) EditShipmentGET(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Only logistics users can edit shipments
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentEdit) {
 		return
 	}
 
@@ -61,6 +61,7 @@ func (h *ShipmentsHandler) EditShipmentGET(w http.ResponseWriter, r *http.Reques
 		        s.picked_up_at, s.arrived_warehouse_at, s.released_warehouse_at, 
 		        s.eta_to_engineer, s.delivered_at, COALESCE(s.notes, '') as notes, 
 		        s.created_at, s.updated_at,
+		        COALESCE(s.export_reason, ''), COALESCE(s.recipient_tax_id, ''), s.customs_currency,
 		        c.name, se.id, se.name
 		FROM shipments s
 		JOIN client_companies c ON c.id = s.client_company_id
@@ -72,6 +73,7 @@ func (h *ShipmentsHandler) EditShipmentGET(w http.ResponseWriter, r *http.Reques
 		&s.JiraTicketNumber, &s.CourierName, &s.TrackingNumber, &s.SecondTrackingNumber, &s.SecondCourierName, &s.PickupScheduledDate,
 		&s.PickedUpAt, &s.ArrivedWarehouseAt, &s.ReleasedWarehouseAt,
 		&s.ETAToEngineer, &s.DeliveredAt, &s.Notes, &s.CreatedAt, &s.UpdatedAt,
+		&s.ExportReason, &s.RecipientTaxID, &s.CustomsCurrency,
 		&companyName, &engineerID, &engineerName,
 	)
 
@@ -126,12 +128,12 @@ func (h *ShipmentsHandler) EditShipmentGET(w http.ResponseWriter, r *http.Reques
 		}
 	}
 
-	// Get list of couriers from database
-	couriers, err := models.GetAllCouriers(h.DB)
+	// Get list of couriers and their service levels
+	couriers, err := models.LoadCourierDirectory(h.DB)
 	if err != nil {
 		// Non-critical error, log but continue with empty list
 		fmt.Printf("Warning: Failed to load couriers: %v\n", err)
-		couriers = []models.Courier{}
+		couriers = models.CourierDirectory{}
 	}
 
 	data := map[string]interface{}{
@@ -146,6 +148,9 @@ func (h *ShipmentsHandler) EditShipmentGET(w http.ResponseWriter, r *http.Reques
 		"PickupFormData": pickupFormData,
 		"TimeSlots":      []string{"morning", "afternoon", "evening"},
 		"Couriers":       couriers,
+		"ShowCustoms":    models.CanHaveCommercialInvoice(s.ShipmentType),
+		"ExportReasons":  models.GetExportReasons(),
+		"CSRFToken":      middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -171,9 +176,7 @@ func (h *ShipmentsHandler) EditShipmentPOST(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Only logistics users can edit shipments
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentEdit) {
 		return
 	}
 
@@ -216,6 +219,12 @@ func (h *ShipmentsHandler) EditShipmentPOST(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// Keep the editable fields for the audit log
+	before, err := loadShipmentEditFields(r.Context(), h.DB, shipmentID)
+	if err != nil {
+		fmt.Printf("Error loading shipment fields for audit: %v\n", err)
+	}
+
 	// Update software engineer if provided (and not bulk shipment)
 	engineerIDStr := r.FormValue("software_engineer_id")
 	if engineerIDStr != "" && currentShipment.ShipmentType != models.ShipmentTypeBulkToWarehouse {
@@ -261,50 +270,74 @@ func (h *ShipmentsHandler) EditShipmentPOST(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	// Update second tracking number if provided
-	secondTrackingNumber := r.FormValue("second_tracking_number")
-	_, err = h.DB.ExecContext(r.Context(),
-		`UPDATE shipments SET second_tracking_number = $1, updated_at = $2 WHERE id = $3`,
-		secondTrackingNumber, time.Now(), shipmentID,
-	)
-	if err != nil {
-		fmt.Printf("Error updating second tracking number: %v\n", err)
-		http.Error(w, "Failed to update second tracking number", http.StatusInternalServerError)
-		return
-	}
-
-	// Update second courier name if provided
-	secondCourierName := r.FormValue("second_courier_name")
-	if secondCourierName != "" {
-		// Validate second courier name
-		valid, err := models.IsValidCourierName(h.DB, secondCourierName)
+	// Legacy second tracking fields are only updated when the form still sends them;
+	// additional tracking numbers are recorded per package on the shipment detail page
+	if _, ok := r.Form["second_tracking_number"]; ok {
+		// Update second tracking number if provided
+		secondTrackingNumber := r.FormValue("second_tracking_number")
+		_, err = h.DB.ExecContext(r.Context(),
+			`UPDATE shipments SET second_tracking_number = $1, updated_at = $2 WHERE id = $3`,
+			secondTrackingNumber, time.Now(), shipmentID,
+		)
 		if err != nil {
-			http.Error(w, "Failed to validate second courier name", http.StatusInternalServerError)
+			fmt.Printf("Error updating second tracking number: %v\n", err)
+			http.Error(w, "Failed to update second tracking number", http.StatusInternalServerError)
 			return
 		}
-		if !valid {
-			http.Error(w, "Invalid second courier name. Courier must exist in the system", http.StatusBadRequest)
-			return
+
+		// Update second courier name if provided
+		secondCourierName := r.FormValue("second_courier_name")
+		if secondCourierName != "" {
+			// Validate second courier name
+			valid, err := models.IsValidCourierName(h.DB, secondCourierName)
+			if err != nil {
+				http.Error(w, "Failed to validate second courier name", http.StatusInternalServerError)
+				return
+			}
+			if !valid {
+				http.Error(w, "Invalid second courier name. Courier must exist in the system", http.StatusBadRequest)
+				return
+			}
+
+			_, err = h.DB.ExecContext(r.Context(),
+				`UPDATE shipments SET second_courier_name = $1, updated_at = $2 WHERE id = $3`,
+				secondCourierName, time.Now(), shipmentID,
+			)
+			if err != nil {
+				fmt.Printf("Error updating second courier name: %v\n", err)
+				http.Error(w, "Failed to update second courier name", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			// Clear second courier name if empty string is provided
+			_, err = h.DB.ExecContext(r.Context(),
+				`UPDATE shipments SET second_courier_name = NULL, updated_at = $1 WHERE id = $2`,
+				time.Now(), shipmentID,
+			)
+			if err != nil {
+				fmt.Printf("Error clearing second courier name: %v\n", err)
+				// Non-critical error, continue
+			}
 		}
+	}
 
-		_, err = h.DB.ExecContext(r.Context(),
-			`UPDATE shipments SET second_courier_name = $1, updated_at = $2 WHERE id = $3`,
-			secondCourierName, time.Now(), shipmentID,
-		)
-		if err != nil {
-			fmt.Printf("Error updating second courier name: %v\n", err)
-			http.Error(w, "Failed to update second courier name", http.StatusInternalServerError)
+	// Update customs details of deliveries to engineers, used on the commercial invoice
+	if _, ok := r.Form["customs_currency"]; ok && models.CanHaveCommercialInvoice(currentShipment.ShipmentType) {
+		customs := models.Shipment{
+			ID:              shipmentID,
+			ExportReason:    models.ExportReason(r.FormValue("export_reason")),
+			RecipientTaxID:  r.FormValue("recipient_tax_id"),
+			CustomsCurrency: r.FormValue("customs_currency"),
+		}
+		customs.NormalizeCustoms()
+		if err := customs.ValidateCustoms(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-	} else {
-		// Clear second courier name if empty string is provided
-		_, err = h.DB.ExecContext(r.Context(),
-			`UPDATE shipments SET second_courier_name = NULL, updated_at = $1 WHERE id = $2`,
-			time.Now(), shipmentID,
-		)
-		if err != nil {
-			fmt.Printf("Error clearing second courier name: %v\n", err)
-			// Non-critical error, continue
+		if err := models.UpdateShipmentCustoms(r.Context(), h.DB, &customs); err != nil {
+			fmt.Printf("Error updating customs details: %v\n", err)
+			http.Error(w, "Failed to update customs details", http.StatusInternalServerError)
+			return
 		}
 	}
 
@@ -375,20 +408,19 @@ func (h *ShipmentsHandler) EditShipmentPOST(w http.ResponseWriter, r *http.Reque
 		}
 	}
 
-	// Create audit log
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action": "shipment_edited",
-	})
-
-	_, err = h.DB.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "shipment_edited", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error
-		fmt.Printf("Failed to create audit log: %v\n", err)
+	// Create audit log entry
+	var changes []models.AuditChange
+	if after, err := loadShipmentEditFields(r.Context(), h.DB, shipmentID); err != nil {
+		fmt.Printf("Error loading shipment fields for audit: %v\n", err)
+	} else if before != nil {
+		changes = audit.Diff(before, after)
 	}
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "shipment_edited",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Changes:    changes,
+	})
 
 	// Redirect back to shipment detail
 	redirectURL := fmt.Sprintf("/shipments/%d?success=Shipment+details+updated+successfully", shipmentID)
@@ -427,3 +459,46 @@ func canEditShipment(shipment *models.Shipment, db *sql.DB, ctx context.Context,
 	return true, ""
 }
 
+// loadShipmentEditFields returns the fields of a shipment that can be edited after creation,
+// with the pickup form fields prefixed by "pickup_form.", for diffing in the audit log
+func loadShipmentEditFields(ctx context.Context, db *sql.DB, shipmentID int64) (map[string]interface{}, error) {
+	var engineerID sql.NullInt64
+	var courierName, secondTrackingNumber, secondCourierName, exportReason, recipientTaxID, customsCurrency sql.NullString
+	var formData []byte
+	err := db.QueryRowContext(ctx,
+		`SELECT s.software_engineer_id, s.courier_name, s.second_tracking_number, s.second_courier_name,
+			s.export_reason, s.recipient_tax_id, s.customs_currency, pf.form_data
+		FROM shipments s
+		LEFT JOIN pickup_forms pf ON pf.shipment_id = s.id
+		WHERE s.id = $1`,
+		shipmentID,
+	).Scan(&engineerID, &courierName, &secondTrackingNumber, &secondCourierName,
+		&exportReason, &recipientTaxID, &customsCurrency, &formData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment %d: %w", shipmentID, err)
+	}
+
+	fields := map[string]interface{}{
+		"courier_name":           courierName.String,
+		"second_tracking_number": secondTrackingNumber.String,
+		"second_courier_name":    secondCourierName.String,
+		"export_reason":          exportReason.String,
+		"recipient_tax_id":       recipientTaxID.String,
+		"customs_currency":       customsCurrency.String,
+	}
+	if engineerID.Valid {
+		fields["software_engineer_id"] = engineerID.Int64
+	}
+
+	if len(formData) > 0 {
+		var form map[string]interface{}
+		if err := json.Unmarshal(formData, &form); err != nil {
+			return nil, fmt.Errorf("failed to decode pickup form of shipment %d: %w", shipmentID, err)
+		}
+		for key, value := range form {
+			fields["pickup_form."+key] = value
+		}
+	}
+
+	return fields, nil
+}
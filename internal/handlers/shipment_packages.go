@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
+
+// CreateShipmentPackage adds a package to a shipment (logistics only)
+func (h *ShipmentsHandler) CreateShipmentPackage(w http.ResponseWriter, r *http.Request) {
+	user, shipmentID, ok := h.packageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	pkg := models.ShipmentPackage{ShipmentID: shipmentID}
+	if err := parsePackageForm(r, &pkg); err != nil {
+		redirectWithPackageMessage(w, r, shipmentID, "error", err.Error())
+		return
+	}
+	if message, err := h.checkPackageCourier(&pkg); err != nil {
+		http.Error(w, "Failed to validate courier name", http.StatusInternalServerError)
+		return
+	} else if message != "" {
+		redirectWithPackageMessage(w, r, shipmentID, "error", message)
+		return
+	}
+
+	if err := models.CreateShipmentPackage(r.Context(), h.DB, &pkg); err != nil {
+		fmt.Printf("Error creating shipment package: %v\n", err)
+		redirectWithPackageMessage(w, r, shipmentID, "error", "Failed to add package")
+		return
+	}
+
+	if err := h.savePackageLaptops(r, shipmentID, pkg.ID); err != nil {
+		fmt.Printf("Warning: Failed to set package laptops: %v\n", err)
+	}
+
+	h.logPackageAudit(r, user, "package_added", shipmentID, &pkg)
+	redirectWithPackageMessage(w, r, shipmentID, "success", fmt.Sprintf("Package %d added", pkg.PackageNumber))
+}
+
+// UpdateShipmentPackage saves a package's dimensions, tracking and laptops (logistics only)
+func (h *ShipmentsHandler) UpdateShipmentPackage(w http.ResponseWriter, r *http.Request) {
+	user, shipmentID, ok := h.packageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	packageID, err := strconv.ParseInt(mux.Vars(r)["packageID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid package ID", http.StatusBadRequest)
+		return
+	}
+
+	pkg := models.ShipmentPackage{ID: packageID, ShipmentID: shipmentID}
+	if err := parsePackageForm(r, &pkg); err != nil {
+		redirectWithPackageMessage(w, r, shipmentID, "error", err.Error())
+		return
+	}
+	if message, err := h.checkPackageCourier(&pkg); err != nil {
+		http.Error(w, "Failed to validate courier name", http.StatusInternalServerError)
+		return
+	} else if message != "" {
+		redirectWithPackageMessage(w, r, shipmentID, "error", message)
+		return
+	}
+
+	if err := models.UpdateShipmentPackage(r.Context(), h.DB, &pkg); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Package not found", http.StatusNotFound)
+			return
+		}
+		fmt.Printf("Error updating shipment package: %v\n", err)
+		redirectWithPackageMessage(w, r, shipmentID, "error", "Failed to update package")
+		return
+	}
+
+	if err := h.savePackageLaptops(r, shipmentID, pkg.ID); err != nil {
+		fmt.Printf("Warning: Failed to set package laptops: %v\n", err)
+	}
+
+	h.logPackageAudit(r, user, "package_updated", shipmentID, &pkg)
+	redirectWithPackageMessage(w, r, shipmentID, "success", "Package updated")
+}
+
+// DeleteShipmentPackage removes a package from a shipment (logistics only)
+func (h *ShipmentsHandler) DeleteShipmentPackage(w http.ResponseWriter, r *http.Request) {
+	user, shipmentID, ok := h.packageRequest(w, r)
+	if !ok {
+		return
+	}
+
+	packageID, err := strconv.ParseInt(mux.Vars(r)["packageID"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid package ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteShipmentPackage(r.Context(), h.DB, shipmentID, packageID); err != nil {
+		if err == sql.ErrNoRows {
+			http.Error(w, "Package not found", http.StatusNotFound)
+			return
+		}
+		fmt.Printf("Error deleting shipment package: %v\n", err)
+		redirectWithPackageMessage(w, r, shipmentID, "error", "Failed to remove package")
+		return
+	}
+
+	h.logPackageAudit(r, user, "package_removed", shipmentID, &models.ShipmentPackage{ID: packageID})
+	redirectWithPackageMessage(w, r, shipmentID, "success", "Package removed")
+}
+
+// packageRequest checks the user and shipment of a package request
+// Writes the error response and returns false when the request cannot continue
+func (h *ShipmentsHandler) packageRequest(w http.ResponseWriter, r *http.Request) (*models.User, int64, bool) {
+	user := middleware.GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return nil, 0, false
+	}
+
+	if !middleware.Authorize(w, r, permissions.ShipmentManagePackages) {
+		return nil, 0, false
+	}
+
+	shipmentID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid shipment ID", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	var exists bool
+	if err := h.DB.QueryRowContext(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM shipments WHERE id = $1)`,
+		shipmentID,
+	).Scan(&exists); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, 0, false
+	}
+	if !exists {
+		http.Error(w, "Shipment not found", http.StatusNotFound)
+		return nil, 0, false
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	return user, shipmentID, true
+}
+
+// parsePackageForm reads the package fields from a submitted form
+func parsePackageForm(r *http.Request, pkg *models.ShipmentPackage) error {
+	measurements := []struct {
+		field string
+		label string
+		dest  *float64
+	}{
+		{"length_in", "length", &pkg.LengthIn},
+		{"width_in", "width", &pkg.WidthIn},
+		{"height_in", "height", &pkg.HeightIn},
+		{"weight_lb", "weight", &pkg.WeightLb},
+	}
+	for _, m := range measurements {
+		value := strings.TrimSpace(r.FormValue(m.field))
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("invalid package %s", m.label)
+		}
+		*m.dest = parsed
+	}
+
+	pkg.CourierName = r.FormValue("courier_name")
+	pkg.TrackingNumber = r.FormValue("tracking_number")
+	pkg.Notes = r.FormValue("notes")
+	return nil
+}
+
+// checkPackageCourier checks the package courier and tracking number against the courier records.
+// Returns the message to show when they do not match.
+func (h *ShipmentsHandler) checkPackageCourier(pkg *models.ShipmentPackage) (string, error) {
+	courierName := strings.TrimSpace(pkg.CourierName)
+	if courierName == "" {
+		return "", nil
+	}
+
+	couriers, err := models.LoadCourierDirectory(h.DB)
+	if err != nil {
+		return "", err
+	}
+	if !couriers.IsValidName(courierName) {
+		return "Invalid courier name. Courier must exist in the system", nil
+	}
+	if trackingNumber := strings.TrimSpace(pkg.TrackingNumber); trackingNumber != "" {
+		if err := couriers.ValidateTrackingNumber(courierName, trackingNumber); err != nil {
+			return "Invalid tracking number for " + courierName + ". Check the number and try again", nil
+		}
+	}
+	return "", nil
+}
+
+// savePackageLaptops replaces the package's laptops with the submitted laptop_ids
+func (h *ShipmentsHandler) savePackageLaptops(r *http.Request, shipmentID, packageID int64) error {
+	laptopIDs := []int64{}
+	for _, value := range r.Form["laptop_ids"] {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		laptopIDs = append(laptopIDs, id)
+	}
+	return models.SetPackageLaptops(r.Context(), h.DB, shipmentID, packageID, laptopIDs)
+}
+
+// logPackageAudit writes an audit log entry for a package change
+func (h *ShipmentsHandler) logPackageAudit(r *http.Request, user *models.User, action string, shipmentID int64, pkg *models.ShipmentPackage) {
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     action,
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"package_id":      pkg.ID,
+			"package_number":  pkg.PackageNumber,
+			"courier_name":    pkg.CourierName,
+			"tracking_number": pkg.TrackingNumber,
+		},
+	})
+}
+
+// redirectWithPackageMessage redirects back to the packages section of the shipment detail page
+func redirectWithPackageMessage(w http.ResponseWriter, r *http.Request, shipmentID int64, kind, message string) {
+	redirectURL := fmt.Sprintf("/shipments/%d?%s=%s#packages", shipmentID, kind, url.QueryEscape(message))
+	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+}
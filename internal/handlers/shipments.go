@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -15,12 +16,16 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/lib/pq"
 
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
 	"github.com/yourusername/laptop-tracking-system/internal/auth"
 	"github.com/yourusername/laptop-tracking-system/internal/email"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/tracking"
 	"github.com/yourusername/laptop-tracking-system/internal/validator"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
+	"github.com/yourusername/laptop-tracking-system/internal/workflow"
 )
 
 // ShipmentsHandler handles shipment-related requests
@@ -29,6 +34,8 @@ type ShipmentsHandler struct {
 	Templates     *template.Template
 	JiraValidator models.JiraTicketValidator
 	EmailNotifier *email.Notifier
+	Tracking      *tracking.Service      // Courier tracking, nil when disabled
+	Warehouse     models.ShippingAddress // Printed on packing slips
 }
 
 // NewShipmentsHandler creates a new ShipmentsHandler
@@ -49,6 +56,10 @@ func (h *ShipmentsHandler) ShipmentsList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !middleware.Authorize(w, r, permissions.ShipmentView) {
+		return
+	}
+
 	// Get filter parameters
 	statusFilter := r.URL.Query().Get("status")
 	typeFilter := r.URL.Query().Get("type")
@@ -89,8 +100,14 @@ func (h *ShipmentsHandler) ShipmentsList(w http.ResponseWriter, r *http.Request)
 	case models.RoleWarehouse:
 		// Warehouse users see shipments in transit or at warehouse
 		baseQuery += " AND s.status IN ('in_transit_to_warehouse', 'at_warehouse', 'released_from_warehouse')"
-	case models.RoleLogistics, models.RoleProjectManager:
-		// Logistics and PM users can see all shipments - no additional filter needed
+	case models.RoleProjectManager:
+		// Project managers only see shipments of the client companies assigned to them
+		condition, scopeArgs := user.CompanyScope().Condition("s.client_company_id", argCount)
+		baseQuery += " AND " + condition
+		args = append(args, scopeArgs...)
+		argCount += len(scopeArgs)
+	case models.RoleLogistics:
+		// Logistics users can see all shipments - no additional filter needed
 	}
 
 	// Status filter
@@ -126,6 +143,14 @@ func (h *ShipmentsHandler) ShipmentsList(w http.ResponseWriter, r *http.Request)
 	}
 	defer rows.Close()
 
+	// Tracking links come from each shipment courier's record
+	couriers, err := models.LoadCourierDirectory(h.DB)
+	if err != nil {
+		// Non-critical error, fall back to the built-in couriers
+		fmt.Printf("Warning: Failed to load couriers: %v\n", err)
+		couriers = models.DefaultCourierDirectory()
+	}
+
 	shipments := []map[string]interface{}{}
 	for rows.Next() {
 		var s models.Shipment
@@ -157,7 +182,7 @@ func (h *ShipmentsHandler) ShipmentsList(w http.ResponseWriter, r *http.Request)
 			"Shipment":     s,
 			"CompanyName":  companyName,
 			"EngineerName": engineerName.String,
-			"TrackingURL":  s.GetTrackingURL(),
+			"TrackingURL":  couriers.TrackingURL(s.CourierName, s.TrackingNumber),
 		}
 		shipments = append(shipments, shipment)
 	}
@@ -179,11 +204,7 @@ func (h *ShipmentsHandler) ShipmentsList(w http.ResponseWriter, r *http.Request)
 		"SortBy":       sortBy,
 		"SortOrder":    sortOrder,
 		"AllStatuses":  models.GetStatusesForRoleFilter(user.Role),
-		"AllShipmentTypes": []models.ShipmentType{
-			models.ShipmentTypeSingleFullJourney,
-			models.ShipmentTypeBulkToWarehouse,
-			models.ShipmentTypeWarehouseToEngineer,
-		},
+		"AllShipmentTypes": models.GetAllShipmentTypes(),
 	}
 
 	if h.Templates != nil {
@@ -208,6 +229,10 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if !middleware.Authorize(w, r, permissions.ShipmentView) {
+		return
+	}
+
 	// Get shipment ID from URL path variable
 	vars := mux.Vars(r)
 	shipmentIDStr := vars["id"]
@@ -239,6 +264,7 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 		        s.pickup_scheduled_date,
 		        s.picked_up_at, s.arrived_warehouse_at, s.released_warehouse_at, 
 		        s.eta_to_engineer, s.delivered_at, COALESCE(s.notes, '') as notes, 
+		        COALESCE(s.exception_reason, '') as exception_reason, s.exception_at, s.status_before_exception,
 		        s.created_at, s.updated_at,
 		        c.name, se.name, se.email, se.employee_number
 		FROM shipments s
@@ -250,7 +276,9 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 		&s.ID, &s.ShipmentType, &s.LaptopCount, &s.ClientCompanyID, &s.SoftwareEngineerID, &s.Status,
 		&s.JiraTicketNumber, &s.CourierName, &s.TrackingNumber, &s.SecondTrackingNumber, &s.SecondCourierName, &s.PickupScheduledDate,
 		&s.PickedUpAt, &s.ArrivedWarehouseAt, &s.ReleasedWarehouseAt,
-		&s.ETAToEngineer, &s.DeliveredAt, &s.Notes, &s.CreatedAt, &s.UpdatedAt,
+		&s.ETAToEngineer, &s.DeliveredAt, &s.Notes,
+		&s.ExceptionReason, &s.ExceptionAt, &s.StatusBeforeException,
+		&s.CreatedAt, &s.UpdatedAt,
 		&companyName, &engineerName, &engineerEmail, &engineerEmployeeNumber,
 	)
 
@@ -306,6 +334,12 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 			args[i] = id
 		}
 		query := fmt.Sprintf(`SELECT laptop_id, id FROM reception_reports WHERE laptop_id IN (%s)`, strings.Join(placeholders, ","))
+		if s.ShipmentType == models.ShipmentTypeEngineerToWarehouse {
+			// A returned laptop needs a new report; reports from earlier arrivals don't count
+			query += fmt.Sprintf(" AND shipment_id = $%d", len(args)+1)
+			args = append(args, s.ID)
+		}
+		query += " ORDER BY received_at, id"
 		
 		reportRows, err := h.DB.QueryContext(r.Context(), query, args...)
 		if err == nil {
@@ -387,116 +421,78 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 	successMsg := r.URL.Query().Get("success")
 	warningMsg := r.URL.Query().Get("warning")
 
-	// Generate tracking URLs if courier and tracking numbers are present
-	trackingURL := s.GetTrackingURL()
-	secondTrackingURL := s.GetSecondTrackingURL()
-
-	// Build complete timeline for the shipment
-	timeline := models.BuildTimeline(&s)
-
-	// Get next allowed statuses for sequential validation
-	nextAllowedStatuses := s.GetNextAllowedStatuses()
-
-	// Filter out 'in_transit_to_engineer' from NextAllowedStatuses if no engineer assigned
-	// Only for single_full_journey and warehouse_to_engineer shipment types
-	if s.ShipmentType == models.ShipmentTypeSingleFullJourney || s.ShipmentType == models.ShipmentTypeWarehouseToEngineer {
-		if s.SoftwareEngineerID == nil {
-			// Filter out in_transit_to_engineer from the list
-			filteredStatuses := []models.ShipmentStatus{}
-			for _, status := range nextAllowedStatuses {
-				if status != models.ShipmentStatusInTransitToEngineer {
-					filteredStatuses = append(filteredStatuses, status)
-				}
-			}
-			nextAllowedStatuses = filteredStatuses
-			
-			// Also show warning message if in_transit_to_engineer would have been available
-			if warningMsg == "" {
-				originalNextAllowedStatuses := s.GetNextAllowedStatuses()
-				for _, status := range originalNextAllowedStatuses {
-					if status == models.ShipmentStatusInTransitToEngineer {
-						warningMsg = "⚠️ An engineer must be assigned before updating the status to 'In Transit to Engineer'. Please assign an engineer first."
-						break
-					}
-				}
-			}
+	// Generate tracking URLs from the courier records if courier and tracking numbers are present
+	couriers, err := models.LoadCourierDirectory(h.DB)
+	if err != nil {
+		// Non-critical error, fall back to the built-in couriers
+		fmt.Printf("Warning: Failed to load couriers: %v\n", err)
+		couriers = models.DefaultCourierDirectory()
+	}
+	trackingURL := couriers.TrackingURL(s.CourierName, s.TrackingNumber)
+	secondTrackingURL := ""
+	if s.SecondTrackingNumber != "" {
+		secondCourierName := s.SecondCourierName
+		if secondCourierName == "" {
+			secondCourierName = s.CourierName
 		}
+		secondTrackingURL = couriers.TrackingURL(secondCourierName, s.SecondTrackingNumber)
 	}
 
-	// Check if pickup form (Complete Shipment Details) is required before updating to pickup_from_client_scheduled
-	// Filter out 'pickup_from_client_scheduled' from NextAllowedStatuses if no pickup form exists
-	if s.Status == models.ShipmentStatusPendingPickup {
-		var pickupFormCount int
-		err := h.DB.QueryRowContext(r.Context(),
-			`SELECT COUNT(*) FROM pickup_forms WHERE shipment_id = $1`,
-			shipmentID,
-		).Scan(&pickupFormCount)
-		if err == nil {
-			if pickupFormCount == 0 {
-				// Filter out pickup_from_client_scheduled from the list
-				filteredStatuses := []models.ShipmentStatus{}
-				for _, status := range nextAllowedStatuses {
-					if status != models.ShipmentStatusPickupScheduled {
-						filteredStatuses = append(filteredStatuses, status)
-					}
-				}
-				nextAllowedStatuses = filteredStatuses
-				
-				// Show warning message if pickup_from_client_scheduled would have been available
-				if warningMsg == "" {
-					originalNextAllowedStatuses := s.GetNextAllowedStatuses()
-					for _, status := range originalNextAllowedStatuses {
-						if status == models.ShipmentStatusPickupScheduled {
-							warningMsg = "⚠️ A completed 'Complete Shipment Details' form is required before updating the status to 'Pickup from Client Scheduled'. Please ensure the shipment details form is completed first."
-							break
-						}
-					}
-				}
-			}
-		}
+	// Load the status history and build the timeline from it
+	statusEvents, err := models.GetShipmentStatusEvents(r.Context(), h.DB, s.ID)
+	if err != nil {
+		// Non-critical error, the timeline falls back to the milestone timestamps
+		fmt.Printf("Warning: Failed to load shipment status history: %v\n", err)
+		statusEvents = nil
 	}
+	timeline := models.BuildTimeline(&s, statusEvents)
 
-	// Check if approved reception report is required for single_full_journey shipments at at_warehouse status
-	// Filter out 'released_from_warehouse' from NextAllowedStatuses if no approved reception report exists
-	if s.ShipmentType == models.ShipmentTypeSingleFullJourney && s.Status == models.ShipmentStatusAtWarehouse {
-		// Get the laptop ID for this shipment
-		var laptopID int64
-		err := h.DB.QueryRowContext(r.Context(),
-			`SELECT laptop_id FROM shipment_laptops WHERE shipment_id = $1 LIMIT 1`,
-			shipmentID,
-		).Scan(&laptopID)
-		if err == nil {
-			// Check if there's an approved reception report for this laptop
-			receptionReport, err := models.GetLaptopReceptionReport(r.Context(), h.DB, laptopID)
-			if err == nil {
-				if receptionReport == nil || !receptionReport.IsApproved() {
-					// Filter out released_from_warehouse from the list
-					filteredStatuses := []models.ShipmentStatus{}
-					for _, status := range nextAllowedStatuses {
-						if status != models.ShipmentStatusReleasedFromWarehouse {
-							filteredStatuses = append(filteredStatuses, status)
-						}
-					}
-					nextAllowedStatuses = filteredStatuses
-					
-					// Show warning message if released_from_warehouse would have been available
-					if warningMsg == "" {
-						originalNextAllowedStatuses := s.GetNextAllowedStatuses()
-						for _, status := range originalNextAllowedStatuses {
-							if status == models.ShipmentStatusReleasedFromWarehouse {
-								warningMsg = "⚠️ An approved reception report is required before updating the status to 'Released from Warehouse'. Please ensure the reception report is created and approved first."
-								break
-							}
-						}
-					}
-				}
-			}
+	// Load the packages the shipment travels in, each with its own tracking number
+	packages, err := models.GetShipmentPackages(r.Context(), h.DB, s.ID)
+	if err != nil {
+		// Non-critical error, log but continue
+		fmt.Printf("Warning: Failed to load shipment packages: %v\n", err)
+		packages = []models.ShipmentPackage{}
+	}
+
+	// Load the courier checkpoints received for the shipment
+	trackingEvents, err := models.GetShipmentTrackingEvents(r.Context(), h.DB, s.ID)
+	if err != nil {
+		// Non-critical error, log but continue
+		fmt.Printf("Warning: Failed to load courier tracking events: %v\n", err)
+		trackingEvents = []models.ShipmentTrackingEvent{}
+	}
+
+	// Get next allowed statuses from the shipment's workflow
+	// Transitions blocked by a workflow guard (e.g. no engineer assigned) are filtered out
+	nextAllowedStatuses, guardWarning, err := workflow.NewEngine(h.DB, h.EmailNotifier).AvailableTransitions(r.Context(), &s)
+	if err != nil {
+		// Non-critical error, log but continue
+		fmt.Printf("Warning: Failed to evaluate workflow transitions: %v\n", err)
+		nextAllowedStatuses = []models.ShipmentStatus{}
+	}
+	if warningMsg == "" {
+		warningMsg = guardWarning
+	}
+
+	// Exception statuses are offered separately from the normal flow
+	statusBeforeException := ""
+	if s.StatusBeforeException != nil {
+		statusBeforeException = string(*s.StatusBeforeException)
+	}
+	exceptionStatuses := []models.ShipmentStatus{}
+	flowStatuses := []models.ShipmentStatus{}
+	for _, status := range nextAllowedStatuses {
+		if models.IsExceptionStatus(status) {
+			exceptionStatuses = append(exceptionStatuses, status)
+		} else {
+			flowStatuses = append(flowStatuses, status)
 		}
 	}
 
-	// Get available laptops for bulk shipments (only for logistics users)
+	// Get available laptops for bulk shipments (only for users who can add them)
 	var availableLaptops []models.Laptop
-	if s.ShipmentType == models.ShipmentTypeBulkToWarehouse && user.Role == models.RoleLogistics {
+	if s.ShipmentType == models.ShipmentTypeBulkToWarehouse && permissions.Can(user, permissions.ShipmentAssignEngineer) {
 		availableLaptops, err = h.GetAvailableLaptopsForBulkShipment(r.Context(), s.ClientCompanyID)
 		if err != nil {
 			// Non-critical error, log but continue
@@ -504,9 +500,9 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	// Get list of client companies (for magic link form - logistics users only)
+	// Get list of client companies (for magic link form)
 	companies := []models.ClientCompany{}
-	if user.Role == models.RoleLogistics {
+	if permissions.Can(user, permissions.MagicLinkManage) {
 		companyRows, err := h.DB.QueryContext(r.Context(),
 			`SELECT id, name, contact_info, created_at FROM client_companies ORDER BY name`,
 		)
@@ -521,14 +517,10 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	// Get list of couriers (for courier dropdown - logistics users only)
-	couriers := []models.Courier{}
-	if user.Role == models.RoleLogistics {
-		couriers, err = models.GetAllCouriers(h.DB)
-		if err != nil {
-			// Non-critical error, log but continue
-			fmt.Printf("Warning: Failed to load couriers: %v\n", err)
-		}
+	// Couriers and their service levels feed the courier dropdowns (shipment editors only)
+	courierOptions := models.CourierDirectory{}
+	if permissions.Can(user, permissions.ShipmentEdit) {
+		courierOptions = couriers
 	}
 
 	data := map[string]interface{}{
@@ -554,9 +546,18 @@ func (h *ShipmentsHandler) ShipmentDetail(w http.ResponseWriter, r *http.Request
 		"DeliveryForm":          deliveryForm,
 		"Engineers":             engineers,
 		"Timeline":              timeline,
-		"NextAllowedStatuses":   nextAllowedStatuses,
+		"StatusHistory":         statusEvents,
+		"Packages":              packages,
+		"NewPackage":            models.ShipmentPackage{},
+		"TrackingEvents":        trackingEvents,
+		"TrackingEnabled":       h.Tracking != nil && !h.Tracking.Registry.Empty(),
+		"NextAllowedStatuses":   flowStatuses,
+		"ExceptionStatuses":     exceptionStatuses,
+		"IsInException":         s.IsInException(),
+		"StatusBeforeException": statusBeforeException,
 		"Companies":             companies,
-		"Couriers":              couriers,
+		"Couriers":              courierOptions,
+		"CSRFToken":             middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -587,9 +588,7 @@ func (h *ShipmentsHandler) UpdateShipmentStatus(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Only logistics users can manually update status
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentUpdateStatus) {
 		return
 	}
 
@@ -613,93 +612,6 @@ func (h *ShipmentsHandler) UpdateShipmentStatus(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	// Get current shipment to validate sequential transition
-	var currentShipment models.Shipment
-	err = h.DB.QueryRowContext(r.Context(),
-		`SELECT id, status, shipment_type, laptop_count FROM shipments WHERE id = $1`,
-		shipmentID,
-	).Scan(&currentShipment.ID, &currentShipment.Status, &currentShipment.ShipmentType, &currentShipment.LaptopCount)
-	if err != nil {
-		http.Error(w, "Failed to fetch current shipment", http.StatusInternalServerError)
-		return
-	}
-
-	// Validate that the status transition is sequential (no skipping or going backwards)
-	if !currentShipment.IsValidStatusTransition(newStatus) {
-		http.Error(w, "Invalid status transition. Status updates must be sequential and cannot skip stages or go backwards.", http.StatusBadRequest)
-		return
-	}
-
-	// Validate that engineer is assigned before updating to in_transit_to_engineer for single_full_journey and warehouse_to_engineer shipments
-	if newStatus == models.ShipmentStatusInTransitToEngineer {
-		if currentShipment.ShipmentType == models.ShipmentTypeSingleFullJourney || 
-		   currentShipment.ShipmentType == models.ShipmentTypeWarehouseToEngineer {
-			var softwareEngineerID sql.NullInt64
-			err = h.DB.QueryRowContext(r.Context(),
-				`SELECT software_engineer_id FROM shipments WHERE id = $1`,
-				shipmentID,
-			).Scan(&softwareEngineerID)
-			if err != nil {
-				http.Error(w, "Failed to fetch shipment engineer information", http.StatusInternalServerError)
-				return
-			}
-			if !softwareEngineerID.Valid {
-				http.Error(w, "Cannot update status to 'in transit to engineer' without an assigned engineer. Please assign an engineer first.", http.StatusBadRequest)
-				return
-			}
-		}
-	}
-
-	// Validate that pickup form (Complete Shipment Details) exists before updating to pickup_from_client_scheduled
-	// This applies when transitioning from pending_pickup_from_client to pickup_from_client_scheduled
-	if newStatus == models.ShipmentStatusPickupScheduled {
-		var pickupFormCount int
-		err = h.DB.QueryRowContext(r.Context(),
-			`SELECT COUNT(*) FROM pickup_forms WHERE shipment_id = $1`,
-			shipmentID,
-		).Scan(&pickupFormCount)
-		if err != nil {
-			http.Error(w, "Failed to check pickup form status", http.StatusInternalServerError)
-			return
-		}
-		if pickupFormCount == 0 {
-			http.Error(w, "Cannot update status to 'Pickup from Client Scheduled' without a completed 'Complete Shipment Details' form. Please ensure the shipment details form is completed first.", http.StatusBadRequest)
-			return
-		}
-	}
-
-	// Validate that approved reception report exists before updating from at_warehouse to released_from_warehouse
-	// Only for single_full_journey shipments
-	if currentShipment.Status == models.ShipmentStatusAtWarehouse && 
-	   newStatus == models.ShipmentStatusReleasedFromWarehouse &&
-	   currentShipment.ShipmentType == models.ShipmentTypeSingleFullJourney {
-		// Get the laptop ID for this shipment
-		var laptopID int64
-		err = h.DB.QueryRowContext(r.Context(),
-			`SELECT laptop_id FROM shipment_laptops WHERE shipment_id = $1 LIMIT 1`,
-			shipmentID,
-		).Scan(&laptopID)
-		if err != nil {
-			if err == sql.ErrNoRows {
-				http.Error(w, "Shipment has no laptops associated", http.StatusBadRequest)
-				return
-			}
-			http.Error(w, "Failed to fetch shipment laptop information", http.StatusInternalServerError)
-			return
-		}
-
-		// Check if there's an approved reception report for this laptop
-		receptionReport, err := models.GetLaptopReceptionReport(r.Context(), h.DB, laptopID)
-		if err != nil {
-			http.Error(w, "Failed to check reception report status", http.StatusInternalServerError)
-			return
-		}
-		if receptionReport == nil || !receptionReport.IsApproved() {
-			http.Error(w, "Cannot update status to 'Released from Warehouse' without an approved reception report for the laptop. Please ensure the reception report is created and approved first.", http.StatusBadRequest)
-			return
-		}
-	}
-
 	// Parse ETA if provided (for in_transit_to_engineer status)
 	var eta *time.Time
 	etaString := r.FormValue("eta_to_engineer")
@@ -712,232 +624,52 @@ func (h *ShipmentsHandler) UpdateShipmentStatus(w http.ResponseWriter, r *http.R
 		eta = &parsedETA
 	}
 
-	// Parse tracking number if provided (required for pickup_from_client_scheduled status)
-	trackingNumber := strings.TrimSpace(r.FormValue("tracking_number"))
-	if newStatus == models.ShipmentStatusPickupScheduled && trackingNumber == "" {
-		http.Error(w, "Tracking number is required when scheduling pickup from client", http.StatusBadRequest)
-		return
-	}
-
-	// Parse courier name if provided (required for pickup_from_client_scheduled status)
-	courierName := strings.TrimSpace(r.FormValue("courier_name"))
-	if newStatus == models.ShipmentStatusPickupScheduled {
-		if courierName == "" {
-			http.Error(w, "Courier name is required when scheduling pickup from client", http.StatusBadRequest)
-			return
-		}
-		// Check if courier exists in database or is a valid hardcoded value
-		valid, err := models.IsValidCourierName(h.DB, courierName)
-		if err != nil {
-			http.Error(w, "Failed to validate courier name", http.StatusInternalServerError)
-			return
-		}
-		if !valid {
-			http.Error(w, "Invalid courier name. Courier must exist in the system", http.StatusBadRequest)
-			return
-		}
-	}
-
-	// Store old status for notification check
-	oldStatus := string(currentShipment.Status)
-
-	// Update shipment status
-	var shipment models.Shipment
-	shipment.Status = newStatus
-	shipment.UpdateStatusWithETA(newStatus, eta)
-
-	// Set tracking number if provided
-	if trackingNumber != "" {
-		shipment.TrackingNumber = trackingNumber
-	}
-
-	// Set courier name if provided
-	if courierName != "" {
-		shipment.CourierName = courierName
-	}
-
-	_, err = h.DB.ExecContext(r.Context(),
-		`UPDATE shipments 
-		SET status = $1, updated_at = $2,
-		    picked_up_at = COALESCE($3, picked_up_at),
-		    arrived_warehouse_at = COALESCE($4, arrived_warehouse_at),
-		    released_warehouse_at = COALESCE($5, released_warehouse_at),
-		    delivered_at = COALESCE($6, delivered_at),
-		    pickup_scheduled_date = COALESCE($7, pickup_scheduled_date),
-		    eta_to_engineer = COALESCE($8, eta_to_engineer),
-		    tracking_number = CASE WHEN $9 != '' THEN $9 ELSE tracking_number END,
-		    courier_name = CASE WHEN $10 != '' THEN $10 ELSE courier_name END
-		WHERE id = $11`,
-		shipment.Status, shipment.UpdatedAt,
-		shipment.PickedUpAt, shipment.ArrivedWarehouseAt,
-		shipment.ReleasedWarehouseAt, shipment.DeliveredAt,
-		shipment.PickupScheduledDate, shipment.ETAToEngineer,
-		trackingNumber,
-		courierName,
-		shipmentID,
-	)
+	// Run the transition through the workflow engine
+	// Guards (engineer assigned, pickup form, reception report, tracking number, courier)
+	// and side effects (timestamps, laptop sync, notifications) come from the workflow definition
+	result, err := workflow.NewEngine(h.DB, h.EmailNotifier).Transition(r.Context(), shipmentID, newStatus, workflow.TransitionInput{
+		TrackingNumber: strings.TrimSpace(r.FormValue("tracking_number")),
+		CourierName:    strings.TrimSpace(r.FormValue("courier_name")),
+		ETA:            eta,
+		Reason:         strings.TrimSpace(r.FormValue("exception_reason")),
+		ActorUserID:    &user.ID,
+		Source:         models.StatusEventSourceUI,
+		Comment:        strings.TrimSpace(r.FormValue("status_comment")),
+	})
 	if err != nil {
-		http.Error(w, "Failed to update shipment status", http.StatusInternalServerError)
-		return
-	}
-
-	// For single_full_journey shipments, when status changes to at_warehouse,
-	// also update the laptop status to at_warehouse (Received at Warehouse)
-	if currentShipment.ShipmentType == models.ShipmentTypeSingleFullJourney && newStatus == models.ShipmentStatusAtWarehouse {
-		_, err = h.DB.ExecContext(r.Context(),
-			`UPDATE laptops l
-			SET status = $1, updated_at = $2
-			FROM shipment_laptops sl
-			WHERE sl.laptop_id = l.id
-			AND sl.shipment_id = $3`,
-			models.LaptopStatusAtWarehouse, time.Now(), shipmentID,
-		)
-		if err != nil {
-			fmt.Printf("Error updating laptop status for single shipment: %v\n", err)
-			// Non-critical error - log but don't fail the request
-		}
-	}
-
-	// Send email notification if status changed to pickup_scheduled
-	notificationSent := false
-	if oldStatus == string(models.ShipmentStatusPendingPickup) && newStatus == models.ShipmentStatusPickupScheduled {
-		if h.EmailNotifier != nil {
-			// Check if pickup form exists before sending notification
-			var pickupFormExists bool
-			err := h.DB.QueryRowContext(r.Context(),
-				`SELECT EXISTS(SELECT 1 FROM pickup_forms WHERE shipment_id = $1)`,
-				shipmentID,
-			).Scan(&pickupFormExists)
-			
-			if err == nil && pickupFormExists {
-				go func() {
-					// Use a fresh context for the background goroutine
-					// r.Context() gets canceled when the HTTP response is sent
-					ctx := context.Background()
-					if err := h.EmailNotifier.SendPickupScheduledNotification(ctx, shipmentID); err != nil {
-						fmt.Printf("Warning: failed to send pickup scheduled notification: %v\n", err)
-					} else {
-						fmt.Printf("Pickup scheduled notification sent successfully for shipment %d\n", shipmentID)
-					}
-				}()
-				notificationSent = true
-			} else if err != nil {
-				fmt.Printf("Warning: failed to check for pickup form: %v\n", err)
-			}
-		}
-	}
-
-	// Send warehouse pre-alert email when status changes to picked_up_from_client
-	if newStatus == models.ShipmentStatusPickedUpFromClient {
-		if h.EmailNotifier != nil {
-			go func() {
-				ctx := context.Background()
-				if err := h.EmailNotifier.SendWarehousePreAlert(ctx, shipmentID); err != nil {
-					fmt.Printf("Warning: failed to send warehouse pre-alert: %v\n", err)
-				} else {
-					fmt.Printf("Warehouse pre-alert sent successfully for shipment %d\n", shipmentID)
-				}
-			}()
-			
-			// Also send notification to client that shipment was picked up
-			go func() {
-				ctx := context.Background()
-				if err := h.EmailNotifier.SendShipmentPickedUpNotification(ctx, shipmentID); err != nil {
-					fmt.Printf("Warning: failed to send shipment picked up notification: %v\n", err)
-				} else {
-					fmt.Printf("Shipment picked up notification sent successfully for shipment %d\n", shipmentID)
-				}
-			}()
-		}
-	}
-
-	// Send release notification email when status changes to released_from_warehouse
-	if newStatus == models.ShipmentStatusReleasedFromWarehouse {
-		if h.EmailNotifier != nil {
-			go func() {
-				ctx := context.Background()
-				if err := h.EmailNotifier.SendReleaseNotification(ctx, shipmentID); err != nil {
-					fmt.Printf("Warning: failed to send release notification: %v\n", err)
-				} else {
-					fmt.Printf("Release notification sent successfully for shipment %d\n", shipmentID)
-				}
-			}()
-		}
-	}
-
-	// Send in transit to engineer notification when status changes to in_transit_to_engineer
-	// Only for shipment types that involve engineer delivery
-	if newStatus == models.ShipmentStatusInTransitToEngineer {
-		if h.EmailNotifier != nil {
-			// Use the shipment type we already fetched earlier (more efficient and avoids potential query issues)
-			if currentShipment.ShipmentType == models.ShipmentTypeSingleFullJourney ||
-				currentShipment.ShipmentType == models.ShipmentTypeWarehouseToEngineer {
-				go func() {
-					ctx := context.Background()
-					if err := h.EmailNotifier.SendInTransitToEngineerNotification(ctx, shipmentID); err != nil {
-						fmt.Printf("Warning: failed to send in transit to engineer notification: %v\n", err)
-					} else {
-						fmt.Printf("In transit to engineer notification sent successfully for shipment %d\n", shipmentID)
-					}
-				}()
-			} else {
-				fmt.Printf("Info: in transit to engineer notification skipped for shipment type: %s (shipment ID: %d)\n", currentShipment.ShipmentType, shipmentID)
-			}
-		} else {
-			fmt.Printf("Warning: EmailNotifier is nil, cannot send in transit to engineer notification for shipment %d\n", shipmentID)
-		}
-	}
-
-	// Send delivery confirmation email when status changes to delivered
-	// Only for shipment types that involve engineer delivery
-	if newStatus == models.ShipmentStatusDelivered {
-		if h.EmailNotifier != nil {
-			// Check shipment type to ensure we only send for applicable types
-			var shipmentType models.ShipmentType
-			err := h.DB.QueryRowContext(r.Context(),
-				`SELECT shipment_type FROM shipments WHERE id = $1`,
-				shipmentID,
-			).Scan(&shipmentType)
-
-			if err == nil && (shipmentType == models.ShipmentTypeSingleFullJourney ||
-				shipmentType == models.ShipmentTypeWarehouseToEngineer) {
-				go func() {
-					ctx := context.Background()
-					if err := h.EmailNotifier.SendDeliveryConfirmation(ctx, shipmentID); err != nil {
-						fmt.Printf("Warning: failed to send delivery confirmation: %v\n", err)
-					} else {
-						fmt.Printf("Delivery confirmation sent successfully for shipment %d\n", shipmentID)
-					}
-				}()
-				
-				// Also send notification to client that device was delivered to engineer
-				go func() {
-					ctx := context.Background()
-					if err := h.EmailNotifier.SendEngineerDeliveryNotificationToClient(ctx, shipmentID); err != nil {
-						fmt.Printf("Warning: failed to send engineer delivery notification to client: %v\n", err)
-					} else {
-						fmt.Printf("Engineer delivery notification to client sent successfully for shipment %d\n", shipmentID)
-					}
-				}()
-			}
+		var guardErr *workflow.GuardError
+		switch {
+		case errors.As(err, &guardErr):
+			http.Error(w, guardErr.Message, http.StatusBadRequest)
+		case errors.Is(err, workflow.ErrInvalidTransition):
+			http.Error(w, "Invalid status transition. Status updates must be sequential and cannot skip stages or go backwards.", http.StatusBadRequest)
+		case errors.Is(err, workflow.ErrShipmentNotFound):
+			http.Error(w, "Shipment not found", http.StatusNotFound)
+		case errors.Is(err, workflow.ErrConcurrentUpdate):
+			http.Error(w, "Shipment status was changed by someone else. Please reload the page and try again.", http.StatusConflict)
+		default:
+			fmt.Printf("Error updating shipment status: %v\n", err)
+			http.Error(w, "Failed to update shipment status", http.StatusInternalServerError)
 		}
+		return
 	}
+	notificationSent := result.Notified(models.WorkflowEffectNotifyPickupScheduled)
 
 	// Create audit log
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":     "status_updated",
+	details := map[string]interface{}{
+		"old_status": result.From,
 		"new_status": newStatus,
-	})
-
-	_, err = h.DB.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "status_updated", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error
-		fmt.Printf("Failed to create audit log: %v\n", err)
 	}
+	if models.IsExceptionStatus(newStatus) {
+		details["exception_reason"] = result.Shipment.ExceptionReason
+	}
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "status_updated",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Changes:    []models.AuditChange{{Field: "status", Before: result.From, After: newStatus}},
+		Details:    details,
+	})
 
 	// Redirect back to shipment detail with appropriate message
 	var redirectURL string
@@ -963,9 +695,7 @@ func (h *ShipmentsHandler) AssignEngineer(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Only logistics users can assign engineers
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentAssignEngineer) {
 		return
 	}
 
@@ -1037,22 +767,16 @@ func (h *ShipmentsHandler) AssignEngineer(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	// Create audit log
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":      "engineer_assigned",
-		"engineer_id": engineerID,
+	// Create audit log entry
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "engineer_assigned",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"engineer_id": engineerID,
+		},
 	})
 
-	_, err = h.DB.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "engineer_assigned", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error
-		fmt.Printf("Failed to create audit log: %v\n", err)
-	}
-
 	// Redirect back to shipment detail
 	redirectURL := fmt.Sprintf("/shipments/%d?success=Engineer+assigned+successfully", shipmentID)
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
@@ -1067,9 +791,7 @@ func (h *ShipmentsHandler) CreateShipment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Only logistics users can create shipments
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentCreate) {
 		return
 	}
 
@@ -1106,6 +828,7 @@ func (h *ShipmentsHandler) CreateShipment(w http.ResponseWriter, r *http.Request
 			"Nav":         views.GetNavigationLinks(user.Role),
 			"CurrentPage": "shipments",
 			"Companies":   companies,
+			"CSRFToken":   middleware.GetCSRFToken(r.Context()),
 		}
 
 		err = h.Templates.ExecuteTemplate(w, "create-shipment.html", data)
@@ -1161,6 +884,16 @@ func (h *ShipmentsHandler) CreateShipment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// The status must be part of the active workflow for the shipment type
+	if ok, err := shipment.IsValidStatusForType(r.Context(), h.DB, shipment.Status); err != nil {
+		log.Printf("Error loading workflow: %v", err)
+		http.Error(w, "Failed to load workflow", http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.Error(w, fmt.Sprintf("status %s is not valid for shipment type %s", shipment.Status, shipment.ShipmentType), http.StatusBadRequest)
+		return
+	}
+
 	// Validate JIRA ticket exists (if validator is configured)
 	if err := models.ValidateJiraTicketExists(jiraTicketNumber, h.JiraValidator); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
@@ -1185,23 +918,29 @@ func (h *ShipmentsHandler) CreateShipment(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Create audit log
-	auditDetails, _ := json.Marshal(map[string]interface{}{
-		"action":             "shipment_created",
-		"jira_ticket_number": jiraTicketNumber,
-		"client_company_id":  clientCompanyID,
-	})
-
-	_, err = h.DB.ExecContext(r.Context(),
-		`INSERT INTO audit_logs (user_id, action, entity_type, entity_id, timestamp, details)
-		VALUES ($1, $2, $3, $4, $5, $6)`,
-		user.ID, "shipment_created", "shipment", shipmentID, time.Now(), auditDetails,
-	)
-	if err != nil {
-		// Non-critical error
-		fmt.Printf("Failed to create audit log: %v\n", err)
+	// Start the shipment's status history
+	if err := models.RecordShipmentStatusEvent(r.Context(), h.DB, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		ToStatus:    shipment.Status,
+		ActorUserID: &user.ID,
+		Source:      models.StatusEventSourceUI,
+		OccurredAt:  shipment.CreatedAt,
+	}); err != nil {
+		// Non-critical error, just log it
+		fmt.Printf("Warning: Failed to record shipment status event: %v\n", err)
 	}
 
+	// Create audit log entry
+	audit.Log(r.Context(), h.DB, audit.UserActor(user), audit.Entry{
+		Action:     "shipment_created",
+		EntityType: audit.EntityShipment,
+		EntityID:   shipmentID,
+		Details: map[string]interface{}{
+			"jira_ticket_number": jiraTicketNumber,
+			"client_company_id":  clientCompanyID,
+		},
+	})
+
 	// Redirect to shipment detail page
 	redirectURL := fmt.Sprintf("/shipments/%d?success=Shipment+created+successfully", shipmentID)
 	http.Redirect(w, r, redirectURL, http.StatusSeeOther)
@@ -1289,6 +1028,7 @@ func (h *ShipmentsHandler) ShipmentPickupFormPage(w http.ResponseWriter, r *http
 		"PickupFormData": pickupFormData,
 		"IsEdit":         pickupFormData != nil,
 		"TimeSlots":      []string{"morning", "afternoon", "evening"},
+		"CSRFToken":      middleware.GetCSRFToken(r.Context()),
 	}
 
 	if h.Templates != nil {
@@ -1479,11 +1219,21 @@ func (h *ShipmentsHandler) ShipmentPickupFormSubmit(w http.ResponseWriter, r *ht
 		return
 	}
 
+	// Create one package per declared box if the shipment has none yet
+	if err := models.EnsureShipmentPackages(r.Context(), h.DB, shipmentID, formInput.NumberOfBoxes, models.ShipmentPackage{
+		LengthIn: formInput.BulkLength,
+		WidthIn:  formInput.BulkWidth,
+		HeightIn: formInput.BulkHeight,
+		WeightLb: formInput.BulkWeight,
+	}); err != nil {
+		// Non-critical, log and continue
+		fmt.Printf("Warning: Failed to create shipment packages: %v\n", err)
+	}
+
 	// Mark magic link as used (if accessed via magic link)
-	magicLinkToken, err := auth.GetMagicLinkByShipmentAndUser(r.Context(), h.DB, shipmentID, user.ID)
-	if err == nil && magicLinkToken != "" {
-		_ = auth.MarkMagicLinkAsUsed(r.Context(), h.DB, magicLinkToken)
+	if err := auth.MarkShipmentMagicLinkAsUsed(r.Context(), h.DB, shipmentID, user.ID); err != nil {
 		// Log error but don't fail the request if marking as used fails
+		fmt.Printf("Warning: Failed to mark magic link as used: %v\n", err)
 	}
 
 	// Redirect to shipment detail page with success message
@@ -1550,9 +1300,7 @@ func (h *ShipmentsHandler) AddLaptopToBulkShipment(w http.ResponseWriter, r *htt
 		return
 	}
 
-	// Only logistics users can add laptops to bulk shipments
-	if user.Role != models.RoleLogistics {
-		http.Error(w, "Forbidden: Only logistics users can add laptops to bulk shipments", http.StatusForbidden)
+	if !middleware.Authorize(w, r, permissions.ShipmentAssignEngineer) {
 		return
 	}
 
@@ -0,0 +1,435 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// twoFactorCookieName is the cookie identifying a login waiting for its second factor
+const twoFactorCookieName = "two_factor_challenge"
+
+// requiresTwoFactor reports whether the user must enter a TOTP code after the password
+func (h *AuthHandler) requiresTwoFactor(user *models.User) bool {
+	return user.TwoFactorEnabled || h.TwoFactorRequiredRoles[user.Role]
+}
+
+// completeLogin finishes a login with a password, a magic link or an identity provider. Users with
+// two-factor authentication (or whose role requires it) are sent to /login/2fa first; the session
+// is only created once the code is checked.
+func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user *models.User, redirectURL string) {
+	if !h.requiresTwoFactor(user) {
+		if err := h.startSession(w, r, user.ID); err != nil {
+			http.Error(w, "Failed to create session", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+		return
+	}
+
+	token, err := auth.CreateTwoFactorChallenge(r.Context(), h.DB, user.ID, redirectURL)
+	if err != nil {
+		log.Printf("Error creating two-factor challenge: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    token,
+		Path:     "/login/2fa",
+		MaxAge:   int(auth.TwoFactorChallengeDuration / time.Second),
+		HttpOnly: true,
+		Secure:   isProduction(),
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+}
+
+// startSession creates a session for the user, recording the client it was signed in from, and sets the session cookie
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, userID int64) error {
+	session, err := auth.CreateSessionForClient(r.Context(), h.DB, userID, auth.DefaultSessionDuration,
+		auth.ClientIP(r, h.TrustProxyHeaders), r.UserAgent())
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.SessionCookieName,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   isProduction(), // Only require HTTPS in production
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// clearTwoFactorCookie removes the challenge cookie once the login completed or failed
+func clearTwoFactorCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     twoFactorCookieName,
+		Value:    "",
+		Path:     "/login/2fa",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isProduction(),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// loadTwoFactorChallenge returns the pending login of the request and its user.
+// Returns false if a response was written (no pending login, or it expired).
+func (h *AuthHandler) loadTwoFactorChallenge(w http.ResponseWriter, r *http.Request) (*auth.TwoFactorChallenge, *models.User, bool) {
+	var token string
+	if cookie, err := r.Cookie(twoFactorCookieName); err == nil {
+		token = cookie.Value
+	}
+
+	challenge, err := auth.GetTwoFactorChallenge(r.Context(), h.DB, token)
+	if err != nil {
+		log.Printf("Error getting two-factor challenge: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	if challenge == nil {
+		clearTwoFactorCookie(w)
+		http.Redirect(w, r, "/login?error="+url.QueryEscape("Your sign-in expired. Please enter your password again."), http.StatusSeeOther)
+		return nil, nil, false
+	}
+
+	user, err := models.GetUserByID(h.DB, challenge.UserID)
+	if err != nil {
+		log.Printf("Error getting user: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return challenge, user, true
+}
+
+// TwoFactorPage asks for the TOTP code of a login, or shows the enrollment QR code
+// to users whose role requires two-factor authentication but who have not set it up yet
+func (h *AuthHandler) TwoFactorPage(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := h.loadTwoFactorChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	data := map[string]interface{}{
+		"Error":     r.URL.Query().Get("error"),
+		"CSRFToken": middleware.GetCSRFToken(r.Context()),
+	}
+	if user.TwoFactorEnabled {
+		data["Mode"] = "verify"
+	} else {
+		if !h.addEnrollment(w, r, user, data) {
+			return
+		}
+		data["Mode"] = "setup"
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "login-2fa.html", data); err != nil {
+		log.Printf("Error executing two-factor template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// TwoFactorSubmit checks the code of a login and creates the session.
+// During a forced enrollment the code confirms the new authenticator and the recovery codes are shown once.
+func (h *AuthHandler) TwoFactorSubmit(w http.ResponseWriter, r *http.Request) {
+	challenge, user, ok := h.loadTwoFactorChallenge(w, r)
+	if !ok {
+		return
+	}
+
+	code := r.FormValue("code")
+	var recoveryCodes []string
+	var usedRecoveryCode bool
+	var err error
+	if user.TwoFactorEnabled {
+		ok, usedRecoveryCode, err = h.checkSecondFactor(r, user.ID, code)
+	} else {
+		recoveryCodes, ok, err = auth.ConfirmTOTPEnrollment(r.Context(), h.DB, user.ID, code)
+	}
+	if err != nil {
+		log.Printf("Error checking two-factor code: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if !ok {
+		h.recordLoginFailure(r, auth.LoginAttempt{
+			Method:    auth.LoginMethodPassword,
+			Email:     user.Email,
+			UserID:    &user.ID,
+			IPAddress: auth.ClientIP(r, h.TrustProxyHeaders),
+			UserAgent: r.UserAgent(),
+		}, auth.LoginFailureInvalidTOTP)
+
+		remaining, err := auth.FailTwoFactorChallenge(r.Context(), h.DB, challenge.ID)
+		if err != nil {
+			log.Printf("Error counting two-factor attempt: %v", err)
+		}
+		if remaining == 0 {
+			clearTwoFactorCookie(w)
+			http.Redirect(w, r, "/login?error="+url.QueryEscape("Too many wrong codes. Please sign in again."), http.StatusSeeOther)
+			return
+		}
+		message := fmt.Sprintf("Invalid code. %d attempt(s) left.", remaining)
+		http.Redirect(w, r, "/login/2fa?error="+url.QueryEscape(message), http.StatusSeeOther)
+		return
+	}
+
+	if err := auth.DeleteTwoFactorChallenge(r.Context(), h.DB, challenge.ID); err != nil {
+		log.Printf("Error deleting two-factor challenge: %v", err)
+	}
+	clearTwoFactorCookie(w)
+
+	if err := h.startSession(w, r, user.ID); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	if usedRecoveryCode {
+		h.logAudit(r, user.ID, "two_factor_recovery_code_used", nil)
+	}
+	if recoveryCodes == nil {
+		http.Redirect(w, r, challenge.RedirectURL, http.StatusSeeOther)
+		return
+	}
+
+	// Forced enrollment: show the recovery codes once before continuing
+	h.logAudit(r, user.ID, "two_factor_enabled", nil)
+	data := map[string]interface{}{
+		"Mode":          "recovery_codes",
+		"RecoveryCodes": recoveryCodes,
+		"ContinueURL":   challenge.RedirectURL,
+		"CSRFToken":     middleware.GetCSRFToken(r.Context()),
+	}
+	if err := h.Templates.ExecuteTemplate(w, "login-2fa.html", data); err != nil {
+		log.Printf("Error executing two-factor template: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
+
+// checkSecondFactor accepts a code from the authenticator app or an unused recovery code
+func (h *AuthHandler) checkSecondFactor(r *http.Request, userID int64, code string) (ok bool, usedRecoveryCode bool, err error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if code == "" {
+		return false, false, nil
+	}
+	if isTOTPCode(code) {
+		ok, err = auth.VerifyTOTP(r.Context(), h.DB, userID, code)
+		return ok, false, err
+	}
+	ok, err = auth.UseRecoveryCode(r.Context(), h.DB, userID, code)
+	return ok, ok, err
+}
+
+// isTOTPCode reports whether the code looks like an authenticator code rather than a recovery code
+func isTOTPCode(code string) bool {
+	if len(code) != auth.TOTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// addEnrollment adds the pending secret and its QR code to the page data.
+// Returns false if a response was written.
+func (h *AuthHandler) addEnrollment(w http.ResponseWriter, r *http.Request, user *models.User, data map[string]interface{}) bool {
+	secret, err := auth.StartTOTPEnrollment(r.Context(), h.DB, user.ID)
+	if err != nil {
+		log.Printf("Error starting TOTP enrollment: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	qrCode, err := qrCodeDataURI(auth.TOTPURI(h.TwoFactorIssuer, user.Email, secret))
+	if err != nil {
+		log.Printf("Error generating TOTP QR code: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+
+	data["Secret"] = secret
+	data["QRCode"] = qrCode
+	return true
+}
+
+// qrCodeDataURI renders text as a QR code PNG embedded in a data: URI
+func qrCodeDataURI(text string) (template.URL, error) {
+	bc, err := qr.Encode(text, qr.M, qr.Auto)
+	if err != nil {
+		return "", err
+	}
+	scaled, err := barcode.Scale(bc, 240, 240)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return "", err
+	}
+	// The data URI is generated here, never from user input
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
+
+// AccountTwoFactorPage lets users enable two-factor authentication, or manage it once enabled
+func (h *AuthHandler) AccountTwoFactorPage(w http.ResponseWriter, r *http.Request) {
+	h.renderAccountTwoFactor(w, r, nil)
+}
+
+// renderAccountTwoFactor renders the two-factor page. recoveryCodes are shown once after they are generated.
+func (h *AuthHandler) renderAccountTwoFactor(w http.ResponseWriter, r *http.Request, recoveryCodes []string) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	status, err := auth.GetTwoFactorStatus(r.Context(), h.DB, user.ID)
+	if err != nil {
+		log.Printf("Error getting two-factor status: %v", err)
+		http.Error(w, "Failed to load two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"User":          user,
+		"Nav":           views.GetNavigationLinks(user.Role),
+		"CurrentPage":   "two-factor",
+		"Status":        status,
+		"Required":      h.TwoFactorRequiredRoles[user.Role],
+		"RecoveryCodes": recoveryCodes,
+		"Success":       r.URL.Query().Get("success"),
+		"Error":         r.URL.Query().Get("error"),
+		"CSRFToken":     middleware.GetCSRFToken(r.Context()),
+	}
+	if !status.Enabled && !h.addEnrollment(w, r, user, data) {
+		return
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "account-two-factor.html", data); err != nil {
+		log.Printf("Error executing two-factor template: %v", err)
+		http.Error(w, "Failed to render two-factor page", http.StatusInternalServerError)
+	}
+}
+
+// AccountTwoFactorEnable confirms the authenticator app with a code and enables two-factor authentication
+func (h *AuthHandler) AccountTwoFactorEnable(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+
+	recoveryCodes, ok, err := auth.ConfirmTOTPEnrollment(r.Context(), h.DB, user.ID, r.FormValue("code"))
+	if errors.Is(err, auth.ErrTwoFactorEnabled) {
+		redirectAccountTwoFactor(w, r, "error", "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		log.Printf("Error enabling two-factor authentication: %v", err)
+		redirectAccountTwoFactor(w, r, "error", "Failed to enable two-factor authentication")
+		return
+	}
+	if !ok {
+		redirectAccountTwoFactor(w, r, "error", "Invalid code. Check the time on your phone and try again.")
+		return
+	}
+
+	h.logAudit(r, user.ID, "two_factor_enabled", nil)
+
+	// Render instead of redirecting so the recovery codes never appear in a URL
+	r.URL.RawQuery = "success=" + url.QueryEscape("Two-factor authentication enabled")
+	h.renderAccountTwoFactor(w, r, recoveryCodes)
+}
+
+// AccountTwoFactorRecoveryCodes replaces the recovery codes after checking a current code
+func (h *AuthHandler) AccountTwoFactorRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if !h.confirmSecondFactor(w, r, user) {
+		return
+	}
+
+	recoveryCodes, err := auth.RegenerateRecoveryCodes(r.Context(), h.DB, user.ID)
+	if err != nil {
+		log.Printf("Error regenerating recovery codes: %v", err)
+		redirectAccountTwoFactor(w, r, "error", "Failed to generate recovery codes")
+		return
+	}
+
+	h.logAudit(r, user.ID, "recovery_codes_regenerated", nil)
+
+	r.URL.RawQuery = "success=" + url.QueryEscape("New recovery codes generated. The old ones no longer work.")
+	h.renderAccountTwoFactor(w, r, recoveryCodes)
+}
+
+// AccountTwoFactorDisable turns two-factor authentication off after checking a current code.
+// Users whose role requires it cannot turn it off.
+func (h *AuthHandler) AccountTwoFactorDisable(w http.ResponseWriter, r *http.Request) {
+	user := middleware.GetUserFromContext(r.Context())
+	if h.TwoFactorRequiredRoles[user.Role] {
+		redirectAccountTwoFactor(w, r, "error", "Two-factor authentication is required for your role")
+		return
+	}
+	if !h.confirmSecondFactor(w, r, user) {
+		return
+	}
+
+	if err := auth.DisableTwoFactor(r.Context(), h.DB, user.ID); err != nil {
+		log.Printf("Error disabling two-factor authentication: %v", err)
+		redirectAccountTwoFactor(w, r, "error", "Failed to disable two-factor authentication")
+		return
+	}
+
+	h.logAudit(r, user.ID, "two_factor_disabled", nil)
+	redirectAccountTwoFactor(w, r, "success", "Two-factor authentication disabled")
+}
+
+// confirmSecondFactor checks the code submitted to change two-factor settings.
+// Returns false if a response was written.
+func (h *AuthHandler) confirmSecondFactor(w http.ResponseWriter, r *http.Request, user *models.User) bool {
+	ok, _, err := h.checkSecondFactor(r, user.ID, r.FormValue("code"))
+	if err != nil {
+		log.Printf("Error checking two-factor code: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return false
+	}
+	if !ok {
+		redirectAccountTwoFactor(w, r, "error", "Invalid code")
+		return false
+	}
+	return true
+}
+
+// redirectAccountTwoFactor redirects to the two-factor page with a success or error message
+func redirectAccountTwoFactor(w http.ResponseWriter, r *http.Request, kind, message string) {
+	http.Redirect(w, r, "/account/two-factor?"+kind+"="+url.QueryEscape(message), http.StatusSeeOther)
+}
+
+// logAudit records a change to the user's own authentication settings
+func (h *AuthHandler) logAudit(r *http.Request, userID int64, action string, details map[string]interface{}) {
+	audit.Log(r.Context(), h.DB, audit.Actor{UserID: &userID, IPAddress: auth.ClientIP(r, h.TrustProxyHeaders)}, audit.Entry{
+		Action:     action,
+		EntityType: audit.EntityUser,
+		EntityID:   userID,
+		Details:    details,
+	})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/yourusername/laptop-tracking-system/internal/auth"
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// createTwoFactorTestUser inserts a logistics user, the role the tests require a second factor for
+func createTwoFactorTestUser(t *testing.T, db *sql.DB, email string) int64 {
+	t.Helper()
+
+	var userID int64
+	err := db.QueryRow(
+		`INSERT INTO users (email, password_hash, role, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4) RETURNING id`,
+		email, "hash", models.RoleLogistics, time.Now(),
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create test user: %v", err)
+	}
+	return userID
+}
+
+// assertTwoFactorRedirect checks that a login was sent to /login/2fa without a session
+func assertTwoFactorRedirect(t *testing.T, w *httptest.ResponseRecorder) {
+	t.Helper()
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "url=/login/2fa") {
+		t.Errorf("Expected a redirect to /login/2fa, got %s", w.Body.String())
+	}
+
+	var challenge bool
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == middleware.SessionCookieName && cookie.Value != "" {
+			t.Error("Session cookie set before the second factor was checked")
+		}
+		if cookie.Name == twoFactorCookieName && cookie.Value != "" {
+			challenge = true
+		}
+	}
+	if !challenge {
+		t.Error("Two-factor challenge cookie not set")
+	}
+}
+
+func TestMagicLinkLogin_RequiresTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	userID := createTwoFactorTestUser(t, db, "magic-2fa@example.com")
+	magicLink, err := auth.CreateMagicLink(context.Background(), db, userID, nil, auth.DefaultMagicLinkDuration)
+	if err != nil {
+		t.Fatalf("Failed to create magic link: %v", err)
+	}
+
+	handler := NewAuthHandler(db, nil)
+	handler.TwoFactorRequiredRoles = map[models.UserRole]bool{models.RoleLogistics: true}
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/magic-link?token="+magicLink.Token, nil)
+	w := httptest.NewRecorder()
+
+	handler.MagicLinkLogin(w, req)
+
+	assertTwoFactorRedirect(t, w)
+}
+
+// fakeGoogle answers the token exchange and user info requests of a Google login
+type fakeGoogle struct {
+	userInfo auth.GoogleUserInfo
+}
+
+func (f *fakeGoogle) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := []byte(`{"access_token":"test-access-token","token_type":"Bearer","expires_in":3600}`)
+	if req.URL.Host != "oauth.test" {
+		var err error
+		if body, err = json.Marshal(f.userInfo); err != nil {
+			return nil, err
+		}
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+func TestGoogleCallback_RequiresTwoFactor(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	createTwoFactorTestUser(t, db, "google-2fa@example.com")
+
+	handler := NewAuthHandler(db, nil)
+	handler.TwoFactorRequiredRoles = map[models.UserRole]bool{models.RoleLogistics: true}
+	handler.OAuthConfig = &oauth2.Config{
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: "https://oauth.test/token"},
+	}
+
+	google := &fakeGoogle{userInfo: auth.GoogleUserInfo{
+		ID:            "google-2fa",
+		Email:         "google-2fa@example.com",
+		VerifiedEmail: true,
+	}}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: google})
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/google/callback?state=test-state&code=test-code", nil)
+	req = req.WithContext(ctx)
+	req.AddCookie(&http.Cookie{Name: "oauth_state", Value: "test-state"})
+	w := httptest.NewRecorder()
+
+	handler.GoogleCallback(w, req)
+
+	assertTwoFactorRedirect(t, w)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/yourusername/laptop-tracking-system/internal/audit"
+	"github.com/yourusername/laptop-tracking-system/internal/middleware"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+	"github.com/yourusername/laptop-tracking-system/internal/views"
+)
+
+// ========== WORKFLOW HANDLERS ==========
+
+// WorkflowsList displays the active shipment workflow for every shipment type
+func (h *FormsHandler) WorkflowsList(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.WorkflowManage) {
+		return
+	}
+
+	definitions, err := models.GetAllWorkflowDefinitions(r.Context(), h.DB)
+	if err != nil {
+		log.Printf("Error getting workflow definitions: %v", err)
+		http.Error(w, "Failed to load workflows", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":        user,
+		"Nav":         views.GetNavigationLinks(user.Role),
+		"CurrentPage": "forms",
+		"Workflows":   definitions,
+		"Success":     r.URL.Query().Get("success"),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "workflows-list.html", data); err != nil {
+		log.Printf("Error executing workflows list template: %v", err)
+		http.Error(w, "Failed to render workflows list", http.StatusInternalServerError)
+		return
+	}
+}
+
+// WorkflowEditPage displays the JSON editor for a shipment type's workflow
+func (h *FormsHandler) WorkflowEditPage(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.WorkflowManage) {
+		return
+	}
+
+	shipmentType := models.ShipmentType(mux.Vars(r)["type"])
+	if !models.IsValidShipmentType(shipmentType) {
+		http.Error(w, "Invalid shipment type", http.StatusBadRequest)
+		return
+	}
+
+	def, err := models.GetWorkflowDefinition(r.Context(), h.DB, shipmentType)
+	if err != nil {
+		log.Printf("Error getting workflow definition: %v", err)
+		http.Error(w, "Failed to load workflow", http.StatusInternalServerError)
+		return
+	}
+
+	definitionJSON, err := json.MarshalIndent(def, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to encode workflow", http.StatusInternalServerError)
+		return
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	data := map[string]interface{}{
+		"User":           user,
+		"Nav":            views.GetNavigationLinks(user.Role),
+		"CurrentPage":    "forms",
+		"Workflow":       def,
+		"DefinitionJSON": string(definitionJSON),
+		"Guards":         models.GetAllWorkflowGuards(),
+		"Effects":        models.GetAllWorkflowEffects(),
+		"Error":          r.URL.Query().Get("error"),
+		"CSRFToken":      middleware.GetCSRFToken(r.Context()),
+	}
+
+	if err := h.Templates.ExecuteTemplate(w, "workflow-form.html", data); err != nil {
+		log.Printf("Error executing workflow form template: %v", err)
+		http.Error(w, "Failed to render form", http.StatusInternalServerError)
+		return
+	}
+}
+
+// WorkflowEditSubmit validates and stores an edited workflow definition
+func (h *FormsHandler) WorkflowEditSubmit(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.WorkflowManage) {
+		return
+	}
+
+	shipmentType := models.ShipmentType(mux.Vars(r)["type"])
+	if !models.IsValidShipmentType(shipmentType) {
+		http.Error(w, "Invalid shipment type", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	editURL := "/forms/workflows/" + string(shipmentType) + "/edit"
+
+	var def models.WorkflowDefinition
+	if err := json.Unmarshal([]byte(r.FormValue("definition")), &def); err != nil {
+		http.Redirect(w, r, editURL+"?error="+url.QueryEscape("Invalid JSON: "+err.Error()), http.StatusSeeOther)
+		return
+	}
+	// The shipment type always comes from the URL
+	def.ShipmentType = shipmentType
+
+	previous, err := models.GetWorkflowDefinition(r.Context(), h.DB, shipmentType)
+	if err != nil {
+		log.Printf("Error loading workflow definition: %v", err)
+	}
+
+	user := middleware.GetUserFromContext(r.Context())
+	if err := models.SaveWorkflowDefinition(h.DB, &def, user.ID); err != nil {
+		log.Printf("Error saving workflow definition: %v", err)
+		http.Redirect(w, r, editURL+"?error="+url.QueryEscape(err.Error()), http.StatusSeeOther)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "workflow_updated",
+		EntityType: audit.EntityWorkflow,
+		Changes:    audit.Diff(previous, &def),
+		Details:    map[string]interface{}{"shipment_type": shipmentType},
+	})
+
+	http.Redirect(w, r, "/forms/workflows?success="+url.QueryEscape("Workflow updated successfully"), http.StatusSeeOther)
+}
+
+// WorkflowResetSubmit removes a stored workflow so the built-in definition applies again
+func (h *FormsHandler) WorkflowResetSubmit(w http.ResponseWriter, r *http.Request) {
+	if !middleware.Authorize(w, r, permissions.WorkflowManage) {
+		return
+	}
+
+	shipmentType := models.ShipmentType(mux.Vars(r)["type"])
+	if !models.IsValidShipmentType(shipmentType) {
+		http.Error(w, "Invalid shipment type", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.ResetWorkflowDefinition(h.DB, shipmentType); err != nil {
+		log.Printf("Error resetting workflow definition: %v", err)
+		http.Error(w, "Failed to reset workflow", http.StatusInternalServerError)
+		return
+	}
+
+	h.logAudit(r, audit.Entry{
+		Action:     "workflow_reset",
+		EntityType: audit.EntityWorkflow,
+		Details:    map[string]interface{}{"shipment_type": shipmentType},
+	})
+
+	http.Redirect(w, r, "/forms/workflows?success="+url.QueryEscape("Workflow reset to built-in default"), http.StatusSeeOther)
+}
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/yourusername/laptop-tracking-system/internal/auth"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 )
 
 // isProduction checks if the application is running in production
@@ -24,15 +26,35 @@ const (
 	UserContextKey ContextKey = "user"
 	// SessionContextKey is the key for storing session in request context
 	SessionContextKey ContextKey = "session"
+	// APITokenContextKey is the key for storing the API token of a bearer-authenticated request
+	APITokenContextKey ContextKey = "api_token"
 )
 
 // SessionCookieName is the name of the session cookie
 const SessionCookieName = "session_token"
 
-// AuthMiddleware validates the session and adds user info to the request context
+// AuthMiddleware validates the session or API token and adds user info to the request context.
+// API tokens are sent as "Authorization: Bearer <token>"; an invalid token leaves the request unauthenticated.
 func AuthMiddleware(db *sql.DB) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if bearer, ok := bearerToken(r); ok {
+				token, user, err := auth.ValidateAPIToken(r.Context(), db, bearer)
+				if err != nil {
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				if token == nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				ctx := context.WithValue(r.Context(), APITokenContextKey, token)
+				ctx = context.WithValue(ctx, UserContextKey, user)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			// Get session token from cookie
 			cookie, err := r.Cookie(SessionCookieName)
 			if err != nil {
@@ -86,6 +108,18 @@ func RequireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// RejectAPITokens middleware refuses requests authenticated with an API token.
+// Tokens are scoped for the JSON API, so the HTML pages only accept session logins.
+func RejectAPITokens(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetAPITokenFromContext(r.Context()) != nil {
+			http.Error(w, "Forbidden: API tokens can only be used with the JSON API", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 // RequireRole middleware ensures the user has one of the specified roles
 func RequireRole(roles ...models.UserRole) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -115,6 +149,35 @@ func RequireRole(roles ...models.UserRole) func(http.Handler) http.Handler {
 	}
 }
 
+// RequirePermission middleware ensures the user is granted at least one of the permissions.
+// Use it when registering routes; handlers that check inside use Authorize.
+func RequirePermission(perms ...permissions.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !Authorize(w, r, perms...) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Authorize checks that the user is granted at least one of the permissions.
+// If not, it logs the denial, writes the redirect or 403 response and returns false.
+func Authorize(w http.ResponseWriter, r *http.Request, perms ...permissions.Permission) bool {
+	user := GetUserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return false
+	}
+	if !permissions.CanAny(user, perms...) {
+		permissions.LogDenied(r, user, perms...)
+		http.Error(w, "Forbidden: insufficient permissions", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // GetUserFromContext retrieves the authenticated user from the request context
 func GetUserFromContext(ctx context.Context) *models.User {
 	user, ok := ctx.Value(UserContextKey).(*models.User)
@@ -133,6 +196,27 @@ func GetSessionFromContext(ctx context.Context) *models.Session {
 	return session
 }
 
+// GetAPITokenFromContext retrieves the API token from the request context.
+// Returns nil for requests authenticated with a session.
+func GetAPITokenFromContext(ctx context.Context) *models.APIToken {
+	token, ok := ctx.Value(APITokenContextKey).(*models.APIToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
 // IsAuthenticated checks if the request has an authenticated user
 func IsAuthenticated(r *http.Request) bool {
 	return GetUserFromContext(r.Context()) != nil
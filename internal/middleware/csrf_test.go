@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testCSRFSecret = "test-secret"
+
+func csrfTestHandler() http.Handler {
+	return CSRF(testCSRFSecret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			w.Write([]byte(GetCSRFToken(r.Context())))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func TestCSRF_StoresTokenInContext(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/page", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "session-a"})
+	rr := httptest.NewRecorder()
+	csrfTestHandler().ServeHTTP(rr, req)
+
+	token := csrfToken([]byte(testCSRFSecret), "session:session-a")
+	if rr.Body.String() != token {
+		t.Errorf("token in context = %q, want %q", rr.Body.String(), token)
+	}
+}
+
+func TestCSRFField(t *testing.T) {
+	got := string(CSRFField(`abc"def`))
+	want := `<input type="hidden" name="csrf_token" value="abc&#34;def">`
+	if got != want {
+		t.Errorf("CSRFField() = %s, want %s", got, want)
+	}
+}
+
+func TestCSRF_ValidatesStateChangingRequests(t *testing.T) {
+	validToken := csrfToken([]byte(testCSRFSecret), "session:session-a")
+	otherSessionToken := csrfToken([]byte(testCSRFSecret), "session:session-b")
+
+	tests := []struct {
+		name       string
+		path       string
+		form       url.Values
+		header     map[string]string
+		wantStatus int
+	}{
+		{"missing token", "/save", url.Values{}, nil, http.StatusForbidden},
+		{"token of another session", "/save", url.Values{"csrf_token": {otherSessionToken}}, nil, http.StatusForbidden},
+		{"valid form token", "/save", url.Values{"csrf_token": {validToken}}, nil, http.StatusNoContent},
+		{"valid header token", "/save", url.Values{}, map[string]string{CSRFHeaderName: validToken}, http.StatusNoContent},
+		{"API token request", "/api/v1/shipments", url.Values{}, map[string]string{"Authorization": "Bearer lts_abc"}, http.StatusNoContent},
+		{"courier webhook", "/webhooks/courier/fedex", url.Values{}, nil, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "session-a"})
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			csrfTestHandler().ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCSRF_AnonymousVisitorsGetACookie(t *testing.T) {
+	rr := httptest.NewRecorder()
+	csrfTestHandler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/login", nil))
+
+	var cookie *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("expected a CSRF cookie for visitors without a session")
+	}
+
+	form := url.Values{"csrf_token": {csrfToken([]byte(testCSRFSecret), "anonymous:"+cookie.Value)}}
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	rr = httptest.NewRecorder()
+	csrfTestHandler().ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d", rr.Code, http.StatusNoContent)
+	}
+}
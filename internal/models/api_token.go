@@ -0,0 +1,459 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// APITokenScope limits what an API token may do
+type APITokenScope string
+
+// API token scope constants.
+// Every token can read what its owner can read; write scopes add write access to part of the API.
+const (
+	ScopeRead           APITokenScope = "read"
+	ScopeShipmentsWrite APITokenScope = "shipments:write"
+	ScopeInventoryWrite APITokenScope = "inventory:write"
+)
+
+// AllAPITokenScopes lists the scopes in the order they are shown in forms
+var AllAPITokenScopes = []APITokenScope{ScopeRead, ScopeShipmentsWrite, ScopeInventoryWrite}
+
+// IsValidAPITokenScope checks if a given scope is valid
+func IsValidAPITokenScope(scope APITokenScope) bool {
+	for _, s := range AllAPITokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// GetAPITokenScopeDescription returns a description of what a scope allows
+func GetAPITokenScopeDescription(scope APITokenScope) string {
+	switch scope {
+	case ScopeRead:
+		return "Read-only access"
+	case ScopeShipmentsWrite:
+		return "Create shipments and change their status"
+	case ScopeInventoryWrite:
+		return "Add, edit and delete laptops, approve reception reports"
+	default:
+		return string(scope)
+	}
+}
+
+// APITokenPrefixLength is how many characters of a token are stored in clear to tell tokens apart
+const APITokenPrefixLength = 12
+
+// APIToken is a bearer token for the JSON API, owned by either a user (personal access token)
+// or a service account
+type APIToken struct {
+	ID               int64           `json:"id" db:"id"`
+	Name             string          `json:"name" db:"name"`
+	TokenHash        string          `json:"-" db:"token_hash"`
+	TokenPrefix      string          `json:"token_prefix" db:"token_prefix"`
+	Scopes           []APITokenScope `json:"scopes" db:"scopes"`
+	UserID           *int64          `json:"user_id,omitempty" db:"user_id"`
+	ServiceAccountID *int64          `json:"service_account_id,omitempty" db:"service_account_id"`
+	CreatedByUserID  *int64          `json:"created_by_user_id,omitempty" db:"created_by_user_id"`
+	ExpiresAt        *time.Time      `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt       *time.Time      `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt        *time.Time      `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+}
+
+// Validate validates the APIToken model
+func (t *APIToken) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return errors.New("name is required")
+	}
+	if len(t.Name) > 255 {
+		return errors.New("name must be at most 255 characters")
+	}
+	if t.TokenHash == "" {
+		return errors.New("token hash is required")
+	}
+	if (t.UserID == nil) == (t.ServiceAccountID == nil) {
+		return errors.New("token must belong to either a user or a service account")
+	}
+	if len(t.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range t.Scopes {
+		if !IsValidAPITokenScope(scope) {
+			return fmt.Errorf("invalid scope: %s", scope)
+		}
+	}
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
+
+// TableName returns the table name for the APIToken model
+func (t *APIToken) TableName() string {
+	return "api_tokens"
+}
+
+// BeforeCreate sets the timestamp before creating a token
+func (t *APIToken) BeforeCreate() {
+	t.CreatedAt = time.Now()
+}
+
+// IsExpired returns true if the token has an expiry in the past
+func (t *APIToken) IsExpired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// IsRevoked returns true if the token has been revoked
+func (t *APIToken) IsRevoked() bool {
+	return t.RevokedAt != nil
+}
+
+// IsActive returns true if the token can still be used
+func (t *APIToken) IsActive() bool {
+	return !t.IsRevoked() && !t.IsExpired()
+}
+
+// StatusLabel returns whether the token is active, expired or revoked
+func (t APIToken) StatusLabel() string {
+	switch {
+	case t.IsRevoked():
+		return "Revoked"
+	case t.IsExpired():
+		return "Expired"
+	default:
+		return "Active"
+	}
+}
+
+// HasScope returns true if the token was granted the scope. Every token may read.
+func (t *APIToken) HasScope(scope APITokenScope) bool {
+	if scope == ScopeRead {
+		return true
+	}
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopesLabel returns the scopes as a comma-separated list
+func (t APIToken) ScopesLabel() string {
+	names := make([]string, len(t.Scopes))
+	for i, scope := range t.Scopes {
+		names[i] = string(scope)
+	}
+	return strings.Join(names, ", ")
+}
+
+// ParseAPITokenScopes converts form values to scopes, dropping blanks and duplicates
+func ParseAPITokenScopes(values []string) []APITokenScope {
+	var scopes []APITokenScope
+	seen := map[APITokenScope]bool{}
+	for _, value := range values {
+		scope := APITokenScope(strings.TrimSpace(value))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// ServiceAccount is an API client that is not tied to a person.
+// It acts with a role (and, for clients, a company) like a user, but only through API tokens.
+type ServiceAccount struct {
+	ID                int64      `json:"id" db:"id"`
+	Name              string     `json:"name" db:"name"`
+	Description       string     `json:"description,omitempty" db:"description"`
+	Role              UserRole   `json:"role" db:"role"`
+	ClientCompanyID   *int64     `json:"client_company_id,omitempty" db:"client_company_id"`
+	ClientCompanyName string     `json:"client_company_name,omitempty" db:"-"` // Populated via JOIN queries
+	CreatedByUserID   *int64     `json:"created_by_user_id,omitempty" db:"created_by_user_id"`
+	DisabledAt        *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	LastUsedAt        *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Relations
+	Tokens []APIToken `json:"tokens,omitempty" db:"-"`
+}
+
+// Validate validates the ServiceAccount model
+func (s *ServiceAccount) Validate() error {
+	if strings.TrimSpace(s.Name) == "" {
+		return errors.New("name is required")
+	}
+	if len(s.Name) > 255 {
+		return errors.New("name must be at most 255 characters")
+	}
+	if !IsValidRole(s.Role) {
+		return errors.New("invalid role")
+	}
+	if s.Role == RoleClient && s.ClientCompanyID == nil {
+		return errors.New("client service accounts must belong to a client company")
+	}
+	if s.Role != RoleClient && s.ClientCompanyID != nil {
+		return errors.New("only client service accounts can belong to a client company")
+	}
+	return nil
+}
+
+// TableName returns the table name for the ServiceAccount model
+func (s *ServiceAccount) TableName() string {
+	return "service_accounts"
+}
+
+// BeforeCreate sets the timestamps before creating a service account
+func (s *ServiceAccount) BeforeCreate() {
+	now := time.Now()
+	s.CreatedAt = now
+	s.UpdatedAt = now
+}
+
+// IsDisabled returns true if the service account has been disabled
+func (s *ServiceAccount) IsDisabled() bool {
+	return s.DisabledAt != nil
+}
+
+// AsUser returns a user carrying the service account's role and company, so role checks
+// treat the service account like a user with the same role. The user has no ID.
+func (s *ServiceAccount) AsUser() *User {
+	id := s.ID
+	return &User{
+		Email:             s.Name,
+		Role:              s.Role,
+		ClientCompanyID:   s.ClientCompanyID,
+		ClientCompanyName: s.ClientCompanyName,
+		ServiceAccountID:  &id,
+		CreatedAt:         s.CreatedAt,
+		UpdatedAt:         s.UpdatedAt,
+	}
+}
+
+// apiTokenColumns selects an API token
+const apiTokenColumns = `
+	id, name, token_hash, token_prefix, scopes, user_id, service_account_id, created_by_user_id,
+	expires_at, last_used_at, revoked_at, created_at
+	FROM api_tokens`
+
+// scanAPIToken scans a row selected with apiTokenColumns
+func scanAPIToken(row interface{ Scan(...interface{}) error }) (APIToken, error) {
+	var t APIToken
+	var scopes []string
+	err := row.Scan(
+		&t.ID, &t.Name, &t.TokenHash, &t.TokenPrefix, pq.Array(&scopes), &t.UserID, &t.ServiceAccountID, &t.CreatedByUserID,
+		&t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt, &t.CreatedAt,
+	)
+	t.Scopes = ParseAPITokenScopes(scopes)
+	return t, err
+}
+
+// CreateAPIToken stores a new API token. TokenHash must already be set.
+func CreateAPIToken(db *sql.DB, token *APIToken) error {
+	if err := token.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	token.BeforeCreate()
+
+	scopes := make([]string, len(token.Scopes))
+	for i, scope := range token.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	err := db.QueryRow(
+		`INSERT INTO api_tokens (name, token_hash, token_prefix, scopes, user_id, service_account_id, created_by_user_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`,
+		strings.TrimSpace(token.Name), token.TokenHash, token.TokenPrefix, pq.Array(scopes),
+		token.UserID, token.ServiceAccountID, token.CreatedByUserID, token.ExpiresAt, token.CreatedAt,
+	).Scan(&token.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create API token: %w", err)
+	}
+	return nil
+}
+
+// GetAPITokenByHash retrieves a token by the hash of its value
+func GetAPITokenByHash(db *sql.DB, tokenHash string) (*APIToken, error) {
+	token, err := scanAPIToken(db.QueryRow("SELECT "+apiTokenColumns+" WHERE token_hash = $1", tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetAPITokenByID retrieves a token by its ID
+func GetAPITokenByID(db *sql.DB, id int64) (*APIToken, error) {
+	token, err := scanAPIToken(db.QueryRow("SELECT "+apiTokenColumns+" WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, &NotFoundError{Entity: "API token", ID: id}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API token: %w", err)
+	}
+	return &token, nil
+}
+
+// GetAPITokensByUser retrieves the personal access tokens of a user, newest first
+func GetAPITokensByUser(db *sql.DB, userID int64) ([]APIToken, error) {
+	return queryAPITokens(db, "SELECT "+apiTokenColumns+" WHERE user_id = $1 ORDER BY created_at DESC, id DESC", userID)
+}
+
+// GetAPITokensByServiceAccount retrieves the tokens of a service account, newest first
+func GetAPITokensByServiceAccount(db *sql.DB, serviceAccountID int64) ([]APIToken, error) {
+	return queryAPITokens(db, "SELECT "+apiTokenColumns+" WHERE service_account_id = $1 ORDER BY created_at DESC, id DESC", serviceAccountID)
+}
+
+// queryAPITokens runs a query selecting apiTokenColumns
+func queryAPITokens(db *sql.DB, query string, args ...interface{}) ([]APIToken, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []APIToken
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating API tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// RevokeAPIToken revokes a token. Revoking an already revoked token keeps the first revocation time.
+func RevokeAPIToken(db *sql.DB, id int64) error {
+	result, err := db.Exec("UPDATE api_tokens SET revoked_at = COALESCE(revoked_at, $1) WHERE id = $2", time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API token: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Entity: "API token", ID: id}
+	}
+	return nil
+}
+
+// serviceAccountColumns selects a service account with its company name
+const serviceAccountColumns = `
+	sa.id, sa.name, COALESCE(sa.description, ''), sa.role, sa.client_company_id, COALESCE(cc.name, ''),
+	sa.created_by_user_id, sa.disabled_at, sa.last_used_at, sa.created_at, sa.updated_at
+	FROM service_accounts sa
+	LEFT JOIN client_companies cc ON cc.id = sa.client_company_id`
+
+// scanServiceAccount scans a row selected with serviceAccountColumns
+func scanServiceAccount(row interface{ Scan(...interface{}) error }) (ServiceAccount, error) {
+	var s ServiceAccount
+	err := row.Scan(
+		&s.ID, &s.Name, &s.Description, &s.Role, &s.ClientCompanyID, &s.ClientCompanyName,
+		&s.CreatedByUserID, &s.DisabledAt, &s.LastUsedAt, &s.CreatedAt, &s.UpdatedAt,
+	)
+	return s, err
+}
+
+// GetAllServiceAccounts retrieves all service accounts ordered by name
+func GetAllServiceAccounts(db *sql.DB) ([]ServiceAccount, error) {
+	rows, err := db.Query("SELECT " + serviceAccountColumns + " ORDER BY sa.name ASC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query service accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []ServiceAccount
+	for rows.Next() {
+		account, err := scanServiceAccount(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan service account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating service accounts: %w", err)
+	}
+	return accounts, nil
+}
+
+// GetServiceAccountByID retrieves a service account by its ID
+func GetServiceAccountByID(db *sql.DB, id int64) (*ServiceAccount, error) {
+	account, err := scanServiceAccount(db.QueryRow("SELECT "+serviceAccountColumns+" WHERE sa.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, &NotFoundError{Entity: "service account", ID: id}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service account: %w", err)
+	}
+	return &account, nil
+}
+
+// CreateServiceAccount creates a new service account
+func CreateServiceAccount(db *sql.DB, account *ServiceAccount) error {
+	if err := account.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	account.BeforeCreate()
+
+	err := db.QueryRow(
+		`INSERT INTO service_accounts (name, description, role, client_company_id, created_by_user_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id`,
+		strings.TrimSpace(account.Name), account.Description, account.Role, account.ClientCompanyID,
+		account.CreatedByUserID, account.CreatedAt, account.UpdatedAt,
+	).Scan(&account.ID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return errors.New("a service account with this name already exists")
+		}
+		return fmt.Errorf("failed to create service account: %w", err)
+	}
+	return nil
+}
+
+// SetServiceAccountDisabled disables or re-enables a service account.
+// A disabled service account's tokens are rejected but kept, so enabling it again restores access.
+func SetServiceAccountDisabled(db *sql.DB, id int64, disabled bool) error {
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+
+	result, err := db.Exec(
+		"UPDATE service_accounts SET disabled_at = $1, updated_at = $2 WHERE id = $3",
+		disabledAt, time.Now(), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update service account: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return &NotFoundError{Entity: "service account", ID: id}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAPIToken_Validate(t *testing.T) {
+	userID, accountID := int64(1), int64(2)
+	future := time.Now().Add(24 * time.Hour)
+	past := time.Now().Add(-time.Hour)
+	read := []APITokenScope{ScopeRead}
+
+	tests := []struct {
+		name    string
+		token   APIToken
+		wantErr bool
+	}{
+		{"personal token", APIToken{Name: "script", TokenHash: "abc", UserID: &userID, Scopes: read}, false},
+		{"service account token with expiry", APIToken{Name: "sync", TokenHash: "abc", ServiceAccountID: &accountID, Scopes: read, ExpiresAt: &future}, false},
+		{"missing name", APIToken{TokenHash: "abc", UserID: &userID, Scopes: read}, true},
+		{"missing hash", APIToken{Name: "script", UserID: &userID, Scopes: read}, true},
+		{"no owner", APIToken{Name: "script", TokenHash: "abc", Scopes: read}, true},
+		{"two owners", APIToken{Name: "script", TokenHash: "abc", UserID: &userID, ServiceAccountID: &accountID, Scopes: read}, true},
+		{"no scopes", APIToken{Name: "script", TokenHash: "abc", UserID: &userID}, true},
+		{"unknown scope", APIToken{Name: "script", TokenHash: "abc", UserID: &userID, Scopes: []APITokenScope{"admin"}}, true},
+		{"expiry in the past", APIToken{Name: "script", TokenHash: "abc", UserID: &userID, Scopes: read, ExpiresAt: &past}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.token.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIToken_Status(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		token      APIToken
+		wantActive bool
+		wantLabel  string
+	}{
+		{"never expires", APIToken{}, true, "Active"},
+		{"expires later", APIToken{ExpiresAt: &future}, true, "Active"},
+		{"expired", APIToken{ExpiresAt: &past}, false, "Expired"},
+		{"revoked", APIToken{RevokedAt: &past, ExpiresAt: &past}, false, "Revoked"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.IsActive(); got != tt.wantActive {
+				t.Errorf("IsActive() = %v, want %v", got, tt.wantActive)
+			}
+			if got := tt.token.StatusLabel(); got != tt.wantLabel {
+				t.Errorf("StatusLabel() = %q, want %q", got, tt.wantLabel)
+			}
+		})
+	}
+}
+
+func TestAPIToken_HasScope(t *testing.T) {
+	token := APIToken{Scopes: []APITokenScope{ScopeShipmentsWrite}}
+
+	if !token.HasScope(ScopeRead) {
+		t.Error("Expected every token to be able to read")
+	}
+	if !token.HasScope(ScopeShipmentsWrite) {
+		t.Error("Expected the granted scope")
+	}
+	if token.HasScope(ScopeInventoryWrite) {
+		t.Error("Expected a scope that was not granted to be missing")
+	}
+}
+
+func TestParseAPITokenScopes(t *testing.T) {
+	got := ParseAPITokenScopes([]string{"read", " shipments:write ", "", "read"})
+	want := []APITokenScope{ScopeRead, ScopeShipmentsWrite}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAPITokenScopes() = %v, want %v", got, want)
+	}
+}
+
+func TestServiceAccount_Validate(t *testing.T) {
+	companyID := int64(3)
+
+	tests := []struct {
+		name    string
+		account ServiceAccount
+		wantErr bool
+	}{
+		{"logistics", ServiceAccount{Name: "erp-sync", Role: RoleLogistics}, false},
+		{"client with company", ServiceAccount{Name: "acme-portal", Role: RoleClient, ClientCompanyID: &companyID}, false},
+		{"missing name", ServiceAccount{Role: RoleLogistics}, true},
+		{"invalid role", ServiceAccount{Name: "erp-sync", Role: "admin"}, true},
+		{"client without company", ServiceAccount{Name: "acme-portal", Role: RoleClient}, true},
+		{"company on another role", ServiceAccount{Name: "erp-sync", Role: RoleWarehouse, ClientCompanyID: &companyID}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.account.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestServiceAccount_AsUser(t *testing.T) {
+	companyID := int64(3)
+	account := ServiceAccount{ID: 9, Name: "acme-portal", Role: RoleClient, ClientCompanyID: &companyID, ClientCompanyName: "Acme"}
+
+	user := account.AsUser()
+	if user.ID != 0 {
+		t.Errorf("Expected the user to have no ID, got %d", user.ID)
+	}
+	if !user.IsServiceAccount() || *user.ServiceAccountID != 9 {
+		t.Errorf("Expected the user to reference service account 9, got %v", user.ServiceAccountID)
+	}
+	if user.Role != RoleClient || user.ClientCompanyID != &companyID || user.ClientCompanyName != "Acme" {
+		t.Errorf("Expected the service account's role and company, got %+v", user)
+	}
+}
@@ -2,24 +2,36 @@ package models
 
 import (
 	"errors"
+	"strings"
 	"time"
 )
 
 // MagicLink represents a one-time login link sent via email
 type MagicLink struct {
-	ID         int64      `json:"id" db:"id"`
-	UserID     int64      `json:"user_id" db:"user_id"`
-	Token      string     `json:"token" db:"token"`
-	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
-	UsedAt     *time.Time `json:"used_at,omitempty" db:"used_at"`
-	ShipmentID *int64     `json:"shipment_id,omitempty" db:"shipment_id"`
-	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	ID         int64            `json:"id" db:"id"`
+	UserID     int64            `json:"user_id" db:"user_id"`
+	Token      string           `json:"token" db:"-"` // Only known when created or presented; the table stores its hash
+	Purpose    MagicLinkPurpose `json:"purpose" db:"purpose"`
+	ExpiresAt  time.Time        `json:"expires_at" db:"expires_at"`
+	UsedAt     *time.Time       `json:"used_at,omitempty" db:"used_at"`
+	ShipmentID *int64           `json:"shipment_id,omitempty" db:"shipment_id"`
+	CreatedAt  time.Time        `json:"created_at" db:"created_at"`
 
 	// Relations
 	User     *User     `json:"user,omitempty" db:"-"`
 	Shipment *Shipment `json:"shipment,omitempty" db:"-"`
 }
 
+// MagicLinkPurpose is what a magic link lets its holder do
+type MagicLinkPurpose string
+
+// Magic link purposes
+const (
+	MagicLinkPurposeLogin         MagicLinkPurpose = "login"          // Signs the user in, e.g. to fill a shipment form
+	MagicLinkPurposeInvitation    MagicLinkPurpose = "invitation"     // Lets a new user set their first password
+	MagicLinkPurposePasswordReset MagicLinkPurpose = "password_reset" // Lets a user replace a forgotten password
+)
+
 // Validate validates the MagicLink model
 func (m *MagicLink) Validate() error {
 	if m.UserID == 0 {
@@ -62,11 +74,14 @@ func (m *MagicLink) MarkAsUsed() {
 
 // Session represents a user session
 type Session struct {
-	ID        int64     `json:"id" db:"id"`
-	UserID    int64     `json:"user_id" db:"user_id"`
-	Token     string    `json:"token" db:"token"`
-	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ID         int64     `json:"id" db:"id"`
+	UserID     int64     `json:"user_id" db:"user_id"`
+	Token      string    `json:"token" db:"-"` // Only known when created or presented; the table stores its hash
+	IPAddress  string    `json:"ip_address,omitempty" db:"ip_address"`
+	UserAgent  string    `json:"user_agent,omitempty" db:"user_agent"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 
 	// Relations
 	User *User `json:"user,omitempty" db:"-"`
@@ -91,9 +106,10 @@ func (s *Session) TableName() string {
 	return "sessions"
 }
 
-// BeforeCreate sets the timestamp before creating a session
+// BeforeCreate sets the timestamps before creating a session
 func (s *Session) BeforeCreate() {
 	s.CreatedAt = time.Now()
+	s.LastSeenAt = s.CreatedAt
 }
 
 // IsExpired returns true if the session has expired
@@ -101,3 +117,48 @@ func (s *Session) IsExpired() bool {
 	return time.Now().After(s.ExpiresAt)
 }
 
+// Device returns a short description of the browser and operating system of the session,
+// such as "Chrome on Windows", from its user agent
+func (s *Session) Device() string {
+	if s.UserAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := "Unknown browser"
+	// Order matters: Edge and Opera also claim to be Chrome, and Chrome claims to be Safari
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(s.UserAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	platform := ""
+	for _, o := range []struct{ token, name string }{
+		{"Android", "Android"},
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(s.UserAgent, o.token) {
+			platform = o.name
+			break
+		}
+	}
+
+	if platform == "" {
+		return browser
+	}
+	return browser + " on " + platform
+}
@@ -100,6 +100,17 @@ func (e *CalendarEvent) GetShipmentLink() string {
 // If userRole is RoleWarehouse, only events for warehouse-related shipments are returned
 // If both are nil, all events are returned
 func GetCalendarEvents(db *sql.DB, startDate, endDate time.Time, clientCompanyID *int64, userRole *UserRole) ([]CalendarEvent, error) {
+	var companyIDs []int64
+	if clientCompanyID != nil {
+		companyIDs = []int64{*clientCompanyID}
+	}
+	return GetCalendarEventsForCompanies(db, startDate, endDate, companyIDs, userRole)
+}
+
+// GetCalendarEventsForCompanies retrieves calendar events within a date range for a set of client companies
+// If companyIDs is nil, events of all companies are returned; an empty slice returns no events
+// If userRole is RoleWarehouse, only events for warehouse-related shipments are returned
+func GetCalendarEventsForCompanies(db *sql.DB, startDate, endDate time.Time, companyIDs []int64, userRole *UserRole) ([]CalendarEvent, error) {
 	query := `
 		SELECT 
 			s.id,
@@ -128,10 +139,11 @@ func GetCalendarEvents(db *sql.DB, startDate, endDate time.Time, clientCompanyID
 	args := []interface{}{startDate, endDate}
 
 	// Add client company filter if provided
-	if clientCompanyID != nil {
-		query += fmt.Sprintf(" AND s.client_company_id = $%d", argCount)
-		args = append(args, *clientCompanyID)
-		argCount++
+	if companyIDs != nil {
+		condition, scopeArgs := CompanyScope{CompanyIDs: companyIDs}.Condition("s.client_company_id", argCount)
+		query += " AND " + condition
+		args = append(args, scopeArgs...)
+		argCount += len(scopeArgs)
 	}
 
 	// Add warehouse role filter if provided
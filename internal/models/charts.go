@@ -172,6 +172,14 @@ func formatStatusLabel(status ShipmentStatus) string {
 		ShipmentStatusReleasedFromWarehouse: "Released from Warehouse",
 		ShipmentStatusInTransitToEngineer:   "In Transit to Engineer",
 		ShipmentStatusDelivered:             "Delivered",
+		ShipmentStatusReturnKitSent:         "Return Kit Sent",
+		ShipmentStatusReturnKitDelivered:    "Return Kit Delivered",
+		ShipmentStatusPickedUpFromEngineer:  "Picked Up from Engineer",
+		ShipmentStatusOnHold:                "On Hold",
+		ShipmentStatusLost:                  "Lost",
+		ShipmentStatusDamaged:               "Damaged",
+		ShipmentStatusReturnedToSender:      "Returned to Sender",
+		ShipmentStatusCancelled:             "Cancelled",
 	}
 
 	if label, ok := labels[status]; ok {
@@ -110,7 +110,7 @@ func GetClientCompanyByID(db *sql.DB, id int64) (*ClientCompany, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("client company not found with id %d", id)
+		return nil, &NotFoundError{Entity: "client company", ID: id}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get client company: %w", err)
@@ -184,7 +184,7 @@ func UpdateClientCompany(db *sql.DB, company *ClientCompany) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("client company not found with id %d", company.ID)
+		return &NotFoundError{Entity: "client company", ID: company.ID}
 	}
 
 	return nil
@@ -205,7 +205,7 @@ func DeleteClientCompany(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("client company not found with id %d", id)
+		return &NotFoundError{Entity: "client company", ID: id}
 	}
 
 	return nil
@@ -0,0 +1,176 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CommercialInvoiceItem is one device declared on a commercial invoice
+type CommercialInvoiceItem struct {
+	LaptopID        int64
+	SerialNumber    string
+	Brand           string
+	Model           string
+	DeviceCategory  DeviceCategory
+	CountryOfOrigin string
+	DeclaredValue   *float64 // nil when no value has been declared yet
+}
+
+// Description returns the goods description printed on the invoice
+func (i CommercialInvoiceItem) Description() string {
+	item := PackingSlipItem{Brand: i.Brand, Model: i.Model}
+	return fmt.Sprintf("%s %s, S/N %s", i.DeviceCategory.Label(), item.Description(), i.SerialNumber)
+}
+
+// HSCode returns the tariff code of the device's category
+func (i CommercialInvoiceItem) HSCode() string {
+	return i.DeviceCategory.HSCode()
+}
+
+// CommercialInvoice holds everything printed on the commercial invoice of an international shipment.
+// The exporter and consignee are the sender and recipient of the shipment's packing slip.
+type CommercialInvoice struct {
+	Shipment    Shipment
+	CompanyName string
+	Exporter    ShippingAddress
+	Consignee   ShippingAddress
+	Items       []CommercialInvoiceItem
+	Packages    []ShipmentPackage
+
+	GeneratedAt time.Time
+}
+
+// InvoiceNumber returns the invoice number, derived from the shipment
+func (c *CommercialInvoice) InvoiceNumber() string {
+	return fmt.Sprintf("CI-%d", c.Shipment.ID)
+}
+
+// Currency returns the currency of the declared values
+func (c *CommercialInvoice) Currency() string {
+	if c.Shipment.CustomsCurrency == "" {
+		return DefaultCustomsCurrency
+	}
+	return c.Shipment.CustomsCurrency
+}
+
+// TotalValue returns the sum of the declared values
+func (c *CommercialInvoice) TotalValue() float64 {
+	total := 0.0
+	for _, item := range c.Items {
+		if item.DeclaredValue != nil {
+			total += *item.DeclaredValue
+		}
+	}
+	return total
+}
+
+// TotalWeightLb returns the weight of the packages that have been weighed
+func (c *CommercialInvoice) TotalWeightLb() float64 {
+	total := 0.0
+	for _, p := range c.Packages {
+		total += p.WeightLb
+	}
+	return total
+}
+
+// MissingFields lists the customs details still to be filled in.
+// An invoice with missing fields can still be printed as a pro-forma draft.
+func (c *CommercialInvoice) MissingFields() []string {
+	missing := []string{}
+	if c.Shipment.ExportReason == "" {
+		missing = append(missing, "reason for export")
+	}
+	if c.Shipment.RecipientTaxID == "" {
+		missing = append(missing, "recipient tax ID")
+	}
+	if len(c.Items) == 0 {
+		missing = append(missing, "devices")
+	}
+	for _, item := range c.Items {
+		if item.DeclaredValue == nil {
+			missing = append(missing, fmt.Sprintf("declared value of %s", item.SerialNumber))
+		}
+		if item.CountryOfOrigin == "" {
+			missing = append(missing, fmt.Sprintf("country of origin of %s", item.SerialNumber))
+		}
+	}
+	return missing
+}
+
+// IsComplete returns true if every customs detail has been filled in
+func (c *CommercialInvoice) IsComplete() bool {
+	return len(c.MissingFields()) == 0
+}
+
+// CanHaveCommercialInvoice returns true if the shipment delivers to an engineer, the leg that can cross a border
+func CanHaveCommercialInvoice(shipmentType ShipmentType) bool {
+	return shipmentType == ShipmentTypeWarehouseToEngineer || shipmentType == ShipmentTypeSingleFullJourney
+}
+
+// GetCommercialInvoice loads the commercial invoice of a shipment.
+// The warehouse is the exporter and the engineer the consignee, as on the outbound packing slip.
+func GetCommercialInvoice(ctx context.Context, db *sql.DB, shipmentID int64, warehouse ShippingAddress) (*CommercialInvoice, error) {
+	slip, err := GetPackingSlip(ctx, db, shipmentID, warehouse)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := &CommercialInvoice{
+		Shipment:    slip.Shipment,
+		CompanyName: slip.CompanyName,
+		Exporter:    warehouse,
+		Consignee:   slip.To,
+		Packages:    slip.Packages,
+		GeneratedAt: slip.GeneratedAt,
+	}
+	if !slip.IsOutbound() {
+		// Prepared before the laptop reaches the warehouse: the packing slip still ships to the
+		// warehouse, so the consignee is the engineer the laptop is assigned to
+		invoice.Consignee = ShippingAddress{}
+		if slip.Shipment.SoftwareEngineerID != nil {
+			if engineer, err := GetSoftwareEngineerByID(db, *slip.Shipment.SoftwareEngineerID); err == nil {
+				invoice.Consignee = engineerShippingAddress(engineer)
+			}
+		}
+	}
+
+	s := &invoice.Shipment
+	var exportReason sql.NullString
+	err = db.QueryRowContext(ctx,
+		`SELECT export_reason, COALESCE(recipient_tax_id, ''), customs_currency FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(&exportReason, &s.RecipientTaxID, &s.CustomsCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment customs details: %w", err)
+	}
+	s.ExportReason = ExportReason(exportReason.String)
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT l.id, l.serial_number, COALESCE(l.brand, ''), l.model, l.device_category,
+		        COALESCE(l.country_of_origin, ''), l.declared_value
+		FROM shipment_laptops sl
+		JOIN laptops l ON l.id = sl.laptop_id
+		WHERE sl.shipment_id = $1
+		ORDER BY l.serial_number`,
+		shipmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment laptops: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item CommercialInvoiceItem
+		if err := rows.Scan(&item.LaptopID, &item.SerialNumber, &item.Brand, &item.Model, &item.DeviceCategory,
+			&item.CountryOfOrigin, &item.DeclaredValue); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment laptop: %w", err)
+		}
+		invoice.Items = append(invoice.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment laptops: %w", err)
+	}
+
+	return invoice, nil
+}
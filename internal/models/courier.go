@@ -4,16 +4,30 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// TrackingNumberPlaceholder is replaced by the tracking number in a courier's tracking URL template
+const TrackingNumberPlaceholder = "{tracking_number}"
+
+// ErrInvalidTrackingNumber is returned when a tracking number does not match its courier's format
+var ErrInvalidTrackingNumber = errors.New("invalid tracking number")
+
 // Courier represents a courier company in the system
 type Courier struct {
-	ID          int64     `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	ContactInfo string    `json:"contact_info,omitempty" db:"contact_info"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID                    int64     `json:"id" db:"id"`
+	Name                  string    `json:"name" db:"name"`
+	ContactInfo           string    `json:"contact_info,omitempty" db:"contact_info"`
+	TrackingURLTemplate   string    `json:"tracking_url_template,omitempty" db:"tracking_url_template"`     // e.g. https://example.com/track?n={tracking_number}
+	TrackingNumberPattern string    `json:"tracking_number_pattern,omitempty" db:"tracking_number_pattern"` // Regular expression a tracking number must fully match
+	ServiceLevels         []string  `json:"service_levels" db:"service_levels"`                             // e.g. Express, Ground
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
 }
 
 // Validate validates the Courier model
@@ -26,6 +40,36 @@ func (c *Courier) Validate() error {
 		return errors.New("courier name must be at least 2 characters")
 	}
 
+	// Tracking URL template validation
+	if c.TrackingURLTemplate != "" {
+		if !strings.HasPrefix(c.TrackingURLTemplate, "http://") && !strings.HasPrefix(c.TrackingURLTemplate, "https://") {
+			return errors.New("tracking URL template must start with http:// or https://")
+		}
+		if !strings.Contains(c.TrackingURLTemplate, TrackingNumberPlaceholder) {
+			return fmt.Errorf("tracking URL template must contain the %s placeholder", TrackingNumberPlaceholder)
+		}
+	}
+
+	// Tracking number pattern validation
+	if c.TrackingNumberPattern != "" {
+		if _, err := regexp.Compile(c.TrackingNumberPattern); err != nil {
+			return fmt.Errorf("invalid tracking number pattern: %w", err)
+		}
+	}
+
+	// Service level validation
+	seen := map[string]bool{}
+	for _, level := range c.ServiceLevels {
+		key := strings.ToLower(strings.TrimSpace(level))
+		if key == "" {
+			return errors.New("service levels cannot be empty")
+		}
+		if seen[key] {
+			return fmt.Errorf("duplicate service level %q", level)
+		}
+		seen[key] = true
+	}
+
 	return nil
 }
 
@@ -39,17 +83,204 @@ func (c *Courier) BeforeCreate() {
 	now := time.Now()
 	c.CreatedAt = now
 	c.UpdatedAt = now
+	c.normalize()
 }
 
 // BeforeUpdate sets the updated_at timestamp before updating a courier
 func (c *Courier) BeforeUpdate() {
 	c.UpdatedAt = time.Now()
+	c.normalize()
+}
+
+func (c *Courier) normalize() {
+	c.Name = strings.TrimSpace(c.Name)
+	c.TrackingURLTemplate = strings.TrimSpace(c.TrackingURLTemplate)
+	c.TrackingNumberPattern = strings.TrimSpace(c.TrackingNumberPattern)
+	c.ServiceLevels = ParseServiceLevels(strings.Join(c.ServiceLevels, ","))
+}
+
+// ParseServiceLevels splits a comma-separated list of service levels, dropping blanks
+func ParseServiceLevels(raw string) []string {
+	levels := []string{}
+	for _, level := range strings.Split(raw, ",") {
+		if level = strings.TrimSpace(level); level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// ServiceLevelsLabel returns the service levels as a comma-separated list
+func (c Courier) ServiceLevelsLabel() string {
+	return strings.Join(c.ServiceLevels, ", ")
+}
+
+// ServiceNames returns the courier name combined with each of its service levels, e.g. "UPS Ground"
+func (c Courier) ServiceNames() []string {
+	names := make([]string, 0, len(c.ServiceLevels))
+	for _, level := range c.ServiceLevels {
+		names = append(names, c.Name+" "+level)
+	}
+	return names
+}
+
+// TrackingURL returns the courier's tracking link for a tracking number.
+// Returns an empty string if the courier has no tracking URL template.
+func (c Courier) TrackingURL(trackingNumber string) string {
+	if c.TrackingURLTemplate == "" {
+		return ""
+	}
+	return strings.ReplaceAll(c.TrackingURLTemplate, TrackingNumberPlaceholder, url.QueryEscape(strings.TrimSpace(trackingNumber)))
+}
+
+// ValidateTrackingNumber checks a tracking number against the courier's tracking number pattern.
+// Any tracking number is accepted when the courier has no pattern.
+func (c Courier) ValidateTrackingNumber(trackingNumber string) error {
+	if c.TrackingNumberPattern == "" {
+		return nil
+	}
+	pattern, err := regexp.Compile(`^(?:` + c.TrackingNumberPattern + `)$`)
+	if err != nil {
+		return fmt.Errorf("invalid tracking number pattern for %s: %w", c.Name, err)
+	}
+	if !pattern.MatchString(strings.TrimSpace(trackingNumber)) {
+		return fmt.Errorf("%w: %q is not a valid %s tracking number", ErrInvalidTrackingNumber, strings.TrimSpace(trackingNumber), c.Name)
+	}
+	return nil
+}
+
+// namedExactly returns true if a courier name is this courier or one of its service names
+func (c Courier) namedExactly(courierName string) bool {
+	if strings.EqualFold(c.Name, courierName) {
+		return true
+	}
+	for _, name := range c.ServiceNames() {
+		if strings.EqualFold(name, courierName) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultCouriers returns the built-in courier records.
+// They match the couriers seeded by the migrations and are used when no database is at hand
+// or when a courier is missing from the database.
+func DefaultCouriers() []Courier {
+	return []Courier{
+		{
+			Name:                CourierUPS,
+			TrackingURLTemplate: "https://www.ups.com/track?tracknum=" + TrackingNumberPlaceholder,
+			ServiceLevels:       []string{"Ground", "Express", "Next Day Air"},
+		},
+		{
+			Name:                CourierFedEx,
+			TrackingURLTemplate: "https://www.fedex.com/fedextrack/?tracknumbers=" + TrackingNumberPlaceholder,
+			ServiceLevels:       []string{"Ground", "Express", "International Priority"},
+		},
+		{
+			Name:                CourierDHL,
+			TrackingURLTemplate: "http://www.dhl.com/en/express/tracking.html?AWB=" + TrackingNumberPlaceholder,
+			ServiceLevels:       []string{"Express", "Economy Select"},
+		},
+	}
+}
+
+// CourierDirectory resolves the courier names stored on shipments and packages to courier records
+type CourierDirectory []Courier
+
+// DefaultCourierDirectory returns a directory of the built-in couriers
+func DefaultCourierDirectory() CourierDirectory {
+	return CourierDirectory(DefaultCouriers())
+}
+
+// LoadCourierDirectory returns the couriers in the database, followed by the built-in couriers
+// the database does not have
+func LoadCourierDirectory(db interface{ Query(query string, args ...interface{}) (*sql.Rows, error) }) (CourierDirectory, error) {
+	couriers, err := GetAllCouriers(db)
+	if err != nil {
+		return nil, err
+	}
+	directory := CourierDirectory(couriers)
+	for _, c := range DefaultCouriers() {
+		if directory.findExact(c.Name) == nil {
+			directory = append(directory, c)
+		}
+	}
+	return directory, nil
+}
+
+func (d CourierDirectory) findExact(courierName string) *Courier {
+	for i := range d {
+		if d[i].namedExactly(courierName) {
+			return &d[i]
+		}
+	}
+	return nil
+}
+
+// Find returns the courier record for a courier name, or nil if none matches.
+// The name matches a courier or one of its service names exactly (case-insensitive);
+// otherwise the courier with the longest name contained in it is used, so free-form
+// names like "UPS Next Day Air Saver" still resolve.
+func (d CourierDirectory) Find(courierName string) *Courier {
+	courierName = strings.TrimSpace(courierName)
+	if courierName == "" {
+		return nil
+	}
+	if c := d.findExact(courierName); c != nil {
+		return c
+	}
+
+	courierLower := strings.ToLower(courierName)
+	var best *Courier
+	for i := range d {
+		if strings.Contains(courierLower, strings.ToLower(d[i].Name)) && (best == nil || len(d[i].Name) > len(best.Name)) {
+			best = &d[i]
+		}
+	}
+	return best
+}
+
+// IsValidName returns true if a courier name is a courier or one of its service names
+func (d CourierDirectory) IsValidName(courierName string) bool {
+	return d.findExact(strings.TrimSpace(courierName)) != nil
+}
+
+// TrackingURL returns the tracking link for a tracking number of the named courier.
+// Returns an empty string if the courier is not recognized or has no tracking URL template.
+func (d CourierDirectory) TrackingURL(courierName, trackingNumber string) string {
+	c := d.Find(courierName)
+	if c == nil {
+		return ""
+	}
+	return c.TrackingURL(trackingNumber)
+}
+
+// ValidateTrackingNumber checks a tracking number against the named courier's format.
+// Couriers that are not recognized impose no format.
+func (d CourierDirectory) ValidateTrackingNumber(courierName, trackingNumber string) error {
+	c := d.Find(courierName)
+	if c == nil {
+		return nil
+	}
+	return c.ValidateTrackingNumber(trackingNumber)
+}
+
+// ServiceNames returns every courier and service name a shipment can be assigned, in directory order
+func (d CourierDirectory) ServiceNames() []string {
+	names := []string{}
+	for _, c := range d {
+		names = append(names, c.Name)
+		names = append(names, c.ServiceNames()...)
+	}
+	return names
 }
 
 // GetAllCouriers retrieves all couriers from the database
 func GetAllCouriers(db interface{ Query(query string, args ...interface{}) (*sql.Rows, error) }) ([]Courier, error) {
 	query := `
-		SELECT id, name, contact_info, created_at, updated_at
+		SELECT id, name, COALESCE(contact_info, ''), COALESCE(tracking_url_template, ''),
+		       COALESCE(tracking_number_pattern, ''), service_levels, created_at, updated_at
 		FROM couriers
 		ORDER BY name ASC
 	`
@@ -67,6 +298,9 @@ func GetAllCouriers(db interface{ Query(query string, args ...interface{}) (*sql
 			&courier.ID,
 			&courier.Name,
 			&courier.ContactInfo,
+			&courier.TrackingURLTemplate,
+			&courier.TrackingNumberPattern,
+			pq.Array(&courier.ServiceLevels),
 			&courier.CreatedAt,
 			&courier.UpdatedAt,
 		)
@@ -86,7 +320,8 @@ func GetAllCouriers(db interface{ Query(query string, args ...interface{}) (*sql
 // GetCourierByID retrieves a courier by its ID
 func GetCourierByID(db *sql.DB, id int64) (*Courier, error) {
 	query := `
-		SELECT id, name, contact_info, created_at, updated_at
+		SELECT id, name, COALESCE(contact_info, ''), COALESCE(tracking_url_template, ''),
+		       COALESCE(tracking_number_pattern, ''), service_levels, created_at, updated_at
 		FROM couriers
 		WHERE id = $1
 	`
@@ -96,12 +331,15 @@ func GetCourierByID(db *sql.DB, id int64) (*Courier, error) {
 		&courier.ID,
 		&courier.Name,
 		&courier.ContactInfo,
+		&courier.TrackingURLTemplate,
+		&courier.TrackingNumberPattern,
+		pq.Array(&courier.ServiceLevels),
 		&courier.CreatedAt,
 		&courier.UpdatedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("courier not found with id %d", id)
+		return nil, &NotFoundError{Entity: "courier", ID: id}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get courier: %w", err)
@@ -112,17 +350,17 @@ func GetCourierByID(db *sql.DB, id int64) (*Courier, error) {
 
 // CreateCourier creates a new courier in the database
 func CreateCourier(db *sql.DB, courier *Courier) error {
+	// Set timestamps
+	courier.BeforeCreate()
+
 	// Validate courier
 	if err := courier.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	// Set timestamps
-	courier.BeforeCreate()
-
 	query := `
-		INSERT INTO couriers (name, contact_info, created_at, updated_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO couriers (name, contact_info, tracking_url_template, tracking_number_pattern, service_levels, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 		RETURNING id
 	`
 
@@ -130,6 +368,9 @@ func CreateCourier(db *sql.DB, courier *Courier) error {
 		query,
 		courier.Name,
 		courier.ContactInfo,
+		nullableText(courier.TrackingURLTemplate),
+		nullableText(courier.TrackingNumberPattern),
+		pq.Array(courier.ServiceLevels),
 		courier.CreatedAt,
 		courier.UpdatedAt,
 	).Scan(&courier.ID)
@@ -143,24 +384,28 @@ func CreateCourier(db *sql.DB, courier *Courier) error {
 
 // UpdateCourier updates an existing courier in the database
 func UpdateCourier(db *sql.DB, courier *Courier) error {
+	// Update timestamp
+	courier.BeforeUpdate()
+
 	// Validate courier
 	if err := courier.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	// Update timestamp
-	courier.BeforeUpdate()
-
 	query := `
 		UPDATE couriers
-		SET name = $1, contact_info = $2, updated_at = $3
-		WHERE id = $4
+		SET name = $1, contact_info = $2, tracking_url_template = $3, tracking_number_pattern = $4,
+		    service_levels = $5, updated_at = $6
+		WHERE id = $7
 	`
 
 	result, err := db.Exec(
 		query,
 		courier.Name,
 		courier.ContactInfo,
+		nullableText(courier.TrackingURLTemplate),
+		nullableText(courier.TrackingNumberPattern),
+		pq.Array(courier.ServiceLevels),
 		courier.UpdatedAt,
 		courier.ID,
 	)
@@ -175,7 +420,7 @@ func UpdateCourier(db *sql.DB, courier *Courier) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("courier not found with id %d", courier.ID)
+		return &NotFoundError{Entity: "courier", ID: courier.ID}
 	}
 
 	return nil
@@ -196,7 +441,7 @@ func DeleteCourier(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("courier not found with id %d", id)
+		return &NotFoundError{Entity: "courier", ID: id}
 	}
 
 	return nil
@@ -215,24 +460,13 @@ func CourierExistsByName(db interface{ QueryRow(query string, args ...interface{
 	return exists, nil
 }
 
-// IsValidCourierName checks if a courier name is valid by checking the database first,
-// then falling back to hardcoded values (UPS, FedEx, DHL) for backward compatibility
-func IsValidCourierName(db interface{ QueryRow(query string, args ...interface{}) *sql.Row }, name string) (bool, error) {
-	// First check database
-	exists, err := CourierExistsByName(db, name)
+// IsValidCourierName checks if a courier name is valid: a courier in the database or one of
+// its service names (e.g. "UPS Ground"), falling back to the built-in couriers for backward compatibility
+func IsValidCourierName(db interface{ Query(query string, args ...interface{}) (*sql.Rows, error) }, name string) (bool, error) {
+	directory, err := LoadCourierDirectory(db)
 	if err != nil {
 		return false, err
 	}
-	if exists {
-		return true, nil
-	}
-	
-	// Fall back to hardcoded values for backward compatibility
-	switch name {
-	case "UPS", "FedEx", "DHL":
-		return true, nil
-	}
-	
-	return false, nil
+	return directory.IsValidName(name), nil
 }
 
@@ -0,0 +1,154 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCourier_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		courier Courier
+		wantErr bool
+	}{
+		{"name only", Courier{Name: "UPS"}, false},
+		{"full record", Courier{
+			Name:                  "UPS",
+			TrackingURLTemplate:   "https://www.ups.com/track?tracknum={tracking_number}",
+			TrackingNumberPattern: `1Z[0-9A-Z]{16}`,
+			ServiceLevels:         []string{"Ground", "Express"},
+		}, false},
+		{"missing name", Courier{}, true},
+		{"short name", Courier{Name: "U"}, true},
+		{"template without placeholder", Courier{Name: "UPS", TrackingURLTemplate: "https://www.ups.com/track"}, true},
+		{"template without scheme", Courier{Name: "UPS", TrackingURLTemplate: "www.ups.com/track?n={tracking_number}"}, true},
+		{"invalid pattern", Courier{Name: "UPS", TrackingNumberPattern: "1Z[0-9"}, true},
+		{"blank service level", Courier{Name: "UPS", ServiceLevels: []string{"Ground", " "}}, true},
+		{"duplicate service level", Courier{Name: "UPS", ServiceLevels: []string{"Ground", "ground"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.courier.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCourier_TrackingURL(t *testing.T) {
+	c := Courier{Name: "Local Van", TrackingURLTemplate: "https://track.example.com/{tracking_number}?ref=lts"}
+	if got := c.TrackingURL(" AB 12 "); got != "https://track.example.com/AB+12?ref=lts" {
+		t.Errorf("TrackingURL() = %q", got)
+	}
+
+	if got := (Courier{Name: "Local Van"}).TrackingURL("AB12"); got != "" {
+		t.Errorf("Expected no link without a template, got %q", got)
+	}
+}
+
+func TestCourier_ValidateTrackingNumber(t *testing.T) {
+	c := Courier{Name: "UPS", TrackingNumberPattern: `1Z[0-9A-Z]{16}`}
+
+	if err := c.ValidateTrackingNumber("1Z999AA10123456784"); err != nil {
+		t.Errorf("Expected valid tracking number, got %v", err)
+	}
+	// The pattern must match the whole tracking number
+	if err := c.ValidateTrackingNumber("X1Z999AA10123456784"); !errors.Is(err, ErrInvalidTrackingNumber) {
+		t.Errorf("Expected ErrInvalidTrackingNumber, got %v", err)
+	}
+	if err := c.ValidateTrackingNumber("1Z999"); !errors.Is(err, ErrInvalidTrackingNumber) {
+		t.Errorf("Expected ErrInvalidTrackingNumber, got %v", err)
+	}
+	if err := (Courier{Name: "DHL"}).ValidateTrackingNumber("anything"); err != nil {
+		t.Errorf("Expected any tracking number without a pattern, got %v", err)
+	}
+}
+
+func TestCourier_BeforeCreateNormalizesServiceLevels(t *testing.T) {
+	c := Courier{Name: " UPS ", ServiceLevels: []string{" Ground ", "", "Express"}}
+	c.BeforeCreate()
+
+	if c.Name != "UPS" {
+		t.Errorf("Expected trimmed name, got %q", c.Name)
+	}
+	if !reflect.DeepEqual(c.ServiceLevels, []string{"Ground", "Express"}) {
+		t.Errorf("Expected normalized service levels, got %v", c.ServiceLevels)
+	}
+	if got := c.ServiceNames(); !reflect.DeepEqual(got, []string{"UPS Ground", "UPS Express"}) {
+		t.Errorf("ServiceNames() = %v", got)
+	}
+}
+
+func TestParseServiceLevels(t *testing.T) {
+	if got := ParseServiceLevels("Express, Ground,, Next Day Air "); !reflect.DeepEqual(got, []string{"Express", "Ground", "Next Day Air"}) {
+		t.Errorf("ParseServiceLevels() = %v", got)
+	}
+	if got := ParseServiceLevels(""); len(got) != 0 {
+		t.Errorf("Expected no service levels, got %v", got)
+	}
+}
+
+func TestCourierDirectory_Find(t *testing.T) {
+	directory := CourierDirectory{
+		{Name: "Post", TrackingURLTemplate: "https://post.example.com/{tracking_number}", ServiceLevels: []string{"Priority"}},
+		{Name: "Post Express", TrackingURLTemplate: "https://express.example.com/{tracking_number}"},
+	}
+
+	tests := []struct {
+		courierName string
+		want        string
+	}{
+		{"Post", "Post"},
+		{"post priority", "Post"},
+		{"Post Express", "Post Express"},
+		{"Post Express Overnight", "Post Express"}, // Longest contained name wins
+		{"Courier", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.courierName, func(t *testing.T) {
+			got := directory.Find(tt.courierName)
+			if tt.want == "" {
+				if got != nil {
+					t.Errorf("Find(%q) = %q, want nil", tt.courierName, got.Name)
+				}
+				return
+			}
+			if got == nil || got.Name != tt.want {
+				t.Errorf("Find(%q) = %v, want %q", tt.courierName, got, tt.want)
+			}
+		})
+	}
+
+	if !directory.IsValidName("Post Priority") {
+		t.Error("Expected service name to be a valid courier name")
+	}
+	if directory.IsValidName("Post Overnight") {
+		t.Error("Expected unknown service name to be invalid")
+	}
+	if got := directory.TrackingURL("Post Priority", "P1"); got != "https://post.example.com/P1" {
+		t.Errorf("TrackingURL() = %q", got)
+	}
+}
+
+func TestDefaultCourierDirectory(t *testing.T) {
+	directory := DefaultCourierDirectory()
+
+	for _, c := range directory {
+		if err := c.Validate(); err != nil {
+			t.Errorf("Built-in courier %s is invalid: %v", c.Name, err)
+		}
+	}
+	for _, name := range []string{"UPS", "FedEx", "DHL", "UPS Ground", "FedEx Express", "DHL Express"} {
+		if !IsValidCourier(name) {
+			t.Errorf("Expected %q to be a valid courier", name)
+		}
+	}
+	if IsValidCourier("Unknown Courier") {
+		t.Error("Expected unknown courier to be invalid")
+	}
+}
@@ -0,0 +1,188 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DeviceCategory is the kind of device, which determines its customs tariff (HS) code
+type DeviceCategory string
+
+// Device category constants
+const (
+	DeviceCategoryLaptop         DeviceCategory = "laptop"
+	DeviceCategoryTablet         DeviceCategory = "tablet"
+	DeviceCategoryDesktop        DeviceCategory = "desktop"
+	DeviceCategoryMonitor        DeviceCategory = "monitor"
+	DeviceCategoryDockingStation DeviceCategory = "docking_station"
+	DeviceCategoryPowerAdapter   DeviceCategory = "power_adapter"
+)
+
+// deviceCategoryHSCodes maps each device category to its Harmonized System code (6 digits, shared by all countries)
+var deviceCategoryHSCodes = map[DeviceCategory]string{
+	DeviceCategoryLaptop:         "8471.30",
+	DeviceCategoryTablet:         "8471.30",
+	DeviceCategoryDesktop:        "8471.41",
+	DeviceCategoryMonitor:        "8528.52",
+	DeviceCategoryDockingStation: "8473.30",
+	DeviceCategoryPowerAdapter:   "8504.40",
+}
+
+// GetDeviceCategories returns all device categories in display order
+func GetDeviceCategories() []DeviceCategory {
+	return []DeviceCategory{
+		DeviceCategoryLaptop,
+		DeviceCategoryTablet,
+		DeviceCategoryDesktop,
+		DeviceCategoryMonitor,
+		DeviceCategoryDockingStation,
+		DeviceCategoryPowerAdapter,
+	}
+}
+
+// IsValidDeviceCategory checks if a given device category is valid
+func IsValidDeviceCategory(category DeviceCategory) bool {
+	_, ok := deviceCategoryHSCodes[category]
+	return ok
+}
+
+// HSCode returns the Harmonized System code declared for the category, or "" if unknown
+func (c DeviceCategory) HSCode() string {
+	return deviceCategoryHSCodes[c]
+}
+
+// Label returns the human-readable name of the category
+func (c DeviceCategory) Label() string {
+	switch c {
+	case DeviceCategoryDockingStation:
+		return "Docking Station"
+	case DeviceCategoryPowerAdapter:
+		return "Power Adapter"
+	}
+	if c == "" {
+		return ""
+	}
+	return strings.ToUpper(string(c[:1])) + string(c[1:])
+}
+
+// ExportReason is the reason for export declared on a commercial invoice
+type ExportReason string
+
+// Export reason constants
+const (
+	ExportReasonCompanyEquipment ExportReason = "company_equipment"
+	ExportReasonSale             ExportReason = "sale"
+	ExportReasonGift             ExportReason = "gift"
+	ExportReasonRepair           ExportReason = "repair"
+	ExportReasonReturn           ExportReason = "return"
+	ExportReasonTemporary        ExportReason = "temporary_export"
+)
+
+// GetExportReasons returns all export reasons in display order
+func GetExportReasons() []ExportReason {
+	return []ExportReason{
+		ExportReasonCompanyEquipment,
+		ExportReasonSale,
+		ExportReasonGift,
+		ExportReasonRepair,
+		ExportReasonReturn,
+		ExportReasonTemporary,
+	}
+}
+
+// IsValidExportReason checks if a given export reason is valid
+func IsValidExportReason(reason ExportReason) bool {
+	for _, r := range GetExportReasons() {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
+
+// Label returns the wording printed on the commercial invoice
+func (r ExportReason) Label() string {
+	switch r {
+	case ExportReasonCompanyEquipment:
+		return "Company equipment for employee use - not for resale"
+	case ExportReasonSale:
+		return "Sale"
+	case ExportReasonGift:
+		return "Gift"
+	case ExportReasonRepair:
+		return "Repair"
+	case ExportReasonReturn:
+		return "Return"
+	case ExportReasonTemporary:
+		return "Temporary export"
+	}
+	return string(r)
+}
+
+// DefaultCustomsCurrency is the currency of declared values unless the shipment says otherwise
+const DefaultCustomsCurrency = "USD"
+
+var currencyCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ParseDeclaredValue parses a declared value from a form; an empty value means not declared
+func ParseDeclaredValue(raw string) (*float64, error) {
+	raw = strings.TrimSpace(strings.ReplaceAll(raw, ",", ""))
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, errors.New("declared value must be a number")
+	}
+	if value < 0 {
+		return nil, errors.New("declared value cannot be negative")
+	}
+	return &value, nil
+}
+
+// ValidateCustoms validates the customs details of a shipment
+func (s *Shipment) ValidateCustoms() error {
+	if s.ExportReason != "" && !IsValidExportReason(s.ExportReason) {
+		return errors.New("invalid reason for export")
+	}
+	if s.CustomsCurrency != "" && !currencyCodePattern.MatchString(s.CustomsCurrency) {
+		return errors.New("currency must be a 3-letter ISO code, e.g. USD")
+	}
+	if len(s.RecipientTaxID) > 100 {
+		return errors.New("recipient tax ID must be 100 characters or less")
+	}
+	return nil
+}
+
+// NormalizeCustoms trims the customs details and applies the default currency
+func (s *Shipment) NormalizeCustoms() {
+	s.RecipientTaxID = strings.TrimSpace(s.RecipientTaxID)
+	s.CustomsCurrency = strings.ToUpper(strings.TrimSpace(s.CustomsCurrency))
+	if s.CustomsCurrency == "" {
+		s.CustomsCurrency = DefaultCustomsCurrency
+	}
+}
+
+// UpdateShipmentCustoms saves the customs details of a shipment
+func UpdateShipmentCustoms(ctx context.Context, db *sql.DB, s *Shipment) error {
+	s.NormalizeCustoms()
+	if err := s.ValidateCustoms(); err != nil {
+		return err
+	}
+
+	s.BeforeUpdate()
+	_, err := db.ExecContext(ctx,
+		`UPDATE shipments SET export_reason = NULLIF($1, ''), recipient_tax_id = NULLIF($2, ''), customs_currency = $3, updated_at = $4
+		WHERE id = $5`,
+		s.ExportReason, s.RecipientTaxID, s.CustomsCurrency, s.UpdatedAt, s.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment customs details: %w", err)
+	}
+	return nil
+}
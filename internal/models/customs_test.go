@@ -0,0 +1,164 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDeviceCategory_HSCode(t *testing.T) {
+	for _, category := range GetDeviceCategories() {
+		if !IsValidDeviceCategory(category) {
+			t.Errorf("Expected %q to be valid", category)
+		}
+		if category.HSCode() == "" {
+			t.Errorf("Expected an HS code for %q", category)
+		}
+		if category.Label() == "" {
+			t.Errorf("Expected a label for %q", category)
+		}
+	}
+
+	if DeviceCategoryLaptop.HSCode() != "8471.30" {
+		t.Errorf("Unexpected laptop HS code %q", DeviceCategoryLaptop.HSCode())
+	}
+	if IsValidDeviceCategory("phone") || DeviceCategory("phone").HSCode() != "" {
+		t.Error("Expected unknown categories to be invalid")
+	}
+	if DeviceCategoryDockingStation.Label() != "Docking Station" || DeviceCategoryLaptop.Label() != "Laptop" {
+		t.Error("Unexpected category labels")
+	}
+}
+
+func TestExportReasons(t *testing.T) {
+	for _, reason := range GetExportReasons() {
+		if !IsValidExportReason(reason) {
+			t.Errorf("Expected %q to be valid", reason)
+		}
+		if reason.Label() == string(reason) {
+			t.Errorf("Expected a printed label for %q", reason)
+		}
+	}
+	if IsValidExportReason("smuggling") {
+		t.Error("Expected unknown reasons to be invalid")
+	}
+}
+
+func TestParseDeclaredValue(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    *float64
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"  ", nil, false},
+		{"1299.99", floatPtr(1299.99), false},
+		{"1,299.99", floatPtr(1299.99), false},
+		{"0", floatPtr(0), false},
+		{"-5", nil, true},
+		{"abc", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDeclaredValue(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDeclaredValue(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseDeclaredValue(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestShipment_ValidateCustoms(t *testing.T) {
+	tests := []struct {
+		name     string
+		shipment Shipment
+		wantErr  bool
+	}{
+		{"empty", Shipment{}, false},
+		{"complete", Shipment{ExportReason: ExportReasonGift, RecipientTaxID: "ABC123", CustomsCurrency: "EUR"}, false},
+		{"invalid reason", Shipment{ExportReason: "other"}, true},
+		{"lowercase currency", Shipment{CustomsCurrency: "usd"}, true},
+		{"long currency", Shipment{CustomsCurrency: "DOLLAR"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.shipment.ValidateCustoms(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCustoms() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShipment_NormalizeCustoms(t *testing.T) {
+	s := Shipment{RecipientTaxID: "  RFC123 ", CustomsCurrency: " eur "}
+	s.NormalizeCustoms()
+	if s.RecipientTaxID != "RFC123" || s.CustomsCurrency != "EUR" {
+		t.Errorf("Unexpected customs details %q %q", s.RecipientTaxID, s.CustomsCurrency)
+	}
+
+	s = Shipment{}
+	s.NormalizeCustoms()
+	if s.CustomsCurrency != DefaultCustomsCurrency {
+		t.Errorf("Expected the default currency, got %q", s.CustomsCurrency)
+	}
+}
+
+func TestCommercialInvoice(t *testing.T) {
+	invoice := &CommercialInvoice{
+		Shipment: Shipment{ID: 42, ExportReason: ExportReasonCompanyEquipment, RecipientTaxID: "X1"},
+		Items: []CommercialInvoiceItem{
+			{SerialNumber: "SN1", Brand: "Dell", Model: "XPS 13", DeviceCategory: DeviceCategoryLaptop, CountryOfOrigin: "China", DeclaredValue: floatPtr(1000)},
+			{SerialNumber: "SN2", Brand: "Dell", Model: "WD19", DeviceCategory: DeviceCategoryDockingStation, CountryOfOrigin: "Mexico", DeclaredValue: floatPtr(150.5)},
+		},
+		Packages: []ShipmentPackage{{WeightLb: 5}, {WeightLb: 2.5}, {}},
+	}
+
+	if invoice.InvoiceNumber() != "CI-42" {
+		t.Errorf("InvoiceNumber() = %q", invoice.InvoiceNumber())
+	}
+	if invoice.Currency() != DefaultCustomsCurrency {
+		t.Errorf("Expected the default currency, got %q", invoice.Currency())
+	}
+	if invoice.TotalValue() != 1150.5 {
+		t.Errorf("TotalValue() = %v", invoice.TotalValue())
+	}
+	if invoice.TotalWeightLb() != 7.5 {
+		t.Errorf("TotalWeightLb() = %v", invoice.TotalWeightLb())
+	}
+	if !invoice.IsComplete() {
+		t.Errorf("Expected a complete invoice, missing %v", invoice.MissingFields())
+	}
+	if got := invoice.Items[1].Description(); got != "Docking Station Dell WD19, S/N SN2" {
+		t.Errorf("Description() = %q", got)
+	}
+	if invoice.Items[1].HSCode() != "8473.30" {
+		t.Errorf("HSCode() = %q", invoice.Items[1].HSCode())
+	}
+
+	invoice.Shipment.RecipientTaxID = ""
+	invoice.Items[0].DeclaredValue = nil
+	invoice.Items[1].CountryOfOrigin = ""
+	want := []string{"recipient tax ID", "declared value of SN1", "country of origin of SN2"}
+	if got := invoice.MissingFields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("MissingFields() = %v, want %v", got, want)
+	}
+	if invoice.TotalValue() != 150.5 {
+		t.Errorf("Expected undeclared values to count as zero, got %v", invoice.TotalValue())
+	}
+}
+
+func TestCanHaveCommercialInvoice(t *testing.T) {
+	if !CanHaveCommercialInvoice(ShipmentTypeWarehouseToEngineer) || !CanHaveCommercialInvoice(ShipmentTypeSingleFullJourney) {
+		t.Error("Expected deliveries to engineers to have commercial invoices")
+	}
+	if CanHaveCommercialInvoice(ShipmentTypeBulkToWarehouse) || CanHaveCommercialInvoice(ShipmentTypeEngineerToWarehouse) {
+		t.Error("Expected shipments to the warehouse not to have commercial invoices")
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -11,6 +11,7 @@ type DashboardStats struct {
 	PendingPickups         int                      `json:"pending_pickups"`
 	InTransit              int                      `json:"in_transit"`
 	Delivered              int                      `json:"delivered"`
+	Exceptions             int                      `json:"exceptions"`
 	AvgDeliveryDays        float64                  `json:"avg_delivery_days"`
 	ShipmentsByStatus      map[ShipmentStatus]int   `json:"shipments_by_status"`
 	LaptopsByStatus        map[LaptopStatus]int     `json:"laptops_by_status"`
@@ -48,6 +49,16 @@ func GetShipmentCountsByStatus(db *sql.DB) (map[ShipmentStatus]int, error) {
 	return counts, nil
 }
 
+// CountExceptionShipments returns the number of shipments in any exception status
+// from a map of shipment counts by status
+func CountExceptionShipments(countsByStatus map[ShipmentStatus]int) int {
+	total := 0
+	for _, status := range GetExceptionStatuses() {
+		total += countsByStatus[status]
+	}
+	return total
+}
+
 // GetTotalShipmentCount returns the total count of all shipments
 func GetTotalShipmentCount(db *sql.DB) (int, error) {
 	var count int
@@ -196,6 +207,7 @@ func GetDashboardStats(db *sql.DB) (*DashboardStats, error) {
 	}
 	stats.ShipmentsByStatus = shipmentsByStatus
 	stats.Delivered = shipmentsByStatus[ShipmentStatusDelivered]
+	stats.Exceptions = CountExceptionShipments(shipmentsByStatus)
 
 	// Get average delivery time
 	avgDeliveryDays, err := GetAverageDeliveryTime(db)
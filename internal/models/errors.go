@@ -0,0 +1,25 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound matches every NotFoundError, use errors.Is(err, ErrNotFound) to check for a missing record
+var ErrNotFound = errors.New("record not found")
+
+// NotFoundError is returned when a record looked up by ID does not exist
+type NotFoundError struct {
+	Entity string // e.g. "laptop", "client company"
+	ID     int64
+}
+
+// Error returns a message such as "laptop not found with id 42"
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s not found with id %d", e.Entity, e.ID)
+}
+
+// Is makes errors.Is(err, ErrNotFound) true for any NotFoundError
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
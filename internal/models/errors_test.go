@@ -0,0 +1,24 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNotFoundError(t *testing.T) {
+	err := error(&NotFoundError{Entity: "laptop", ID: 42})
+
+	if err.Error() != "laptop not found with id 42" {
+		t.Errorf("Unexpected message %q", err.Error())
+	}
+	if !errors.Is(err, ErrNotFound) {
+		t.Error("Expected NotFoundError to match ErrNotFound")
+	}
+	if !errors.Is(fmt.Errorf("loading: %w", err), ErrNotFound) {
+		t.Error("Expected a wrapped NotFoundError to match ErrNotFound")
+	}
+	if errors.Is(errors.New("laptop not found with id 42"), ErrNotFound) {
+		t.Error("Expected other errors not to match ErrNotFound")
+	}
+}
@@ -8,15 +8,16 @@ import (
 
 // LaptopFilter represents filtering options for laptop queries
 type LaptopFilter struct {
-	Status          LaptopStatus
-	Brand           string
-	Search          string
-	Limit           int
-	Offset          int
-	UserRole        UserRole // Filter laptops based on user role permissions
-	ClientCompanyID *int64   // Filter laptops by client company (for client role)
-	SortBy          string   // Column to sort by (e.g., "serial_number", "brand", "status", "client_company")
-	SortOrder       string   // Sort order: "asc" or "desc"
+	Status           LaptopStatus
+	Brand            string
+	Search           string
+	Limit            int
+	Offset           int
+	UserRole         UserRole // Filter laptops based on user role permissions
+	ClientCompanyID  *int64   // Filter laptops by client company (for client role)
+	ClientCompanyIDs []int64  // Filter laptops by assigned client companies (for project manager role)
+	SortBy           string   // Column to sort by (e.g., "serial_number", "brand", "status", "client_company")
+	SortOrder        string   // Sort order: "asc" or "desc"
 }
 
 // GetAllLaptops retrieves all laptops with optional filtering
@@ -33,7 +34,12 @@ func GetAllLaptops(db *sql.DB, filter *LaptopFilter) ([]Laptop, error) {
 		FROM laptops l
 		LEFT JOIN client_companies cc ON cc.id = l.client_company_id
 		LEFT JOIN software_engineers se ON se.id = l.software_engineer_id
-		LEFT JOIN reception_reports rr ON rr.laptop_id = l.id
+		LEFT JOIN LATERAL (
+			SELECT id, status FROM reception_reports
+			WHERE laptop_id = l.id
+			ORDER BY received_at DESC, id DESC
+			LIMIT 1
+		) rr ON true
 	`
 
 	var conditions []string
@@ -55,6 +61,15 @@ func GetAllLaptops(db *sql.DB, filter *LaptopFilter) ([]Laptop, error) {
 			args = append(args, *filter.ClientCompanyID)
 		}
 
+		// Role-based filtering: Project managers only see laptops of their assigned companies
+		if filter.UserRole == RoleProjectManager {
+			scope := CompanyScope{CompanyIDs: filter.ClientCompanyIDs}
+			condition, scopeArgs := scope.Condition("l.client_company_id", argCount+1)
+			conditions = append(conditions, condition)
+			args = append(args, scopeArgs...)
+			argCount += len(scopeArgs)
+		}
+
 		if filter.Status != "" {
 			argCount++
 			conditions = append(conditions, fmt.Sprintf("l.status = $%d", argCount))
@@ -222,7 +237,8 @@ func GetLaptopByID(db *sql.DB, id int64) (*Laptop, error) {
 			l.client_company_id, l.software_engineer_id, l.created_at, l.updated_at,
 			cc.name as client_company_name,
 			se.name as software_engineer_name,
-			se.employee_number as employee_id
+			se.employee_number as employee_id,
+			l.device_category, l.declared_value, COALESCE(l.country_of_origin, '')
 		FROM laptops l
 		LEFT JOIN client_companies cc ON cc.id = l.client_company_id
 		LEFT JOIN software_engineers se ON se.id = l.software_engineer_id
@@ -252,10 +268,13 @@ func GetLaptopByID(db *sql.DB, id int64) (*Laptop, error) {
 		&clientCompanyName,
 		&softwareEngineerName,
 		&employeeID,
+		&laptop.DeviceCategory,
+		&laptop.DeclaredValue,
+		&laptop.CountryOfOrigin,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("laptop not found with id %d", id)
+		return nil, &NotFoundError{Entity: "laptop", ID: id}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get laptop: %w", err)
@@ -290,10 +309,14 @@ func CreateLaptop(db *sql.DB, laptop *Laptop) error {
 
 	// Set timestamps
 	laptop.BeforeCreate()
+	if laptop.DeviceCategory == "" {
+		laptop.DeviceCategory = DeviceCategoryLaptop
+	}
 
 	query := `
-		INSERT INTO laptops (serial_number, sku, brand, model, cpu, ram_gb, ssd_gb, status, client_company_id, software_engineer_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO laptops (serial_number, sku, brand, model, cpu, ram_gb, ssd_gb, status, client_company_id, software_engineer_id, created_at, updated_at,
+		                     device_category, declared_value, country_of_origin)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NULLIF($15, ''))
 		RETURNING id
 	`
 
@@ -311,6 +334,9 @@ func CreateLaptop(db *sql.DB, laptop *Laptop) error {
 		laptop.SoftwareEngineerID,
 		laptop.CreatedAt,
 		laptop.UpdatedAt,
+		laptop.DeviceCategory,
+		laptop.DeclaredValue,
+		laptop.CountryOfOrigin,
 	).Scan(&laptop.ID)
 
 	if err != nil {
@@ -333,11 +359,15 @@ func UpdateLaptop(db *sql.DB, laptop *Laptop) error {
 
 	// Update timestamp
 	laptop.BeforeUpdate()
+	if laptop.DeviceCategory == "" {
+		laptop.DeviceCategory = DeviceCategoryLaptop
+	}
 
 	query := `
 		UPDATE laptops
 		SET serial_number = $1, sku = $2, brand = $3, model = $4, cpu = $5, ram_gb = $6, ssd_gb = $7, status = $8, 
-		    client_company_id = $9, software_engineer_id = $10, updated_at = $11
+		    client_company_id = $9, software_engineer_id = $10, updated_at = $11,
+		    device_category = $13, declared_value = $14, country_of_origin = NULLIF($15, '')
 		WHERE id = $12
 	`
 
@@ -355,6 +385,9 @@ func UpdateLaptop(db *sql.DB, laptop *Laptop) error {
 		laptop.SoftwareEngineerID,
 		laptop.UpdatedAt,
 		laptop.ID,
+		laptop.DeviceCategory,
+		laptop.DeclaredValue,
+		laptop.CountryOfOrigin,
 	)
 
 	if err != nil {
@@ -367,7 +400,7 @@ func UpdateLaptop(db *sql.DB, laptop *Laptop) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("laptop not found with id %d", laptop.ID)
+		return &NotFoundError{Entity: "laptop", ID: laptop.ID}
 	}
 
 	return nil
@@ -388,7 +421,7 @@ func DeleteLaptop(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("laptop not found with id %d", id)
+		return &NotFoundError{Entity: "laptop", ID: id}
 	}
 
 	return nil
@@ -477,4 +510,3 @@ func buildOrderByClause(filter *LaptopFilter) string {
 	// Also add serial_number as tie-breaker for consistent ordering
 	return fmt.Sprintf("ORDER BY %s %s, l.serial_number ASC", sqlColumn, sortOrder)
 }
-
@@ -2,6 +2,7 @@ package models
 
 import (
 	"errors"
+	"strconv"
 	"time"
 )
 
@@ -16,6 +17,8 @@ const (
 	LaptopStatusInTransitToEngineer  LaptopStatus = "in_transit_to_engineer"
 	LaptopStatusDelivered            LaptopStatus = "delivered"
 	LaptopStatusRetired              LaptopStatus = "retired"
+	LaptopStatusLost                 LaptopStatus = "lost"
+	LaptopStatusDamaged              LaptopStatus = "damaged"
 )
 
 // Laptop represents a laptop device in the inventory
@@ -34,6 +37,11 @@ type Laptop struct {
 	CreatedAt          time.Time    `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time    `json:"updated_at" db:"updated_at"`
 
+	// Customs details for international deliveries
+	DeviceCategory  DeviceCategory `json:"device_category" db:"device_category"`
+	DeclaredValue   *float64       `json:"declared_value,omitempty" db:"declared_value"`
+	CountryOfOrigin string         `json:"country_of_origin,omitempty" db:"country_of_origin"`
+
 	// Relations (not stored in DB directly, populated by queries with joins)
 	ClientCompanyName    string `json:"client_company_name,omitempty" db:"client_company_name"`
 	SoftwareEngineerName string `json:"software_engineer_name,omitempty" db:"software_engineer_name"`
@@ -45,6 +53,14 @@ type Laptop struct {
 	ReceptionReportStatus  string  `json:"reception_report_status,omitempty" db:"reception_report_status"`
 }
 
+// DeclaredValueText returns the declared value with two decimals, or "" if none has been declared
+func (l *Laptop) DeclaredValueText() string {
+	if l.DeclaredValue == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*l.DeclaredValue, 'f', 2, 64)
+}
+
 // GenerateAndSetSKU generates and sets the SKU for the laptop if it's not already set
 func (l *Laptop) GenerateAndSetSKU() {
 	// Only generate if SKU is empty
@@ -98,6 +114,14 @@ func (l *Laptop) Validate() error {
 		return errors.New("invalid status")
 	}
 
+	// Customs details are optional, but must be valid when set
+	if l.DeviceCategory != "" && !IsValidDeviceCategory(l.DeviceCategory) {
+		return errors.New("invalid device category")
+	}
+	if l.DeclaredValue != nil && *l.DeclaredValue < 0 {
+		return errors.New("declared value cannot be negative")
+	}
+
 	return nil
 }
 
@@ -128,7 +152,9 @@ func IsValidLaptopStatus(status LaptopStatus) bool {
 		LaptopStatusAtWarehouse,
 		LaptopStatusInTransitToEngineer,
 		LaptopStatusDelivered,
-		LaptopStatusRetired:
+		LaptopStatusRetired,
+		LaptopStatusLost,
+		LaptopStatusDamaged:
 		return true
 	}
 	return false
@@ -264,6 +290,10 @@ func GetLaptopStatusDisplayName(status LaptopStatus) string {
 		return "Delivered"
 	case LaptopStatusRetired:
 		return "Retired"
+	case LaptopStatusLost:
+		return "Lost"
+	case LaptopStatusDamaged:
+		return "Damaged"
 	default:
 		return string(status)
 	}
@@ -277,6 +307,8 @@ func GetLaptopStatusesInOrder() []LaptopStatus {
 		LaptopStatusAvailable,   // "Available at Warehouse"
 		LaptopStatusInTransitToEngineer,
 		LaptopStatusDelivered,
+		LaptopStatusDamaged,
+		LaptopStatusLost,
 		LaptopStatusRetired,
 	}
 }
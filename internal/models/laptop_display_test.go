@@ -41,6 +41,16 @@ func TestGetLaptopStatusDisplayName(t *testing.T) {
 			status:   LaptopStatusRetired,
 			expected: "Retired",
 		},
+		{
+			name:     "lost shows as Lost",
+			status:   LaptopStatusLost,
+			expected: "Lost",
+		},
+		{
+			name:     "damaged shows as Damaged",
+			status:   LaptopStatusDamaged,
+			expected: "Damaged",
+		},
 	}
 
 	for _, tt := range tests {
@@ -61,6 +71,8 @@ func TestGetLaptopStatusesInOrder(t *testing.T) {
 		LaptopStatusAvailable,   // "Available at Warehouse"
 		LaptopStatusInTransitToEngineer,
 		LaptopStatusDelivered,
+		LaptopStatusDamaged,
+		LaptopStatusLost,
 		LaptopStatusRetired,
 	}
 
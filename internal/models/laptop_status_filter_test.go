@@ -25,7 +25,7 @@ func TestGetAllowedStatusesForRole(t *testing.T) {
 		{
 			name:          "Logistics user sees all statuses",
 			role:          RoleLogistics,
-			expectedCount: 6, // all statuses
+			expectedCount: 8, // all statuses
 			expectedStatus: []LaptopStatus{
 				LaptopStatusAvailable,
 				LaptopStatusInTransitToWarehouse,
@@ -33,12 +33,14 @@ func TestGetAllowedStatusesForRole(t *testing.T) {
 				LaptopStatusInTransitToEngineer,
 				LaptopStatusDelivered,
 				LaptopStatusRetired,
+				LaptopStatusLost,
+				LaptopStatusDamaged,
 			},
 		},
 		{
 			name:          "Client user sees all statuses",
 			role:          RoleClient,
-			expectedCount: 6,
+			expectedCount: 8,
 			expectedStatus: []LaptopStatus{
 				LaptopStatusAvailable,
 				LaptopStatusInTransitToWarehouse,
@@ -46,12 +48,14 @@ func TestGetAllowedStatusesForRole(t *testing.T) {
 				LaptopStatusInTransitToEngineer,
 				LaptopStatusDelivered,
 				LaptopStatusRetired,
+				LaptopStatusLost,
+				LaptopStatusDamaged,
 			},
 		},
 		{
 			name:          "Project Manager user sees all statuses",
 			role:          RoleProjectManager,
-			expectedCount: 6,
+			expectedCount: 8,
 			expectedStatus: []LaptopStatus{
 				LaptopStatusAvailable,
 				LaptopStatusInTransitToWarehouse,
@@ -59,6 +63,8 @@ func TestGetAllowedStatusesForRole(t *testing.T) {
 				LaptopStatusInTransitToEngineer,
 				LaptopStatusDelivered,
 				LaptopStatusRetired,
+				LaptopStatusLost,
+				LaptopStatusDamaged,
 			},
 		},
 	}
@@ -51,16 +51,52 @@ func (n *NotificationLog) IsSent() bool {
 
 // AuditLog represents an audit trail entry for important actions
 type AuditLog struct {
-	ID         int64           `json:"id" db:"id"`
-	UserID     int64           `json:"user_id" db:"user_id"`
-	Action     string          `json:"action" db:"action"`
-	EntityType string          `json:"entity_type" db:"entity_type"`
-	EntityID   int64           `json:"entity_id" db:"entity_id"`
-	Timestamp  time.Time       `json:"timestamp" db:"timestamp"`
-	Details    json.RawMessage `json:"details,omitempty" db:"details"`
+	ID               int64           `json:"id" db:"id"`
+	UserID           int64           `json:"user_id" db:"user_id"`
+	ServiceAccountID *int64          `json:"service_account_id,omitempty" db:"service_account_id"`
+	Action           string          `json:"action" db:"action"`
+	EntityType       string          `json:"entity_type" db:"entity_type"`
+	EntityID         int64           `json:"entity_id" db:"entity_id"`
+	Timestamp        time.Time       `json:"timestamp" db:"timestamp"`
+	Details          json.RawMessage `json:"details,omitempty" db:"details"`
+	Changes          []AuditChange   `json:"changes,omitempty" db:"changes"`
 
 	// Relations
-	User *User `json:"user,omitempty" db:"-"`
+	User      *User  `json:"user,omitempty" db:"-"`
+	ActorName string `json:"actor,omitempty" db:"-"` // Email of the user or name of the service account
+}
+
+// AuditChange is one field of an entity that an audited action changed
+type AuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// BeforeText returns the value before the change as shown in the audit log
+func (c AuditChange) BeforeText() string {
+	return auditValueText(c.Before)
+}
+
+// AfterText returns the value after the change as shown in the audit log
+func (c AuditChange) AfterText() string {
+	return auditValueText(c.After)
+}
+
+// auditValueText formats a changed value: strings as they are, other values as JSON, nothing for null
+func auditValueText(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
 }
 
 // Validate validates the AuditLog model
@@ -97,3 +133,17 @@ func (a *AuditLog) GetFormattedAction() string {
 	return action + "d " + entityType
 }
 
+// DetailFields returns the details of the entry without the action, which is shown separately
+func (a *AuditLog) DetailFields() map[string]string {
+	var details map[string]interface{}
+	if len(a.Details) == 0 || json.Unmarshal(a.Details, &details) != nil {
+		return nil
+	}
+	delete(details, "action")
+
+	fields := make(map[string]string, len(details))
+	for key, value := range details {
+		fields[key] = auditValueText(value)
+	}
+	return fields
+}
@@ -289,3 +289,44 @@ func TestAuditLog_GetFormattedAction(t *testing.T) {
 	}
 }
 
+
+func TestAuditChange_Text(t *testing.T) {
+	tests := []struct {
+		name   string
+		change AuditChange
+		before string
+		after  string
+	}{
+		{"string values", AuditChange{Field: "status", Before: "available", After: "in_transit"}, "available", "in_transit"},
+		{"created field", AuditChange{Field: "brand", After: "Dell"}, "", "Dell"},
+		{"number and bool", AuditChange{Field: "ram_gb", Before: float64(16), After: true}, "16", "true"},
+		{"list", AuditChange{Field: "companies", Before: []interface{}{float64(1)}, After: []interface{}{float64(1), float64(2)}}, "[1]", "[1,2]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.change.BeforeText(); got != tt.before {
+				t.Errorf("BeforeText() = %q, want %q", got, tt.before)
+			}
+			if got := tt.change.AfterText(); got != tt.after {
+				t.Errorf("AfterText() = %q, want %q", got, tt.after)
+			}
+		})
+	}
+}
+
+func TestAuditLog_DetailFields(t *testing.T) {
+	log := AuditLog{Details: json.RawMessage(`{"action":"status_updated","old_status":"pending_pickup","laptop_id":42}`)}
+
+	fields := log.DetailFields()
+	if _, ok := fields["action"]; ok {
+		t.Error("Expected the action to be left out of the details")
+	}
+	if fields["old_status"] != "pending_pickup" || fields["laptop_id"] != "42" {
+		t.Errorf("Unexpected details: %v", fields)
+	}
+
+	if fields := (&AuditLog{}).DetailFields(); fields != nil {
+		t.Errorf("Expected no details for an entry without any, got %v", fields)
+	}
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ShippingAddress is a sender or recipient printed on shipping documents
+type ShippingAddress struct {
+	Name    string
+	Company string
+	Lines   []string // Street first, then city/state/postal code and country
+	Phone   string
+	Email   string
+}
+
+// IsEmpty returns true if the address has nothing to print
+func (a ShippingAddress) IsEmpty() bool {
+	return a.Name == "" && a.Company == "" && len(a.Lines) == 0
+}
+
+// NewShippingAddressLines builds address lines, skipping empty parts.
+// City, state and postal code share one line, e.g. "Austin, TX 78701".
+func NewShippingAddressLines(street, city, state, postalCode, country string) []string {
+	lines := []string{}
+	if street = strings.TrimSpace(street); street != "" {
+		lines = append(lines, street)
+	}
+
+	locality := strings.TrimSpace(city)
+	if region := strings.TrimSpace(strings.TrimSpace(state) + " " + strings.TrimSpace(postalCode)); region != "" {
+		if locality != "" {
+			locality += ", "
+		}
+		locality += region
+	}
+	if locality != "" {
+		lines = append(lines, locality)
+	}
+
+	if country = strings.TrimSpace(country); country != "" {
+		lines = append(lines, country)
+	}
+	return lines
+}
+
+// PackingSlipItem is one laptop packed in a shipment
+type PackingSlipItem struct {
+	LaptopID      int64
+	SerialNumber  string
+	SKU           string
+	Brand         string
+	Model         string
+	PackageNumber int // 0 when the laptop is not assigned to a package
+}
+
+// Description returns the brand and model of the laptop
+func (i PackingSlipItem) Description() string {
+	return strings.TrimSpace(i.Brand + " " + i.Model)
+}
+
+// PackingSlip holds everything printed on a shipment's packing slip
+type PackingSlip struct {
+	Shipment    Shipment
+	CompanyName string
+	From        ShippingAddress
+	To          ShippingAddress
+	Items       []PackingSlipItem
+	Packages    []ShipmentPackage
+
+	IncludeAccessories     bool
+	AccessoriesDescription string
+	SpecialInstructions    string
+
+	GeneratedAt time.Time
+}
+
+// IsOutbound returns true if the shipment leaves the warehouse towards an engineer
+func (p *PackingSlip) IsOutbound() bool {
+	return isOutboundFromWarehouse(&p.Shipment)
+}
+
+// isOutboundFromWarehouse returns true if the shipment's current leg starts at the warehouse
+func isOutboundFromWarehouse(s *Shipment) bool {
+	switch s.ShipmentType {
+	case ShipmentTypeWarehouseToEngineer:
+		return true
+	case ShipmentTypeSingleFullJourney:
+		return s.ArrivedWarehouseAt != nil
+	}
+	return false
+}
+
+// GetPackingSlip loads the packing slip of a shipment.
+// The warehouse address is the sender of outbound shipments and the recipient of inbound ones;
+// the other side comes from the pickup form, falling back to the engineer's address.
+func GetPackingSlip(ctx context.Context, db *sql.DB, shipmentID int64, warehouse ShippingAddress) (*PackingSlip, error) {
+	slip := &PackingSlip{GeneratedAt: time.Now()}
+	s := &slip.Shipment
+
+	var engineerID sql.NullInt64
+	err := db.QueryRowContext(ctx,
+		`SELECT s.id, s.shipment_type, s.laptop_count, s.client_company_id, s.software_engineer_id, s.status,
+		        COALESCE(s.jira_ticket_number, ''), COALESCE(s.courier_name, ''), COALESCE(s.tracking_number, ''),
+		        s.arrived_warehouse_at, s.released_warehouse_at, COALESCE(s.notes, ''), s.created_at, s.updated_at,
+		        c.name
+		FROM shipments s
+		JOIN client_companies c ON c.id = s.client_company_id
+		WHERE s.id = $1`,
+		shipmentID,
+	).Scan(&s.ID, &s.ShipmentType, &s.LaptopCount, &s.ClientCompanyID, &engineerID, &s.Status,
+		&s.JiraTicketNumber, &s.CourierName, &s.TrackingNumber,
+		&s.ArrivedWarehouseAt, &s.ReleasedWarehouseAt, &s.Notes, &s.CreatedAt, &s.UpdatedAt,
+		&slip.CompanyName)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment: %w", err)
+	}
+	if engineerID.Valid {
+		s.SoftwareEngineerID = &engineerID.Int64
+	}
+
+	// Laptops, with the package each one is packed in
+	rows, err := db.QueryContext(ctx,
+		`SELECT l.id, l.serial_number, COALESCE(l.sku, ''), COALESCE(l.brand, ''), l.model,
+		        COALESCE((SELECT p.package_number
+		                  FROM shipment_package_laptops spl
+		                  JOIN shipment_packages p ON p.id = spl.package_id
+		                  WHERE spl.laptop_id = l.id AND p.shipment_id = sl.shipment_id
+		                  ORDER BY p.package_number LIMIT 1), 0)
+		FROM shipment_laptops sl
+		JOIN laptops l ON l.id = sl.laptop_id
+		WHERE sl.shipment_id = $1
+		ORDER BY l.serial_number`,
+		shipmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load shipment laptops: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var item PackingSlipItem
+		if err := rows.Scan(&item.LaptopID, &item.SerialNumber, &item.SKU, &item.Brand, &item.Model, &item.PackageNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment laptop: %w", err)
+		}
+		slip.Items = append(slip.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment laptops: %w", err)
+	}
+
+	slip.Packages, err = GetShipmentPackages(ctx, db, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pickup form data, if the shipment has one
+	formData := map[string]interface{}{}
+	var formDataJSON json.RawMessage
+	err = db.QueryRowContext(ctx,
+		`SELECT form_data FROM pickup_forms WHERE shipment_id = $1 ORDER BY submitted_at DESC LIMIT 1`,
+		shipmentID,
+	).Scan(&formDataJSON)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to load pickup form: %w", err)
+	}
+	if len(formDataJSON) > 0 {
+		if err := json.Unmarshal(formDataJSON, &formData); err != nil {
+			return nil, fmt.Errorf("failed to parse pickup form: %w", err)
+		}
+	}
+	field := func(key string) string {
+		if v, ok := formData[key].(string); ok {
+			return strings.TrimSpace(v)
+		}
+		return ""
+	}
+	slip.IncludeAccessories, _ = formData["include_accessories"].(bool)
+	slip.AccessoriesDescription = field("accessories_description")
+	slip.SpecialInstructions = field("special_instructions")
+
+	var engineer *SoftwareEngineer
+	if s.SoftwareEngineerID != nil {
+		engineer, err = GetSoftwareEngineerByID(db, *s.SoftwareEngineerID)
+		if err != nil {
+			// The engineer may have been removed, the pickup form still has the address
+			engineer = nil
+		}
+	}
+
+	if slip.IsOutbound() {
+		slip.From = warehouse
+		slip.To = ShippingAddress{
+			Name:  field("contact_name"),
+			Lines: NewShippingAddressLines(field("delivery_address"), field("delivery_city"), field("delivery_state"), field("delivery_zip"), field("delivery_country")),
+			Phone: field("contact_phone"),
+			Email: field("contact_email"),
+		}
+		if len(slip.To.Lines) == 0 && engineer != nil {
+			slip.To = engineerShippingAddress(engineer)
+		}
+	} else {
+		slip.From = ShippingAddress{
+			Name:    field("contact_name"),
+			Company: slip.CompanyName,
+			Lines:   NewShippingAddressLines(field("pickup_address"), field("pickup_city"), field("pickup_state"), field("pickup_zip"), field("pickup_country")),
+			Phone:   field("contact_phone"),
+			Email:   field("contact_email"),
+		}
+		if len(slip.From.Lines) == 0 && engineer != nil {
+			slip.From = engineerShippingAddress(engineer)
+		}
+		slip.To = warehouse
+	}
+
+	return slip, nil
+}
+
+// engineerShippingAddress returns the address on an engineer's profile
+func engineerShippingAddress(e *SoftwareEngineer) ShippingAddress {
+	lines := NewShippingAddressLines(e.AddressStreet, e.AddressCity, e.AddressState, e.AddressPostalCode, e.AddressCountry)
+	if len(lines) == 0 && strings.TrimSpace(e.Address) != "" {
+		lines = []string{strings.TrimSpace(e.Address)}
+	}
+	return ShippingAddress{
+		Name:  e.Name,
+		Lines: lines,
+		Phone: e.Phone,
+		Email: e.Email,
+	}
+}
+
+// GetShipmentIDsReleasedBetween returns the shipments released from the warehouse in [from, to), oldest first
+func GetShipmentIDsReleasedBetween(ctx context.Context, db *sql.DB, from, to time.Time) ([]int64, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id FROM shipments
+		WHERE released_warehouse_at >= $1 AND released_warehouse_at < $2
+		ORDER BY released_warehouse_at, id`,
+		from, to,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query released shipments: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan released shipment: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
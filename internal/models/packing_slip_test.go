@@ -0,0 +1,68 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewShippingAddressLines(t *testing.T) {
+	tests := []struct {
+		name                                     string
+		street, city, state, postalCode, country string
+		want                                     []string
+	}{
+		{"full address", "100 Main St", "Austin", "TX", "78701", "USA", []string{"100 Main St", "Austin, TX 78701", "USA"}},
+		{"no state", "Av. Corrientes 1234", "Buenos Aires", "", "C1043", "Argentina", []string{"Av. Corrientes 1234", "Buenos Aires, C1043", "Argentina"}},
+		{"city only", "", " Lima ", "", "", "", []string{"Lima"}},
+		{"postal code only", "", "", "", "12345", "", []string{"12345"}},
+		{"empty", "", "", "", "", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewShippingAddressLines(tt.street, tt.city, tt.state, tt.postalCode, tt.country)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewShippingAddressLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackingSlip_IsOutbound(t *testing.T) {
+	arrived := time.Now()
+	tests := []struct {
+		name     string
+		shipment Shipment
+		want     bool
+	}{
+		{"warehouse to engineer", Shipment{ShipmentType: ShipmentTypeWarehouseToEngineer}, true},
+		{"single full journey before the warehouse", Shipment{ShipmentType: ShipmentTypeSingleFullJourney}, false},
+		{"single full journey after the warehouse", Shipment{ShipmentType: ShipmentTypeSingleFullJourney, ArrivedWarehouseAt: &arrived}, true},
+		{"bulk to warehouse", Shipment{ShipmentType: ShipmentTypeBulkToWarehouse, ArrivedWarehouseAt: &arrived}, false},
+		{"engineer to warehouse", Shipment{ShipmentType: ShipmentTypeEngineerToWarehouse}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slip := PackingSlip{Shipment: tt.shipment}
+			if got := slip.IsOutbound(); got != tt.want {
+				t.Errorf("IsOutbound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEngineerShippingAddress(t *testing.T) {
+	e := &SoftwareEngineer{Name: "Ana", Email: "ana@example.com", AddressStreet: "1 Calle", AddressCity: "Madrid", AddressCountry: "Spain"}
+	a := engineerShippingAddress(e)
+	if a.Name != "Ana" || !reflect.DeepEqual(a.Lines, []string{"1 Calle", "Madrid", "Spain"}) {
+		t.Errorf("Unexpected address %+v", a)
+	}
+
+	// The legacy single-line address is used when no structured address is set
+	legacy := engineerShippingAddress(&SoftwareEngineer{Name: "Bo", Address: "2 Road, Lisbon"})
+	if !reflect.DeepEqual(legacy.Lines, []string{"2 Road, Lisbon"}) {
+		t.Errorf("Expected legacy address, got %v", legacy.Lines)
+	}
+}
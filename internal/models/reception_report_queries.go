@@ -6,7 +6,8 @@ import (
 	"errors"
 )
 
-// GetLaptopReceptionReport retrieves the reception report for a specific laptop
+// GetLaptopReceptionReport retrieves the most recent reception report for a specific laptop
+// A laptop gets a new report every time it arrives at the warehouse (e.g. when returned by an engineer)
 func GetLaptopReceptionReport(ctx context.Context, db *sql.DB, laptopID int64) (*ReceptionReport, error) {
 	query := `
 		SELECT 
@@ -16,6 +17,8 @@ func GetLaptopReceptionReport(ctx context.Context, db *sql.DB, laptopID int64) (
 			status, approved_by, approved_at, created_at, updated_at
 		FROM reception_reports
 		WHERE laptop_id = $1
+		ORDER BY received_at DESC, id DESC
+		LIMIT 1
 	`
 
 	report := &ReceptionReport{}
@@ -75,8 +78,11 @@ func GetLaptopReceptionReport(ctx context.Context, db *sql.DB, laptopID int64) (
 	return report, nil
 }
 
-// CreateReceptionReport creates a new reception report in the database
-func CreateReceptionReport(ctx context.Context, db *sql.DB, report *ReceptionReport) error {
+// CreateReceptionReport creates a new reception report in the database.
+// Pass a transaction to commit the report together with related changes.
+func CreateReceptionReport(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, report *ReceptionReport) error {
 	// Set timestamps
 	report.BeforeCreate()
 
@@ -1,10 +1,10 @@
 package models
 
 import (
+	"context"
+	"database/sql"
 	"errors"
-	"fmt"
 	"regexp"
-	"strings"
 	"time"
 )
 
@@ -23,6 +23,24 @@ const (
 	ShipmentStatusDelivered              ShipmentStatus = "delivered"
 )
 
+// Return shipment status constants
+// Used by engineer_to_warehouse shipments before the laptop is back in transit to the warehouse
+const (
+	ShipmentStatusReturnKitSent         ShipmentStatus = "return_kit_sent"
+	ShipmentStatusReturnKitDelivered    ShipmentStatus = "return_kit_delivered"
+	ShipmentStatusPickedUpFromEngineer  ShipmentStatus = "picked_up_from_engineer"
+)
+
+// Shipment exception status constants
+// Exceptions can be entered from any in-flight status and require a reason
+const (
+	ShipmentStatusOnHold           ShipmentStatus = "on_hold"
+	ShipmentStatusLost             ShipmentStatus = "lost"
+	ShipmentStatusDamaged          ShipmentStatus = "damaged"
+	ShipmentStatusReturnedToSender ShipmentStatus = "returned_to_sender"
+	ShipmentStatusCancelled        ShipmentStatus = "cancelled"
+)
+
 // Courier name constants
 const (
 	CourierUPS   = "UPS"
@@ -38,6 +56,7 @@ const (
 	ShipmentTypeSingleFullJourney    ShipmentType = "single_full_journey"
 	ShipmentTypeBulkToWarehouse      ShipmentType = "bulk_to_warehouse"
 	ShipmentTypeWarehouseToEngineer  ShipmentType = "warehouse_to_engineer"
+	ShipmentTypeEngineerToWarehouse  ShipmentType = "engineer_to_warehouse"
 )
 
 // Shipment represents a shipment of laptops through the delivery pipeline
@@ -62,6 +81,16 @@ type Shipment struct {
 	ETAToEngineer       *time.Time      `json:"eta_to_engineer,omitempty" db:"eta_to_engineer"`
 	DeliveredAt         *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
 	
+	// Exception details, set while the shipment is in an exception status
+	ExceptionReason       string          `json:"exception_reason,omitempty" db:"exception_reason"`
+	ExceptionAt           *time.Time      `json:"exception_at,omitempty" db:"exception_at"`
+	StatusBeforeException *ShipmentStatus `json:"status_before_exception,omitempty" db:"status_before_exception"`
+
+	// Customs details printed on the commercial invoice of international deliveries
+	ExportReason    ExportReason `json:"export_reason,omitempty" db:"export_reason"`
+	RecipientTaxID  string       `json:"recipient_tax_id,omitempty" db:"recipient_tax_id"`
+	CustomsCurrency string       `json:"customs_currency,omitempty" db:"customs_currency"`
+
 	Notes               string          `json:"notes,omitempty" db:"notes"`
 	CreatedAt           time.Time       `json:"created_at" db:"created_at"`
 	UpdatedAt           time.Time       `json:"updated_at" db:"updated_at"`
@@ -94,11 +123,6 @@ func (s *Shipment) Validate() error {
 	if !IsValidShipmentStatus(s.Status) {
 		return errors.New("invalid status")
 	}
-	
-	// Validate status is valid for this shipment type
-	if !s.IsValidStatusForType(s.Status) {
-		return fmt.Errorf("status %s is not valid for shipment type %s", s.Status, s.ShipmentType)
-	}
 
 	// JIRA ticket validation
 	if s.JiraTicketNumber == "" {
@@ -134,6 +158,11 @@ func (s *Shipment) ValidateEngineerAssignment() error {
 		if s.SoftwareEngineerID == nil {
 			return errors.New("warehouse_to_engineer shipments must have software engineer assigned")
 		}
+	case ShipmentTypeEngineerToWarehouse:
+		// Return shipments always come back from a specific engineer
+		if s.SoftwareEngineerID == nil {
+			return errors.New("engineer_to_warehouse shipments must have software engineer assigned")
+		}
 	case ShipmentTypeSingleFullJourney:
 		// Single full journey can be assigned anytime (optional validation here)
 		// No error - engineer can be nil or assigned
@@ -144,7 +173,7 @@ func (s *Shipment) ValidateEngineerAssignment() error {
 // ValidateLaptopCount validates laptop count based on shipment type
 func (s *Shipment) ValidateLaptopCount() error {
 	switch s.ShipmentType {
-	case ShipmentTypeSingleFullJourney, ShipmentTypeWarehouseToEngineer:
+	case ShipmentTypeSingleFullJourney, ShipmentTypeWarehouseToEngineer, ShipmentTypeEngineerToWarehouse:
 		// Single shipments must have exactly 1 laptop
 		if s.LaptopCount != 1 {
 			return errors.New("single shipments must have exactly 1 laptop")
@@ -193,7 +222,44 @@ func IsValidShipmentStatus(status ShipmentStatus) bool {
 		ShipmentStatusAtWarehouse,
 		ShipmentStatusReleasedFromWarehouse,
 		ShipmentStatusInTransitToEngineer,
-		ShipmentStatusDelivered:
+		ShipmentStatusDelivered,
+		ShipmentStatusReturnKitSent,
+		ShipmentStatusReturnKitDelivered,
+		ShipmentStatusPickedUpFromEngineer:
+		return true
+	}
+	return IsExceptionStatus(status)
+}
+
+// IsExceptionStatus checks if a status is one of the exception statuses
+func IsExceptionStatus(status ShipmentStatus) bool {
+	switch status {
+	case ShipmentStatusOnHold,
+		ShipmentStatusLost,
+		ShipmentStatusDamaged,
+		ShipmentStatusReturnedToSender,
+		ShipmentStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// GetExceptionStatuses returns all exception statuses
+func GetExceptionStatuses() []ShipmentStatus {
+	return []ShipmentStatus{
+		ShipmentStatusOnHold,
+		ShipmentStatusLost,
+		ShipmentStatusDamaged,
+		ShipmentStatusReturnedToSender,
+		ShipmentStatusCancelled,
+	}
+}
+
+// IsResumableExceptionStatus checks if a shipment in the given exception status
+// can resume the normal flow. Returned and cancelled shipments cannot be resumed.
+func IsResumableExceptionStatus(status ShipmentStatus) bool {
+	switch status {
+	case ShipmentStatusOnHold, ShipmentStatusLost, ShipmentStatusDamaged:
 		return true
 	}
 	return false
@@ -204,66 +270,40 @@ func IsValidShipmentType(shipmentType ShipmentType) bool {
 	switch shipmentType {
 	case ShipmentTypeSingleFullJourney,
 		ShipmentTypeBulkToWarehouse,
-		ShipmentTypeWarehouseToEngineer:
+		ShipmentTypeWarehouseToEngineer,
+		ShipmentTypeEngineerToWarehouse:
 		return true
 	}
 	return false
 }
 
 // GetValidStatusesForType returns valid statuses for a shipment type
-func GetValidStatusesForType(shipmentType ShipmentType) []ShipmentStatus {
-	switch shipmentType {
-	case ShipmentTypeSingleFullJourney:
-		// Full journey: all statuses
-		return []ShipmentStatus{
-			ShipmentStatusPendingPickup,
-			ShipmentStatusPickupScheduled,
-			ShipmentStatusPickedUpFromClient,
-			ShipmentStatusInTransitToWarehouse,
-			ShipmentStatusAtWarehouse,
-			ShipmentStatusReleasedFromWarehouse,
-			ShipmentStatusInTransitToEngineer,
-			ShipmentStatusDelivered,
-		}
-	case ShipmentTypeBulkToWarehouse:
-		// Bulk to warehouse: stops at warehouse
-		return []ShipmentStatus{
-			ShipmentStatusPendingPickup,
-			ShipmentStatusPickupScheduled,
-			ShipmentStatusPickedUpFromClient,
-			ShipmentStatusInTransitToWarehouse,
-			ShipmentStatusAtWarehouse,
-		}
-	case ShipmentTypeWarehouseToEngineer:
-		// Warehouse to engineer: starts from released
-		return []ShipmentStatus{
-			ShipmentStatusReleasedFromWarehouse,
-			ShipmentStatusInTransitToEngineer,
-			ShipmentStatusDelivered,
-		}
-	default:
-		return []ShipmentStatus{}
-	}
+// The list comes from the active workflow definition for the type
+func GetValidStatusesForType(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, shipmentType ShipmentType) ([]ShipmentStatus, error) {
+	def, err := GetWorkflowDefinition(ctx, db, shipmentType)
+	if err != nil {
+		return nil, err
+	}
+	return def.Statuses, nil
 }
 
 // IsValidStatusForType checks if a status is valid for the shipment type
-func (s *Shipment) IsValidStatusForType(status ShipmentStatus) bool {
-	validStatuses := GetValidStatusesForType(s.ShipmentType)
-	for _, validStatus := range validStatuses {
-		if status == validStatus {
-			return true
-		}
-	}
-	return false
+func (s *Shipment) IsValidStatusForType(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, status ShipmentStatus) (bool, error) {
+	def, err := GetWorkflowDefinition(ctx, db, s.ShipmentType)
+	if err != nil {
+		return false, err
+	}
+	return def.HasStatus(status), nil
 }
 
-// IsValidCourier checks if a given courier name is valid
+// IsValidCourier checks if a given courier name is one of the built-in couriers or their service names.
+// Use IsValidCourierName to also accept the couriers stored in the database.
 func IsValidCourier(courier string) bool {
-	switch courier {
-	case CourierUPS, CourierFedEx, CourierDHL:
-		return true
-	}
-	return false
+	return DefaultCourierDirectory().IsValidName(courier)
 }
 
 // GetStatusesForRoleFilter returns the list of statuses that should be shown in filters
@@ -288,6 +328,14 @@ func GetStatusesForRoleFilter(role UserRole) []ShipmentStatus {
 			ShipmentStatusReleasedFromWarehouse,
 			ShipmentStatusInTransitToEngineer,
 			ShipmentStatusDelivered,
+			ShipmentStatusReturnKitSent,
+			ShipmentStatusReturnKitDelivered,
+			ShipmentStatusPickedUpFromEngineer,
+			ShipmentStatusOnHold,
+			ShipmentStatusLost,
+			ShipmentStatusDamaged,
+			ShipmentStatusReturnedToSender,
+			ShipmentStatusCancelled,
 		}
 	default:
 		// Default to all statuses for unknown roles
@@ -299,53 +347,49 @@ func GetStatusesForRoleFilter(role UserRole) []ShipmentStatus {
 			ShipmentStatusReleasedFromWarehouse,
 			ShipmentStatusInTransitToEngineer,
 			ShipmentStatusDelivered,
+			ShipmentStatusReturnKitSent,
+			ShipmentStatusReturnKitDelivered,
+			ShipmentStatusPickedUpFromEngineer,
+			ShipmentStatusOnHold,
+			ShipmentStatusLost,
+			ShipmentStatusDamaged,
+			ShipmentStatusReturnedToSender,
+			ShipmentStatusCancelled,
 		}
 	}
 }
 
 // GetNextAllowedStatuses returns the list of valid next statuses for the current shipment status
 // This enforces sequential status transitions and prevents skipping or going backwards
-// Now considers shipment type to restrict available statuses
-func (s *Shipment) GetNextAllowedStatuses() []ShipmentStatus {
-	// Get valid statuses for this type
-	validStatuses := GetValidStatusesForType(s.ShipmentType)
-	
-	// Find current status index
-	currentIndex := -1
-	for i, status := range validStatuses {
-		if status == s.Status {
-			currentIndex = i
-			break
+// The allowed transitions come from the active workflow definition for the shipment type
+// Exception statuses are not included; use GetExceptionStatuses for those
+func (s *Shipment) GetNextAllowedStatuses(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}) ([]ShipmentStatus, error) {
+	def, err := GetWorkflowDefinition(ctx, db, s.ShipmentType)
+	if err != nil {
+		return nil, err
+	}
+	next := []ShipmentStatus{}
+	for _, status := range def.NextStatuses(s.Status) {
+		if !IsExceptionStatus(status) {
+			next = append(next, status)
 		}
 	}
-	
-	// Return next status if available
-	if currentIndex >= 0 && currentIndex < len(validStatuses)-1 {
-		return []ShipmentStatus{validStatuses[currentIndex+1]}
-	}
-	
-	return []ShipmentStatus{} // No next status available
+	return next, nil
 }
 
 // IsValidStatusTransition checks if transitioning from the current status to the new status is valid
-// Returns true only if the transition is sequential (to the immediate next status)
+// Returns true only if the active workflow defines a transition between the two statuses
 // Returns false for: skipping statuses, going backwards, staying at same status
-// Now considers shipment type to enforce type-specific status flows
-func (s *Shipment) IsValidStatusTransition(newStatus ShipmentStatus) bool {
-	// First check if the new status is valid for this shipment type
-	if !s.IsValidStatusForType(newStatus) {
-		return false
-	}
-	
-	// Then check if it's the immediate next status
-	allowedStatuses := s.GetNextAllowedStatuses()
-	for _, allowed := range allowedStatuses {
-		if allowed == newStatus {
-			return true
-		}
-	}
-	
-	return false
+func (s *Shipment) IsValidStatusTransition(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, newStatus ShipmentStatus) (bool, error) {
+	def, err := GetWorkflowDefinition(ctx, db, s.ShipmentType)
+	if err != nil {
+		return false, err
+	}
+	return def.FindTransition(s.Status, newStatus) != nil, nil
 }
 
 // TableName returns the table name for the Shipment model
@@ -377,7 +421,7 @@ func (s *Shipment) UpdateStatus(status ShipmentStatus) {
 		if s.PickupScheduledDate == nil {
 			s.PickupScheduledDate = &now
 		}
-	case ShipmentStatusPickedUpFromClient:
+	case ShipmentStatusPickedUpFromClient, ShipmentStatusPickedUpFromEngineer:
 		s.PickedUpAt = &now
 	case ShipmentStatusAtWarehouse:
 		s.ArrivedWarehouseAt = &now
@@ -402,7 +446,7 @@ func (s *Shipment) UpdateStatusWithETA(status ShipmentStatus, eta *time.Time) {
 		if s.PickupScheduledDate == nil {
 			s.PickupScheduledDate = &now
 		}
-	case ShipmentStatusPickedUpFromClient:
+	case ShipmentStatusPickedUpFromClient, ShipmentStatusPickedUpFromEngineer:
 		s.PickedUpAt = &now
 	case ShipmentStatusAtWarehouse:
 		s.ArrivedWarehouseAt = &now
@@ -436,10 +480,11 @@ func (s *Shipment) GetLaptopCount() int {
 }
 
 // ShouldSyncLaptopStatus returns true if laptop status should sync with shipment status
-// Only single shipments (single_full_journey and warehouse_to_engineer) sync laptop status
+// Only single shipments (single_full_journey, warehouse_to_engineer and engineer_to_warehouse) sync laptop status
 func (s *Shipment) ShouldSyncLaptopStatus() bool {
 	return s.ShipmentType == ShipmentTypeSingleFullJourney ||
-		s.ShipmentType == ShipmentTypeWarehouseToEngineer
+		s.ShipmentType == ShipmentTypeWarehouseToEngineer ||
+		s.ShipmentType == ShipmentTypeEngineerToWarehouse
 }
 
 // GetLaptopStatusForShipmentStatus returns the corresponding laptop status for the current shipment status
@@ -450,8 +495,11 @@ func (s *Shipment) GetLaptopStatusForShipmentStatus() LaptopStatus {
 	}
 
 	switch s.Status {
+	case ShipmentStatusReturnKitSent, ShipmentStatusReturnKitDelivered:
+		return "" // The laptop stays with the engineer until it is picked up
 	case ShipmentStatusPendingPickup, ShipmentStatusPickupScheduled,
-		ShipmentStatusPickedUpFromClient, ShipmentStatusInTransitToWarehouse:
+		ShipmentStatusPickedUpFromClient, ShipmentStatusPickedUpFromEngineer,
+		ShipmentStatusInTransitToWarehouse:
 		return LaptopStatusInTransitToWarehouse
 	case ShipmentStatusAtWarehouse:
 		return LaptopStatusAtWarehouse
@@ -459,41 +507,51 @@ func (s *Shipment) GetLaptopStatusForShipmentStatus() LaptopStatus {
 		return LaptopStatusInTransitToEngineer
 	case ShipmentStatusDelivered:
 		return LaptopStatusDelivered
+	case ShipmentStatusOnHold:
+		return "" // Laptops keep their status while the shipment is on hold
+	case ShipmentStatusLost:
+		return LaptopStatusLost
+	case ShipmentStatusDamaged:
+		return LaptopStatusDamaged
+	case ShipmentStatusReturnedToSender, ShipmentStatusCancelled:
+		if s.ShipmentType == ShipmentTypeEngineerToWarehouse {
+			// A failed return goes back to the engineer; laptops never picked up stay with them
+			if s.StatusBeforeException != nil && (*s.StatusBeforeException == ShipmentStatusPickedUpFromEngineer ||
+				*s.StatusBeforeException == ShipmentStatusInTransitToWarehouse) {
+				return LaptopStatusDelivered
+			}
+			return ""
+		}
+		// Laptops that already reached the warehouse go back to it;
+		// laptops that never left the client keep their status
+		if s.StatusBeforeException != nil {
+			switch *s.StatusBeforeException {
+			case ShipmentStatusAtWarehouse, ShipmentStatusReleasedFromWarehouse, ShipmentStatusInTransitToEngineer:
+				return LaptopStatusAtWarehouse
+			}
+		}
+		return ""
 	default:
 		return LaptopStatusAvailable
 	}
 }
 
-// GetTrackingURL returns the courier's tracking URL for this shipment's tracking number
+// IsInException returns true if the shipment is currently in an exception status
+func (s *Shipment) IsInException() bool {
+	return IsExceptionStatus(s.Status)
+}
+
+// GetTrackingURL returns the courier's tracking URL for this shipment's tracking number,
+// using the built-in courier records. Handlers with database access resolve links through
+// a CourierDirectory loaded from the couriers table instead.
 // Returns an empty string if the courier is not recognized or if courier name is empty
 // Supports courier names with service types (e.g., "FedEx Express", "UPS Next Day Air")
 func (s *Shipment) GetTrackingURL() string {
-	if s.CourierName == "" || s.TrackingNumber == "" {
-		if s.CourierName == "" {
-			return ""
-		}
-	}
-
-	// Normalize courier name to lowercase for comparison
-	courierLower := strings.ToLower(strings.TrimSpace(s.CourierName))
-
-	// Check for courier name using substring matching to support service types
-	// e.g., "FedEx Express", "UPS Next Day Air", "DHL Express"
-	var baseURL string
-	if strings.Contains(courierLower, "ups") {
-		baseURL = "https://www.ups.com/track?tracknum="
-	} else if strings.Contains(courierLower, "dhl") {
-		baseURL = "http://www.dhl.com/en/express/tracking.html?AWB="
-	} else if strings.Contains(courierLower, "fedex") {
-		baseURL = "https://www.fedex.com/fedextrack/?tracknumbers="
-	} else {
-		return ""
-	}
-
-	return baseURL + s.TrackingNumber
+	return DefaultCourierDirectory().TrackingURL(s.CourierName, s.TrackingNumber)
 }
 
-// GetSecondTrackingURL returns the courier's tracking URL for the second tracking number
+// GetSecondTrackingURL returns the courier's tracking URL for the second tracking number,
+// using the built-in courier records
 // Uses SecondCourierName if available, otherwise falls back to CourierName
 // Returns an empty string if the courier is not recognized, courier name is empty, or second tracking number is empty
 func (s *Shipment) GetSecondTrackingURL() string {
@@ -507,26 +565,6 @@ func (s *Shipment) GetSecondTrackingURL() string {
 		courierName = s.CourierName
 	}
 
-	if courierName == "" {
-		return ""
-	}
-
-	// Normalize courier name to lowercase for comparison
-	courierLower := strings.ToLower(strings.TrimSpace(courierName))
-
-	// Check for courier name using substring matching to support service types
-	// e.g., "FedEx Express", "UPS Next Day Air", "DHL Express"
-	var baseURL string
-	if strings.Contains(courierLower, "ups") {
-		baseURL = "https://www.ups.com/track?tracknum="
-	} else if strings.Contains(courierLower, "dhl") {
-		baseURL = "http://www.dhl.com/en/express/tracking.html?AWB="
-	} else if strings.Contains(courierLower, "fedex") {
-		baseURL = "https://www.fedex.com/fedextrack/?tracknumbers="
-	} else {
-		return ""
-	}
-
-	return baseURL + s.SecondTrackingNumber
+	return DefaultCourierDirectory().TrackingURL(courierName, s.SecondTrackingNumber)
 }
 
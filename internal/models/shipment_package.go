@@ -0,0 +1,372 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ShipmentPackage is one physical package a shipment travels in
+type ShipmentPackage struct {
+	ID             int64     `json:"id" db:"id"`
+	ShipmentID     int64     `json:"shipment_id" db:"shipment_id"`
+	PackageNumber  int       `json:"package_number" db:"package_number"`
+	LengthIn       float64   `json:"length_in,omitempty" db:"length_in"` // 0 when unknown
+	WidthIn        float64   `json:"width_in,omitempty" db:"width_in"`
+	HeightIn       float64   `json:"height_in,omitempty" db:"height_in"`
+	WeightLb       float64   `json:"weight_lb,omitempty" db:"weight_lb"`
+	CourierName    string    `json:"courier_name,omitempty" db:"courier_name"`
+	TrackingNumber string    `json:"tracking_number,omitempty" db:"tracking_number"`
+	Notes          string    `json:"notes,omitempty" db:"notes"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+
+	// Relations (populated by GetShipmentPackages)
+	Laptops     []Laptop `json:"laptops,omitempty" db:"-"`
+	TrackingURL string   `json:"tracking_url,omitempty" db:"-"` // From the package courier's record
+
+}
+
+// Validate validates the ShipmentPackage model
+func (p *ShipmentPackage) Validate() error {
+	if p.ShipmentID == 0 {
+		return errors.New("shipment ID is required")
+	}
+	if p.LengthIn < 0 || p.WidthIn < 0 || p.HeightIn < 0 {
+		return errors.New("package dimensions cannot be negative")
+	}
+	if p.WeightLb < 0 {
+		return errors.New("package weight cannot be negative")
+	}
+	if p.TrackingNumber != "" && p.CourierName == "" {
+		return errors.New("courier name is required when a tracking number is set")
+	}
+	return nil
+}
+
+// TableName returns the table name for the ShipmentPackage model
+func (p *ShipmentPackage) TableName() string {
+	return "shipment_packages"
+}
+
+// BeforeCreate sets timestamps and trims text fields before creating a package
+func (p *ShipmentPackage) BeforeCreate() {
+	now := time.Now()
+	p.CreatedAt = now
+	p.UpdatedAt = now
+	p.trim()
+}
+
+// BeforeUpdate sets the updated timestamp and trims text fields before updating a package
+func (p *ShipmentPackage) BeforeUpdate() {
+	p.UpdatedAt = time.Now()
+	p.trim()
+}
+
+func (p *ShipmentPackage) trim() {
+	p.CourierName = strings.TrimSpace(p.CourierName)
+	p.TrackingNumber = strings.TrimSpace(p.TrackingNumber)
+	p.Notes = strings.TrimSpace(p.Notes)
+}
+
+// SetTrackingURL fills in the package tracking link from its courier's record.
+// The link stays empty if the package has no tracking number or the courier is not recognized.
+func (p *ShipmentPackage) SetTrackingURL(couriers CourierDirectory) {
+	p.TrackingURL = ""
+	if p.TrackingNumber != "" {
+		p.TrackingURL = couriers.TrackingURL(p.CourierName, p.TrackingNumber)
+	}
+}
+
+// HasDimensions returns true if all three dimensions of the package are known
+func (p ShipmentPackage) HasDimensions() bool {
+	return p.LengthIn > 0 && p.WidthIn > 0 && p.HeightIn > 0
+}
+
+// DimensionsLabel returns the package dimensions formatted as "L x W x H in"
+func (p ShipmentPackage) DimensionsLabel() string {
+	if !p.HasDimensions() {
+		return ""
+	}
+	return fmt.Sprintf("%g x %g x %g in", p.LengthIn, p.WidthIn, p.HeightIn)
+}
+
+// HasLaptop returns true if the laptop is packed in this package
+func (p ShipmentPackage) HasLaptop(laptopID int64) bool {
+	for _, l := range p.Laptops {
+		if l.ID == laptopID {
+			return true
+		}
+	}
+	return false
+}
+
+// nullablePositive maps unknown (zero) measurements to NULL
+func nullablePositive(v float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: v, Valid: v > 0}
+}
+
+// nullableText maps empty strings to NULL
+func nullableText(v string) sql.NullString {
+	return sql.NullString{String: v, Valid: v != ""}
+}
+
+// GetShipmentPackages returns a shipment's packages in package number order, with their laptops
+// and the tracking links of their couriers
+func GetShipmentPackages(ctx context.Context, db *sql.DB, shipmentID int64) ([]ShipmentPackage, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, shipment_id, package_number, length_in, width_in, height_in, weight_lb,
+		        COALESCE(courier_name, ''), COALESCE(tracking_number, ''), COALESCE(notes, ''),
+		        created_at, updated_at
+		FROM shipment_packages
+		WHERE shipment_id = $1
+		ORDER BY package_number`,
+		shipmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipment packages: %w", err)
+	}
+	defer rows.Close()
+
+	packages := []ShipmentPackage{}
+	index := map[int64]int{}
+	for rows.Next() {
+		var p ShipmentPackage
+		var length, width, height, weight sql.NullFloat64
+		if err := rows.Scan(&p.ID, &p.ShipmentID, &p.PackageNumber, &length, &width, &height, &weight,
+			&p.CourierName, &p.TrackingNumber, &p.Notes, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment package: %w", err)
+		}
+		p.LengthIn, p.WidthIn, p.HeightIn, p.WeightLb = length.Float64, width.Float64, height.Float64, weight.Float64
+		index[p.ID] = len(packages)
+		packages = append(packages, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment packages: %w", err)
+	}
+	if len(packages) == 0 {
+		return packages, nil
+	}
+
+	couriers, err := LoadCourierDirectory(db)
+	if err != nil {
+		return nil, err
+	}
+	for i := range packages {
+		packages[i].SetTrackingURL(couriers)
+	}
+
+	packageIDs := make([]int64, 0, len(packages))
+	for _, p := range packages {
+		packageIDs = append(packageIDs, p.ID)
+	}
+
+	laptopRows, err := db.QueryContext(ctx,
+		`SELECT spl.package_id, l.id, l.serial_number, COALESCE(l.brand, ''), l.model
+		FROM shipment_package_laptops spl
+		JOIN laptops l ON l.id = spl.laptop_id
+		WHERE spl.package_id = ANY($1)
+		ORDER BY l.serial_number`,
+		pq.Array(packageIDs),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query package laptops: %w", err)
+	}
+	defer laptopRows.Close()
+
+	for laptopRows.Next() {
+		var packageID int64
+		var l Laptop
+		if err := laptopRows.Scan(&packageID, &l.ID, &l.SerialNumber, &l.Brand, &l.Model); err != nil {
+			return nil, fmt.Errorf("failed to scan package laptop: %w", err)
+		}
+		if i, ok := index[packageID]; ok {
+			packages[i].Laptops = append(packages[i].Laptops, l)
+		}
+	}
+	if err := laptopRows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating package laptops: %w", err)
+	}
+
+	return packages, nil
+}
+
+// CreateShipmentPackage adds a package to a shipment and assigns it the next package number
+func CreateShipmentPackage(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, p *ShipmentPackage) error {
+	p.BeforeCreate()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	err := db.QueryRowContext(ctx,
+		`INSERT INTO shipment_packages (shipment_id, package_number, length_in, width_in, height_in, weight_lb,
+		                               courier_name, tracking_number, notes, created_at, updated_at)
+		SELECT $1, COALESCE(MAX(package_number), 0) + 1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		FROM shipment_packages WHERE shipment_id = $1
+		RETURNING id, package_number`,
+		p.ShipmentID, nullablePositive(p.LengthIn), nullablePositive(p.WidthIn), nullablePositive(p.HeightIn),
+		nullablePositive(p.WeightLb), nullableText(p.CourierName), nullableText(p.TrackingNumber),
+		nullableText(p.Notes), p.CreatedAt, p.UpdatedAt,
+	).Scan(&p.ID, &p.PackageNumber)
+	if err != nil {
+		return fmt.Errorf("failed to create shipment package: %w", err)
+	}
+	return nil
+}
+
+// UpdateShipmentPackage saves a package's dimensions, tracking and notes
+func UpdateShipmentPackage(ctx context.Context, db *sql.DB, p *ShipmentPackage) error {
+	p.BeforeUpdate()
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	result, err := db.ExecContext(ctx,
+		`UPDATE shipment_packages
+		SET length_in = $1, width_in = $2, height_in = $3, weight_lb = $4,
+		    courier_name = $5, tracking_number = $6, notes = $7, updated_at = $8
+		WHERE id = $9 AND shipment_id = $10`,
+		nullablePositive(p.LengthIn), nullablePositive(p.WidthIn), nullablePositive(p.HeightIn),
+		nullablePositive(p.WeightLb), nullableText(p.CourierName), nullableText(p.TrackingNumber),
+		nullableText(p.Notes), p.UpdatedAt, p.ID, p.ShipmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update shipment package: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteShipmentPackage removes a package from a shipment
+func DeleteShipmentPackage(ctx context.Context, db *sql.DB, shipmentID, packageID int64) error {
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM shipment_packages WHERE id = $1 AND shipment_id = $2`,
+		packageID, shipmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete shipment package: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetPackageLaptops replaces the laptops packed in a package.
+// Only laptops that belong to the package's shipment are kept.
+func SetPackageLaptops(ctx context.Context, db *sql.DB, shipmentID, packageID int64, laptopIDs []int64) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM shipment_packages WHERE id = $1 AND shipment_id = $2)`,
+		packageID, shipmentID,
+	).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check shipment package: %w", err)
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM shipment_package_laptops WHERE package_id = $1`, packageID); err != nil {
+		return fmt.Errorf("failed to clear package laptops: %w", err)
+	}
+
+	if len(laptopIDs) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO shipment_package_laptops (package_id, laptop_id)
+			SELECT $1, sl.laptop_id
+			FROM shipment_laptops sl
+			WHERE sl.shipment_id = $2 AND sl.laptop_id = ANY($3)
+			ON CONFLICT DO NOTHING`,
+			packageID, shipmentID, pq.Array(laptopIDs),
+		); err != nil {
+			return fmt.Errorf("failed to set package laptops: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit package laptops: %w", err)
+	}
+	return nil
+}
+
+// EnsureShipmentPackages creates count packages with the given dimensions for a shipment that has none yet.
+// Shipments that already have packages are left untouched, so resubmitted forms do not duplicate them.
+func EnsureShipmentPackages(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, shipmentID int64, count int, dims ShipmentPackage) error {
+	if count < 1 {
+		count = 1
+	}
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO shipment_packages (shipment_id, package_number, length_in, width_in, height_in, weight_lb)
+		SELECT $1, n, $2, $3, $4, $5
+		FROM generate_series(1, $6::INTEGER) AS n
+		WHERE NOT EXISTS (SELECT 1 FROM shipment_packages WHERE shipment_id = $1)`,
+		shipmentID, nullablePositive(dims.LengthIn), nullablePositive(dims.WidthIn),
+		nullablePositive(dims.HeightIn), nullablePositive(dims.WeightLb), count,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create shipment packages: %w", err)
+	}
+	return nil
+}
+
+// AssignPackageTracking records a courier tracking number on the shipment's packages.
+// The number goes on the first package without a tracking number; a new package is added when every
+// package already has one. Nothing changes if a package already carries this tracking number.
+func AssignPackageTracking(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, shipmentID int64, courierName, trackingNumber string) error {
+	courierName = strings.TrimSpace(courierName)
+	trackingNumber = strings.TrimSpace(trackingNumber)
+	if trackingNumber == "" {
+		return nil
+	}
+
+	_, err := db.ExecContext(ctx,
+		`WITH existing AS (
+			SELECT 1 FROM shipment_packages WHERE shipment_id = $1 AND tracking_number = $3
+		), target AS (
+			SELECT id FROM shipment_packages
+			WHERE shipment_id = $1 AND COALESCE(tracking_number, '') = ''
+			ORDER BY package_number
+			LIMIT 1
+		), updated AS (
+			UPDATE shipment_packages
+			SET courier_name = $2, tracking_number = $3, updated_at = NOW()
+			WHERE id IN (SELECT id FROM target) AND NOT EXISTS (SELECT 1 FROM existing)
+			RETURNING id
+		)
+		INSERT INTO shipment_packages (shipment_id, package_number, courier_name, tracking_number)
+		SELECT $1, COALESCE((SELECT MAX(package_number) FROM shipment_packages WHERE shipment_id = $1), 0) + 1, $2, $3
+		WHERE NOT EXISTS (SELECT 1 FROM existing) AND NOT EXISTS (SELECT 1 FROM target)`,
+		shipmentID, nullableText(courierName), trackingNumber,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record package tracking: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package models
+
+import "testing"
+
+func TestShipmentPackage_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pkg     ShipmentPackage
+		wantErr bool
+	}{
+		{
+			name: "package without measurements or tracking",
+			pkg:  ShipmentPackage{ShipmentID: 1},
+		},
+		{
+			name: "package with dimensions and tracking",
+			pkg:  ShipmentPackage{ShipmentID: 1, LengthIn: 20, WidthIn: 16, HeightIn: 8, WeightLb: 12.5, CourierName: "UPS", TrackingNumber: "1Z999"},
+		},
+		{
+			name:    "missing shipment",
+			pkg:     ShipmentPackage{LengthIn: 20},
+			wantErr: true,
+		},
+		{
+			name:    "negative dimension",
+			pkg:     ShipmentPackage{ShipmentID: 1, WidthIn: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative weight",
+			pkg:     ShipmentPackage{ShipmentID: 1, WeightLb: -2},
+			wantErr: true,
+		},
+		{
+			name:    "tracking number without courier",
+			pkg:     ShipmentPackage{ShipmentID: 1, TrackingNumber: "1Z999"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.pkg.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShipmentPackage_BeforeCreate(t *testing.T) {
+	pkg := ShipmentPackage{ShipmentID: 1, CourierName: " UPS ", TrackingNumber: " 1Z999 ", Notes: " fragile "}
+	pkg.BeforeCreate()
+
+	if pkg.CreatedAt.IsZero() || pkg.UpdatedAt.IsZero() {
+		t.Error("Expected timestamps to be set")
+	}
+	if pkg.CourierName != "UPS" || pkg.TrackingNumber != "1Z999" || pkg.Notes != "fragile" {
+		t.Errorf("Expected trimmed fields, got %q %q %q", pkg.CourierName, pkg.TrackingNumber, pkg.Notes)
+	}
+}
+
+func TestShipmentPackage_SetTrackingURL(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  ShipmentPackage
+		want string
+	}{
+		{"ups", ShipmentPackage{CourierName: "UPS", TrackingNumber: "1Z999"}, "https://www.ups.com/track?tracknum=1Z999"},
+		{"fedex service level", ShipmentPackage{CourierName: "FedEx Express", TrackingNumber: "7777"}, "https://www.fedex.com/fedextrack/?tracknumbers=7777"},
+		{"dhl", ShipmentPackage{CourierName: "DHL", TrackingNumber: "JD01"}, "http://www.dhl.com/en/express/tracking.html?AWB=JD01"},
+		{"unknown courier", ShipmentPackage{CourierName: "Local Van", TrackingNumber: "A1"}, ""},
+		{"no tracking number", ShipmentPackage{CourierName: "UPS"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.pkg.SetTrackingURL(DefaultCourierDirectory())
+			if tt.pkg.TrackingURL != tt.want {
+				t.Errorf("SetTrackingURL() set %q, want %q", tt.pkg.TrackingURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestShipmentPackage_Dimensions(t *testing.T) {
+	pkg := ShipmentPackage{LengthIn: 20, WidthIn: 16.5, HeightIn: 8}
+	if !pkg.HasDimensions() {
+		t.Fatal("Expected package to have dimensions")
+	}
+	if got := pkg.DimensionsLabel(); got != "20 x 16.5 x 8 in" {
+		t.Errorf("DimensionsLabel() = %q", got)
+	}
+
+	partial := ShipmentPackage{LengthIn: 20}
+	if partial.HasDimensions() || partial.DimensionsLabel() != "" {
+		t.Error("Expected partial dimensions to be treated as unknown")
+	}
+}
+
+func TestShipmentPackage_HasLaptop(t *testing.T) {
+	pkg := ShipmentPackage{Laptops: []Laptop{{ID: 3}, {ID: 7}}}
+	if !pkg.HasLaptop(7) {
+		t.Error("Expected laptop 7 to be in the package")
+	}
+	if pkg.HasLaptop(4) {
+		t.Error("Expected laptop 4 not to be in the package")
+	}
+}
@@ -0,0 +1,180 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// StatusEventSource identifies where a shipment status change came from
+type StatusEventSource string
+
+// StatusEventSource constants
+const (
+	StatusEventSourceUI      StatusEventSource = "ui"
+	StatusEventSourceAPI     StatusEventSource = "api"
+	StatusEventSourceJira    StatusEventSource = "jira"
+	StatusEventSourceCourier StatusEventSource = "courier"
+	StatusEventSourceSystem  StatusEventSource = "system"
+)
+
+// IsValidStatusEventSource checks if a given source is valid
+func IsValidStatusEventSource(source StatusEventSource) bool {
+	switch source {
+	case StatusEventSourceUI, StatusEventSourceAPI, StatusEventSourceJira,
+		StatusEventSourceCourier, StatusEventSourceSystem:
+		return true
+	}
+	return false
+}
+
+// ShipmentStatusEvent is one entry in the append-only history of a shipment's status changes
+type ShipmentStatusEvent struct {
+	ID          int64             `json:"id" db:"id"`
+	ShipmentID  int64             `json:"shipment_id" db:"shipment_id"`
+	FromStatus  *ShipmentStatus   `json:"from_status,omitempty" db:"from_status"`
+	ToStatus    ShipmentStatus    `json:"to_status" db:"to_status"`
+	ActorUserID *int64            `json:"actor_user_id,omitempty" db:"actor_user_id"`
+	Source      StatusEventSource `json:"source" db:"source"`
+	Comment     string            `json:"comment,omitempty" db:"comment"`
+	OccurredAt  time.Time         `json:"occurred_at" db:"occurred_at"`
+
+	// Joined fields
+	ActorEmail string `json:"actor_email,omitempty" db:"-"`
+}
+
+// Validate validates the ShipmentStatusEvent model
+func (e *ShipmentStatusEvent) Validate() error {
+	if e.ShipmentID == 0 {
+		return errors.New("shipment ID is required")
+	}
+	if !IsValidShipmentStatus(e.ToStatus) {
+		return errors.New("invalid status")
+	}
+	if e.FromStatus != nil && !IsValidShipmentStatus(*e.FromStatus) {
+		return errors.New("invalid previous status")
+	}
+	if !IsValidStatusEventSource(e.Source) {
+		return errors.New("invalid status event source")
+	}
+	return nil
+}
+
+// TableName returns the table name for the ShipmentStatusEvent model
+func (e *ShipmentStatusEvent) TableName() string {
+	return "shipment_status_events"
+}
+
+// BeforeCreate fills in defaults before an event is recorded
+func (e *ShipmentStatusEvent) BeforeCreate() {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+	if e.Source == "" {
+		e.Source = StatusEventSourceUI
+	}
+	e.Comment = strings.TrimSpace(e.Comment)
+}
+
+// FromStatusLabel returns the display label of the status before the change ("" for the creation event)
+func (e ShipmentStatusEvent) FromStatusLabel() string {
+	if e.FromStatus == nil {
+		return ""
+	}
+	return formatStatusLabel(*e.FromStatus)
+}
+
+// ToStatusLabel returns the display label of the status after the change
+func (e ShipmentStatusEvent) ToStatusLabel() string {
+	return formatStatusLabel(e.ToStatus)
+}
+
+// RecordShipmentStatusEvent appends a status change to the shipment's history.
+// Pass the transaction that changes the shipment status so the event and the change commit together.
+func RecordShipmentStatusEvent(ctx context.Context, db interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}, event *ShipmentStatusEvent) error {
+	event.BeforeCreate()
+	if err := event.Validate(); err != nil {
+		return err
+	}
+
+	var comment sql.NullString
+	if event.Comment != "" {
+		comment = sql.NullString{String: event.Comment, Valid: true}
+	}
+
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO shipment_status_events (shipment_id, from_status, to_status, actor_user_id, source, comment, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		event.ShipmentID, event.FromStatus, event.ToStatus, event.ActorUserID,
+		event.Source, comment, event.OccurredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record shipment status event: %w", err)
+	}
+	return nil
+}
+
+// GetShipmentStatusEvents returns a shipment's status history, oldest first
+func GetShipmentStatusEvents(ctx context.Context, db *sql.DB, shipmentID int64) ([]ShipmentStatusEvent, error) {
+	events, err := queryShipmentStatusEvents(ctx, db, `se.shipment_id = $1`, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	return events[shipmentID], nil
+}
+
+// GetShipmentStatusEventsForShipments returns the status history of several shipments keyed by shipment ID
+func GetShipmentStatusEventsForShipments(ctx context.Context, db *sql.DB, shipmentIDs []int64) (map[int64][]ShipmentStatusEvent, error) {
+	if len(shipmentIDs) == 0 {
+		return map[int64][]ShipmentStatusEvent{}, nil
+	}
+	return queryShipmentStatusEvents(ctx, db, `se.shipment_id = ANY($1)`, pq.Array(shipmentIDs))
+}
+
+// queryShipmentStatusEvents loads events matching the given condition, oldest first
+func queryShipmentStatusEvents(ctx context.Context, db *sql.DB, condition string, args ...interface{}) (map[int64][]ShipmentStatusEvent, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT se.id, se.shipment_id, se.from_status, se.to_status, se.actor_user_id,
+		        COALESCE(u.email, ''), se.source, COALESCE(se.comment, ''), se.occurred_at
+		FROM shipment_status_events se
+		LEFT JOIN users u ON u.id = se.actor_user_id
+		WHERE `+condition+`
+		ORDER BY se.shipment_id, se.occurred_at, se.id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipment status events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make(map[int64][]ShipmentStatusEvent)
+	for rows.Next() {
+		var e ShipmentStatusEvent
+		var fromStatus sql.NullString
+		var actorUserID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.ShipmentID, &fromStatus, &e.ToStatus, &actorUserID,
+			&e.ActorEmail, &e.Source, &e.Comment, &e.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment status event: %w", err)
+		}
+		if fromStatus.Valid {
+			from := ShipmentStatus(fromStatus.String)
+			e.FromStatus = &from
+		}
+		if actorUserID.Valid {
+			e.ActorUserID = &actorUserID.Int64
+		}
+		events[e.ShipmentID] = append(events[e.ShipmentID], e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment status events: %w", err)
+	}
+
+	return events, nil
+}
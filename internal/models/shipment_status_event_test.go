@@ -0,0 +1,110 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShipmentStatusEvent_Validate(t *testing.T) {
+	invalid := ShipmentStatus("not_a_status")
+	pending := ShipmentStatusPendingPickup
+
+	tests := []struct {
+		name    string
+		event   ShipmentStatusEvent
+		wantErr bool
+	}{
+		{
+			name:  "creation event without previous status",
+			event: ShipmentStatusEvent{ShipmentID: 1, ToStatus: ShipmentStatusPendingPickup, Source: StatusEventSourceUI},
+		},
+		{
+			name:  "status change from courier",
+			event: ShipmentStatusEvent{ShipmentID: 1, FromStatus: &pending, ToStatus: ShipmentStatusPickedUpFromClient, Source: StatusEventSourceCourier},
+		},
+		{
+			name:    "missing shipment",
+			event:   ShipmentStatusEvent{ToStatus: ShipmentStatusPendingPickup, Source: StatusEventSourceUI},
+			wantErr: true,
+		},
+		{
+			name:    "invalid new status",
+			event:   ShipmentStatusEvent{ShipmentID: 1, ToStatus: invalid, Source: StatusEventSourceUI},
+			wantErr: true,
+		},
+		{
+			name:    "invalid previous status",
+			event:   ShipmentStatusEvent{ShipmentID: 1, FromStatus: &invalid, ToStatus: ShipmentStatusPendingPickup, Source: StatusEventSourceUI},
+			wantErr: true,
+		},
+		{
+			name:    "invalid source",
+			event:   ShipmentStatusEvent{ShipmentID: 1, ToStatus: ShipmentStatusPendingPickup, Source: "fax"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.event.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShipmentStatusEvent_BeforeCreate(t *testing.T) {
+	event := ShipmentStatusEvent{ShipmentID: 1, ToStatus: ShipmentStatusPendingPickup, Comment: "  note  "}
+	event.BeforeCreate()
+
+	if event.OccurredAt.IsZero() {
+		t.Error("Expected OccurredAt to be set")
+	}
+	if event.Source != StatusEventSourceUI {
+		t.Errorf("Expected default source ui, got %s", event.Source)
+	}
+	if event.Comment != "note" {
+		t.Errorf("Expected trimmed comment, got %q", event.Comment)
+	}
+
+	occurredAt := time.Now().Add(-time.Hour)
+	event = ShipmentStatusEvent{ShipmentID: 1, ToStatus: ShipmentStatusPendingPickup, Source: StatusEventSourceJira, OccurredAt: occurredAt}
+	event.BeforeCreate()
+	if !event.OccurredAt.Equal(occurredAt) || event.Source != StatusEventSourceJira {
+		t.Error("Expected explicit OccurredAt and Source to be kept")
+	}
+}
+
+func TestLatestStatusEvent(t *testing.T) {
+	now := time.Now()
+	events := []ShipmentStatusEvent{
+		{ID: 1, ToStatus: ShipmentStatusPendingPickup, OccurredAt: now.Add(-3 * time.Hour)},
+		{ID: 2, ToStatus: ShipmentStatusPickedUpFromClient, OccurredAt: now.Add(-2 * time.Hour)},
+		{ID: 3, ToStatus: ShipmentStatusPendingPickup, OccurredAt: now.Add(-time.Hour)},
+	}
+
+	if event := LatestStatusEvent(events, ShipmentStatusPendingPickup); event == nil || event.ID != 3 {
+		t.Errorf("Expected the latest pending pickup event, got %+v", event)
+	}
+	if event := LatestStatusEvent(events, ShipmentStatusDelivered); event != nil {
+		t.Errorf("Expected no event for a status never reached, got %+v", event)
+	}
+}
+
+func TestShipmentStatusEvent_Labels(t *testing.T) {
+	pending := ShipmentStatusPendingPickup
+	event := ShipmentStatusEvent{FromStatus: &pending, ToStatus: ShipmentStatusAtWarehouse}
+
+	if event.FromStatusLabel() != "Pending Pickup from Client" {
+		t.Errorf("Unexpected from label %q", event.FromStatusLabel())
+	}
+	if event.ToStatusLabel() != "At Warehouse" {
+		t.Errorf("Unexpected to label %q", event.ToStatusLabel())
+	}
+
+	creation := ShipmentStatusEvent{ToStatus: ShipmentStatusPendingPickup}
+	if creation.FromStatusLabel() != "" {
+		t.Errorf("Expected empty from label for creation event, got %q", creation.FromStatusLabel())
+	}
+}
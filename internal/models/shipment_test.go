@@ -1,9 +1,12 @@
 package models
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/database"
 )
 
 func TestShipment_Validate(t *testing.T) {
@@ -186,6 +189,11 @@ func TestShipment_IsValidStatus(t *testing.T) {
 		{"released_from_warehouse", ShipmentStatusReleasedFromWarehouse, true},
 		{"in_transit_to_engineer", ShipmentStatusInTransitToEngineer, true},
 		{"delivered", ShipmentStatusDelivered, true},
+		{"on_hold", ShipmentStatusOnHold, true},
+		{"lost", ShipmentStatusLost, true},
+		{"damaged", ShipmentStatusDamaged, true},
+		{"returned_to_sender", ShipmentStatusReturnedToSender, true},
+		{"cancelled", ShipmentStatusCancelled, true},
 		{"invalid status", "unknown", false},
 		{"empty status", "", false},
 	}
@@ -582,6 +590,10 @@ func TestShipment_UpdateStatus_WithoutETA(t *testing.T) {
 
 // TestShipment_GetNextAllowedStatuses tests getting the next valid statuses for sequential transitions
 func TestShipment_GetNextAllowedStatuses(t *testing.T) {
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
 	tests := []struct {
 		name          string
 		currentStatus ShipmentStatus
@@ -635,7 +647,10 @@ func TestShipment_GetNextAllowedStatuses(t *testing.T) {
 				ShipmentType: ShipmentTypeSingleFullJourney, // Full journey through all statuses
 				Status:       tt.currentStatus,
 			}
-			got := shipment.GetNextAllowedStatuses()
+			got, err := shipment.GetNextAllowedStatuses(ctx, db)
+			if err != nil {
+				t.Fatalf("GetNextAllowedStatuses() error = %v", err)
+			}
 
 			// Check length matches
 			if len(got) != len(tt.expectedNext) {
@@ -662,6 +677,10 @@ func TestShipment_GetNextAllowedStatuses(t *testing.T) {
 
 // TestShipment_IsValidStatusTransition tests sequential status transition validation
 func TestShipment_IsValidStatusTransition(t *testing.T) {
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
 	tests := []struct {
 		name          string
 		currentStatus ShipmentStatus
@@ -781,7 +800,10 @@ func TestShipment_IsValidStatusTransition(t *testing.T) {
 				ShipmentType: ShipmentTypeSingleFullJourney, // Full journey through all statuses
 				Status:       tt.currentStatus,
 			}
-			got := shipment.IsValidStatusTransition(tt.newStatus)
+			got, err := shipment.IsValidStatusTransition(ctx, db, tt.newStatus)
+			if err != nil {
+				t.Fatalf("IsValidStatusTransition() error = %v", err)
+			}
 			if got != tt.expected {
 				t.Errorf("IsValidStatusTransition() = %v, want %v", got, tt.expected)
 			}
@@ -794,6 +816,7 @@ func TestShipmentType_Validation(t *testing.T) {
 		ShipmentTypeSingleFullJourney,
 		ShipmentTypeBulkToWarehouse,
 		ShipmentTypeWarehouseToEngineer,
+		ShipmentTypeEngineerToWarehouse,
 	}
 
 	for _, shipmentType := range validTypes {
@@ -852,6 +875,21 @@ func TestShipment_EngineerAssignmentRules(t *testing.T) {
 			engineerID:    &engineerID,
 			shouldBeValid: true,
 		},
+		{
+			name:          "engineer_to_warehouse must have engineer assigned",
+			shipmentType:  ShipmentTypeEngineerToWarehouse,
+			status:        ShipmentStatusReturnKitSent,
+			engineerID:    nil,
+			shouldBeValid: false,
+			errorContains: "must have software engineer assigned",
+		},
+		{
+			name:          "engineer_to_warehouse with engineer is valid",
+			shipmentType:  ShipmentTypeEngineerToWarehouse,
+			status:        ShipmentStatusReturnKitSent,
+			engineerID:    &engineerID,
+			shouldBeValid: true,
+		},
 		{
 			name:          "bulk_to_warehouse cannot have engineer assigned",
 			shipmentType:  ShipmentTypeBulkToWarehouse,
@@ -897,6 +935,10 @@ func TestShipment_EngineerAssignmentRules(t *testing.T) {
 }
 
 func TestShipment_TypeSpecificStatusFlows(t *testing.T) {
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
 	tests := []struct {
 		name          string
 		shipmentType  ShipmentType
@@ -981,7 +1023,10 @@ func TestShipment_TypeSpecificStatusFlows(t *testing.T) {
 				JiraTicketNumber: "SCOP-12345",
 			}
 
-			isValid := s.IsValidStatusTransition(tt.nextStatus)
+			isValid, err := s.IsValidStatusTransition(ctx, db, tt.nextStatus)
+			if err != nil {
+				t.Fatalf("IsValidStatusTransition() error = %v", err)
+			}
 			if tt.shouldBeValid && !isValid {
 				t.Errorf("Expected transition from %s to %s to be valid for %s", tt.currentStatus, tt.nextStatus, tt.shipmentType)
 			}
@@ -992,6 +1037,96 @@ func TestShipment_TypeSpecificStatusFlows(t *testing.T) {
 	}
 }
 
+func TestShipment_IsValidStatusForType(t *testing.T) {
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	tests := []struct {
+		name         string
+		shipmentType ShipmentType
+		status       ShipmentStatus
+		want         bool
+	}{
+		{"bulk_to_warehouse at warehouse", ShipmentTypeBulkToWarehouse, ShipmentStatusAtWarehouse, true},
+		{"bulk_to_warehouse past its last status", ShipmentTypeBulkToWarehouse, ShipmentStatusInTransitToEngineer, false},
+		{"warehouse_to_engineer released", ShipmentTypeWarehouseToEngineer, ShipmentStatusReleasedFromWarehouse, true},
+		{"warehouse_to_engineer awaiting pickup", ShipmentTypeWarehouseToEngineer, ShipmentStatusPendingPickup, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Shipment{ShipmentType: tt.shipmentType}
+			got, err := s.IsValidStatusForType(ctx, db, tt.status)
+			if err != nil {
+				t.Fatalf("IsValidStatusForType() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("IsValidStatusForType(%s) = %v, want %v", tt.status, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestShipment_StatusHelpersUseStoredWorkflow checks that a workflow saved by an admin
+// replaces the built-in one for the status helpers
+func TestShipment_StatusHelpersUseStoredWorkflow(t *testing.T) {
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var userID int64
+	err := db.QueryRow(
+		`INSERT INTO users (email, password_hash, role, created_at, updated_at)
+		VALUES ('workflow-admin@example.com', 'hash', 'logistics', NOW(), NOW()) RETURNING id`,
+	).Scan(&userID)
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	// Bulk shipments skip pickup scheduling in this workflow
+	custom := &WorkflowDefinition{
+		ShipmentType: ShipmentTypeBulkToWarehouse,
+		Statuses: []ShipmentStatus{
+			ShipmentStatusPendingPickup,
+			ShipmentStatusPickedUpFromClient,
+			ShipmentStatusAtWarehouse,
+		},
+		Transitions: []WorkflowTransition{
+			{From: ShipmentStatusPendingPickup, To: ShipmentStatusPickedUpFromClient},
+			{From: ShipmentStatusPickedUpFromClient, To: ShipmentStatusAtWarehouse},
+		},
+	}
+	if err := SaveWorkflowDefinition(db, custom, userID); err != nil {
+		t.Fatalf("Failed to save workflow: %v", err)
+	}
+
+	s := &Shipment{ShipmentType: ShipmentTypeBulkToWarehouse, Status: ShipmentStatusPendingPickup}
+
+	next, err := s.GetNextAllowedStatuses(ctx, db)
+	if err != nil {
+		t.Fatalf("GetNextAllowedStatuses() error = %v", err)
+	}
+	if len(next) != 1 || next[0] != ShipmentStatusPickedUpFromClient {
+		t.Errorf("GetNextAllowedStatuses() = %v, want [%s]", next, ShipmentStatusPickedUpFromClient)
+	}
+
+	if ok, _ := s.IsValidStatusTransition(ctx, db, ShipmentStatusPickupScheduled); ok {
+		t.Error("transition to the built-in pickup scheduled status should not be allowed")
+	}
+	if ok, _ := s.IsValidStatusForType(ctx, db, ShipmentStatusInTransitToWarehouse); ok {
+		t.Error("status left out of the stored workflow should not be valid")
+	}
+
+	statuses, err := GetValidStatusesForType(ctx, db, ShipmentTypeBulkToWarehouse)
+	if err != nil {
+		t.Fatalf("GetValidStatusesForType() error = %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Errorf("GetValidStatusesForType() = %v, want the 3 stored statuses", statuses)
+	}
+}
+
 func TestShipment_LaptopCountTracking(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1059,6 +1194,13 @@ func TestShipment_LaptopCountTracking(t *testing.T) {
 			shouldBeValid: false,
 			errorContains: "exactly 1 laptop",
 		},
+		{
+			name:          "engineer_to_warehouse cannot have count > 1",
+			shipmentType:  ShipmentTypeEngineerToWarehouse,
+			laptopCount:   2,
+			shouldBeValid: false,
+			errorContains: "exactly 1 laptop",
+		},
 	}
 
 	for _, tt := range tests {
@@ -1168,18 +1310,6 @@ func TestShipment_ValidateWithType(t *testing.T) {
 			shouldBeValid: false,
 			errorContains: "at least 2 laptops",
 		},
-		{
-			name: "invalid bulk_to_warehouse - status past allowed",
-			shipment: Shipment{
-				ShipmentType:     ShipmentTypeBulkToWarehouse,
-				ClientCompanyID:  1,
-				LaptopCount:      5,
-				Status:           ShipmentStatusInTransitToEngineer,
-				JiraTicketNumber: "SCOP-12345",
-			},
-			shouldBeValid: false,
-			errorContains: "not valid for shipment type",
-		},
 		{
 			name: "valid warehouse_to_engineer",
 			shipment: Shipment{
@@ -1217,19 +1347,6 @@ func TestShipment_ValidateWithType(t *testing.T) {
 			shouldBeValid: false,
 			errorContains: "exactly 1 laptop",
 		},
-		{
-			name: "invalid warehouse_to_engineer - invalid status for type",
-			shipment: Shipment{
-				ShipmentType:       ShipmentTypeWarehouseToEngineer,
-				ClientCompanyID:    1,
-				LaptopCount:        1,
-				Status:             ShipmentStatusPendingPickup,
-				JiraTicketNumber:   "SCOP-12345",
-				SoftwareEngineerID: &engineerID,
-			},
-			shouldBeValid: false,
-			errorContains: "not valid for shipment type",
-		},
 	}
 
 	for _, tt := range tests {
@@ -1303,6 +1420,28 @@ func TestShipment_SyncLaptopStatusOnUpdate(t *testing.T) {
 			expectedLaptopStatus: LaptopStatusDelivered,
 			shouldSync:           true,
 		},
+		// Engineer to warehouse - should sync once the laptop leaves the engineer
+		{
+			name:                 "engineer_to_warehouse keeps laptop with engineer while return kit is out",
+			shipmentType:         ShipmentTypeEngineerToWarehouse,
+			shipmentStatus:       ShipmentStatusReturnKitDelivered,
+			expectedLaptopStatus: "",
+			shouldSync:           true,
+		},
+		{
+			name:                 "engineer_to_warehouse syncs laptop status - picked up from engineer",
+			shipmentType:         ShipmentTypeEngineerToWarehouse,
+			shipmentStatus:       ShipmentStatusPickedUpFromEngineer,
+			expectedLaptopStatus: LaptopStatusInTransitToWarehouse,
+			shouldSync:           true,
+		},
+		{
+			name:                 "engineer_to_warehouse syncs laptop status - at warehouse",
+			shipmentType:         ShipmentTypeEngineerToWarehouse,
+			shipmentStatus:       ShipmentStatusAtWarehouse,
+			expectedLaptopStatus: LaptopStatusAtWarehouse,
+			shouldSync:           true,
+		},
 		// Bulk to warehouse - should NOT sync
 		{
 			name:           "bulk_to_warehouse does not sync laptop status",
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TrackingCheckpointStatus is a courier checkpoint normalized across tracking providers
+type TrackingCheckpointStatus string
+
+// TrackingCheckpointStatus constants
+const (
+	TrackingCheckpointLabelCreated   TrackingCheckpointStatus = "label_created"
+	TrackingCheckpointPickedUp       TrackingCheckpointStatus = "picked_up"
+	TrackingCheckpointInTransit      TrackingCheckpointStatus = "in_transit"
+	TrackingCheckpointOutForDelivery TrackingCheckpointStatus = "out_for_delivery"
+	TrackingCheckpointDelivered      TrackingCheckpointStatus = "delivered"
+	TrackingCheckpointException      TrackingCheckpointStatus = "exception"
+	TrackingCheckpointUnknown        TrackingCheckpointStatus = "unknown"
+)
+
+// Tracking event delivery channels
+const (
+	TrackingReceivedViaPoll    = "poll"
+	TrackingReceivedViaWebhook = "webhook"
+)
+
+// IsValidTrackingCheckpointStatus checks if a given checkpoint status is valid
+func IsValidTrackingCheckpointStatus(status TrackingCheckpointStatus) bool {
+	switch status {
+	case TrackingCheckpointLabelCreated, TrackingCheckpointPickedUp, TrackingCheckpointInTransit,
+		TrackingCheckpointOutForDelivery, TrackingCheckpointDelivered, TrackingCheckpointException,
+		TrackingCheckpointUnknown:
+		return true
+	}
+	return false
+}
+
+// trackingCheckpointKeywords maps words used by courier APIs to normalized checkpoints.
+// Checked in order, so more specific phrases come first.
+var trackingCheckpointKeywords = []struct {
+	keyword string
+	status  TrackingCheckpointStatus
+}{
+	{"out for delivery", TrackingCheckpointOutForDelivery},
+	{"out_for_delivery", TrackingCheckpointOutForDelivery},
+	{"delivery attempt", TrackingCheckpointException},
+	{"delivered", TrackingCheckpointDelivered},
+	{"exception", TrackingCheckpointException},
+	{"failed", TrackingCheckpointException},
+	{"delay", TrackingCheckpointException},
+	{"returned", TrackingCheckpointException},
+	{"damaged", TrackingCheckpointException},
+	{"picked up", TrackingCheckpointPickedUp},
+	{"picked_up", TrackingCheckpointPickedUp},
+	{"in transit", TrackingCheckpointInTransit},
+	{"in_transit", TrackingCheckpointInTransit},
+	{"departed", TrackingCheckpointInTransit},
+	{"arrived", TrackingCheckpointInTransit},
+	{"label", TrackingCheckpointLabelCreated},
+	{"information received", TrackingCheckpointLabelCreated},
+	{"manifest", TrackingCheckpointLabelCreated},
+}
+
+// NormalizeTrackingCheckpointStatus maps a provider's checkpoint code or description to a normalized status
+func NormalizeTrackingCheckpointStatus(raw string) TrackingCheckpointStatus {
+	value := strings.ToLower(strings.TrimSpace(raw))
+	if value == "" {
+		return TrackingCheckpointUnknown
+	}
+	if IsValidTrackingCheckpointStatus(TrackingCheckpointStatus(value)) {
+		return TrackingCheckpointStatus(value)
+	}
+	for _, k := range trackingCheckpointKeywords {
+		if strings.Contains(value, k.keyword) {
+			return k.status
+		}
+	}
+	return TrackingCheckpointUnknown
+}
+
+// ShipmentTrackingEvent is a courier checkpoint recorded for a shipment
+type ShipmentTrackingEvent struct {
+	ID             int64                    `json:"id" db:"id"`
+	ShipmentID     int64                    `json:"shipment_id" db:"shipment_id"`
+	PackageID      *int64                   `json:"package_id,omitempty" db:"package_id"`
+	Provider       string                   `json:"provider" db:"provider"`
+	TrackingNumber string                   `json:"tracking_number" db:"tracking_number"`
+	Status         TrackingCheckpointStatus `json:"status" db:"status"`
+	ProviderCode   string                   `json:"provider_code,omitempty" db:"provider_code"`
+	Description    string                   `json:"description,omitempty" db:"description"`
+	Location       string                   `json:"location,omitempty" db:"location"`
+	OccurredAt     time.Time                `json:"occurred_at" db:"occurred_at"`
+	ReceivedAt     time.Time                `json:"received_at" db:"received_at"`
+	ReceivedVia    string                   `json:"received_via" db:"received_via"`
+}
+
+// Validate validates the ShipmentTrackingEvent model
+func (e *ShipmentTrackingEvent) Validate() error {
+	if e.ShipmentID == 0 {
+		return errors.New("shipment ID is required")
+	}
+	if e.Provider == "" {
+		return errors.New("tracking provider is required")
+	}
+	if e.TrackingNumber == "" {
+		return errors.New("tracking number is required")
+	}
+	if !IsValidTrackingCheckpointStatus(e.Status) {
+		return errors.New("invalid tracking checkpoint status")
+	}
+	if e.OccurredAt.IsZero() {
+		return errors.New("checkpoint time is required")
+	}
+	if e.ReceivedVia != TrackingReceivedViaPoll && e.ReceivedVia != TrackingReceivedViaWebhook {
+		return errors.New("invalid tracking event channel")
+	}
+	return nil
+}
+
+// TableName returns the table name for the ShipmentTrackingEvent model
+func (e *ShipmentTrackingEvent) TableName() string {
+	return "shipment_tracking_events"
+}
+
+// BeforeCreate fills in defaults before an event is recorded
+func (e *ShipmentTrackingEvent) BeforeCreate() {
+	e.ReceivedAt = time.Now()
+	if e.Status == "" {
+		e.Status = TrackingCheckpointUnknown
+	}
+	e.TrackingNumber = strings.TrimSpace(e.TrackingNumber)
+	e.Description = strings.TrimSpace(e.Description)
+	e.Location = strings.TrimSpace(e.Location)
+}
+
+// StatusLabel returns the display label of the checkpoint status
+func (e ShipmentTrackingEvent) StatusLabel() string {
+	return GetTrackingCheckpointStatusLabel(e.Status)
+}
+
+// GetTrackingCheckpointStatusLabel returns the display label for a checkpoint status
+func GetTrackingCheckpointStatusLabel(status TrackingCheckpointStatus) string {
+	switch status {
+	case TrackingCheckpointLabelCreated:
+		return "Label Created"
+	case TrackingCheckpointPickedUp:
+		return "Picked Up"
+	case TrackingCheckpointInTransit:
+		return "In Transit"
+	case TrackingCheckpointOutForDelivery:
+		return "Out for Delivery"
+	case TrackingCheckpointDelivered:
+		return "Delivered"
+	case TrackingCheckpointException:
+		return "Exception"
+	default:
+		return "Unknown"
+	}
+}
+
+// RecordShipmentTrackingEvent stores a courier checkpoint.
+// Returns false without an error when the same checkpoint was already recorded.
+func RecordShipmentTrackingEvent(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, event *ShipmentTrackingEvent) (bool, error) {
+	event.BeforeCreate()
+	if err := event.Validate(); err != nil {
+		return false, err
+	}
+
+	err := db.QueryRowContext(ctx,
+		`INSERT INTO shipment_tracking_events (shipment_id, package_id, provider, tracking_number, status,
+		                                       provider_code, description, location, occurred_at, received_at, received_via)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT (shipment_id, tracking_number, status, occurred_at, description) DO NOTHING
+		RETURNING id`,
+		event.ShipmentID, event.PackageID, event.Provider, event.TrackingNumber, event.Status,
+		event.ProviderCode, event.Description, event.Location, event.OccurredAt, event.ReceivedAt, event.ReceivedVia,
+	).Scan(&event.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to record shipment tracking event: %w", err)
+	}
+	return true, nil
+}
+
+// GetShipmentTrackingEvents returns a shipment's courier checkpoints, newest first
+func GetShipmentTrackingEvents(ctx context.Context, db *sql.DB, shipmentID int64) ([]ShipmentTrackingEvent, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, shipment_id, package_id, provider, tracking_number, status, provider_code,
+		        description, location, occurred_at, received_at, received_via
+		FROM shipment_tracking_events
+		WHERE shipment_id = $1
+		ORDER BY occurred_at DESC, id DESC`,
+		shipmentID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query shipment tracking events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []ShipmentTrackingEvent{}
+	for rows.Next() {
+		var e ShipmentTrackingEvent
+		var packageID sql.NullInt64
+		if err := rows.Scan(&e.ID, &e.ShipmentID, &packageID, &e.Provider, &e.TrackingNumber, &e.Status,
+			&e.ProviderCode, &e.Description, &e.Location, &e.OccurredAt, &e.ReceivedAt, &e.ReceivedVia); err != nil {
+			return nil, fmt.Errorf("failed to scan shipment tracking event: %w", err)
+		}
+		if packageID.Valid {
+			e.PackageID = &packageID.Int64
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating shipment tracking events: %w", err)
+	}
+	return events, nil
+}
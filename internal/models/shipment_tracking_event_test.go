@@ -0,0 +1,62 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeTrackingCheckpointStatus(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want TrackingCheckpointStatus
+	}{
+		{"delivered", TrackingCheckpointDelivered},
+		{"DELIVERED - Front Door", TrackingCheckpointDelivered},
+		{"Out For Delivery Today", TrackingCheckpointOutForDelivery},
+		{"Delivery attempt failed", TrackingCheckpointException},
+		{"Picked up", TrackingCheckpointPickedUp},
+		{"Departed from facility", TrackingCheckpointInTransit},
+		{"Shipment information received", TrackingCheckpointLabelCreated},
+		{"in_transit", TrackingCheckpointInTransit},
+		{"", TrackingCheckpointUnknown},
+		{"Customs clearance", TrackingCheckpointUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			if got := NormalizeTrackingCheckpointStatus(tt.raw); got != tt.want {
+				t.Errorf("NormalizeTrackingCheckpointStatus(%q) = %s, want %s", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShipmentTrackingEvent_Validate(t *testing.T) {
+	valid := ShipmentTrackingEvent{
+		ShipmentID:     1,
+		Provider:       "fake",
+		TrackingNumber: "1Z999",
+		Status:         TrackingCheckpointInTransit,
+		OccurredAt:     time.Now(),
+		ReceivedVia:    TrackingReceivedViaWebhook,
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected valid event, got %v", err)
+	}
+
+	invalid := []func(e *ShipmentTrackingEvent){
+		func(e *ShipmentTrackingEvent) { e.ShipmentID = 0 },
+		func(e *ShipmentTrackingEvent) { e.Provider = "" },
+		func(e *ShipmentTrackingEvent) { e.TrackingNumber = "" },
+		func(e *ShipmentTrackingEvent) { e.Status = "lost_in_space" },
+		func(e *ShipmentTrackingEvent) { e.OccurredAt = time.Time{} },
+		func(e *ShipmentTrackingEvent) { e.ReceivedVia = "email" },
+	}
+	for i, mutate := range invalid {
+		e := valid
+		mutate(&e)
+		if err := e.Validate(); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
@@ -232,7 +232,7 @@ func GetSoftwareEngineerByID(db *sql.DB, id int64) (*SoftwareEngineer, error) {
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("software engineer not found with id %d", id)
+		return nil, &NotFoundError{Entity: "software engineer", ID: id}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get software engineer: %w", err)
@@ -355,7 +355,7 @@ func UpdateSoftwareEngineer(db *sql.DB, engineer *SoftwareEngineer) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("software engineer not found with id %d", engineer.ID)
+		return &NotFoundError{Entity: "software engineer", ID: engineer.ID}
 	}
 
 	return nil
@@ -376,7 +376,7 @@ func DeleteSoftwareEngineer(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("software engineer not found with id %d", id)
+		return &NotFoundError{Entity: "software engineer", ID: id}
 	}
 
 	return nil
@@ -14,14 +14,20 @@ type TimelineItem struct {
 	Icon                string     // Icon/emoji for the status
 	TrackingNumber      string     // Tracking number associated with this status (if applicable)
 	SecondTrackingNumber string    // Second tracking number (for in_transit_to_engineer status)
+	ActorEmail          string     // Who moved the shipment to this status (from the status history)
+	Source              StatusEventSource // Where the status change came from (from the status history)
+	Comment             string     // Comment entered with the status change (from the status history)
 }
 
-// BuildTimeline creates a complete timeline from the shipment's current state
+// BuildTimeline creates a complete timeline from the shipment's status history.
+// Each step takes the time, actor and comment of the latest event that reached it, so corrections
+// replace earlier entries. Shipments without recorded events fall back to the milestone columns.
 // The timeline is filtered based on shipment type:
 // - single_full_journey: Full timeline from pickup to delivery
 // - bulk_to_warehouse: Only pickup to warehouse arrival
 // - warehouse_to_engineer: Only warehouse release to delivery
-func BuildTimeline(s *Shipment) []TimelineItem {
+// - engineer_to_warehouse: Return kit to the engineer, then back to warehouse arrival
+func BuildTimeline(s *Shipment, events []ShipmentStatusEvent) []TimelineItem {
 	// All possible statuses in order
 	allStatuses := []struct {
 		Status    ShipmentStatus
@@ -82,6 +88,34 @@ func BuildTimeline(s *Shipment) []TimelineItem {
 		},
 	}
 
+	// Return statuses that precede the transit leg of engineer_to_warehouse shipments
+	returnStatuses := []struct {
+		Status    ShipmentStatus
+		Label     string
+		Icon      string
+		IsTransit bool
+		GetTime   func(*Shipment) *time.Time
+	}{
+		{
+			Status:  ShipmentStatusReturnKitSent,
+			Label:   "Return Kit Sent",
+			Icon:    "clock",
+			GetTime: func(s *Shipment) *time.Time { return &s.CreatedAt },
+		},
+		{
+			Status:  ShipmentStatusReturnKitDelivered,
+			Label:   "Return Kit Delivered",
+			Icon:    "check",
+			GetTime: func(s *Shipment) *time.Time { return nil }, // No timestamp is tracked for kit delivery
+		},
+		{
+			Status:  ShipmentStatusPickedUpFromEngineer,
+			Label:   "Picked Up from Engineer",
+			Icon:    "check",
+			GetTime: func(s *Shipment) *time.Time { return s.PickedUpAt },
+		},
+	}
+
 	// Filter statuses based on shipment type
 	var filteredStatuses []struct {
 		Status    ShipmentStatus
@@ -98,6 +132,10 @@ func BuildTimeline(s *Shipment) []TimelineItem {
 	case ShipmentTypeWarehouseToEngineer:
 		// Only show warehouse release to delivery (last 3 statuses)
 		filteredStatuses = allStatuses[5:]
+	case ShipmentTypeEngineerToWarehouse:
+		// Return kit statuses followed by transit to warehouse and arrival
+		filteredStatuses = append(filteredStatuses, returnStatuses...)
+		filteredStatuses = append(filteredStatuses, allStatuses[3:5]...)
 	default:
 		// Single full journey or unspecified: show all statuses
 		filteredStatuses = allStatuses
@@ -116,7 +154,14 @@ func BuildTimeline(s *Shipment) []TimelineItem {
 	timeline := make([]TimelineItem, 0, len(filteredStatuses))
 	for i, statusInfo := range filteredStatuses {
 		timestamp := statusInfo.GetTime(s)
-		
+		var event *ShipmentStatusEvent
+		if len(events) > 0 {
+			timestamp = nil
+			if event = LatestStatusEvent(events, statusInfo.Status); event != nil {
+				timestamp = &event.OccurredAt
+			}
+		}
+
 		item := TimelineItem{
 			Label:       statusInfo.Label,
 			Status:      statusInfo.Status,
@@ -128,6 +173,12 @@ func BuildTimeline(s *Shipment) []TimelineItem {
 			IsPending:   i > currentStatusIndex,
 		}
 
+		if event != nil {
+			item.ActorEmail = event.ActorEmail
+			item.Source = event.Source
+			item.Comment = event.Comment
+		}
+
 		// Add tracking number for pickup scheduled status
 		if statusInfo.Status == ShipmentStatusPickupScheduled && s.TrackingNumber != "" {
 			item.TrackingNumber = s.TrackingNumber
@@ -151,3 +202,14 @@ func BuildTimeline(s *Shipment) []TimelineItem {
 	return timeline
 }
 
+
+// LatestStatusEvent returns the most recent event that moved the shipment to the given status,
+// or nil if the status was never reached. Events are expected oldest first.
+func LatestStatusEvent(events []ShipmentStatusEvent, status ShipmentStatus) *ShipmentStatusEvent {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].ToStatus == status {
+			return &events[i]
+		}
+	}
+	return nil
+}
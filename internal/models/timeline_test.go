@@ -14,7 +14,7 @@ func TestBuildTimeline(t *testing.T) {
 			CreatedAt: createdAt,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		if len(timeline) != 8 {
 			t.Errorf("Expected 8 timeline items, got %d", len(timeline))
@@ -52,7 +52,7 @@ func TestBuildTimeline(t *testing.T) {
 			PickedUpAt:          &pickedUpAt,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// First three items should be completed (pending pickup, pickup scheduled, picked up)
 		if !timeline[0].IsCompleted {
@@ -105,7 +105,7 @@ func TestBuildTimeline(t *testing.T) {
 			DeliveredAt:         &deliveredAt,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// All items with timestamps should be completed
 		completedCount := 0
@@ -135,7 +135,7 @@ func TestBuildTimeline(t *testing.T) {
 			ReleasedWarehouseAt: &releasedAt,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// Find the "In Transit to Engineer" item
 		var transitItem *TimelineItem
@@ -172,7 +172,7 @@ func TestBuildTimeline(t *testing.T) {
 			Status: ShipmentStatusPendingPickup,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		expectedLabels := []string{
 			"Pending Pickup",
@@ -200,7 +200,7 @@ func TestBuildTimeline(t *testing.T) {
 			PickupScheduledDate: &now,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// Should have all 8 statuses from Pending Pickup to Delivered
 		if len(timeline) != 8 {
@@ -224,7 +224,7 @@ func TestBuildTimeline(t *testing.T) {
 			PickupScheduledDate: &now,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// Should have only 5 statuses from Pending Pickup to At Warehouse
 		if len(timeline) != 5 {
@@ -257,7 +257,7 @@ func TestBuildTimeline(t *testing.T) {
 			ReleasedWarehouseAt: &now,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// Should have only 3 statuses from Released from Warehouse to Delivered
 		if len(timeline) != 3 {
@@ -284,6 +284,86 @@ func TestBuildTimeline(t *testing.T) {
 		}
 	})
 
+	t.Run("timeline for engineer_to_warehouse shipment shows return kit to warehouse", func(t *testing.T) {
+		now := time.Now()
+		shipment := Shipment{
+			ShipmentType: ShipmentTypeEngineerToWarehouse,
+			Status:       ShipmentStatusPickedUpFromEngineer,
+			CreatedAt:    now.AddDate(0, 0, -3),
+			PickedUpAt:   &now,
+		}
+
+		timeline := BuildTimeline(&shipment, nil)
+
+		expected := []ShipmentStatus{
+			ShipmentStatusReturnKitSent,
+			ShipmentStatusReturnKitDelivered,
+			ShipmentStatusPickedUpFromEngineer,
+			ShipmentStatusInTransitToWarehouse,
+			ShipmentStatusAtWarehouse,
+		}
+		if len(timeline) != len(expected) {
+			t.Fatalf("Engineer to warehouse should have %d timeline items, got %d", len(expected), len(timeline))
+		}
+		for i, status := range expected {
+			if timeline[i].Status != status {
+				t.Errorf("Timeline item %d should be %s, got %s", i, status, timeline[i].Status)
+			}
+		}
+
+		if !timeline[2].IsCurrent {
+			t.Error("Picked Up from Engineer should be the current status")
+		}
+		if timeline[2].Timestamp == nil {
+			t.Error("Picked Up from Engineer should use PickedUpAt timestamp")
+		}
+	})
+
+	t.Run("timeline is built from status events when present", func(t *testing.T) {
+		now := time.Now()
+		staleMilestone := now.AddDate(0, -1, 0)
+		pending := ShipmentStatusPendingPickup
+		pickedUp := ShipmentStatusPickedUpFromClient
+		shipment := Shipment{
+			ShipmentType: ShipmentTypeBulkToWarehouse,
+			Status:       ShipmentStatusPickedUpFromClient,
+			CreatedAt:    now.AddDate(0, 0, -3),
+			PickedUpAt:   &staleMilestone, // Milestone columns are ignored once events exist
+		}
+		events := []ShipmentStatusEvent{
+			{ToStatus: ShipmentStatusPendingPickup, ActorEmail: "client@example.com", OccurredAt: now.Add(-72 * time.Hour)},
+			{FromStatus: &pending, ToStatus: ShipmentStatusPickedUpFromClient, OccurredAt: now.Add(-48 * time.Hour)},
+			// Set back by mistake, then corrected
+			{FromStatus: &pickedUp, ToStatus: ShipmentStatusPendingPickup, OccurredAt: now.Add(-47 * time.Hour)},
+			{FromStatus: &pending, ToStatus: ShipmentStatusPickedUpFromClient, ActorEmail: "logistics@example.com",
+				Source: StatusEventSourceCourier, Comment: "Corrected", OccurredAt: now.Add(-46 * time.Hour)},
+		}
+
+		timeline := BuildTimeline(&shipment, events)
+
+		if len(timeline) != 5 {
+			t.Fatalf("Bulk to warehouse should have 5 timeline items, got %d", len(timeline))
+		}
+
+		pickedUpItem := timeline[2]
+		if pickedUpItem.Timestamp == nil || !pickedUpItem.Timestamp.Equal(events[3].OccurredAt) {
+			t.Errorf("Picked up step should use the latest event time, got %v", pickedUpItem.Timestamp)
+		}
+		if pickedUpItem.ActorEmail != "logistics@example.com" || pickedUpItem.Source != StatusEventSourceCourier || pickedUpItem.Comment != "Corrected" {
+			t.Errorf("Picked up step should carry the latest event details, got %+v", pickedUpItem)
+		}
+		if !pickedUpItem.IsCurrent {
+			t.Error("Picked up step should be current")
+		}
+
+		if timeline[1].Timestamp != nil {
+			t.Error("Pickup scheduled step was never reached and should have no timestamp")
+		}
+		if timeline[0].Timestamp == nil || !timeline[0].Timestamp.Equal(events[2].OccurredAt) {
+			t.Errorf("Pending pickup step should use its latest event time, got %v", timeline[0].Timestamp)
+		}
+	})
+
 	t.Run("timeline shows CreatedAt timestamp for completed pending pickup status", func(t *testing.T) {
 		now := time.Now()
 		createdAt := now.AddDate(0, 0, -10)
@@ -297,7 +377,7 @@ func TestBuildTimeline(t *testing.T) {
 			PickedUpAt:          &pickedUpAt,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// Find the "Pending Pickup" item (should be completed)
 		var pendingPickupItem *TimelineItem
@@ -338,7 +418,7 @@ func TestBuildTimeline(t *testing.T) {
 			ArrivedWarehouseAt: &arrivedWarehouseAt,
 		}
 
-		timeline := BuildTimeline(&shipment)
+		timeline := BuildTimeline(&shipment, nil)
 
 		// Find the "In Transit to Warehouse" item (should be completed)
 		var transitItem *TimelineItem
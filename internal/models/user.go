@@ -19,17 +19,40 @@ const (
 	RoleProjectManager UserRole = "project_manager"
 )
 
+// Invitation statuses
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationExpired  = "expired"
+)
+
+// PendingPasswordHash is stored for invited users until they set a password.
+// It is not a bcrypt hash, so no password ever matches it.
+const PendingPasswordHash = "INVITATION_PENDING"
+
+// ExternalLoginPasswordHash is stored for users provisioned by an OpenID Connect provider,
+// who sign in there and have no password in this app
+const ExternalLoginPasswordHash = "EXTERNAL_LOGIN"
+
 // User represents a user in the system
 type User struct {
-	ID                int64     `json:"id" db:"id"`
-	Email             string    `json:"email" db:"email"`
-	PasswordHash      string    `json:"-" db:"password_hash"`
-	Role              UserRole  `json:"role" db:"role"`
-	ClientCompanyID   *int64    `json:"client_company_id,omitempty" db:"client_company_id"`
-	ClientCompanyName string    `json:"client_company_name,omitempty" db:"-"` // Populated via JOIN queries
-	GoogleID          *string   `json:"google_id,omitempty" db:"google_id"`
-	CreatedAt         time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+	ID                   int64      `json:"id" db:"id"`
+	Email                string     `json:"email" db:"email"`
+	PasswordHash         string     `json:"-" db:"password_hash"`
+	Role                 UserRole   `json:"role" db:"role"`
+	ClientCompanyID      *int64     `json:"client_company_id,omitempty" db:"client_company_id"`
+	ClientCompanyName    string     `json:"client_company_name,omitempty" db:"-"` // Populated via JOIN queries
+	GoogleID             *string    `json:"google_id,omitempty" db:"google_id"`
+	ServiceAccountID     *int64     `json:"service_account_id,omitempty" db:"-"`   // Set when a service account token authenticated the request
+	AssignedCompanyIDs   []int64    `json:"assigned_company_ids,omitempty" db:"-"` // Client companies of a project manager, see LoadAssignedCompanies
+	FailedLoginCount     int        `json:"failed_login_count" db:"failed_login_count"`
+	LockedUntil          *time.Time `json:"locked_until,omitempty" db:"locked_until"`
+	TwoFactorEnabled     bool       `json:"two_factor_enabled" db:"-"` // TOTP enrollment completed (users.totp_enabled_at is set)
+	InvitedAt            *time.Time `json:"invited_at,omitempty" db:"invited_at"`
+	InvitationExpiresAt  *time.Time `json:"invitation_expires_at,omitempty" db:"invitation_expires_at"`
+	InvitationAcceptedAt *time.Time `json:"invitation_accepted_at,omitempty" db:"invitation_accepted_at"`
+	CreatedAt            time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 // Email validation regex
@@ -75,11 +98,41 @@ func (u *User) HasRole(role UserRole) bool {
 	return u.Role == role
 }
 
+// IsServiceAccount checks if the user stands for a service account rather than a person
+func (u *User) IsServiceAccount() bool {
+	return u.ServiceAccountID != nil
+}
+
+// IsLocked checks if password logins are refused after too many failed attempts
+func (u *User) IsLocked() bool {
+	return u.LockedUntil != nil && u.LockedUntil.After(time.Now())
+}
+
+// InvitationStatus returns the status of the user's invitation: "pending", "accepted", "expired",
+// or "" for users created with a password
+func (u *User) InvitationStatus() string {
+	switch {
+	case u.InvitedAt == nil:
+		return ""
+	case u.InvitationAcceptedAt != nil:
+		return InvitationAccepted
+	case u.InvitationExpiresAt != nil && u.InvitationExpiresAt.After(time.Now()):
+		return InvitationPending
+	default:
+		return InvitationExpired
+	}
+}
+
 // IsGoogleUser checks if the user authenticated via Google OAuth
 func (u *User) IsGoogleUser() bool {
 	return u.GoogleID != nil && *u.GoogleID != ""
 }
 
+// IsExternalLoginUser checks if the user was provisioned by an OpenID Connect provider and has no password
+func (u *User) IsExternalLoginUser() bool {
+	return u.PasswordHash == ExternalLoginPasswordHash
+}
+
 // TableName returns the table name for the User model
 func (u *User) TableName() string {
 	return "users"
@@ -101,7 +154,8 @@ func (u *User) BeforeUpdate() {
 func GetAllUsers(db *sql.DB) ([]User, error) {
 	query := `
 		SELECT u.id, u.email, u.password_hash, u.role, u.client_company_id, u.google_id, u.created_at, u.updated_at,
-		       cc.name as client_company_name
+		       cc.name as client_company_name, u.failed_login_count, u.locked_until,
+		       u.totp_enabled_at IS NOT NULL, u.invited_at, u.invitation_expires_at, u.invitation_accepted_at
 		FROM users u
 		LEFT JOIN client_companies cc ON cc.id = u.client_company_id
 		ORDER BY u.email ASC
@@ -129,6 +183,15 @@ func GetAllUsers(db *sql.DB) ([]User, error) {
 			&user.CreatedAt,
 			&user.UpdatedAt,
 			&clientCompanyName,
+			&user.FailedLoginCount,
+			&user.LockedUntil,
+			&user.TwoFactorEnabled,
+			&user.InvitedAt,
+			&user.InvitationExpiresAt,
+			&user.InvitationAcceptedAt,
+			&user.InvitedAt,
+			&user.InvitationExpiresAt,
+			&user.InvitationAcceptedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan user: %w", err)
@@ -155,7 +218,8 @@ func GetAllUsers(db *sql.DB) ([]User, error) {
 func GetUserByID(db *sql.DB, id int64) (*User, error) {
 	query := `
 		SELECT u.id, u.email, u.password_hash, u.role, u.client_company_id, u.google_id, u.created_at, u.updated_at,
-		       cc.name as client_company_name
+		       cc.name as client_company_name, u.failed_login_count, u.locked_until,
+		       u.totp_enabled_at IS NOT NULL, u.invited_at, u.invitation_expires_at, u.invitation_accepted_at
 		FROM users u
 		LEFT JOIN client_companies cc ON cc.id = u.client_company_id
 		WHERE u.id = $1
@@ -175,10 +239,16 @@ func GetUserByID(db *sql.DB, id int64) (*User, error) {
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&clientCompanyName,
+		&user.FailedLoginCount,
+		&user.LockedUntil,
+		&user.TwoFactorEnabled,
+		&user.InvitedAt,
+		&user.InvitationExpiresAt,
+		&user.InvitationAcceptedAt,
 	)
 
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("user not found with id %d", id)
+		return nil, &NotFoundError{Entity: "user", ID: id}
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
@@ -194,8 +264,10 @@ func GetUserByID(db *sql.DB, id int64) (*User, error) {
 	return &user, nil
 }
 
-// CreateUser creates a new user in the database
-func CreateUser(db *sql.DB, user *User) error {
+// CreateUser creates a new user in the database. Pass a transaction to create the user together with other changes.
+func CreateUser(db interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, user *User) error {
 	// Validate user
 	if err := user.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %w", err)
@@ -265,7 +337,7 @@ func UpdateUser(db *sql.DB, user *User) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found with id %d", user.ID)
+		return &NotFoundError{Entity: "user", ID: user.ID}
 	}
 
 	return nil
@@ -286,9 +358,8 @@ func DeleteUser(db *sql.DB, id int64) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("user not found with id %d", id)
+		return &NotFoundError{Entity: "user", ID: id}
 	}
 
 	return nil
 }
-
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// CompanyScope is the set of client companies whose shipments, laptops and reports a user can see
+type CompanyScope struct {
+	// All is true for users who are not limited to some companies (logistics and warehouse)
+	All bool
+	// CompanyIDs are the visible companies when All is false; empty means none
+	CompanyIDs []int64
+}
+
+// CompanyScope returns the companies the user can see.
+// Client users see their own company, project managers the companies assigned to them.
+func (u *User) CompanyScope() CompanyScope {
+	switch u.Role {
+	case RoleClient:
+		if u.ClientCompanyID == nil {
+			return CompanyScope{CompanyIDs: []int64{}}
+		}
+		return CompanyScope{CompanyIDs: []int64{*u.ClientCompanyID}}
+	case RoleProjectManager:
+		return CompanyScope{CompanyIDs: append([]int64{}, u.AssignedCompanyIDs...)}
+	}
+	return CompanyScope{All: true}
+}
+
+// IDs returns the visible company IDs, or nil when every company is visible.
+// The result is never nil for a limited scope, so callers can tell "none" from "all".
+func (s CompanyScope) IDs() []int64 {
+	if s.All {
+		return nil
+	}
+	if s.CompanyIDs == nil {
+		return []int64{}
+	}
+	return s.CompanyIDs
+}
+
+// Allows checks if a company is in the scope. Records without a company are only visible to unlimited users.
+func (s CompanyScope) Allows(companyID *int64) bool {
+	if s.All {
+		return true
+	}
+	if companyID == nil {
+		return false
+	}
+	for _, id := range s.CompanyIDs {
+		if id == *companyID {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition returns an SQL condition limiting column to the scope, and its arguments.
+// argIndex is the placeholder number to use.
+func (s CompanyScope) Condition(column string, argIndex int) (string, []interface{}) {
+	if s.All {
+		return "TRUE", nil
+	}
+	if len(s.CompanyIDs) == 0 {
+		return "FALSE", nil
+	}
+	return fmt.Sprintf("%s = ANY($%d)", column, argIndex), []interface{}{pq.Array(s.CompanyIDs)}
+}
+
+// GetAssignedCompanyIDs retrieves the client companies assigned to a user
+func GetAssignedCompanyIDs(db *sql.DB, userID int64) ([]int64, error) {
+	rows, err := db.Query(
+		`SELECT client_company_id FROM user_client_companies WHERE user_id = $1 ORDER BY client_company_id`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query assigned companies: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan assigned company: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating assigned companies: %w", err)
+	}
+
+	return ids, nil
+}
+
+// LoadAssignedCompanies sets the assigned companies of a project manager.
+// Other roles are not limited by assignments, so nothing is loaded for them.
+func LoadAssignedCompanies(db *sql.DB, user *User) error {
+	if user.Role != RoleProjectManager || user.ID == 0 {
+		return nil
+	}
+	ids, err := GetAssignedCompanyIDs(db, user.ID)
+	if err != nil {
+		return err
+	}
+	user.AssignedCompanyIDs = ids
+	return nil
+}
+
+// SetAssignedCompanies replaces the client companies assigned to a user
+func SetAssignedCompanies(db *sql.DB, userID int64, companyIDs []int64) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := ReplaceAssignedCompanies(tx, userID, companyIDs); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit assigned companies: %w", err)
+	}
+	return nil
+}
+
+// ReplaceAssignedCompanies replaces the client companies assigned to a user inside the caller's
+// transaction, e.g. the one creating the user
+func ReplaceAssignedCompanies(tx *sql.Tx, userID int64, companyIDs []int64) error {
+	if _, err := tx.Exec(`DELETE FROM user_client_companies WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear assigned companies: %w", err)
+	}
+	if len(companyIDs) > 0 {
+		_, err := tx.Exec(
+			`INSERT INTO user_client_companies (user_id, client_company_id)
+			SELECT $1, UNNEST($2::BIGINT[])
+			ON CONFLICT DO NOTHING`,
+			userID, pq.Array(companyIDs),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to assign companies: %w", err)
+		}
+	}
+	return nil
+}
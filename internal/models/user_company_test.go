@@ -0,0 +1,78 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestUser_CompanyScope(t *testing.T) {
+	companyA, companyB := int64(1), int64(2)
+
+	tests := []struct {
+		name    string
+		user    *User
+		wantAll bool
+		wantIDs []int64
+	}{
+		{"logistics sees all companies", &User{Role: RoleLogistics}, true, nil},
+		{"warehouse sees all companies", &User{Role: RoleWarehouse}, true, nil},
+		{"client sees its company", &User{Role: RoleClient, ClientCompanyID: &companyA}, false, []int64{companyA}},
+		{"client without company sees none", &User{Role: RoleClient}, false, []int64{}},
+		{"project manager sees assigned companies", &User{Role: RoleProjectManager, AssignedCompanyIDs: []int64{companyA, companyB}}, false, []int64{companyA, companyB}},
+		{"project manager without assignments sees none", &User{Role: RoleProjectManager}, false, []int64{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := tt.user.CompanyScope()
+			if scope.All != tt.wantAll {
+				t.Errorf("All = %v, want %v", scope.All, tt.wantAll)
+			}
+			ids := scope.IDs()
+			if tt.wantIDs == nil {
+				if ids != nil {
+					t.Errorf("IDs() = %v, want nil", ids)
+				}
+				return
+			}
+			if ids == nil || len(ids) != len(tt.wantIDs) {
+				t.Fatalf("IDs() = %v, want %v", ids, tt.wantIDs)
+			}
+			for i := range ids {
+				if ids[i] != tt.wantIDs[i] {
+					t.Errorf("IDs() = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
+
+func TestCompanyScope_Allows(t *testing.T) {
+	companyA, companyB := int64(1), int64(2)
+	scope := CompanyScope{CompanyIDs: []int64{companyA}}
+
+	if !scope.Allows(&companyA) {
+		t.Error("scope should allow an assigned company")
+	}
+	if scope.Allows(&companyB) {
+		t.Error("scope should not allow another company")
+	}
+	if scope.Allows(nil) {
+		t.Error("a limited scope should not allow records without a company")
+	}
+	if !(CompanyScope{All: true}).Allows(nil) {
+		t.Error("an unlimited scope should allow records without a company")
+	}
+}
+
+func TestCompanyScope_Condition(t *testing.T) {
+	if cond, args := (CompanyScope{All: true}).Condition("s.client_company_id", 1); cond != "TRUE" || len(args) != 0 {
+		t.Errorf("unlimited scope: got %q %v", cond, args)
+	}
+	if cond, args := (CompanyScope{}).Condition("s.client_company_id", 1); cond != "FALSE" || len(args) != 0 {
+		t.Errorf("empty scope: got %q %v", cond, args)
+	}
+	cond, args := CompanyScope{CompanyIDs: []int64{1, 2}}.Condition("s.client_company_id", 3)
+	if cond != "s.client_company_id = ANY($3)" || len(args) != 1 {
+		t.Errorf("limited scope: got %q %v", cond, args)
+	}
+}
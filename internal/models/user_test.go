@@ -241,3 +241,27 @@ func TestUser_BeforeUpdate(t *testing.T) {
 		}
 	}
 }
+
+func TestUser_InvitationStatus(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		user     User
+		expected string
+	}{
+		{"created with a password", User{}, ""},
+		{"pending", User{InvitedAt: &past, InvitationExpiresAt: &future}, InvitationPending},
+		{"expired", User{InvitedAt: &past, InvitationExpiresAt: &past}, InvitationExpired},
+		{"accepted", User{InvitedAt: &past, InvitationExpiresAt: &past, InvitationAcceptedAt: &past}, InvitationAccepted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.InvitationStatus(); got != tt.expected {
+				t.Errorf("User.InvitationStatus() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
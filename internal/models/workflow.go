@@ -0,0 +1,469 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Workflow guard names. A guard must pass before a transition is allowed.
+const (
+	WorkflowGuardEngineerAssigned        = "engineer_assigned"
+	WorkflowGuardPickupFormSubmitted     = "pickup_form_submitted"
+	WorkflowGuardReceptionReportApproved = "reception_report_approved"
+	WorkflowGuardTrackingNumberPresent   = "tracking_number_present"
+	WorkflowGuardCourierNamePresent      = "courier_name_present"
+	WorkflowGuardExceptionReasonPresent  = "exception_reason_present"
+	WorkflowGuardResumeToPreviousStatus  = "resume_to_previous_status"
+)
+
+// Workflow effect names. Effects run as part of (or right after) a transition.
+const (
+	WorkflowEffectSetTimestamps                  = "set_timestamps"
+	WorkflowEffectSyncLaptopStatus               = "sync_laptop_status"
+	WorkflowEffectNotifyPickupScheduled          = "notify_pickup_scheduled"
+	WorkflowEffectNotifyWarehousePreAlert        = "notify_warehouse_pre_alert"
+	WorkflowEffectNotifyShipmentPickedUp         = "notify_shipment_picked_up"
+	WorkflowEffectNotifyRelease                  = "notify_release"
+	WorkflowEffectNotifyInTransitToEngineer      = "notify_in_transit_to_engineer"
+	WorkflowEffectNotifyDeliveryConfirmation     = "notify_delivery_confirmation"
+	WorkflowEffectNotifyEngineerDeliveryToClient = "notify_engineer_delivery_to_client"
+	WorkflowEffectRecordException                = "record_exception"
+	WorkflowEffectClearException                 = "clear_exception"
+	WorkflowEffectUnassignEngineer               = "unassign_engineer"
+)
+
+// WorkflowTransition describes an allowed status change and what has to happen around it
+type WorkflowTransition struct {
+	From    ShipmentStatus `json:"from"`
+	To      ShipmentStatus `json:"to"`
+	Guards  []string       `json:"guards,omitempty"`
+	Effects []string       `json:"effects,omitempty"`
+}
+
+// WorkflowDefinition describes the status flow of one shipment type
+type WorkflowDefinition struct {
+	ShipmentType ShipmentType         `json:"shipment_type"`
+	Statuses     []ShipmentStatus     `json:"statuses"`
+	Transitions  []WorkflowTransition `json:"transitions"`
+
+	// Metadata (not part of the stored definition JSON)
+	IsBuiltin       bool       `json:"-"`
+	UpdatedByUserID *int64     `json:"-"`
+	UpdatedAt       *time.Time `json:"-"`
+}
+
+// GetAllWorkflowGuards returns all guard names known to the workflow engine
+func GetAllWorkflowGuards() []string {
+	return []string{
+		WorkflowGuardEngineerAssigned,
+		WorkflowGuardPickupFormSubmitted,
+		WorkflowGuardReceptionReportApproved,
+		WorkflowGuardTrackingNumberPresent,
+		WorkflowGuardCourierNamePresent,
+		WorkflowGuardExceptionReasonPresent,
+		WorkflowGuardResumeToPreviousStatus,
+	}
+}
+
+// GetAllWorkflowEffects returns all effect names known to the workflow engine
+func GetAllWorkflowEffects() []string {
+	return []string{
+		WorkflowEffectSetTimestamps,
+		WorkflowEffectSyncLaptopStatus,
+		WorkflowEffectNotifyPickupScheduled,
+		WorkflowEffectNotifyWarehousePreAlert,
+		WorkflowEffectNotifyShipmentPickedUp,
+		WorkflowEffectNotifyRelease,
+		WorkflowEffectNotifyInTransitToEngineer,
+		WorkflowEffectNotifyDeliveryConfirmation,
+		WorkflowEffectNotifyEngineerDeliveryToClient,
+		WorkflowEffectRecordException,
+		WorkflowEffectClearException,
+		WorkflowEffectUnassignEngineer,
+	}
+}
+
+// IsValidWorkflowGuard checks if a given guard name is known
+func IsValidWorkflowGuard(name string) bool {
+	for _, guard := range GetAllWorkflowGuards() {
+		if guard == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidWorkflowEffect checks if a given effect name is known
+func IsValidWorkflowEffect(name string) bool {
+	for _, effect := range GetAllWorkflowEffects() {
+		if effect == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate validates the WorkflowDefinition
+func (d *WorkflowDefinition) Validate() error {
+	if !IsValidShipmentType(d.ShipmentType) {
+		return errors.New("invalid shipment type")
+	}
+	if len(d.Statuses) == 0 {
+		return errors.New("workflow must define at least one status")
+	}
+
+	seen := make(map[ShipmentStatus]bool, len(d.Statuses))
+	for _, status := range d.Statuses {
+		if !IsValidShipmentStatus(status) {
+			return fmt.Errorf("invalid status %q", status)
+		}
+		if seen[status] {
+			return fmt.Errorf("status %q is listed more than once", status)
+		}
+		seen[status] = true
+	}
+
+	edges := make(map[string]bool, len(d.Transitions))
+	for _, t := range d.Transitions {
+		if !seen[t.From] {
+			return fmt.Errorf("transition from %q uses a status not in the workflow", t.From)
+		}
+		if !seen[t.To] {
+			return fmt.Errorf("transition to %q uses a status not in the workflow", t.To)
+		}
+		if t.From == t.To {
+			return fmt.Errorf("transition from %q to itself is not allowed", t.From)
+		}
+		key := string(t.From) + "->" + string(t.To)
+		if edges[key] {
+			return fmt.Errorf("transition from %q to %q is defined more than once", t.From, t.To)
+		}
+		edges[key] = true
+
+		for _, guard := range t.Guards {
+			if !IsValidWorkflowGuard(guard) {
+				return fmt.Errorf("unknown guard %q on transition %s", guard, key)
+			}
+		}
+		for _, effect := range t.Effects {
+			if !IsValidWorkflowEffect(effect) {
+				return fmt.Errorf("unknown effect %q on transition %s", effect, key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// InitialStatus returns the status new shipments of this type start in
+func (d *WorkflowDefinition) InitialStatus() ShipmentStatus {
+	if len(d.Statuses) == 0 {
+		return ""
+	}
+	return d.Statuses[0]
+}
+
+// HasStatus checks if a status is part of this workflow
+func (d *WorkflowDefinition) HasStatus(status ShipmentStatus) bool {
+	for _, s := range d.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTransition returns the transition between two statuses, or nil if none is defined
+func (d *WorkflowDefinition) FindTransition(from, to ShipmentStatus) *WorkflowTransition {
+	for i := range d.Transitions {
+		if d.Transitions[i].From == from && d.Transitions[i].To == to {
+			return &d.Transitions[i]
+		}
+	}
+	return nil
+}
+
+// TransitionsFrom returns all transitions leaving the given status, in definition order
+func (d *WorkflowDefinition) TransitionsFrom(from ShipmentStatus) []WorkflowTransition {
+	transitions := []WorkflowTransition{}
+	for _, t := range d.Transitions {
+		if t.From == from {
+			transitions = append(transitions, t)
+		}
+	}
+	return transitions
+}
+
+// NextStatuses returns the statuses reachable from the given status
+func (d *WorkflowDefinition) NextStatuses(from ShipmentStatus) []ShipmentStatus {
+	statuses := []ShipmentStatus{}
+	for _, t := range d.TransitionsFrom(from) {
+		statuses = append(statuses, t.To)
+	}
+	return statuses
+}
+
+// linearWorkflow builds a definition where each status leads to the next one in order.
+// Guards and effects are attached per target status.
+func linearWorkflow(shipmentType ShipmentType, statuses []ShipmentStatus, guards, effects map[ShipmentStatus][]string) *WorkflowDefinition {
+	def := &WorkflowDefinition{
+		ShipmentType: shipmentType,
+		Statuses:     statuses,
+		Transitions:  []WorkflowTransition{},
+		IsBuiltin:    true,
+	}
+	for i := 0; i < len(statuses)-1; i++ {
+		to := statuses[i+1]
+		transitionEffects := append([]string{WorkflowEffectSetTimestamps}, effects[to]...)
+		def.Transitions = append(def.Transitions, WorkflowTransition{
+			From:    statuses[i],
+			To:      to,
+			Guards:  guards[to],
+			Effects: transitionEffects,
+		})
+	}
+	return def
+}
+
+// addExceptionTransitions adds the exception statuses to a workflow.
+// Every in-flight status can enter any exception with a reason. Shipments that are
+// on hold, lost or damaged can move to another exception or resume the status they
+// were in before the exception; returned and cancelled shipments are final.
+func addExceptionTransitions(def *WorkflowDefinition, inFlight []ShipmentStatus) *WorkflowDefinition {
+	exceptions := GetExceptionStatuses()
+	def.Statuses = append(def.Statuses, exceptions...)
+
+	enter := func(from, to ShipmentStatus) {
+		def.Transitions = append(def.Transitions, WorkflowTransition{
+			From:    from,
+			To:      to,
+			Guards:  []string{WorkflowGuardExceptionReasonPresent},
+			Effects: []string{WorkflowEffectRecordException, WorkflowEffectSyncLaptopStatus},
+		})
+	}
+
+	for _, from := range inFlight {
+		for _, to := range exceptions {
+			enter(from, to)
+		}
+	}
+
+	for _, from := range exceptions {
+		if !IsResumableExceptionStatus(from) {
+			continue
+		}
+		for _, to := range exceptions {
+			if to != from {
+				enter(from, to)
+			}
+		}
+		for _, to := range inFlight {
+			def.Transitions = append(def.Transitions, WorkflowTransition{
+				From:    from,
+				To:      to,
+				Guards:  []string{WorkflowGuardResumeToPreviousStatus},
+				Effects: []string{WorkflowEffectClearException, WorkflowEffectSyncLaptopStatus},
+			})
+		}
+	}
+
+	return def
+}
+
+// BuiltinWorkflowDefinition returns the built-in workflow for a shipment type.
+// These are used whenever no override has been stored in the database.
+// Every status except the last one of the normal flow can enter an exception.
+func BuiltinWorkflowDefinition(shipmentType ShipmentType) *WorkflowDefinition {
+	var def *WorkflowDefinition
+	switch shipmentType {
+	case ShipmentTypeSingleFullJourney:
+		// Full journey: all statuses
+		def = linearWorkflow(shipmentType,
+			[]ShipmentStatus{
+				ShipmentStatusPendingPickup,
+				ShipmentStatusPickupScheduled,
+				ShipmentStatusPickedUpFromClient,
+				ShipmentStatusInTransitToWarehouse,
+				ShipmentStatusAtWarehouse,
+				ShipmentStatusReleasedFromWarehouse,
+				ShipmentStatusInTransitToEngineer,
+				ShipmentStatusDelivered,
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusPickupScheduled:       {WorkflowGuardPickupFormSubmitted, WorkflowGuardTrackingNumberPresent, WorkflowGuardCourierNamePresent},
+				ShipmentStatusReleasedFromWarehouse: {WorkflowGuardReceptionReportApproved},
+				ShipmentStatusInTransitToEngineer:   {WorkflowGuardEngineerAssigned},
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusPickupScheduled:       {WorkflowEffectNotifyPickupScheduled},
+				ShipmentStatusPickedUpFromClient:    {WorkflowEffectNotifyWarehousePreAlert, WorkflowEffectNotifyShipmentPickedUp},
+				ShipmentStatusAtWarehouse:           {WorkflowEffectSyncLaptopStatus},
+				ShipmentStatusReleasedFromWarehouse: {WorkflowEffectNotifyRelease},
+				ShipmentStatusInTransitToEngineer:   {WorkflowEffectNotifyInTransitToEngineer},
+				ShipmentStatusDelivered:             {WorkflowEffectNotifyDeliveryConfirmation, WorkflowEffectNotifyEngineerDeliveryToClient},
+			},
+		)
+	case ShipmentTypeBulkToWarehouse:
+		// Bulk to warehouse: stops at warehouse
+		def = linearWorkflow(shipmentType,
+			[]ShipmentStatus{
+				ShipmentStatusPendingPickup,
+				ShipmentStatusPickupScheduled,
+				ShipmentStatusPickedUpFromClient,
+				ShipmentStatusInTransitToWarehouse,
+				ShipmentStatusAtWarehouse,
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusPickupScheduled: {WorkflowGuardPickupFormSubmitted, WorkflowGuardTrackingNumberPresent, WorkflowGuardCourierNamePresent},
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusPickupScheduled:    {WorkflowEffectNotifyPickupScheduled},
+				ShipmentStatusPickedUpFromClient: {WorkflowEffectNotifyWarehousePreAlert, WorkflowEffectNotifyShipmentPickedUp},
+			},
+		)
+	case ShipmentTypeWarehouseToEngineer:
+		// Warehouse to engineer: starts from released
+		def = linearWorkflow(shipmentType,
+			[]ShipmentStatus{
+				ShipmentStatusReleasedFromWarehouse,
+				ShipmentStatusInTransitToEngineer,
+				ShipmentStatusDelivered,
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusInTransitToEngineer: {WorkflowGuardEngineerAssigned},
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusInTransitToEngineer: {WorkflowEffectNotifyInTransitToEngineer},
+				ShipmentStatusDelivered:           {WorkflowEffectNotifyDeliveryConfirmation, WorkflowEffectNotifyEngineerDeliveryToClient},
+			},
+		)
+	case ShipmentTypeEngineerToWarehouse:
+		// Engineer to warehouse: return kit goes out, laptop comes back.
+		// Arrival leaves the laptop at the warehouse until its reception report is approved.
+		def = linearWorkflow(shipmentType,
+			[]ShipmentStatus{
+				ShipmentStatusReturnKitSent,
+				ShipmentStatusReturnKitDelivered,
+				ShipmentStatusPickedUpFromEngineer,
+				ShipmentStatusInTransitToWarehouse,
+				ShipmentStatusAtWarehouse,
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusPickedUpFromEngineer: {WorkflowGuardTrackingNumberPresent, WorkflowGuardCourierNamePresent},
+			},
+			map[ShipmentStatus][]string{
+				ShipmentStatusPickedUpFromEngineer: {WorkflowEffectSyncLaptopStatus},
+				ShipmentStatusAtWarehouse:          {WorkflowEffectSyncLaptopStatus, WorkflowEffectUnassignEngineer},
+			},
+		)
+	default:
+		return nil
+	}
+
+	flow := append([]ShipmentStatus{}, def.Statuses...)
+	return addExceptionTransitions(def, flow[:len(flow)-1])
+}
+
+// GetAllShipmentTypes returns all shipment types in display order
+func GetAllShipmentTypes() []ShipmentType {
+	return []ShipmentType{
+		ShipmentTypeSingleFullJourney,
+		ShipmentTypeBulkToWarehouse,
+		ShipmentTypeWarehouseToEngineer,
+		ShipmentTypeEngineerToWarehouse,
+	}
+}
+
+// GetWorkflowDefinition returns the active workflow for a shipment type.
+// A definition stored in the shipment_workflows table takes precedence over the built-in one.
+func GetWorkflowDefinition(ctx context.Context, db interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}, shipmentType ShipmentType) (*WorkflowDefinition, error) {
+	var definitionJSON []byte
+	var updatedBy sql.NullInt64
+	var updatedAt time.Time
+	err := db.QueryRowContext(ctx,
+		`SELECT definition, updated_by_user_id, updated_at FROM shipment_workflows WHERE shipment_type = $1`,
+		shipmentType,
+	).Scan(&definitionJSON, &updatedBy, &updatedAt)
+
+	if err == sql.ErrNoRows {
+		builtin := BuiltinWorkflowDefinition(shipmentType)
+		if builtin == nil {
+			return nil, fmt.Errorf("no workflow defined for shipment type %s", shipmentType)
+		}
+		return builtin, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load workflow definition: %w", err)
+	}
+
+	var def WorkflowDefinition
+	if err := json.Unmarshal(definitionJSON, &def); err != nil {
+		return nil, fmt.Errorf("failed to parse workflow definition for %s: %w", shipmentType, err)
+	}
+	def.ShipmentType = shipmentType
+	def.UpdatedAt = &updatedAt
+	if updatedBy.Valid {
+		def.UpdatedByUserID = &updatedBy.Int64
+	}
+
+	return &def, nil
+}
+
+// GetAllWorkflowDefinitions returns the active workflow for every shipment type
+func GetAllWorkflowDefinitions(ctx context.Context, db *sql.DB) ([]*WorkflowDefinition, error) {
+	definitions := []*WorkflowDefinition{}
+	for _, shipmentType := range GetAllShipmentTypes() {
+		def, err := GetWorkflowDefinition(ctx, db, shipmentType)
+		if err != nil {
+			return nil, err
+		}
+		definitions = append(definitions, def)
+	}
+	return definitions, nil
+}
+
+// SaveWorkflowDefinition validates and stores a workflow override for its shipment type
+func SaveWorkflowDefinition(db *sql.DB, def *WorkflowDefinition, userID int64) error {
+	if err := def.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	definitionJSON, err := json.Marshal(def)
+	if err != nil {
+		return fmt.Errorf("failed to encode workflow definition: %w", err)
+	}
+
+	now := time.Now()
+	_, err = db.Exec(
+		`INSERT INTO shipment_workflows (shipment_type, definition, updated_by_user_id, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (shipment_type) DO UPDATE
+		SET definition = EXCLUDED.definition,
+		    updated_by_user_id = EXCLUDED.updated_by_user_id,
+		    updated_at = EXCLUDED.updated_at`,
+		def.ShipmentType, definitionJSON, userID, now,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save workflow definition: %w", err)
+	}
+
+	def.IsBuiltin = false
+	def.UpdatedByUserID = &userID
+	def.UpdatedAt = &now
+	return nil
+}
+
+// ResetWorkflowDefinition removes a stored override so the built-in workflow applies again
+func ResetWorkflowDefinition(db *sql.DB, shipmentType ShipmentType) error {
+	_, err := db.Exec(`DELETE FROM shipment_workflows WHERE shipment_type = $1`, shipmentType)
+	if err != nil {
+		return fmt.Errorf("failed to reset workflow definition: %w", err)
+	}
+	return nil
+}
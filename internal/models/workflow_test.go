@@ -0,0 +1,227 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuiltinWorkflowDefinition(t *testing.T) {
+	for _, shipmentType := range GetAllShipmentTypes() {
+		t.Run(string(shipmentType), func(t *testing.T) {
+			def := BuiltinWorkflowDefinition(shipmentType)
+			if def == nil {
+				t.Fatalf("expected builtin workflow for %s", shipmentType)
+			}
+			if !def.IsBuiltin {
+				t.Error("expected IsBuiltin to be true")
+			}
+			if err := def.Validate(); err != nil {
+				t.Errorf("builtin workflow failed validation: %v", err)
+			}
+		})
+	}
+
+	if def := BuiltinWorkflowDefinition(ShipmentType("unknown")); def != nil {
+		t.Error("expected nil workflow for unknown shipment type")
+	}
+}
+
+func TestBuiltinWorkflowDefinition_InitialStatus(t *testing.T) {
+	tests := []struct {
+		shipmentType ShipmentType
+		want         ShipmentStatus
+	}{
+		{ShipmentTypeSingleFullJourney, ShipmentStatusPendingPickup},
+		{ShipmentTypeBulkToWarehouse, ShipmentStatusPendingPickup},
+		{ShipmentTypeWarehouseToEngineer, ShipmentStatusReleasedFromWarehouse},
+		{ShipmentTypeEngineerToWarehouse, ShipmentStatusReturnKitSent},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.shipmentType), func(t *testing.T) {
+			if got := BuiltinWorkflowDefinition(tt.shipmentType).InitialStatus(); got != tt.want {
+				t.Errorf("InitialStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowDefinition_FindTransition(t *testing.T) {
+	def := BuiltinWorkflowDefinition(ShipmentTypeSingleFullJourney)
+
+	transition := def.FindTransition(ShipmentStatusPendingPickup, ShipmentStatusPickupScheduled)
+	if transition == nil {
+		t.Fatal("expected transition from pending_pickup_from_client to pickup_from_client_scheduled")
+	}
+	for _, guard := range []string{WorkflowGuardPickupFormSubmitted, WorkflowGuardTrackingNumberPresent, WorkflowGuardCourierNamePresent} {
+		if !containsString(transition.Guards, guard) {
+			t.Errorf("expected guard %s on pickup scheduled transition", guard)
+		}
+	}
+	if !containsString(transition.Effects, WorkflowEffectNotifyPickupScheduled) {
+		t.Error("expected pickup scheduled notification effect")
+	}
+
+	if def.FindTransition(ShipmentStatusPendingPickup, ShipmentStatusDelivered) != nil {
+		t.Error("expected no transition that skips stages")
+	}
+	if def.FindTransition(ShipmentStatusDelivered, ShipmentStatusPendingPickup) != nil {
+		t.Error("expected no transition that goes backwards")
+	}
+
+	released := def.FindTransition(ShipmentStatusAtWarehouse, ShipmentStatusReleasedFromWarehouse)
+	if released == nil || !containsString(released.Guards, WorkflowGuardReceptionReportApproved) {
+		t.Error("expected reception report guard on release transition")
+	}
+}
+
+func TestWorkflowDefinition_NextStatuses(t *testing.T) {
+	bulk := BuiltinWorkflowDefinition(ShipmentTypeBulkToWarehouse)
+	if got := bulk.NextStatuses(ShipmentStatusAtWarehouse); len(got) != 0 {
+		t.Errorf("expected at_warehouse to be terminal for bulk, got %v", got)
+	}
+
+	w2e := BuiltinWorkflowDefinition(ShipmentTypeWarehouseToEngineer)
+	got := w2e.NextStatuses(ShipmentStatusReleasedFromWarehouse)
+	if len(got) == 0 || got[0] != ShipmentStatusInTransitToEngineer {
+		t.Errorf("NextStatuses() = %v, want %v first", got, ShipmentStatusInTransitToEngineer)
+	}
+	if got := w2e.NextStatuses(ShipmentStatusDelivered); len(got) != 0 {
+		t.Errorf("expected delivered to be terminal, got %v", got)
+	}
+}
+
+func TestBuiltinWorkflowDefinition_Exceptions(t *testing.T) {
+	def := BuiltinWorkflowDefinition(ShipmentTypeSingleFullJourney)
+
+	for _, exception := range GetExceptionStatuses() {
+		transition := def.FindTransition(ShipmentStatusInTransitToWarehouse, exception)
+		if transition == nil {
+			t.Errorf("expected transition from in_transit_to_warehouse to %s", exception)
+			continue
+		}
+		if !containsString(transition.Guards, WorkflowGuardExceptionReasonPresent) {
+			t.Errorf("expected reason guard on transition to %s", exception)
+		}
+		if def.FindTransition(ShipmentStatusDelivered, exception) != nil {
+			t.Errorf("expected no transition from delivered to %s", exception)
+		}
+	}
+
+	resume := def.FindTransition(ShipmentStatusOnHold, ShipmentStatusInTransitToWarehouse)
+	if resume == nil || !containsString(resume.Guards, WorkflowGuardResumeToPreviousStatus) {
+		t.Error("expected guarded resume transition from on_hold")
+	}
+	if def.FindTransition(ShipmentStatusOnHold, ShipmentStatusDelivered) != nil {
+		t.Error("expected no resume into the final status")
+	}
+	if got := def.NextStatuses(ShipmentStatusCancelled); len(got) != 0 {
+		t.Errorf("expected cancelled to be final, got %v", got)
+	}
+	if got := def.NextStatuses(ShipmentStatusReturnedToSender); len(got) != 0 {
+		t.Errorf("expected returned_to_sender to be final, got %v", got)
+	}
+}
+
+func TestWorkflowDefinition_Validate(t *testing.T) {
+	valid := func() *WorkflowDefinition {
+		return &WorkflowDefinition{
+			ShipmentType: ShipmentTypeWarehouseToEngineer,
+			Statuses:     []ShipmentStatus{ShipmentStatusReleasedFromWarehouse, ShipmentStatusDelivered},
+			Transitions: []WorkflowTransition{
+				{From: ShipmentStatusReleasedFromWarehouse, To: ShipmentStatusDelivered, Effects: []string{WorkflowEffectSetTimestamps}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(d *WorkflowDefinition)
+		errMsg string
+	}{
+		{
+			name:   "valid definition",
+			modify: func(d *WorkflowDefinition) {},
+		},
+		{
+			name:   "invalid shipment type",
+			modify: func(d *WorkflowDefinition) { d.ShipmentType = "unknown" },
+			errMsg: "invalid shipment type",
+		},
+		{
+			name:   "no statuses",
+			modify: func(d *WorkflowDefinition) { d.Statuses = nil; d.Transitions = nil },
+			errMsg: "at least one status",
+		},
+		{
+			name:   "unknown status",
+			modify: func(d *WorkflowDefinition) { d.Statuses = append(d.Statuses, "teleported") },
+			errMsg: "invalid status",
+		},
+		{
+			name:   "duplicate status",
+			modify: func(d *WorkflowDefinition) { d.Statuses = append(d.Statuses, ShipmentStatusDelivered) },
+			errMsg: "more than once",
+		},
+		{
+			name: "transition to status not in workflow",
+			modify: func(d *WorkflowDefinition) {
+				d.Transitions = append(d.Transitions, WorkflowTransition{From: ShipmentStatusDelivered, To: ShipmentStatusAtWarehouse})
+			},
+			errMsg: "not in the workflow",
+		},
+		{
+			name: "self transition",
+			modify: func(d *WorkflowDefinition) {
+				d.Transitions = append(d.Transitions, WorkflowTransition{From: ShipmentStatusDelivered, To: ShipmentStatusDelivered})
+			},
+			errMsg: "to itself",
+		},
+		{
+			name: "duplicate transition",
+			modify: func(d *WorkflowDefinition) {
+				d.Transitions = append(d.Transitions, d.Transitions[0])
+			},
+			errMsg: "defined more than once",
+		},
+		{
+			name:   "unknown guard",
+			modify: func(d *WorkflowDefinition) { d.Transitions[0].Guards = []string{"moon_is_full"} },
+			errMsg: "unknown guard",
+		},
+		{
+			name:   "unknown effect",
+			modify: func(d *WorkflowDefinition) { d.Transitions[0].Effects = []string{"send_fax"} },
+			errMsg: "unknown effect",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			def := valid()
+			tt.modify(def)
+			err := def.Validate()
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() expected error containing %q", tt.errMsg)
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Validate() error = %v, want error containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
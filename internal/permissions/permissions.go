@@ -0,0 +1,136 @@
+// Package permissions is the central registry of what each role may do.
+//
+// Handlers, route registration and navigation all ask the registry instead of comparing roles,
+// so a link is shown exactly when the page behind it is allowed. Every permission has a default
+// set of roles; admins can grant or revoke permissions per role, and those changes are stored as
+// overrides in the role_permissions table.
+package permissions
+
+import "github.com/yourusername/laptop-tracking-system/internal/models"
+
+// Permission is an action a role can be allowed to perform
+type Permission string
+
+// Permission constants
+const (
+	DashboardView Permission = "dashboard.view"
+	ReportsView   Permission = "reports.view"
+
+	ShipmentView            Permission = "shipment.view"
+	ShipmentCreate          Permission = "shipment.create"
+	ShipmentEdit            Permission = "shipment.edit"
+	ShipmentUpdateStatus    Permission = "shipment.update_status"
+	ShipmentAssignEngineer  Permission = "shipment.assign_engineer"
+	ShipmentManagePackages  Permission = "shipment.manage_packages"
+	ShipmentRefreshTracking Permission = "shipment.refresh_tracking"
+	ShipmentPrintDocuments  Permission = "shipment.print_documents"
+
+	InventoryView   Permission = "inventory.view"
+	InventoryEdit   Permission = "inventory.edit"
+	InventoryDelete Permission = "inventory.delete"
+
+	ReceptionReportView    Permission = "reception_report.view"
+	ReceptionReportCreate  Permission = "reception_report.create"
+	ReceptionReportApprove Permission = "reception_report.approve"
+
+	MagicLinkManage        Permission = "magic_link.manage"
+	UserManage             Permission = "user.manage"
+	ClientCompanyManage    Permission = "client_company.manage"
+	SoftwareEngineerManage Permission = "software_engineer.manage"
+	CourierManage          Permission = "courier.manage"
+	WorkflowManage         Permission = "workflow.manage"
+	ServiceAccountManage   Permission = "service_account.manage"
+	PermissionManage       Permission = "permission.manage"
+	AuditLogView           Permission = "audit_log.view"
+	EmailOutboxManage      Permission = "email_outbox.manage"
+	EmailTemplateManage    Permission = "email_template.manage"
+)
+
+// Definition describes a permission and which roles have it unless an admin changed it
+type Definition struct {
+	Permission   Permission
+	Group        string
+	Description  string
+	DefaultRoles []models.UserRole
+}
+
+var (
+	logistics      = models.RoleLogistics
+	warehouse      = models.RoleWarehouse
+	client         = models.RoleClient
+	projectManager = models.RoleProjectManager
+	allRoles       = []models.UserRole{logistics, warehouse, client, projectManager}
+)
+
+// Definitions lists every permission, grouped in the order they are shown on the permissions page
+var Definitions = []Definition{
+	{DashboardView, "General", "View the dashboard and its charts", []models.UserRole{logistics, projectManager}},
+	{ReportsView, "General", "View reports", []models.UserRole{client, projectManager}},
+
+	{ShipmentView, "Shipments", "View shipments (clients only see their company's)", allRoles},
+	{ShipmentCreate, "Shipments", "Create shipments and pickup forms", []models.UserRole{logistics}},
+	{ShipmentEdit, "Shipments", "Edit shipment details", []models.UserRole{logistics}},
+	{ShipmentUpdateStatus, "Shipments", "Change shipment status", []models.UserRole{logistics}},
+	{ShipmentAssignEngineer, "Shipments", "Assign software engineers and laptops to shipments", []models.UserRole{logistics}},
+	{ShipmentManagePackages, "Shipments", "Add, edit and remove packages", []models.UserRole{logistics}},
+	{ShipmentRefreshTracking, "Shipments", "Refresh courier tracking", []models.UserRole{logistics}},
+	{ShipmentPrintDocuments, "Shipments", "Print packing slips and commercial invoices", []models.UserRole{logistics, warehouse}},
+
+	{InventoryView, "Inventory", "View laptops (clients only see their company's)", allRoles},
+	{InventoryEdit, "Inventory", "Add and edit laptops", []models.UserRole{logistics, warehouse}},
+	{InventoryDelete, "Inventory", "Delete laptops", []models.UserRole{logistics}},
+
+	{ReceptionReportView, "Reception Reports", "View reception reports", []models.UserRole{logistics, warehouse}},
+	{ReceptionReportCreate, "Reception Reports", "Submit reception reports", []models.UserRole{warehouse}},
+	{ReceptionReportApprove, "Reception Reports", "Approve reception reports", []models.UserRole{logistics}},
+
+	{MagicLinkManage, "Administration", "Send and list magic links", []models.UserRole{logistics}},
+	{UserManage, "Administration", "Manage users", []models.UserRole{logistics}},
+	{ClientCompanyManage, "Administration", "Manage client companies", []models.UserRole{logistics}},
+	{SoftwareEngineerManage, "Administration", "Manage software engineers", []models.UserRole{logistics}},
+	{CourierManage, "Administration", "Manage couriers", []models.UserRole{logistics}},
+	{WorkflowManage, "Administration", "Edit shipment workflows", []models.UserRole{logistics}},
+	{ServiceAccountManage, "Administration", "Manage service accounts and revoke any API token", []models.UserRole{logistics}},
+	{PermissionManage, "Administration", "Edit role permissions", []models.UserRole{logistics}},
+	{AuditLogView, "Administration", "View and export the audit log", []models.UserRole{logistics}},
+	{EmailOutboxManage, "Administration", "View and retry failed notification emails", []models.UserRole{logistics}},
+	{EmailTemplateManage, "Administration", "Edit notification email templates", []models.UserRole{logistics}},
+}
+
+// FormsPermissions are the permissions behind the cards of the forms page
+var FormsPermissions = []Permission{UserManage, ClientCompanyManage, SoftwareEngineerManage, CourierManage, WorkflowManage, PermissionManage, AuditLogView, EmailOutboxManage, EmailTemplateManage}
+
+// Lookup returns the definition of a permission
+func Lookup(p Permission) (Definition, bool) {
+	for _, d := range Definitions {
+		if d.Permission == p {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// IsValid checks if a permission is in the registry
+func IsValid(p Permission) bool {
+	_, ok := Lookup(p)
+	return ok
+}
+
+// DefaultGrants returns the roles each permission is granted to by default
+func DefaultGrants() map[models.UserRole]map[Permission]bool {
+	grants := map[models.UserRole]map[Permission]bool{}
+	for _, role := range allRoles {
+		grants[role] = map[Permission]bool{}
+	}
+	for _, d := range Definitions {
+		for _, role := range d.DefaultRoles {
+			grants[role][d.Permission] = true
+		}
+	}
+	return grants
+}
+
+// Roles returns the roles permissions are granted to, in the order they are shown
+func Roles() []models.UserRole {
+	return append([]models.UserRole(nil), allRoles...)
+}
@@ -0,0 +1,189 @@
+package permissions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func TestDefinitions(t *testing.T) {
+	seen := map[Permission]bool{}
+	for _, d := range Definitions {
+		if seen[d.Permission] {
+			t.Errorf("permission %q is defined twice", d.Permission)
+		}
+		seen[d.Permission] = true
+
+		if d.Group == "" || d.Description == "" {
+			t.Errorf("permission %q needs a group and a description", d.Permission)
+		}
+		for _, role := range d.DefaultRoles {
+			if !models.IsValidRole(role) {
+				t.Errorf("permission %q is granted to unknown role %q", d.Permission, role)
+			}
+		}
+	}
+
+	for _, p := range FormsPermissions {
+		if !IsValid(p) {
+			t.Errorf("forms permission %q is not defined", p)
+		}
+	}
+}
+
+func TestRegistry_Defaults(t *testing.T) {
+	r := NewRegistry()
+
+	tests := []struct {
+		role models.UserRole
+		perm Permission
+		want bool
+	}{
+		{models.RoleLogistics, ShipmentUpdateStatus, true},
+		{models.RoleLogistics, InventoryDelete, true},
+		{models.RoleLogistics, ReportsView, false},
+		{models.RoleWarehouse, InventoryEdit, true},
+		{models.RoleWarehouse, InventoryDelete, false},
+		{models.RoleWarehouse, ReceptionReportCreate, true},
+		{models.RoleWarehouse, ShipmentPrintDocuments, true},
+		{models.RoleClient, ShipmentView, true},
+		{models.RoleClient, ReportsView, true},
+		{models.RoleClient, ShipmentCreate, false},
+		{models.RoleProjectManager, DashboardView, true},
+		{models.RoleProjectManager, ShipmentEdit, false},
+		{models.UserRole("unknown"), ShipmentView, false},
+	}
+
+	for _, tt := range tests {
+		if got := r.Has(tt.role, tt.perm); got != tt.want {
+			t.Errorf("Has(%s, %s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_Can(t *testing.T) {
+	r := NewRegistry()
+
+	if r.Can(nil, ShipmentView) {
+		t.Error("anonymous users should have no permissions")
+	}
+
+	warehouse := &models.User{ID: 1, Role: models.RoleWarehouse}
+	if !r.CanAny(warehouse, InventoryDelete, InventoryEdit) {
+		t.Error("CanAny should be true when one permission is granted")
+	}
+	if r.CanAny(warehouse, InventoryDelete, UserManage) {
+		t.Error("CanAny should be false when no permission is granted")
+	}
+	if r.CanAny(warehouse) {
+		t.Error("CanAny without permissions should be false")
+	}
+}
+
+func TestRegistry_Apply(t *testing.T) {
+	r := NewRegistry()
+
+	r.Apply([]Override{
+		{Role: models.RoleWarehouse, Permission: InventoryDelete, Granted: true},
+		{Role: models.RoleClient, Permission: ReportsView, Granted: false},
+		{Role: models.RoleLogistics, Permission: PermissionManage, Granted: false},
+		{Role: models.UserRole("unknown"), Permission: ShipmentView, Granted: true},
+		{Role: models.RoleClient, Permission: Permission("unknown.permission"), Granted: true},
+	})
+
+	if !r.Has(models.RoleWarehouse, InventoryDelete) {
+		t.Error("override should grant inventory.delete to warehouse")
+	}
+	if r.Has(models.RoleClient, ReportsView) {
+		t.Error("override should revoke reports.view from client")
+	}
+	if !r.Has(models.RoleLogistics, PermissionManage) {
+		t.Error("logistics must keep permission.manage")
+	}
+	if r.Has(models.UserRole("unknown"), ShipmentView) {
+		t.Error("overrides of unknown roles should be ignored")
+	}
+
+	// Applying again starts from the defaults
+	r.Apply(nil)
+	if r.Has(models.RoleWarehouse, InventoryDelete) || !r.Has(models.RoleClient, ReportsView) {
+		t.Error("Apply(nil) should restore the defaults")
+	}
+}
+
+func TestRegistry_GrantsIsACopy(t *testing.T) {
+	r := NewRegistry()
+
+	grants := r.Grants()
+	grants[models.RoleClient][UserManage] = true
+
+	if r.Has(models.RoleClient, UserManage) {
+		t.Error("changing the returned grants should not change the registry")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	if overrides := Diff(DefaultGrants()); len(overrides) != 0 {
+		t.Errorf("Diff of the defaults = %v, want no overrides", overrides)
+	}
+
+	grants := DefaultGrants()
+	grants[models.RoleWarehouse][InventoryDelete] = true
+	grants[models.RoleClient][ReportsView] = false
+	grants[models.RoleLogistics][PermissionManage] = false
+
+	overrides := Diff(grants)
+	want := map[Override]bool{
+		{Role: models.RoleWarehouse, Permission: InventoryDelete, Granted: true}: true,
+		{Role: models.RoleClient, Permission: ReportsView, Granted: false}:       true,
+	}
+	if len(overrides) != len(want) {
+		t.Fatalf("Diff = %v, want %d overrides", overrides, len(want))
+	}
+	for _, o := range overrides {
+		if !want[o] {
+			t.Errorf("unexpected override %+v", o)
+		}
+	}
+
+	// Applying the diff gives back the grants, except the locked one
+	r := NewRegistry()
+	r.Apply(overrides)
+	if !r.Has(models.RoleWarehouse, InventoryDelete) || r.Has(models.RoleClient, ReportsView) {
+		t.Error("applying the diff should reproduce the grants")
+	}
+}
+
+func TestRegistry_RunReloadsOverrides(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	r := NewRegistry()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx, db, 10*time.Millisecond)
+
+	// Another instance revokes a permission
+	_, err := db.Exec(
+		`INSERT INTO role_permissions (role, permission, granted) VALUES ($1, $2, false)`,
+		models.RoleClient, ReportsView,
+	)
+	if err != nil {
+		t.Fatalf("Failed to save role permission: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Has(models.RoleClient, ReportsView) {
+		if time.Now().After(deadline) {
+			t.Fatal("revocation saved by another instance was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,226 @@
+package permissions
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// Override grants or revokes a permission for a role, replacing the default
+type Override struct {
+	Role       models.UserRole
+	Permission Permission
+	Granted    bool
+}
+
+// Registry holds the permissions granted to each role. It is safe for concurrent use.
+type Registry struct {
+	mu     sync.RWMutex
+	grants map[models.UserRole]map[Permission]bool
+}
+
+// NewRegistry creates a registry with the default grants
+func NewRegistry() *Registry {
+	return &Registry{grants: DefaultGrants()}
+}
+
+// isLocked reports whether a grant cannot be changed, so admins cannot lock everyone out of the permissions page
+func isLocked(role models.UserRole, p Permission) bool {
+	return role == models.RoleLogistics && p == PermissionManage
+}
+
+// IsLocked reports whether the grant of p to role is fixed
+func IsLocked(role models.UserRole, p Permission) bool {
+	return isLocked(role, p)
+}
+
+// Has reports whether the role is granted the permission
+func (r *Registry) Has(role models.UserRole, p Permission) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.grants[role][p]
+}
+
+// Can reports whether the user is granted the permission. Anonymous users have no permissions.
+func (r *Registry) Can(user *models.User, p Permission) bool {
+	return user != nil && r.Has(user.Role, p)
+}
+
+// CanAny reports whether the user is granted at least one of the permissions
+func (r *Registry) CanAny(user *models.User, perms ...Permission) bool {
+	for _, p := range perms {
+		if r.Can(user, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Grants returns a copy of the current grants
+func (r *Registry) Grants() map[models.UserRole]map[Permission]bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	grants := make(map[models.UserRole]map[Permission]bool, len(r.grants))
+	for role, perms := range r.grants {
+		grants[role] = make(map[Permission]bool, len(perms))
+		for p, granted := range perms {
+			grants[role][p] = granted
+		}
+	}
+	return grants
+}
+
+// Apply replaces the grants with the defaults changed by the overrides.
+// Overrides of unknown roles or permissions, and of locked grants, are ignored.
+func (r *Registry) Apply(overrides []Override) {
+	grants := DefaultGrants()
+	for _, o := range overrides {
+		if !models.IsValidRole(o.Role) || !IsValid(o.Permission) || isLocked(o.Role, o.Permission) {
+			continue
+		}
+		grants[o.Role][o.Permission] = o.Granted
+	}
+
+	r.mu.Lock()
+	r.grants = grants
+	r.mu.Unlock()
+}
+
+// Load reads the overrides from the database and applies them
+func (r *Registry) Load(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, "SELECT role, permission, granted FROM role_permissions")
+	if err != nil {
+		return fmt.Errorf("failed to query role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	var overrides []Override
+	for rows.Next() {
+		var o Override
+		if err := rows.Scan(&o.Role, &o.Permission, &o.Granted); err != nil {
+			return fmt.Errorf("failed to scan role permission: %w", err)
+		}
+		overrides = append(overrides, o)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating role permissions: %w", err)
+	}
+
+	r.Apply(overrides)
+	return nil
+}
+
+// Run reloads the overrides every interval and returns when ctx is canceled,
+// so changes saved by another instance take effect here too
+func (r *Registry) Run(ctx context.Context, db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Load(ctx, db); err != nil {
+				log.Printf("Warning: failed to reload role permissions: %v", err)
+			}
+		}
+	}
+}
+
+// Save stores the grants as overrides of the defaults and applies them.
+// Only grants that differ from the default are stored, so permissions added later get their defaults.
+func (r *Registry) Save(ctx context.Context, db *sql.DB, grants map[models.UserRole]map[Permission]bool, userID int64) error {
+	overrides := Diff(grants)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM role_permissions"); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+	for _, o := range overrides {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO role_permissions (role, permission, granted, updated_by_user_id, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())`,
+			o.Role, o.Permission, o.Granted, userID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save role permission: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role permissions: %w", err)
+	}
+
+	r.Apply(overrides)
+	return nil
+}
+
+// Diff returns the overrides that turn the default grants into grants
+func Diff(grants map[models.UserRole]map[Permission]bool) []Override {
+	defaults := DefaultGrants()
+	var overrides []Override
+	for _, role := range Roles() {
+		for _, d := range Definitions {
+			granted := grants[role][d.Permission]
+			if granted != defaults[role][d.Permission] && !isLocked(role, d.Permission) {
+				overrides = append(overrides, Override{Role: role, Permission: d.Permission, Granted: granted})
+			}
+		}
+	}
+	return overrides
+}
+
+// defaultRegistry is the registry used by the package-level functions
+var defaultRegistry = NewRegistry()
+
+// Default returns the registry used by the application
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Can reports whether the user is granted the permission in the default registry
+func Can(user *models.User, p Permission) bool {
+	return defaultRegistry.Can(user, p)
+}
+
+// CanAny reports whether the user is granted at least one of the permissions in the default registry
+func CanAny(user *models.User, perms ...Permission) bool {
+	return defaultRegistry.CanAny(user, perms...)
+}
+
+// Load loads the overrides stored in the database into the default registry
+func Load(ctx context.Context, db *sql.DB) error {
+	return defaultRegistry.Load(ctx, db)
+}
+
+// Run reloads the overrides into the default registry every interval
+func Run(ctx context.Context, db *sql.DB, interval time.Duration) {
+	defaultRegistry.Run(ctx, db, interval)
+}
+
+// LogDenied logs a request that was refused because the user lacks a permission
+func LogDenied(r *http.Request, user *models.User, perms ...Permission) {
+	if user == nil {
+		log.Printf("Permission denied: anonymous request lacks %v for %s %s", perms, r.Method, r.URL.Path)
+		return
+	}
+	who := fmt.Sprintf("user %d", user.ID)
+	if user.IsServiceAccount() {
+		who = fmt.Sprintf("service account %d", *user.ServiceAccountID)
+	}
+	log.Printf("Permission denied: %s (%s) lacks %v for %s %s", who, user.Role, perms, r.Method, r.URL.Path)
+}
@@ -0,0 +1,181 @@
+package tracking
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// FakeProviderName is the name of the local fake provider
+const FakeProviderName = "fake"
+
+// FakeSignatureHeader carries the hex HMAC-SHA256 of a fake webhook body
+const FakeSignatureHeader = "X-Tracking-Signature"
+
+// fakeProgression is the sequence of checkpoints the fake provider walks through when AutoProgress is on
+var fakeProgression = []struct {
+	status      models.TrackingCheckpointStatus
+	description string
+}{
+	{models.TrackingCheckpointLabelCreated, "Shipping label created"},
+	{models.TrackingCheckpointPickedUp, "Picked up by courier"},
+	{models.TrackingCheckpointInTransit, "Departed origin facility"},
+	{models.TrackingCheckpointOutForDelivery, "Out for delivery"},
+	{models.TrackingCheckpointDelivered, "Delivered"},
+}
+
+// FakeTracker is an in-memory CourierTracker for local development and tests.
+// Checkpoints are added with AddCheckpoint or pushed through its webhook; with AutoProgress
+// every poll moves each tracking number one step further towards delivery.
+type FakeTracker struct {
+	// AutoProgress adds the next simulated checkpoint on every Track call
+	AutoProgress bool
+
+	secret   string
+	couriers []string
+	now      func() time.Time
+
+	mu          sync.Mutex
+	checkpoints map[string][]Checkpoint
+}
+
+// NewFakeTracker creates a fake provider.
+// Webhook pushes must be signed with secret when it is not empty. The provider handles the listed
+// couriers (matched by substring, case-insensitive) or every courier when none are listed.
+func NewFakeTracker(secret string, couriers ...string) *FakeTracker {
+	return &FakeTracker{
+		secret:      secret,
+		couriers:    couriers,
+		now:         time.Now,
+		checkpoints: make(map[string][]Checkpoint),
+	}
+}
+
+// Name implements CourierTracker
+func (f *FakeTracker) Name() string {
+	return FakeProviderName
+}
+
+// Handles implements CourierTracker
+func (f *FakeTracker) Handles(courierName string) bool {
+	if len(f.couriers) == 0 {
+		return true
+	}
+	courierLower := strings.ToLower(courierName)
+	for _, c := range f.couriers {
+		if c != "" && strings.Contains(courierLower, strings.ToLower(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddCheckpoint records a checkpoint that later polls will return
+func (f *FakeTracker) AddCheckpoint(cp Checkpoint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if cp.OccurredAt.IsZero() {
+		cp.OccurredAt = f.now()
+	}
+	f.checkpoints[cp.TrackingNumber] = append(f.checkpoints[cp.TrackingNumber], cp)
+}
+
+// Track implements CourierTracker
+func (f *FakeTracker) Track(ctx context.Context, trackingNumber string) ([]Checkpoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	existing := f.checkpoints[trackingNumber]
+	if f.AutoProgress && len(existing) < len(fakeProgression) {
+		step := fakeProgression[len(existing)]
+		existing = append(existing, Checkpoint{
+			TrackingNumber: trackingNumber,
+			Status:         step.status,
+			ProviderCode:   string(step.status),
+			Description:    step.description,
+			Location:       "Fake Courier Hub",
+			OccurredAt:     f.now(),
+		})
+		f.checkpoints[trackingNumber] = existing
+	}
+
+	result := make([]Checkpoint, len(existing))
+	copy(result, existing)
+	return result, nil
+}
+
+// fakeWebhookPayload is the JSON body accepted by the fake webhook
+type fakeWebhookPayload struct {
+	Events []struct {
+		TrackingNumber string    `json:"tracking_number"`
+		Status         string    `json:"status"`
+		Description    string    `json:"description"`
+		Location       string    `json:"location"`
+		OccurredAt     time.Time `json:"occurred_at"`
+	} `json:"events"`
+}
+
+// ParseWebhook implements CourierTracker
+func (f *FakeTracker) ParseWebhook(r *http.Request) ([]Checkpoint, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook body: %w", err)
+	}
+
+	if f.secret != "" {
+		expected := f.Sign(body)
+		if !hmac.Equal([]byte(expected), []byte(strings.ToLower(r.Header.Get(FakeSignatureHeader)))) {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	var payload fakeWebhookPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	checkpoints := make([]Checkpoint, 0, len(payload.Events))
+	for _, e := range payload.Events {
+		if strings.TrimSpace(e.TrackingNumber) == "" {
+			continue
+		}
+		status := models.NormalizeTrackingCheckpointStatus(e.Status)
+		if status == models.TrackingCheckpointUnknown {
+			status = models.NormalizeTrackingCheckpointStatus(e.Description)
+		}
+		occurredAt := e.OccurredAt
+		if occurredAt.IsZero() {
+			occurredAt = f.now()
+		}
+		checkpoints = append(checkpoints, Checkpoint{
+			TrackingNumber: strings.TrimSpace(e.TrackingNumber),
+			Status:         status,
+			ProviderCode:   e.Status,
+			Description:    e.Description,
+			Location:       e.Location,
+			OccurredAt:     occurredAt,
+		})
+	}
+	return checkpoints, nil
+}
+
+// Sign returns the signature the fake webhook expects for a body
+func (f *FakeTracker) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(f.secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
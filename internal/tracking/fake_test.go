@@ -0,0 +1,91 @@
+package tracking
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func TestFakeTracker_TrackAutoProgress(t *testing.T) {
+	fake := NewFakeTracker("")
+	fake.AutoProgress = true
+
+	var checkpoints []Checkpoint
+	var err error
+	for i := 0; i < len(fakeProgression)+2; i++ {
+		checkpoints, err = fake.Track(context.Background(), "1Z999")
+		if err != nil {
+			t.Fatalf("Track() error = %v", err)
+		}
+	}
+
+	if len(checkpoints) != len(fakeProgression) {
+		t.Fatalf("Expected %d checkpoints, got %d", len(fakeProgression), len(checkpoints))
+	}
+	if last := checkpoints[len(checkpoints)-1]; last.Status != models.TrackingCheckpointDelivered {
+		t.Errorf("Expected the last checkpoint to be delivered, got %s", last.Status)
+	}
+}
+
+func TestFakeTracker_AddCheckpoint(t *testing.T) {
+	fake := NewFakeTracker("")
+	fake.AddCheckpoint(Checkpoint{TrackingNumber: "1Z999", Status: models.TrackingCheckpointPickedUp})
+
+	checkpoints, err := fake.Track(context.Background(), "1Z999")
+	if err != nil {
+		t.Fatalf("Track() error = %v", err)
+	}
+	if len(checkpoints) != 1 || checkpoints[0].OccurredAt.IsZero() {
+		t.Errorf("Expected one timestamped checkpoint, got %+v", checkpoints)
+	}
+
+	other, _ := fake.Track(context.Background(), "OTHER")
+	if len(other) != 0 {
+		t.Errorf("Expected no checkpoints for an unknown number without AutoProgress, got %d", len(other))
+	}
+}
+
+func TestFakeTracker_ParseWebhook(t *testing.T) {
+	fake := NewFakeTracker("secret")
+	body := []byte(`{"events":[
+		{"tracking_number":"1Z999","status":"Out for Delivery","location":"Austin, TX","occurred_at":"2026-01-05T10:00:00Z"},
+		{"tracking_number":"1Z999","status":"","description":"Package delivered to front desk"},
+		{"tracking_number":"","status":"delivered"}
+	]}`)
+
+	req := httptest.NewRequest("POST", "/webhooks/courier/fake", bytes.NewReader(body))
+	req.Header.Set(FakeSignatureHeader, fake.Sign(body))
+
+	checkpoints, err := fake.ParseWebhook(req)
+	if err != nil {
+		t.Fatalf("ParseWebhook() error = %v", err)
+	}
+	if len(checkpoints) != 2 {
+		t.Fatalf("Expected 2 checkpoints, got %d", len(checkpoints))
+	}
+	if checkpoints[0].Status != models.TrackingCheckpointOutForDelivery || checkpoints[0].Location != "Austin, TX" {
+		t.Errorf("Unexpected first checkpoint %+v", checkpoints[0])
+	}
+	if !checkpoints[0].OccurredAt.Equal(time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected checkpoint time %v", checkpoints[0].OccurredAt)
+	}
+	if checkpoints[1].Status != models.TrackingCheckpointDelivered {
+		t.Errorf("Expected status from description to be delivered, got %s", checkpoints[1].Status)
+	}
+}
+
+func TestFakeTracker_ParseWebhookRejectsBadSignature(t *testing.T) {
+	fake := NewFakeTracker("secret")
+	body := []byte(`{"events":[{"tracking_number":"1Z999","status":"delivered"}]}`)
+
+	req := httptest.NewRequest("POST", "/webhooks/courier/fake", bytes.NewReader(body))
+	req.Header.Set(FakeSignatureHeader, "deadbeef")
+
+	if _, err := fake.ParseWebhook(req); err != ErrInvalidSignature {
+		t.Errorf("Expected ErrInvalidSignature, got %v", err)
+	}
+}
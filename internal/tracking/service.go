@@ -0,0 +1,320 @@
+package tracking
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/workflow"
+)
+
+// IngestResult summarizes what happened to a batch of checkpoints
+type IngestResult struct {
+	Recorded   int      // New checkpoints stored on a shipment
+	Duplicates int      // Checkpoints that were already stored
+	Unmatched  int      // Checkpoints whose tracking number belongs to no shipment
+	Advanced   []string // Status changes made from the checkpoints, e.g. "shipment 12: in_transit_to_warehouse"
+}
+
+func (r *IngestResult) add(other *IngestResult) {
+	r.Recorded += other.Recorded
+	r.Duplicates += other.Duplicates
+	r.Unmatched += other.Unmatched
+	r.Advanced = append(r.Advanced, other.Advanced...)
+}
+
+// Service stores courier checkpoints on shipments and optionally advances their status
+type Service struct {
+	DB       *sql.DB
+	Registry *Registry
+	Engine   *workflow.Engine
+
+	// AutoAdvance moves shipments along the workflow when a checkpoint shows progress
+	AutoAdvance bool
+}
+
+// NewService creates a new tracking Service
+func NewService(db *sql.DB, registry *Registry, engine *workflow.Engine, autoAdvance bool) *Service {
+	return &Service{
+		DB:          db,
+		Registry:    registry,
+		Engine:      engine,
+		AutoAdvance: autoAdvance,
+	}
+}
+
+// trackedNumber is a tracking number of a shipment or one of its packages
+type trackedNumber struct {
+	ShipmentID     int64
+	PackageID      *int64
+	CourierName    string
+	TrackingNumber string
+}
+
+// HandleWebhook verifies and ingests a webhook push for the named provider
+func (s *Service) HandleWebhook(ctx context.Context, providerName string, r *http.Request) (*IngestResult, error) {
+	tracker, err := s.Registry.Get(providerName)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := tracker.ParseWebhook(r)
+	if err != nil {
+		return nil, err
+	}
+	return s.Ingest(ctx, tracker.Name(), models.TrackingReceivedViaWebhook, checkpoints)
+}
+
+// Ingest stores checkpoints on the shipments whose shipment or package tracking number they carry.
+// New checkpoints advance the shipment status when AutoAdvance is on.
+func (s *Service) Ingest(ctx context.Context, provider, via string, checkpoints []Checkpoint) (*IngestResult, error) {
+	result := &IngestResult{Advanced: []string{}}
+
+	for _, cp := range checkpoints {
+		matches, err := s.matchTrackingNumber(ctx, cp.TrackingNumber)
+		if err != nil {
+			return result, err
+		}
+		if len(matches) == 0 {
+			result.Unmatched++
+			continue
+		}
+
+		for _, m := range matches {
+			inserted, err := models.RecordShipmentTrackingEvent(ctx, s.DB, &models.ShipmentTrackingEvent{
+				ShipmentID:     m.ShipmentID,
+				PackageID:      m.PackageID,
+				Provider:       provider,
+				TrackingNumber: cp.TrackingNumber,
+				Status:         cp.Status,
+				ProviderCode:   cp.ProviderCode,
+				Description:    cp.Description,
+				Location:       cp.Location,
+				OccurredAt:     cp.OccurredAt,
+				ReceivedVia:    via,
+			})
+			if err != nil {
+				return result, err
+			}
+			if !inserted {
+				result.Duplicates++
+				continue
+			}
+			result.Recorded++
+
+			if !s.AutoAdvance || s.Engine == nil {
+				continue
+			}
+			advanced, err := s.advance(ctx, m.ShipmentID, cp)
+			for _, status := range advanced {
+				result.Advanced = append(result.Advanced, fmt.Sprintf("shipment %d: %s", m.ShipmentID, status))
+			}
+			if err != nil {
+				// One shipment failing to advance should not drop the rest of the batch
+				log.Printf("Warning: failed to advance shipment %d from courier checkpoint: %v", m.ShipmentID, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// advance walks the shipment through the statuses a checkpoint allows.
+// Checkpoints older than the shipment's last status change are ignored, so late deliveries
+// of an earlier leg cannot move the shipment again.
+func (s *Service) advance(ctx context.Context, shipmentID int64, cp Checkpoint) ([]models.ShipmentStatus, error) {
+	path := StatusPath(cp.Status)
+	if len(path) == 0 {
+		return nil, nil
+	}
+
+	var lastChange sql.NullTime
+	if err := s.DB.QueryRowContext(ctx,
+		`SELECT MAX(occurred_at) FROM shipment_status_events WHERE shipment_id = $1`,
+		shipmentID,
+	).Scan(&lastChange); err != nil {
+		return nil, fmt.Errorf("failed to load last status change: %w", err)
+	}
+	if lastChange.Valid && cp.OccurredAt.Before(lastChange.Time) {
+		return nil, nil
+	}
+
+	comment := "Courier checkpoint: " + models.GetTrackingCheckpointStatusLabel(cp.Status)
+	if cp.Description != "" {
+		comment = "Courier checkpoint: " + cp.Description
+	}
+	if cp.Location != "" {
+		comment += " (" + cp.Location + ")"
+	}
+
+	advanced := []models.ShipmentStatus{}
+	for _, target := range path {
+		_, err := s.Engine.Transition(ctx, shipmentID, target, workflow.TransitionInput{
+			Source:  models.StatusEventSourceCourier,
+			Comment: comment,
+		})
+		if err == nil {
+			advanced = append(advanced, target)
+			continue
+		}
+		if errors.Is(err, workflow.ErrInvalidTransition) {
+			continue
+		}
+		var guardErr *workflow.GuardError
+		if errors.As(err, &guardErr) {
+			// The shipment needs manual input before it can move on
+			log.Printf("Courier checkpoint for shipment %d blocked by %s: %s", shipmentID, guardErr.Guard, guardErr.Message)
+			return advanced, nil
+		}
+		return advanced, err
+	}
+	return advanced, nil
+}
+
+// matchTrackingNumber finds the shipments and packages carrying a tracking number
+func (s *Service) matchTrackingNumber(ctx context.Context, trackingNumber string) ([]trackedNumber, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT p.shipment_id, p.id
+		FROM shipment_packages p
+		WHERE p.tracking_number = $1
+		UNION ALL
+		SELECT s.id, NULL
+		FROM shipments s
+		WHERE s.tracking_number = $1
+		  AND NOT EXISTS (SELECT 1 FROM shipment_packages p WHERE p.shipment_id = s.id AND p.tracking_number = $1)`,
+		trackingNumber,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match tracking number: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []trackedNumber{}
+	for rows.Next() {
+		var m trackedNumber
+		var packageID sql.NullInt64
+		if err := rows.Scan(&m.ShipmentID, &packageID); err != nil {
+			return nil, fmt.Errorf("failed to scan tracking number match: %w", err)
+		}
+		if packageID.Valid {
+			m.PackageID = &packageID.Int64
+		}
+		m.TrackingNumber = trackingNumber
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+// trackedNumbers lists the tracking numbers of the shipments matching a condition on s
+func (s *Service) trackedNumbers(ctx context.Context, condition string, args ...interface{}) ([]trackedNumber, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		`SELECT s.id, p.id, COALESCE(NULLIF(p.courier_name, ''), s.courier_name, ''), p.tracking_number
+		FROM shipment_packages p
+		JOIN shipments s ON s.id = p.shipment_id
+		WHERE COALESCE(p.tracking_number, '') <> '' AND `+condition+`
+		UNION ALL
+		SELECT s.id, NULL, COALESCE(s.courier_name, ''), s.tracking_number
+		FROM shipments s
+		WHERE COALESCE(s.tracking_number, '') <> '' AND `+condition+`
+		  AND NOT EXISTS (SELECT 1 FROM shipment_packages p WHERE p.shipment_id = s.id AND p.tracking_number = s.tracking_number)`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracking numbers: %w", err)
+	}
+	defer rows.Close()
+
+	numbers := []trackedNumber{}
+	for rows.Next() {
+		var n trackedNumber
+		var packageID sql.NullInt64
+		if err := rows.Scan(&n.ShipmentID, &packageID, &n.CourierName, &n.TrackingNumber); err != nil {
+			return nil, fmt.Errorf("failed to scan tracking number: %w", err)
+		}
+		if packageID.Valid {
+			n.PackageID = &packageID.Int64
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, rows.Err()
+}
+
+// PollShipment polls the courier of every tracking number of one shipment
+func (s *Service) PollShipment(ctx context.Context, shipmentID int64) (*IngestResult, error) {
+	numbers, err := s.trackedNumbers(ctx, `s.id = $1`, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	return s.poll(ctx, numbers)
+}
+
+// PollActive polls the couriers of all shipments that are still moving
+func (s *Service) PollActive(ctx context.Context) (*IngestResult, error) {
+	numbers, err := s.trackedNumbers(ctx,
+		`s.status NOT IN ('delivered', 'at_warehouse', 'returned_to_sender', 'cancelled')`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s.poll(ctx, numbers)
+}
+
+// poll asks each number's provider for its checkpoints and ingests them
+func (s *Service) poll(ctx context.Context, numbers []trackedNumber) (*IngestResult, error) {
+	result := &IngestResult{Advanced: []string{}}
+	seen := map[string]bool{}
+
+	for _, n := range numbers {
+		if seen[n.TrackingNumber] {
+			continue
+		}
+		seen[n.TrackingNumber] = true
+
+		tracker := s.Registry.ForCourier(n.CourierName)
+		if tracker == nil {
+			continue
+		}
+		checkpoints, err := tracker.Track(ctx, n.TrackingNumber)
+		if err != nil {
+			log.Printf("Warning: failed to poll %s for tracking number %s: %v", tracker.Name(), n.TrackingNumber, err)
+			continue
+		}
+		batch, err := s.Ingest(ctx, tracker.Name(), models.TrackingReceivedViaPoll, checkpoints)
+		if batch != nil {
+			result.add(batch)
+		}
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// Run polls active shipments every interval and returns when the context is cancelled.
+// Status changes go through the workflow engine, which queues their notifications in the email outbox.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	if s.Registry.Empty() || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			result, err := s.PollActive(ctx)
+			if err != nil {
+				log.Printf("Warning: courier tracking poll failed: %v", err)
+				continue
+			}
+			if result.Recorded > 0 {
+				log.Printf("Courier tracking poll recorded %d checkpoint(s), %d status change(s)", result.Recorded, len(result.Advanced))
+			}
+		}
+	}
+}
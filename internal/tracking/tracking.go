@@ -0,0 +1,142 @@
+// Package tracking connects shipments to courier tracking providers.
+// A CourierTracker polls a courier's tracking API or parses its webhook pushes; the Service
+// stores the normalized checkpoints on the shipment and can advance its status through the workflow.
+package tracking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/config"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+var (
+	// ErrProviderNotFound is returned when no tracker is registered under a name
+	ErrProviderNotFound = errors.New("tracking provider not found")
+
+	// ErrInvalidSignature is returned when a webhook push fails the provider's signature check
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// ErrWebhooksNotSupported is returned by trackers that can only be polled
+	ErrWebhooksNotSupported = errors.New("tracking provider does not support webhooks")
+)
+
+// Checkpoint is one tracking update reported by a courier
+type Checkpoint struct {
+	TrackingNumber string
+	Status         models.TrackingCheckpointStatus
+	ProviderCode   string // Code or status text as reported by the courier
+	Description    string
+	Location       string
+	OccurredAt     time.Time
+}
+
+// CourierTracker is implemented by every courier tracking provider
+type CourierTracker interface {
+	// Name identifies the provider in configuration, webhook URLs and stored events
+	Name() string
+
+	// Handles returns true if the provider tracks shipments of the given courier
+	Handles(courierName string) bool
+
+	// Track polls the courier for all checkpoints of a tracking number
+	Track(ctx context.Context, trackingNumber string) ([]Checkpoint, error)
+
+	// ParseWebhook verifies a webhook push and returns the checkpoints it carries
+	ParseWebhook(r *http.Request) ([]Checkpoint, error)
+}
+
+// Registry holds the configured tracking providers
+type Registry struct {
+	trackers []CourierTracker
+}
+
+// NewRegistry creates a Registry with the given providers.
+// When several providers handle the same courier, the first one wins.
+func NewRegistry(trackers ...CourierTracker) *Registry {
+	return &Registry{trackers: trackers}
+}
+
+// Get returns the provider registered under a name
+func (r *Registry) Get(name string) (CourierTracker, error) {
+	for _, t := range r.trackers {
+		if strings.EqualFold(t.Name(), name) {
+			return t, nil
+		}
+	}
+	return nil, ErrProviderNotFound
+}
+
+// ForCourier returns the provider that tracks a courier, or nil if none does
+func (r *Registry) ForCourier(courierName string) CourierTracker {
+	if strings.TrimSpace(courierName) == "" {
+		return nil
+	}
+	for _, t := range r.trackers {
+		if t.Handles(courierName) {
+			return t
+		}
+	}
+	return nil
+}
+
+// Empty returns true if no provider is configured
+func (r *Registry) Empty() bool {
+	return r == nil || len(r.trackers) == 0
+}
+
+// statusPaths lists, per checkpoint, the shipment statuses a courier update may move a shipment through.
+// They are tried in order and each one only applies when the workflow allows it from the current status,
+// so a single checkpoint can catch a shipment up over skipped steps but never past the courier's leg.
+var statusPaths = map[models.TrackingCheckpointStatus][]models.ShipmentStatus{
+	models.TrackingCheckpointPickedUp: {
+		models.ShipmentStatusPickedUpFromClient,
+		models.ShipmentStatusPickedUpFromEngineer,
+	},
+	models.TrackingCheckpointInTransit: {
+		models.ShipmentStatusPickedUpFromClient,
+		models.ShipmentStatusPickedUpFromEngineer,
+		models.ShipmentStatusInTransitToWarehouse,
+		models.ShipmentStatusInTransitToEngineer,
+	},
+	models.TrackingCheckpointOutForDelivery: {
+		models.ShipmentStatusInTransitToEngineer,
+	},
+	models.TrackingCheckpointDelivered: {
+		models.ShipmentStatusReturnKitDelivered,
+		models.ShipmentStatusInTransitToEngineer,
+		models.ShipmentStatusDelivered,
+	},
+}
+
+// StatusPath returns the shipment statuses a checkpoint can advance a shipment through, in order
+func StatusPath(status models.TrackingCheckpointStatus) []models.ShipmentStatus {
+	return statusPaths[status]
+}
+
+// NewRegistryFromConfig builds the registry for the configured provider.
+// An empty provider name returns an empty registry, which disables courier tracking.
+func NewRegistryFromConfig(cfg config.CourierTrackingConfig) (*Registry, error) {
+	couriers := []string{}
+	for _, c := range strings.Split(cfg.Couriers, ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			couriers = append(couriers, c)
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(cfg.Provider)) {
+	case "":
+		return NewRegistry(), nil
+	case FakeProviderName:
+		fake := NewFakeTracker(cfg.WebhookSecret, couriers...)
+		fake.AutoProgress = true
+		return NewRegistry(fake), nil
+	default:
+		return NewRegistry(), fmt.Errorf("unknown courier tracking provider %q", cfg.Provider)
+	}
+}
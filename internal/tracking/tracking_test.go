@@ -0,0 +1,74 @@
+package tracking
+
+import (
+	"testing"
+
+	"github.com/yourusername/laptop-tracking-system/internal/config"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+func TestRegistry_ForCourier(t *testing.T) {
+	ups := NewFakeTracker("", "UPS")
+	registry := NewRegistry(ups)
+
+	if got := registry.ForCourier("UPS Next Day Air"); got != ups {
+		t.Errorf("Expected the UPS tracker for a UPS service level, got %v", got)
+	}
+	if got := registry.ForCourier("DHL"); got != nil {
+		t.Errorf("Expected no tracker for DHL, got %v", got)
+	}
+	if got := registry.ForCourier(""); got != nil {
+		t.Errorf("Expected no tracker for an empty courier, got %v", got)
+	}
+}
+
+func TestRegistry_Get(t *testing.T) {
+	registry := NewRegistry(NewFakeTracker(""))
+
+	if _, err := registry.Get("FAKE"); err != nil {
+		t.Errorf("Expected provider lookup to ignore case, got %v", err)
+	}
+	if _, err := registry.Get("acme"); err != ErrProviderNotFound {
+		t.Errorf("Expected ErrProviderNotFound, got %v", err)
+	}
+}
+
+func TestNewRegistryFromConfig(t *testing.T) {
+	registry, err := NewRegistryFromConfig(config.CourierTrackingConfig{})
+	if err != nil || !registry.Empty() {
+		t.Errorf("Expected an empty registry without a provider, got %v (err %v)", registry, err)
+	}
+
+	registry, err = NewRegistryFromConfig(config.CourierTrackingConfig{Provider: "fake", Couriers: "UPS, FedEx"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if registry.ForCourier("FedEx Express") == nil {
+		t.Error("Expected the fake provider to handle FedEx")
+	}
+	if registry.ForCourier("DHL") != nil {
+		t.Error("Expected the fake provider not to handle DHL")
+	}
+
+	registry, err = NewRegistryFromConfig(config.CourierTrackingConfig{Provider: "acme"})
+	if err == nil || !registry.Empty() {
+		t.Error("Expected an error and an empty registry for an unknown provider")
+	}
+}
+
+func TestStatusPath(t *testing.T) {
+	path := StatusPath(models.TrackingCheckpointInTransit)
+	if len(path) == 0 || path[0] != models.ShipmentStatusPickedUpFromClient {
+		t.Errorf("Expected in transit to start by catching up the pickup, got %v", path)
+	}
+
+	for _, status := range StatusPath(models.TrackingCheckpointDelivered) {
+		if status == models.ShipmentStatusAtWarehouse {
+			t.Error("Courier delivery must not mark a shipment as received at the warehouse")
+		}
+	}
+
+	if path := StatusPath(models.TrackingCheckpointException); len(path) != 0 {
+		t.Errorf("Expected exceptions to be left for manual handling, got %v", path)
+	}
+}
@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"errors"
+)
+
+// EngineerToWarehouseFormInput represents the form data for engineer-to-warehouse (return) shipments
+type EngineerToWarehouseFormInput struct {
+	LaptopID            int64
+	SoftwareEngineerID  int64
+	EngineerEmail       string
+	PickupAddress       string
+	PickupCity          string
+	PickupCountry       string // Required for international addresses
+	PickupState         string // Optional for international addresses
+	PickupZip           string // Optional for international addresses (postal code)
+	CourierName         string // Courier used for the return kit
+	TrackingNumber      string // Tracking number of the return kit
+	JiraTicketNumber    string
+	SpecialInstructions string
+}
+
+// ValidateEngineerToWarehouseForm validates the engineer-to-warehouse shipment form
+func ValidateEngineerToWarehouseForm(input EngineerToWarehouseFormInput) error {
+	// Software engineer validation (REQUIRED)
+	if input.SoftwareEngineerID == 0 {
+		return errors.New("software engineer is required")
+	}
+
+	// Laptop selection validation (REQUIRED)
+	if input.LaptopID == 0 {
+		return errors.New("laptop selection is required")
+	}
+
+	// Pickup address validation (international format, the engineer's address)
+	if err := validateInternationalAddress(input.PickupAddress, input.PickupCity, input.PickupCountry, input.PickupState, input.PickupZip); err != nil {
+		return err
+	}
+
+	// Return kit courier information is optional, it can be added later
+
+	// JIRA ticket validation
+	if err := validateJiraTicket(input.JiraTicketNumber); err != nil {
+		return err
+	}
+
+	return nil
+}
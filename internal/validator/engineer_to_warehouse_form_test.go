@@ -0,0 +1,86 @@
+package validator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateEngineerToWarehouseForm(t *testing.T) {
+	valid := func() EngineerToWarehouseFormInput {
+		return EngineerToWarehouseFormInput{
+			LaptopID:           1,
+			SoftwareEngineerID: 5,
+			EngineerEmail:      "jane@bairesdev.com",
+			PickupAddress:      "456 Tech Ave",
+			PickupCity:         "Buenos Aires",
+			PickupCountry:      "Argentina",
+			CourierName:        "FedEx",
+			TrackingNumber:     "123456789",
+			JiraTicketNumber:   "SCOP-12345",
+		}
+	}
+
+	tests := []struct {
+		name          string
+		modify        func(in *EngineerToWarehouseFormInput)
+		shouldBeValid bool
+		errorContains string
+	}{
+		{
+			name:          "valid engineer to warehouse form",
+			modify:        func(in *EngineerToWarehouseFormInput) {},
+			shouldBeValid: true,
+		},
+		{
+			name:          "invalid - missing engineer",
+			modify:        func(in *EngineerToWarehouseFormInput) { in.SoftwareEngineerID = 0 },
+			errorContains: "software engineer is required",
+		},
+		{
+			name:          "invalid - missing laptop",
+			modify:        func(in *EngineerToWarehouseFormInput) { in.LaptopID = 0 },
+			errorContains: "laptop selection is required",
+		},
+		{
+			name:          "invalid - missing pickup address",
+			modify:        func(in *EngineerToWarehouseFormInput) { in.PickupAddress = "" },
+			errorContains: "address",
+		},
+		{
+			name:          "invalid - missing pickup country",
+			modify:        func(in *EngineerToWarehouseFormInput) { in.PickupCountry = "" },
+			errorContains: "country",
+		},
+		{
+			name: "valid - return kit courier info optional",
+			modify: func(in *EngineerToWarehouseFormInput) {
+				in.CourierName = ""
+				in.TrackingNumber = ""
+			},
+			shouldBeValid: true,
+		},
+		{
+			name:          "invalid - bad JIRA ticket",
+			modify:        func(in *EngineerToWarehouseFormInput) { in.JiraTicketNumber = "scop12345" },
+			errorContains: "JIRA ticket",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := valid()
+			tt.modify(&input)
+			err := ValidateEngineerToWarehouseForm(input)
+			if tt.shouldBeValid && err != nil {
+				t.Errorf("Expected valid, got error: %v", err)
+			}
+			if !tt.shouldBeValid {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				} else if tt.errorContains != "" && !strings.Contains(err.Error(), tt.errorContains) {
+					t.Errorf("Expected error to contain '%s', got: %v", tt.errorContains, err)
+				}
+			}
+		})
+	}
+}
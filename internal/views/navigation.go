@@ -1,6 +1,9 @@
 package views
 
-import "github.com/yourusername/laptop-tracking-system/internal/models"
+import (
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
+)
 
 // NavigationLinks represents which navigation links should be visible to a user
 type NavigationLinks struct {
@@ -15,60 +18,33 @@ type NavigationLinks struct {
 	Reports          bool
 }
 
-// GetNavigationLinks returns the navigation links visible to a user based on their role
+// GetNavigationLinks returns the navigation links visible to a user based on the permissions of their role.
+// Each link is shown exactly when the role is granted the permission its page checks.
 func GetNavigationLinks(role models.UserRole) NavigationLinks {
-	nav := NavigationLinks{}
-
-	switch role {
-	case models.RoleLogistics:
-		// Logistics has full access to all navigation links
-		nav.Dashboard = true
-		nav.Shipments = true
-		nav.Inventory = true
-		nav.Calendar = false // Calendar link removed
-		nav.PickupForms = true
-		nav.ReceptionReports = true
-		nav.MagicLinks = true
-		nav.Forms = true
-
-	case models.RoleProjectManager:
-		// Project Manager has access to dashboards and reports
-		nav.Dashboard = true
-		nav.Shipments = true
-		nav.Inventory = true
-		nav.Calendar = false // Calendar link removed
-		nav.PickupForms = false
-		nav.ReceptionReports = false
-		nav.MagicLinks = false
-		nav.Reports = true // Project Manager can access reports
-
-	case models.RoleWarehouse:
-		// Warehouse has access to inventory and reception
-		nav.Dashboard = false
-		nav.Shipments = true
-		nav.Inventory = true
-		nav.Calendar = false // Calendar link removed
-		nav.PickupForms = false
-		nav.ReceptionReports = true
-		nav.MagicLinks = false
-
-	case models.RoleClient:
-		// Client has limited access - can see inventory but only their company's laptops
-		nav.Dashboard = false
-		nav.Shipments = true
-		nav.Inventory = true // Client users can view their company's laptops
-		nav.Calendar = false // Calendar link removed
-		nav.PickupForms = false // Pickup Forms removed for Client users
-		nav.ReceptionReports = false
-		nav.MagicLinks = false
-		nav.Reports = true // Client users can access reports
+	registry := permissions.Default()
+	has := func(perms ...permissions.Permission) bool {
+		for _, p := range perms {
+			if registry.Has(role, p) {
+				return true
+			}
+		}
+		return false
 	}
 
-	return nav
+	return NavigationLinks{
+		Dashboard:        has(permissions.DashboardView),
+		Shipments:        has(permissions.ShipmentView),
+		Inventory:        has(permissions.InventoryView),
+		Calendar:         false, // Calendar link removed
+		PickupForms:      has(permissions.ShipmentCreate),
+		ReceptionReports: has(permissions.ReceptionReportView),
+		MagicLinks:       has(permissions.MagicLinkManage),
+		Forms:            has(permissions.FormsPermissions...),
+		Reports:          has(permissions.ReportsView),
+	}
 }
 
 // HasAnyLink returns true if at least one navigation link is visible
 func (n NavigationLinks) HasAnyLink() bool {
 	return n.Dashboard || n.Shipments || n.Inventory || n.Calendar || n.PickupForms || n.ReceptionReports || n.MagicLinks || n.Forms || n.Reports
 }
-
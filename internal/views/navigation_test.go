@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 )
 
 func TestGetNavigationLinks(t *testing.T) {
@@ -152,3 +153,28 @@ func TestNavigationLinks_HasAnyLink(t *testing.T) {
 		})
 	}
 }
+
+// TestGetNavigationLinks_FollowsPermissions tests that links follow permission changes made by admins
+func TestGetNavigationLinks_FollowsPermissions(t *testing.T) {
+	registry := permissions.Default()
+	defer registry.Apply(nil)
+
+	registry.Apply([]permissions.Override{
+		{Role: models.RoleWarehouse, Permission: permissions.DashboardView, Granted: true},
+		{Role: models.RoleWarehouse, Permission: permissions.ReceptionReportView, Granted: false},
+		{Role: models.RoleClient, Permission: permissions.CourierManage, Granted: true},
+	})
+
+	warehouse := GetNavigationLinks(models.RoleWarehouse)
+	if !warehouse.Dashboard {
+		t.Error("warehouse should see the dashboard link once dashboard.view is granted")
+	}
+	if warehouse.ReceptionReports {
+		t.Error("warehouse should not see reception reports once reception_report.view is revoked")
+	}
+
+	client := GetNavigationLinks(models.RoleClient)
+	if !client.Forms {
+		t.Error("client should see the forms link when granted any forms permission")
+	}
+}
@@ -0,0 +1,135 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// effect is the implementation behind an effect name used in workflow definitions.
+// An effect can hook into any of the three phases of a transition.
+type effect struct {
+	// mutate changes the in-memory shipment before it is written
+	mutate func(tc *TransitionContext)
+
+	// persist runs inside the transaction that writes the new status
+	persist func(ctx context.Context, tx *sql.Tx, tc *TransitionContext) error
+
+	// notify is the notification queued in the email outbox by the same transaction
+	notify string
+}
+
+// effects maps effect names to their implementations
+var effects = map[string]effect{
+	models.WorkflowEffectSetTimestamps: {
+		mutate: func(tc *TransitionContext) {
+			tc.Shipment.UpdateStatusWithETA(tc.To, tc.Input.ETA)
+		},
+	},
+	models.WorkflowEffectSyncLaptopStatus: {
+		persist: syncLaptopStatus,
+	},
+	models.WorkflowEffectRecordException: {
+		mutate:  recordException,
+		persist: saveExceptionDetails,
+	},
+	models.WorkflowEffectClearException: {
+		mutate:  clearException,
+		persist: saveExceptionDetails,
+	},
+	models.WorkflowEffectUnassignEngineer: {
+		persist: unassignEngineer,
+	},
+	models.WorkflowEffectNotifyPickupScheduled: {
+		notify: email.KindPickupScheduled,
+	},
+	models.WorkflowEffectNotifyWarehousePreAlert: {
+		notify: email.KindWarehousePreAlert,
+	},
+	models.WorkflowEffectNotifyShipmentPickedUp: {
+		notify: email.KindShipmentPickedUp,
+	},
+	models.WorkflowEffectNotifyRelease: {
+		notify: email.KindReleaseNotification,
+	},
+	models.WorkflowEffectNotifyInTransitToEngineer: {
+		notify: email.KindInTransitToEngineer,
+	},
+	models.WorkflowEffectNotifyDeliveryConfirmation: {
+		notify: email.KindDeliveryConfirmation,
+	},
+	models.WorkflowEffectNotifyEngineerDeliveryToClient: {
+		notify: email.KindEngineerDeliveryNotificationToClient,
+	},
+}
+
+// syncLaptopStatus sets every laptop in the shipment to the laptop status matching the new shipment status.
+// Shipment types that don't sync laptop status (bulk) are left untouched.
+func syncLaptopStatus(ctx context.Context, tx *sql.Tx, tc *TransitionContext) error {
+	laptopStatus := tc.Shipment.GetLaptopStatusForShipmentStatus()
+	if laptopStatus == "" {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`UPDATE laptops l
+		SET status = $1, updated_at = $2
+		FROM shipment_laptops sl
+		WHERE sl.laptop_id = l.id
+		AND sl.shipment_id = $3`,
+		laptopStatus, time.Now(), tc.Shipment.ID,
+	)
+	return err
+}
+
+// unassignEngineer removes the engineer assignment from every laptop in the shipment.
+// Used when a returned laptop arrives back at the warehouse.
+func unassignEngineer(ctx context.Context, tx *sql.Tx, tc *TransitionContext) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE laptops l
+		SET software_engineer_id = NULL, updated_at = $1
+		FROM shipment_laptops sl
+		WHERE sl.laptop_id = l.id
+		AND sl.shipment_id = $2`,
+		time.Now(), tc.Shipment.ID,
+	)
+	return err
+}
+
+// recordException stores the exception reason and remembers the normal flow status being left.
+// Moving from one exception to another keeps the original status so the shipment can still resume it.
+func recordException(tc *TransitionContext) {
+	now := time.Now()
+	if !models.IsExceptionStatus(tc.From) {
+		from := tc.From
+		tc.Shipment.StatusBeforeException = &from
+	}
+	tc.Shipment.ExceptionReason = strings.TrimSpace(tc.Input.Reason)
+	tc.Shipment.ExceptionAt = &now
+}
+
+// clearException removes the exception details when a shipment resumes the normal flow
+func clearException(tc *TransitionContext) {
+	tc.Shipment.StatusBeforeException = nil
+	tc.Shipment.ExceptionReason = ""
+	tc.Shipment.ExceptionAt = nil
+}
+
+// saveExceptionDetails writes the shipment's exception columns
+func saveExceptionDetails(ctx context.Context, tx *sql.Tx, tc *TransitionContext) error {
+	var reason sql.NullString
+	if tc.Shipment.ExceptionReason != "" {
+		reason = sql.NullString{String: tc.Shipment.ExceptionReason, Valid: true}
+	}
+	_, err := tx.ExecContext(ctx,
+		`UPDATE shipments
+		SET exception_reason = $1, exception_at = $2, status_before_exception = $3
+		WHERE id = $4`,
+		reason, tc.Shipment.ExceptionAt, tc.Shipment.StatusBeforeException, tc.Shipment.ID,
+	)
+	return err
+}
@@ -0,0 +1,328 @@
+// Package workflow drives shipment status changes from data-driven workflow definitions.
+// Each transition in a models.WorkflowDefinition names the guards that must pass and the
+// effects that run when it is taken; this package holds the implementations behind those names.
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/email"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+var (
+	// ErrShipmentNotFound is returned when the shipment being transitioned does not exist
+	ErrShipmentNotFound = errors.New("shipment not found")
+
+	// ErrInvalidTransition is returned when the workflow has no transition between the two statuses
+	ErrInvalidTransition = errors.New("status transition is not allowed by the workflow")
+
+	// ErrConcurrentUpdate is returned when the shipment status changed while the transition was running
+	ErrConcurrentUpdate = errors.New("shipment status was changed by another request")
+)
+
+// GuardError is returned when a guard blocks a transition.
+// Message is safe to show to the user.
+type GuardError struct {
+	Guard   string
+	Message string
+}
+
+// Error implements the error interface
+func (e *GuardError) Error() string {
+	return e.Message
+}
+
+// TransitionInput carries the values submitted together with a status change
+type TransitionInput struct {
+	TrackingNumber string
+	CourierName    string
+	ETA            *time.Time
+	Reason         string // Required when entering an exception status
+
+	// Recorded in the shipment's status history
+	ActorUserID *int64                   // User making the change, nil for automated sources
+	Source      models.StatusEventSource // Defaults to ui
+	Comment     string                   // Optional note; the exception reason is used when empty
+
+	// Persist runs inside the transaction that writes the new status, after the workflow's effects.
+	// Callers use it to save records that belong with the status change, like a submitted form.
+	Persist func(ctx context.Context, tx *sql.Tx) error
+}
+
+// TransitionContext is the state handed to guards and effects
+type TransitionContext struct {
+	Shipment   *models.Shipment
+	From       models.ShipmentStatus
+	To         models.ShipmentStatus
+	Input      TransitionInput
+	Definition *models.WorkflowDefinition
+	Transition *models.WorkflowTransition
+}
+
+// TransitionResult describes a completed transition
+type TransitionResult struct {
+	Shipment      *models.Shipment
+	From          models.ShipmentStatus
+	To            models.ShipmentStatus
+	Notifications []string // Notification effects that were queued
+}
+
+// Notified returns true if the given notification effect was queued for this transition
+func (r *TransitionResult) Notified(effect string) bool {
+	for _, name := range r.Notifications {
+		if name == effect {
+			return true
+		}
+	}
+	return false
+}
+
+// Engine evaluates workflow guards and runs workflow effects for shipment status changes
+type Engine struct {
+	DB       *sql.DB
+	Notifier *email.Notifier
+}
+
+// NewEngine creates a new workflow Engine
+func NewEngine(db *sql.DB, notifier *email.Notifier) *Engine {
+	return &Engine{
+		DB:       db,
+		Notifier: notifier,
+	}
+}
+
+// AvailableTransitions returns the statuses the shipment can move to right now.
+// Transitions blocked by a guard are left out; the warning of the first blocking guard is returned
+// so the UI can explain why a step is missing. Guards that depend on submitted input are not evaluated.
+func (e *Engine) AvailableTransitions(ctx context.Context, shipment *models.Shipment) ([]models.ShipmentStatus, string, error) {
+	def, err := models.GetWorkflowDefinition(ctx, e.DB, shipment.ShipmentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	allowed := []models.ShipmentStatus{}
+	warning := ""
+	for _, transition := range def.TransitionsFrom(shipment.Status) {
+		t := transition
+		tc := &TransitionContext{
+			Shipment:   shipment,
+			From:       shipment.Status,
+			To:         t.To,
+			Definition: def,
+			Transition: &t,
+		}
+
+		blocked := false
+		for _, name := range t.Guards {
+			g, ok := guards[name]
+			if !ok || g.needsInput {
+				continue
+			}
+			if err := g.check(ctx, e.DB, tc); err != nil {
+				var guardErr *GuardError
+				if !errors.As(err, &guardErr) {
+					return nil, "", err
+				}
+				blocked = true
+				if warning == "" {
+					warning = g.warning
+				}
+				break
+			}
+		}
+
+		if !blocked {
+			allowed = append(allowed, t.To)
+		}
+	}
+
+	return allowed, warning, nil
+}
+
+// Transition moves a shipment to a new status.
+// It checks the workflow for the shipment's type, evaluates every guard on the transition,
+// writes the new status together with any persistent effects, a status history event
+// and the notifications to send in one transaction.
+func (e *Engine) Transition(ctx context.Context, shipmentID int64, to models.ShipmentStatus, input TransitionInput) (*TransitionResult, error) {
+	shipment, err := e.loadShipment(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	def, err := models.GetWorkflowDefinition(ctx, e.DB, shipment.ShipmentType)
+	if err != nil {
+		return nil, err
+	}
+
+	from := shipment.Status
+	transition := def.FindTransition(from, to)
+	if transition == nil {
+		return nil, ErrInvalidTransition
+	}
+
+	tc := &TransitionContext{
+		Shipment:   shipment,
+		From:       from,
+		To:         to,
+		Input:      input,
+		Definition: def,
+		Transition: transition,
+	}
+
+	// Every guard has to pass before anything is written
+	for _, name := range transition.Guards {
+		g, ok := guards[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown workflow guard %q", name)
+		}
+		if err := g.check(ctx, e.DB, tc); err != nil {
+			return nil, err
+		}
+	}
+
+	// Apply the new state to the in-memory shipment
+	shipment.Status = to
+	shipment.BeforeUpdate()
+	if input.TrackingNumber != "" {
+		shipment.TrackingNumber = input.TrackingNumber
+	}
+	if input.CourierName != "" {
+		shipment.CourierName = input.CourierName
+	}
+	for _, name := range transition.Effects {
+		if fx, ok := effects[name]; ok && fx.mutate != nil {
+			fx.mutate(tc)
+		}
+	}
+
+	tx, err := e.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE shipments
+		SET status = $1, updated_at = $2,
+		    picked_up_at = COALESCE($3, picked_up_at),
+		    arrived_warehouse_at = COALESCE($4, arrived_warehouse_at),
+		    released_warehouse_at = COALESCE($5, released_warehouse_at),
+		    delivered_at = COALESCE($6, delivered_at),
+		    pickup_scheduled_date = COALESCE($7, pickup_scheduled_date),
+		    eta_to_engineer = COALESCE($8, eta_to_engineer),
+		    tracking_number = CASE WHEN $9 != '' THEN $9 ELSE tracking_number END,
+		    courier_name = CASE WHEN $10 != '' THEN $10 ELSE courier_name END
+		WHERE id = $11 AND status = $12`,
+		shipment.Status, shipment.UpdatedAt,
+		shipment.PickedUpAt, shipment.ArrivedWarehouseAt,
+		shipment.ReleasedWarehouseAt, shipment.DeliveredAt,
+		shipment.PickupScheduledDate, shipment.ETAToEngineer,
+		input.TrackingNumber,
+		input.CourierName,
+		shipmentID, from,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update shipment status: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, ErrConcurrentUpdate
+	}
+
+	for _, name := range transition.Effects {
+		if fx, ok := effects[name]; ok && fx.persist != nil {
+			if err := fx.persist(ctx, tx, tc); err != nil {
+				return nil, fmt.Errorf("effect %s failed: %w", name, err)
+			}
+		}
+	}
+
+	if input.Persist != nil {
+		if err := input.Persist(ctx, tx); err != nil {
+			return nil, err
+		}
+	}
+
+	if input.TrackingNumber != "" {
+		if err := models.AssignPackageTracking(ctx, tx, shipmentID, shipment.CourierName, input.TrackingNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	comment := input.Comment
+	if strings.TrimSpace(comment) == "" && models.IsExceptionStatus(to) {
+		comment = input.Reason
+	}
+	if err := models.RecordShipmentStatusEvent(ctx, tx, &models.ShipmentStatusEvent{
+		ShipmentID:  shipmentID,
+		FromStatus:  &from,
+		ToStatus:    to,
+		ActorUserID: input.ActorUserID,
+		Source:      input.Source,
+		Comment:     comment,
+		OccurredAt:  shipment.UpdatedAt,
+	}); err != nil {
+		return nil, err
+	}
+
+	// Notifications are queued with the status change and delivered by the email outbox
+	notifications := []string{}
+	for _, name := range transition.Effects {
+		fx, ok := effects[name]
+		if !ok || fx.notify == "" {
+			continue
+		}
+		if e.Notifier == nil {
+			log.Printf("Warning: EmailNotifier is nil, skipping %s for shipment %d", name, shipmentID)
+			continue
+		}
+		if err := email.Enqueue(ctx, tx, fx.notify, shipmentID); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, name)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit status update: %w", err)
+	}
+
+	return &TransitionResult{
+		Shipment:      shipment,
+		From:          from,
+		To:            to,
+		Notifications: notifications,
+	}, nil
+}
+
+// loadShipment loads the fields guards and effects rely on
+func (e *Engine) loadShipment(ctx context.Context, shipmentID int64) (*models.Shipment, error) {
+	var s models.Shipment
+	err := e.DB.QueryRowContext(ctx,
+		`SELECT id, shipment_type, client_company_id, software_engineer_id, status, laptop_count,
+		        COALESCE(courier_name, ''), COALESCE(tracking_number, ''),
+		        pickup_scheduled_date, COALESCE(exception_reason, ''), exception_at, status_before_exception,
+		        created_at, updated_at
+		FROM shipments WHERE id = $1`,
+		shipmentID,
+	).Scan(&s.ID, &s.ShipmentType, &s.ClientCompanyID, &s.SoftwareEngineerID, &s.Status, &s.LaptopCount,
+		&s.CourierName, &s.TrackingNumber,
+		&s.PickupScheduledDate, &s.ExceptionReason, &s.ExceptionAt, &s.StatusBeforeException,
+		&s.CreatedAt, &s.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrShipmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch current shipment: %w", err)
+	}
+	return &s, nil
+}
@@ -0,0 +1,237 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yourusername/laptop-tracking-system/internal/database"
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// createTestShipment inserts a shipment with one laptop and returns both IDs
+func createTestShipment(t *testing.T, db *sql.DB, shipmentType models.ShipmentType, status models.ShipmentStatus, laptopStatus models.LaptopStatus) (int64, int64) {
+	t.Helper()
+	ctx := context.Background()
+
+	var companyID int64
+	err := db.QueryRowContext(ctx,
+		`INSERT INTO client_companies (name, created_at) VALUES ($1, $2) RETURNING id`,
+		"Workflow Test Company", time.Now(),
+	).Scan(&companyID)
+	if err != nil {
+		t.Fatalf("Failed to create test company: %v", err)
+	}
+
+	var shipmentID int64
+	err = db.QueryRowContext(ctx,
+		`INSERT INTO shipments (shipment_type, client_company_id, status, laptop_count, jira_ticket_number, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		shipmentType, companyID, status, 1, "TEST-WF-001", time.Now(), time.Now(),
+	).Scan(&shipmentID)
+	if err != nil {
+		t.Fatalf("Failed to create test shipment: %v", err)
+	}
+
+	var laptopID int64
+	err = db.QueryRowContext(ctx,
+		`INSERT INTO laptops (serial_number, brand, model, ram_gb, ssd_gb, status, client_company_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		"SN-WF-001", "Dell", "Latitude 7420", "16", "512", laptopStatus, companyID, time.Now(), time.Now(),
+	).Scan(&laptopID)
+	if err != nil {
+		t.Fatalf("Failed to create test laptop: %v", err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO shipment_laptops (shipment_id, laptop_id, created_at) VALUES ($1, $2, $3)`,
+		shipmentID, laptopID, time.Now(),
+	)
+	if err != nil {
+		t.Fatalf("Failed to link laptop to shipment: %v", err)
+	}
+
+	return shipmentID, laptopID
+}
+
+// assertShipmentUnchanged checks that a failed transition left no trace on the shipment
+func assertShipmentUnchanged(t *testing.T, db *sql.DB, shipmentID int64, want models.ShipmentStatus) {
+	t.Helper()
+
+	var status models.ShipmentStatus
+	if err := db.QueryRow(`SELECT status FROM shipments WHERE id = $1`, shipmentID).Scan(&status); err != nil {
+		t.Fatalf("Failed to query shipment status: %v", err)
+	}
+	if status != want {
+		t.Errorf("shipment status = %s, want %s", status, want)
+	}
+
+	var events int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM shipment_status_events WHERE shipment_id = $1`, shipmentID).Scan(&events); err != nil {
+		t.Fatalf("Failed to count status events: %v", err)
+	}
+	if events != 0 {
+		t.Errorf("expected no status events, got %d", events)
+	}
+}
+
+func TestEngine_Transition_GuardRejects(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	// No engineer is assigned, so the engineer_assigned guard must block the transition
+	shipmentID, _ := createTestShipment(t, db, models.ShipmentTypeWarehouseToEngineer,
+		models.ShipmentStatusReleasedFromWarehouse, models.LaptopStatusAtWarehouse)
+
+	engine := NewEngine(db, nil)
+	_, err := engine.Transition(context.Background(), shipmentID, models.ShipmentStatusInTransitToEngineer, TransitionInput{})
+
+	var guardErr *GuardError
+	if !errors.As(err, &guardErr) {
+		t.Fatalf("Transition() error = %v, want a *GuardError", err)
+	}
+	if guardErr.Guard != models.WorkflowGuardEngineerAssigned {
+		t.Errorf("Guard = %s, want %s", guardErr.Guard, models.WorkflowGuardEngineerAssigned)
+	}
+
+	assertShipmentUnchanged(t, db, shipmentID, models.ShipmentStatusReleasedFromWarehouse)
+}
+
+func TestEngine_Transition_InvalidTransition(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	shipmentID, _ := createTestShipment(t, db, models.ShipmentTypeSingleFullJourney,
+		models.ShipmentStatusPendingPickup, models.LaptopStatusAvailable)
+
+	engine := NewEngine(db, nil)
+	_, err := engine.Transition(context.Background(), shipmentID, models.ShipmentStatusDelivered, TransitionInput{})
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("Transition() error = %v, want ErrInvalidTransition", err)
+	}
+
+	assertShipmentUnchanged(t, db, shipmentID, models.ShipmentStatusPendingPickup)
+}
+
+func TestEngine_Transition_ConcurrentUpdate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	shipmentID, _ := createTestShipment(t, db, models.ShipmentTypeWarehouseToEngineer,
+		models.ShipmentStatusReleasedFromWarehouse, models.LaptopStatusAtWarehouse)
+
+	// Another request moves the shipment on hold after the engine loaded it
+	// but before it writes the new status
+	original := guards[models.WorkflowGuardEngineerAssigned]
+	guards[models.WorkflowGuardEngineerAssigned] = guard{
+		check: func(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+			_, err := db.ExecContext(ctx,
+				`UPDATE shipments SET status = $1 WHERE id = $2`,
+				models.ShipmentStatusOnHold, tc.Shipment.ID,
+			)
+			return err
+		},
+	}
+	defer func() { guards[models.WorkflowGuardEngineerAssigned] = original }()
+
+	engine := NewEngine(db, nil)
+	_, err := engine.Transition(context.Background(), shipmentID, models.ShipmentStatusInTransitToEngineer, TransitionInput{})
+	if !errors.Is(err, ErrConcurrentUpdate) {
+		t.Fatalf("Transition() error = %v, want ErrConcurrentUpdate", err)
+	}
+
+	// The concurrent change wins
+	assertShipmentUnchanged(t, db, shipmentID, models.ShipmentStatusOnHold)
+}
+
+func TestEngine_Transition_PersistEffectRollsBack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	// Arrival at the warehouse syncs the laptop status, then unassigns the engineer
+	shipmentID, laptopID := createTestShipment(t, db, models.ShipmentTypeEngineerToWarehouse,
+		models.ShipmentStatusInTransitToWarehouse, models.LaptopStatusInTransitToWarehouse)
+
+	failure := errors.New("unassign failed")
+	original := effects[models.WorkflowEffectUnassignEngineer]
+	effects[models.WorkflowEffectUnassignEngineer] = effect{
+		persist: func(ctx context.Context, tx *sql.Tx, tc *TransitionContext) error {
+			return failure
+		},
+	}
+	defer func() { effects[models.WorkflowEffectUnassignEngineer] = original }()
+
+	engine := NewEngine(db, nil)
+	_, err := engine.Transition(context.Background(), shipmentID, models.ShipmentStatusAtWarehouse, TransitionInput{})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Transition() error = %v, want %v", err, failure)
+	}
+
+	assertShipmentUnchanged(t, db, shipmentID, models.ShipmentStatusInTransitToWarehouse)
+
+	// The laptop status written by the earlier sync effect is rolled back too
+	var laptopStatus models.LaptopStatus
+	if err := db.QueryRow(`SELECT status FROM laptops WHERE id = $1`, laptopID).Scan(&laptopStatus); err != nil {
+		t.Fatalf("Failed to query laptop status: %v", err)
+	}
+	if laptopStatus != models.LaptopStatusInTransitToWarehouse {
+		t.Errorf("laptop status = %s, want %s", laptopStatus, models.LaptopStatusInTransitToWarehouse)
+	}
+}
+
+func TestEngine_Transition_Success(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	db, cleanup := database.SetupTestDB(t)
+	defer cleanup()
+
+	shipmentID, laptopID := createTestShipment(t, db, models.ShipmentTypeEngineerToWarehouse,
+		models.ShipmentStatusInTransitToWarehouse, models.LaptopStatusInTransitToWarehouse)
+
+	engine := NewEngine(db, nil)
+	result, err := engine.Transition(context.Background(), shipmentID, models.ShipmentStatusAtWarehouse, TransitionInput{
+		Source: models.StatusEventSourceUI,
+	})
+	if err != nil {
+		t.Fatalf("Transition() error = %v", err)
+	}
+	if result.From != models.ShipmentStatusInTransitToWarehouse || result.To != models.ShipmentStatusAtWarehouse {
+		t.Errorf("result = %s -> %s", result.From, result.To)
+	}
+
+	var laptopStatus models.LaptopStatus
+	if err := db.QueryRow(`SELECT status FROM laptops WHERE id = $1`, laptopID).Scan(&laptopStatus); err != nil {
+		t.Fatalf("Failed to query laptop status: %v", err)
+	}
+	if laptopStatus != models.LaptopStatusAtWarehouse {
+		t.Errorf("laptop status = %s, want %s", laptopStatus, models.LaptopStatusAtWarehouse)
+	}
+
+	var events int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM shipment_status_events WHERE shipment_id = $1`, shipmentID).Scan(&events); err != nil {
+		t.Fatalf("Failed to count status events: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("expected 1 status event, got %d", events)
+	}
+}
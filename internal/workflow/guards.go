@@ -0,0 +1,210 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/yourusername/laptop-tracking-system/internal/models"
+)
+
+// guard is the implementation behind a guard name used in workflow definitions
+type guard struct {
+	// check returns a *GuardError when the transition must be blocked,
+	// or any other error if the guard could not be evaluated
+	check func(ctx context.Context, db *sql.DB, tc *TransitionContext) error
+
+	// needsInput marks guards that look at values submitted with the transition.
+	// They are skipped when listing the transitions available on the detail page.
+	needsInput bool
+
+	// warning is shown on the shipment detail page when the guard hides a transition
+	warning string
+}
+
+// guards maps guard names to their implementations
+var guards = map[string]guard{
+	models.WorkflowGuardEngineerAssigned: {
+		check:   checkEngineerAssigned,
+		warning: "⚠️ An engineer must be assigned before updating the status to 'In Transit to Engineer'. Please assign an engineer first.",
+	},
+	models.WorkflowGuardPickupFormSubmitted: {
+		check:   checkPickupFormSubmitted,
+		warning: "⚠️ A completed 'Complete Shipment Details' form is required before updating the status to 'Pickup from Client Scheduled'. Please ensure the shipment details form is completed first.",
+	},
+	models.WorkflowGuardReceptionReportApproved: {
+		check:   checkReceptionReportApproved,
+		warning: "⚠️ An approved reception report is required before updating the status to 'Released from Warehouse'. Please ensure the reception report is created and approved first.",
+	},
+	models.WorkflowGuardTrackingNumberPresent: {
+		check:      checkTrackingNumberPresent,
+		needsInput: true,
+	},
+	models.WorkflowGuardCourierNamePresent: {
+		check:      checkCourierNamePresent,
+		needsInput: true,
+	},
+	models.WorkflowGuardExceptionReasonPresent: {
+		check:      checkExceptionReasonPresent,
+		needsInput: true,
+	},
+	models.WorkflowGuardResumeToPreviousStatus: {
+		check: checkResumeToPreviousStatus,
+	},
+}
+
+// checkEngineerAssigned requires a software engineer on the shipment
+func checkEngineerAssigned(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	if tc.Shipment.SoftwareEngineerID == nil {
+		return &GuardError{
+			Guard:   models.WorkflowGuardEngineerAssigned,
+			Message: "Cannot update status to 'in transit to engineer' without an assigned engineer. Please assign an engineer first.",
+		}
+	}
+	return nil
+}
+
+// checkPickupFormSubmitted requires the Complete Shipment Details (pickup) form to exist
+func checkPickupFormSubmitted(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	var pickupFormCount int
+	err := db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM pickup_forms WHERE shipment_id = $1`,
+		tc.Shipment.ID,
+	).Scan(&pickupFormCount)
+	if err != nil {
+		return fmt.Errorf("failed to check pickup form status: %w", err)
+	}
+	if pickupFormCount == 0 {
+		return &GuardError{
+			Guard:   models.WorkflowGuardPickupFormSubmitted,
+			Message: "Cannot update status to 'Pickup from Client Scheduled' without a completed 'Complete Shipment Details' form. Please ensure the shipment details form is completed first.",
+		}
+	}
+	return nil
+}
+
+// checkReceptionReportApproved requires an approved reception report for every laptop in the shipment
+func checkReceptionReportApproved(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	rows, err := db.QueryContext(ctx,
+		`SELECT laptop_id FROM shipment_laptops WHERE shipment_id = $1 ORDER BY laptop_id`,
+		tc.Shipment.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to fetch shipment laptop information: %w", err)
+	}
+	var laptopIDs []int64
+	for rows.Next() {
+		var laptopID int64
+		if err := rows.Scan(&laptopID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan shipment laptop: %w", err)
+		}
+		laptopIDs = append(laptopIDs, laptopID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to fetch shipment laptop information: %w", err)
+	}
+
+	if len(laptopIDs) == 0 {
+		return &GuardError{
+			Guard:   models.WorkflowGuardReceptionReportApproved,
+			Message: "Shipment has no laptops associated",
+		}
+	}
+
+	for _, laptopID := range laptopIDs {
+		receptionReport, err := models.GetLaptopReceptionReport(ctx, db, laptopID)
+		if err != nil {
+			return fmt.Errorf("failed to check reception report status: %w", err)
+		}
+		if receptionReport == nil || !receptionReport.IsApproved() {
+			return &GuardError{
+				Guard:   models.WorkflowGuardReceptionReportApproved,
+				Message: "Cannot update status to 'Released from Warehouse' without an approved reception report for the laptop. Please ensure the reception report is created and approved first.",
+			}
+		}
+	}
+	return nil
+}
+
+// checkTrackingNumberPresent requires a tracking number, either submitted now or already on the shipment.
+// A tracking number submitted with the transition must match its courier's tracking number format.
+func checkTrackingNumberPresent(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	trackingNumber := strings.TrimSpace(tc.Input.TrackingNumber)
+	if trackingNumber == "" && tc.Shipment.TrackingNumber == "" {
+		return &GuardError{
+			Guard:   models.WorkflowGuardTrackingNumberPresent,
+			Message: "Tracking number is required when updating the status to " + strings.ReplaceAll(string(tc.To), "_", " "),
+		}
+	}
+	if trackingNumber == "" {
+		return nil
+	}
+
+	courierName := strings.TrimSpace(tc.Input.CourierName)
+	if courierName == "" {
+		courierName = tc.Shipment.CourierName
+	}
+	couriers, err := models.LoadCourierDirectory(db)
+	if err != nil {
+		return fmt.Errorf("failed to load couriers: %w", err)
+	}
+	if err := couriers.ValidateTrackingNumber(courierName, trackingNumber); err != nil {
+		return &GuardError{
+			Guard:   models.WorkflowGuardTrackingNumberPresent,
+			Message: "Invalid tracking number for " + courierName + ". Check the number and try again",
+		}
+	}
+	return nil
+}
+
+// checkCourierNamePresent requires a courier that exists in the system.
+// A courier submitted with the transition takes precedence over the one already on the shipment.
+func checkCourierNamePresent(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	courierName := strings.TrimSpace(tc.Input.CourierName)
+	if courierName == "" {
+		courierName = tc.Shipment.CourierName
+	}
+	if courierName == "" {
+		return &GuardError{
+			Guard:   models.WorkflowGuardCourierNamePresent,
+			Message: "Courier name is required when updating the status to " + strings.ReplaceAll(string(tc.To), "_", " "),
+		}
+	}
+
+	valid, err := models.IsValidCourierName(db, courierName)
+	if err != nil {
+		return fmt.Errorf("failed to validate courier name: %w", err)
+	}
+	if !valid {
+		return &GuardError{
+			Guard:   models.WorkflowGuardCourierNamePresent,
+			Message: "Invalid courier name. Courier must exist in the system",
+		}
+	}
+	return nil
+}
+
+// checkExceptionReasonPresent requires a reason when a shipment enters an exception status
+func checkExceptionReasonPresent(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	if strings.TrimSpace(tc.Input.Reason) == "" {
+		return &GuardError{
+			Guard:   models.WorkflowGuardExceptionReasonPresent,
+			Message: "A reason is required when marking a shipment as " + strings.ReplaceAll(string(tc.To), "_", " "),
+		}
+	}
+	return nil
+}
+
+// checkResumeToPreviousStatus only lets a shipment leave an exception back into the status it was in before
+func checkResumeToPreviousStatus(ctx context.Context, db *sql.DB, tc *TransitionContext) error {
+	if tc.Shipment.StatusBeforeException == nil || *tc.Shipment.StatusBeforeException != tc.To {
+		return &GuardError{
+			Guard:   models.WorkflowGuardResumeToPreviousStatus,
+			Message: "A shipment can only resume the status it was in before the exception",
+		}
+	}
+	return nil
+}
@@ -0,0 +1,2 @@
+-- Drop shipment_workflows table
+DROP TABLE IF EXISTS shipment_workflows;
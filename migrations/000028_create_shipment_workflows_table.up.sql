@@ -0,0 +1,14 @@
+-- Create shipment_workflows table
+-- Holds ops-maintained overrides of the built-in status workflow per shipment type.
+-- When no row exists for a type, the built-in workflow compiled into the application is used.
+CREATE TABLE IF NOT EXISTS shipment_workflows (
+    shipment_type shipment_type PRIMARY KEY,
+    definition JSONB NOT NULL,
+    updated_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Comment on table and columns
+COMMENT ON TABLE shipment_workflows IS 'Workflow definitions (statuses, transitions, guards and effects) overriding the built-in flow per shipment type';
+COMMENT ON COLUMN shipment_workflows.definition IS 'Workflow definition JSON: statuses in order and transitions with guard and effect names';
+COMMENT ON COLUMN shipment_workflows.updated_by_user_id IS 'User who last saved the definition';
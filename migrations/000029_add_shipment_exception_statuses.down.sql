@@ -0,0 +1,11 @@
+-- Remove exception details from shipments
+ALTER TABLE shipments DROP COLUMN IF EXISTS status_before_exception;
+ALTER TABLE shipments DROP COLUMN IF EXISTS exception_at;
+ALTER TABLE shipments DROP COLUMN IF EXISTS exception_reason;
+
+-- Note: PostgreSQL does not support removing enum values directly.
+-- The on_hold, lost, damaged, returned_to_sender and cancelled shipment statuses
+-- and the lost and damaged laptop statuses are left in place.
+-- If you must remove them, first move affected rows back to a normal status, e.g.:
+-- UPDATE shipments SET status = 'pending_pickup_from_client' WHERE status IN ('on_hold', 'lost', 'damaged', 'returned_to_sender', 'cancelled');
+-- UPDATE laptops SET status = 'available' WHERE status IN ('lost', 'damaged');
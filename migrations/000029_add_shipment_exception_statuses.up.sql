@@ -0,0 +1,21 @@
+-- Add exception statuses to shipment_status enum
+-- Exceptions can be entered from any in-flight status and always carry a reason
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'on_hold';
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'lost';
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'damaged';
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'returned_to_sender';
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'cancelled';
+
+-- Add laptop statuses used when a shipment is lost or damaged
+ALTER TYPE laptop_status ADD VALUE IF NOT EXISTS 'lost';
+ALTER TYPE laptop_status ADD VALUE IF NOT EXISTS 'damaged';
+
+-- Add exception details to shipments
+ALTER TABLE shipments ADD COLUMN exception_reason TEXT;
+ALTER TABLE shipments ADD COLUMN exception_at TIMESTAMP;
+ALTER TABLE shipments ADD COLUMN status_before_exception shipment_status;
+
+-- Add comments
+COMMENT ON COLUMN shipments.exception_reason IS 'Reason given when the shipment entered its current exception status';
+COMMENT ON COLUMN shipments.exception_at IS 'When the shipment entered its current exception status';
+COMMENT ON COLUMN shipments.status_before_exception IS 'Normal flow status the shipment was in before the exception; used to resume the shipment';
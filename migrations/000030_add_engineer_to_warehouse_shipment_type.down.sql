@@ -0,0 +1,13 @@
+-- Restore one reception report per laptop
+-- This fails if a laptop already has more than one reception report
+DROP INDEX IF EXISTS idx_reception_reports_laptop_shipment_unique;
+CREATE UNIQUE INDEX idx_reception_reports_laptop_unique ON reception_reports(laptop_id);
+
+COMMENT ON COLUMN reception_reports.laptop_id IS 'Laptop this report is for (one report per laptop)';
+COMMENT ON COLUMN shipments.shipment_type IS 'Type of shipment: single_full_journey (1 laptop full flow), bulk_to_warehouse (2+ laptops to warehouse only), or warehouse_to_engineer (1 laptop from warehouse to engineer)';
+
+-- Note: PostgreSQL does not support removing enum values directly.
+-- The engineer_to_warehouse shipment type and the return_kit_sent, return_kit_delivered
+-- and picked_up_from_engineer shipment statuses are left in place.
+-- If you must remove them, first delete or convert the affected shipments, e.g.:
+-- DELETE FROM shipments WHERE shipment_type = 'engineer_to_warehouse';
@@ -0,0 +1,15 @@
+-- Add engineer_to_warehouse shipment type for retrieving laptops from departing engineers
+ALTER TYPE shipment_type ADD VALUE IF NOT EXISTS 'engineer_to_warehouse';
+
+-- Add return statuses used before the laptop is in transit back to the warehouse
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'return_kit_sent';
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'return_kit_delivered';
+ALTER TYPE shipment_status ADD VALUE IF NOT EXISTS 'picked_up_from_engineer';
+
+-- A laptop that comes back from an engineer needs a new reception report,
+-- so allow one report per laptop per shipment instead of one per laptop
+DROP INDEX IF EXISTS idx_reception_reports_laptop_unique;
+CREATE UNIQUE INDEX idx_reception_reports_laptop_shipment_unique ON reception_reports(laptop_id, shipment_id);
+
+COMMENT ON COLUMN reception_reports.laptop_id IS 'Laptop this report is for (one report per laptop per shipment)';
+COMMENT ON COLUMN shipments.shipment_type IS 'Type of shipment: single_full_journey (1 laptop full flow), bulk_to_warehouse (2+ laptops to warehouse only), warehouse_to_engineer (1 laptop from warehouse to engineer), or engineer_to_warehouse (1 laptop returned from engineer to warehouse)';
@@ -0,0 +1,5 @@
+-- Drop shipment_status_events table
+DROP TRIGGER IF EXISTS shipment_status_events_append_only ON shipment_status_events;
+DROP FUNCTION IF EXISTS prevent_shipment_status_event_changes();
+DROP TABLE IF EXISTS shipment_status_events;
+DROP TYPE IF EXISTS status_event_source;
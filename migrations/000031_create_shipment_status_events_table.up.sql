@@ -0,0 +1,96 @@
+-- Create status event source enum
+CREATE TYPE status_event_source AS ENUM (
+    'ui',
+    'api',
+    'jira',
+    'courier',
+    'system'
+);
+
+-- Create shipment_status_events table
+-- Append-only history of every shipment status change. The milestone columns on shipments
+-- only keep the latest timestamp per status; this table keeps who changed what, when and why.
+CREATE TABLE IF NOT EXISTS shipment_status_events (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id BIGINT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    from_status shipment_status,
+    to_status shipment_status NOT NULL,
+    actor_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    source status_event_source NOT NULL DEFAULT 'ui',
+    comment TEXT,
+    occurred_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_shipment_status_events_shipment ON shipment_status_events(shipment_id, occurred_at, id);
+CREATE INDEX idx_shipment_status_events_actor ON shipment_status_events(actor_user_id);
+
+-- Reject updates and deletes so the history stays append-only.
+-- Changes made by foreign key actions (shipment deleted, actor removed) are nested in the
+-- referential trigger and are still allowed.
+CREATE OR REPLACE FUNCTION prevent_shipment_status_event_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF pg_trigger_depth() > 1 THEN
+        IF TG_OP = 'DELETE' THEN
+            RETURN OLD;
+        END IF;
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'shipment_status_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER shipment_status_events_append_only
+    BEFORE UPDATE OR DELETE ON shipment_status_events
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_shipment_status_event_changes();
+
+-- Backfill history for existing shipments from their milestone columns.
+-- The exact sequence of older changes is unknown, so each recorded milestone becomes one event
+-- and the current status is added at updated_at when no milestone covers it.
+INSERT INTO shipment_status_events (shipment_id, from_status, to_status, source, comment, occurred_at)
+SELECT shipment_id,
+       LAG(to_status) OVER (PARTITION BY shipment_id ORDER BY occurred_at, step),
+       to_status, 'system', 'Backfilled from shipment milestones', occurred_at
+FROM (
+    SELECT id AS shipment_id, 0 AS step, created_at AS occurred_at,
+           CASE shipment_type
+               WHEN 'warehouse_to_engineer' THEN 'released_from_warehouse'::shipment_status
+               WHEN 'engineer_to_warehouse' THEN 'return_kit_sent'::shipment_status
+               ELSE 'pending_pickup'::shipment_status
+           END AS to_status
+    FROM shipments
+    UNION ALL
+    SELECT id, 1, picked_up_at,
+           CASE shipment_type
+               WHEN 'engineer_to_warehouse' THEN 'picked_up_from_engineer'::shipment_status
+               ELSE 'picked_up_from_client'::shipment_status
+           END
+    FROM shipments WHERE picked_up_at IS NOT NULL AND shipment_type <> 'warehouse_to_engineer'
+    UNION ALL
+    SELECT id, 2, arrived_warehouse_at, 'at_warehouse'::shipment_status
+    FROM shipments WHERE arrived_warehouse_at IS NOT NULL AND shipment_type <> 'warehouse_to_engineer'
+    UNION ALL
+    SELECT id, 3, released_warehouse_at, 'released_from_warehouse'::shipment_status
+    FROM shipments WHERE released_warehouse_at IS NOT NULL
+      AND shipment_type IN ('single_full_journey')
+    UNION ALL
+    SELECT id, 4, delivered_at, 'delivered'::shipment_status
+    FROM shipments WHERE delivered_at IS NOT NULL
+      AND shipment_type IN ('single_full_journey', 'warehouse_to_engineer')
+    UNION ALL
+    SELECT s.id, 5, s.updated_at, s.status
+    FROM shipments s
+    WHERE s.status NOT IN ('pending_pickup', 'picked_up_from_client', 'picked_up_from_engineer',
+                           'at_warehouse', 'released_from_warehouse', 'return_kit_sent', 'delivered')
+) milestones;
+
+-- Comment on table and columns
+COMMENT ON TABLE shipment_status_events IS 'Append-only history of shipment status changes';
+COMMENT ON COLUMN shipment_status_events.from_status IS 'Status before the change (NULL for the event recorded when the shipment is created)';
+COMMENT ON COLUMN shipment_status_events.to_status IS 'Status after the change';
+COMMENT ON COLUMN shipment_status_events.actor_user_id IS 'User who made the change (NULL for automated sources or removed users)';
+COMMENT ON COLUMN shipment_status_events.source IS 'Where the change came from: ui, api, jira, courier or system';
+COMMENT ON COLUMN shipment_status_events.comment IS 'Optional comment entered with the change (exception reason, correction note, ...)';
+COMMENT ON COLUMN shipment_status_events.occurred_at IS 'When the status change happened';
@@ -0,0 +1,7 @@
+-- Drop shipment package tables
+DROP TABLE IF EXISTS shipment_package_laptops;
+DROP TABLE IF EXISTS shipment_packages;
+
+-- Restore column comments
+COMMENT ON COLUMN shipments.second_tracking_number IS 'Optional second tracking number for shipments (e.g., return tracking or secondary courier)';
+COMMENT ON COLUMN shipments.second_courier_name IS 'Optional second courier name for shipments (e.g., return courier or secondary courier)';
@@ -0,0 +1,93 @@
+-- Create shipment_packages table
+-- A shipment travels as one or more packages, each with its own dimensions, courier and tracking number.
+CREATE TABLE IF NOT EXISTS shipment_packages (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id BIGINT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    package_number INTEGER NOT NULL CHECK (package_number > 0),
+    length_in NUMERIC(8, 2) CHECK (length_in > 0),
+    width_in NUMERIC(8, 2) CHECK (width_in > 0),
+    height_in NUMERIC(8, 2) CHECK (height_in > 0),
+    weight_lb NUMERIC(8, 2) CHECK (weight_lb > 0),
+    courier_name VARCHAR(255),
+    tracking_number VARCHAR(255),
+    notes TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (shipment_id, package_number)
+);
+
+-- Create shipment_package_laptops junction table
+-- Records which laptops of the shipment are packed in which package.
+-- A laptop can appear in several packages of one shipment when it is repacked between legs.
+CREATE TABLE IF NOT EXISTS shipment_package_laptops (
+    package_id BIGINT NOT NULL REFERENCES shipment_packages(id) ON DELETE CASCADE,
+    laptop_id BIGINT NOT NULL REFERENCES laptops(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (package_id, laptop_id)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_shipment_packages_shipment ON shipment_packages(shipment_id);
+CREATE INDEX idx_shipment_packages_tracking ON shipment_packages(tracking_number) WHERE tracking_number IS NOT NULL;
+CREATE INDEX idx_shipment_package_laptops_laptop ON shipment_package_laptops(laptop_id);
+
+-- Backfill: bulk shipments get one package per box declared on their pickup form,
+-- all with the declared dimensions. Other shipments get a single package.
+-- The shipment's tracking number goes on package 1.
+INSERT INTO shipment_packages (shipment_id, package_number, length_in, width_in, height_in, weight_lb,
+                               courier_name, tracking_number, created_at, updated_at)
+SELECT s.id, box.n,
+       NULLIF(NULLIF(pf.form_data->>'bulk_length', '')::NUMERIC, 0),
+       NULLIF(NULLIF(pf.form_data->>'bulk_width', '')::NUMERIC, 0),
+       NULLIF(NULLIF(pf.form_data->>'bulk_height', '')::NUMERIC, 0),
+       NULLIF(NULLIF(pf.form_data->>'bulk_weight', '')::NUMERIC, 0),
+       CASE WHEN box.n = 1 THEN NULLIF(s.courier_name, '') END,
+       CASE WHEN box.n = 1 THEN NULLIF(s.tracking_number, '') END,
+       s.created_at, s.updated_at
+FROM shipments s
+LEFT JOIN LATERAL (
+    SELECT form_data FROM pickup_forms
+    WHERE shipment_id = s.id
+    ORDER BY submitted_at DESC, id DESC
+    LIMIT 1
+) pf ON true
+CROSS JOIN LATERAL generate_series(
+    1,
+    CASE WHEN s.shipment_type = 'bulk_to_warehouse'
+         THEN GREATEST(COALESCE(NULLIF(pf.form_data->>'number_of_boxes', '')::INTEGER, 1), 1)
+         ELSE 1
+    END
+) AS box(n)
+WHERE s.shipment_type = 'bulk_to_warehouse'
+   OR NULLIF(s.tracking_number, '') IS NOT NULL;
+
+-- The second tracking number (warehouse to engineer leg) becomes its own package
+INSERT INTO shipment_packages (shipment_id, package_number, courier_name, tracking_number, created_at, updated_at)
+SELECT s.id,
+       COALESCE((SELECT MAX(p.package_number) FROM shipment_packages p WHERE p.shipment_id = s.id), 0) + 1,
+       COALESCE(NULLIF(s.second_courier_name, ''), NULLIF(s.courier_name, '')),
+       s.second_tracking_number, s.updated_at, s.updated_at
+FROM shipments s
+WHERE NULLIF(s.second_tracking_number, '') IS NOT NULL;
+
+-- Laptops of non-bulk shipments travel in every package of the shipment (one package per leg).
+-- Which box holds which laptop is unknown for existing bulk shipments, so those are left unassigned.
+INSERT INTO shipment_package_laptops (package_id, laptop_id)
+SELECT p.id, sl.laptop_id
+FROM shipment_packages p
+JOIN shipments s ON s.id = p.shipment_id
+JOIN shipment_laptops sl ON sl.shipment_id = p.shipment_id
+WHERE s.shipment_type <> 'bulk_to_warehouse';
+
+-- Comment on tables and columns
+COMMENT ON TABLE shipment_packages IS 'Physical packages a shipment travels in';
+COMMENT ON COLUMN shipment_packages.package_number IS 'Package number within the shipment (1, 2, ...)';
+COMMENT ON COLUMN shipment_packages.length_in IS 'Package length in inches';
+COMMENT ON COLUMN shipment_packages.width_in IS 'Package width in inches';
+COMMENT ON COLUMN shipment_packages.height_in IS 'Package height in inches';
+COMMENT ON COLUMN shipment_packages.weight_lb IS 'Package weight in pounds';
+COMMENT ON COLUMN shipment_packages.courier_name IS 'Courier carrying this package';
+COMMENT ON COLUMN shipment_packages.tracking_number IS 'Courier tracking number of this package';
+COMMENT ON TABLE shipment_package_laptops IS 'Laptops packed in each shipment package';
+COMMENT ON COLUMN shipments.second_tracking_number IS 'Deprecated: tracking is recorded per package in shipment_packages';
+COMMENT ON COLUMN shipments.second_courier_name IS 'Deprecated: couriers are recorded per package in shipment_packages';
@@ -0,0 +1,5 @@
+-- Drop shipment_tracking_events table
+DROP TABLE IF EXISTS shipment_tracking_events;
+
+-- Drop tracking_checkpoint_status enum
+DROP TYPE IF EXISTS tracking_checkpoint_status;
@@ -0,0 +1,41 @@
+-- Create tracking_checkpoint_status enum
+-- Courier checkpoints normalized across providers
+CREATE TYPE tracking_checkpoint_status AS ENUM (
+    'label_created',
+    'picked_up',
+    'in_transit',
+    'out_for_delivery',
+    'delivered',
+    'exception',
+    'unknown'
+);
+
+-- Create shipment_tracking_events table
+-- Checkpoints reported by courier tracking providers, either polled or pushed through webhooks.
+CREATE TABLE IF NOT EXISTS shipment_tracking_events (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id BIGINT NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    package_id BIGINT REFERENCES shipment_packages(id) ON DELETE SET NULL,
+    provider VARCHAR(50) NOT NULL,
+    tracking_number VARCHAR(255) NOT NULL,
+    status tracking_checkpoint_status NOT NULL,
+    provider_code VARCHAR(100) NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    location VARCHAR(255) NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP NOT NULL,
+    received_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    received_via VARCHAR(20) NOT NULL CHECK (received_via IN ('poll', 'webhook')),
+    -- The same checkpoint is often delivered by both polling and webhooks
+    UNIQUE (shipment_id, tracking_number, status, occurred_at, description)
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_shipment_tracking_events_shipment ON shipment_tracking_events(shipment_id, occurred_at);
+CREATE INDEX idx_shipment_tracking_events_tracking ON shipment_tracking_events(tracking_number);
+
+-- Comment on table and columns
+COMMENT ON TABLE shipment_tracking_events IS 'Courier tracking checkpoints received for shipments';
+COMMENT ON COLUMN shipment_tracking_events.provider IS 'Name of the tracking provider that reported the checkpoint';
+COMMENT ON COLUMN shipment_tracking_events.status IS 'Checkpoint normalized across providers';
+COMMENT ON COLUMN shipment_tracking_events.provider_code IS 'Checkpoint code as reported by the provider';
+COMMENT ON COLUMN shipment_tracking_events.received_via IS 'Whether the checkpoint was polled or pushed by webhook';
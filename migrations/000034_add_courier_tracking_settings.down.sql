@@ -0,0 +1,5 @@
+-- Seeded couriers are kept because shipments may reference them by name
+ALTER TABLE couriers
+    DROP COLUMN IF EXISTS service_levels,
+    DROP COLUMN IF EXISTS tracking_number_pattern,
+    DROP COLUMN IF EXISTS tracking_url_template;
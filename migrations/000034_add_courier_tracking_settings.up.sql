@@ -0,0 +1,20 @@
+-- Let each courier own its tracking link, tracking number format and service levels
+ALTER TABLE couriers
+    ADD COLUMN tracking_url_template TEXT,
+    ADD COLUMN tracking_number_pattern TEXT,
+    ADD COLUMN service_levels TEXT[] NOT NULL DEFAULT '{}';
+
+COMMENT ON COLUMN couriers.tracking_url_template IS 'Tracking link with a {tracking_number} placeholder';
+COMMENT ON COLUMN couriers.tracking_number_pattern IS 'Regular expression a tracking number must fully match (optional)';
+COMMENT ON COLUMN couriers.service_levels IS 'Service levels offered by the courier, e.g. Express, Ground';
+
+-- Seed the couriers whose tracking links used to be built into the application
+INSERT INTO couriers (name, contact_info, tracking_url_template, service_levels, created_at, updated_at)
+VALUES
+    ('UPS', NULL, 'https://www.ups.com/track?tracknum={tracking_number}', ARRAY['Ground', 'Express', 'Next Day Air'], NOW(), NOW()),
+    ('FedEx', NULL, 'https://www.fedex.com/fedextrack/?tracknumbers={tracking_number}', ARRAY['Ground', 'Express', 'International Priority'], NOW(), NOW()),
+    ('DHL', NULL, 'http://www.dhl.com/en/express/tracking.html?AWB={tracking_number}', ARRAY['Express', 'Economy Select'], NOW(), NOW())
+ON CONFLICT (name) DO UPDATE
+SET tracking_url_template = COALESCE(couriers.tracking_url_template, EXCLUDED.tracking_url_template),
+    service_levels = CASE WHEN cardinality(couriers.service_levels) = 0 THEN EXCLUDED.service_levels ELSE couriers.service_levels END,
+    updated_at = NOW();
@@ -0,0 +1,9 @@
+ALTER TABLE shipments
+    DROP COLUMN IF EXISTS customs_currency,
+    DROP COLUMN IF EXISTS recipient_tax_id,
+    DROP COLUMN IF EXISTS export_reason;
+
+ALTER TABLE laptops
+    DROP COLUMN IF EXISTS country_of_origin,
+    DROP COLUMN IF EXISTS declared_value,
+    DROP COLUMN IF EXISTS device_category;
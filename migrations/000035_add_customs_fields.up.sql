@@ -0,0 +1,18 @@
+-- Customs details printed on commercial invoices of international deliveries
+ALTER TABLE laptops
+    ADD COLUMN device_category VARCHAR(50) NOT NULL DEFAULT 'laptop',
+    ADD COLUMN declared_value NUMERIC(12, 2) CHECK (declared_value >= 0),
+    ADD COLUMN country_of_origin VARCHAR(100);
+
+COMMENT ON COLUMN laptops.device_category IS 'Device category, which determines the HS code declared to customs';
+COMMENT ON COLUMN laptops.declared_value IS 'Value declared to customs, in the currency of the shipment';
+COMMENT ON COLUMN laptops.country_of_origin IS 'Country where the device was manufactured';
+
+ALTER TABLE shipments
+    ADD COLUMN export_reason VARCHAR(50),
+    ADD COLUMN recipient_tax_id VARCHAR(100),
+    ADD COLUMN customs_currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+COMMENT ON COLUMN shipments.export_reason IS 'Reason for export declared on the commercial invoice';
+COMMENT ON COLUMN shipments.recipient_tax_id IS 'Tax ID of the recipient (e.g. VAT, CPF, RFC), required by some destination countries';
+COMMENT ON COLUMN shipments.customs_currency IS 'ISO 4217 currency of the declared values';
@@ -0,0 +1,10 @@
+-- Remove service account audit entries, which have no user
+DELETE FROM audit_logs WHERE user_id IS NULL;
+
+ALTER TABLE audit_logs
+    DROP COLUMN IF EXISTS service_account_id,
+    ALTER COLUMN user_id SET NOT NULL;
+
+-- Drop api_tokens and service_accounts tables
+DROP TABLE IF EXISTS api_tokens;
+DROP TABLE IF EXISTS service_accounts;
@@ -0,0 +1,58 @@
+-- Create service_accounts table
+-- Non-human API clients (scripts, integrations). They act with a role like a user
+-- but cannot log in to the web interface.
+CREATE TABLE IF NOT EXISTS service_accounts (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL UNIQUE,
+    description TEXT,
+    role user_role NOT NULL,
+    client_company_id BIGINT REFERENCES client_companies(id) ON DELETE RESTRICT,
+    created_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    disabled_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Create api_tokens table
+-- Only the SHA-256 hash of a token is stored; the token itself is shown once when created.
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    token_prefix VARCHAR(16) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    user_id BIGINT REFERENCES users(id) ON DELETE CASCADE,
+    service_account_id BIGINT REFERENCES service_accounts(id) ON DELETE CASCADE,
+    created_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    expires_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    revoked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_api_tokens_owner CHECK ((user_id IS NULL) <> (service_account_id IS NULL))
+);
+
+-- Create indexes for better query performance
+CREATE INDEX idx_api_tokens_user_id ON api_tokens(user_id);
+CREATE INDEX idx_api_tokens_service_account_id ON api_tokens(service_account_id);
+
+-- Changes made by service accounts are audited without a user
+ALTER TABLE audit_logs
+    ALTER COLUMN user_id DROP NOT NULL,
+    ADD COLUMN service_account_id BIGINT REFERENCES service_accounts(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_audit_logs_service_account_id ON audit_logs(service_account_id);
+
+-- Comment on tables and columns
+COMMENT ON TABLE service_accounts IS 'API clients that are not tied to a person';
+COMMENT ON COLUMN service_accounts.role IS 'Role the service account acts with, same rules as users';
+COMMENT ON COLUMN service_accounts.client_company_id IS 'Company a client service account is limited to';
+COMMENT ON COLUMN service_accounts.disabled_at IS 'When the service account was disabled (null if active)';
+
+COMMENT ON TABLE api_tokens IS 'Personal access tokens and service account tokens for the JSON API';
+COMMENT ON COLUMN api_tokens.token_hash IS 'Hex SHA-256 of the token';
+COMMENT ON COLUMN api_tokens.token_prefix IS 'First characters of the token, shown to tell tokens apart';
+COMMENT ON COLUMN api_tokens.scopes IS 'Granted scopes: read, shipments:write, inventory:write';
+COMMENT ON COLUMN api_tokens.expires_at IS 'When the token expires (null if it never expires)';
+COMMENT ON COLUMN api_tokens.revoked_at IS 'When the token was revoked (null if active)';
+COMMENT ON COLUMN audit_logs.service_account_id IS 'Service account that made the change, when not made by a user';
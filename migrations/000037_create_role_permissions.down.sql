@@ -0,0 +1,2 @@
+-- Drop role_permissions table
+DROP TABLE IF EXISTS role_permissions;
@@ -0,0 +1,16 @@
+-- Create role_permissions table
+-- The default permissions of each role are defined in code (internal/permissions); this table
+-- only stores the grants and revocations an admin made on top of them.
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role user_role NOT NULL,
+    permission VARCHAR(100) NOT NULL,
+    granted BOOLEAN NOT NULL,
+    updated_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (role, permission)
+);
+
+-- Comment on table and columns
+COMMENT ON TABLE role_permissions IS 'Changes to the default permissions of each role';
+COMMENT ON COLUMN role_permissions.permission IS 'Permission key, e.g. shipment.update_status';
+COMMENT ON COLUMN role_permissions.granted IS 'True to grant the permission to the role, false to revoke it';
@@ -0,0 +1,3 @@
+-- Drop user_client_companies table
+DROP INDEX IF EXISTS idx_user_client_companies_company;
+DROP TABLE IF EXISTS user_client_companies;
@@ -0,0 +1,15 @@
+-- Create user_client_companies table
+-- Assigns project managers to the client companies in their portfolio. Client users keep
+-- using users.client_company_id; logistics and warehouse users are not limited by company.
+CREATE TABLE IF NOT EXISTS user_client_companies (
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_company_id BIGINT NOT NULL REFERENCES client_companies(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, client_company_id)
+);
+
+-- Create index for looking up the users of a company
+CREATE INDEX idx_user_client_companies_company ON user_client_companies(client_company_id);
+
+-- Comment on table
+COMMENT ON TABLE user_client_companies IS 'Client companies a project manager is responsible for';
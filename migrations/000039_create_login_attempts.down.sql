@@ -0,0 +1,7 @@
+-- Drop login_attempts table and lockout columns
+ALTER TABLE users DROP COLUMN IF EXISTS locked_until;
+ALTER TABLE users DROP COLUMN IF EXISTS failed_login_count;
+DROP INDEX IF EXISTS idx_login_attempts_user;
+DROP INDEX IF EXISTS idx_login_attempts_email;
+DROP INDEX IF EXISTS idx_login_attempts_ip;
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,29 @@
+-- Create login_attempts table
+-- Every password and magic link login is recorded; failures are counted per IP address and per
+-- email to throttle brute-force attempts across all app instances.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    method VARCHAR(20) NOT NULL CHECK (method IN ('password', 'magic_link')),
+    email VARCHAR(255),
+    user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    reason VARCHAR(50),
+    attempted_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip_address, attempted_at) WHERE success = FALSE;
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email, attempted_at) WHERE success = FALSE;
+CREATE INDEX IF NOT EXISTS idx_login_attempts_user ON login_attempts(user_id, attempted_at);
+
+-- Account lockout after repeated password failures
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+-- Comment on table and columns
+COMMENT ON TABLE login_attempts IS 'Password and magic link login attempts, used for throttling';
+COMMENT ON COLUMN login_attempts.email IS 'Lowercased email submitted with a password login';
+COMMENT ON COLUMN login_attempts.reason IS 'Why the attempt failed, e.g. invalid_password, locked, throttled';
+COMMENT ON COLUMN users.failed_login_count IS 'Consecutive failed password logins since the last success or unlock';
+COMMENT ON COLUMN users.locked_until IS 'Password logins are refused until this time';
@@ -0,0 +1,8 @@
+-- Remove TOTP two-factor authentication
+DROP INDEX IF EXISTS idx_two_factor_challenges_expires_at;
+DROP TABLE IF EXISTS two_factor_challenges;
+DROP INDEX IF EXISTS idx_user_recovery_codes_user;
+DROP TABLE IF EXISTS user_recovery_codes;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_last_step;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_enabled_at;
+ALTER TABLE users DROP COLUMN IF EXISTS totp_secret;
@@ -0,0 +1,37 @@
+-- Add TOTP two-factor authentication
+-- totp_secret is set when a user starts enrolling; 2FA is only active once totp_enabled_at is set,
+-- after the user confirmed a code from the authenticator app.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step BIGINT;
+
+-- Single-use recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_recovery_codes_user ON user_recovery_codes(user_id);
+
+-- Logins waiting for the second factor; the token is kept in a cookie and stored as a SHA-256 hash
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    id BIGSERIAL PRIMARY KEY,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_url TEXT NOT NULL,
+    failed_attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_challenges_expires_at ON two_factor_challenges(expires_at);
+
+-- Comment on tables and columns
+COMMENT ON COLUMN users.totp_secret IS 'Base32 TOTP secret; pending until totp_enabled_at is set';
+COMMENT ON COLUMN users.totp_enabled_at IS 'When two-factor authentication was enabled';
+COMMENT ON COLUMN users.totp_last_step IS 'Time step of the last accepted code, so a code cannot be used twice';
+COMMENT ON TABLE user_recovery_codes IS 'Single-use codes to sign in without the authenticator app';
+COMMENT ON TABLE two_factor_challenges IS 'Password logins waiting for a TOTP or recovery code';
@@ -0,0 +1,9 @@
+-- Remove invitation and password reset links
+ALTER TABLE users DROP COLUMN IF EXISTS invitation_accepted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS invitation_expires_at;
+ALTER TABLE users DROP COLUMN IF EXISTS invited_at;
+
+DROP INDEX IF EXISTS idx_magic_links_user_purpose;
+DELETE FROM magic_links WHERE purpose <> 'login';
+ALTER TABLE magic_links DROP CONSTRAINT IF EXISTS chk_magic_links_purpose;
+ALTER TABLE magic_links DROP COLUMN IF EXISTS purpose;
@@ -0,0 +1,18 @@
+-- Invitation and password reset links reuse the magic_links table
+-- Login links (the existing rows) sign the user in; account links let the user set a password.
+ALTER TABLE magic_links ADD COLUMN IF NOT EXISTS purpose VARCHAR(20) NOT NULL DEFAULT 'login';
+ALTER TABLE magic_links ADD CONSTRAINT chk_magic_links_purpose
+    CHECK (purpose IN ('login', 'invitation', 'password_reset'));
+
+CREATE INDEX IF NOT EXISTS idx_magic_links_user_purpose ON magic_links(user_id, purpose, created_at);
+
+-- Invitation state is kept on the user so it survives the cleanup of used and expired links
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invited_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invitation_expires_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS invitation_accepted_at TIMESTAMP;
+
+-- Comment on columns
+COMMENT ON COLUMN magic_links.purpose IS 'login (sign in, e.g. to fill a form), invitation or password_reset (set a password)';
+COMMENT ON COLUMN users.invited_at IS 'When the last invitation email was sent (null for users created with a password)';
+COMMENT ON COLUMN users.invitation_expires_at IS 'When the last invitation link expires';
+COMMENT ON COLUMN users.invitation_accepted_at IS 'When the invited user set their password';
@@ -0,0 +1,15 @@
+-- Hashed tokens cannot be turned back into tokens: sessions are ended and
+-- unused links stop working. The hash fills the token column to keep it unique.
+DELETE FROM sessions;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS token VARCHAR(255);
+ALTER TABLE sessions ALTER COLUMN token SET NOT NULL;
+ALTER TABLE sessions ADD CONSTRAINT sessions_token_key UNIQUE (token);
+CREATE INDEX IF NOT EXISTS idx_sessions_token ON sessions(token);
+ALTER TABLE sessions DROP COLUMN IF EXISTS token_hash;
+
+ALTER TABLE magic_links ADD COLUMN IF NOT EXISTS token VARCHAR(255);
+UPDATE magic_links SET token = token_hash;
+ALTER TABLE magic_links ALTER COLUMN token SET NOT NULL;
+ALTER TABLE magic_links ADD CONSTRAINT magic_links_token_key UNIQUE (token);
+CREATE INDEX IF NOT EXISTS idx_magic_links_token ON magic_links(token);
+ALTER TABLE magic_links DROP COLUMN IF EXISTS token_hash;
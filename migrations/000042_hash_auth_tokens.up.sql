@@ -0,0 +1,20 @@
+-- Store only the SHA-256 hash of session and magic link tokens, so a copy of the
+-- database or a backup cannot be used to take over sessions or open links.
+-- Existing rows are hashed in place, so signed-in users and sent links keep working.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS token_hash CHAR(64);
+UPDATE sessions SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+ALTER TABLE sessions ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE sessions ADD CONSTRAINT sessions_token_hash_key UNIQUE (token_hash);
+DROP INDEX IF EXISTS idx_sessions_token;
+ALTER TABLE sessions DROP COLUMN token;
+
+ALTER TABLE magic_links ADD COLUMN IF NOT EXISTS token_hash CHAR(64);
+UPDATE magic_links SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+ALTER TABLE magic_links ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE magic_links ADD CONSTRAINT magic_links_token_hash_key UNIQUE (token_hash);
+DROP INDEX IF EXISTS idx_magic_links_token;
+ALTER TABLE magic_links DROP COLUMN token;
+
+-- Comment on columns
+COMMENT ON COLUMN sessions.token_hash IS 'Hex SHA-256 of the session token; the token is only in the cookie';
+COMMENT ON COLUMN magic_links.token_hash IS 'Hex SHA-256 of the link token; the token is only in the sent URL';
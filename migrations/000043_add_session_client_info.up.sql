@@ -0,0 +1,13 @@
+-- Record where each session signed in from and when it was last used,
+-- so users and administrators can recognise and revoke sessions
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45);
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP;
+UPDATE sessions SET last_seen_at = created_at WHERE last_seen_at IS NULL;
+ALTER TABLE sessions ALTER COLUMN last_seen_at SET NOT NULL;
+ALTER TABLE sessions ALTER COLUMN last_seen_at SET DEFAULT NOW();
+
+-- Comment on columns
+COMMENT ON COLUMN sessions.ip_address IS 'Client IP address at sign-in (null for sessions created before it was recorded)';
+COMMENT ON COLUMN sessions.user_agent IS 'Browser user agent at sign-in';
+COMMENT ON COLUMN sessions.last_seen_at IS 'Last request made with the session, updated at most once a minute';
@@ -0,0 +1,7 @@
+DELETE FROM login_attempts WHERE method = 'oidc';
+ALTER TABLE login_attempts DROP CONSTRAINT IF EXISTS login_attempts_method_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_method_check
+    CHECK (method IN ('password', 'magic_link'));
+
+DROP INDEX IF EXISTS idx_user_identities_user_id;
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,25 @@
+-- Create user_identities table
+-- Links users to accounts at OpenID Connect providers. A user can sign in with any linked identity.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMP,
+    CONSTRAINT uq_user_identities_provider_subject UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities(user_id);
+
+-- OIDC logins are recorded with the other login attempts
+ALTER TABLE login_attempts DROP CONSTRAINT IF EXISTS login_attempts_method_check;
+ALTER TABLE login_attempts ADD CONSTRAINT login_attempts_method_check
+    CHECK (method IN ('password', 'magic_link', 'oidc'));
+
+-- Comment on table and columns
+COMMENT ON TABLE user_identities IS 'Accounts at OpenID Connect providers linked to users';
+COMMENT ON COLUMN user_identities.provider IS 'Provider ID from OIDC_PROVIDERS';
+COMMENT ON COLUMN user_identities.subject IS 'Stable user identifier at the provider (sub claim)';
+COMMENT ON COLUMN user_identities.email IS 'Email the provider reported at the last login';
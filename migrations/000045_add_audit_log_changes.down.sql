@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_audit_logs_user_timestamp;
+DROP INDEX IF EXISTS idx_audit_logs_entity_timestamp;
+
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS changes;
@@ -0,0 +1,9 @@
+-- Store the fields an audited edit changed next to the other details
+ALTER TABLE audit_logs
+    ADD COLUMN changes JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+-- The audit log viewer lists entries newest first, usually filtered by entity or user
+CREATE INDEX IF NOT EXISTS idx_audit_logs_entity_timestamp ON audit_logs(entity_type, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_logs_user_timestamp ON audit_logs(user_id, timestamp DESC);
+
+COMMENT ON COLUMN audit_logs.changes IS 'Fields changed by the action: [{"field", "before", "after"}]';
@@ -0,0 +1,36 @@
+-- Make the audit log tamper-evident.
+-- Each entry stores the hash of the entry before it and a SHA-256 hash over its own content and
+-- that previous hash, computed by the application (internal/audit). Changing, removing or
+-- reordering an entry breaks the chain, which `go run ./cmd/auditverify` reports.
+-- Entries written before this migration are not chained; the chain starts at the first entry
+-- with a hash.
+ALTER TABLE audit_logs
+    ADD COLUMN prev_hash CHAR(64),
+    ADD COLUMN hash CHAR(64);
+
+-- Entries outlive the users and service accounts they name: removing one must neither delete
+-- the entries (ON DELETE CASCADE) nor rewrite them (ON DELETE SET NULL)
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_user_id_fkey;
+ALTER TABLE audit_logs DROP CONSTRAINT IF EXISTS audit_logs_service_account_id_fkey;
+
+-- Reject updates and deletes so the log stays append-only, whatever the role
+CREATE OR REPLACE FUNCTION prevent_audit_log_changes()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_logs_append_only
+    BEFORE UPDATE OR DELETE ON audit_logs
+    FOR EACH ROW
+    EXECUTE FUNCTION prevent_audit_log_changes();
+
+-- Privileges cannot restrict the owner of the table or a superuser, so the application must
+-- connect with a separate role that does not own the tables and is only granted SELECT and
+-- INSERT on audit_logs (see docs/DATABASE_SETUP.md). TRUNCATE is left to the owner, which
+-- empties the log of test databases.
+
+-- Comment on columns
+COMMENT ON COLUMN audit_logs.prev_hash IS 'Hash of the previous entry of the chain (64 zeros for the first entry)';
+COMMENT ON COLUMN audit_logs.hash IS 'Hex SHA-256 over the content of the entry and prev_hash (NULL for entries written before the chain started)';
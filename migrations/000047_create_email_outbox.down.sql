@@ -0,0 +1,2 @@
+-- Drop email_outbox table
+DROP TABLE IF EXISTS email_outbox;
@@ -0,0 +1,26 @@
+-- Create email_outbox table
+-- Notification emails are queued here in the same transaction as the change they announce
+-- and delivered by a background worker, so an SMTP outage or a restart does not lose them.
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(100) NOT NULL,
+    subject_id BIGINT NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+-- The worker only looks at messages that are due
+CREATE INDEX idx_email_outbox_due ON email_outbox(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_email_outbox_status ON email_outbox(status, created_at);
+
+-- Comment on table and columns
+COMMENT ON TABLE email_outbox IS 'Notification emails waiting to be delivered, with their delivery attempts';
+COMMENT ON COLUMN email_outbox.kind IS 'Notification to send, e.g. warehouse_pre_alert';
+COMMENT ON COLUMN email_outbox.subject_id IS 'Shipment or reception report the notification is about, depending on the kind';
+COMMENT ON COLUMN email_outbox.status IS 'pending until delivered (sent) or until every attempt failed (dead)';
+COMMENT ON COLUMN email_outbox.next_attempt_at IS 'Earliest time of the next delivery attempt, pushed back exponentially after each failure';
+COMMENT ON COLUMN email_outbox.last_error IS 'Error of the last failed attempt';
@@ -0,0 +1,2 @@
+-- Drop email_template_versions table
+DROP TABLE IF EXISTS email_template_versions;
@@ -0,0 +1,25 @@
+-- Create email_template_versions table
+-- Every save of an email template adds a version; the highest version of a template is the one
+-- that is sent. Rolling back saves an earlier version again, so the history is never rewritten.
+-- The built-in templates are seeded as version 1 when the application starts.
+CREATE TABLE IF NOT EXISTS email_template_versions (
+    id BIGSERIAL PRIMARY KEY,
+    template_name VARCHAR(100) NOT NULL,
+    version INTEGER NOT NULL CHECK (version > 0),
+    subject TEXT NOT NULL,
+    html_body TEXT NOT NULL,
+    text_body TEXT NOT NULL DEFAULT '',
+    comment TEXT NOT NULL DEFAULT '',
+    created_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (template_name, version)
+);
+
+-- Comment on table and columns
+COMMENT ON TABLE email_template_versions IS 'Version history of the email templates; the highest version of each template is current';
+COMMENT ON COLUMN email_template_versions.template_name IS 'Template the version belongs to, e.g. warehouse_pre_alert';
+COMMENT ON COLUMN email_template_versions.subject IS 'Go text template of the subject line';
+COMMENT ON COLUMN email_template_versions.html_body IS 'Go HTML template rendered inside the common email layout';
+COMMENT ON COLUMN email_template_versions.text_body IS 'Go text template of the plain text part, empty to derive it from the HTML';
+COMMENT ON COLUMN email_template_versions.comment IS 'Note about the change, e.g. the version it was restored from';
+COMMENT ON COLUMN email_template_versions.created_by_user_id IS 'User who saved the version, NULL for the seeded defaults';
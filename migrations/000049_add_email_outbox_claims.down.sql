@@ -0,0 +1,11 @@
+-- Remove outbox claims
+DROP INDEX IF EXISTS idx_email_outbox_claims;
+
+-- Messages being sent are retried by the previous worker
+UPDATE email_outbox SET status = 'pending' WHERE status = 'sending';
+
+ALTER TABLE email_outbox DROP COLUMN IF EXISTS requested_by_user_id;
+ALTER TABLE email_outbox DROP COLUMN IF EXISTS locked_until;
+
+ALTER TABLE email_outbox DROP CONSTRAINT IF EXISTS email_outbox_status_check;
+ALTER TABLE email_outbox ADD CONSTRAINT email_outbox_status_check CHECK (status IN ('pending', 'sent', 'dead'));
@@ -0,0 +1,19 @@
+-- Claim outbox messages before sending them
+-- A worker marks a message as sending and commits before it talks to the SMTP server, so no row lock
+-- is held during delivery. If the worker stops before recording the result, the claim expires at
+-- locked_until and the message is picked up again.
+ALTER TABLE email_outbox DROP CONSTRAINT IF EXISTS email_outbox_status_check;
+ALTER TABLE email_outbox ADD CONSTRAINT email_outbox_status_check CHECK (status IN ('pending', 'sending', 'sent', 'dead'));
+
+ALTER TABLE email_outbox ADD COLUMN locked_until TIMESTAMP;
+
+-- Account emails (invitations, password resets) are about a user and may name the user who asked for them
+ALTER TABLE email_outbox ADD COLUMN requested_by_user_id BIGINT REFERENCES users(id) ON DELETE SET NULL;
+
+-- Claims left behind by a stopped worker
+CREATE INDEX idx_email_outbox_claims ON email_outbox(locked_until) WHERE status = 'sending';
+
+COMMENT ON COLUMN email_outbox.status IS 'pending until claimed by a worker (sending), then delivered (sent) or pending again until every attempt failed (dead)';
+COMMENT ON COLUMN email_outbox.subject_id IS 'Shipment, reception report or user the notification is about, depending on the kind';
+COMMENT ON COLUMN email_outbox.locked_until IS 'End of the claim of the worker sending the message; an expired claim is taken over by another worker';
+COMMENT ON COLUMN email_outbox.requested_by_user_id IS 'User whose action queued the message, e.g. the admin sending an invitation';
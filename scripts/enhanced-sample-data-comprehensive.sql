@@ -19,7 +19,8 @@
 -- =============================================
 -- CLEAN EXISTING DATA
 -- =============================================
-DELETE FROM audit_logs;
+-- audit_logs is append-only and rejects DELETE; its owner may still empty it
+TRUNCATE audit_logs;
 DELETE FROM magic_links;
 DELETE FROM sessions;
 DELETE FROM delivery_forms;
@@ -11,7 +11,6 @@ Write-Host ""
 
 Write-Host "Step 1: Clearing existing shipments and forms..." -ForegroundColor Cyan
 docker exec -i laptop-tracking-db psql -U postgres -d laptop_tracking_dev -c "
-DELETE FROM audit_logs WHERE entity_type = 'shipment';
 DELETE FROM delivery_forms;
 DELETE FROM reception_reports;
 DELETE FROM pickup_forms;
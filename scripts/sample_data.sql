@@ -9,7 +9,7 @@
 -- CLEAR EXISTING DATA
 -- ============================================
 -- Clear in reverse order of dependencies
-DELETE FROM audit_logs;
+TRUNCATE audit_logs;
 DELETE FROM magic_links;
 DELETE FROM sessions;
 DELETE FROM delivery_forms;
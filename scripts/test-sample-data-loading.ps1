@@ -27,7 +27,7 @@ Write-Host ""
 # Test 2: Clear existing data
 Write-Host "Test 2: Clearing existing data..." -ForegroundColor Yellow
 docker exec -i laptop-tracking-db psql -U postgres -d laptop_tracking_dev -c "
-DELETE FROM audit_logs;
+TRUNCATE audit_logs;
 DELETE FROM magic_links;
 DELETE FROM sessions;
 DELETE FROM delivery_forms;
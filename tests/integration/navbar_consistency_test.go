@@ -15,6 +15,7 @@ import (
 	"github.com/yourusername/laptop-tracking-system/internal/handlers"
 	"github.com/yourusername/laptop-tracking-system/internal/middleware"
 	"github.com/yourusername/laptop-tracking-system/internal/models"
+	"github.com/yourusername/laptop-tracking-system/internal/permissions"
 	"github.com/yourusername/laptop-tracking-system/internal/utils"
 	"github.com/yourusername/laptop-tracking-system/internal/views"
 )
@@ -279,6 +280,11 @@ func loadTemplatesWithNavigation(t *testing.T) *template.Template {
 		"getNav": func(role models.UserRole) views.NavigationLinks {
 			return views.GetNavigationLinks(role)
 		},
+		"can": func(user *models.User, perm string) bool {
+			return permissions.Can(user, permissions.Permission(perm))
+		},
+		// Hidden CSRF token field for POST forms
+		"csrfField": middleware.CSRFField,
 		// Calendar template functions
 		"formatDate": func(t interface{}) string {
 			return ""